@@ -1,19 +1,25 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"text/tabwriter"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 
 	"github.com/modelpack/model-csi-driver/pkg/client"
 	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/service"
 	"github.com/modelpack/model-csi-driver/pkg/status"
 )
 
@@ -25,6 +31,34 @@ type VolumeInfo struct {
 	Status status.Status
 }
 
+// formatProgress formats the downloaded bytes of the pull, e.g.
+// "31.0% (12 GiB / 40 GiB, 310 MiB/s, ~1m30s remaining)", or "-" if the size
+// is unknown.
+func formatProgress(progress status.Progress) string {
+	if progress.TotalBytes <= 0 {
+		return "-"
+	}
+	formatted := fmt.Sprintf(
+		"%.1f%% (%s / %s",
+		progress.Percentage(), humanize.IBytes(uint64(progress.DownloadedBytes)), humanize.IBytes(uint64(progress.TotalBytes)),
+	)
+	if progress.Throughput > 0 {
+		formatted += fmt.Sprintf(", %s/s", humanize.IBytes(uint64(progress.Throughput)))
+	}
+	if progress.RemainingSeconds > 0 {
+		formatted += fmt.Sprintf(", ~%s remaining", time.Duration(progress.RemainingSeconds)*time.Second)
+	}
+	return formatted + ")"
+}
+
+// formatSize returns the size of the pulled model, "-" until it's pulled.
+func formatSize(size int64) string {
+	if size <= 0 {
+		return "-"
+	}
+	return humanize.IBytes(uint64(size))
+}
+
 func getVolumeInfo(c *cli.Context) (*VolumeInfo, error) {
 	workDir := c.String("workdir")
 	sockPath := filepath.Join(workDir, "csi", "csi.sock")
@@ -61,17 +95,21 @@ func main() {
 		Version: version,
 		Flags: []cli.Flag{
 			&cli.StringFlag{Name: "log-level", Value: "info", Usage: "Set the logging level [trace, debug, info, warn, error, fatal, panic]"},
-			&cli.StringFlag{Name: "workdir", Value: "/home/admin/model-csi", Usage: "The work directory for model csi"},
+			&cli.StringFlag{Name: "workdir", Value: "/home/admin/model-csi", EnvVars: []string{"MODEL_CSI_WORKDIR"}, Usage: "The work directory for model csi, set it to select the driver instance if several are mounted"},
 		},
 		Commands: []*cli.Command{
 			{
 				Name:  "mount",
 				Usage: "Mount a model by a specified reference and id",
 				Flags: []cli.Flag{
-					&cli.StringFlag{Name: "type", Required: false, Usage: "The model type to mount", Value: "image"},
+					&cli.StringFlag{Name: "type", Required: false, Usage: "The model type to mount, image or huggingface", Value: "image"},
 					&cli.StringFlag{Name: "reference", Required: true, Usage: "The model reference to mount"},
 					&cli.StringFlag{Name: "mount-id", Required: true, Usage: "The mount id"},
 					&cli.BoolFlag{Name: "check-disk-quota", Required: false, Usage: "The disk quota check", Value: false},
+					&cli.DurationFlag{Name: "ttl", Required: false, Usage: "Delete the mount once it's not refreshed for the duration, e.g. 8h"},
+					&cli.BoolFlag{Name: "async", Required: false, Usage: "Return once the pull is started instead of waiting for it", Value: false},
+					&cli.BoolFlag{Name: "force", Required: false, Usage: "Pull the model of the existing mount again, even by another reference", Value: false},
+					&cli.StringSliceFlag{Name: "label", Required: false, Usage: "The label of the mount as key=value, can be repeated"},
 				},
 				Action: func(c *cli.Context) error {
 					info, err := getVolumeInfo(c)
@@ -85,7 +123,33 @@ func main() {
 						return errors.Wrap(err, "create client")
 					}
 
-					_, err = client.CreateMount(c.Context, info.Status.VolumeName, mountID, c.String("reference"), c.Bool("check-disk-quota"))
+					req := service.MountRequest{
+						Type:           c.String("type"),
+						MountID:        mountID,
+						Reference:      c.String("reference"),
+						CheckDiskQuota: c.Bool("check-disk-quota"),
+						TTLSeconds:     uint(c.Duration("ttl").Seconds()),
+					}
+					for _, label := range c.StringSlice("label") {
+						key, value, ok := strings.Cut(label, "=")
+						if !ok {
+							return errors.Errorf("invalid label: %s", label)
+						}
+						if req.Labels == nil {
+							req.Labels = map[string]string{}
+						}
+						req.Labels[key] = value
+					}
+					if c.Bool("force") {
+						_, err = client.UpdateMount(c.Context, info.Status.VolumeName, mountID, service.UpdateMountRequest{
+							MountRequest: req,
+							Force:        true,
+						}, c.Bool("async"))
+					} else if c.Bool("async") {
+						_, err = client.CreateMountAsync(c.Context, info.Status.VolumeName, req)
+					} else {
+						_, err = client.CreateMountWithRequest(c.Context, info.Status.VolumeName, req)
+					}
 					if err != nil {
 						return errors.Wrap(err, "create mount")
 					}
@@ -94,11 +158,65 @@ func main() {
 					return nil
 				},
 			},
+			{
+				Name:  "refresh",
+				Usage: "Refresh the mount created with the TTL by a specified mount id",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "mount-id", Required: true, Usage: "The mount id"},
+				},
+				Action: func(c *cli.Context) error {
+					info, err := getVolumeInfo(c)
+					if err != nil {
+						return err
+					}
+					mountID := c.String("mount-id")
+
+					client, err := client.NewHTTPClient(info.Addr)
+					if err != nil {
+						return errors.Wrap(err, "create client")
+					}
+
+					if err := client.RefreshMount(c.Context, info.Status.VolumeName, mountID); err != nil {
+						return errors.Wrap(err, "refresh mount")
+					}
+					fmt.Println(mountID)
+
+					return nil
+				},
+			},
+			{
+				Name:  "cancel",
+				Usage: "Cancel the pull in progress of the mount by a specified mount id, the mount is kept in PULL_CANCELED state",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "mount-id", Required: true, Usage: "The mount id"},
+				},
+				Action: func(c *cli.Context) error {
+					info, err := getVolumeInfo(c)
+					if err != nil {
+						return err
+					}
+					mountID := c.String("mount-id")
+
+					client, err := client.NewHTTPClient(info.Addr)
+					if err != nil {
+						return errors.Wrap(err, "create client")
+					}
+
+					mount, err := client.CancelMount(c.Context, info.Status.VolumeName, mountID)
+					if err != nil {
+						return errors.Wrap(err, "cancel mount")
+					}
+					fmt.Println(mount.State)
+
+					return nil
+				},
+			},
 			{
 				Name:  "umount",
 				Usage: "Umount a model by a specified mount id",
 				Flags: []cli.Flag{
 					&cli.StringFlag{Name: "mount-id", Required: true, Usage: "The mount id"},
+					&cli.BoolFlag{Name: "force", Usage: "Cancel the pull of the mount being pulled before deleting it"},
 				},
 				Action: func(c *cli.Context) error {
 					info, err := getVolumeInfo(c)
@@ -112,7 +230,11 @@ func main() {
 						return errors.Wrap(err, "create client")
 					}
 
-					if err := client.DeleteMount(c.Context, info.Status.VolumeName, mountID); err != nil {
+					deleteMount := client.DeleteMount
+					if c.Bool("force") {
+						deleteMount = client.ForceDeleteMount
+					}
+					if err := deleteMount(c.Context, info.Status.VolumeName, mountID); err != nil {
 						return errors.Wrap(err, "delete mount")
 					}
 					fmt.Println(mountID)
@@ -141,12 +263,12 @@ func main() {
 					}
 
 					tw := tabwriter.NewWriter(os.Stdout, 1, 8, 1, '\t', 0)
-					if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\n", "Mount ID", "Reference", "State"); err != nil {
+					if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", "Mount ID", "Reference", "State", "Size", "Progress"); err != nil {
 						return errors.Wrap(err, "write header")
 					}
 
 					for _, mount := range mounts {
-						if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\n", mount.MountID, mount.Reference, mount.State); err != nil {
+						if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", mount.MountID, mount.Reference, mount.State, formatSize(mount.SizeInBytes), formatProgress(mount.Progress)); err != nil {
 							return errors.Wrap(err, "write mount")
 						}
 					}
@@ -155,6 +277,540 @@ func main() {
 						return errors.Wrap(err, "flush output")
 					}
 
+					return nil
+				},
+			},
+			{
+				Name:  "info",
+				Usage: "Show the driver serving the volume and the disk quota of the node",
+				Flags: []cli.Flag{},
+				Action: func(c *cli.Context) error {
+					info, err := getVolumeInfo(c)
+					if err != nil {
+						return err
+					}
+
+					client, err := client.NewHTTPClient(info.Addr)
+					if err != nil {
+						return errors.Wrap(err, "create client")
+					}
+
+					if err := client.Healthz(c.Context); err != nil {
+						return errors.Wrap(err, "check health")
+					}
+					serverInfo, err := client.GetInfo(c.Context)
+					if err != nil {
+						return errors.Wrap(err, "get info")
+					}
+
+					tw := tabwriter.NewWriter(os.Stdout, 1, 8, 1, '\t', 0)
+					for _, item := range [][2]string{
+						{"Driver", fmt.Sprintf("%s %s", serverInfo.ServiceName, serverInfo.Version)},
+						{"Node", serverInfo.NodeID},
+						{"Volume", serverInfo.VolumeName},
+						{"Disk Quota Check", fmt.Sprintf("%t", serverInfo.DiskQuota.Enabled)},
+						{"Disk Available", fmt.Sprintf("%s of %s", formatSize(serverInfo.DiskQuota.AvailableSize), formatSize(serverInfo.DiskQuota.TotalSize))},
+					} {
+						if _, err := fmt.Fprintf(tw, "%s:\t%s\n", item[0], item[1]); err != nil {
+							return errors.Wrap(err, "write info")
+						}
+					}
+
+					if err := tw.Flush(); err != nil {
+						return errors.Wrap(err, "flush output")
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "usage",
+				Usage: "Show the disk usage of the mounts of the volume",
+				Flags: []cli.Flag{},
+				Action: func(c *cli.Context) error {
+					info, err := getVolumeInfo(c)
+					if err != nil {
+						return err
+					}
+
+					client, err := client.NewHTTPClient(info.Addr)
+					if err != nil {
+						return errors.Wrap(err, "create client")
+					}
+
+					usage, err := client.GetVolumeUsage(c.Context, info.Status.VolumeName)
+					if err != nil {
+						return errors.Wrap(err, "get volume usage")
+					}
+
+					tw := tabwriter.NewWriter(os.Stdout, 1, 8, 1, '\t', 0)
+					if _, err := fmt.Fprintln(tw, "MOUNT ID\tREFERENCE\tSTATE\tUSED"); err != nil {
+						return errors.Wrap(err, "write header")
+					}
+					for _, mount := range usage.Mounts {
+						if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", mount.MountID, mount.Reference, mount.State, formatSize(mount.UsedBytes)); err != nil {
+							return errors.Wrap(err, "write mount")
+						}
+					}
+					if _, err := fmt.Fprintf(tw, "TOTAL\t\t\t%s\n", formatSize(usage.UsedBytes)); err != nil {
+						return errors.Wrap(err, "write total")
+					}
+
+					if err := tw.Flush(); err != nil {
+						return errors.Wrap(err, "flush output")
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "inspect",
+				Usage: "Inspect the files and the size of a model without pulling it",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "reference", Required: true, Usage: "The model reference to inspect"},
+				},
+				Action: func(c *cli.Context) error {
+					info, err := getVolumeInfo(c)
+					if err != nil {
+						return err
+					}
+
+					client, err := client.NewHTTPClient(info.Addr)
+					if err != nil {
+						return errors.Wrap(err, "create client")
+					}
+
+					inspection, err := client.InspectModel(c.Context, c.String("reference"))
+					if err != nil {
+						return errors.Wrap(err, "inspect model")
+					}
+
+					tw := tabwriter.NewWriter(os.Stdout, 1, 8, 1, '\t', 0)
+					if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\n", "Path", "Size", "Weights"); err != nil {
+						return errors.Wrap(err, "write header")
+					}
+
+					for _, file := range inspection.Files {
+						if _, err := fmt.Fprintf(tw, "%s\t%s\t%t\n", file.Path, formatSize(file.Size), file.Weights); err != nil {
+							return errors.Wrap(err, "write file")
+						}
+					}
+
+					if err := tw.Flush(); err != nil {
+						return errors.Wrap(err, "flush output")
+					}
+					fmt.Printf("Total: %s, weights: %s, layers: %d\n", formatSize(inspection.TotalSize), formatSize(inspection.WeightsSize), inspection.LayerCount)
+
+					return nil
+				},
+			},
+			{
+				Name:  "tags",
+				Usage: "List the tags of a model repository by the registry auth of the node",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "repository", Required: true, Usage: "The model repository, e.g. registry.example.com/models/qwen3"},
+					&cli.StringFlag{Name: "last", Required: false, Usage: "List the tags after the tag, printed as the next page"},
+					&cli.IntFlag{Name: "limit", Required: false, Usage: "The max number of the tags to list, 100 by default"},
+				},
+				Action: func(c *cli.Context) error {
+					info, err := getVolumeInfo(c)
+					if err != nil {
+						return err
+					}
+
+					client, err := client.NewHTTPClient(info.Addr)
+					if err != nil {
+						return errors.Wrap(err, "create client")
+					}
+
+					resp, err := client.ListTags(c.Context, service.ListTagsRequest{
+						Repository: c.String("repository"),
+						Last:       c.String("last"),
+						Limit:      c.Int("limit"),
+					})
+					if err != nil {
+						return errors.Wrap(err, "list tags")
+					}
+					for _, tag := range resp.Tags {
+						fmt.Println(tag)
+					}
+					if resp.Next != "" {
+						if _, err := fmt.Fprintf(os.Stderr, "More tags after: --last %s\n", resp.Next); err != nil {
+							return errors.Wrap(err, "write next")
+						}
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "prefetch",
+				Usage: "Prefetch a model into the node without a volume",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "create",
+						Usage: "Prefetch a model by a specified reference",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "type", Required: false, Usage: "The model type to prefetch, image or huggingface", Value: "image"},
+							&cli.StringFlag{Name: "reference", Required: true, Usage: "The model reference to prefetch"},
+							&cli.StringFlag{Name: "platform", Required: false, Usage: "The platform selected from the image index, e.g. linux/arm64"},
+						},
+						Action: func(c *cli.Context) error {
+							info, err := getVolumeInfo(c)
+							if err != nil {
+								return err
+							}
+
+							client, err := client.NewHTTPClient(info.Addr)
+							if err != nil {
+								return errors.Wrap(err, "create client")
+							}
+
+							prefetch, err := client.Prefetch(c.Context, service.PrefetchRequest{
+								Type:      c.String("type"),
+								Reference: c.String("reference"),
+								Platform:  c.String("platform"),
+							})
+							if err != nil {
+								return errors.Wrap(err, "create prefetch")
+							}
+							fmt.Println(prefetch.VolumeName)
+
+							return nil
+						},
+					},
+					{
+						Name:  "list",
+						Usage: "List all prefetched models",
+						Flags: []cli.Flag{},
+						Action: func(c *cli.Context) error {
+							info, err := getVolumeInfo(c)
+							if err != nil {
+								return err
+							}
+
+							client, err := client.NewHTTPClient(info.Addr)
+							if err != nil {
+								return errors.Wrap(err, "create client")
+							}
+
+							prefetches, err := client.ListPrefetches(c.Context)
+							if err != nil {
+								return errors.Wrap(err, "list prefetches")
+							}
+
+							tw := tabwriter.NewWriter(os.Stdout, 1, 8, 1, '\t', 0)
+							if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", "Name", "Reference", "State", "Size"); err != nil {
+								return errors.Wrap(err, "write header")
+							}
+
+							for _, prefetch := range prefetches {
+								if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", prefetch.VolumeName, prefetch.Reference, prefetch.State, formatSize(prefetch.SizeInBytes)); err != nil {
+									return errors.Wrap(err, "write prefetch")
+								}
+							}
+
+							if err := tw.Flush(); err != nil {
+								return errors.Wrap(err, "flush output")
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:  "cancel",
+						Usage: "Cancel a prefetch and remove the prefetched model",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "name", Required: true, Usage: "The prefetch name"},
+						},
+						Action: func(c *cli.Context) error {
+							info, err := getVolumeInfo(c)
+							if err != nil {
+								return err
+							}
+							name := c.String("name")
+
+							client, err := client.NewHTTPClient(info.Addr)
+							if err != nil {
+								return errors.Wrap(err, "create client")
+							}
+
+							if err := client.CancelPrefetch(c.Context, name); err != nil {
+								return errors.Wrap(err, "cancel prefetch")
+							}
+							fmt.Println(name)
+
+							return nil
+						},
+					},
+					{
+						Name:  "export",
+						Usage: "Export a prefetched model as a tarball to pre-seed other nodes",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "name", Required: true, Usage: "The prefetch name"},
+							&cli.StringFlag{Name: "output", Required: true, Usage: "The path of the tarball to write"},
+						},
+						Action: func(c *cli.Context) error {
+							info, err := getVolumeInfo(c)
+							if err != nil {
+								return err
+							}
+
+							client, err := client.NewHTTPClient(info.Addr)
+							if err != nil {
+								return errors.Wrap(err, "create client")
+							}
+
+							output := c.String("output")
+							file, err := os.Create(output)
+							if err != nil {
+								return errors.Wrapf(err, "create file: %s", output)
+							}
+							defer func() { _ = file.Close() }()
+
+							hash := sha256.New()
+							if err := client.ExportPrefetch(c.Context, c.String("name"), io.MultiWriter(file, hash)); err != nil {
+								_ = os.Remove(output)
+								return errors.Wrap(err, "export prefetch")
+							}
+							if err := file.Close(); err != nil {
+								return errors.Wrapf(err, "close file: %s", output)
+							}
+							// The digest is verified by the import.
+							fmt.Printf("sha256:%s\n", hex.EncodeToString(hash.Sum(nil)))
+
+							return nil
+						},
+					},
+					{
+						Name:  "import",
+						Usage: "Import a model tarball exported from another node as a prefetch",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "input", Required: true, Usage: "The path of the tarball to import"},
+							&cli.StringFlag{Name: "digest", Required: false, Usage: "The digest of the tarball printed by the export, e.g. sha256:..."},
+						},
+						Action: func(c *cli.Context) error {
+							info, err := getVolumeInfo(c)
+							if err != nil {
+								return err
+							}
+
+							client, err := client.NewHTTPClient(info.Addr)
+							if err != nil {
+								return errors.Wrap(err, "create client")
+							}
+
+							input := c.String("input")
+							file, err := os.Open(input)
+							if err != nil {
+								return errors.Wrapf(err, "open file: %s", input)
+							}
+							defer func() { _ = file.Close() }()
+
+							prefetch, err := client.ImportPrefetch(c.Context, file, c.String("digest"))
+							if err != nil {
+								return errors.Wrap(err, "import prefetch")
+							}
+							fmt.Println(prefetch.VolumeName)
+
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:  "pin",
+				Usage: "Pin the cached models on the node against the eviction",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "add",
+						Usage: "Pin a model by a specified reference",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "reference", Required: true, Usage: "The model reference to pin"},
+						},
+						Action: func(c *cli.Context) error {
+							info, err := getVolumeInfo(c)
+							if err != nil {
+								return err
+							}
+							reference := c.String("reference")
+
+							client, err := client.NewHTTPClient(info.Addr)
+							if err != nil {
+								return errors.Wrap(err, "create client")
+							}
+
+							if err := client.PinModel(c.Context, reference); err != nil {
+								return errors.Wrap(err, "pin model")
+							}
+							fmt.Println(reference)
+
+							return nil
+						},
+					},
+					{
+						Name:  "remove",
+						Usage: "Unpin a model by a specified reference",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "reference", Required: true, Usage: "The model reference to unpin"},
+						},
+						Action: func(c *cli.Context) error {
+							info, err := getVolumeInfo(c)
+							if err != nil {
+								return err
+							}
+							reference := c.String("reference")
+
+							client, err := client.NewHTTPClient(info.Addr)
+							if err != nil {
+								return errors.Wrap(err, "create client")
+							}
+
+							if err := client.UnpinModel(c.Context, reference); err != nil {
+								return errors.Wrap(err, "unpin model")
+							}
+							fmt.Println(reference)
+
+							return nil
+						},
+					},
+					{
+						Name:  "list",
+						Usage: "List all pinned models",
+						Flags: []cli.Flag{},
+						Action: func(c *cli.Context) error {
+							info, err := getVolumeInfo(c)
+							if err != nil {
+								return err
+							}
+
+							client, err := client.NewHTTPClient(info.Addr)
+							if err != nil {
+								return errors.Wrap(err, "create client")
+							}
+
+							references, err := client.ListPins(c.Context)
+							if err != nil {
+								return errors.Wrap(err, "list pins")
+							}
+							for _, reference := range references {
+								fmt.Println(reference)
+							}
+
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:  "gc",
+				Usage: "Clean up the cached models and the unused blobs on the node",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "target-free", Required: false, Usage: "Evict the cached models until the free disk quota reaches the size, e.g. 100GiB"},
+					&cli.DurationFlag{Name: "max-age", Required: false, Usage: "Evict the cached models not pulled or cloned for the duration, e.g. 168h"},
+					&cli.BoolFlag{Name: "dry-run", Required: false, Usage: "Print what would be deleted without deleting it", Value: false},
+				},
+				Action: func(c *cli.Context) error {
+					info, err := getVolumeInfo(c)
+					if err != nil {
+						return err
+					}
+
+					targetFree := uint64(0)
+					if c.String("target-free") != "" {
+						if targetFree, err = humanize.ParseBytes(c.String("target-free")); err != nil {
+							return errors.Wrap(err, "parse target free size")
+						}
+					}
+
+					client, err := client.NewHTTPClient(info.Addr)
+					if err != nil {
+						return errors.Wrap(err, "create client")
+					}
+
+					resp, err := client.GC(c.Context, service.GCRequest{
+						TargetFreeBytes: int64(targetFree),
+						MaxAgeInSeconds: int64(c.Duration("max-age").Seconds()),
+						DryRun:          c.Bool("dry-run"),
+					})
+					if err != nil {
+						return errors.Wrap(err, "gc")
+					}
+
+					tw := tabwriter.NewWriter(os.Stdout, 1, 8, 1, '\t', 0)
+					if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", "Name", "Reference", "Size", "Last Used"); err != nil {
+						return errors.Wrap(err, "write header")
+					}
+					for _, model := range resp.Models {
+						if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", model.Name, model.Reference, humanize.IBytes(uint64(model.Size)), model.LastUsed.Format(time.RFC3339)); err != nil {
+							return errors.Wrap(err, "write model")
+						}
+					}
+					for _, blob := range resp.Blobs {
+						if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", "blob", blob.Digest, humanize.IBytes(uint64(blob.Size)), "-"); err != nil {
+							return errors.Wrap(err, "write blob")
+						}
+					}
+					if err := tw.Flush(); err != nil {
+						return errors.Wrap(err, "flush output")
+					}
+
+					verb := "reclaimed"
+					if resp.DryRun {
+						verb = "would reclaim"
+					}
+					fmt.Printf("%s %s\n", verb, humanize.IBytes(uint64(resp.ReclaimedBytes)))
+
+					return nil
+				},
+			},
+			{
+				Name:  "reconcile",
+				Usage: "Remove the orphaned volume dirs on the node, e.g. whose status is missing or whose PV is gone",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "dry-run", Required: false, Usage: "Print the orphaned volume dirs without removing them", Value: false},
+				},
+				Action: func(c *cli.Context) error {
+					info, err := getVolumeInfo(c)
+					if err != nil {
+						return err
+					}
+
+					client, err := client.NewHTTPClient(info.Addr)
+					if err != nil {
+						return errors.Wrap(err, "create client")
+					}
+
+					resp, err := client.ReconcileVolumes(c.Context, service.ReconcileRequest{
+						DryRun: c.Bool("dry-run"),
+					})
+					if err != nil {
+						return errors.Wrap(err, "reconcile")
+					}
+
+					tw := tabwriter.NewWriter(os.Stdout, 1, 8, 1, '\t', 0)
+					if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", "Volume", "Mount ID", "Reason", "Size", "Removed"); err != nil {
+						return errors.Wrap(err, "write header")
+					}
+					for _, volume := range resp.Volumes {
+						mountID := volume.MountID
+						if mountID == "" {
+							mountID = "-"
+						}
+						if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\n", volume.VolumeName, mountID, volume.Reason, humanize.IBytes(uint64(volume.Size)), volume.Removed); err != nil {
+							return errors.Wrap(err, "write volume")
+						}
+					}
+					if err := tw.Flush(); err != nil {
+						return errors.Wrap(err, "flush output")
+					}
+
+					verb := "reclaimed"
+					if resp.DryRun {
+						verb = "would reclaim"
+					}
+					fmt.Printf("%s %s\n", verb, humanize.IBytes(uint64(resp.ReclaimedBytes)))
+
 					return nil
 				},
 			},
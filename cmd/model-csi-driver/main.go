@@ -3,8 +3,10 @@ package main
 import (
 	"fmt"
 	"os"
+	"text/tabwriter"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
@@ -12,6 +14,8 @@ import (
 	"github.com/modelpack/model-csi-driver/pkg/config"
 	"github.com/modelpack/model-csi-driver/pkg/logger"
 	"github.com/modelpack/model-csi-driver/pkg/server"
+	"github.com/modelpack/model-csi-driver/pkg/service"
+	"github.com/modelpack/model-csi-driver/pkg/tracing"
 )
 
 var revision string
@@ -37,6 +41,99 @@ func main() {
 				Required: true,
 			},
 		},
+		Commands: []*cli.Command{
+			{
+				Name:  "bench",
+				Usage: "Benchmark pulling a model with the real puller without CSI",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "reference", Required: true, Usage: "The model reference to pull"},
+					&cli.UintSliceFlag{Name: "concurrency", Usage: "The pull concurrency, repeat it to compare several values"},
+					&cli.BoolFlag{Name: "exclude-model-weights", Usage: "Pull the model without weights", Value: false},
+					&cli.StringSliceFlag{Name: "exclude-file-patterns", Usage: "The file patterns to exclude from pulling"},
+				},
+				Action: func(c *cli.Context) error {
+					cfg, err := config.New(c.String("config"))
+					if err != nil {
+						return errors.Wrap(err, "load config")
+					}
+					if err := tracing.Init(cfg); err != nil {
+						return errors.Wrap(err, "init tracing")
+					}
+					results, err := service.Bench(c.Context, cfg, service.BenchOptions{
+						Reference:           c.String("reference"),
+						Concurrencies:       c.UintSlice("concurrency"),
+						ExcludeModelWeights: c.Bool("exclude-model-weights"),
+						ExcludeFilePatterns: c.StringSlice("exclude-file-patterns"),
+					})
+					if err != nil {
+						return errors.Wrap(err, "bench")
+					}
+
+					tw := tabwriter.NewWriter(os.Stdout, 1, 8, 1, '\t', 0)
+					if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+						"Concurrency", "Duration", "Size", "Throughput", "CPU", "Disk Util", "Disk Written"); err != nil {
+						return errors.Wrap(err, "write header")
+					}
+					for _, result := range results {
+						diskUtil, diskWritten := "-", "-"
+						if result.DiskUtilization >= 0 {
+							diskUtil = fmt.Sprintf("%.1f%%", result.DiskUtilization*100)
+							diskWritten = humanize.IBytes(uint64(result.DiskWrittenBytes))
+						}
+						if _, err := fmt.Fprintf(tw, "%d\t%s\t%s\t%s/s\t%.2f cores\t%s\t%s\n",
+							result.Concurrency,
+							result.Duration.Round(time.Millisecond),
+							humanize.IBytes(uint64(result.PulledBytes)),
+							humanize.IBytes(uint64(result.Throughput)),
+							result.CPUUsage,
+							diskUtil,
+							diskWritten,
+						); err != nil {
+							return errors.Wrap(err, "write result")
+						}
+					}
+					if err := tw.Flush(); err != nil {
+						return errors.Wrap(err, "flush output")
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "migrate",
+				Usage: "Relocate the volumes tree to a new root dir while the driver is stopped",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "new-root-dir", Required: true, Usage: "The new root dir to relocate the volumes tree to"},
+				},
+				Action: func(c *cli.Context) error {
+					cfg, err := config.New(c.String("config"))
+					if err != nil {
+						return errors.Wrap(err, "load config")
+					}
+					result, err := service.MigrateRootDir(c.Context, cfg, c.String("new-root-dir"))
+					if err != nil {
+						return errors.Wrap(err, "migrate root dir")
+					}
+					logger.Logger().Infof(
+						"migrated %d volumes, remounted %d target paths, update root_dir to %s and restart the driver",
+						len(result.Volumes), len(result.Remounted), c.String("new-root-dir"),
+					)
+					if len(result.Remounted) > 0 {
+						logger.Logger().Warnf(
+							"running containers still use %s, restart the pods of the remounted target paths: %v",
+							cfg.Get().RootDir, result.Remounted,
+						)
+					}
+					if len(result.Stale) > 0 {
+						logger.Logger().Warnf(
+							"volumes still mounted from %s, restart their pods: %v",
+							cfg.Get().RootDir, result.Stale,
+						)
+					}
+					return nil
+				},
+			},
+		},
 		Action: func(c *cli.Context) error {
 			cfg, err := config.New(c.String("config"))
 			if err != nil {
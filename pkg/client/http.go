@@ -9,15 +9,21 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"path"
+	"path/filepath"
 	"strings"
 
+	"github.com/modelpack/model-csi-driver/pkg/logger"
 	"github.com/pkg/errors"
 )
 
 type HTTPClient struct {
 	baseURL url.URL
 	client  *http.Client
+	// The bearer token beside the csi.sock, minted by the driver with
+	// features.dynamic_server_auth enabled.
+	tokenPath string
 }
 
 func NewHTTPClient(addr string) (*HTTPClient, error) {
@@ -40,9 +46,15 @@ func NewHTTPClient(addr string) (*HTTPClient, error) {
 		},
 	}
 
+	tokenPath := ""
+	if url.Scheme == "unix" {
+		tokenPath = filepath.Join(filepath.Dir(url.Path), "token")
+	}
+
 	return &HTTPClient{
-		baseURL: *baseURL,
-		client:  &client,
+		baseURL:   *baseURL,
+		client:    &client,
+		tokenPath: tokenPath,
 	}, nil
 }
 
@@ -56,6 +68,33 @@ func (client *HTTPClient) request(ctx context.Context, method, endpoint string,
 		}
 	}
 
+	resp, err := client.do(ctx, method, endpoint, payload, "application/json", query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return nil, fmt.Errorf("broken api endpoint")
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "read from body")
+	}
+
+	if ret != nil {
+		if err := json.Unmarshal(data, ret); err != nil {
+			return nil, errors.Wrap(err, "unmarshal body")
+		}
+	}
+
+	return data, nil
+}
+
+// do sends the request with the raw body, the response body must be closed
+// by the caller unless an error is returned.
+func (client *HTTPClient) do(ctx context.Context, method, endpoint string, body io.Reader, contentType string, query map[string]string) (*http.Response, error) {
 	url := client.baseURL
 	url.Path = path.Join(url.Path, endpoint)
 	for k, v := range query {
@@ -64,19 +103,30 @@ func (client *HTTPClient) request(ctx context.Context, method, endpoint string,
 		url.RawQuery = q.Encode()
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, url.String(), payload)
+	req, err := http.NewRequestWithContext(ctx, method, url.String(), body)
 	if err != nil {
 		return nil, errors.Wrap(err, "new request")
 	}
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
+	// The driver logs the request by the ID of the caller's request.
+	if requestID := logger.RequestID(ctx); requestID != "" {
+		req.Header.Set("X-Request-ID", requestID)
+	}
+	// The token is read per request since it's minted once the auth is
+	// enabled on the driver.
+	if client.tokenPath != "" {
+		if token, err := os.ReadFile(client.tokenPath); err == nil {
+			req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+		}
+	}
 
 	resp, err := client.client.Do(req)
 	if err != nil {
 		return nil, errors.Wrap(err, "do request")
 	}
-	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode >= http.StatusBadRequest {
+		defer func() { _ = resp.Body.Close() }()
 		msg, err := io.ReadAll(resp.Body)
 		if err != nil {
 			return nil, errors.Wrap(err, "read from body for error message")
@@ -84,22 +134,7 @@ func (client *HTTPClient) request(ctx context.Context, method, endpoint string,
 		return nil, errors.New(string(msg))
 	}
 
-	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
-		return nil, fmt.Errorf("broken api endpoint")
-	}
-
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errors.Wrap(err, "read from body")
-	}
-
-	if ret != nil {
-		if err := json.Unmarshal(data, ret); err != nil {
-			return nil, errors.Wrap(err, "unmarshal body")
-		}
-	}
-
-	return data, nil
+	return resp, nil
 }
 
 func dumpPayload(obj interface{}) (io.Reader, error) {
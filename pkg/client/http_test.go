@@ -1,16 +1,20 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/modelpack/model-csi-driver/pkg/logger"
 	"github.com/modelpack/model-csi-driver/pkg/status"
 	"github.com/stretchr/testify/require"
 )
@@ -109,6 +113,83 @@ func TestHTTPClient_ListMounts(t *testing.T) {
 	require.Len(t, items, 1)
 }
 
+func TestHTTPClient_Pins(t *testing.T) {
+	mux := http.NewServeMux()
+	pins := map[string]bool{}
+	mux.HandleFunc("/api/v1/pins", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			var req struct {
+				Reference string `json:"reference"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			pins[req.Reference] = true
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			delete(pins, r.URL.Query().Get("reference"))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			references := []string{}
+			for reference := range pins {
+				references = append(references, reference)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(w).Encode(references)
+		}
+	})
+
+	sockPath := setupTestHTTPServer(t, mux)
+	client, err := NewHTTPClient("unix://" + sockPath)
+	require.NoError(t, err)
+
+	require.NoError(t, client.PinModel(context.Background(), "test/model:latest"))
+	references, err := client.ListPins(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"test/model:latest"}, references)
+
+	require.NoError(t, client.UnpinModel(context.Background(), "test/model:latest"))
+	references, err = client.ListPins(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, references)
+}
+
+func TestHTTPClient_ExportImportPrefetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/prefetch/prefetch-1/export", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-tar")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("tarball"))
+	})
+	mux.HandleFunc("/api/v1/prefetch/import", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		if string(body) != "tarball" || r.URL.Query().Get("digest") != "sha256:abc" {
+			http.Error(w, "digest mismatch", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(status.Status{VolumeName: "prefetch-1", State: status.StatePullSucceeded})
+	})
+
+	sockPath := setupTestHTTPServer(t, mux)
+	client, err := NewHTTPClient("unix://" + sockPath)
+	require.NoError(t, err)
+
+	var tarball bytes.Buffer
+	require.NoError(t, client.ExportPrefetch(context.Background(), "prefetch-1", &tarball))
+	require.Equal(t, "tarball", tarball.String())
+
+	result, err := client.ImportPrefetch(context.Background(), &tarball, "sha256:abc")
+	require.NoError(t, err)
+	require.Equal(t, "prefetch-1", result.VolumeName)
+
+	_, err = client.ImportPrefetch(context.Background(), bytes.NewReader([]byte("tarball")), "sha256:other")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "digest mismatch")
+}
+
 func TestHTTPClient_ServerError(t *testing.T) {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/v1/volumes/vol1/mounts", func(w http.ResponseWriter, r *http.Request) {
@@ -153,3 +234,54 @@ func TestHTTPClient_Request_HTMLResponse(t *testing.T) {
 	require.Contains(t, err.Error(), "broken api endpoint")
 }
 
+func TestHTTPClient_ForceDeleteMount(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/volumes/vol1/mounts/m1", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodDelete, r.Method)
+		require.Equal(t, "true", r.URL.Query().Get("force"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	sockPath := setupTestHTTPServer(t, mux)
+	client, err := NewHTTPClient("unix://" + sockPath)
+	require.NoError(t, err)
+
+	require.NoError(t, client.ForceDeleteMount(context.Background(), "vol1", "m1"))
+}
+
+func TestHTTPClient_Token(t *testing.T) {
+	mux := http.NewServeMux()
+	authorization := make(chan string, 2)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		authorization <- r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	})
+
+	sockPath := setupTestHTTPServer(t, mux)
+	client, err := NewHTTPClient("unix://" + sockPath)
+	require.NoError(t, err)
+
+	// No token beside the socket.
+	require.NoError(t, client.Healthz(context.Background()))
+	require.Empty(t, <-authorization)
+
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(sockPath), "token"), []byte("secret\n"), 0644))
+	require.NoError(t, client.Healthz(context.Background()))
+	require.Equal(t, "Bearer secret", <-authorization)
+}
+
+func TestHTTPClient_RequestID(t *testing.T) {
+	mux := http.NewServeMux()
+	requestID := make(chan string, 1)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		requestID <- r.Header.Get("X-Request-ID")
+		w.WriteHeader(http.StatusOK)
+	})
+
+	sockPath := setupTestHTTPServer(t, mux)
+	client, err := NewHTTPClient("unix://" + sockPath)
+	require.NoError(t, err)
+
+	require.NoError(t, client.Healthz(logger.WithRequestID(context.Background(), "req-1")))
+	require.Equal(t, "req-1", <-requestID)
+}
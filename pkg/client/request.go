@@ -2,11 +2,15 @@ package client
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/modelpack/model-csi-driver/pkg/service"
 	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
 )
 
 func (client *HTTPClient) CreateMount(ctx context.Context, volumeName, mountID, reference string, checkDiskQuota bool) (*status.Status, error) {
@@ -16,6 +20,12 @@ func (client *HTTPClient) CreateMount(ctx context.Context, volumeName, mountID,
 		CheckDiskQuota: checkDiskQuota,
 	}
 
+	return client.CreateMountWithRequest(ctx, volumeName, req)
+}
+
+// CreateMountWithRequest creates the mount with the full mount request, e.g.
+// for the models not in image type.
+func (client *HTTPClient) CreateMountWithRequest(ctx context.Context, volumeName string, req service.MountRequest) (*status.Status, error) {
 	var mountItem status.Status
 	if _, err := client.request(
 		ctx,
@@ -31,6 +41,145 @@ func (client *HTTPClient) CreateMount(ctx context.Context, volumeName, mountID,
 	return &mountItem, nil
 }
 
+// CreateMountAsync starts the pull of the mount and returns the mount in
+// PULLING state without waiting for the pull, the mount is polled by
+// GetMount until it's pulled or failed.
+func (client *HTTPClient) CreateMountAsync(ctx context.Context, volumeName string, req service.MountRequest) (*status.Status, error) {
+	var mountItem status.Status
+	if _, err := client.request(
+		ctx,
+		http.MethodPost,
+		fmt.Sprintf("/api/v1/volumes/%s/mounts", volumeName),
+		&req,
+		map[string]string{"async": "true"},
+		&mountItem,
+	); err != nil {
+		return nil, err
+	}
+
+	return &mountItem, nil
+}
+
+// CreateMounts creates the mounts of the batch, the result of each mount is
+// in the order of the mounts of the batch.
+func (client *HTTPClient) CreateMounts(ctx context.Context, volumeName string, req service.BatchMountRequest) (*service.BatchMountResponse, error) {
+	var resp service.BatchMountResponse
+	if _, err := client.request(
+		ctx,
+		http.MethodPost,
+		fmt.Sprintf("/api/v1/volumes/%s/mounts:batch", volumeName),
+		&req,
+		nil,
+		&resp,
+	); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// UpdateMount pulls the model of the existing mount again, with force, even by
+// another reference. With async, it returns once the pull is started.
+func (client *HTTPClient) UpdateMount(ctx context.Context, volumeName, mountID string, req service.UpdateMountRequest, async bool) (*status.Status, error) {
+	var mountItem status.Status
+	if _, err := client.request(
+		ctx,
+		http.MethodPut,
+		fmt.Sprintf("/api/v1/volumes/%s/mounts/%s", volumeName, mountID),
+		&req,
+		map[string]string{"async": strconv.FormatBool(async)},
+		&mountItem,
+	); err != nil {
+		return nil, err
+	}
+
+	return &mountItem, nil
+}
+
+// InspectModel returns the manifest-level info of the model without pulling
+// it.
+func (client *HTTPClient) InspectModel(ctx context.Context, reference string) (*service.ModelInspection, error) {
+	var inspection service.ModelInspection
+	if _, err := client.request(
+		ctx,
+		http.MethodGet,
+		"/api/v1/models/inspect",
+		nil,
+		map[string]string{"reference": reference},
+		&inspection,
+	); err != nil {
+		return nil, err
+	}
+
+	return &inspection, nil
+}
+
+// ListTags lists the tags of the model repository by pages, the next page is
+// listed by the next of the response as the last of the request.
+func (client *HTTPClient) ListTags(ctx context.Context, req service.ListTagsRequest) (*service.ListTagsResponse, error) {
+	query := map[string]string{"repository": req.Repository}
+	if req.Last != "" {
+		query["last"] = req.Last
+	}
+	if req.Limit > 0 {
+		query["limit"] = strconv.Itoa(req.Limit)
+	}
+
+	var resp service.ListTagsResponse
+	if _, err := client.request(
+		ctx,
+		http.MethodGet,
+		"/api/v1/models/tags",
+		nil,
+		query,
+		&resp,
+	); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// Healthz checks the driver serving the socket is healthy.
+func (client *HTTPClient) Healthz(ctx context.Context) error {
+	_, err := client.request(ctx, http.MethodGet, "/healthz", nil, nil, nil)
+	return err
+}
+
+// GetInfo returns the info of the driver serving the socket.
+func (client *HTTPClient) GetInfo(ctx context.Context) (*service.ServerInfo, error) {
+	var info service.ServerInfo
+	if _, err := client.request(
+		ctx,
+		http.MethodGet,
+		"/api/v1/info",
+		nil,
+		nil,
+		&info,
+	); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// GetVolumeUsage returns the disk usage of the mounts of the volume.
+func (client *HTTPClient) GetVolumeUsage(ctx context.Context, volumeName string) (*service.VolumeUsage, error) {
+	var usage service.VolumeUsage
+	if _, err := client.request(
+		ctx,
+		http.MethodGet,
+		fmt.Sprintf("/api/v1/volumes/%s/usage", volumeName),
+		nil,
+		nil,
+		&usage,
+	); err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
+
 func (client *HTTPClient) GetMount(ctx context.Context, volumeName, mountID string) (*status.Status, error) {
 	var mountItem status.Status
 	if _, err := client.request(
@@ -47,6 +196,24 @@ func (client *HTTPClient) GetMount(ctx context.Context, volumeName, mountID stri
 	return &mountItem, nil
 }
 
+// GetMountV2 returns the mount as the resource of the v2 API, with the
+// timestamps, the size and the error of the pull.
+func (client *HTTPClient) GetMountV2(ctx context.Context, volumeName, mountID string) (*service.MountResource, error) {
+	var mount service.MountResource
+	if _, err := client.request(
+		ctx,
+		http.MethodGet,
+		fmt.Sprintf("/api/v2/volumes/%s/mounts/%s", volumeName, mountID),
+		nil,
+		nil,
+		&mount,
+	); err != nil {
+		return nil, err
+	}
+
+	return &mount, nil
+}
+
 func (client *HTTPClient) DeleteMount(ctx context.Context, volumeName, mountID string) error {
 	if _, err := client.request(
 		ctx,
@@ -62,6 +229,57 @@ func (client *HTTPClient) DeleteMount(ctx context.Context, volumeName, mountID s
 	return nil
 }
 
+// ForceDeleteMount deletes the mount, the pull of the mount being pulled is
+// canceled first instead of being rejected.
+func (client *HTTPClient) ForceDeleteMount(ctx context.Context, volumeName, mountID string) error {
+	if _, err := client.request(
+		ctx,
+		http.MethodDelete,
+		fmt.Sprintf("/api/v1/volumes/%s/mounts/%s", volumeName, mountID),
+		nil,
+		map[string]string{"force": "true"},
+		nil,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RefreshMount extends the lease of the mount created with the TTL.
+func (client *HTTPClient) RefreshMount(ctx context.Context, volumeName, mountID string) error {
+	if _, err := client.request(
+		ctx,
+		http.MethodPost,
+		fmt.Sprintf("/api/v1/volumes/%s/mounts/%s/refresh", volumeName, mountID),
+		nil,
+		nil,
+		nil,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CancelMount cancels the pull in progress of the mount, the mount is kept
+// in PULL_CANCELED state until it's deleted or created again.
+func (client *HTTPClient) CancelMount(ctx context.Context, volumeName, mountID string) (*status.Status, error) {
+	var mountItem status.Status
+	if _, err := client.request(
+		ctx,
+		http.MethodPost,
+		fmt.Sprintf("/api/v1/volumes/%s/mounts/%s/cancel", volumeName, mountID),
+		nil,
+		nil,
+		&mountItem,
+	); err != nil {
+		return nil, err
+	}
+
+	return &mountItem, nil
+}
+
 func (client *HTTPClient) ListMounts(ctx context.Context, volumeName string) ([]status.Status, error) {
 	var mountItems []status.Status
 
@@ -78,3 +296,226 @@ func (client *HTTPClient) ListMounts(ctx context.Context, volumeName string) ([]
 
 	return mountItems, nil
 }
+
+// ListMountsWithRequest lists the page of the mounts matching the filters of
+// the request.
+func (client *HTTPClient) ListMountsWithRequest(ctx context.Context, volumeName string, req service.ListMountsRequest) ([]status.Status, error) {
+	query := map[string]string{}
+	if req.State != "" {
+		query["state"] = req.State
+	}
+	if req.Reference != "" {
+		query["reference"] = req.Reference
+	}
+	if req.Labels != "" {
+		query["labels"] = req.Labels
+	}
+	if req.Sort != "" {
+		query["sort"] = req.Sort
+	}
+	if req.Limit > 0 {
+		query["limit"] = strconv.Itoa(req.Limit)
+	}
+	if req.Offset > 0 {
+		query["offset"] = strconv.Itoa(req.Offset)
+	}
+
+	var mountItems []status.Status
+	if _, err := client.request(
+		ctx,
+		http.MethodGet,
+		fmt.Sprintf("/api/v1/volumes/%s/mounts", volumeName),
+		nil,
+		query,
+		&mountItems,
+	); err != nil {
+		return nil, err
+	}
+
+	return mountItems, nil
+}
+
+// Prefetch pulls the model into the node without a volume, the status of the
+// prefetch is returned while the model is pulled in the background.
+func (client *HTTPClient) Prefetch(ctx context.Context, req service.PrefetchRequest) (*status.Status, error) {
+	var prefetchItem status.Status
+	if _, err := client.request(
+		ctx,
+		http.MethodPost,
+		"/api/v1/prefetch",
+		&req,
+		nil,
+		&prefetchItem,
+	); err != nil {
+		return nil, err
+	}
+
+	return &prefetchItem, nil
+}
+
+func (client *HTTPClient) ListPrefetches(ctx context.Context) ([]status.Status, error) {
+	var prefetchItems []status.Status
+
+	if _, err := client.request(
+		ctx,
+		http.MethodGet,
+		"/api/v1/prefetch",
+		nil,
+		nil,
+		&prefetchItems,
+	); err != nil {
+		return nil, err
+	}
+
+	return prefetchItems, nil
+}
+
+func (client *HTTPClient) CancelPrefetch(ctx context.Context, name string) error {
+	if _, err := client.request(
+		ctx,
+		http.MethodDelete,
+		fmt.Sprintf("/api/v1/prefetch/%s", name),
+		nil,
+		nil,
+		nil,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ExportPrefetch writes the prefetched model as a tarball into the writer.
+func (client *HTTPClient) ExportPrefetch(ctx context.Context, name string, writer io.Writer) error {
+	resp, err := client.do(
+		ctx,
+		http.MethodGet,
+		fmt.Sprintf("/api/v1/prefetch/%s/export", name),
+		nil,
+		"application/json",
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if _, err := io.Copy(writer, resp.Body); err != nil {
+		return errors.Wrap(err, "read tarball")
+	}
+
+	return nil
+}
+
+// ImportPrefetch imports the tarball exported by ExportPrefetch as a prefetch,
+// the tarball is verified against the digest if it's not empty.
+func (client *HTTPClient) ImportPrefetch(ctx context.Context, reader io.Reader, digest string) (*status.Status, error) {
+	query := map[string]string{}
+	if digest != "" {
+		query["digest"] = digest
+	}
+	resp, err := client.do(
+		ctx,
+		http.MethodPost,
+		"/api/v1/prefetch/import",
+		reader,
+		"application/x-tar",
+		query,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var prefetchItem status.Status
+	if err := json.NewDecoder(resp.Body).Decode(&prefetchItem); err != nil {
+		return nil, errors.Wrap(err, "unmarshal body")
+	}
+
+	return &prefetchItem, nil
+}
+
+// GC runs the cleanup of the cached models and the unused blobs on the node,
+// the deleted models and blobs are returned, or the ones to be deleted on the
+// dry run.
+func (client *HTTPClient) GC(ctx context.Context, req service.GCRequest) (*service.GCResponse, error) {
+	var resp service.GCResponse
+	if _, err := client.request(
+		ctx,
+		http.MethodPost,
+		"/api/v1/gc",
+		&req,
+		nil,
+		&resp,
+	); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// ReconcileVolumes removes the orphaned volume dirs on the node, the dirs
+// found are returned, and only reported on the dry run.
+func (client *HTTPClient) ReconcileVolumes(ctx context.Context, req service.ReconcileRequest) (*service.ReconcileResponse, error) {
+	var resp service.ReconcileResponse
+	if _, err := client.request(
+		ctx,
+		http.MethodPost,
+		"/api/v1/reconcile",
+		&req,
+		nil,
+		&resp,
+	); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// PinModel pins the model of the reference on the node against the eviction.
+func (client *HTTPClient) PinModel(ctx context.Context, reference string) error {
+	if _, err := client.request(
+		ctx,
+		http.MethodPost,
+		"/api/v1/pins",
+		&service.PinRequest{Reference: reference},
+		nil,
+		nil,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (client *HTTPClient) UnpinModel(ctx context.Context, reference string) error {
+	if _, err := client.request(
+		ctx,
+		http.MethodDelete,
+		"/api/v1/pins",
+		nil,
+		map[string]string{"reference": reference},
+		nil,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (client *HTTPClient) ListPins(ctx context.Context) ([]string, error) {
+	var references []string
+
+	if _, err := client.request(
+		ctx,
+		http.MethodGet,
+		"/api/v1/pins",
+		nil,
+		nil,
+		&references,
+	); err != nil {
+		return nil, err
+	}
+
+	return references, nil
+}
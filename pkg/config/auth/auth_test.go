@@ -200,7 +200,178 @@ func TestGetKeyChainByRef_Valid(t *testing.T) {
 	require.NotNil(t, kc)
 }
 
+func TestRefreshKeyChainByRef(t *testing.T) {
+	tmpDir := t.TempDir()
+	dockerConfigPath := filepath.Join(tmpDir, "config.json")
+	writeAuth := func(pass string) {
+		auth := base64.StdEncoding.EncodeToString([]byte("user:" + pass))
+		configContent := fmt.Sprintf(`{"auths":{"refresh.registry.io":{"auth":"%s"}}}`, auth)
+		require.NoError(t, os.WriteFile(dockerConfigPath, []byte(configContent), 0600))
+	}
+	writeAuth("token-1")
+
+	keyChainCache.mutex.Lock()
+	delete(keyChainCache.data, "refresh.registry.io")
+	keyChainCache.mutex.Unlock()
+
+	t.Setenv("DOCKER_CONFIG", tmpDir)
+
+	kc, err := GetKeyChainByRef("refresh.registry.io/my-org/model:v1")
+	require.NoError(t, err)
+	require.Equal(t, "token-1", kc.Password)
+
+	// The cached auth is used until it's refreshed.
+	writeAuth("token-2")
+	kc, err = GetKeyChainByRef("refresh.registry.io/my-org/model:v1")
+	require.NoError(t, err)
+	require.Equal(t, "token-1", kc.Password)
+
+	kc, err = RefreshKeyChainByRef("refresh.registry.io/my-org/model:v1")
+	require.NoError(t, err)
+	require.Equal(t, "token-2", kc.Password)
+	kc, err = GetKeyChainByRef("refresh.registry.io/my-org/model:v1")
+	require.NoError(t, err)
+	require.Equal(t, "token-2", kc.Password)
+
+	_, err = RefreshKeyChainByRef(":::invalid:::")
+	require.Error(t, err)
+}
+
 func TestGetKeyChainByRef_InvalidRef(t *testing.T) {
 	_, err := GetKeyChainByRef(":::invalid:::")
 	require.Error(t, err)
 }
+
+// ─── credential helpers ──────────────────────────────────────────────────────
+
+// installCredentialHelper installs docker-credential-$name into PATH, which
+// responds the credentials of the hosts, or not found for the other hosts.
+func installCredentialHelper(t *testing.T, name string, credentials map[string]string) {
+	t.Helper()
+	binDir := t.TempDir()
+	script := "#!/bin/sh\nread host\ncase \"$host\" in\n"
+	for host, cred := range credentials {
+		script += fmt.Sprintf("%s) echo '%s' ;;\n", host, cred)
+	}
+	script += "*) echo 'credentials not found in native keychain'; exit 1 ;;\nesac\n"
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "docker-credential-"+name), []byte(script), 0755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestFromDockerConfig_CredentialHelpers(t *testing.T) {
+	tmpDir := t.TempDir()
+	auth := base64.StdEncoding.EncodeToString([]byte("static:pass"))
+	configContent := fmt.Sprintf(`{
+		"auths": {"static.helper.io": {"auth": "%s"}, "store.helper.io": {}},
+		"credHelpers": {"ecr.helper.io": "ecr-login", "acr.helper.io": "acr-env"},
+		"credsStore": "store"
+	}`, auth)
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "config.json"), []byte(configContent), 0600))
+	t.Setenv("DOCKER_CONFIG", tmpDir)
+
+	installCredentialHelper(t, "ecr-login", map[string]string{
+		"ecr.helper.io": `{"ServerURL":"ecr.helper.io","Username":"AWS","Secret":"ecr-token"}`,
+	})
+	installCredentialHelper(t, "acr-env", map[string]string{
+		"acr.helper.io": `{"ServerURL":"acr.helper.io","Username":"<token>","Secret":"refresh-token"}`,
+	})
+	installCredentialHelper(t, "store", map[string]string{
+		"store.helper.io": `{"ServerURL":"store.helper.io","Username":"user","Secret":"store-pass"}`,
+	})
+
+	hosts := []string{"ecr.helper.io", "acr.helper.io", "store.helper.io", "static.helper.io", "none.helper.io"}
+	keyChainCache.mutex.Lock()
+	for _, host := range hosts {
+		delete(keyChainCache.data, host)
+	}
+	keyChainCache.mutex.Unlock()
+
+	kc, err := FromDockerConfig("ecr.helper.io")
+	require.NoError(t, err)
+	require.Equal(t, &PassKeyChain{Username: "AWS", Password: "ecr-token"}, kc)
+
+	kc, err = FromDockerConfig("acr.helper.io")
+	require.NoError(t, err)
+	require.Equal(t, &PassKeyChain{IdentityToken: "refresh-token"}, kc)
+
+	// The credentials store is used for the host without the auth.
+	kc, err = FromDockerConfig("store.helper.io")
+	require.NoError(t, err)
+	require.Equal(t, &PassKeyChain{Username: "user", Password: "store-pass"}, kc)
+
+	kc, err = FromDockerConfig("static.helper.io")
+	require.NoError(t, err)
+	require.Equal(t, &PassKeyChain{Username: "static", Password: "pass"}, kc)
+
+	// The host without the credentials in the helper is accessed anonymously.
+	kc, err = FromDockerConfig("none.helper.io")
+	require.NoError(t, err)
+	require.Equal(t, &PassKeyChain{}, kc)
+}
+
+func TestFromDockerConfig_CredentialHelperFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+	configContent := `{"credHelpers": {"missing.helper.io": "missing"}}`
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "config.json"), []byte(configContent), 0600))
+	t.Setenv("DOCKER_CONFIG", tmpDir)
+	t.Setenv("PATH", t.TempDir())
+
+	keyChainCache.mutex.Lock()
+	delete(keyChainCache.data, "missing.helper.io")
+	keyChainCache.mutex.Unlock()
+
+	_, err := FromDockerConfig("missing.helper.io")
+	require.Error(t, err)
+}
+
+// ─── secret configs ──────────────────────────────────────────────────────────
+
+func TestSetSecretConfigs(t *testing.T) {
+	t.Cleanup(func() { _, _ = SetSecretConfigs(nil) })
+	t.Setenv("DOCKER_CONFIG", "/nonexistent/dir")
+
+	auth := base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	raw := map[string][]byte{
+		"model-csi/registry-auth": []byte(fmt.Sprintf(`{"auths":{"secret.test.io":{"auth":"%s"}}}`, auth)),
+	}
+	changed, err := SetSecretConfigs(raw)
+	require.NoError(t, err)
+	require.True(t, changed)
+	changed, err = SetSecretConfigs(raw)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	kc, err := FromDockerConfig("secret.test.io")
+	require.NoError(t, err)
+	require.Equal(t, "user", kc.Username)
+	require.Equal(t, "pass", kc.Password)
+
+	// The docker config file is optional with the secrets.
+	kc, err = FromDockerConfig("other.test.io")
+	require.NoError(t, err)
+	require.Equal(t, &PassKeyChain{}, kc)
+
+	_, err = SetSecretConfigs(map[string][]byte{"model-csi/invalid": []byte("{")})
+	require.Error(t, err)
+}
+
+func TestFromDockerConfigData(t *testing.T) {
+	auth := base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	data := []byte(fmt.Sprintf(`{"auths":{"volume.test.io":{"auth":"%s"},"https://index.docker.io/v1/":{"auth":"%s"}}}`, auth, auth))
+
+	kc, err := FromDockerConfigData(data, "volume.test.io")
+	require.NoError(t, err)
+	require.Equal(t, "user", kc.Username)
+	require.Equal(t, "pass", kc.Password)
+
+	kc, err = FromDockerConfigData(data, "registry-1.docker.io")
+	require.NoError(t, err)
+	require.Equal(t, "user", kc.Username)
+
+	kc, err = FromDockerConfigData(data, "other.test.io")
+	require.NoError(t, err)
+	require.Equal(t, &PassKeyChain{}, kc)
+
+	_, err = FromDockerConfigData([]byte("{"), "volume.test.io")
+	require.Error(t, err)
+}
@@ -36,6 +36,18 @@ func (c *cache) Set(host string, auth *PassKeyChain) *PassKeyChain {
 	return auth
 }
 
+func (c *cache) Delete(host string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.data, host)
+}
+
+func (c *cache) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.data = make(map[string]*PassKeyChain)
+}
+
 var keyChainCache = cache{
 	data: make(map[string]*PassKeyChain),
 }
@@ -50,6 +62,12 @@ type AuthConfig struct {
 
 type ConfigFile struct {
 	AuthConfigs map[string]AuthConfig `json:"auths"`
+	// The credential helpers by the registry host, e.g.
+	// {"123456789012.dkr.ecr.us-east-1.amazonaws.com": "ecr-login"}.
+	CredentialHelpers map[string]string `json:"credHelpers,omitempty"`
+	// The credential helper of the registries without the auth or the
+	// credential helper of the host.
+	CredentialsStore string `json:"credsStore,omitempty"`
 }
 
 func (configFile *ConfigFile) GetAuthConfig(host string) *AuthConfig {
@@ -108,6 +126,24 @@ func loadFromReader(configData io.Reader) (*ConfigFile, error) {
 	return &cf, nil
 }
 
+func dockerConfigHost(host string) string {
+	if host == convertedDockerHost {
+		return dockerHost
+	}
+	return host
+}
+
+// RefreshFromDockerConfig drops the cached auth of the host and finds it in
+// docker's config.json again, e.g. the short-lived registry token renewed on
+// the node during a long pull.
+func RefreshFromDockerConfig(host string) (*PassKeyChain, error) {
+	if len(host) == 0 {
+		return nil, fmt.Errorf("invalid host")
+	}
+	keyChainCache.Delete(dockerConfigHost(host))
+	return FromDockerConfig(host)
+}
+
 // FromDockerConfig finds auth for a given host in docker's config.json settings.
 func FromDockerConfig(host string) (*PassKeyChain, error) {
 	if len(host) == 0 {
@@ -117,14 +153,21 @@ func FromDockerConfig(host string) (*PassKeyChain, error) {
 	// The host of docker hub image will be converted to `registry-1.docker.io` in:
 	// github.com/containerd/containerd/remotes/docker/registry.go
 	// But we need use the key `https://index.docker.io/v1/` to find auth from docker config.
-	if host == convertedDockerHost {
-		host = dockerHost
-	}
+	host = dockerConfigHost(host)
 
 	if keyChain := keyChainCache.Get(host); keyChain != nil {
 		return keyChain, nil
 	}
 
+	authConfig, hasSecretConfigs := getSecretAuthConfig(host)
+	if authConfig != nil {
+		return keyChainCache.Set(host, &PassKeyChain{
+			Username:     authConfig.Username,
+			Password:     authConfig.Password,
+			ServerScheme: authConfig.ServerScheme,
+		}), nil
+	}
+
 	dockerConfigPath := "/root/.docker/config.json"
 	dockerConfigDir := os.Getenv("DOCKER_CONFIG")
 	if dockerConfigDir != "" {
@@ -133,6 +176,10 @@ func FromDockerConfig(host string) (*PassKeyChain, error) {
 
 	file, err := os.Open(dockerConfigPath)
 	if err != nil {
+		// The docker config file is optional with the auth from the Secrets.
+		if hasSecretConfigs && os.IsNotExist(err) {
+			return keyChainCache.Set(host, &PassKeyChain{}), nil
+		}
 		return nil, errors.Wrapf(err, "open docker config file from %s", dockerConfigPath)
 	}
 	defer func() { _ = file.Close() }()
@@ -142,15 +189,30 @@ func FromDockerConfig(host string) (*PassKeyChain, error) {
 		return nil, errors.Wrap(err, "load docker config file")
 	}
 
-	authConfig := config.GetAuthConfig(host)
-	if authConfig == nil {
-		return keyChainCache.Set(host, &PassKeyChain{}), nil
+	authConfig = config.GetAuthConfig(host)
+	keyChain := &PassKeyChain{}
+	if authConfig != nil {
+		keyChain = &PassKeyChain{
+			Username:     authConfig.Username,
+			Password:     authConfig.Password,
+			ServerScheme: authConfig.ServerScheme,
+		}
 	}
 
-	keyChain := &PassKeyChain{
-		Username:     authConfig.Username,
-		Password:     authConfig.Password,
-		ServerScheme: authConfig.ServerScheme,
+	// The same precedence as docker, the credential helper of the host, then
+	// the auth, then the credentials store.
+	helper := config.CredentialHelpers[host]
+	if helper == "" && keyChain.Username == "" && keyChain.Password == "" {
+		helper = config.CredentialsStore
+	}
+	if helper != "" {
+		helperKeyChain, err := getFromCredentialHelper(helper, host)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get auth of %s from credential helper %s", host, helper)
+		}
+		keyChain.Username = helperKeyChain.Username
+		keyChain.Password = helperKeyChain.Password
+		keyChain.IdentityToken = helperKeyChain.IdentityToken
 	}
 	keyChainCache.Set(host, keyChain)
 
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The timeout of the credential helper, which may request the token from the
+// cloud provider, e.g. docker-credential-ecr-login.
+var credentialHelperTimeout = 30 * time.Second
+
+// The username returned by the credential helper for the identity token, e.g.
+// by docker-credential-acr-env, which is exchanged for the registry token.
+const identityTokenUsername = "<token>"
+
+// The error of the credential helper if the host has no credentials.
+const credentialsNotFound = "credentials not found in native keychain"
+
+type credentialHelperResponse struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// getFromCredentialHelper gets the credentials of the host by the docker
+// credential helper, e.g. "ecr-login" runs docker-credential-ecr-login in
+// PATH, see https://github.com/docker/docker-credential-helpers. The empty
+// credentials are returned if the host has no credentials in the helper.
+func getFromCredentialHelper(helper, host string) (*PassKeyChain, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), credentialHelperTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(host)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		output := strings.TrimSpace(stdout.String() + stderr.String())
+		if strings.Contains(output, credentialsNotFound) {
+			return &PassKeyChain{}, nil
+		}
+		return nil, errors.Wrapf(err, "run credential helper: %s", output)
+	}
+
+	var resp credentialHelperResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, errors.Wrap(err, "unmarshal credential helper response")
+	}
+	if resp.Username == identityTokenUsername {
+		return &PassKeyChain{IdentityToken: resp.Secret}, nil
+	}
+
+	return &PassKeyChain{Username: resp.Username, Password: resp.Secret}, nil
+}
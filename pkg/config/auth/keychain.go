@@ -15,6 +15,9 @@ type PassKeyChain struct {
 	Username     string
 	Password     string
 	ServerScheme string
+	// The identity token from the credential helper, which is exchanged for
+	// the registry token instead of the username and password.
+	IdentityToken string
 }
 
 func GetKeyChainByRef(ref string) (*PassKeyChain, error) {
@@ -28,6 +31,19 @@ func GetKeyChainByRef(ref string) (*PassKeyChain, error) {
 	return FromDockerConfig(docker.Domain(named))
 }
 
+// RefreshKeyChainByRef finds the auth of the reference in docker's
+// config.json again instead of the cached one.
+func RefreshKeyChainByRef(ref string) (*PassKeyChain, error) {
+	// nolint
+	named, err := docker.ParseDockerRef(ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse ref %s", ref)
+	}
+
+	// nolint
+	return RefreshFromDockerConfig(docker.Domain(named))
+}
+
 func (kc *PassKeyChain) ToBase64() string {
 	if kc.Username == "" && kc.Password == "" {
 		return ""
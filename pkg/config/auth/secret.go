@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"bytes"
+	"maps"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// secretConfigs are the docker configs read from the Kubernetes Secrets of
+// type kubernetes.io/dockerconfigjson by the source, e.g. the path of the
+// mounted Secret or "namespace/name", which take precedence over the docker
+// config file.
+var secretConfigs = struct {
+	mutex   sync.Mutex
+	raw     map[string][]byte
+	sources []string
+	configs map[string]*ConfigFile
+}{
+	raw:     map[string][]byte{},
+	configs: map[string]*ConfigFile{},
+}
+
+// SetSecretConfigs replaces the docker configs read from the Secrets by the
+// source, the cached auth is dropped once any of them is changed, so that the
+// rotated credentials take effect on the next pull. It returns true if the
+// configs are changed.
+func SetSecretConfigs(raw map[string][]byte) (bool, error) {
+	configs := map[string]*ConfigFile{}
+	for source, data := range raw {
+		config, err := loadFromReader(bytes.NewReader(data))
+		if err != nil {
+			return false, errors.Wrapf(err, "load docker config from secret: %s", source)
+		}
+		configs[source] = config
+	}
+
+	secretConfigs.mutex.Lock()
+	defer secretConfigs.mutex.Unlock()
+
+	if maps.EqualFunc(secretConfigs.raw, raw, bytes.Equal) {
+		return false, nil
+	}
+	sources := make([]string, 0, len(raw))
+	for source := range raw {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	secretConfigs.raw = maps.Clone(raw)
+	secretConfigs.sources = sources
+	secretConfigs.configs = configs
+
+	keyChainCache.Reset()
+
+	return true, nil
+}
+
+// getSecretAuthConfig finds the auth of the host in the docker configs read
+// from the Secrets, in the order of the source.
+func getSecretAuthConfig(host string) (*AuthConfig, bool) {
+	secretConfigs.mutex.Lock()
+	defer secretConfigs.mutex.Unlock()
+
+	for _, source := range secretConfigs.sources {
+		if authConfig := secretConfigs.configs[source].GetAuthConfig(host); authConfig != nil {
+			return authConfig, true
+		}
+	}
+	return nil, len(secretConfigs.sources) > 0
+}
+
+// FromDockerConfigData finds the auth of the host in the docker config data,
+// e.g. the ".dockerconfigjson" in the CSI secrets of the volume, the empty
+// auth is returned if the host isn't found.
+func FromDockerConfigData(data []byte, host string) (*PassKeyChain, error) {
+	config, err := loadFromReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "load docker config")
+	}
+	authConfig := config.GetAuthConfig(dockerConfigHost(host))
+	if authConfig == nil {
+		return &PassKeyChain{}, nil
+	}
+	return &PassKeyChain{
+		Username:     authConfig.Username,
+		Password:     authConfig.Password,
+		ServerScheme: authConfig.ServerScheme,
+	}, nil
+}
@@ -1,13 +1,18 @@
 package config
 
 import (
+	"fmt"
+	"net"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"sync/atomic"
 
 	"github.com/dustin/go-humanize"
 	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/opencontainers/go-digest"
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
 )
@@ -39,6 +44,12 @@ type RawConfig struct {
 	RootDir                  string `yaml:"root_dir"`
 	ExternalCSIEndpoint      string `yaml:"external_csi_endpoint"`
 	ExternalCSIAuthorization string `yaml:"external_csi_authorization"`
+	// The storage roots besides root_dir, e.g. a larger HDD besides the local
+	// NVMe of root_dir, the models are placed on the first tier matched.
+	StorageTiers []StorageTier `yaml:"storage_tiers"`
+	// If set, the target path of NodePublishVolume/NodeUnpublishVolume must be
+	// located under $KubeletRootDir/pods, e.g. /var/lib/kubelet.
+	KubeletRootDir string `yaml:"kubelet_root_dir"`
 	// Deprecated: To ensure secure isolation for each dynamic mount and avoid
 	// unstable mount propagation, an independent csi.sock is currently created
 	// under each dynamic mount directory instead of using a shared csi.sock,
@@ -50,13 +61,432 @@ type RawConfig struct {
 	PprofAddr          string     `yaml:"pprof_addr"`
 	PullConfig         PullConfig `yaml:"pull_config"`
 	Features           Features   `yaml:"features"`
-	NodeID             string     // From env CSI_NODE_ID
-	Mode               string     // From env X_CSI_MODE: "controller" or "node"
+	// Restrict the model references allowed to be mounted on the node.
+	Policy PolicyConfig `yaml:"policy"`
+	// The models kept pulled on the node without a volume.
+	WarmModels WarmModelsConfig `yaml:"warm_models"`
+	// Only for the resilience testing in staging clusters.
+	FaultInjection FaultInjection `yaml:"fault_injection"`
+	NodeID         string         // From env CSI_NODE_ID
+	Mode           string         // From env X_CSI_MODE: "controller" or "node"
 }
 
 type Features struct {
 	CheckDiskQuota bool         `yaml:"check_disk_quota"`
 	DiskUsageLimit HumanizeSize `yaml:"disk_usage_limit"`
+	// Publish the references of the models cached on the node to the node
+	// annotation, so that schedulers can prefer the nodes holding the model.
+	// The annotation is removed once it's disabled by the config reload.
+	PublishCachedModels bool `yaml:"publish_cached_models"`
+	// Clean up the dynamic root volumes whose pod is gone (e.g. force deleted)
+	// without NodeUnpublishVolume being called.
+	CleanupOrphanedVolumes bool `yaml:"cleanup_orphaned_volumes"`
+	// Advertise the MODIFY_VOLUME controller capability and serve
+	// ControllerModifyVolume (VolumeAttributesClass).
+	ModifyVolume bool `yaml:"modify_volume"`
+	// Advertise the CREATE_DELETE_SNAPSHOT and LIST_SNAPSHOTS controller
+	// capabilities and snapshot the model dirs of the volumes into
+	// $root_dir/snapshots (VolumeSnapshot).
+	Snapshot bool `yaml:"snapshot"`
+	// Advertise the STAGE_UNSTAGE_VOLUME node capability, the model of the
+	// static volume is mounted once to the staging path of the node by
+	// NodeStageVolume and bind mounted from there to the target path of each
+	// pod by NodePublishVolume.
+	StageVolume bool `yaml:"stage_volume"`
+	// Publish the volumes read-only to the pods by default, unless the access
+	// mode of the volume (e.g. ReadWriteOnce) writes it. The volumes are
+	// published read-only anyway if the pod mounts them read-only or the
+	// access mode only reads them (e.g. ReadOnlyMany).
+	ReadOnlyMount bool `yaml:"read_only_mount"`
+	// Advertise the VOLUME_MOUNT_GROUP node capability, the fsGroup of the
+	// pod is applied by the driver to the model files of the volume as the
+	// group with the read permission, instead of by kubelet.
+	VolumeMountGroup bool `yaml:"volume_mount_group"`
+	// Store the pulled model files once in the node-level blob store keyed by
+	// layer digest, and hardlink them into each volume's model dir, so that the
+	// volumes of the same model don't pull and store it twice. The volumes are
+	// mounted read-only as the hardlinked files are shared.
+	SharedBlobStore bool `yaml:"shared_blob_store"`
+	// The interval of the periodic GC of the blobs not linked by any volume,
+	// 600 seconds by default.
+	BlobGCIntervalInSeconds uint `yaml:"blob_gc_interval_in_seconds"`
+	// Evict the models cached on the node without a volume once the disk
+	// usage approaches disk_usage_limit (or the disk size).
+	Eviction EvictionConfig `yaml:"eviction"`
+	// Limit the volume dir to the size of the model by the project quota of
+	// the file system, so that a model exceeding its declared size fails the
+	// pull instead of filling the disk.
+	ProjectQuota ProjectQuotaConfig `yaml:"project_quota"`
+	// Publish the CSIStorageCapacity objects of the nodes from the controller,
+	// so that the pods are scheduled to the nodes with the disk quota left
+	// for the model volumes.
+	StorageCapacity StorageCapacityConfig `yaml:"storage_capacity"`
+	// Remove the orphaned volume dirs left by the crashes on the start of the
+	// node, e.g. the dirs whose status is missing or whose PV is gone.
+	ReconcileVolumes ReconcileVolumesConfig `yaml:"reconcile_volumes"`
+	// Verify the cached model files against their checksum files in the
+	// background, so that the files corrupted on the disk are reported.
+	Scrub ScrubConfig `yaml:"scrub"`
+	// Serve the gRPC server reflection and the admin service of the in-flight
+	// CSI calls, the held locks and the recent errors on the external gRPC
+	// endpoint, so that the stuck calls are debugged with grpcurl.
+	GRPCDebug bool `yaml:"grpc_debug"`
+	// Require the bearer token minted per dynamic volume on the HTTP API of
+	// the dynamic csi.sock, the token is written beside the csi.sock.
+	DynamicServerAuth bool `yaml:"dynamic_server_auth"`
+	// Limit the requests on the HTTP API of each dynamic csi.sock, so that
+	// a misbehaving client in the pod can't overload the driver.
+	DynamicServerLimits DynamicServerLimitsConfig `yaml:"dynamic_server_limits"`
+	// The time to drain the dynamic csi.sock servers on SIGTERM, the requests
+	// in progress, e.g. the mounts pulled synchronously, are waited for up to
+	// the timeout, 20 seconds by default.
+	DynamicServerShutdownTimeoutInSeconds uint `yaml:"dynamic_server_shutdown_timeout_in_seconds"`
+}
+
+// DynamicServerLimitsConfig limits the requests per dynamic csi.sock, the
+// limits are unlimited by 0 and are applied on the config reload.
+type DynamicServerLimitsConfig struct {
+	// The requests per second allowed, except /healthz.
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	// The requests allowed at once over requests_per_second, the rounded up
+	// requests_per_second by default.
+	Burst uint `yaml:"burst"`
+	// The mounts created at once, including the mounts created in the
+	// background by the async requests.
+	MaxConcurrentCreates uint `yaml:"max_concurrent_creates"`
+}
+
+// Validate checks the requests per second isn't negative.
+func (cfg *DynamicServerLimitsConfig) Validate() error {
+	if cfg.RequestsPerSecond < 0 {
+		return errors.New("features.dynamic_server_limits.requests_per_second must not be negative")
+	}
+	return nil
+}
+
+// ScrubConfig re-hashes the model files of the pulled models against the
+// SHA256SUMS written by pull_config.write_checksum_file at the interval.
+type ScrubConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// The interval between the scrubs, 86400 (a day) by default.
+	IntervalInSeconds uint `yaml:"interval_in_seconds"`
+	// The number of the files sampled from each model by a scrub, all the
+	// files by default.
+	SampleFiles uint `yaml:"sample_files"`
+	// Limit the read throughput of the scrub, so that it doesn't compete with
+	// the pulls and the pods for the disk, unlimited by default.
+	BytesPerSecond HumanizeSize `yaml:"bytes_per_second"`
+	// Re-pull the prefetched models holding the corrupted files, the models
+	// of the volumes are only reported as they're used by the pods.
+	Repair bool `yaml:"repair"`
+}
+
+// Validate checks the checksum files are written for the scrub.
+func (cfg *ScrubConfig) Validate(writeChecksumFile bool) error {
+	if cfg.Enabled && !writeChecksumFile {
+		return errors.New("features.scrub requires pull_config.write_checksum_file")
+	}
+	return nil
+}
+
+// ReconcileVolumesConfig reconciles the volume dirs once on the start of the
+// node, the PVs are listed from Kubernetes to find the deleted volumes.
+type ReconcileVolumesConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Only log the orphaned volume dirs without removing them.
+	DryRun bool `yaml:"dry_run"`
+}
+
+// StorageCapacityConfig publishes the capacity of each node reported by
+// GetCapacity for each StorageClass of the driver, it's only used in
+// controller mode.
+type StorageCapacityConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// The namespace of the CSIStorageCapacity objects, usually the namespace
+	// of the controller.
+	Namespace string `yaml:"namespace"`
+	// The label selector of the nodes running the driver, all the nodes by
+	// default.
+	NodeSelector string `yaml:"node_selector"`
+	// The interval to publish the capacity, 60 by default.
+	IntervalInSeconds uint `yaml:"interval_in_seconds"`
+}
+
+// Validate checks the namespace is set once it's enabled.
+func (cfg *StorageCapacityConfig) Validate() error {
+	if cfg.Enabled && cfg.Namespace == "" {
+		return errors.New("features.storage_capacity.namespace is required")
+	}
+	return nil
+}
+
+// ProjectQuotaConfig applies the project quota (XFS or ext4 mounted with
+// prjquota, Linux 5.14+) to the volume dirs pulled with check_disk_quota.
+type ProjectQuotaConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// The headroom over the model size in percent, 10 by default.
+	HeadroomPercent uint `yaml:"headroom_percent"`
+}
+
+// Validate checks the project quota isn't enabled with the shared blob store,
+// the files can't be hardlinked across the projects.
+func (cfg *ProjectQuotaConfig) Validate(sharedBlobStore bool) error {
+	if cfg.Enabled && sharedBlobStore {
+		return errors.New("features.project_quota can't be enabled with features.shared_blob_store")
+	}
+	return nil
+}
+
+const (
+	// Evict the least recently pulled or cloned model first.
+	EvictionPolicyLRU = "lru"
+	// Evict the largest model first.
+	EvictionPolicyLargest = "largest"
+)
+
+// EvictionConfig evicts the prefetched models which aren't used by a volume,
+// the models of the volumes are only removed with the volumes.
+type EvictionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// The eviction policy, "lru" by default or "largest".
+	Policy string `yaml:"policy"`
+	// The models are evicted once the disk usage exceeds the high watermark,
+	// in the percent of disk_usage_limit (or the disk size), until it drops
+	// below the low watermark, 90 and 80 by default. A pull short of the
+	// disk quota evicts the models to make room for it regardless.
+	HighWatermarkPercent uint `yaml:"high_watermark_percent"`
+	LowWatermarkPercent  uint `yaml:"low_watermark_percent"`
+	// The interval to check the disk usage against the high watermark, 60 by
+	// default.
+	IntervalInSeconds uint `yaml:"interval_in_seconds"`
+}
+
+// Validate checks the policy and the watermarks.
+func (cfg *EvictionConfig) Validate() error {
+	switch cfg.Policy {
+	case "", EvictionPolicyLRU, EvictionPolicyLargest:
+	default:
+		return errors.Errorf("features.eviction.policy must be %s or %s, got %s", EvictionPolicyLRU, EvictionPolicyLargest, cfg.Policy)
+	}
+	if cfg.HighWatermarkPercent > 100 {
+		return errors.Errorf("features.eviction.high_watermark_percent must be in 0-100, got %d", cfg.HighWatermarkPercent)
+	}
+	if cfg.LowWatermarkPercent > cfg.HighWatermarkPercent && cfg.HighWatermarkPercent > 0 {
+		return errors.Errorf(
+			"features.eviction.low_watermark_percent %d must not exceed high_watermark_percent %d",
+			cfg.LowWatermarkPercent, cfg.HighWatermarkPercent,
+		)
+	}
+	return nil
+}
+
+// FaultInjection injects the faults at the rates (0-1) into the pull and
+// mount paths, to test the retry, cleanup and recovery logic.
+type FaultInjection struct {
+	Enabled                    bool    `yaml:"enabled"`
+	LayerFailureRate           float64 `yaml:"layer_failure_rate"`
+	SlowTransferRate           float64 `yaml:"slow_transfer_rate"`
+	SlowTransferDelayInSeconds uint    `yaml:"slow_transfer_delay_in_seconds"`
+	NoSpaceRate                float64 `yaml:"no_space_rate"`
+	MountErrorRate             float64 `yaml:"mount_error_rate"`
+	UmountErrorRate            float64 `yaml:"umount_error_rate"`
+}
+
+// Validate checks that the rates are in the range of 0-1.
+func (fi *FaultInjection) Validate() error {
+	rates := []struct {
+		name string
+		rate float64
+	}{
+		{"layer_failure_rate", fi.LayerFailureRate},
+		{"slow_transfer_rate", fi.SlowTransferRate},
+		{"no_space_rate", fi.NoSpaceRate},
+		{"mount_error_rate", fi.MountErrorRate},
+		{"umount_error_rate", fi.UmountErrorRate},
+	}
+	for _, item := range rates {
+		if item.rate < 0 || item.rate > 1 {
+			return errors.Errorf("fault_injection.%s must be in the range of 0-1, got %v", item.name, item.rate)
+		}
+	}
+	return nil
+}
+
+// PolicyConfig is the admission policy of the model references evaluated on
+// CreateVolume, the reference is denied if it matches any deny rule, or the
+// allow rules are set but none of them matches, or the webhook denies it.
+type PolicyConfig struct {
+	Allow   []PolicyRule  `yaml:"allow"`
+	Deny    []PolicyRule  `yaml:"deny"`
+	Webhook PolicyWebhook `yaml:"webhook"`
+}
+
+type PolicyRule struct {
+	// The model type, "image" by default.
+	Type string `yaml:"type"`
+	// The glob pattern of the reference without the tag, digest or revision,
+	// e.g. registry.example.com/models/*, and the trailing "/**" matches all
+	// the references under the prefix.
+	Pattern string `yaml:"pattern"`
+}
+
+// PolicyWebhook is the external policy, e.g. the OPA data API, which is
+// posted with {"input": {...}} and responds {"result": true} or
+// {"result": {"allow": true, "reason": "..."}}.
+type PolicyWebhook struct {
+	URL              string `yaml:"url"`
+	TimeoutInSeconds uint   `yaml:"timeout_in_seconds"`
+	// Allow the reference if the webhook is unavailable.
+	FailOpen bool `yaml:"fail_open"`
+}
+
+// Validate checks the patterns of the rules and the webhook URL.
+func (cfg *PolicyConfig) Validate() error {
+	for _, rules := range []struct {
+		name  string
+		rules []PolicyRule
+	}{{"allow", cfg.Allow}, {"deny", cfg.Deny}} {
+		for _, rule := range rules.rules {
+			if rule.Pattern == "" {
+				return errors.Errorf("policy.%s requires pattern", rules.name)
+			}
+			if _, err := path.Match(strings.TrimSuffix(rule.Pattern, "/**"), ""); err != nil {
+				return errors.Wrapf(err, "invalid pattern of policy.%s: %s", rules.name, rule.Pattern)
+			}
+		}
+	}
+	if cfg.Webhook.URL != "" {
+		endpoint, err := url.Parse(cfg.Webhook.URL)
+		if err != nil {
+			return errors.Wrap(err, "parse policy.webhook.url")
+		}
+		if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+			return errors.Errorf("policy.webhook.url must be a http or https URL, got %s", cfg.Webhook.URL)
+		}
+	}
+	return nil
+}
+
+// WarmModelsConfig is the list of the models kept pulled on the node, e.g. the
+// golden-path models which every node must serve instantly. The missing models
+// are pulled by the reconciliation, and pulled again once removed.
+type WarmModelsConfig struct {
+	Models []WarmModel `yaml:"models"`
+	// The interval of the reconciliation, 300 seconds by default.
+	ReconcileIntervalInSeconds uint `yaml:"reconcile_interval_in_seconds"`
+}
+
+type WarmModel struct {
+	// The model type, "image" by default.
+	Type      string `yaml:"type"`
+	Reference string `yaml:"reference"`
+	// The platform selected from the image index, e.g. "linux/arm64", the
+	// node platform by default.
+	Platform string `yaml:"platform"`
+}
+
+// Validate checks that the reference of each model is set.
+func (cfg *WarmModelsConfig) Validate() error {
+	for idx, model := range cfg.Models {
+		if strings.TrimSpace(model.Reference) == "" {
+			return errors.Errorf("warm_models.models[%d] requires reference", idx)
+		}
+	}
+	return nil
+}
+
+// StorageTier is a storage root besides root_dir, the volume dirs of the
+// models matching the placement rules are placed on the tier and linked from
+// root_dir, the disk usage of root_dir and the tiers is accounted together.
+type StorageTier struct {
+	Name    string `yaml:"name"`
+	RootDir string `yaml:"root_dir"`
+	// The disk quota of the tier, the disk size by default.
+	DiskUsageLimit HumanizeSize `yaml:"disk_usage_limit"`
+	// The models matching any of the references are placed on the tier, all
+	// the models by default.
+	References []PolicyRule `yaml:"references"`
+	// The size of the models placed on the tier, the models of unknown size
+	// (e.g. not in image type) aren't matched by the size.
+	MinModelSize HumanizeSize `yaml:"min_model_size"`
+	MaxModelSize HumanizeSize `yaml:"max_model_size"`
+}
+
+// /var/lib/model-csi-hdd/volumes
+func (tier *StorageTier) GetVolumesDir() string {
+	return filepath.Join(tier.RootDir, "volumes")
+}
+
+// validateStorageTiers checks the tiers are named uniquely, and their root
+// dirs don't overlap with each other or root_dir. The tiers can't be used
+// with the shared blob store, the blobs can't be hardlinked across them.
+func validateStorageTiers(rootDir string, tiers []StorageTier, sharedBlobStore bool) error {
+	if len(tiers) > 0 && sharedBlobStore {
+		return errors.New("storage_tiers can't be used with features.shared_blob_store")
+	}
+	names := map[string]bool{}
+	rootDirs := []string{rootDir}
+	for idx, tier := range tiers {
+		if tier.Name == "" {
+			return errors.Errorf("storage_tiers[%d] requires name", idx)
+		}
+		if names[tier.Name] {
+			return errors.Errorf("duplicated storage_tiers name: %s", tier.Name)
+		}
+		names[tier.Name] = true
+		if !filepath.IsAbs(tier.RootDir) {
+			return errors.Errorf("storage_tiers[%s].root_dir must be an absolute path", tier.Name)
+		}
+		for _, other := range rootDirs {
+			if isPathOverlapped(other, tier.RootDir) {
+				return errors.Errorf("storage_tiers[%s].root_dir overlaps with %s", tier.Name, other)
+			}
+		}
+		rootDirs = append(rootDirs, tier.RootDir)
+		for _, rule := range tier.References {
+			if rule.Pattern == "" {
+				return errors.Errorf("storage_tiers[%s].references requires pattern", tier.Name)
+			}
+			if _, err := path.Match(strings.TrimSuffix(rule.Pattern, "/**"), ""); err != nil {
+				return errors.Wrapf(err, "invalid pattern of storage_tiers[%s].references: %s", tier.Name, rule.Pattern)
+			}
+		}
+		if tier.MaxModelSize > 0 && tier.MinModelSize > tier.MaxModelSize {
+			return errors.Errorf("storage_tiers[%s].min_model_size exceeds max_model_size", tier.Name)
+		}
+	}
+	return nil
+}
+
+// isPathOverlapped returns true if either of the paths is under the other.
+func isPathOverlapped(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	return a == b || strings.HasPrefix(a, b+string(filepath.Separator)) || strings.HasPrefix(b, a+string(filepath.Separator))
+}
+
+// RegistryAuthConfig is the registry auth read from the Kubernetes Secrets of
+// type kubernetes.io/dockerconfigjson, which takes precedence over the docker
+// config, the Secrets are read again at the resync interval, so that the
+// rotated credentials take effect without restarting the driver.
+type RegistryAuthConfig struct {
+	// The .dockerconfigjson files mounted from the Secrets, e.g.
+	// /etc/model-csi-driver/registry-auth/.dockerconfigjson.
+	Files []string `yaml:"files"`
+	// The Secrets read by the Kubernetes API, in "namespace/name".
+	Secrets []string `yaml:"secrets"`
+	// The interval to read the Secrets again, 60 seconds by default.
+	ResyncIntervalInSeconds uint `yaml:"resync_interval_in_seconds"`
+}
+
+// Validate checks the Secrets are in "namespace/name".
+func (cfg *RegistryAuthConfig) Validate() error {
+	for _, secret := range cfg.Secrets {
+		namespace, name, ok := strings.Cut(secret, "/")
+		if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+			return errors.Errorf("pull_config.registry_auth.secrets must be in namespace/name, got %s", secret)
+		}
+	}
+	return nil
 }
 
 type PullConfig struct {
@@ -65,6 +495,313 @@ type PullConfig struct {
 	DragonflyEndpoint         string `yaml:"dragonfly_endpoint"`
 	Concurrency               uint   `yaml:"concurrency"`
 	PullLayerTimeoutInSeconds uint   `yaml:"pull_layer_timeout_in_seconds"`
+	// The timeout of the whole pull of a model, including the time queued,
+	// the pull is failed in PULL_TIMEOUT state once exceeded, 0 to disable.
+	PullTimeoutInSeconds uint `yaml:"pull_timeout_in_seconds"`
+	// The maximum number of the models pulled concurrently on the node, the
+	// other pulls are queued in PULL_QUEUED state, 0 for unlimited.
+	MaxConcurrentPulls uint `yaml:"max_concurrent_pulls"`
+	// The hosts bypassing the proxy_url in the NO_PROXY style, e.g.
+	// "registry.internal:5000", ".svc.cluster.local" for the domain and its
+	// subdomains, "10.0.0.0/8" for the IPs, or "*" for all the hosts. The
+	// proxy_url of the registries below takes precedence.
+	NoProxy []string `yaml:"no_proxy"`
+	// The registry auth from the Kubernetes Secrets, docker_config_dir
+	// is the fallback.
+	RegistryAuth RegistryAuthConfig `yaml:"registry_auth"`
+	// The TLS of the registries, overridden by the registries below.
+	TLS TLSConfig `yaml:"tls"`
+	// The retry of the layers failed by the transient errors, e.g. the
+	// connection reset or 5xx response.
+	Retry RetryConfig `yaml:"retry"`
+	// Skip verifying the pulled model files against the digest and size of
+	// the layers in the manifest, e.g. for the latency-sensitive pulls.
+	DisableFileVerification bool `yaml:"disable_file_verification"`
+	// Write the sha256 checksums of the model files into the SHA256SUMS file
+	// at the root of the model dir after the pull, so that the files can be
+	// verified inside the pod without access to the registry.
+	WriteChecksumFile bool `yaml:"write_checksum_file"`
+	// Write the config of the model spec (e.g. the name, format, parameter
+	// size and quantization) of the model images into the .model-metadata.json
+	// file at the root of the model dir after the pull.
+	WriteMetadataFile bool `yaml:"write_metadata_file"`
+	// The extraction of the compressed tar layers, independent of the download
+	// concurrency above.
+	Extraction ExtractionConfig `yaml:"extraction"`
+	// Verify the cosign signature of the model images before pulling them.
+	Signature SignatureConfig `yaml:"signature"`
+	// For the models of type "huggingface".
+	HuggingFace HuggingFaceConfig `yaml:"huggingface"`
+	// For the models of type "s3".
+	S3 S3Config `yaml:"s3"`
+	// For the models of type "archive".
+	Archive ArchiveConfig `yaml:"archive"`
+	// Import the layers of the model images already present in the
+	// containerd content store of the node instead of pulling them.
+	Containerd ContainerdConfig `yaml:"containerd"`
+	// Preheat the model images by the Dragonfly manager before or while
+	// pulling them through dragonfly_endpoint.
+	Preheat PreheatConfig `yaml:"preheat"`
+	// Override the pull config above for the models pulled from the
+	// registries, keyed by the registry host of the reference, e.g.
+	// "registry.internal:5000" or "docker.io".
+	Registries map[string]RegistryPullConfig `yaml:"registries"`
+}
+
+// RegistryPullConfig overrides the pull config for a registry, the unset
+// fields inherit the pull config, an empty proxy_url or dragonfly_endpoint
+// explicitly disables the proxy or Dragonfly for the registry.
+type RegistryPullConfig struct {
+	ProxyURL                  *string `yaml:"proxy_url"`
+	DragonflyEndpoint         *string `yaml:"dragonfly_endpoint"`
+	Concurrency               uint    `yaml:"concurrency"`
+	PullLayerTimeoutInSeconds *uint   `yaml:"pull_layer_timeout_in_seconds"`
+	// Replaces the whole TLS config of the pull config if set.
+	TLS *TLSConfig `yaml:"tls"`
+}
+
+// TLSConfig is the TLS of the registries accessed by https, the registries
+// accessed by plain http (the "serverscheme" in the docker config) ignore it.
+type TLSConfig struct {
+	// The PEM bundle of the CAs verifying the certificate of the registry, in
+	// addition to the system CAs.
+	CAFile string `yaml:"ca_file"`
+	// The PEM certificate and key of the client for the mutual TLS.
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// Skip verifying the certificate of the registry. It's true unless the
+	// ca_file is set for the compatibility, set it to false explicitly to
+	// verify the registry by the system CAs.
+	InsecureSkipVerify *bool `yaml:"insecure_skip_verify"`
+}
+
+// SkipVerify returns whether to skip verifying the certificate of the
+// registry.
+func (cfg *TLSConfig) SkipVerify() bool {
+	if cfg.InsecureSkipVerify != nil {
+		return *cfg.InsecureSkipVerify
+	}
+	return cfg.CAFile == ""
+}
+
+// Validate checks the certificate and key of the client are set together.
+func (cfg *TLSConfig) Validate(field string) error {
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return errors.Errorf("%s.cert_file and %s.key_file must be set together", field, field)
+	}
+	return nil
+}
+
+type RetryConfig struct {
+	// The max number of the retries of a layer, 0 to disable the retry.
+	MaxRetries uint `yaml:"max_retries"`
+	// The delay before the first retry, doubled on each retry, 1000 by default.
+	BackoffBaseInMilliseconds uint `yaml:"backoff_base_in_milliseconds"`
+	// The max delay between the retries, 30 by default.
+	MaxBackoffInSeconds uint `yaml:"max_backoff_in_seconds"`
+}
+
+type ExtractionConfig struct {
+	// The max number of the layers of a pull extracted concurrently, the
+	// layers are downloaded into the spool files first, so that the download
+	// isn't blocked by the decompression. 0 to extract the layers while
+	// downloading them.
+	Concurrency uint `yaml:"concurrency"`
+	// The number of the goroutines decompressing a zstd layer, 0 or 1 to
+	// decompress it synchronously.
+	ZstdConcurrency uint `yaml:"zstd_concurrency"`
+}
+
+type SignatureConfig struct {
+	// Require the model images to be signed by cosign with any of the public
+	// keys or keyless identities below.
+	Enabled bool `yaml:"enabled"`
+	// The PEM files of the public keys.
+	PublicKeyFiles []string `yaml:"public_key_files"`
+	// The identities of the keyless signing, which requires the Fulcio root
+	// certs and the Rekor public key.
+	Keyless             []KeylessIdentity `yaml:"keyless"`
+	FulcioRootCertsFile string            `yaml:"fulcio_root_certs_file"`
+	RekorPublicKeyFile  string            `yaml:"rekor_public_key_file"`
+}
+
+type KeylessIdentity struct {
+	// The OIDC issuer, e.g. https://token.actions.githubusercontent.com
+	Issuer string `yaml:"issuer"`
+	// The subject of the signing certificate, i.e. the email or URI.
+	Subject string `yaml:"subject"`
+}
+
+// Validate checks that the keys or the keyless identities are configured
+// for the enabled verification.
+func (cfg *SignatureConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if len(cfg.PublicKeyFiles) == 0 && len(cfg.Keyless) == 0 {
+		return errors.New("pull_config.signature requires public_key_files or keyless identities")
+	}
+	if len(cfg.Keyless) > 0 && (cfg.FulcioRootCertsFile == "" || cfg.RekorPublicKeyFile == "") {
+		return errors.New("pull_config.signature.keyless requires fulcio_root_certs_file and rekor_public_key_file")
+	}
+	for _, identity := range cfg.Keyless {
+		if identity.Issuer == "" || identity.Subject == "" {
+			return errors.New("pull_config.signature.keyless requires issuer and subject")
+		}
+	}
+	return nil
+}
+
+type HuggingFaceConfig struct {
+	// The HuggingFace Hub endpoint, https://huggingface.co by default.
+	Endpoint string `yaml:"endpoint"`
+	// Optional file containing the access token for the private or gated
+	// models, the HF_TOKEN env is used if not set.
+	TokenFile string `yaml:"token_file"`
+}
+
+type S3Config struct {
+	// The S3 compatible endpoint, e.g. https://oss-cn-hangzhou.aliyuncs.com
+	// or https://storage.googleapis.com, https://s3.amazonaws.com by default.
+	Endpoint string `yaml:"endpoint"`
+	// The region to sign the requests, us-east-1 by default.
+	Region string `yaml:"region"`
+	// Use the path-style URL (endpoint/bucket/key) instead of the
+	// virtual-hosted-style URL (bucket.endpoint/key), e.g. for MinIO.
+	ForcePathStyle bool `yaml:"force_path_style"`
+	// Optional static credentials, the AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY envs are used if not set, the credentials
+	// in the CSI secrets of the volume take precedence over both.
+	AccessKeyID         string `yaml:"access_key_id"`
+	SecretAccessKeyFile string `yaml:"secret_access_key_file"`
+}
+
+type ArchiveConfig struct {
+	// The node dir holding the model tarballs or dirs imported by the models
+	// of type "archive", the references must be located under it, the type
+	// "archive" is disabled if not set.
+	RootDir string `yaml:"root_dir"`
+}
+
+type PreheatConfig struct {
+	// The URL of the Dragonfly manager, e.g. http://dragonfly-manager:8080,
+	// the preheat is disabled if not set.
+	ManagerURL string `yaml:"manager_url"`
+	// The file containing the personal access token of the manager open API.
+	TokenFile string `yaml:"token_file"`
+	// Wait for the preheat to finish before pulling, otherwise the model is
+	// pulled while preheating.
+	Wait bool `yaml:"wait"`
+	// The max time of the preheat, 600 by default.
+	TimeoutInSeconds uint `yaml:"timeout_in_seconds"`
+}
+
+func (cfg *PreheatConfig) GetToken() (string, error) {
+	if cfg.TokenFile == "" {
+		return "", nil
+	}
+	token, err := os.ReadFile(cfg.TokenFile)
+	if err != nil {
+		return "", errors.Wrapf(err, "read preheat token file: %s", cfg.TokenFile)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+type ContainerdConfig struct {
+	// The content store dir of containerd on the node, e.g.
+	// /var/lib/containerd/io.containerd.content.v1.content, the import is
+	// disabled if not set.
+	ContentDir string `yaml:"content_dir"`
+}
+
+// IsNoProxy checks if the host (optionally with the port) bypasses the proxy
+// by no_proxy.
+func (cfg *PullConfig) IsNoProxy(host string) bool {
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+	hostname = strings.ToLower(strings.Trim(hostname, "[]"))
+	ip := net.ParseIP(hostname)
+
+	for _, pattern := range cfg.NoProxy {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		switch {
+		case pattern == "":
+			continue
+		case pattern == "*":
+			return true
+		case strings.Contains(pattern, "/"):
+			if _, cidr, err := net.ParseCIDR(pattern); err == nil && ip != nil && cidr.Contains(ip) {
+				return true
+			}
+		case strings.ToLower(host) == pattern || hostname == pattern:
+			return true
+		default:
+			// ".example.com", "*.example.com" and "example.com" match the
+			// domain and its subdomains.
+			domain := strings.TrimPrefix(strings.TrimPrefix(pattern, "*"), ".")
+			if hostname == domain || strings.HasSuffix(hostname, "."+domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ForRegistry returns the pull config with the overrides of the registry
+// host applied, or the pull config itself if there are no overrides.
+func (cfg *PullConfig) ForRegistry(host string) *PullConfig {
+	override, ok := cfg.Registries[host]
+	noProxy := cfg.ProxyURL != "" && cfg.IsNoProxy(host)
+	if !ok && !noProxy {
+		return cfg
+	}
+
+	merged := *cfg
+	if override.ProxyURL != nil {
+		merged.ProxyURL = *override.ProxyURL
+	} else if noProxy {
+		merged.ProxyURL = ""
+	}
+	if override.DragonflyEndpoint != nil {
+		merged.DragonflyEndpoint = *override.DragonflyEndpoint
+	}
+	if override.Concurrency > 0 {
+		merged.Concurrency = override.Concurrency
+	}
+	if override.PullLayerTimeoutInSeconds != nil {
+		merged.PullLayerTimeoutInSeconds = *override.PullLayerTimeoutInSeconds
+	}
+	if override.TLS != nil {
+		merged.TLS = *override.TLS
+	}
+
+	return &merged
+}
+
+// GetCredentials returns the static S3 credentials, or empty for the anonymous access.
+func (cfg *S3Config) GetCredentials() (string, string, string, error) {
+	if cfg.AccessKeyID == "" {
+		return os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN"), nil
+	}
+	secretAccessKey, err := os.ReadFile(cfg.SecretAccessKeyFile)
+	if err != nil {
+		return "", "", "", errors.Wrapf(err, "read s3 secret access key file: %s", cfg.SecretAccessKeyFile)
+	}
+	return cfg.AccessKeyID, strings.TrimSpace(string(secretAccessKey)), "", nil
+}
+
+// GetToken returns the HuggingFace access token, or empty for the anonymous access.
+func (cfg *HuggingFaceConfig) GetToken() (string, error) {
+	if cfg.TokenFile == "" {
+		return os.Getenv("HF_TOKEN"), nil
+	}
+	token, err := os.ReadFile(cfg.TokenFile)
+	if err != nil {
+		return "", errors.Wrapf(err, "read huggingface token file: %s", cfg.TokenFile)
+	}
+	return strings.TrimSpace(string(token)), nil
 }
 
 func (cfg *RawConfig) ParameterKeyType() string {
@@ -75,6 +812,19 @@ func (cfg *RawConfig) ParameterKeyReference() string {
 	return cfg.ServiceName + "/reference"
 }
 
+// ParameterKeyDigest is the digest the tag of the image reference is
+// resolved to on CreateVolume, it's returned in the VolumeContext.
+func (cfg *RawConfig) ParameterKeyDigest() string {
+	return cfg.ServiceName + "/digest"
+}
+
+// ParameterKeyPlatform selects the manifest of the platform (e.g.
+// "linux/arm64") from the image index, the node platform by default, the
+// selected platform is returned in the VolumeContext.
+func (cfg *RawConfig) ParameterKeyPlatform() string {
+	return cfg.ServiceName + "/platform"
+}
+
 func (cfg *RawConfig) ParameterKeyMountID() string {
 	return cfg.ServiceName + "/mount-id"
 }
@@ -87,10 +837,20 @@ func (cfg *RawConfig) ParameterKeyStatusProgress() string {
 	return cfg.ServiceName + "/status/progress"
 }
 
+// ParameterKeyReadOnly is a mutable parameter which can be changed on an
+// existing volume by ControllerModifyVolume (VolumeAttributesClass).
+func (cfg *RawConfig) ParameterKeyReadOnly() string {
+	return cfg.ServiceName + "/read-only"
+}
+
 func (cfg *RawConfig) ParameterVolumeContextNodeIP() string {
 	return cfg.ServiceName + "/node-ip"
 }
 
+func (cfg *RawConfig) ParameterVolumeContextNodeHostname() string {
+	return cfg.ServiceName + "/node-hostname"
+}
+
 func (cfg *RawConfig) ParameterKeyCheckDiskQuota() string {
 	return cfg.ServiceName + "/check-disk-quota"
 }
@@ -99,10 +859,71 @@ func (cfg *RawConfig) ParameterKeyExcludeModelWeights() string {
 	return cfg.ServiceName + "/exclude-model-weights"
 }
 
+// ParameterKeyExcludeWeights is the short alias of the
+// ParameterKeyExcludeModelWeights.
+func (cfg *RawConfig) ParameterKeyExcludeWeights() string {
+	return cfg.ServiceName + "/exclude-weights"
+}
+
 func (cfg *RawConfig) ParameterKeyExcludeFilePatterns() string {
 	return cfg.ServiceName + "/exclude-file-patterns"
 }
 
+// ParameterKeyIncludeFilePatterns is the JSON array of the patterns of the
+// only files to pull, e.g. ["tokenizer*", "config.json"].
+func (cfg *RawConfig) ParameterKeyIncludeFilePatterns() string {
+	return cfg.ServiceName + "/include-file-patterns"
+}
+
+// ParameterKeyBackgroundWeights pulls the model weights in the background,
+// the volume is ready once the other files (e.g. config and tokenizer) are
+// pulled.
+func (cfg *RawConfig) ParameterKeyBackgroundWeights() string {
+	return cfg.ServiceName + "/background-weights"
+}
+
+// ParameterKeyAdapters is the JSON array of the adapters (e.g. LoRA) pulled
+// beside the base model of the composite mount, e.g.
+// [{"name": "sql", "reference": "registry.example.com/loras/sql:v1"}].
+func (cfg *RawConfig) ParameterKeyAdapters() string {
+	return cfg.ServiceName + "/adapters"
+}
+
+// ParameterKeyLabels is the JSON object of the labels of the dynamic
+// mount, e.g. {"tenant": "team-a"}.
+func (cfg *RawConfig) ParameterKeyLabels() string {
+	return cfg.ServiceName + "/labels"
+}
+
+// ParameterKeyPriority is the priority of the pull in the node-wide pull
+// queue, the queued pulls of the higher priority are admitted first.
+func (cfg *RawConfig) ParameterKeyPriority() string {
+	return cfg.ServiceName + "/priority"
+}
+
+// ParameterKeyPullTimeoutInSeconds overrides pull_timeout_in_seconds of the
+// pull config for the volume, 0 for the default.
+func (cfg *RawConfig) ParameterKeyPullTimeoutInSeconds() string {
+	return cfg.ServiceName + "/pull-timeout-in-seconds"
+}
+
+// ParameterKeyPinned pins the model of the volume on the node, so that the
+// cached copies of the model are never evicted.
+func (cfg *RawConfig) ParameterKeyPinned() string {
+	return cfg.ServiceName + "/pinned"
+}
+
+// ParameterKeyRetainCache keeps the model of the volume on the node as the
+// cache once the volume is deleted, so that the volume of the same model
+// created later reuses it instead of pulling it.
+func (cfg *RawConfig) ParameterKeyRetainCache() string {
+	return cfg.ServiceName + "/retain-cache"
+}
+
+func (cfg *RawConfig) AnnotationKeyCachedModels() string {
+	return cfg.ServiceName + "/cached-models"
+}
+
 // /var/lib/dragonfly/model-csi/volumes
 func (cfg *RawConfig) GetVolumesDir() string {
 	return filepath.Join(cfg.RootDir, "volumes")
@@ -118,6 +939,16 @@ func (cfg *RawConfig) GetModelDir(volumeName string) string {
 	return filepath.Join(cfg.GetVolumesDir(), volumeName, "model")
 }
 
+// /var/lib/dragonfly/model-csi/snapshots
+func (cfg *RawConfig) GetSnapshotsDir() string {
+	return filepath.Join(cfg.RootDir, "snapshots")
+}
+
+// /var/lib/dragonfly/model-csi/snapshots/$snapshotName
+func (cfg *RawConfig) GetSnapshotDir(snapshotName string) string {
+	return filepath.Join(cfg.GetSnapshotsDir(), snapshotName)
+}
+
 // /var/lib/dragonfly/model-csi/volumes/$volumeName
 func (cfg *RawConfig) GetVolumeDirForDynamic(volumeName string) string {
 	return filepath.Join(cfg.GetVolumesDir(), volumeName)
@@ -148,6 +979,36 @@ func (cfg *RawConfig) GetCSISockPathForDynamic(volumeName string) string {
 	return filepath.Join(cfg.GetCSISockDirForDynamic(volumeName), "csi.sock")
 }
 
+// /var/lib/dragonfly/model-csi/volumes/$volumeName/csi/token
+func (cfg *RawConfig) GetCSITokenPathForDynamic(volumeName string) string {
+	return filepath.Join(cfg.GetCSISockDirForDynamic(volumeName), "token")
+}
+
+// /var/lib/dragonfly/model-csi/blobs
+func (cfg *RawConfig) GetBlobsDir() string {
+	return filepath.Join(cfg.RootDir, "blobs")
+}
+
+// /var/lib/dragonfly/model-csi/pins.json
+func (cfg *RawConfig) GetPinsPath() string {
+	return filepath.Join(cfg.RootDir, "pins.json")
+}
+
+// /var/lib/dragonfly/model-csi/blobs/sha256/$hex
+func (cfg *RawConfig) GetBlobPath(dgst digest.Digest) string {
+	return filepath.Join(cfg.GetBlobsDir(), dgst.Algorithm().String(), dgst.Encoded())
+}
+
+// /var/lib/kubelet/pods
+func (cfg *RawConfig) GetKubeletPodsDir() string {
+	return filepath.Join(cfg.KubeletRootDir, "pods")
+}
+
+// /var/lib/kubelet/plugins
+func (cfg *RawConfig) GetKubeletPluginsDir() string {
+	return filepath.Join(cfg.KubeletRootDir, "plugins")
+}
+
 func (cfg *RawConfig) IsControllerMode() bool {
 	return cfg.Mode == "controller"
 }
@@ -171,6 +1032,10 @@ func parse(path string) (*RawConfig, error) {
 		return nil, errors.New("service_name is required")
 	}
 
+	if err := cfg.FaultInjection.Validate(); err != nil {
+		return nil, err
+	}
+
 	csiMode := os.Getenv("X_CSI_MODE")
 	if csiMode == "" {
 		return nil, errors.New("X_CSI_MODE env is required")
@@ -208,29 +1073,147 @@ func parse(path string) (*RawConfig, error) {
 			return nil, errors.New("root_dir is required")
 		}
 
-		if cfg.PullConfig.DragonflyEndpoint != "" {
-			endpoint, err := url.Parse(cfg.PullConfig.DragonflyEndpoint)
-			if err != nil {
-				return nil, errors.Wrap(err, "parse dragonfly endpoint")
+		if err := cfg.PullConfig.Signature.Validate(); err != nil {
+			return nil, err
+		}
+
+		if err := cfg.Policy.Validate(); err != nil {
+			return nil, err
+		}
+
+		if err := cfg.Features.DynamicServerLimits.Validate(); err != nil {
+			return nil, err
+		}
+
+		if err := cfg.PullConfig.RegistryAuth.Validate(); err != nil {
+			return nil, err
+		}
+		if cfg.PullConfig.RegistryAuth.ResyncIntervalInSeconds == 0 {
+			cfg.PullConfig.RegistryAuth.ResyncIntervalInSeconds = 60
+		}
+
+		if err := cfg.Features.Eviction.Validate(); err != nil {
+			return nil, err
+		}
+		if cfg.Features.Eviction.Policy == "" {
+			cfg.Features.Eviction.Policy = EvictionPolicyLRU
+		}
+		if cfg.Features.Eviction.HighWatermarkPercent == 0 {
+			cfg.Features.Eviction.HighWatermarkPercent = 90
+		}
+		if cfg.Features.Eviction.LowWatermarkPercent == 0 {
+			cfg.Features.Eviction.LowWatermarkPercent = min(80, cfg.Features.Eviction.HighWatermarkPercent)
+		}
+		if cfg.Features.Eviction.IntervalInSeconds == 0 {
+			cfg.Features.Eviction.IntervalInSeconds = 60
+		}
+		if cfg.Features.BlobGCIntervalInSeconds == 0 {
+			cfg.Features.BlobGCIntervalInSeconds = 600
+		}
+		if cfg.Features.DynamicServerShutdownTimeoutInSeconds == 0 {
+			cfg.Features.DynamicServerShutdownTimeoutInSeconds = 20
+		}
+		if err := cfg.Features.ProjectQuota.Validate(cfg.Features.SharedBlobStore); err != nil {
+			return nil, err
+		}
+		if err := validateStorageTiers(cfg.RootDir, cfg.StorageTiers, cfg.Features.SharedBlobStore); err != nil {
+			return nil, err
+		}
+		if err := cfg.Features.Scrub.Validate(cfg.PullConfig.WriteChecksumFile); err != nil {
+			return nil, err
+		}
+		if cfg.Features.ProjectQuota.HeadroomPercent == 0 {
+			cfg.Features.ProjectQuota.HeadroomPercent = 10
+		}
+
+		if err := cfg.WarmModels.Validate(); err != nil {
+			return nil, err
+		}
+		if cfg.WarmModels.ReconcileIntervalInSeconds == 0 {
+			cfg.WarmModels.ReconcileIntervalInSeconds = 300
+		}
+
+		if err := validateDragonflyEndpoint("pull_config.dragonfly_endpoint", cfg.PullConfig.DragonflyEndpoint); err != nil {
+			return nil, err
+		}
+		if err := cfg.PullConfig.TLS.Validate("pull_config.tls"); err != nil {
+			return nil, err
+		}
+		for _, pattern := range cfg.PullConfig.NoProxy {
+			if !strings.Contains(pattern, "/") {
+				continue
 			}
-			if endpoint.Path == "" {
-				return nil, errors.New("pull_config.dragonfly_endpoint must be a valid URL with path")
+			if _, _, err := net.ParseCIDR(strings.TrimSpace(pattern)); err != nil {
+				return nil, errors.Wrapf(err, "invalid pull_config.no_proxy: %s", pattern)
 			}
-			if _, err := os.Stat(endpoint.Path); err != nil {
-				return nil, errors.Wrapf(err, "check dragonfly endpoint: %s", endpoint.Path)
+		}
+		for host, override := range cfg.PullConfig.Registries {
+			if host == "" {
+				return nil, errors.New("pull_config.registries must be keyed by the registry host")
+			}
+			if override.DragonflyEndpoint != nil {
+				field := fmt.Sprintf("pull_config.registries[%s].dragonfly_endpoint", host)
+				if err := validateDragonflyEndpoint(field, *override.DragonflyEndpoint); err != nil {
+					return nil, err
+				}
+			}
+			if override.TLS != nil {
+				if err := override.TLS.Validate(fmt.Sprintf("pull_config.registries[%s].tls", host)); err != nil {
+					return nil, err
+				}
 			}
 		}
 
 		if cfg.PullConfig.Concurrency == 0 {
 			cfg.PullConfig.Concurrency = 5
 		}
+
+		if cfg.PullConfig.HuggingFace.Endpoint == "" {
+			cfg.PullConfig.HuggingFace.Endpoint = "https://huggingface.co"
+		}
+
+		if cfg.PullConfig.S3.Endpoint == "" {
+			cfg.PullConfig.S3.Endpoint = "https://s3.amazonaws.com"
+		}
+		if cfg.PullConfig.S3.Region == "" {
+			cfg.PullConfig.S3.Region = "us-east-1"
+		}
+	}
+
+	if cfg.IsControllerMode() {
+		if err := cfg.Features.StorageCapacity.Validate(); err != nil {
+			return nil, err
+		}
+		if cfg.Features.StorageCapacity.IntervalInSeconds == 0 {
+			cfg.Features.StorageCapacity.IntervalInSeconds = 60
+		}
 	}
 
 	return &cfg, nil
 }
 
+func validateDragonflyEndpoint(field, dragonflyEndpoint string) error {
+	if dragonflyEndpoint == "" {
+		return nil
+	}
+	endpoint, err := url.Parse(dragonflyEndpoint)
+	if err != nil {
+		return errors.Wrapf(err, "parse %s", field)
+	}
+	if endpoint.Path == "" {
+		return errors.Errorf("%s must be a valid URL with path", field)
+	}
+	if _, err := os.Stat(endpoint.Path); err != nil {
+		return errors.Wrapf(err, "check %s: %s", field, endpoint.Path)
+	}
+	return nil
+}
+
 type Config struct {
 	atomic.Value
+	// The error of the last reload of the config file, the config parsed
+	// before is kept meanwhile.
+	reloadErr atomic.Pointer[error]
 }
 
 func New(path string) (*Config, error) {
@@ -258,10 +1241,20 @@ func (cfg *Config) Get() *RawConfig {
 	return cfg.Load().(*RawConfig)
 }
 
+// ReloadError returns the error of the last reload of the config file, or
+// nil if the config file is parsed.
+func (cfg *Config) ReloadError() error {
+	if err := cfg.reloadErr.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
 func (cfg *Config) reload(path string) {
 	newCfg, err := parse(path)
 	if err != nil {
 		logger.Logger().WithError(err).Error("failed to parse config file")
+		cfg.reloadErr.Store(&err)
 		return
 	}
 
@@ -269,6 +1262,7 @@ func (cfg *Config) reload(path string) {
 	defer mutex.Unlock()
 
 	cfg.Store(newCfg)
+	cfg.reloadErr.Store(nil)
 
 	logger.Logger().Infof("config reloaded: %s", path)
 }
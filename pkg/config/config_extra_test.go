@@ -15,9 +15,19 @@ func TestRawConfig_ParameterKeys(t *testing.T) {
 	require.Equal(t, "test.csi.example.com/status/state", cfg.ParameterKeyStatusState())
 	require.Equal(t, "test.csi.example.com/status/progress", cfg.ParameterKeyStatusProgress())
 	require.Equal(t, "test.csi.example.com/node-ip", cfg.ParameterVolumeContextNodeIP())
+	require.Equal(t, "test.csi.example.com/node-hostname", cfg.ParameterVolumeContextNodeHostname())
 	require.Equal(t, "test.csi.example.com/check-disk-quota", cfg.ParameterKeyCheckDiskQuota())
 	require.Equal(t, "test.csi.example.com/exclude-model-weights", cfg.ParameterKeyExcludeModelWeights())
+	require.Equal(t, "test.csi.example.com/exclude-weights", cfg.ParameterKeyExcludeWeights())
+	require.Equal(t, "test.csi.example.com/include-file-patterns", cfg.ParameterKeyIncludeFilePatterns())
 	require.Equal(t, "test.csi.example.com/exclude-file-patterns", cfg.ParameterKeyExcludeFilePatterns())
+	require.Equal(t, "test.csi.example.com/priority", cfg.ParameterKeyPriority())
+	require.Equal(t, "test.csi.example.com/background-weights", cfg.ParameterKeyBackgroundWeights())
+	require.Equal(t, "test.csi.example.com/adapters", cfg.ParameterKeyAdapters())
+	require.Equal(t, "test.csi.example.com/labels", cfg.ParameterKeyLabels())
+	require.Equal(t, "test.csi.example.com/platform", cfg.ParameterKeyPlatform())
+	require.Equal(t, "test.csi.example.com/pull-timeout-in-seconds", cfg.ParameterKeyPullTimeoutInSeconds())
+	require.Equal(t, "test.csi.example.com/pinned", cfg.ParameterKeyPinned())
 }
 
 func TestRawConfig_PathHelpers(t *testing.T) {
@@ -29,12 +39,16 @@ func TestRawConfig_PathHelpers(t *testing.T) {
 	require.Equal(t, "/var/lib/model-csi/volumes", cfg.GetVolumesDir())
 	require.Equal(t, "/var/lib/model-csi/volumes/pvc-vol", cfg.GetVolumeDir("pvc-vol"))
 	require.Equal(t, "/var/lib/model-csi/volumes/pvc-vol/model", cfg.GetModelDir("pvc-vol"))
+	require.Equal(t, "/var/lib/model-csi/snapshots", cfg.GetSnapshotsDir())
+	require.Equal(t, "/var/lib/model-csi/snapshots/snapshot-1", cfg.GetSnapshotDir("snapshot-1"))
 	require.Equal(t, "/var/lib/model-csi/volumes/csi-vol", cfg.GetVolumeDirForDynamic("csi-vol"))
 	require.Equal(t, "/var/lib/model-csi/volumes/csi-vol/models", cfg.GetModelsDirForDynamic("csi-vol"))
 	require.Equal(t, "/var/lib/model-csi/volumes/csi-vol/models/mnt-1", cfg.GetMountIDDirForDynamic("csi-vol", "mnt-1"))
 	require.Equal(t, "/var/lib/model-csi/volumes/csi-vol/models/mnt-1/model", cfg.GetModelDirForDynamic("csi-vol", "mnt-1"))
 	require.Equal(t, "/var/lib/model-csi/volumes/csi-vol/csi", cfg.GetCSISockDirForDynamic("csi-vol"))
 	require.Equal(t, "/var/lib/model-csi/volumes/csi-vol/csi/csi.sock", cfg.GetCSISockPathForDynamic("csi-vol"))
+	require.Equal(t, "/var/lib/model-csi/volumes/csi-vol/csi/token", cfg.GetCSITokenPathForDynamic("csi-vol"))
+	require.Equal(t, "/var/lib/model-csi/pins.json", cfg.GetPinsPath())
 }
 
 func TestRawConfig_ModeHelpers(t *testing.T) {
@@ -77,3 +91,153 @@ func TestNewWithRaw(t *testing.T) {
 	require.NotNil(t, cfg)
 	require.Equal(t, "test-svc", cfg.Get().ServiceName)
 }
+
+func TestFaultInjection_Validate(t *testing.T) {
+	require.NoError(t, (&FaultInjection{}).Validate())
+	require.NoError(t, (&FaultInjection{LayerFailureRate: 1, NoSpaceRate: 0.5}).Validate())
+	require.Error(t, (&FaultInjection{SlowTransferRate: 1.5}).Validate())
+	require.Error(t, (&FaultInjection{MountErrorRate: -0.1}).Validate())
+}
+
+func TestPolicyConfig_Validate(t *testing.T) {
+	require.NoError(t, (&PolicyConfig{}).Validate())
+	require.NoError(t, (&PolicyConfig{
+		Allow:   []PolicyRule{{Pattern: "registry.example.com/models/**"}},
+		Deny:    []PolicyRule{{Type: "huggingface", Pattern: "org/*"}},
+		Webhook: PolicyWebhook{URL: "http://opa:8181/v1/data/model/allow"},
+	}).Validate())
+	require.Error(t, (&PolicyConfig{Allow: []PolicyRule{{}}}).Validate())
+	require.Error(t, (&PolicyConfig{Deny: []PolicyRule{{Pattern: "registry/[models"}}}).Validate())
+	require.Error(t, (&PolicyConfig{Webhook: PolicyWebhook{URL: "unix:///run/opa.sock"}}).Validate())
+}
+
+func TestRegistryAuthConfig_Validate(t *testing.T) {
+	require.NoError(t, (&RegistryAuthConfig{}).Validate())
+	require.NoError(t, (&RegistryAuthConfig{
+		Files:   []string{"/etc/model-csi-driver/registry-auth/.dockerconfigjson"},
+		Secrets: []string{"model-csi/registry-auth"},
+	}).Validate())
+	require.Error(t, (&RegistryAuthConfig{Secrets: []string{"registry-auth"}}).Validate())
+	require.Error(t, (&RegistryAuthConfig{Secrets: []string{"model-csi/"}}).Validate())
+	require.Error(t, (&RegistryAuthConfig{Secrets: []string{"model-csi/registry/auth"}}).Validate())
+}
+
+func TestWarmModelsConfig_Validate(t *testing.T) {
+	require.NoError(t, (&WarmModelsConfig{}).Validate())
+	require.NoError(t, (&WarmModelsConfig{Models: []WarmModel{
+		{Reference: "registry.example.com/models/qwen3-0.6b:latest"},
+		{Type: "huggingface", Reference: "Qwen/Qwen3-0.6B"},
+	}}).Validate())
+	require.Error(t, (&WarmModelsConfig{Models: []WarmModel{{Type: "image"}}}).Validate())
+}
+
+func TestEvictionConfig_Validate(t *testing.T) {
+	require.NoError(t, (&EvictionConfig{}).Validate())
+	require.NoError(t, (&EvictionConfig{Policy: EvictionPolicyLargest, HighWatermarkPercent: 90, LowWatermarkPercent: 80}).Validate())
+	require.Error(t, (&EvictionConfig{Policy: "fifo"}).Validate())
+	require.Error(t, (&EvictionConfig{HighWatermarkPercent: 101}).Validate())
+	require.Error(t, (&EvictionConfig{HighWatermarkPercent: 80, LowWatermarkPercent: 90}).Validate())
+}
+
+func TestStorageCapacityConfig_Validate(t *testing.T) {
+	require.NoError(t, (&StorageCapacityConfig{}).Validate())
+	require.NoError(t, (&StorageCapacityConfig{Enabled: true, Namespace: "model-csi"}).Validate())
+	require.Error(t, (&StorageCapacityConfig{Enabled: true}).Validate())
+}
+
+func TestDynamicServerLimitsConfig_Validate(t *testing.T) {
+	require.NoError(t, (&DynamicServerLimitsConfig{}).Validate())
+	require.NoError(t, (&DynamicServerLimitsConfig{RequestsPerSecond: 0.5, MaxConcurrentCreates: 2}).Validate())
+	require.Error(t, (&DynamicServerLimitsConfig{RequestsPerSecond: -1}).Validate())
+}
+
+func TestValidateStorageTiers(t *testing.T) {
+	hdd := StorageTier{Name: "hdd", RootDir: "/mnt/hdd/model-csi", MinModelSize: 10 << 30}
+	require.NoError(t, validateStorageTiers("/var/lib/model-csi", nil, true))
+	require.NoError(t, validateStorageTiers("/var/lib/model-csi", []StorageTier{hdd}, false))
+	require.Error(t, validateStorageTiers("/var/lib/model-csi", []StorageTier{hdd}, true))
+	require.Error(t, validateStorageTiers("/var/lib/model-csi", []StorageTier{hdd, hdd}, false))
+	require.Error(t, validateStorageTiers("/var/lib/model-csi", []StorageTier{{Name: "hdd", RootDir: "hdd"}}, false))
+	require.Error(t, validateStorageTiers("/var/lib/model-csi", []StorageTier{{Name: "hdd", RootDir: "/var/lib/model-csi/hdd"}}, false))
+	require.Error(t, validateStorageTiers("/mnt/hdd/model-csi/root", []StorageTier{hdd}, false))
+	require.Error(t, validateStorageTiers("/var/lib/model-csi", []StorageTier{{Name: "hdd", RootDir: "/mnt/hdd", References: []PolicyRule{{Pattern: "["}}}}, false))
+	require.Error(t, validateStorageTiers("/var/lib/model-csi", []StorageTier{{Name: "hdd", RootDir: "/mnt/hdd", MinModelSize: 2, MaxModelSize: 1}}, false))
+}
+
+func TestScrubConfig_Validate(t *testing.T) {
+	require.NoError(t, (&ScrubConfig{}).Validate(false))
+	require.NoError(t, (&ScrubConfig{Enabled: true}).Validate(true))
+	require.Error(t, (&ScrubConfig{Enabled: true}).Validate(false))
+}
+
+func TestProjectQuotaConfig_Validate(t *testing.T) {
+	require.NoError(t, (&ProjectQuotaConfig{}).Validate(true))
+	require.NoError(t, (&ProjectQuotaConfig{Enabled: true}).Validate(false))
+	require.Error(t, (&ProjectQuotaConfig{Enabled: true}).Validate(true))
+}
+
+func TestTLSConfig(t *testing.T) {
+	verify := false
+	require.True(t, (&TLSConfig{}).SkipVerify())
+	require.False(t, (&TLSConfig{CAFile: "/etc/ca.pem"}).SkipVerify())
+	require.False(t, (&TLSConfig{InsecureSkipVerify: &verify}).SkipVerify())
+
+	require.NoError(t, (&TLSConfig{}).Validate("pull_config.tls"))
+	require.NoError(t, (&TLSConfig{CertFile: "/etc/client.pem", KeyFile: "/etc/client-key.pem"}).Validate("pull_config.tls"))
+	require.Error(t, (&TLSConfig{CertFile: "/etc/client.pem"}).Validate("pull_config.tls"))
+}
+
+func TestPullConfig_IsNoProxy(t *testing.T) {
+	cfg := &PullConfig{NoProxy: []string{"registry.internal:5000", ".svc.cluster.local", "*.corp.example.com", "example.org", "10.0.0.0/8"}}
+
+	require.True(t, cfg.IsNoProxy("registry.internal:5000"))
+	require.False(t, cfg.IsNoProxy("registry.internal:5001"))
+	require.True(t, cfg.IsNoProxy("harbor.registry.svc.cluster.local"))
+	require.True(t, cfg.IsNoProxy("hub.corp.example.com:443"))
+	require.True(t, cfg.IsNoProxy("example.org"))
+	require.True(t, cfg.IsNoProxy("registry.example.org"))
+	require.False(t, cfg.IsNoProxy("notexample.org"))
+	require.True(t, cfg.IsNoProxy("10.1.2.3:5000"))
+	require.False(t, cfg.IsNoProxy("docker.io"))
+
+	require.False(t, (&PullConfig{}).IsNoProxy("docker.io"))
+	require.True(t, (&PullConfig{NoProxy: []string{"*"}}).IsNoProxy("docker.io"))
+}
+
+func TestPullConfig_ForRegistry(t *testing.T) {
+	proxyURL := ""
+	timeout := uint(0)
+	cfg := &PullConfig{
+		ProxyURL:                  "http://127.0.0.1:4001",
+		Concurrency:               5,
+		PullLayerTimeoutInSeconds: 300,
+		Registries: map[string]RegistryPullConfig{
+			"registry.internal:5000": {
+				ProxyURL:                  &proxyURL,
+				Concurrency:               20,
+				PullLayerTimeoutInSeconds: &timeout,
+			},
+		},
+	}
+
+	require.Same(t, cfg, cfg.ForRegistry("docker.io"))
+
+	merged := cfg.ForRegistry("registry.internal:5000")
+	require.Equal(t, "", merged.ProxyURL)
+	require.Equal(t, uint(20), merged.Concurrency)
+	require.Equal(t, uint(0), merged.PullLayerTimeoutInSeconds)
+	require.Equal(t, "http://127.0.0.1:4001", cfg.ProxyURL)
+	require.Equal(t, uint(5), cfg.Concurrency)
+
+	cfg.TLS = TLSConfig{CAFile: "/etc/ca.pem"}
+	cfg.Registries["registry.test:5000"] = RegistryPullConfig{TLS: &TLSConfig{}}
+	require.Equal(t, "/etc/ca.pem", cfg.ForRegistry("registry.internal:5000").TLS.CAFile)
+	require.Equal(t, TLSConfig{}, cfg.ForRegistry("registry.test:5000").TLS)
+
+	// The registry by no_proxy bypasses the proxy unless it's overridden.
+	cfg.NoProxy = []string{"registry.test:5000", "harbor.svc"}
+	cfg.Registries["harbor.svc"] = RegistryPullConfig{ProxyURL: &cfg.ProxyURL}
+	require.Equal(t, "", cfg.ForRegistry("registry.test:5000").ProxyURL)
+	require.Equal(t, "http://127.0.0.1:4001", cfg.ForRegistry("harbor.svc").ProxyURL)
+	require.Equal(t, "http://127.0.0.1:4001", cfg.ForRegistry("docker.io").ProxyURL)
+}
@@ -52,3 +52,23 @@ func TestConfig(t *testing.T) {
 	// Verify the config is reloaded
 	require.Equal(t, uint64(0x50000000000), uint64(cfg.Get().Features.DiskUsageLimit))
 }
+
+func TestReloadError(t *testing.T) {
+	require.NoError(t, os.Setenv("X_CSI_MODE", "node"))
+	require.NoError(t, os.Setenv("CSI_NODE_ID", "test-node"))
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	copyFile(t, "../../test/testdata/config.test.yaml", configPath)
+	cfg, err := New(configPath)
+	require.NoError(t, err)
+	require.NoError(t, cfg.ReloadError())
+
+	// The config parsed before is kept until the config file is fixed.
+	require.NoError(t, os.WriteFile(configPath, []byte("features: ["), 0644))
+	cfg.reload(configPath)
+	require.Error(t, cfg.ReloadError())
+	require.NotEmpty(t, cfg.Get().RootDir)
+
+	copyFile(t, "../../test/testdata/config.test.yaml", configPath)
+	cfg.reload(configPath)
+	require.NoError(t, cfg.ReloadError())
+}
@@ -0,0 +1,125 @@
+// Package fault injects faults into the pull and mount paths at the rates
+// configured by fault_injection, it's only for the resilience testing of the
+// retry, cleanup and recovery logic in staging clusters, never enable it in
+// production.
+package fault
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/pkg/errors"
+)
+
+var ErrInjected = errors.New("injected fault")
+
+var current atomic.Pointer[config.Config]
+
+// Setup enables the fault injection with the fault_injection config, which
+// takes effect on config reload as well.
+func Setup(cfg *config.Config) {
+	current.Store(cfg)
+}
+
+func get() *config.FaultInjection {
+	cfg := current.Load()
+	if cfg == nil || !cfg.Get().FaultInjection.Enabled {
+		return nil
+	}
+	return &cfg.Get().FaultInjection
+}
+
+func hit(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+// Enabled returns true if the fault injection is enabled.
+func Enabled() bool {
+	return get() != nil
+}
+
+// BeforePull fails the pull with ENOSPC before any layer is pulled.
+func BeforePull(ctx context.Context) error {
+	fi := get()
+	if fi == nil {
+		return nil
+	}
+
+	if hit(fi.NoSpaceRate) {
+		logger.WithContext(ctx).Warnf("injected no space error")
+		return errors.Wrap(syscall.ENOSPC, ErrInjected.Error())
+	}
+
+	return nil
+}
+
+// BeforePullLayer slows down the pull of the layer.
+func BeforePullLayer(ctx context.Context, layer string) error {
+	fi := get()
+	if fi == nil {
+		return nil
+	}
+
+	if hit(fi.SlowTransferRate) {
+		delay := time.Duration(fi.SlowTransferDelayInSeconds) * time.Second
+		logger.WithContext(ctx).Warnf("injected slow transfer: %s, layer: %s", delay, layer)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// AfterPullLayer fails the pulled layer, the pulled files are kept to
+// exercise the resume and cleanup logic.
+func AfterPullLayer(ctx context.Context, layer string) error {
+	fi := get()
+	if fi == nil {
+		return nil
+	}
+
+	if hit(fi.LayerFailureRate) {
+		logger.WithContext(ctx).Warnf("injected layer failure: %s", layer)
+		return errors.Wrapf(ErrInjected, "pull layer %s", layer)
+	}
+
+	return nil
+}
+
+// Mount fails the mount.
+func Mount(ctx context.Context, mountPoint string) error {
+	fi := get()
+	if fi == nil {
+		return nil
+	}
+
+	if hit(fi.MountErrorRate) {
+		logger.WithContext(ctx).Warnf("injected mount error: %s", mountPoint)
+		return errors.Wrapf(ErrInjected, "mount %s", mountPoint)
+	}
+
+	return nil
+}
+
+// Umount fails the unmount.
+func Umount(ctx context.Context, mountPoint string) error {
+	fi := get()
+	if fi == nil {
+		return nil
+	}
+
+	if hit(fi.UmountErrorRate) {
+		logger.WithContext(ctx).Warnf("injected unmount error: %s", mountPoint)
+		return errors.Wrapf(ErrInjected, "unmount %s", mountPoint)
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package fault
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func setup(t *testing.T, fi config.FaultInjection) {
+	t.Helper()
+	Setup(config.NewWithRaw(&config.RawConfig{FaultInjection: fi}))
+	t.Cleanup(func() { current.Store(nil) })
+}
+
+func TestDisabled(t *testing.T) {
+	require.False(t, Enabled())
+	require.NoError(t, BeforePull(context.Background()))
+	require.NoError(t, AfterPullLayer(context.Background(), "model.safetensors"))
+	require.NoError(t, Mount(context.Background(), "/mnt"))
+	require.NoError(t, Umount(context.Background(), "/mnt"))
+
+	// Rates take no effect without enabled.
+	setup(t, config.FaultInjection{LayerFailureRate: 1, NoSpaceRate: 1, MountErrorRate: 1, UmountErrorRate: 1})
+	require.False(t, Enabled())
+	require.NoError(t, BeforePull(context.Background()))
+	require.NoError(t, AfterPullLayer(context.Background(), "model.safetensors"))
+	require.NoError(t, Mount(context.Background(), "/mnt"))
+	require.NoError(t, Umount(context.Background(), "/mnt"))
+}
+
+func TestInjected(t *testing.T) {
+	setup(t, config.FaultInjection{Enabled: true, LayerFailureRate: 1, NoSpaceRate: 1, MountErrorRate: 1, UmountErrorRate: 1})
+	require.True(t, Enabled())
+
+	err := BeforePull(context.Background())
+	require.ErrorIs(t, err, syscall.ENOSPC)
+	require.NoError(t, BeforePullLayer(context.Background(), "model.safetensors"))
+	require.ErrorIs(t, AfterPullLayer(context.Background(), "model.safetensors"), ErrInjected)
+	require.ErrorIs(t, Mount(context.Background(), "/mnt"), ErrInjected)
+	require.ErrorIs(t, Umount(context.Background(), "/mnt"), ErrInjected)
+}
+
+func TestSlowTransfer(t *testing.T) {
+	setup(t, config.FaultInjection{Enabled: true, SlowTransferRate: 1, SlowTransferDelayInSeconds: 60})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	require.ErrorIs(t, BeforePullLayer(ctx, "model.safetensors"), context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 10*time.Second)
+	require.NoError(t, BeforePull(context.Background()))
+}
@@ -16,8 +16,13 @@ type RequestOpKey struct{}
 type RequestVolumeNameKey struct{}
 type RequestTargetPathKey struct{}
 
+// NewContext returns the context of the op logged by WithContext, the ID of
+// the request set by WithRequestID is kept, e.g. the X-Request-ID of the
+// HTTP request.
 func NewContext(ctx context.Context, op, volumeName, targetPath string) context.Context {
-	ctx = context.WithValue(ctx, RequestIDKey{}, uuid.New().String())
+	if RequestID(ctx) == "" {
+		ctx = WithRequestID(ctx, uuid.New().String())
+	}
 	ctx = context.WithValue(ctx, RequestOpKey{}, op)
 	ctx = context.WithValue(ctx, RequestVolumeNameKey{}, volumeName)
 	if targetPath != "" {
@@ -26,6 +31,18 @@ func NewContext(ctx context.Context, op, volumeName, targetPath string) context.
 	return ctx
 }
 
+// WithRequestID returns the context with the ID of the request.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, RequestIDKey{}, requestID)
+}
+
+// RequestID returns the ID of the request of the context, empty if it's not
+// set.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(RequestIDKey{}).(string)
+	return requestID
+}
+
 func WithContext(ctx context.Context) *logrus.Entry {
 	entry := logger.WithField("request", ctx.Value(RequestIDKey{})).
 		WithField("op", ctx.Value(RequestOpKey{})).
@@ -25,6 +25,15 @@ func TestNewContext_EmptyTargetPath(t *testing.T) {
 	require.Equal(t, "NodeUnpublishVolume", ctx.Value(RequestOpKey{}))
 }
 
+func TestNewContext_RequestID(t *testing.T) {
+	require.Empty(t, RequestID(context.Background()))
+	require.NotEmpty(t, RequestID(NewContext(context.Background(), "op", "vol", "")))
+
+	ctx := NewContext(WithRequestID(context.Background(), "req-1"), "CreateVolume", "vol", "")
+	require.Equal(t, "req-1", RequestID(ctx))
+	require.Equal(t, "req-1", RequestID(NewContext(ctx, "CancelPull", "vol", "")))
+}
+
 func TestWithContext_Basic(t *testing.T) {
 	ctx := NewContext(context.Background(), "op", "vol", "")
 	entry := WithContext(ctx)
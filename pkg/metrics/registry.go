@@ -14,8 +14,14 @@ var (
 	Registry       = prometheus.NewRegistry()
 	Prefix         = "model_csi_"
 
-	sizeLabel = "size_in_mb"
-	opLabel   = "op"
+	sizeLabel   = "size_in_mb"
+	opLabel     = "op"
+	resultLabel = "result"
+)
+
+const (
+	CacheResultHit  = "hit"
+	CacheResultMiss = "miss"
 )
 
 var LatencyInSecondsBuckets = prometheus.ExponentialBuckets(1, 2, 16)
@@ -60,6 +66,12 @@ var (
 		},
 	)
 
+	NodePullLayerRetried = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: Prefix + "node_pull_layer_retried_total",
+		},
+	)
+
 	NodeOpLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    Prefix + "node_op_latency_in_seconds",
 		Buckets: LatencyInSecondsBuckets,
@@ -76,6 +88,61 @@ var (
 		},
 	)
 
+	NodePullCacheLookup = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: Prefix + "node_pull_cache_lookup_total",
+		},
+		[]string{resultLabel},
+	)
+
+	NodePullCacheSavedInBytes = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: Prefix + "node_pull_cache_saved_bytes_total",
+		},
+	)
+
+	NodePullRegistryInBytes = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: Prefix + "node_pull_registry_bytes_total",
+		},
+	)
+
+	NodeEvictedModels = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: Prefix + "node_evicted_models_total",
+		},
+	)
+
+	NodeScrubbedFiles = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: Prefix + "node_scrubbed_files_total",
+		},
+	)
+
+	NodeScrubCorruptedFiles = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: Prefix + "node_scrub_corrupted_files_total",
+		},
+	)
+
+	NodeCorruptedModels = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: Prefix + "node_corrupted_models",
+		},
+	)
+
+	NodeBlobStoreSizeInBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: Prefix + "node_blob_store_size_in_bytes",
+		},
+	)
+
+	NodeBlobStoreReclaimedInBytes = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: Prefix + "node_blob_store_reclaimed_bytes_total",
+		},
+	)
+
 	NodeMountedPVCModels = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name: Prefix + "node_mounted_pvc_models",
@@ -143,6 +210,19 @@ func NodePullOpObserve(op string, size int64, start time.Time, err error) {
 	}
 }
 
+// NodePullCacheObserve records whether a model pull was satisfied by an existing
+// local copy (hit) or by pulling from the registry (miss), the size is counted as
+// saved bytes on hit and as registry bytes on miss.
+func NodePullCacheObserve(hit bool, size int64) {
+	if hit {
+		NodePullCacheLookup.With(prometheus.Labels{resultLabel: CacheResultHit}).Inc()
+		NodePullCacheSavedInBytes.Add(float64(size))
+	} else {
+		NodePullCacheLookup.With(prometheus.Labels{resultLabel: CacheResultMiss}).Inc()
+		NodePullRegistryInBytes.Add(float64(size))
+	}
+}
+
 func init() {
 	DummyRegistry.MustRegister()
 
@@ -167,5 +247,15 @@ func init() {
 		NodeMountedInlineModels,
 		NodeMountedDynamicModels,
 		NodePullLayerTooLong,
+		NodePullLayerRetried,
+		NodePullCacheLookup,
+		NodePullCacheSavedInBytes,
+		NodePullRegistryInBytes,
+		NodeEvictedModels,
+		NodeScrubbedFiles,
+		NodeScrubCorruptedFiles,
+		NodeCorruptedModels,
+		NodeBlobStoreSizeInBytes,
+		NodeBlobStoreReclaimedInBytes,
 	)
 }
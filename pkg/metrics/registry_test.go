@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -17,3 +18,19 @@ func TestGetSizeLabel(t *testing.T) {
 	require.Equal(t, prometheus.Labels{sizeLabel: "8.0 TiB"}, getSizeLabel(1024*1024*1024*1024*8))
 	require.Equal(t, prometheus.Labels{sizeLabel: "+Inf"}, getSizeLabel(1024*1024*1024*1024*8+1))
 }
+
+func TestNodePullCacheObserve(t *testing.T) {
+	hits := testutil.ToFloat64(NodePullCacheLookup.With(prometheus.Labels{resultLabel: CacheResultHit}))
+	misses := testutil.ToFloat64(NodePullCacheLookup.With(prometheus.Labels{resultLabel: CacheResultMiss}))
+	saved := testutil.ToFloat64(NodePullCacheSavedInBytes)
+	pulled := testutil.ToFloat64(NodePullRegistryInBytes)
+
+	NodePullCacheObserve(true, 1024)
+	NodePullCacheObserve(false, 2048)
+	NodePullCacheObserve(false, 4096)
+
+	require.Equal(t, hits+1, testutil.ToFloat64(NodePullCacheLookup.With(prometheus.Labels{resultLabel: CacheResultHit})))
+	require.Equal(t, misses+2, testutil.ToFloat64(NodePullCacheLookup.With(prometheus.Labels{resultLabel: CacheResultMiss})))
+	require.Equal(t, saved+1024, testutil.ToFloat64(NodePullCacheSavedInBytes))
+	require.Equal(t, pulled+6144, testutil.ToFloat64(NodePullRegistryInBytes))
+}
@@ -7,18 +7,56 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
+	"sort"
 	"strings"
 
 	"github.com/modelpack/model-csi-driver/pkg/logger"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
 )
 
 const EnvPodIP = "POD_IP"
 
+const serviceNameLabel = "service_name"
+
 type Server struct {
-	listener net.Listener
-	addr     string
+	listener    net.Listener
+	addr        string
+	serviceName string
+}
+
+// serviceNameGatherer labels all the gathered metrics with the service name,
+// to distinguish the metrics of several driver instances (e.g. one per storage
+// tier) running on the same node, the metrics already labeled with it are
+// kept as is.
+type serviceNameGatherer struct {
+	prometheus.Gatherer
+	serviceName string
+}
+
+func (g *serviceNameGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.Gatherer.Gather()
+	if g.serviceName == "" {
+		return mfs, err
+	}
+	name := serviceNameLabel
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			if slices.ContainsFunc(m.Label, func(label *dto.LabelPair) bool {
+				return label.GetName() == name
+			}) {
+				continue
+			}
+			m.Label = append(m.Label, &dto.LabelPair{Name: &name, Value: &g.serviceName})
+			sort.Slice(m.Label, func(i, j int) bool {
+				return m.Label[i].GetName() < m.Label[j].GetName()
+			})
+		}
+	}
+	return mfs, err
 }
 
 var defaultHost = "0.0.0.0"
@@ -34,7 +72,7 @@ func GetAddrByEnv(addr string, local bool) string {
 	return addr
 }
 
-func NewServer(addr string) (*Server, error) {
+func NewServer(addr, serviceName string) (*Server, error) {
 	if addr == "" {
 		return nil, fmt.Errorf("metrics addr is required")
 	}
@@ -51,18 +89,19 @@ func NewServer(addr string) (*Server, error) {
 	}
 
 	return &Server{
-		listener: ln,
-		addr:     addr,
+		listener:    ln,
+		addr:        addr,
+		serviceName: serviceName,
 	}, nil
 }
 
 func (s *Server) Serve(stop <-chan struct{}) {
 	mux := http.NewServeMux()
 
-	handler := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{
+	handler := promhttp.HandlerFor(&serviceNameGatherer{Registry, s.serviceName}, promhttp.HandlerOpts{
 		ErrorHandling: promhttp.HTTPErrorOnError,
 	})
-	detailHandler := promhttp.HandlerFor(DetailRegistry, promhttp.HandlerOpts{
+	detailHandler := promhttp.HandlerFor(&serviceNameGatherer{DetailRegistry, s.serviceName}, promhttp.HandlerOpts{
 		ErrorHandling: promhttp.HTTPErrorOnError,
 	})
 	mux.Handle("/metrics", handler)
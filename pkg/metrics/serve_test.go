@@ -38,12 +38,12 @@ func TestGetAddrByEnv_NoPlaceholder(t *testing.T) {
 // ─── NewServer ────────────────────────────────────────────────────────────────
 
 func TestNewServer_EmptyAddr(t *testing.T) {
-	_, err := NewServer("")
+	_, err := NewServer("", "")
 	require.Error(t, err)
 }
 
 func TestNewServer_ValidAddr(t *testing.T) {
-	srv, err := NewServer("tcp://127.0.0.1:0")
+	srv, err := NewServer("tcp://127.0.0.1:0", "")
 	require.NoError(t, err)
 	require.NotNil(t, srv)
 
@@ -56,10 +56,42 @@ func TestNewServer_ValidAddr(t *testing.T) {
 
 func TestNewServer_InvalidPort(t *testing.T) {
 	// port 99999 is out of range.
-	_, err := NewServer("tcp://127.0.0.1:99999")
+	_, err := NewServer("tcp://127.0.0.1:99999", "")
 	require.Error(t, err)
 }
 
+func TestServiceNameGatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_counter"}, []string{"op"})
+	reg.MustRegister(counter)
+	counter.WithLabelValues("mount").Inc()
+
+	mfs, err := (&serviceNameGatherer{reg, "tier1.csi.modelpack.org"}).Gather()
+	require.NoError(t, err)
+	require.Len(t, mfs, 1)
+	labels := mfs[0].Metric[0].Label
+	require.Len(t, labels, 2)
+	require.Equal(t, "op", labels[0].GetName())
+	require.Equal(t, "service_name", labels[1].GetName())
+	require.Equal(t, "tier1.csi.modelpack.org", labels[1].GetValue())
+
+	// No label is added without service name.
+	mfs, err = (&serviceNameGatherer{reg, ""}).Gather()
+	require.NoError(t, err)
+	require.Len(t, mfs[0].Metric[0].Label, 1)
+
+	// The metrics already labeled with the service name are kept as is.
+	labeled := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_gauge"}, []string{serviceNameLabel})
+	labeled.MustRegister(gauge)
+	gauge.WithLabelValues("tier2.csi.modelpack.org").Set(1)
+	mfs, err = (&serviceNameGatherer{labeled, "tier1.csi.modelpack.org"}).Gather()
+	require.NoError(t, err)
+	labels = mfs[0].Metric[0].Label
+	require.Len(t, labels, 1)
+	require.Equal(t, "tier2.csi.modelpack.org", labels[0].GetValue())
+}
+
 // ─── MountItemCollector ───────────────────────────────────────────────────────
 
 func TestMountItemCollector_SetAndCollect(t *testing.T) {
@@ -34,6 +34,8 @@ type MountBuilder struct {
 	command    string
 	targetPath string
 	args       []string
+	readOnly   bool
+	bind       bool
 }
 
 func NewBuilder() *MountBuilder {
@@ -43,8 +45,12 @@ func NewBuilder() *MountBuilder {
 }
 
 type MountCmd struct {
-	command string
-	args    []string
+	command    string
+	args       []string
+	targetPath string
+	// The args to remount the read-only bind mount, as the ro option may be
+	// ignored by the bind mount of the older mount(8).
+	remountArgs []string
 }
 
 func (cmd MountCmd) String() string {
@@ -56,13 +62,21 @@ func (b *MountBuilder) Tmpfs() SizeLimiter {
 	return b
 }
 
+func (b *MountBuilder) ReadOnly() *MountBuilder {
+	b.args = append(b.args, "-o", "ro")
+	b.readOnly = true
+	return b
+}
+
 func (b *MountBuilder) Bind() BindFrom {
 	b.args = append(b.args, "--bind")
+	b.bind = true
 	return b
 }
 
 func (b *MountBuilder) RBind() BindFrom {
 	b.args = append(b.args, "--rbind")
+	b.bind = true
 	return b
 }
 
@@ -92,8 +106,13 @@ func (b *MountBuilder) Build() (MountCmd, error) {
 	if err := os.MkdirAll(b.targetPath, 0777); err != nil {
 		return MountCmd{}, fmt.Errorf("failed to make dir for targetpath %s, err: %v", b.targetPath, err)
 	}
-	return MountCmd{
-		command: b.command,
-		args:    b.args,
-	}, nil
+	cmd := MountCmd{
+		command:    b.command,
+		args:       b.args,
+		targetPath: b.targetPath,
+	}
+	if b.readOnly && b.bind {
+		cmd.remountArgs = []string{"-o", "remount,bind,ro", b.targetPath}
+	}
+	return cmd, nil
 }
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/moby/sys/mountinfo"
+	"github.com/modelpack/model-csi-driver/pkg/fault"
 	"github.com/modelpack/model-csi-driver/pkg/logger"
 	"github.com/pkg/errors"
 )
@@ -28,9 +29,19 @@ func Mount(ctx context.Context, builder Builder) error {
 	if err != nil {
 		return err
 	}
+	if err := fault.Mount(ctx, cmd.targetPath); err != nil {
+		return err
+	}
 	if out, err := execCmd(ctx, cmd.command, cmd.args...); err != nil {
 		return fmt.Errorf("mount failed: %v %s output %s", err, cmd, string(out))
 	}
+	if len(cmd.remountArgs) > 0 {
+		// The bind mount left writable is unmounted instead of being exposed.
+		if out, err := execCmd(ctx, cmd.command, cmd.remountArgs...); err != nil {
+			_ = UMount(ctx, cmd.targetPath, true)
+			return fmt.Errorf("remount read-only failed: %v %s output %s", err, cmd, string(out))
+		}
+	}
 	return nil
 }
 
@@ -39,6 +50,9 @@ func UMount(ctx context.Context, mountPoint string, lazy bool) error {
 	if mountPoint == "" {
 		return errors.New("target is not specified for unmounting the volume")
 	}
+	if err := fault.Umount(ctx, mountPoint); err != nil {
+		return err
+	}
 	var out string
 	var err error
 
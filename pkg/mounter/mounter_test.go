@@ -66,6 +66,20 @@ func TestMountBuilder_RBind_Build(t *testing.T) {
 	require.NotEmpty(t, cmd.String())
 }
 
+func TestMountBuilder_ReadOnly_Bind_Build(t *testing.T) {
+	tmpDir := t.TempDir()
+	target := filepath.Join(tmpDir, "target")
+
+	cmd, err := NewBuilder().ReadOnly().Bind().From("/source").MountPoint(target).Build()
+	require.NoError(t, err)
+	require.Equal(t, []string{"-o", "ro", "--bind", "/source", target}, cmd.args)
+	require.Equal(t, []string{"-o", "remount,bind,ro", target}, cmd.remountArgs)
+
+	cmd, err = NewBuilder().Bind().From("/source").MountPoint(target).Build()
+	require.NoError(t, err)
+	require.Empty(t, cmd.remountArgs)
+}
+
 func TestMountBuilder_Tmpfs_Build(t *testing.T) {
 	tmpDir := t.TempDir()
 	target := filepath.Join(tmpDir, "tmpfs-target")
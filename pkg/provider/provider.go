@@ -6,6 +6,8 @@ import (
 
 	"github.com/rexray/gocsi"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/modelpack/model-csi-driver/pkg/config"
 	"github.com/modelpack/model-csi-driver/pkg/service"
@@ -32,6 +34,15 @@ func New(cfg *config.Config, svc *service.Service) (gocsi.StoragePluginProvider,
 			return nil
 		},
 
+		// Track the in-flight CSI calls and their recent errors for the
+		// admin service.
+		Interceptors: []grpc.UnaryServerInterceptor{svc.TrackOperation},
+
+		// Serve the gRPC health checking beside the CSI services.
+		RegisterAdditionalServers: func(server *grpc.Server) {
+			healthpb.RegisterHealthServer(server, service.NewHealthServer(svc))
+		},
+
 		EnvVars: []string{
 			// Enable request validation.
 			gocsi.EnvVarSpecReqValidation + "=true",
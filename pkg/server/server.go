@@ -16,8 +16,10 @@ import (
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
@@ -61,6 +63,27 @@ type Server struct {
 	svc *service.Service
 }
 
+// gracefulProvider drains the dynamic servers before the CSI gRPC server is
+// stopped gracefully, as gocsi traps SIGTERM to stop the gRPC server and
+// exits the process right after then.
+type gracefulProvider struct {
+	gocsi.StoragePluginProvider
+	cfg *config.Config
+	svc *service.Service
+}
+
+func (p *gracefulProvider) GracefulStop(ctx context.Context) {
+	if p.svc.DynamicServerManager != nil {
+		timeout := time.Duration(p.cfg.Get().Features.DynamicServerShutdownTimeoutInSeconds) * time.Second
+		drainCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		if err := p.svc.DynamicServerManager.Shutdown(drainCtx); err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("shutdown dynamic servers")
+		}
+		cancel()
+	}
+	p.StoragePluginProvider.GracefulStop(ctx)
+}
+
 func NewServer(cfg *config.Config) (*Server, error) {
 	svc, err := service.New(cfg)
 	if err != nil {
@@ -82,6 +105,12 @@ func (server *Server) tokenAuthInterceptor(
 	info *grpc.UnaryServerInfo,
 	handler grpc.UnaryHandler,
 ) (interface{}, error) {
+	// The health checking is served without the token for the probes and
+	// the load balancers.
+	if strings.HasPrefix(info.FullMethod, "/grpc.health.v1.Health/") {
+		return handler(ctx, req)
+	}
+
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
 		return nil, status.Errorf(codes.Unauthenticated, "Missing metadata")
@@ -97,6 +126,8 @@ func (server *Server) tokenAuthInterceptor(
 }
 
 func (server *Server) Run(ctx context.Context) error {
+	defer func() { _ = server.svc.Close() }()
+
 	eg, ctx := errgroup.WithContext(ctx)
 
 	withFatalError := func(fn func() error) func() error {
@@ -117,6 +148,15 @@ func (server *Server) Run(ctx context.Context) error {
 				return err
 			}
 		}
+
+		// Several driver instances (e.g. one per storage tier) can run on the
+		// same node with different service_name and root_dir, make sure they
+		// don't share the root dir by mistake.
+		rootDirLock, err := utils.LockDir(server.cfg.Get().RootDir)
+		if err != nil {
+			return errors.Wrap(err, "lock root dir")
+		}
+		defer func() { _ = rootDirLock.Close() }()
 	}
 
 	if server.cfg.Get().PprofAddr != "" {
@@ -171,6 +211,8 @@ func (server *Server) Run(ctx context.Context) error {
 
 		logger.WithContext(ctx).Infof("serving csi plugin on %s", server.cfg.Get().CSIEndpoint)
 
+		pvd = &gracefulProvider{StoragePluginProvider: pvd, cfg: server.cfg, svc: server.svc}
+
 		gocsi.Run(ctx, server.cfg.Get().ServiceName, "A description of the SP", "", pvd)
 
 		return nil
@@ -179,7 +221,7 @@ func (server *Server) Run(ctx context.Context) error {
 	if server.cfg.Get().MetricsAddr != "" {
 		eg.Go(withFatalError(func() error {
 			metricsAddr := metrics.GetAddrByEnv(server.cfg.Get().MetricsAddr, false)
-			metricServer, err := metrics.NewServer(metricsAddr)
+			metricServer, err := metrics.NewServer(metricsAddr, server.cfg.Get().ServiceName)
 			if err != nil {
 				return errors.Wrap(err, "create metrics server")
 			}
@@ -191,7 +233,7 @@ func (server *Server) Run(ctx context.Context) error {
 		if envPodIP := os.Getenv(metrics.EnvPodIP); envPodIP != "" {
 			eg.Go(withFatalError(func() error {
 				metricsAddr := metrics.GetAddrByEnv(server.cfg.Get().MetricsAddr, true)
-				metricServer, err := metrics.NewServer(metricsAddr)
+				metricServer, err := metrics.NewServer(metricsAddr, server.cfg.Get().ServiceName)
 				if err != nil {
 					return errors.Wrap(err, "create metrics server")
 				}
@@ -219,12 +261,18 @@ func (server *Server) Run(ctx context.Context) error {
 					grpc.StatsHandler(otelgrpc.NewServerHandler()),
 					grpc.KeepaliveEnforcementPolicy(kaep),
 					grpc.KeepaliveParams(kasp),
-					grpc.UnaryInterceptor(server.tokenAuthInterceptor),
+					grpc.ChainUnaryInterceptor(server.tokenAuthInterceptor, server.svc.TrackOperation),
 				}
 				grpcServer := grpc.NewServer(opts...)
 				csi.RegisterControllerServer(grpcServer, server.svc)
 				csi.RegisterIdentityServer(grpcServer, server.svc)
 				csi.RegisterNodeServer(grpcServer, server.svc)
+				healthpb.RegisterHealthServer(grpcServer, service.NewHealthServer(server.svc))
+				service.RegisterModelServer(grpcServer, service.NewModelServer(server.svc))
+				if server.cfg.Get().Features.GRPCDebug {
+					service.RegisterAdminServer(grpcServer, service.NewAdminServer(server.svc))
+					reflection.Register(grpcServer)
+				}
 				return grpcServer.Serve(lis)
 			}))
 		}
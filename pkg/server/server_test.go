@@ -130,14 +130,7 @@ func testStaticVolume(t *testing.T, ctx context.Context, cfg *config.Config, ser
 	controllerClient, err := client.NewGRPCClient(cfg, cfg.Get().ExternalCSIEndpoint)
 	require.NoError(t, err)
 
-	// create volume
-	resp1, err := controllerClient.CreateVolume(ctx, volumeName, map[string]string{
-		cfg.Get().ParameterKeyType():      "image",
-		cfg.Get().ParameterKeyReference(): testImage,
-	})
-	require.NoError(t, err)
-
-	// create again with timeout, the volume should be cleaned up
+	// create with timeout, the volume should be cleaned up
 	if withTimeout {
 		ctx, cancel := context.WithTimeout(ctx, time.Second*1)
 		defer cancel()
@@ -152,6 +145,13 @@ func testStaticVolume(t *testing.T, ctx context.Context, cfg *config.Config, ser
 		return
 	}
 
+	// create volume
+	resp1, err := controllerClient.CreateVolume(ctx, volumeName, map[string]string{
+		cfg.Get().ParameterKeyType():      "image",
+		cfg.Get().ParameterKeyReference(): testImage,
+	})
+	require.NoError(t, err)
+
 	// check if the volume is created
 	statusPath := filepath.Join(cfg.Get().RootDir, "volumes", volumeName, "status.json")
 	_, err = os.Stat(statusPath)
@@ -179,6 +179,13 @@ func testStaticVolume(t *testing.T, ctx context.Context, cfg *config.Config, ser
 	require.Equal(t, resp1.GetVolume().GetVolumeId(), resp2.GetVolume().GetVolumeId())
 	volumeID := resp1.GetVolume().GetVolumeId()
 
+	// create volume again with same name but another model
+	_, err = controllerClient.CreateVolume(ctx, volumeName, map[string]string{
+		cfg.Get().ParameterKeyType():      "image",
+		cfg.Get().ParameterKeyReference(): testImage + "-other",
+	})
+	require.True(t, strings.Contains(err.Error(), "AlreadyExists"))
+
 	// mount the volume
 	mountedDir := volumeName + "-mounted"
 	targetPath := filepath.Join(cfg.Get().RootDir, mountedDir)
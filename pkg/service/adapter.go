@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+)
+
+// The subdir of the model dir holding the adapters of the composite mount,
+// e.g. /var/lib/dragonfly/model-csi/volumes/$volumeName/model/adapters/$name.
+const adaptersDir = "adapters"
+
+var adapterNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// adapterName derives the name of the adapter subdir from the reference,
+// e.g. sql-lora for "registry.example.com/loras/sql-lora:v1" or
+// "Qwen/sql-lora@main".
+func adapterName(reference string) string {
+	name := strings.TrimSuffix(strings.TrimSpace(reference), "/")
+	if i := strings.LastIndex(name, "@"); i > 0 {
+		name = name[:i]
+	}
+	name = path.Base(name)
+	if i := strings.LastIndex(name, ":"); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// validateAdapters fills the defaults of the adapters of the composite mount
+// and checks them, the adapters must be of the supported types and get the
+// unique names.
+func validateAdapters(baseType string, adapters []status.Adapter) ([]status.Adapter, error) {
+	validated := make([]status.Adapter, 0, len(adapters))
+	names := map[string]bool{}
+	for _, adapter := range adapters {
+		adapter.Reference = strings.TrimSpace(adapter.Reference)
+		if adapter.Reference == "" {
+			return nil, errors.New("empty adapter reference")
+		}
+		adapter.Type = strings.TrimSpace(adapter.Type)
+		if adapter.Type == "" {
+			adapter.Type = baseType
+		}
+		if !isSupportedModelType(adapter.Type) {
+			return nil, errors.Errorf("unsupported model type of adapter %s: %s", adapter.Reference, adapter.Type)
+		}
+		adapter.Name = strings.TrimSpace(adapter.Name)
+		if adapter.Name == "" {
+			adapter.Name = adapterName(adapter.Reference)
+		}
+		if !adapterNameRegexp.MatchString(adapter.Name) {
+			return nil, errors.Errorf("invalid adapter name: %q", adapter.Name)
+		}
+		if names[adapter.Name] {
+			return nil, errors.Errorf("duplicate adapter name: %s", adapter.Name)
+		}
+		names[adapter.Name] = true
+		validated = append(validated, adapter)
+	}
+	return validated, nil
+}
+
+// pullAdapters pulls the adapters of the composite mount into the adapters
+// subdir of the model dir. Each adapter is pulled into its own dir beside the
+// model dir first, so that it's resumed and shared like a model, then moved
+// into the model dir once pulled.
+func (worker *Worker) pullAdapters(ctx context.Context, hook *status.Hook, modelDir string, opts PullOptions, setState func(state status.State) error) error {
+	for _, adapter := range opts.Adapters {
+		adapterOpts := PullOptions{
+			Type:     adapter.Type,
+			Secrets:  opts.Secrets,
+			Priority: opts.Priority,
+		}
+		// /var/lib/dragonfly/model-csi/volumes/$volumeName/adapters/$name/model
+		stagingDir := filepath.Join(filepath.Dir(modelDir), adaptersDir, adapter.Name, "model")
+		if !canResumePull(stagingDir, pullStateKey(adapter.Reference, adapterOpts)) {
+			if err := os.RemoveAll(stagingDir); err != nil {
+				return errors.Wrapf(err, "cleanup adapter dir before pull: %s", stagingDir)
+			}
+		}
+
+		puller, _, err := worker.newModelPuller(ctx, adapterOpts, hook, nil, setState)
+		if err != nil {
+			return err
+		}
+		if _, err := worker.pullShared(ctx, puller, hook, adapter.Reference, stagingDir, adapterOpts); err != nil {
+			return errors.Wrapf(err, "pull adapter: %s", adapter.Reference)
+		}
+
+		adapterDir := filepath.Join(modelDir, adaptersDir, adapter.Name)
+		if err := os.RemoveAll(adapterDir); err != nil {
+			return errors.Wrapf(err, "cleanup adapter dir: %s", adapterDir)
+		}
+		if err := os.MkdirAll(filepath.Dir(adapterDir), 0755); err != nil {
+			return errors.Wrapf(err, "create adapters dir: %s", filepath.Dir(adapterDir))
+		}
+		if err := os.Rename(stagingDir, adapterDir); err != nil {
+			return errors.Wrapf(err, "move adapter dir: %s", adapterDir)
+		}
+		logger.WithContext(ctx).Infof("pulled adapter %s into %s", adapter.Reference, adapterDir)
+	}
+
+	return nil
+}
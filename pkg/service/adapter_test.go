@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdapterName(t *testing.T) {
+	require.Equal(t, "sql-lora", adapterName("registry.example.com/loras/sql-lora:v1"))
+	require.Equal(t, "sql-lora", adapterName("registry.example.com:5000/sql-lora"))
+	require.Equal(t, "sql-lora", adapterName("sql-lora@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"))
+	require.Equal(t, "sql-lora", adapterName("Qwen/sql-lora@main"))
+	require.Equal(t, "sql", adapterName("s3://loras/sql/"))
+	require.Equal(t, "sql.tar.gz", adapterName("loras/sql.tar.gz"))
+}
+
+func TestValidateAdapters(t *testing.T) {
+	adapters, err := validateAdapters(ModelTypeImage, []status.Adapter{
+		{Reference: " registry.example.com/loras/sql:v1 "},
+		{Name: "chat", Reference: "Qwen/chat-lora", Type: ModelTypeHuggingFace},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []status.Adapter{
+		{Name: "sql", Reference: "registry.example.com/loras/sql:v1", Type: ModelTypeImage},
+		{Name: "chat", Reference: "Qwen/chat-lora", Type: ModelTypeHuggingFace},
+	}, adapters)
+
+	_, err = validateAdapters(ModelTypeImage, []status.Adapter{{Reference: ""}})
+	require.ErrorContains(t, err, "empty adapter reference")
+	_, err = validateAdapters(ModelTypeImage, []status.Adapter{{Reference: "sql", Type: "unknown"}})
+	require.ErrorContains(t, err, "unsupported model type")
+	_, err = validateAdapters(ModelTypeImage, []status.Adapter{{Name: "../sql", Reference: "sql"}})
+	require.ErrorContains(t, err, "invalid adapter name")
+	_, err = validateAdapters(ModelTypeImage, []status.Adapter{{Reference: "a/sql:v1"}, {Reference: "b/sql:v2"}})
+	require.ErrorContains(t, err, "duplicate adapter name: sql")
+}
+
+// namedPuller writes the file named by the reference into the model dir.
+type namedPuller struct {
+	references []string
+}
+
+func (p *namedPuller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	p.references = append(p.references, reference)
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(targetDir, adapterName(reference)), []byte(reference), 0644)
+}
+
+func TestPullModel_Adapters(t *testing.T) {
+	worker := newWorkerWithMockPuller(t, nil)
+	puller := &namedPuller{}
+	worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return puller
+	}
+
+	opts := PullOptions{Adapters: []status.Adapter{
+		{Name: "sql", Reference: "test/sql-lora:v1", Type: ModelTypeImage},
+		{Name: "chat", Reference: "test/chat-lora:v1", Type: ModelTypeImage},
+	}}
+	modelDir := worker.cfg.Get().GetModelDir("pvc-adapters")
+	require.NoError(t, worker.PullModel(context.Background(), true, "pvc-adapters", "", "test/model:latest", modelDir, opts))
+	require.Equal(t, []string{"test/model:latest", "test/sql-lora:v1", "test/chat-lora:v1"}, puller.references)
+
+	// The adapters are laid out in the subdirs named by them.
+	require.FileExists(t, filepath.Join(modelDir, "model"))
+	require.FileExists(t, filepath.Join(modelDir, adaptersDir, "sql", "sql-lora"))
+	require.FileExists(t, filepath.Join(modelDir, adaptersDir, "chat", "chat-lora"))
+	require.NoDirExists(t, filepath.Join(worker.cfg.Get().GetVolumeDir("pvc-adapters"), adaptersDir, "sql", "model"))
+
+	modelStatus, err := worker.sm.Get(filepath.Join(filepath.Dir(modelDir), "status.json"))
+	require.NoError(t, err)
+	require.Equal(t, status.StatePullSucceeded, modelStatus.State)
+	require.Equal(t, opts.Adapters, modelStatus.Adapters)
+
+	// The composite mount is reused only with the same adapters.
+	secondDir := worker.cfg.Get().GetModelDir("pvc-adapters-reused")
+	require.NoError(t, worker.PullModel(context.Background(), true, "pvc-adapters-reused", "", "test/model:latest", secondDir, opts))
+	require.Len(t, puller.references, 3)
+	require.FileExists(t, filepath.Join(secondDir, adaptersDir, "chat", "chat-lora"))
+
+	thirdDir := worker.cfg.Get().GetModelDir("pvc-adapters-other")
+	require.NoError(t, worker.PullModel(context.Background(), true, "pvc-adapters-other", "", "test/model:latest", thirdDir, PullOptions{Adapters: opts.Adapters[:1]}))
+	require.Len(t, puller.references, 5)
+	require.NoDirExists(t, filepath.Join(thirdDir, adaptersDir, "chat"))
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	adminServiceName = "modelcsi.admin.v1.Admin"
+	adminProtoFile   = "modelcsi/admin/v1/admin.proto"
+)
+
+// The methods of the admin service, each takes google.protobuf.Empty and
+// returns the debug state as google.protobuf.Struct.
+var adminMethods = []struct {
+	name string
+	call func(AdminService, context.Context, *emptypb.Empty) (*structpb.Struct, error)
+}{
+	{"ListOperations", AdminService.ListOperations},
+	{"ListLocks", AdminService.ListLocks},
+	{"ListErrors", AdminService.ListErrors},
+}
+
+// The admin service is defined by the descriptor registered here instead of
+// a generated proto, so that it's described by the server reflection, e.g.
+// `grpcurl describe modelcsi.admin.v1.Admin`.
+func init() {
+	methods := []*descriptorpb.MethodDescriptorProto{}
+	for _, method := range adminMethods {
+		methods = append(methods, &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(method.name),
+			InputType:  proto.String(".google.protobuf.Empty"),
+			OutputType: proto.String(".google.protobuf.Struct"),
+		})
+	}
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String(adminProtoFile),
+		Package:    proto.String("modelcsi.admin.v1"),
+		Dependency: []string{"google/protobuf/empty.proto", "google/protobuf/struct.proto"},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name:   proto.String("Admin"),
+			Method: methods,
+		}},
+		Syntax: proto.String("proto3"),
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(errors.Wrap(err, "create admin service descriptor"))
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(file); err != nil {
+		panic(errors.Wrap(err, "register admin service descriptor"))
+	}
+}
+
+// AdminService exposes the debug state of the driver, for the operators to
+// debug the stuck CSI calls with grpcurl.
+type AdminService interface {
+	ListOperations(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+	ListLocks(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+	ListErrors(context.Context, *emptypb.Empty) (*structpb.Struct, error)
+}
+
+// AdminServer serves the admin service by the state of the service: the
+// in-flight CSI calls, the context keys held by the worker and the recent
+// errors of the CSI calls.
+type AdminServer struct {
+	svc *Service
+}
+
+func NewAdminServer(svc *Service) *AdminServer {
+	return &AdminServer{svc: svc}
+}
+
+// RegisterAdminServer registers the admin service to the gRPC server.
+func RegisterAdminServer(server *grpc.Server, admin AdminService) {
+	desc := grpc.ServiceDesc{
+		ServiceName: adminServiceName,
+		HandlerType: (*AdminService)(nil),
+		Streams:     []grpc.StreamDesc{},
+		Metadata:    adminProtoFile,
+	}
+	for _, method := range adminMethods {
+		call := method.call
+		fullMethod := "/" + adminServiceName + "/" + method.name
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: method.name,
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &emptypb.Empty{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return call(srv.(AdminService), ctx, req.(*emptypb.Empty))
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod}, handler)
+			},
+		})
+	}
+	server.RegisterService(&desc, admin)
+}
+
+// toStruct converts the debug state to google.protobuf.Struct by its JSON.
+func toStruct(key string, value interface{}) (*structpb.Struct, error) {
+	data, err := json.Marshal(map[string]interface{}{key: value})
+	if err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "marshal debug state").Error())
+	}
+	st := &structpb.Struct{}
+	if err := protojson.Unmarshal(data, st); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "unmarshal debug state").Error())
+	}
+	return st, nil
+}
+
+func (admin *AdminServer) ListOperations(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	operations := []Operation{}
+	if admin.svc.operations != nil {
+		operations = admin.svc.operations.Inflight()
+	}
+	return toStruct("operations", operations)
+}
+
+func (admin *AdminServer) ListLocks(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	locks := []HeldLock{}
+	if admin.svc.worker != nil {
+		locks = admin.svc.worker.kmutex.HeldLocks()
+	}
+	return toStruct("locks", locks)
+}
+
+func (admin *AdminServer) ListErrors(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	operations := []Operation{}
+	if admin.svc.operations != nil {
+		operations = admin.svc.operations.RecentErrors()
+	}
+	return toStruct("errors", operations)
+}
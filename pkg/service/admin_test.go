@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcStatus "google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestAdminServer(t *testing.T) {
+	svc, _ := newNodeService(t)
+	svc.operations = NewOperationTracker()
+	ctx := context.Background()
+
+	// The admin service is described by the server reflection.
+	_, err := protoregistry.GlobalFiles.FindDescriptorByName(adminServiceName + ".ListLocks")
+	require.NoError(t, err)
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	RegisterAdminServer(server, NewAdminServer(svc))
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	invoke := func(method string) *structpb.Struct {
+		t.Helper()
+		resp := &structpb.Struct{}
+		require.NoError(t, conn.Invoke(ctx, "/"+adminServiceName+"/"+method, &emptypb.Empty{}, resp))
+		return resp
+	}
+
+	require.NoError(t, svc.worker.kmutex.Lock(ctx, "pvc-1/"))
+	locks := invoke("ListLocks").GetFields()["locks"].GetListValue().GetValues()
+	require.Len(t, locks, 1)
+	require.Equal(t, "pvc-1/", locks[0].GetStructValue().GetFields()["key"].GetStringValue())
+	svc.worker.kmutex.Unlock("pvc-1/")
+	require.Empty(t, invoke("ListLocks").GetFields()["locks"].GetListValue().GetValues())
+
+	id := svc.operations.begin("CreateVolume", "pvc-2")
+	operations := invoke("ListOperations").GetFields()["operations"].GetListValue().GetValues()
+	require.Len(t, operations, 1)
+	require.Equal(t, "pvc-2", operations[0].GetStructValue().GetFields()["volume_id"].GetStringValue())
+	svc.operations.end(id, grpcStatus.Error(codes.DeadlineExceeded, "pull model timeout"))
+	recentErrors := invoke("ListErrors").GetFields()["errors"].GetListValue().GetValues()
+	require.Len(t, recentErrors, 1)
+	require.Equal(t, codes.DeadlineExceeded.String(), recentErrors[0].GetStructValue().GetFields()["code"].GetStringValue())
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/modelpack/modctl/pkg/backend"
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/modelpack/model-csi-driver/pkg/utils"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+var NewArchivePuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+	return &archivePuller{
+		pullCfg:          pullCfg,
+		hook:             hook,
+		diskQuotaChecker: diskQuotaChecker,
+	}
+}
+
+// archivePuller imports the model from a tarball (optionally compressed) or a
+// dir already present on the node, e.g. shipped by hostPath in the
+// air-gapped clusters, the reference is the path of the tarball or dir,
+// which must be located under pull_config.archive.root_dir.
+type archivePuller struct {
+	pullCfg          *config.PullConfig
+	hook             *status.Hook
+	diskQuotaChecker *DiskQuotaChecker
+}
+
+// resolveArchivePath returns the path of the archive, the relative path is
+// relative to the archive root dir.
+func resolveArchivePath(rootDir, reference string) (string, error) {
+	if rootDir == "" {
+		return "", errors.New("model type archive is disabled, pull_config.archive.root_dir is not set")
+	}
+
+	path := reference
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(rootDir, path)
+	}
+	under, err := utils.IsPathUnder(rootDir, path)
+	if err != nil {
+		return "", errors.Wrapf(err, "check archive path: %s", reference)
+	}
+	if !under {
+		return "", errors.Errorf("archive %s is not under %s", reference, rootDir)
+	}
+
+	return path, nil
+}
+
+// archiveFileDescriptor returns the descriptor reporting the progress of the
+// archive file, which is identified by the path instead of the content.
+func archiveFileDescriptor(path string, size int64) ocispec.Descriptor {
+	return fileDescriptor(backend.InspectedModelArtifactLayer{
+		Filepath: path,
+		Size:     size,
+		Digest:   digest.FromString(path).String(),
+	})
+}
+
+func (p *archivePuller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	path, err := resolveArchivePath(p.pullCfg.Archive.RootDir, reference)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return errors.Wrapf(err, "stat archive: %s", path)
+	}
+
+	includeFile := includeLayerFunc(ctx, p.hook, excludeModelWeights, excludeFilePatterns)
+	include := func(name string) bool {
+		return includeFile(backend.InspectedModelArtifactLayer{Filepath: name})
+	}
+
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return errors.Wrapf(err, "create model dir: %s", targetDir)
+	}
+
+	if info.IsDir() {
+		err = p.importDir(ctx, path, targetDir, include)
+	} else {
+		err = p.importTarball(ctx, path, info.Size(), targetDir, include)
+	}
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Errorf("failed to import model archive: %s", path)
+		return errors.Wrapf(err, "import model archive: %s", path)
+	}
+
+	return nil
+}
+
+func (p *archivePuller) importTarball(ctx context.Context, path string, size int64, targetDir string, include func(name string) bool) error {
+	// The size of the compressed tarball is less than the extracted files.
+	if p.diskQuotaChecker != nil {
+		if err := p.diskQuotaChecker.CheckMinSize(ctx, path, size); err != nil {
+			return errors.Wrap(err, "check disk quota")
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "open archive: %s", path)
+	}
+	defer func() { _ = file.Close() }()
+
+	logger.WithContext(ctx).Infof("extracting model tarball: %s", path)
+	p.hook.SetTotal(1)
+
+	desc := archiveFileDescriptor(filepath.Base(path), size)
+	p.hook.BeforePullLayer(desc, ocispec.Manifest{})
+	err = extractTar(ctx, file, targetDir, include, &p.pullCfg.Extraction)
+	p.hook.AfterPullLayer(desc, err)
+
+	return err
+}
+
+func (p *archivePuller) importDir(ctx context.Context, srcDir, targetDir string, include func(name string) bool) error {
+	files := []string{}
+	modelSize := int64(0)
+	if err := filepath.WalkDir(srcDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !include(rel) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return errors.Wrapf(err, "stat: %s", path)
+		}
+		if info.Mode().IsRegular() || info.Mode()&os.ModeSymlink != 0 {
+			files = append(files, rel)
+			modelSize += info.Size()
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "walk archive dir: %s", srcDir)
+	}
+
+	if p.diskQuotaChecker != nil {
+		if err := p.diskQuotaChecker.CheckSize(ctx, srcDir, modelSize); err != nil {
+			return errors.Wrap(err, "check disk quota")
+		}
+	}
+
+	logger.WithContext(ctx).Infof("copying model dir: %s, files: %d", srcDir, len(files))
+	p.hook.SetTotal(len(files))
+
+	// The files are copied instead of hardlinked, so that a write to the
+	// volume never changes the archive shared by the volumes.
+	for _, rel := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		src := filepath.Join(srcDir, rel)
+		info, err := os.Lstat(src)
+		if err != nil {
+			return errors.Wrapf(err, "stat: %s", src)
+		}
+		desc := archiveFileDescriptor(rel, info.Size())
+		p.hook.BeforePullLayer(desc, ocispec.Manifest{})
+		err = importFile(src, rel, info, targetDir)
+		p.hook.AfterPullLayer(desc, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func importFile(src, rel string, info os.FileInfo, targetDir string) error {
+	target := filepath.Join(targetDir, rel)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return errors.Wrapf(err, "create dir: %s", filepath.Dir(target))
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(src)
+		if err != nil {
+			return errors.Wrapf(err, "read link: %s", src)
+		}
+		if filepath.IsAbs(link) || !filepath.IsLocal(filepath.Join(filepath.Dir(rel), link)) {
+			return errors.Errorf("invalid symlink in archive: %s -> %s", rel, link)
+		}
+		if err := os.Symlink(link, target); err != nil {
+			return errors.Wrapf(err, "create symlink: %s", target)
+		}
+		return nil
+	}
+
+	file, err := os.Open(src)
+	if err != nil {
+		return errors.Wrapf(err, "open file: %s", src)
+	}
+	defer func() { _ = file.Close() }()
+
+	if err := writeFile(file, target); err != nil {
+		return err
+	}
+	if err := os.Chmod(target, info.Mode().Perm()); err != nil {
+		return errors.Wrapf(err, "chmod file: %s", target)
+	}
+
+	return nil
+}
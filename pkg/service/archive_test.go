@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchivePuller_Pull(t *testing.T) {
+	rootDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "qwen3.tar.gz"), newTarGz(t, []tarEntry{
+		{name: "config.json", content: `{"model_type":"qwen3"}`},
+		{name: "model.safetensors", content: "weights"},
+	}), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(rootDir, "qwen3", "tokenizer"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "qwen3", "config.json"), []byte(`{"model_type":"qwen3"}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "qwen3", "tokenizer", "vocab.json"), []byte("{}"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "qwen3", "model.safetensors"), []byte("weights"), 0644))
+	require.NoError(t, os.Symlink("config.json", filepath.Join(rootDir, "qwen3", "latest")))
+
+	ctx := context.Background()
+	hook := status.NewHook(ctx)
+	p := NewArchivePuller(ctx, &config.PullConfig{Archive: config.ArchiveConfig{RootDir: rootDir}}, hook, nil)
+
+	targetDir := filepath.Join(t.TempDir(), "model")
+	require.NoError(t, p.Pull(ctx, "qwen3.tar.gz", targetDir, true, nil))
+	require.FileExists(t, filepath.Join(targetDir, "config.json"))
+	require.NoFileExists(t, filepath.Join(targetDir, "model.safetensors"))
+	progress := hook.GetProgress()
+	require.Equal(t, 1, progress.Total)
+	require.Len(t, progress.Items, 1)
+	require.Equal(t, "/qwen3.tar.gz", progress.Items[0].Path)
+	require.NotNil(t, progress.Items[0].FinishedAt)
+
+	hook = status.NewHook(ctx)
+	p = NewArchivePuller(ctx, &config.PullConfig{Archive: config.ArchiveConfig{RootDir: rootDir}}, hook, nil)
+	targetDir = filepath.Join(t.TempDir(), "model")
+	require.NoError(t, p.Pull(ctx, filepath.Join(rootDir, "qwen3"), targetDir, false, nil))
+	data, err := os.ReadFile(filepath.Join(targetDir, "tokenizer", "vocab.json"))
+	require.NoError(t, err)
+	require.Equal(t, "{}", string(data))
+	require.FileExists(t, filepath.Join(targetDir, "model.safetensors"))
+	link, err := os.Readlink(filepath.Join(targetDir, "latest"))
+	require.NoError(t, err)
+	require.Equal(t, "config.json", link)
+	require.Equal(t, 4, hook.GetProgress().Total)
+	// The files are copied instead of hardlinked.
+	srcInfo, err := os.Stat(filepath.Join(rootDir, "qwen3", "config.json"))
+	require.NoError(t, err)
+	dstInfo, err := os.Stat(filepath.Join(targetDir, "config.json"))
+	require.NoError(t, err)
+	require.False(t, os.SameFile(srcInfo, dstInfo))
+}
+
+func TestArchivePuller_InvalidPath(t *testing.T) {
+	rootDir := t.TempDir()
+	ctx := context.Background()
+
+	p := NewArchivePuller(ctx, &config.PullConfig{}, status.NewHook(ctx), nil)
+	require.ErrorContains(t, p.Pull(ctx, "qwen3.tar.gz", t.TempDir(), false, nil), "disabled")
+
+	p = NewArchivePuller(ctx, &config.PullConfig{Archive: config.ArchiveConfig{RootDir: rootDir}}, status.NewHook(ctx), nil)
+	for _, reference := range []string{"/etc", "../etc", "qwen3/../../etc"} {
+		require.ErrorContains(t, p.Pull(ctx, reference, t.TempDir(), false, nil), "is not under", reference)
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+)
+
+// asyncMount is the dynamic mount created in the background. It's kept until
+// the mount is created, or for the failed one until the mount is deleted or
+// created again, so that GetMount tells the pending and the failed mounts
+// from the mounts not found.
+type asyncMount struct {
+	mount modelStatus.Status
+	done  chan struct{}
+	// Set before done is closed.
+	err error
+}
+
+func asyncMountKey(volumeName, mountID string) string {
+	return volumeName + "/" + mountID
+}
+
+// createMountAsync creates the dynamic mount by the create func in the
+// background, the mount being created already isn't created again.
+func (s *Service) createMountAsync(ctx context.Context, mount modelStatus.Status, create func(ctx context.Context) error) {
+	key := asyncMountKey(mount.VolumeName, mount.MountID)
+	record := &asyncMount{mount: mount, done: make(chan struct{})}
+	if previous, loaded := s.asyncMounts.LoadOrStore(key, record); loaded {
+		select {
+		case <-previous.(*asyncMount).done:
+			// Retry the failed mount.
+			if !s.asyncMounts.CompareAndSwap(key, previous, record) {
+				return
+			}
+		default:
+			return
+		}
+	}
+
+	// The pull outlives the request.
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if err := create(ctx); err != nil {
+			logger.WithContext(ctx).WithError(err).Errorf("failed to create mount asynchronously: %s", key)
+			record.err = err
+		} else {
+			s.asyncMounts.CompareAndDelete(key, record)
+		}
+		close(record.done)
+	}()
+}
+
+// asyncMountStatus returns the mount being created in the background, or the
+// error of the failed one, nil and nil if the mount isn't created in the
+// background.
+func (s *Service) asyncMountStatus(volumeName, mountID string) (*modelStatus.Status, error) {
+	value, ok := s.asyncMounts.Load(asyncMountKey(volumeName, mountID))
+	if !ok {
+		return nil, nil
+	}
+	record := value.(*asyncMount)
+	select {
+	case <-record.done:
+		return nil, record.err
+	default:
+		mount := record.mount
+		return &mount, nil
+	}
+}
+
+// failedAsyncMount returns the failed mount created in the background with
+// its error, nil and nil if the mount isn't failed in the background.
+func (s *Service) failedAsyncMount(volumeName, mountID string) (*modelStatus.Status, error) {
+	value, ok := s.asyncMounts.Load(asyncMountKey(volumeName, mountID))
+	if !ok {
+		return nil, nil
+	}
+	record := value.(*asyncMount)
+	select {
+	case <-record.done:
+		if record.err == nil {
+			return nil, nil
+		}
+		mount := record.mount
+		mount.State = failedMountState(record.err)
+		return &mount, record.err
+	default:
+		return nil, nil
+	}
+}
+
+// failedMountState returns the state of the mount failed to be created by
+// the reason of the ErrorInfo of the error, e.g. PULL_TIMEOUT, PULL_FAILED
+// by default.
+func failedMountState(err error) string {
+	if reason := errorInfo(err).GetReason(); reason == modelStatus.StatePullTimeout || reason == modelStatus.StatePullCanceled {
+		return reason
+	}
+	return modelStatus.StatePullFailed
+}
+
+// getMount returns the dynamic mount, or the mount being created in the
+// background. The error wraps os.ErrNotExist if neither exists, or is the
+// error of the failed mount created in the background.
+func (s *Service) getMount(ctx context.Context, volumeName, mountID string) (*modelStatus.Status, error) {
+	mount, err := s.GetDynamicVolume(ctx, volumeName, mountID)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return mount, err
+	}
+	// The mount created in the background is pending until its status is
+	// written, and the failed one is cleaned up.
+	pending, asyncErr := s.asyncMountStatus(volumeName, mountID)
+	if asyncErr != nil {
+		return nil, asyncErr
+	}
+	if pending != nil {
+		return s.withDeletingState(pending), nil
+	}
+	return nil, err
+}
+
+// pendingAsyncMounts returns the number of the mounts of the volume being
+// created in the background.
+func (s *Service) pendingAsyncMounts(volumeName string) int {
+	pending := 0
+	s.asyncMounts.Range(func(key, value interface{}) bool {
+		if !strings.HasPrefix(key.(string), volumeName+"/") {
+			return true
+		}
+		select {
+		case <-value.(*asyncMount).done:
+		default:
+			pending++
+		}
+		return true
+	})
+	return pending
+}
+
+// forgetAsyncMount forgets the mount created in the background, e.g. the
+// failed mount deleted.
+func (s *Service) forgetAsyncMount(volumeName, mountID string) {
+	s.asyncMounts.Delete(asyncMountKey(volumeName, mountID))
+}
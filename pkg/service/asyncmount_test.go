@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateMountAsync(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	mount := modelStatus.Status{VolumeName: "csi-vol", MountID: "m1", State: modelStatus.StatePullRunning}
+
+	mountStatus, err := svc.asyncMountStatus("csi-vol", "m1")
+	require.NoError(t, err)
+	require.Nil(t, mountStatus)
+
+	// The mount is pending until it's created.
+	release := make(chan error)
+	svc.createMountAsync(ctx, mount, func(ctx context.Context) error { return <-release })
+	mountStatus, err = svc.asyncMountStatus("csi-vol", "m1")
+	require.NoError(t, err)
+	require.Equal(t, modelStatus.StatePullRunning, mountStatus.State)
+	require.Equal(t, 1, svc.pendingAsyncMounts("csi-vol"))
+	require.Equal(t, 0, svc.pendingAsyncMounts("csi"))
+	// The mount being created isn't created again.
+	svc.createMountAsync(ctx, mount, func(ctx context.Context) error { return errors.New("created again") })
+
+	// The failed mount is kept until it's created again.
+	release <- errors.New("pull model failed")
+	require.Eventually(t, func() bool {
+		_, err := svc.asyncMountStatus("csi-vol", "m1")
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+	_, err = svc.asyncMountStatus("csi-vol", "m1")
+	require.EqualError(t, err, "pull model failed")
+	require.Equal(t, 0, svc.pendingAsyncMounts("csi-vol"))
+
+	created := make(chan struct{})
+	svc.createMountAsync(ctx, mount, func(ctx context.Context) error {
+		close(created)
+		return nil
+	})
+	<-created
+	require.Eventually(t, func() bool {
+		mountStatus, err := svc.asyncMountStatus("csi-vol", "m1")
+		return mountStatus == nil && err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	svc.createMountAsync(ctx, mount, func(ctx context.Context) error { return errors.New("pull model failed") })
+	require.Eventually(t, func() bool {
+		_, err := svc.asyncMountStatus("csi-vol", "m1")
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+	svc.forgetAsyncMount("csi-vol", "m1")
+	mountStatus, err = svc.asyncMountStatus("csi-vol", "m1")
+	require.NoError(t, err)
+	require.Nil(t, mountStatus)
+}
+
+func TestDynamicServerHandler_CreateVolume_Async(t *testing.T) {
+	h, _ := newHandler(t)
+	body := `{"mount_id":"m1","reference":"test/model:latest"}`
+
+	c, rec := newHandlerContextWithParam(t, http.MethodPost, "/?async=invalid", body,
+		[]string{"volume_name"}, []string{"my-volume"})
+	_ = h.CreateVolume(c)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	c, rec = newHandlerContextWithParam(t, http.MethodPost, "/?async=true", body,
+		[]string{"volume_name"}, []string{"my-volume"})
+	_ = h.CreateVolume(c)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	var mount modelStatus.Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &mount))
+	require.Equal(t, "m1", mount.MountID)
+	require.Equal(t, modelStatus.StatePullRunning, mount.State)
+
+	// The volume directory doesn't exist, so the pull fails in the background
+	// and GetMount returns its error instead of not found.
+	require.Eventually(t, func() bool {
+		c, rec := newHandlerContextWithParam(t, http.MethodGet, "/", "",
+			[]string{"volume_name", "mount_id"}, []string{"my-volume", "m1"})
+		_ = h.GetVolume(c)
+		return rec.Code >= http.StatusBadRequest && rec.Code != http.StatusNotFound
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The failed mount is not found once deleted.
+	c, rec = newHandlerContextWithParam(t, http.MethodDelete, "/", "",
+		[]string{"volume_name", "mount_id"}, []string{"my-volume", "m1"})
+	_ = h.DeleteVolume(c)
+	if rec.Code == http.StatusNoContent {
+		c, rec = newHandlerContextWithParam(t, http.MethodGet, "/", "",
+			[]string{"volume_name", "mount_id"}, []string{"my-volume", "m1"})
+		_ = h.GetVolume(c)
+		require.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
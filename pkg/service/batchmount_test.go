@@ -0,0 +1,73 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynamicServerHandler_CreateVolumes_Invalid(t *testing.T) {
+	h, _ := newHandler(t)
+	tooMany := make([]string, maxBatchMounts+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf(`{"mount_id":"m%d","reference":"test/model:latest"}`, i)
+	}
+
+	for _, body := range []string{
+		`invalid`,
+		`{"mounts":[]}`,
+		`{"mounts":[` + strings.Join(tooMany, ",") + `]}`,
+		`{"mounts":[{"mount_id":"m1","reference":"test/model:latest"},{"mount_id":" m1 ","reference":"test/model:v2"}]}`,
+	} {
+		c, rec := newHandlerContextWithParam(t, http.MethodPost, "/", body,
+			[]string{"volume_name"}, []string{"my-volume"})
+		_ = h.CreateVolumes(c)
+		require.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+
+	c, rec := newHandlerContextWithParam(t, http.MethodPost, "/", `{"mounts":[{"mount_id":"m1","reference":"test/model:latest"}]}`,
+		[]string{"volume_name"}, []string{"invalid/name"})
+	_ = h.CreateVolumes(c)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestDynamicServerHandler_CreateVolumes(t *testing.T) {
+	h, _ := newHandler(t)
+	body := `{"mounts":[
+		{"mount_id":"m1","reference":"test/model:latest"},
+		{"mount_id":"bad/mount","reference":"test/model:latest"},
+		{"mount_id":"m2","reference":""},
+		{"mount_id":"m3","reference":"test/model:latest"}
+	]}`
+
+	c, rec := newHandlerContextWithParam(t, http.MethodPost, "/?async=true", body,
+		[]string{"volume_name"}, []string{"my-volume"})
+	_ = h.CreateVolumes(c)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp BatchMountResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Results, 4)
+
+	// The results are in the order of the mounts, each is the same as the
+	// mount created alone.
+	for _, i := range []int{0, 3} {
+		result := resp.Results[i]
+		require.Equal(t, http.StatusAccepted, result.StatusCode)
+		require.Nil(t, result.Error)
+		require.Equal(t, result.MountID, result.Mount.MountID)
+		require.Equal(t, modelStatus.StatePullRunning, result.Mount.State)
+	}
+	for _, i := range []int{1, 2} {
+		result := resp.Results[i]
+		require.Equal(t, http.StatusBadRequest, result.StatusCode)
+		require.Nil(t, result.Mount)
+		require.Equal(t, ERR_CODE_INVALID_ARGUMENT, result.Error.Code)
+	}
+	require.Equal(t, "bad/mount", resp.Results[1].MountID)
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+var diskStatsPath = "/proc/diskstats"
+
+type BenchOptions struct {
+	Reference           string
+	Concurrencies       []uint
+	ExcludeModelWeights bool
+	ExcludeFilePatterns []string
+}
+
+type BenchResult struct {
+	Concurrency uint
+	Duration    time.Duration
+	// Size of the pulled model layers.
+	PulledBytes int64
+	// Bytes per second of the pulled model layers.
+	Throughput float64
+	// CPU cores used by the driver process during the pull, e.g. 1.5 means
+	// one and a half cores on average.
+	CPUUsage float64
+	// Utilization (0-1) and bytes written of the disk holding the root dir,
+	// they are -1 if the disk stats are unavailable (e.g. on overlay fs).
+	DiskUtilization  float64
+	DiskWrittenBytes int64
+}
+
+type diskStats struct {
+	ioTicks        time.Duration
+	sectorsWritten int64
+}
+
+// Bench pulls the model into a temporary dir under the root dir with the real
+// puller once per concurrency, so that operators can tune pull concurrency and
+// hardware before production rollout, the pulled model is removed afterwards.
+func Bench(ctx context.Context, cfg *config.Config, opts BenchOptions) ([]BenchResult, error) {
+	if opts.Reference == "" {
+		return nil, errors.New("reference is required")
+	}
+	concurrencies := opts.Concurrencies
+	if len(concurrencies) == 0 {
+		concurrencies = []uint{cfg.Get().PullConfig.Concurrency}
+	}
+
+	// The unique dir under the root dir keeps the concurrent benches apart,
+	// and never removes a user's dir named "bench" on cleanup.
+	if err := os.MkdirAll(cfg.Get().RootDir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "create root dir: %s", cfg.Get().RootDir)
+	}
+	benchDir, err := os.MkdirTemp(cfg.Get().RootDir, "bench-")
+	if err != nil {
+		return nil, errors.Wrapf(err, "create bench dir under: %s", cfg.Get().RootDir)
+	}
+	defer func() { _ = os.RemoveAll(benchDir) }()
+
+	results := []BenchResult{}
+	for idx, concurrency := range concurrencies {
+		if concurrency == 0 {
+			return nil, errors.New("concurrency must be greater than 0")
+		}
+		targetDir := filepath.Join(benchDir, strconv.Itoa(idx))
+		result, err := benchPull(ctx, cfg, opts, concurrency, benchDir, targetDir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "bench with concurrency %d", concurrency)
+		}
+		results = append(results, *result)
+		if err := os.RemoveAll(targetDir); err != nil {
+			return nil, errors.Wrapf(err, "remove bench model dir: %s", targetDir)
+		}
+	}
+
+	return results, nil
+}
+
+func benchPull(ctx context.Context, cfg *config.Config, opts BenchOptions, concurrency uint, benchDir, targetDir string) (*BenchResult, error) {
+	pullCfg := cfg.Get().PullConfig
+	pullCfg.Concurrency = concurrency
+	hook := status.NewHook(ctx)
+	puller := NewPuller(ctx, &pullCfg, hook, nil)
+
+	diskBefore, diskErr := getDiskStats(benchDir)
+	if diskErr != nil {
+		logger.WithContext(ctx).WithError(diskErr).Warnf("disk stats are unavailable")
+	}
+	cpuBefore, err := getCPUTime()
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+
+	logger.WithContext(ctx).Infof("pulling model %s with concurrency %d", opts.Reference, concurrency)
+	if err := puller.Pull(ctx, opts.Reference, targetDir, opts.ExcludeModelWeights, opts.ExcludeFilePatterns); err != nil {
+		return nil, errors.Wrap(err, "pull model")
+	}
+
+	duration := time.Since(start)
+	cpuAfter, err := getCPUTime()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BenchResult{
+		Concurrency:      concurrency,
+		Duration:         duration,
+		PulledBytes:      hook.GetPulledSize(),
+		CPUUsage:         float64(cpuAfter-cpuBefore) / float64(duration),
+		DiskUtilization:  -1,
+		DiskWrittenBytes: -1,
+	}
+	result.Throughput = float64(result.PulledBytes) / duration.Seconds()
+
+	if diskErr == nil {
+		diskAfter, err := getDiskStats(benchDir)
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("disk stats are unavailable")
+		} else {
+			result.DiskUtilization = float64(diskAfter.ioTicks-diskBefore.ioTicks) / float64(duration)
+			result.DiskWrittenBytes = (diskAfter.sectorsWritten - diskBefore.sectorsWritten) * 512
+		}
+	}
+
+	return result, nil
+}
+
+// getCPUTime returns the user and system CPU time consumed by the process.
+func getCPUTime() (time.Duration, error) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, errors.Wrap(err, "get rusage")
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano()), nil
+}
+
+// getDiskStats returns the stats of the block device holding the path from
+// /proc/diskstats, see https://www.kernel.org/doc/Documentation/iostats.txt.
+func getDiskStats(path string) (*diskStats, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		return nil, errors.Wrapf(err, "stat path: %s", path)
+	}
+	major, minor := unix.Major(stat.Dev), unix.Minor(stat.Dev)
+
+	file, err := os.Open(diskStatsPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open %s", diskStatsPath)
+	}
+	defer func() { _ = file.Close() }()
+
+	device := fmt.Sprintf("%d %d", major, minor)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 13 || fields[0]+" "+fields[1] != device {
+			continue
+		}
+		sectorsWritten, err := strconv.ParseInt(fields[9], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse sectors written of device %s", fields[2])
+		}
+		ioTicks, err := strconv.ParseInt(fields[12], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse io ticks of device %s", fields[2])
+		}
+		return &diskStats{
+			ioTicks:        time.Duration(ioTicks) * time.Millisecond,
+			sectorsWritten: sectorsWritten,
+		}, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "read %s", diskStatsPath)
+	}
+
+	return nil, errors.Errorf("device %s of path %s is not found in %s", device, path, diskStatsPath)
+}
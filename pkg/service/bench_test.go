@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+type benchPuller struct {
+	pullCfg *config.PullConfig
+	hook    *status.Hook
+}
+
+func (p *benchPuller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	desc := ocispec.Descriptor{Digest: "sha256:weights", Size: 1024}
+	p.hook.BeforePullLayer(desc, ocispec.Manifest{})
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "model.safetensors"), make([]byte, desc.Size), 0644); err != nil {
+		return err
+	}
+	time.Sleep(10 * time.Millisecond)
+	p.hook.AfterPullLayer(desc, nil)
+	return nil
+}
+
+func TestBench(t *testing.T) {
+	svc, rootDir := newNodeService(t)
+	svc.cfg.Get().PullConfig.Concurrency = 5
+
+	concurrencies := []uint{}
+	origNewPuller := NewPuller
+	NewPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		concurrencies = append(concurrencies, pullCfg.Concurrency)
+		return &benchPuller{pullCfg: pullCfg, hook: hook}
+	}
+	defer func() { NewPuller = origNewPuller }()
+
+	results, err := Bench(context.Background(), svc.cfg, BenchOptions{
+		Reference:     "registry/model:v1",
+		Concurrencies: []uint{1, 4},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []uint{1, 4}, concurrencies)
+	require.Len(t, results, 2)
+	for _, result := range results {
+		require.Equal(t, int64(1024), result.PulledBytes)
+		require.Greater(t, result.Duration, time.Duration(0))
+		require.Greater(t, result.Throughput, float64(0))
+	}
+	// The configured concurrency is not changed.
+	require.Equal(t, uint(5), svc.cfg.Get().PullConfig.Concurrency)
+	benchDirs, err := filepath.Glob(filepath.Join(rootDir, "bench-*"))
+	require.NoError(t, err)
+	require.Empty(t, benchDirs)
+
+	// Defaults to the configured concurrency.
+	concurrencies = []uint{}
+	results, err = Bench(context.Background(), svc.cfg, BenchOptions{Reference: "registry/model:v1"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, []uint{5}, concurrencies)
+
+	_, err = Bench(context.Background(), svc.cfg, BenchOptions{})
+	require.Error(t, err)
+}
+
+func TestGetDiskStats(t *testing.T) {
+	dir := t.TempDir()
+	var stat unix.Stat_t
+	require.NoError(t, unix.Stat(dir, &stat))
+
+	statsPath := filepath.Join(t.TempDir(), "diskstats")
+	origDiskStatsPath := diskStatsPath
+	diskStatsPath = statsPath
+	defer func() { diskStatsPath = origDiskStatsPath }()
+
+	require.NoError(t, os.WriteFile(statsPath, []byte(fmt.Sprintf(
+		"   7       0 loop0 1 0 2 0 0 0 0 0 0 0 0\n %4d %7d sda1 100 0 2000 30 200 0 4096 50 0 1500 80\n",
+		unix.Major(stat.Dev), unix.Minor(stat.Dev),
+	)), 0644))
+	stats, err := getDiskStats(dir)
+	require.NoError(t, err)
+	require.Equal(t, int64(4096), stats.sectorsWritten)
+	require.Equal(t, 1500*time.Millisecond, stats.ioTicks)
+
+	require.NoError(t, os.WriteFile(statsPath, []byte("   7       0 loop0 1 0 2 0 0 0 0 0 0 0 0\n"), 0644))
+	_, err = getDiskStats(dir)
+	require.ErrorContains(t, err, "is not found")
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/metrics"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// The interval of the periodic GC of the blob store if it's not configured.
+var BlobGCInterval = 10 * time.Minute
+
+// BlobStore is the node-level content store of the pulled model files keyed
+// by layer digest, the files in the model dirs of the volumes are hardlinks
+// to the blobs, so that an identical model is stored only once on the node.
+//
+// The model dirs are always mounted read-only while the store is enabled (see
+// isReadOnlyMount), as a file modified in place by the workload would be
+// modified for all the volumes sharing the blob.
+type BlobStore struct {
+	cfg *config.Config
+	// Serializes GC against Link and Ingest, which expect the blob to exist
+	// between the stat and the link.
+	mutex sync.RWMutex
+}
+
+// isReadOnlyMount returns true if the model dir must be mounted read-only,
+// either requested by the volume or required by the shared blob store.
+func isReadOnlyMount(cfg *config.RawConfig, readOnly bool) bool {
+	return readOnly || cfg.Features.SharedBlobStore
+}
+
+func NewBlobStore(cfg *config.Config) *BlobStore {
+	return &BlobStore{
+		cfg: cfg,
+	}
+}
+
+func (bs *BlobStore) blobPath(dgst string) (string, error) {
+	d := digest.Digest(dgst)
+	if err := d.Validate(); err != nil {
+		return "", errors.Wrapf(err, "invalid digest: %s", dgst)
+	}
+	return bs.cfg.Get().GetBlobPath(d), nil
+}
+
+// Link hardlinks the blob of the digest to the target path, it returns false
+// if the blob doesn't exist in the store.
+func (bs *BlobStore) Link(dgst, targetPath string) (bool, error) {
+	blobPath, err := bs.blobPath(dgst)
+	if err != nil {
+		return false, err
+	}
+
+	bs.mutex.RLock()
+	defer bs.mutex.RUnlock()
+
+	if _, err := os.Stat(blobPath); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "stat blob: %s", blobPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return false, errors.Wrapf(err, "create dir: %s", filepath.Dir(targetPath))
+	}
+	if err := os.Link(blobPath, targetPath); err != nil {
+		if os.IsNotExist(err) {
+			// The blob is removed concurrently.
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "link blob %s to %s", blobPath, targetPath)
+	}
+
+	return true, nil
+}
+
+// Ingest adds the pulled file as the blob of the digest, if the blob already
+// exists, the file is replaced by a hardlink to the blob to release its space.
+// Only the regular files are ingested.
+func (bs *BlobStore) Ingest(ctx context.Context, dgst, filePath string) error {
+	blobPath, err := bs.blobPath(dgst)
+	if err != nil {
+		return err
+	}
+
+	fileInfo, err := os.Lstat(filePath)
+	if err != nil {
+		return errors.Wrapf(err, "stat file: %s", filePath)
+	}
+	if !fileInfo.Mode().IsRegular() {
+		return nil
+	}
+
+	bs.mutex.RLock()
+	defer bs.mutex.RUnlock()
+
+	if err := os.MkdirAll(filepath.Dir(blobPath), 0755); err != nil {
+		return errors.Wrapf(err, "create blob dir: %s", filepath.Dir(blobPath))
+	}
+
+	err = os.Link(filePath, blobPath)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, syscall.EXDEV) {
+		logger.WithContext(ctx).Warnf("skip ingesting %s, blob store is on a different device", filePath)
+		return nil
+	}
+	if !os.IsExist(err) {
+		return errors.Wrapf(err, "link %s to blob %s", filePath, blobPath)
+	}
+
+	blobInfo, err := os.Lstat(blobPath)
+	if err != nil {
+		return errors.Wrapf(err, "stat blob: %s", blobPath)
+	}
+	if os.SameFile(fileInfo, blobInfo) || !blobInfo.Mode().IsRegular() {
+		return nil
+	}
+
+	tmpPath := filePath + ".blob"
+	_ = os.Remove(tmpPath)
+	if err := os.Link(blobPath, tmpPath); err != nil {
+		return errors.Wrapf(err, "link blob %s to %s", blobPath, tmpPath)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		_ = os.Remove(tmpPath)
+		return errors.Wrapf(err, "replace %s with blob", filePath)
+	}
+
+	return nil
+}
+
+// GCBlob is the blob removed, or to be removed, by the GC.
+type GCBlob struct {
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
+
+// GC removes the blobs not linked by any model dir, i.e. the blobs whose link
+// count drops to 1 after the model dirs are deleted. The link count of a blob
+// is the reference count of the volumes sharing it plus the blob itself.
+func (bs *BlobStore) GC(ctx context.Context) error {
+	_, err := bs.gc(ctx, false)
+	return err
+}
+
+// gc returns the unused blobs, which are only removed unless dryRun is set.
+func (bs *BlobStore) gc(ctx context.Context, dryRun bool) ([]GCBlob, error) {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+
+	blobsDir := bs.cfg.Get().GetBlobsDir()
+	blobs := []GCBlob{}
+	reclaimed := int64(0)
+	size := int64(0)
+	err := filepath.WalkDir(blobsDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == blobsDir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return errors.Wrapf(err, "stat blob: %s", path)
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); !ok || stat.Nlink > 1 {
+			size += info.Size()
+			return nil
+		}
+		blobs = append(blobs, GCBlob{
+			Digest: filepath.Base(filepath.Dir(path)) + ":" + filepath.Base(path),
+			Size:   info.Size(),
+		})
+		if dryRun {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "remove blob: %s", path)
+		}
+		reclaimed += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "walk blobs dir: %s", blobsDir)
+	}
+	if dryRun {
+		return blobs, nil
+	}
+	metrics.NodeBlobStoreSizeInBytes.Set(float64(size))
+	metrics.NodeBlobStoreReclaimedInBytes.Add(float64(reclaimed))
+	if len(blobs) > 0 {
+		logger.WithContext(ctx).Infof("removed %d unused blobs, reclaimed: %s", len(blobs), humanizeBytes(reclaimed))
+	}
+
+	return blobs, nil
+}
+
+// gcBlobsLoop collects the blobs released by the model dirs removed without
+// the GC, e.g. the driver restarted in the middle of deleting a volume. The
+// interval is picked up on the config reload.
+func (s *Service) gcBlobsLoop() {
+	for {
+		interval := time.Duration(s.cfg.Get().Features.BlobGCIntervalInSeconds) * time.Second
+		if interval == 0 {
+			interval = BlobGCInterval
+		}
+		time.Sleep(interval)
+		ctx := logger.NewContext(context.Background(), "GCBlobs", "", "")
+		if err := s.worker.blobStore.GC(ctx); err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("failed to collect unused blobs")
+		}
+	}
+}
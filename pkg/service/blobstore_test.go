@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const testBlobDigest = "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+
+func newTestBlobStore(t *testing.T) (*BlobStore, string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	cfg := config.NewWithRaw(&config.RawConfig{ServiceName: "test", RootDir: tmpDir})
+	return NewBlobStore(cfg), tmpDir
+}
+
+func TestBlobStore_LinkMissing(t *testing.T) {
+	store, tmpDir := newTestBlobStore(t)
+
+	linked, err := store.Link(testBlobDigest, filepath.Join(tmpDir, "model", "a.bin"))
+	require.NoError(t, err)
+	require.False(t, linked)
+}
+
+func TestBlobStore_InvalidDigest(t *testing.T) {
+	store, tmpDir := newTestBlobStore(t)
+
+	_, err := store.Link("sha256:invalid", filepath.Join(tmpDir, "model", "a.bin"))
+	require.Error(t, err)
+}
+
+func TestBlobStore_IngestAndLink(t *testing.T) {
+	store, tmpDir := newTestBlobStore(t)
+
+	first := filepath.Join(tmpDir, "volumes", "pvc-1", "model", "a.bin")
+	require.NoError(t, os.MkdirAll(filepath.Dir(first), 0755))
+	require.NoError(t, os.WriteFile(first, []byte("foo"), 0644))
+	require.NoError(t, store.Ingest(context.Background(), testBlobDigest, first))
+	// Ingest is idempotent for the same file.
+	require.NoError(t, store.Ingest(context.Background(), testBlobDigest, first))
+
+	second := filepath.Join(tmpDir, "volumes", "pvc-2", "model", "sub", "a.bin")
+	linked, err := store.Link(testBlobDigest, second)
+	require.NoError(t, err)
+	require.True(t, linked)
+
+	firstInfo, err := os.Stat(first)
+	require.NoError(t, err)
+	secondInfo, err := os.Stat(second)
+	require.NoError(t, err)
+	require.True(t, os.SameFile(firstInfo, secondInfo))
+}
+
+func TestBlobStore_IngestReplacesDuplicate(t *testing.T) {
+	store, tmpDir := newTestBlobStore(t)
+
+	first := filepath.Join(tmpDir, "first.bin")
+	second := filepath.Join(tmpDir, "second.bin")
+	require.NoError(t, os.WriteFile(first, []byte("foo"), 0644))
+	require.NoError(t, os.WriteFile(second, []byte("foo"), 0644))
+
+	require.NoError(t, store.Ingest(context.Background(), testBlobDigest, first))
+	require.NoError(t, store.Ingest(context.Background(), testBlobDigest, second))
+
+	firstInfo, err := os.Stat(first)
+	require.NoError(t, err)
+	secondInfo, err := os.Stat(second)
+	require.NoError(t, err)
+	require.True(t, os.SameFile(firstInfo, secondInfo))
+	require.NoFileExists(t, second+".blob")
+}
+
+func TestBlobStore_IngestSkipsSymlink(t *testing.T) {
+	store, tmpDir := newTestBlobStore(t)
+
+	target := filepath.Join(tmpDir, "target.bin")
+	link := filepath.Join(tmpDir, "link.bin")
+	require.NoError(t, os.WriteFile(target, []byte("foo"), 0644))
+	require.NoError(t, os.Symlink(target, link))
+
+	require.NoError(t, store.Ingest(context.Background(), testBlobDigest, link))
+	linked, err := store.Link(testBlobDigest, filepath.Join(tmpDir, "model", "a.bin"))
+	require.NoError(t, err)
+	require.False(t, linked)
+}
+
+func TestBlobStore_GC(t *testing.T) {
+	store, tmpDir := newTestBlobStore(t)
+	// The blobs dir doesn't exist yet.
+	require.NoError(t, store.GC(context.Background()))
+
+	filePath := filepath.Join(tmpDir, "volumes", "pvc-1", "model", "a.bin")
+	require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+	require.NoError(t, os.WriteFile(filePath, []byte("foo"), 0644))
+	require.NoError(t, store.Ingest(context.Background(), testBlobDigest, filePath))
+
+	blobPath, err := store.blobPath(testBlobDigest)
+	require.NoError(t, err)
+	// The blob is still linked by the model dir.
+	require.NoError(t, store.GC(context.Background()))
+	require.FileExists(t, blobPath)
+	require.Equal(t, float64(3), testutil.ToFloat64(metrics.NodeBlobStoreSizeInBytes))
+
+	// The blob is kept until the last model dir linking it is removed.
+	secondPath := filepath.Join(tmpDir, "volumes", "pvc-2", "model", "a.bin")
+	linked, err := store.Link(testBlobDigest, secondPath)
+	require.NoError(t, err)
+	require.True(t, linked)
+	require.NoError(t, os.RemoveAll(filepath.Join(tmpDir, "volumes", "pvc-1")))
+	require.NoError(t, store.GC(context.Background()))
+	require.FileExists(t, blobPath)
+
+	reclaimed := testutil.ToFloat64(metrics.NodeBlobStoreReclaimedInBytes)
+	require.NoError(t, os.RemoveAll(filepath.Join(tmpDir, "volumes", "pvc-2")))
+	require.NoError(t, store.GC(context.Background()))
+	require.NoFileExists(t, blobPath)
+	require.Equal(t, reclaimed+3, testutil.ToFloat64(metrics.NodeBlobStoreReclaimedInBytes))
+	require.Zero(t, testutil.ToFloat64(metrics.NodeBlobStoreSizeInBytes))
+}
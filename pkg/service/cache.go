@@ -1,8 +1,12 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/modelpack/model-csi-driver/pkg/config"
@@ -10,6 +14,7 @@ import (
 	"github.com/modelpack/model-csi-driver/pkg/metrics"
 	"github.com/modelpack/model-csi-driver/pkg/status"
 	"github.com/pkg/errors"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
 )
 
 var CacheScanInterval = 60 * time.Second
@@ -23,6 +28,13 @@ const (
 type CacheManager struct {
 	cfg *config.Config
 	sm *status.StatusManager
+	// node is used to publish the cached models to the node annotation,
+	// it's nil if the publish_cached_models feature is disabled.
+	node            v1.NodeInterface
+	publishedModels string
+	// annotationRemoved is true if the annotation is removed since the
+	// publish_cached_models feature is disabled.
+	annotationRemoved bool
 }
 
 func (cm *CacheManager) getCacheSize() (int64, error) {
@@ -30,6 +42,13 @@ func (cm *CacheManager) getCacheSize() (int64, error) {
 	if err != nil {
 		return 0, errors.Wrapf(err, "get used size: %s", cm.cfg.Get().RootDir)
 	}
+	for _, tier := range cm.cfg.Get().StorageTiers {
+		tierSize, err := getUsedSize(tier.RootDir)
+		if err != nil {
+			return 0, errors.Wrapf(err, "get used size: %s", tier.RootDir)
+		}
+		size += tierSize
+	}
 
 	return size, nil
 }
@@ -48,8 +67,14 @@ func (cm *CacheManager) scanModels() error {
 	}
 
 	mountItems := []metrics.MountItem{}
+	cachedModels := map[string]bool{}
+	collectCachedModel := func(modelStatus *status.Status) {
+		if (modelStatus.State == status.StatePullSucceeded || modelStatus.State == status.StateMounted) && modelStatus.ClonedFrom == "" {
+			cachedModels[cachedModelReference(modelStatus)] = true
+		}
+	}
 	for _, volumeDir := range volumeDirs {
-		if !volumeDir.IsDir() {
+		if !isVolumeDirEntry(volumesDir, volumeDir) {
 			continue
 		}
 		volumeName := volumeDir.Name()
@@ -64,6 +89,15 @@ func (cm *CacheManager) scanModels() error {
 					MountID:    modelStatus.MountID,
 				})
 				pvcModels += 1
+				collectCachedModel(modelStatus)
+			}
+		}
+		if isPrefetchVolume(volumeName) {
+			// The prefetched model isn't mounted, it's only counted as cached.
+			statusPath := filepath.Join(volumesDir, volumeName, "status.json")
+			modelStatus, err := cm.sm.Get(statusPath)
+			if err == nil {
+				collectCachedModel(modelStatus)
 			}
 		}
 		if isDynamicVolume(volumeName) {
@@ -83,6 +117,7 @@ func (cm *CacheManager) scanModels() error {
 							MountID:    modelStatus.MountID,
 						})
 						inlineModels += 1
+						collectCachedModel(modelStatus)
 					}
 					continue
 				}
@@ -90,7 +125,7 @@ func (cm *CacheManager) scanModels() error {
 				continue
 			}
 			for _, modelDir := range modelDirs {
-				if !modelDir.IsDir() {
+				if !isVolumeDirEntry(modelsDir, modelDir) {
 					continue
 				}
 				statusPath := filepath.Join(modelsDir, modelDir.Name(), "status.json")
@@ -103,6 +138,7 @@ func (cm *CacheManager) scanModels() error {
 						MountID:    modelStatus.MountID,
 					})
 					dynamicModels += 1
+					collectCachedModel(modelStatus)
 				}
 			}
 		}
@@ -113,6 +149,89 @@ func (cm *CacheManager) scanModels() error {
 	metrics.NodeMountedInlineModels.Set(float64(inlineModels))
 	metrics.NodeMountedDynamicModels.Set(float64(dynamicModels))
 
+	if err := cm.publishCachedModels(cachedModels); err != nil {
+		return errors.Wrap(err, "publish cached models")
+	}
+
+	return nil
+}
+
+// maxCachedModelsAnnotationSize limits the size of the cached models
+// annotation, as the total size of the annotations of a node is limited
+// to 256KiB by the API server.
+const maxCachedModelsAnnotationSize = 32 * 1024
+
+// cachedModelReference returns the normalized reference of the cached model,
+// e.g. "docker.io/foo/bar:latest" for "foo/bar", so that the references of
+// the same model in different forms are published once.
+func cachedModelReference(modelStatus *status.Status) string {
+	// The pull key is "<type>|<normalized reference>|...".
+	parts := strings.SplitN(modelStatus.PullKey, "|", 3)
+	if len(parts) == 3 && parts[0] == ModelTypeImage {
+		// The model pinned to the digest is published by the tag.
+		if modelStatus.Digest != "" {
+			return normalizeImageReference(modelStatus.Reference)
+		}
+		return parts[1]
+	}
+	return modelStatus.Reference
+}
+
+// publishCachedModels sets the sorted references of the cached models as a
+// JSON array to the node annotation, the annotation is only patched when the
+// cached models are changed since the last publish, and it's removed once the
+// feature is disabled.
+func (cm *CacheManager) publishCachedModels(cachedModels map[string]bool) error {
+	if cm.node == nil {
+		return nil
+	}
+
+	nodeName := cm.cfg.Get().NodeID
+	key := cm.cfg.Get().AnnotationKeyCachedModels()
+
+	if !cm.cfg.Get().Features.PublishCachedModels {
+		if cm.annotationRemoved {
+			return nil
+		}
+		if err := removeNodeAnnotation(context.Background(), cm.node, nodeName, key); err != nil {
+			return errors.Wrapf(err, "remove annotation %s", key)
+		}
+		cm.annotationRemoved = true
+		cm.publishedModels = ""
+		return nil
+	}
+	cm.annotationRemoved = false
+
+	references := make([]string, 0, len(cachedModels))
+	for reference := range cachedModels {
+		references = append(references, reference)
+	}
+	sort.Strings(references)
+
+	value, err := json.Marshal(references)
+	if err != nil {
+		return errors.Wrap(err, "marshal cached models")
+	}
+	for len(value) > maxCachedModelsAnnotationSize {
+		references = references[:len(references)-1]
+		if value, err = json.Marshal(references); err != nil {
+			return errors.Wrap(err, "marshal cached models")
+		}
+	}
+	if len(references) < len(cachedModels) {
+		logger.Logger().Warnf("published %d/%d cached models, exceeded the annotation size limit", len(references), len(cachedModels))
+	}
+	if string(value) == cm.publishedModels {
+		return nil
+	}
+
+	if err := patchNodeAnnotation(context.Background(), cm.node, nodeName, key, string(value)); err != nil {
+		return errors.Wrapf(err, "set annotation %s", key)
+	}
+	cm.publishedModels = string(value)
+
+	logger.Logger().Infof("published %d cached models to node %s", len(references), nodeName)
+
 	return nil
 }
 
@@ -132,10 +251,11 @@ func (cm *CacheManager) Scan() error {
 	return nil
 }
 
-func NewCacheManager(cfg *config.Config, sm *status.StatusManager) (*CacheManager, error) {
+func NewCacheManager(cfg *config.Config, sm *status.StatusManager, node v1.NodeInterface) (*CacheManager, error) {
 	cm := CacheManager{
-		cfg: cfg,
-		sm:  sm,
+		cfg:  cfg,
+		sm:   sm,
+		node: node,
 	}
 
 	go func() {
@@ -10,6 +10,8 @@ import (
 	"github.com/modelpack/model-csi-driver/pkg/config"
 	"github.com/modelpack/model-csi-driver/pkg/status"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
 )
 
 func TestNewCacheManager(t *testing.T) {
@@ -21,11 +23,12 @@ func TestNewCacheManager(t *testing.T) {
 	cfg := config.NewWithRaw(rawCfg)
 	sm, err := status.NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 
 	// Do not modify CacheScanInterval here: the background goroutine started by
 	// NewCacheManager reads it concurrently, so writing and then restoring the
 	// global would cause a data race under -race.
-	cm, err := NewCacheManager(cfg, sm)
+	cm, err := NewCacheManager(cfg, sm, nil)
 	require.NoError(t, err)
 	require.NotNil(t, cm)
 }
@@ -39,6 +42,7 @@ func TestCacheManager_getCacheSize(t *testing.T) {
 	cfg := config.NewWithRaw(rawCfg)
 	sm, err := status.NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 
 	cm := &CacheManager{cfg: cfg, sm: sm}
 	size, err := cm.getCacheSize()
@@ -55,6 +59,7 @@ func TestCacheManager_Scan_EmptyDir(t *testing.T) {
 	cfg := config.NewWithRaw(rawCfg)
 	sm, err := status.NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 
 	cm := &CacheManager{cfg: cfg, sm: sm}
 	err = cm.Scan()
@@ -78,6 +83,74 @@ func TestLocalListVolumes_NoVolumesDir(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestLocalListVolumes_SizeInBytes(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	volumeDir := svc.cfg.Get().GetVolumeDir("pvc-size")
+	require.NoError(t, os.MkdirAll(volumeDir, 0750))
+	_, err := svc.sm.Set(filepath.Join(volumeDir, "status.json"), status.Status{
+		VolumeName:  "pvc-size",
+		Reference:   "test/model:latest",
+		State:       status.StatePullSucceeded,
+		SizeInBytes: 1 << 20,
+	})
+	require.NoError(t, err)
+
+	resp, err := svc.localListVolumes(ctx, &csi.ListVolumesRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Entries, 1)
+	require.Equal(t, int64(1<<20), resp.Entries[0].Volume.CapacityBytes)
+}
+
+func TestLocalListVolumes_DynamicMountsAndPagination(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	cfg := svc.cfg.Get()
+	for _, volumeDir := range []string{
+		cfg.GetVolumeDir("pvc-b"),
+		cfg.GetVolumeDir("pvc-a"),
+		cfg.GetMountIDDirForDynamic("csi-dyn", "mount-2"),
+		cfg.GetMountIDDirForDynamic("csi-dyn", "mount-1"),
+		cfg.GetVolumeDir(prefetchVolumePrefix + "model"),
+	} {
+		require.NoError(t, os.MkdirAll(volumeDir, 0750))
+		_, err := svc.sm.Set(filepath.Join(volumeDir, "status.json"), status.Status{
+			Reference: "test/model:latest",
+			State:     status.StatePullSucceeded,
+		})
+		require.NoError(t, err)
+	}
+
+	listVolumeIDs := func(req *csi.ListVolumesRequest) ([]string, string) {
+		t.Helper()
+		resp, err := svc.localListVolumes(ctx, req)
+		require.NoError(t, err)
+		volumeIDs := []string{}
+		for _, entry := range resp.Entries {
+			volumeIDs = append(volumeIDs, entry.Volume.VolumeId)
+		}
+		return volumeIDs, resp.NextToken
+	}
+
+	volumeIDs, nextToken := listVolumeIDs(&csi.ListVolumesRequest{})
+	require.Equal(t, []string{"csi-dyn/mount-1", "csi-dyn/mount-2", "pvc-a", "pvc-b"}, volumeIDs)
+	require.Empty(t, nextToken)
+
+	volumeIDs, nextToken = listVolumeIDs(&csi.ListVolumesRequest{MaxEntries: 3})
+	require.Equal(t, []string{"csi-dyn/mount-1", "csi-dyn/mount-2", "pvc-a"}, volumeIDs)
+	require.Equal(t, "3", nextToken)
+	volumeIDs, nextToken = listVolumeIDs(&csi.ListVolumesRequest{MaxEntries: 3, StartingToken: nextToken})
+	require.Equal(t, []string{"pvc-b"}, volumeIDs)
+	require.Empty(t, nextToken)
+
+	for _, token := range []string{"5", "-1", "pvc-a"} {
+		_, err := svc.localListVolumes(ctx, &csi.ListVolumesRequest{StartingToken: token})
+		require.Equal(t, codes.Aborted, grpcStatus.Code(err))
+	}
+	_, err := svc.localListVolumes(ctx, &csi.ListVolumesRequest{MaxEntries: -1})
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+}
+
 // --- localCreateVolume dynamic path ---
 
 func TestLocalCreateVolume_DynamicPath_VolumeDirNotExist(t *testing.T) {
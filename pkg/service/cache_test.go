@@ -1,8 +1,12 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/modelpack/model-csi-driver/pkg/config"
@@ -12,6 +16,9 @@ import (
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestCacheManagerScanUpdatesMetrics(t *testing.T) {
@@ -22,6 +29,7 @@ func TestCacheManagerScanUpdatesMetrics(t *testing.T) {
 
 	sm, err := status.NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 
 	// Create a pvc volume status
 	pvcStatusPath := filepath.Join(tempDir, "volumes", "pvc-static", "status.json")
@@ -84,6 +92,102 @@ func TestCacheManagerScanUpdatesMetrics(t *testing.T) {
 	require.True(t, foundDynamic, "dynamic mount item metric not found")
 }
 
+func TestCacheManagerPublishCachedModels(t *testing.T) {
+	tempDir := t.TempDir()
+
+	rawCfg := &config.RawConfig{
+		ServiceName: "test",
+		RootDir:     tempDir,
+		NodeID:      "node-1",
+		Features:    config.Features{PublishCachedModels: true},
+	}
+	cfg := config.NewWithRaw(rawCfg)
+
+	sm, err := status.NewStatusManager()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
+
+	_, err = sm.Set(filepath.Join(tempDir, "volumes", "pvc-a", "status.json"), status.Status{Reference: "ref-b", State: status.StateMounted})
+	require.NoError(t, err)
+	_, err = sm.Set(filepath.Join(tempDir, "volumes", "csi-dyn", "models", "mount-1", "status.json"), status.Status{Reference: "ref-a", MountID: "mount-1", State: status.StatePullSucceeded})
+	require.NoError(t, err)
+	_, err = sm.Set(filepath.Join(tempDir, "volumes", "csi-dyn", "models", "mount-2", "status.json"), status.Status{Reference: "ref-b", MountID: "mount-2", State: status.StateMounted})
+	require.NoError(t, err)
+	// Models still being pulled are not published.
+	_, err = sm.Set(filepath.Join(tempDir, "volumes", "csi-dyn", "models", "mount-3", "status.json"), status.Status{Reference: "ref-c", MountID: "mount-3", State: status.StatePullRunning})
+	require.NoError(t, err)
+
+	clientset := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}})
+	node := clientset.CoreV1().Nodes()
+
+	cm := &CacheManager{cfg: cfg, sm: sm, node: node}
+	require.NoError(t, cm.Scan())
+
+	got, err := node.Get(context.Background(), "node-1", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, `["ref-a","ref-b"]`, got.Annotations[rawCfg.AnnotationKeyCachedModels()])
+
+	// The node is not patched again if the cached models are unchanged.
+	actions := len(clientset.Actions())
+	require.NoError(t, cm.Scan())
+	require.Len(t, clientset.Actions(), actions)
+
+	// The annotation is removed once the feature is disabled.
+	cfg.Get().Features.PublishCachedModels = false
+	require.NoError(t, cm.Scan())
+	got, err = node.Get(context.Background(), "node-1", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotContains(t, got.Annotations, rawCfg.AnnotationKeyCachedModels())
+	actions = len(clientset.Actions())
+	require.NoError(t, cm.Scan())
+	require.Len(t, clientset.Actions(), actions)
+}
+
+func TestCachedModelReference(t *testing.T) {
+	require.Equal(t, "docker.io/foo/bar:latest", cachedModelReference(&status.Status{
+		Reference: "foo/bar",
+		PullKey:   pullKey("foo/bar", PullOptions{}),
+	}))
+	require.Equal(t, "org/model", cachedModelReference(&status.Status{
+		Reference: "org/model",
+		PullKey:   pullKey("org/model", PullOptions{Type: ModelTypeHuggingFace}),
+	}))
+	dgst := "sha256:" + strings.Repeat("a", 64)
+	require.Equal(t, "docker.io/foo/bar:v1", cachedModelReference(&status.Status{
+		Reference: "foo/bar:v1",
+		Digest:    dgst,
+		PullKey:   pullKey(pinReference("foo/bar:v1", dgst), PullOptions{}),
+	}))
+	// The status written before the pull key is introduced.
+	require.Equal(t, "foo/bar", cachedModelReference(&status.Status{Reference: "foo/bar"}))
+}
+
+func TestCacheManagerPublishCachedModels_SizeLimit(t *testing.T) {
+	cfg := config.NewWithRaw(&config.RawConfig{
+		ServiceName: "test",
+		NodeID:      "node-1",
+		Features:    config.Features{PublishCachedModels: true},
+	})
+	clientset := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}})
+	node := clientset.CoreV1().Nodes()
+	cm := &CacheManager{cfg: cfg, node: node}
+
+	cachedModels := map[string]bool{}
+	for idx := 0; idx < 1000; idx++ {
+		cachedModels[fmt.Sprintf("registry.example.com/models/model-%04d:latest", idx)] = true
+	}
+	require.NoError(t, cm.publishCachedModels(cachedModels))
+
+	got, err := node.Get(context.Background(), "node-1", metav1.GetOptions{})
+	require.NoError(t, err)
+	value := got.Annotations[cfg.Get().AnnotationKeyCachedModels()]
+	require.LessOrEqual(t, len(value), maxCachedModelsAnnotationSize)
+	references := []string{}
+	require.NoError(t, json.Unmarshal([]byte(value), &references))
+	require.NotEmpty(t, references)
+	require.Less(t, len(references), len(cachedModels))
+}
+
 func findMetricFamily(t *testing.T, mfs []*dto.MetricFamily, name string) *dto.MetricFamily {
 	t.Helper()
 	for _, mf := range mfs {
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/status"
+)
+
+// The prefix of the callback on the unix socket in the dynamic volume, e.g.
+// "unix:csi/callback.sock" for the socket created by the pod in the csi dir
+// of the volume.
+const callbackUnixPrefix = "unix:"
+
+var (
+	// The attempts to post the callback, retried on the errors and the 5xx
+	// responses.
+	callbackAttempts      = 3
+	callbackRetryInterval = time.Second
+	callbackTimeout       = 10 * time.Second
+	// The interval to check the mount until its pull is done.
+	callbackPollInterval = time.Second
+)
+
+// checkCallbackIP rejects the callbacks to the node itself, e.g. the kubelet
+// on the loopback or the cloud metadata on the link-local address, since the
+// driver is on the host network.
+var checkCallbackIP = func(ip net.IP) error {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return errors.Errorf("callback to %s is not allowed", ip)
+	}
+	return nil
+}
+
+// validateCallback checks the callback is an http(s) URL, or a unix socket
+// in the dynamic volume by the path relative to the volume.
+func validateCallback(callback string) error {
+	if callback == "" {
+		return nil
+	}
+	if strings.HasPrefix(callback, callbackUnixPrefix) {
+		path := strings.TrimPrefix(callback, callbackUnixPrefix)
+		if path == "" || filepath.IsAbs(path) || !filepath.IsLocal(path) {
+			return errors.Errorf("callback_url %s must be the path relative to the volume", callback)
+		}
+		return nil
+	}
+	callbackURL, err := url.Parse(callback)
+	if err != nil {
+		return errors.Wrapf(err, "parse callback_url %s", callback)
+	}
+	if (callbackURL.Scheme != "http" && callbackURL.Scheme != "https") || callbackURL.Host == "" {
+		return errors.Errorf("callback_url %s must be an http(s) URL or unix:<path>", callback)
+	}
+	return nil
+}
+
+// newCallbackClient returns the HTTP client and the URL to post the callback
+// of the dynamic volume.
+func (s *Service) newCallbackClient(volumeName, callback string) (*http.Client, string) {
+	dialer := &net.Dialer{
+		Timeout: callbackTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if network == "unix" {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return errors.Wrapf(err, "split callback address %s", address)
+			}
+			return checkCallbackIP(net.ParseIP(host))
+		},
+	}
+	transport := &http.Transport{DialContext: dialer.DialContext}
+	callbackURL := callback
+	if strings.HasPrefix(callback, callbackUnixPrefix) {
+		sockPath := filepath.Join(s.cfg.Get().GetVolumeDirForDynamic(volumeName), strings.TrimPrefix(callback, callbackUnixPrefix))
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", sockPath)
+		}
+		callbackURL = "http://unix/"
+	}
+	return &http.Client{Transport: transport, Timeout: callbackTimeout}, callbackURL
+}
+
+// postCallback posts the mount to the callback, the callback failed after
+// the retries is logged.
+func (s *Service) postCallback(ctx context.Context, callback string, mount *modelStatus.Status) {
+	payload, err := json.Marshal(mount)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Errorf("marshal mount for callback")
+		return
+	}
+	client, callbackURL := s.newCallbackClient(mount.VolumeName, callback)
+	defer client.CloseIdleConnections()
+
+	post := func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(payload))
+		if err != nil {
+			return errors.Wrap(err, "new callback request")
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if requestID := logger.RequestID(ctx); requestID != "" {
+			req.Header.Set("X-Request-ID", requestID)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return errors.Wrap(err, "post callback")
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("callback responded %d", resp.StatusCode)
+		}
+		return nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := post()
+		if err == nil {
+			logger.WithContext(ctx).Infof("posted callback of mount in %s state", mount.State)
+			return
+		}
+		if attempt >= callbackAttempts {
+			logger.WithContext(ctx).WithError(err).Errorf("failed to post callback after %d attempts", attempt)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(callbackRetryInterval):
+		}
+	}
+}
+
+// notifyMount posts the mount to the callback once its pull is done, e.g.
+// the weights pulled in the background. The mount failed to be created is
+// posted with its error, and the mount deleted before its pull is done isn't
+// posted.
+func (s *Service) notifyMount(ctx context.Context, callback string, mount modelStatus.Status, createErr error) {
+	ctx = logger.NewContext(ctx, "NotifyMount", mount.VolumeName, mount.MountID)
+	if createErr != nil {
+		mount.State = failedMountState(createErr)
+		mount.Error = &modelStatus.ErrorDetail{
+			Reason:  errorInfo(createErr).GetReason(),
+			Message: status.Convert(createErr).Message(),
+		}
+		s.postCallback(ctx, callback, &mount)
+		return
+	}
+
+	ticker := time.NewTicker(callbackPollInterval)
+	defer ticker.Stop()
+	for {
+		current, err := s.GetDynamicVolume(ctx, mount.VolumeName, mount.MountID)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				logger.WithContext(ctx).Infof("skip callback of mount deleted")
+				return
+			}
+			logger.WithContext(ctx).WithError(err).Warnf("get mount for callback")
+		} else if isPullDone(current.State) {
+			s.postCallback(ctx, callback, current)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+func TestValidateCallback(t *testing.T) {
+	for _, callback := range []string{"", "http://10.0.0.1:8080/hook", "https://example.com/hook", "unix:csi/callback.sock"} {
+		require.NoError(t, validateCallback(callback), callback)
+	}
+	for _, callback := range []string{"hook", "ftp://example.com/hook", "http://", "unix:", "unix:/run/callback.sock", "unix:../callback.sock"} {
+		require.Error(t, validateCallback(callback), callback)
+	}
+
+	require.Error(t, checkCallbackIP(net.ParseIP("127.0.0.1")))
+	require.Error(t, checkCallbackIP(net.ParseIP("169.254.169.254")))
+	require.Error(t, checkCallbackIP(net.ParseIP("::1")))
+	require.NoError(t, checkCallbackIP(net.ParseIP("10.0.0.1")))
+}
+
+func withCallbackSeams(t *testing.T) {
+	origCheck, origPoll, origRetry := checkCallbackIP, callbackPollInterval, callbackRetryInterval
+	checkCallbackIP = func(net.IP) error { return nil }
+	callbackPollInterval, callbackRetryInterval = 10*time.Millisecond, 10*time.Millisecond
+	t.Cleanup(func() {
+		checkCallbackIP, callbackPollInterval, callbackRetryInterval = origCheck, origPoll, origRetry
+	})
+}
+
+func newCallbackHandler(t *testing.T, failures int32) (http.Handler, chan modelStatus.Status) {
+	mounts := make(chan modelStatus.Status, 1)
+	var attempts atomic.Int32
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		if attempts.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var mount modelStatus.Status
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&mount))
+		mounts <- mount
+		w.WriteHeader(http.StatusNoContent)
+	}), mounts
+}
+
+func TestNotifyMount(t *testing.T) {
+	withCallbackSeams(t)
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+
+	// The callback failed is retried.
+	handler, mounts := newCallbackHandler(t, 1)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	newDynamicMount(t, svc, "csi-vol", "m1")
+	statusPath := filepath.Join(svc.cfg.Get().GetMountIDDirForDynamic("csi-vol", "m1"), "status.json")
+	mount, err := svc.sm.Get(statusPath)
+	require.NoError(t, err)
+	mount.State = modelStatus.StateWeightsPulling
+	_, err = svc.sm.Set(statusPath, *mount)
+	require.NoError(t, err)
+
+	go svc.notifyMount(ctx, server.URL, modelStatus.Status{VolumeName: "csi-vol", MountID: "m1"}, nil)
+	// The mount isn't posted until its pull is done.
+	select {
+	case <-mounts:
+		t.Fatal("posted the mount being pulled")
+	case <-time.After(50 * time.Millisecond):
+	}
+	mount.State = modelStatus.StatePullSucceeded
+	_, err = svc.sm.Set(statusPath, *mount)
+	require.NoError(t, err)
+	posted := <-mounts
+	require.Equal(t, "m1", posted.MountID)
+	require.Equal(t, modelStatus.StatePullSucceeded, posted.State)
+
+	// The mount failed to be created is posted with its error.
+	svc.notifyMount(ctx, server.URL, modelStatus.Status{VolumeName: "csi-vol", MountID: "m2"},
+		grpcStatus.Error(codes.Internal, "pull model failed"))
+	posted = <-mounts
+	require.Equal(t, modelStatus.StatePullFailed, posted.State)
+	require.Equal(t, "pull model failed", posted.Error.Message)
+
+	// The mount deleted isn't posted.
+	svc.notifyMount(ctx, server.URL, modelStatus.Status{VolumeName: "csi-vol", MountID: "m3"}, nil)
+	require.Empty(t, mounts)
+}
+
+func TestNotifyMount_Unix(t *testing.T) {
+	withCallbackSeams(t)
+	svc, _ := newNodeService(t)
+	handler, mounts := newCallbackHandler(t, 0)
+	sockPath := filepath.Join(svc.cfg.Get().GetCSISockDirForDynamic("csi-vol"), "callback.sock")
+	require.NoError(t, os.MkdirAll(filepath.Dir(sockPath), 0755))
+	listener, err := net.Listen("unix", sockPath)
+	require.NoError(t, err)
+	server := &http.Server{Handler: handler}
+	go func() { _ = server.Serve(listener) }()
+	defer func() { _ = server.Close() }()
+
+	newDynamicMount(t, svc, "csi-vol", "m1")
+	svc.notifyMount(context.Background(), "unix:csi/callback.sock", modelStatus.Status{VolumeName: "csi-vol", MountID: "m1"}, nil)
+	posted := <-mounts
+	require.Equal(t, modelStatus.StatePullSucceeded, posted.State)
+}
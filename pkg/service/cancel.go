@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// cancelPull cancels the pull in progress of the volume, the model pulled
+// partially is kept instead of being deleted. It returns false if the volume
+// isn't being pulled.
+func (worker *Worker) cancelPull(volumeName, mountID string) bool {
+	contextKey := fmt.Sprintf("%s/%s", volumeName, mountID)
+	cancelFunc := worker.contextMap.Get(contextKey)
+	if cancelFunc == nil {
+		return false
+	}
+	worker.canceledPulls.Store(contextKey, struct{}{})
+	(*cancelFunc)()
+	return true
+}
+
+// CancelPull cancels the pull in progress of the dynamic mount, including the
+// weights pulled in the background. Unlike deleting the mount, the mount is
+// kept in PULL_CANCELED state with the time it's canceled at, until it's
+// deleted or created again, which resumes the pull.
+func (s *Service) CancelPull(ctx context.Context, volumeName, mountID string) (*modelStatus.Status, error) {
+	ctx = logger.NewContext(ctx, "CancelPull", volumeName, mountID)
+	if !s.worker.cancelPull(volumeName, mountID) {
+		if _, err := s.getDynamicVolume(ctx, volumeName, mountID); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, status.Errorf(codes.NotFound, "volume_name %s with mount_id %s is not found", volumeName, mountID)
+			}
+			return nil, status.Error(codes.Internal, errors.Wrap(err, "get mount status").Error())
+		}
+		return nil, status.Errorf(codes.FailedPrecondition, "volume_name %s with mount_id %s is not being pulled", volumeName, mountID)
+	}
+	logger.WithContext(ctx).Infof("canceled pull by request")
+
+	// Wait for the pull to stop, the pull of the weights in the background
+	// doesn't set the state once canceled.
+	contextKey := fmt.Sprintf("%s/%s", volumeName, mountID)
+	if err := s.worker.kmutex.Lock(ctx, contextKey); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrapf(err, "lock context key: %s", contextKey).Error())
+	}
+	defer s.worker.kmutex.Unlock(contextKey)
+
+	statusPath := filepath.Join(s.cfg.Get().GetMountIDDirForDynamic(volumeName, mountID), "status.json")
+	mountStatus, err := s.sm.Get(statusPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, status.Errorf(codes.NotFound, "volume_name %s with mount_id %s is not found", volumeName, mountID)
+		}
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "get mount status").Error())
+	}
+	// The pull may be done before it's canceled.
+	if isPullDone(mountStatus.State) && mountStatus.State != modelStatus.StatePullCanceled {
+		return mountStatus, nil
+	}
+	canceledAt := time.Now()
+	mountStatus.State = modelStatus.StatePullCanceled
+	mountStatus.CanceledAt = &canceledAt
+	mountStatus, err = s.sm.Set(statusPath, *mountStatus)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return mountStatus, nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+func TestCancelPull(t *testing.T) {
+	svc, _ := newNodeService(t)
+	svc.worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return &weightsPuller{
+			filteringPuller: filteringPuller{hook: hook, files: []string{"config.json", "model.safetensors"}},
+			release:         make(chan struct{}),
+		}
+	}
+	ctx := context.Background()
+	cfg := svc.cfg.Get()
+
+	_, err := svc.CancelPull(ctx, "csi-dyn", "mount-1")
+	require.Equal(t, codes.NotFound, grpcStatus.Code(err))
+
+	// The mount is kept in PULL_CANCELED state.
+	pulled := make(chan error)
+	go func() {
+		pulled <- svc.worker.PullModel(ctx, false, "csi-dyn", "mount-1", "test/model:latest", cfg.GetModelDirForDynamic("csi-dyn", "mount-1"), PullOptions{})
+	}()
+	require.Eventually(t, func() bool {
+		mountStatus, err := svc.getDynamicVolume(ctx, "csi-dyn", "mount-1")
+		return err == nil && mountStatus.State == status.StatePullRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	mountStatus, err := svc.CancelPull(ctx, "csi-dyn", "mount-1")
+	require.NoError(t, err)
+	require.Equal(t, status.StatePullCanceled, mountStatus.State)
+	require.NotNil(t, mountStatus.CanceledAt)
+	require.ErrorIs(t, <-pulled, context.Canceled)
+	mountStatus, err = svc.getDynamicVolume(ctx, "csi-dyn", "mount-1")
+	require.NoError(t, err)
+	require.Equal(t, status.StatePullCanceled, mountStatus.State)
+	require.NotNil(t, mountStatus.CanceledAt)
+
+	_, err = svc.CancelPull(ctx, "csi-dyn", "mount-1")
+	require.Equal(t, codes.FailedPrecondition, grpcStatus.Code(err))
+
+	// The weights pulled in the background are canceled too.
+	modelDir := cfg.GetModelDirForDynamic("csi-dyn", "mount-2")
+	require.NoError(t, svc.worker.PullModel(ctx, false, "csi-dyn", "mount-2", "test/model:latest", modelDir, PullOptions{BackgroundWeights: true}))
+	mountStatus, err = svc.CancelPull(ctx, "csi-dyn", "mount-2")
+	require.NoError(t, err)
+	require.Equal(t, status.StatePullCanceled, mountStatus.State)
+	require.FileExists(t, filepath.Join(modelDir, "config.json"))
+	require.NoFileExists(t, filepath.Join(modelDir, "model.safetensors"))
+}
+
+func TestDynamicServerHandler_CancelVolume(t *testing.T) {
+	h, svc := newHandler(t)
+
+	c, rec := newHandlerContextWithParam(t, http.MethodPost, "/", "",
+		[]string{"volume_name", "mount_id"}, []string{"my-volume", "m1"})
+	_ = h.CancelVolume(c)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	mountIDDir := svc.cfg.Get().GetMountIDDirForDynamic("my-volume", "m1")
+	_, err := svc.sm.Set(filepath.Join(mountIDDir, "status.json"), status.Status{MountID: "m1", State: status.StatePullSucceeded})
+	require.NoError(t, err)
+	c, rec = newHandlerContextWithParam(t, http.MethodPost, "/", "",
+		[]string{"volume_name", "mount_id"}, []string{"my-volume", "m1"})
+	_ = h.CancelVolume(c)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, ERR_CODE_FAILED_PRECONDITION, resp.Code)
+}
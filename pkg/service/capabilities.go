@@ -0,0 +1,64 @@
+package service
+
+import (
+	"slices"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+// The capabilities are advertised only if they are implemented and enabled by
+// the feature flags, so that the sidecars (e.g. external-resizer) only engage
+// with the features actually supported by the driver.
+
+func (s *Service) pluginCapabilities() []csi.PluginCapability_Service_Type {
+	caps := []csi.PluginCapability_Service_Type{
+		csi.PluginCapability_Service_CONTROLLER_SERVICE,
+	}
+
+	// The volumes created in controller mode are accessible only from the
+	// node pulling the model, see the AccessibleTopology of CreateVolume.
+	if s.cfg.Get().IsControllerMode() {
+		caps = append(caps, csi.PluginCapability_Service_VOLUME_ACCESSIBILITY_CONSTRAINTS)
+	}
+
+	return caps
+}
+
+func (s *Service) controllerCapabilities() []csi.ControllerServiceCapability_RPC_Type {
+	caps := []csi.ControllerServiceCapability_RPC_Type{
+		csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
+		csi.ControllerServiceCapability_RPC_GET_CAPACITY,
+		csi.ControllerServiceCapability_RPC_GET_VOLUME,
+		csi.ControllerServiceCapability_RPC_VOLUME_CONDITION,
+		csi.ControllerServiceCapability_RPC_CLONE_VOLUME,
+	}
+
+	if s.cfg.Get().Features.ModifyVolume {
+		caps = append(caps, csi.ControllerServiceCapability_RPC_MODIFY_VOLUME)
+	}
+	if s.cfg.Get().Features.Snapshot {
+		caps = append(caps,
+			csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT,
+			csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS,
+		)
+	}
+
+	return caps
+}
+
+func (s *Service) nodeCapabilities() []csi.NodeServiceCapability_RPC_Type {
+	caps := []csi.NodeServiceCapability_RPC_Type{}
+
+	if s.cfg.Get().Features.StageVolume {
+		caps = append(caps, csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME)
+	}
+	if s.cfg.Get().Features.VolumeMountGroup {
+		caps = append(caps, csi.NodeServiceCapability_RPC_VOLUME_MOUNT_GROUP)
+	}
+
+	return caps
+}
+
+func (s *Service) hasControllerCapability(capability csi.ControllerServiceCapability_RPC_Type) bool {
+	return slices.Contains(s.controllerCapabilities(), capability)
+}
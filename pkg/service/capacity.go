@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/pkg/errors"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// The interval to publish the CSIStorageCapacity objects if it's not
+// configured.
+var StorageCapacityInterval = time.Minute
+
+// The labels of the CSIStorageCapacity objects, the same as the ones of the
+// external-provisioner, so that the objects published by the driver are told
+// apart from the others.
+const (
+	labelCapacityDriverName = "csi.storage.k8s.io/drivername"
+	labelCapacityManagedBy  = "csi.storage.k8s.io/managed-by"
+	capacityManagedBy       = "model-csi-driver-controller"
+)
+
+const (
+	// The timeout to get the capacity of a node.
+	nodeCapacityTimeout = 10 * time.Second
+	// The number of the nodes the capacity is got from concurrently.
+	nodeCapacityConcurrency = 16
+)
+
+// getNodeCapacity returns the disk quota left on the node for the volumes of
+// the StorageClass.
+var getNodeCapacity = func(ctx context.Context, s *Service, nodeInfo *nodeInfo, parameters map[string]string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, nodeCapacityTimeout)
+	defer cancel()
+	resp, err := s.callNodeGetCapacity(ctx, nodeInfo, &csi.GetCapacityRequest{Parameters: parameters})
+	if err != nil {
+		return 0, err
+	}
+	return resp.GetAvailableCapacity(), nil
+}
+
+// storageCapacityName returns the name of the CSIStorageCapacity object of
+// the StorageClass on the node.
+func storageCapacityName(storageClassName, hostname string) string {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(storageClassName + "/" + hostname))
+	return fmt.Sprintf("csisc-%016x", hash.Sum64())
+}
+
+func (s *Service) publishStorageCapacityLoop() {
+	for {
+		interval := time.Duration(s.cfg.Get().Features.StorageCapacity.IntervalInSeconds) * time.Second
+		if interval == 0 {
+			interval = StorageCapacityInterval
+		}
+		time.Sleep(interval)
+		ctx := logger.NewContext(context.Background(), "PublishStorageCapacity", "", "")
+		if err := s.publishStorageCapacities(ctx); err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("failed to publish storage capacities")
+		}
+	}
+}
+
+// publishStorageCapacities publishes a CSIStorageCapacity object for each
+// StorageClass of the driver on each node, with the capacity reported by the
+// node. The objects of the nodes failing to report the capacity are deleted,
+// so that no more volumes are scheduled to them.
+func (s *Service) publishStorageCapacities(ctx context.Context) error {
+	cfg := s.cfg.Get()
+	storageCapacity := cfg.Features.StorageCapacity
+	if !storageCapacity.Enabled {
+		return nil
+	}
+
+	storageClasses, err := s.storage.StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "list storage classes")
+	}
+	nodes, err := s.node.List(ctx, metav1.ListOptions{LabelSelector: storageCapacity.NodeSelector})
+	if err != nil {
+		return errors.Wrap(err, "list nodes")
+	}
+
+	desired := map[string]*storagev1.CSIStorageCapacity{}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, nodeCapacityConcurrency)
+	for idx := range nodes.Items {
+		nodeInfo, err := getNodeInfo(&nodes.Items[idx])
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Debugf("skip node: %s", nodes.Items[idx].Name)
+			continue
+		}
+		for _, storageClass := range storageClasses.Items {
+			if storageClass.Provisioner != cfg.ServiceName {
+				continue
+			}
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(storageClass storagev1.StorageClass) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				capacity, err := getNodeCapacity(ctx, s, nodeInfo, storageClass.Parameters)
+				if err != nil {
+					logger.WithContext(ctx).WithError(err).Warnf("failed to get capacity of node: %s", nodeInfo.hostname)
+					return
+				}
+				name := storageCapacityName(storageClass.Name, nodeInfo.hostname)
+				mutex.Lock()
+				defer mutex.Unlock()
+				desired[name] = &storagev1.CSIStorageCapacity{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: name,
+						Labels: map[string]string{
+							labelCapacityDriverName: cfg.ServiceName,
+							labelCapacityManagedBy:  capacityManagedBy,
+						},
+					},
+					NodeTopology: &metav1.LabelSelector{
+						MatchLabels: map[string]string{labelHostname: nodeInfo.hostname},
+					},
+					StorageClassName: storageClass.Name,
+					Capacity:         resource.NewQuantity(capacity, resource.BinarySI),
+				}
+			}(storageClass)
+		}
+	}
+	wg.Wait()
+
+	capacities := s.storage.CSIStorageCapacities(storageCapacity.Namespace)
+	existing, err := capacities.List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{
+			labelCapacityDriverName: cfg.ServiceName,
+			labelCapacityManagedBy:  capacityManagedBy,
+		}).String(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "list storage capacities")
+	}
+
+	for idx := range existing.Items {
+		current := &existing.Items[idx]
+		capacity, ok := desired[current.Name]
+		if !ok {
+			if err := capacities.Delete(ctx, current.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+				logger.WithContext(ctx).WithError(err).Warnf("failed to delete storage capacity: %s", current.Name)
+			}
+			continue
+		}
+		delete(desired, current.Name)
+		if current.Capacity != nil && current.Capacity.Cmp(*capacity.Capacity) == 0 {
+			continue
+		}
+		current.Capacity = capacity.Capacity
+		if _, err := capacities.Update(ctx, current, metav1.UpdateOptions{}); err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("failed to update storage capacity: %s", current.Name)
+		}
+	}
+	for _, capacity := range desired {
+		if _, err := capacities.Create(ctx, capacity, metav1.CreateOptions{}); err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("failed to create storage capacity: %s", capacity.Name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPublishStorageCapacities(t *testing.T) {
+	newNode := func(name, hostname, ip string) *corev1.Node {
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{labelHostname: hostname}},
+		}
+		if ip != "" {
+			node.Status.Addresses = []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: ip}}
+		}
+		return node
+	}
+	clientset := fake.NewSimpleClientset(
+		newNode("node-1", "host-1", "10.0.0.1"),
+		newNode("node-2", "host-2", "10.0.0.2"),
+		newNode("node-3", "host-3", ""),
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "model-image"}, Provisioner: "model.csi.modelpack.org"},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "other"}, Provisioner: "other.csi.example.com"},
+		&storagev1.CSIStorageCapacity{
+			ObjectMeta: metav1.ObjectMeta{Name: "csisc-stale", Namespace: "model-csi", Labels: map[string]string{
+				labelCapacityDriverName: "model.csi.modelpack.org",
+				labelCapacityManagedBy:  capacityManagedBy,
+			}},
+			StorageClassName: "model-image",
+		},
+		&storagev1.CSIStorageCapacity{
+			ObjectMeta:       metav1.ObjectMeta{Name: "csisc-other", Namespace: "model-csi"},
+			StorageClassName: "other",
+		},
+	)
+	svc := &Service{
+		cfg:     config.NewWithRaw(&config.RawConfig{ServiceName: "model.csi.modelpack.org", Mode: "controller"}),
+		node:    clientset.CoreV1().Nodes(),
+		storage: clientset.StorageV1(),
+	}
+	ctx := context.Background()
+
+	capacities := map[string]int64{"host-1": 100 << 20}
+	origGetNodeCapacity := getNodeCapacity
+	getNodeCapacity = func(ctx context.Context, s *Service, nodeInfo *nodeInfo, parameters map[string]string) (int64, error) {
+		capacity, ok := capacities[nodeInfo.hostname]
+		if !ok {
+			return 0, errors.New("connection refused")
+		}
+		return capacity, nil
+	}
+	defer func() { getNodeCapacity = origGetNodeCapacity }()
+
+	getCapacity := func(hostname string) *storagev1.CSIStorageCapacity {
+		capacity, err := clientset.StorageV1().CSIStorageCapacities("model-csi").Get(ctx, storageCapacityName("model-image", hostname), metav1.GetOptions{})
+		if err != nil {
+			return nil
+		}
+		return capacity
+	}
+
+	// Nothing is published until it's enabled.
+	require.NoError(t, svc.publishStorageCapacities(ctx))
+	require.Nil(t, getCapacity("host-1"))
+
+	svc.cfg.Get().Features.StorageCapacity = config.StorageCapacityConfig{Enabled: true, Namespace: "model-csi"}
+	require.NoError(t, svc.publishStorageCapacities(ctx))
+	capacity := getCapacity("host-1")
+	require.NotNil(t, capacity)
+	require.Equal(t, "model-image", capacity.StorageClassName)
+	require.Equal(t, map[string]string{labelHostname: "host-1"}, capacity.NodeTopology.MatchLabels)
+	require.Equal(t, int64(100<<20), capacity.Capacity.Value())
+	require.Nil(t, getCapacity("host-2"))
+
+	list, err := clientset.StorageV1().CSIStorageCapacities("model-csi").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	names := []string{}
+	for _, item := range list.Items {
+		names = append(names, item.Name)
+	}
+	require.ElementsMatch(t, []string{"csisc-other", storageCapacityName("model-image", "host-1")}, names)
+
+	// The objects of the nodes failing to report the capacity are deleted.
+	capacities = map[string]int64{"host-2": 10 << 20}
+	require.NoError(t, svc.publishStorageCapacities(ctx))
+	require.Nil(t, getCapacity("host-1"))
+	require.Equal(t, int64(10<<20), getCapacity("host-2").Capacity.Value())
+
+	// The capacity is updated.
+	capacities = map[string]int64{"host-2": 20 << 20}
+	require.NoError(t, svc.publishStorageCapacities(ctx))
+	require.Equal(t, int64(20<<20), getCapacity("host-2").Capacity.Value())
+}
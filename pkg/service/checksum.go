@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+// The checksum file written at the root of the model dir, in the format of
+// sha256sum, so that it can be verified by `sha256sum -c SHA256SUMS` inside
+// the pod without access to the registry.
+const checksumFile = "SHA256SUMS"
+
+const checksumTmpFile = "." + checksumFile + ".tmp"
+
+// writeChecksumFile writes the sha256 checksums of all the regular files in
+// the model dir into the checksum file, sorted by the file path. The file is
+// replaced atomically, so that the checksum file hardlinked from another
+// model dir is never changed.
+func writeChecksumFile(ctx context.Context, modelDir string, concurrency uint) error {
+	start := time.Now()
+
+	paths := []string{}
+	err := filepath.WalkDir(modelDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		relPath, err := filepath.Rel(modelDir, path)
+		if err != nil {
+			return err
+		}
+		if relPath == checksumFile || relPath == checksumTmpFile {
+			return nil
+		}
+		paths = append(paths, relPath)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "walk model dir: %s", modelDir)
+	}
+	sort.Strings(paths)
+
+	checksums := make([]string, len(paths))
+	eg, egCtx := errgroup.WithContext(ctx)
+	if concurrency > 0 {
+		eg.SetLimit(int(concurrency))
+	}
+	for idx, relPath := range paths {
+		eg.Go(func() error {
+			if err := egCtx.Err(); err != nil {
+				return err
+			}
+			checksum, err := sha256File(filepath.Join(modelDir, relPath))
+			if err != nil {
+				return errors.Wrapf(err, "checksum file: %s", relPath)
+			}
+			checksums[idx] = checksum
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	var content strings.Builder
+	for idx, relPath := range paths {
+		fmt.Fprintf(&content, "%s  %s\n", checksums[idx], filepath.ToSlash(relPath))
+	}
+	tmpPath := filepath.Join(modelDir, checksumTmpFile)
+	if err := os.WriteFile(tmpPath, []byte(content.String()), 0644); err != nil {
+		return errors.Wrapf(err, "write checksum file: %s", tmpPath)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(modelDir, checksumFile)); err != nil {
+		_ = os.Remove(tmpPath)
+		return errors.Wrapf(err, "rename checksum file: %s", tmpPath)
+	}
+	logger.WithContext(ctx).Infof("wrote checksums of %d model files, duration: %s", len(paths), time.Since(start))
+
+	return nil
+}
+
+func sha256File(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrap(err, "open file")
+	}
+	defer func() { _ = file.Close() }()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", errors.Wrap(err, "read file")
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writeModelChecksums writes the checksum file into the model dir once all the
+// model files are pulled, if enabled by write_checksum_file.
+func (worker *Worker) writeModelChecksums(ctx context.Context, modelDir string, reused bool) error {
+	pullCfg := worker.cfg.Get().PullConfig
+	if !pullCfg.WriteChecksumFile {
+		return nil
+	}
+	// The checksum file is cloned with the model dir from another volume.
+	if reused {
+		if _, err := os.Stat(filepath.Join(modelDir, checksumFile)); err == nil {
+			return nil
+		}
+	}
+	return writeChecksumFile(ctx, modelDir, pullCfg.Concurrency)
+}
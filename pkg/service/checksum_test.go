@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteChecksumFile(t *testing.T) {
+	modelDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(modelDir, "tokenizer"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(modelDir, "model.safetensors"), []byte("weights"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(modelDir, "tokenizer", "vocab.json"), []byte("{}"), 0644))
+	require.NoError(t, os.Symlink("model.safetensors", filepath.Join(modelDir, "link.safetensors")))
+
+	require.NoError(t, writeChecksumFile(context.Background(), modelDir, 2))
+	data, err := os.ReadFile(filepath.Join(modelDir, checksumFile))
+	require.NoError(t, err)
+	require.Equal(t,
+		digest.FromString("weights").Encoded()+"  model.safetensors\n"+
+			digest.FromString("{}").Encoded()+"  tokenizer/vocab.json\n",
+		string(data))
+
+	// The checksum file itself is excluded on rewriting.
+	require.NoError(t, writeChecksumFile(context.Background(), modelDir, 0))
+	rewritten, err := os.ReadFile(filepath.Join(modelDir, checksumFile))
+	require.NoError(t, err)
+	require.Equal(t, string(data), string(rewritten))
+	require.NoFileExists(t, filepath.Join(modelDir, checksumTmpFile))
+}
+
+func TestPullModel_ChecksumFile(t *testing.T) {
+	worker := newWorkerWithMockPuller(t, nil)
+	worker.cfg.Get().PullConfig.WriteChecksumFile = true
+	puller := &namedPuller{}
+	worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return puller
+	}
+
+	modelDir := worker.cfg.Get().GetModelDir("pvc-checksum")
+	require.NoError(t, worker.PullModel(context.Background(), true, "pvc-checksum", "", "test/model:latest", modelDir, PullOptions{}))
+	data, err := os.ReadFile(filepath.Join(modelDir, checksumFile))
+	require.NoError(t, err)
+	require.Equal(t, digest.FromString("test/model:latest").Encoded()+"  model\n", string(data))
+
+	// The checksum file is cloned with the reused model.
+	reusedDir := worker.cfg.Get().GetModelDir("pvc-checksum-reused")
+	require.NoError(t, worker.PullModel(context.Background(), true, "pvc-checksum-reused", "", "test/model:latest", reusedDir, PullOptions{}))
+	require.Len(t, puller.references, 1)
+	reused, err := os.ReadFile(filepath.Join(reusedDir, checksumFile))
+	require.NoError(t, err)
+	require.Equal(t, string(data), string(reused))
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// cloneSource is the model dir a volume is cloned from, with the status of
+// the model.
+type cloneSource struct {
+	name     string
+	modelDir string
+	status   *modelStatus.Status
+	unlock   func()
+}
+
+// lockCloneSource resolves the volume or the snapshot of the content source
+// on the node, it's locked against the deletion and the pull until unlocked.
+func (s *Service) lockCloneSource(ctx context.Context, source *csi.VolumeContentSource) (*cloneSource, error) {
+	if snapshot := source.GetSnapshot(); snapshot != nil {
+		snapshotID := snapshot.GetSnapshotId()
+		if !isValidSnapshotName(snapshotID) {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid snapshot id: %s", snapshotID)
+		}
+		contextKey := snapshotContextKey(snapshotID)
+		if err := s.worker.kmutex.Lock(ctx, contextKey); err != nil {
+			return nil, status.Error(codes.Internal, errors.Wrapf(err, "lock context key: %s", contextKey).Error())
+		}
+		snapshotDir := s.cfg.Get().GetSnapshotDir(snapshotID)
+		record, err := readSnapshotRecord(snapshotDir)
+		if err != nil {
+			s.worker.kmutex.Unlock(contextKey)
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, status.Errorf(codes.NotFound, "source snapshot not found: %s", snapshotID)
+			}
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		return &cloneSource{
+			name:     "snapshot/" + snapshotID,
+			modelDir: filepath.Join(snapshotDir, "model"),
+			status:   &record.Status,
+			unlock:   func() { s.worker.kmutex.Unlock(contextKey) },
+		}, nil
+	}
+
+	volumeID := source.GetVolume().GetVolumeId()
+	if volumeID == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing volume content source")
+	}
+	volumeDir, modelDir, err := s.resolveVolumeDir(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	contextKey := volumeContextKey(s.cfg.Get(), volumeDir)
+	if err := s.worker.kmutex.Lock(ctx, contextKey); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrapf(err, "lock context key: %s", contextKey).Error())
+	}
+	volumeStatus, err := s.sm.Get(filepath.Join(volumeDir, "status.json"))
+	if err != nil {
+		s.worker.kmutex.Unlock(contextKey)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, status.Errorf(codes.NotFound, "source volume not found: %s", volumeID)
+		}
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "get source volume status").Error())
+	}
+	reason := missingModelContent(modelDir, volumeStatus)
+	if !isScrubbable(volumeStatus) {
+		reason = "not pulled: " + volumeStatus.State
+	}
+	if reason != "" {
+		s.worker.kmutex.Unlock(contextKey)
+		return nil, status.Errorf(codes.FailedPrecondition, "model of source volume %s is %s", volumeID, reason)
+	}
+	return &cloneSource{
+		name:     "volume/" + volumeID,
+		modelDir: modelDir,
+		status:   volumeStatus,
+		unlock:   func() { s.worker.kmutex.Unlock(contextKey) },
+	}, nil
+}
+
+// localCloneVolume creates the static volume by cloning the model dir of the
+// volume or the snapshot on the node instead of pulling the model, the model
+// files are cloned the same as the models reused by the volumes.
+func (s *Service) localCloneVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
+	volumeName := req.GetName()
+	source, err := s.lockCloneSource(ctx, req.GetVolumeContentSource())
+	if err != nil {
+		return nil, err
+	}
+	defer source.unlock()
+	if err := checkCapacityRange(req.GetCapacityRange(), source.status.SizeInBytes); err != nil {
+		return nil, err
+	}
+
+	contextKey := fmt.Sprintf("%s/", volumeName)
+	if err := s.worker.kmutex.Lock(ctx, contextKey); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrapf(err, "lock context key: %s", contextKey).Error())
+	}
+	defer s.worker.kmutex.Unlock(contextKey)
+
+	volumeDir := s.cfg.Get().GetVolumeDir(volumeName)
+	statusPath := filepath.Join(volumeDir, "status.json")
+	volumeStatus, err := s.sm.Get(statusPath)
+	switch {
+	case err == nil && volumeStatus.ClonedFrom == source.name && isScrubbable(volumeStatus):
+		// The volume is cloned already.
+	case err == nil:
+		return nil, status.Errorf(codes.AlreadyExists, "volume %s exists with another model", volumeName)
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "get volume status").Error())
+	default:
+		volumeStatus, err = s.cloneVolume(ctx, volumeName, source)
+		if err != nil {
+			_ = removeVolumeDir(volumeDir)
+			return nil, status.Error(codes.Internal, errors.Wrapf(err, "clone volume from %s", source.name).Error())
+		}
+	}
+
+	volumeContext := map[string]string{}
+	for key, value := range req.GetParameters() {
+		volumeContext[key] = value
+	}
+	volumeContext[s.cfg.Get().ParameterKeyReference()] = volumeStatus.Reference
+	if volumeStatus.Digest != "" {
+		volumeContext[s.cfg.Get().ParameterKeyDigest()] = volumeStatus.Digest
+	}
+
+	return &csi.CreateVolumeResponse{
+		Volume: &csi.Volume{
+			VolumeId:      volumeName,
+			CapacityBytes: volumeCapacity(req.GetCapacityRange(), volumeStatus.SizeInBytes),
+			VolumeContext: volumeContext,
+			ContentSource: req.GetVolumeContentSource(),
+		},
+	}, nil
+}
+
+func (s *Service) cloneVolume(ctx context.Context, volumeName string, source *cloneSource) (*modelStatus.Status, error) {
+	volumeDir := s.cfg.Get().GetVolumeDir(volumeName)
+	modelDir := s.cfg.Get().GetModelDir(volumeName)
+	if err := os.MkdirAll(volumeDir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "create volume dir: %s", volumeDir)
+	}
+	if err := s.worker.cloneModelDir(source.modelDir, modelDir); err != nil {
+		return nil, errors.Wrapf(err, "clone model dir: %s", source.modelDir)
+	}
+
+	volumeStatus, err := s.sm.Set(filepath.Join(volumeDir, "status.json"), modelStatus.Status{
+		VolumeName:          volumeName,
+		Reference:           source.status.Reference,
+		Digest:              source.status.Digest,
+		Platform:            source.status.Platform,
+		State:               modelStatus.StatePullSucceeded,
+		ExcludeModelWeights: source.status.ExcludeModelWeights,
+		ExcludeFilePatterns: source.status.ExcludeFilePatterns,
+		ExcludedFiles:       source.status.ExcludedFiles,
+		Adapters:            source.status.Adapters,
+		SizeInBytes:         modelDirSize(ctx, modelDir),
+		ClonedFrom:          source.name,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "set volume status")
+	}
+	logger.WithContext(ctx).Infof("cloned model %s from %s", source.status.Reference, source.name)
+
+	return volumeStatus, nil
+}
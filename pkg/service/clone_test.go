@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCloneVolume(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	cfg := svc.cfg.Get()
+	cfg.Features.Snapshot = true
+
+	newVolume := func(volumeName, state string) {
+		t.Helper()
+		modelDir := cfg.GetModelDir(volumeName)
+		require.NoError(t, os.MkdirAll(modelDir, 0750))
+		require.NoError(t, os.WriteFile(filepath.Join(modelDir, "weights.bin"), []byte("tuned"), 0644))
+		_, err := svc.sm.Set(filepath.Join(cfg.GetVolumeDir(volumeName), "status.json"), status.Status{
+			VolumeName:  volumeName,
+			Reference:   "test/model:latest",
+			Digest:      "sha256:abc",
+			State:       state,
+			PullKey:     "image|docker.io/test/model:latest|",
+			SizeInBytes: 5,
+		})
+		require.NoError(t, err)
+	}
+	newVolume("pvc-source", status.StateMounted)
+	newVolume("pvc-pulling", status.StatePullRunning)
+	_, err := svc.CreateSnapshot(ctx, &csi.CreateSnapshotRequest{Name: "snapshot-1", SourceVolumeId: "pvc-source"})
+	require.NoError(t, err)
+
+	parameters := map[string]string{
+		cfg.ParameterKeyType():      "image",
+		cfg.ParameterKeyReference(): "test/other:latest",
+	}
+	volumeSource := &csi.VolumeContentSource{Type: &csi.VolumeContentSource_Volume{
+		Volume: &csi.VolumeContentSource_VolumeSource{VolumeId: "pvc-source"},
+	}}
+	snapshotSource := &csi.VolumeContentSource{Type: &csi.VolumeContentSource_Snapshot{
+		Snapshot: &csi.VolumeContentSource_SnapshotSource{SnapshotId: "snapshot-1"},
+	}}
+	createVolume := func(volumeName string, source *csi.VolumeContentSource) (*csi.CreateVolumeResponse, error) {
+		return svc.CreateVolume(ctx, &csi.CreateVolumeRequest{Name: volumeName, Parameters: parameters, VolumeContentSource: source})
+	}
+	requireCloned := func(volumeName, clonedFrom string) {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(cfg.GetModelDir(volumeName), "weights.bin"))
+		require.NoError(t, err)
+		require.Equal(t, "tuned", string(data))
+		volumeStatus, err := svc.sm.Get(filepath.Join(cfg.GetVolumeDir(volumeName), "status.json"))
+		require.NoError(t, err)
+		require.Equal(t, status.StatePullSucceeded, volumeStatus.State)
+		require.Equal(t, "test/model:latest", volumeStatus.Reference)
+		require.Equal(t, clonedFrom, volumeStatus.ClonedFrom)
+		require.Empty(t, volumeStatus.PullKey)
+	}
+
+	// The model of the source is cloned instead of the model of the parameters.
+	resp, err := createVolume("pvc-clone", volumeSource)
+	require.NoError(t, err)
+	require.Equal(t, "pvc-clone", resp.Volume.VolumeId)
+	require.Equal(t, volumeSource, resp.Volume.ContentSource)
+	require.Equal(t, "test/model:latest", resp.Volume.VolumeContext[cfg.ParameterKeyReference()])
+	require.Equal(t, "sha256:abc", resp.Volume.VolumeContext[cfg.ParameterKeyDigest()])
+	requireCloned("pvc-clone", "volume/pvc-source")
+
+	resp, err = createVolume("pvc-restore", snapshotSource)
+	require.NoError(t, err)
+	require.Equal(t, snapshotSource, resp.Volume.ContentSource)
+	require.Positive(t, resp.Volume.CapacityBytes)
+
+	// The capacity is the size of the source within the capacity range.
+	resp, err = svc.CreateVolume(ctx, &csi.CreateVolumeRequest{
+		Name: "pvc-sized", Parameters: parameters, VolumeContentSource: volumeSource,
+		CapacityRange: &csi.CapacityRange{RequiredBytes: 1 << 20},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1<<20), resp.Volume.CapacityBytes)
+	_, err = svc.CreateVolume(ctx, &csi.CreateVolumeRequest{
+		Name: "pvc-small", Parameters: parameters, VolumeContentSource: volumeSource,
+		CapacityRange: &csi.CapacityRange{RequiredBytes: 4},
+	})
+	require.Equal(t, codes.OutOfRange, grpcStatus.Code(err))
+	require.NoDirExists(t, cfg.GetVolumeDir("pvc-small"))
+	requireCloned("pvc-restore", "snapshot/snapshot-1")
+
+	// The creation is idempotent for the same source.
+	_, err = createVolume("pvc-clone", volumeSource)
+	require.NoError(t, err)
+	_, err = createVolume("pvc-clone", snapshotSource)
+	require.Equal(t, codes.AlreadyExists, grpcStatus.Code(err))
+
+	// The cloned model isn't reused by the volumes of the same model.
+	require.Equal(t, cfg.GetModelDir("pvc-source"), svc.worker.findPulledModel(ctx, "image|docker.io/test/model:latest|", cfg.GetModelDir("pvc-new")))
+	require.NoError(t, os.RemoveAll(cfg.GetVolumeDir("pvc-source")))
+	require.Empty(t, svc.worker.findPulledModel(ctx, "image|docker.io/test/model:latest|", cfg.GetModelDir("pvc-new")))
+
+	// The wiped model of the clone isn't pulled again.
+	require.NoError(t, os.RemoveAll(cfg.GetModelDir("pvc-restore")))
+	_, err = svc.nodePublishVolumeStatic(ctx, "pvc-restore", filepath.Join(t.TempDir(), "target"), nil, publishAccess{})
+	require.Equal(t, codes.FailedPrecondition, grpcStatus.Code(err))
+
+	_, err = createVolume("pvc-a", volumeSource)
+	require.Equal(t, codes.NotFound, grpcStatus.Code(err))
+	require.NoDirExists(t, cfg.GetVolumeDir("pvc-a"))
+	_, err = createVolume("pvc-a", &csi.VolumeContentSource{Type: &csi.VolumeContentSource_Volume{
+		Volume: &csi.VolumeContentSource_VolumeSource{VolumeId: "pvc-pulling"},
+	}})
+	require.Equal(t, codes.FailedPrecondition, grpcStatus.Code(err))
+	parameters[cfg.ParameterKeyMountID()] = "mount-1"
+	_, err = createVolume("csi-dyn", snapshotSource)
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+}
+
+func TestNodeContentSource(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{labelHostname: "host-1"}},
+			Status:     corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.0.0.1"}}},
+		},
+		&corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc-source"},
+			Spec: corev1.PersistentVolumeSpec{
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					CSI: &corev1.CSIPersistentVolumeSource{Driver: "model.csi.modelpack.org", VolumeHandle: "pvc-source"},
+				},
+				NodeAffinity: &corev1.VolumeNodeAffinity{
+					Required: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+							MatchExpressions: []corev1.NodeSelectorRequirement{{
+								Key:      labelHostname,
+								Operator: corev1.NodeSelectorOpIn,
+								Values:   []string{"host-1"},
+							}},
+						}},
+					},
+				},
+			},
+		},
+	)
+	svc := &Service{
+		cfg:  config.NewWithRaw(&config.RawConfig{ServiceName: "model.csi.modelpack.org", Mode: "controller"}),
+		node: clientset.CoreV1().Nodes(),
+		pvs:  clientset.CoreV1().PersistentVolumes(),
+	}
+	ctx := context.Background()
+	host1 := &nodeInfo{ip: "10.0.0.1", hostname: "host-1"}
+	host2 := &nodeInfo{ip: "10.0.0.2", hostname: "host-2"}
+
+	source, err := svc.nodeContentSource(ctx, nil, host1)
+	require.NoError(t, err)
+	require.Nil(t, source)
+
+	volumeSource := &csi.VolumeContentSource{Type: &csi.VolumeContentSource_Volume{
+		Volume: &csi.VolumeContentSource_VolumeSource{VolumeId: "pvc-source"},
+	}}
+	source, err = svc.nodeContentSource(ctx, volumeSource, host1)
+	require.NoError(t, err)
+	require.Equal(t, "pvc-source", source.GetVolume().GetVolumeId())
+	_, err = svc.nodeContentSource(ctx, volumeSource, host2)
+	require.Equal(t, codes.FailedPrecondition, grpcStatus.Code(err))
+
+	// The hostname of the snapshot ID is stripped for the node.
+	source, err = svc.nodeContentSource(ctx, &csi.VolumeContentSource{Type: &csi.VolumeContentSource_Snapshot{
+		Snapshot: &csi.VolumeContentSource_SnapshotSource{SnapshotId: "host-2/snapshot-1"},
+	}}, host2)
+	require.NoError(t, err)
+	require.Equal(t, "snapshot-1", source.GetSnapshot().GetSnapshotId())
+	_, err = svc.nodeContentSource(ctx, &csi.VolumeContentSource{Type: &csi.VolumeContentSource_Snapshot{
+		Snapshot: &csi.VolumeContentSource_SnapshotSource{SnapshotId: "snapshot-1"},
+	}}, host2)
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/modelpack/model-csi-driver/pkg/mounter"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// localControllerGetVolume returns the volume hosted by the node with the
+// condition of its model, the volume ID is $volumeName for the static volume
+// and $volumeName/$mountID for the mount of the dynamic volume.
+func (s *Service) localControllerGetVolume(
+	ctx context.Context,
+	req *csi.ControllerGetVolumeRequest) (
+	*csi.ControllerGetVolumeResponse, error) {
+	volumeID := req.GetVolumeId()
+	if volumeID == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing required parameter: volumeID")
+	}
+
+	volumeDir, modelDir, err := s.resolveVolumeDir(volumeID)
+	if err != nil {
+		return nil, err
+	}
+
+	volume, volumeStatus, err := s.getLocalVolume(ctx, volumeID, volumeDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, status.Errorf(codes.NotFound, "volume not found: %s", volumeID)
+		}
+		return nil, err
+	}
+
+	return &csi.ControllerGetVolumeResponse{
+		Volume: volume,
+		Status: &csi.ControllerGetVolumeResponse_VolumeStatus{
+			VolumeCondition: s.volumeCondition(ctx, modelDir, volumeStatus),
+		},
+	}, nil
+}
+
+// volumeCondition reports the model of the volume as abnormal if its pull is
+// failed, its files are missing or corrupted by the last scrub, or any target
+// path it's published to isn't mounted any more.
+func (s *Service) volumeCondition(ctx context.Context, modelDir string, volumeStatus *modelStatus.Status) *csi.VolumeCondition {
+	abnormal := func(message string) *csi.VolumeCondition {
+		return &csi.VolumeCondition{Abnormal: true, Message: message}
+	}
+
+	switch volumeStatus.State {
+	case modelStatus.StatePullFailed, modelStatus.StatePullTimeout, modelStatus.StatePullCanceled:
+		return abnormal(fmt.Sprintf("model %s is not pulled: %s", volumeStatus.Reference, volumeStatus.State))
+	case modelStatus.StatePullQueued, modelStatus.StatePullRunning:
+		return &csi.VolumeCondition{Message: fmt.Sprintf("model %s is being pulled", volumeStatus.Reference)}
+	}
+
+	if reason := missingModelContent(modelDir, volumeStatus); reason != "" {
+		return abnormal(fmt.Sprintf("model files are %s in %s", reason, modelDir))
+	}
+
+	statuses := []modelStatus.Status{*volumeStatus}
+	s.worker.markScrubbed(statuses)
+	if scrub := statuses[0].Scrub; scrub != nil && len(scrub.CorruptedFiles) > 0 {
+		return abnormal(fmt.Sprintf(
+			"model files mismatch the checksums at %s: %s",
+			scrub.ScrubbedAt.Format(time.RFC3339), strings.Join(scrub.CorruptedFiles, ", "),
+		))
+	}
+
+	if volumeStatus.StagingPath != "" {
+		isMounted, err := mounter.IsMounted(ctx, volumeStatus.StagingPath)
+		if err != nil {
+			return abnormal(fmt.Sprintf("failed to check staging path %s: %s", volumeStatus.StagingPath, err))
+		}
+		if !isMounted {
+			return abnormal(fmt.Sprintf("staging path is not mounted: %s", volumeStatus.StagingPath))
+		}
+	}
+	for _, target := range volumeStatus.Targets {
+		isMounted, err := mounter.IsMounted(ctx, target.Path)
+		if err != nil {
+			return abnormal(fmt.Sprintf("failed to check target path %s: %s", target.Path, err))
+		}
+		if !isMounted {
+			return abnormal(fmt.Sprintf("target path is not mounted: %s", target.Path))
+		}
+	}
+
+	return &csi.VolumeCondition{Message: fmt.Sprintf("model %s is healthy", volumeStatus.Reference)}
+}
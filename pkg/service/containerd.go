@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/modelpack/modctl/pkg/backend"
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// containerdPuller imports the layers of the model image already present in
+// the containerd content store of the node (e.g. pulled by the container
+// runtime as an image volume) into the model dir, the other layers are pulled
+// by the wrapped puller, which resumes from the imported layers by the pull
+// state. The content store is best effort, the model is pulled from the
+// registry if the import fails.
+type containerdPuller struct {
+	Puller
+	pullCfg *config.PullConfig
+	hook    *status.Hook
+}
+
+func (p *containerdPuller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	stateKey := pullStateKey(reference, PullOptions{
+		Type:                ModelTypeImage,
+		ExcludeModelWeights: excludeModelWeights,
+		ExcludeFilePatterns: excludeFilePatterns,
+	})
+	// The model dir holding the layers of an interrupted pull is resumed by
+	// the puller instead.
+	if !canResumePull(targetDir, stateKey) {
+		include := includeLayerFunc(ctx, nil, excludeModelWeights, excludeFilePatterns)
+		if err := p.importLayers(ctx, reference, targetDir, stateKey, include); err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("failed to import layers from containerd content store, pull them instead: %s", reference)
+			if err := os.Remove(getPullStatePath(targetDir)); err != nil && !os.IsNotExist(err) {
+				return errors.Wrapf(err, "remove pull state: %s", targetDir)
+			}
+			if err := os.RemoveAll(targetDir); err != nil {
+				return errors.Wrapf(err, "cleanup model dir: %s", targetDir)
+			}
+		}
+	}
+
+	return p.Puller.Pull(ctx, reference, targetDir, excludeModelWeights, excludeFilePatterns)
+}
+
+// contentPath returns the path of the blob of the layer in the content store,
+// or empty if the blob isn't there, e.g.
+// /var/lib/containerd/io.containerd.content.v1.content/blobs/sha256/$hex.
+func (p *containerdPuller) contentPath(desc ocispec.Descriptor) string {
+	if err := desc.Digest.Validate(); err != nil {
+		return ""
+	}
+	blobPath := filepath.Join(p.pullCfg.Containerd.ContentDir, "blobs", desc.Digest.Algorithm().String(), desc.Digest.Encoded())
+	info, err := os.Stat(blobPath)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != desc.Size {
+		return ""
+	}
+	return blobPath
+}
+
+// importLayers writes the files of the layers found in the content store
+// into the model dir, and records them as pulled in the pull state.
+func (p *containerdPuller) importLayers(
+	ctx context.Context,
+	reference, targetDir, stateKey string,
+	include func(layer backend.InspectedModelArtifactLayer) bool,
+) error {
+	pullCfg := registryPullConfig(p.pullCfg, reference)
+	repo, err := newOCIRepository(pullCfg, reference)
+	if err != nil {
+		return err
+	}
+	manifestDesc, manifest, err := fetchManifest(ctx, repo)
+	if err != nil {
+		return err
+	}
+	// Verify the signature before importing the model files, which bypasses
+	// the puller.
+	if err := verifySignature(ctx, &pullCfg.Signature, repo, manifestDesc); err != nil {
+		return err
+	}
+	if !isModelManifest(manifest) {
+		return nil
+	}
+
+	state := &pullState{Key: stateKey, Layers: map[string]string{}}
+	importedSize := int64(0)
+	for _, desc := range manifest.Layers {
+		filePath := status.LayerFilepath(desc)
+		if filePath == "" || !filepath.IsLocal(filePath) {
+			continue
+		}
+		if !include(backend.InspectedModelArtifactLayer{Digest: desc.Digest.String(), Filepath: filePath, Size: desc.Size}) {
+			continue
+		}
+		blobPath := p.contentPath(desc)
+		if blobPath == "" {
+			continue
+		}
+		if err := importLayer(ctx, desc, blobPath, targetDir, filePath, &p.pullCfg.Extraction); err != nil {
+			return errors.Wrapf(err, "import layer: %s", desc.Digest)
+		}
+		state.Layers[filePath] = desc.Digest.String()
+		importedSize += desc.Size
+	}
+	if len(state.Layers) == 0 {
+		return nil
+	}
+
+	if err := newResumeHook(ctx, p.hook, getPullStatePath(targetDir), state).save(); err != nil {
+		return err
+	}
+	logger.WithContext(ctx).Infof(
+		"imported %d model files (%d bytes) from containerd content store: %s", len(state.Layers), importedSize, reference,
+	)
+
+	return nil
+}
+
+// importLayer writes the file of the layer from the blob in the content store,
+// the blob is copied instead of hardlinked, so that the content store is never
+// changed by a write to the volume.
+func importLayer(ctx context.Context, desc ocispec.Descriptor, blobPath, targetDir, filePath string, extractCfg *config.ExtractionConfig) error {
+	file, err := os.Open(blobPath)
+	if err != nil {
+		return errors.Wrapf(err, "open blob: %s", blobPath)
+	}
+	defer func() { _ = file.Close() }()
+
+	verifier := desc.Digest.Verifier()
+	reader := io.TeeReader(file, verifier)
+
+	if isRawLayer(desc) {
+		err = writeFile(reader, filepath.Join(targetDir, filePath))
+	} else {
+		err = extractTar(ctx, reader, targetDir, func(name string) bool { return true }, extractCfg)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Consume the rest of the blob to verify the whole layer.
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return errors.Wrapf(err, "read blob: %s", blobPath)
+	}
+	if !verifier.Verified() {
+		return errors.Errorf("digest mismatch for blob: %s", blobPath)
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	modelspec "github.com/modelpack/model-spec/specs-go/v1"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func writeContentBlob(t *testing.T, contentDir string, data []byte) {
+	dgst := digest.FromBytes(data)
+	blobPath := filepath.Join(contentDir, "blobs", dgst.Algorithm().String(), dgst.Encoded())
+	require.NoError(t, os.MkdirAll(filepath.Dir(blobPath), 0755))
+	require.NoError(t, os.WriteFile(blobPath, data, 0644))
+}
+
+func newContainerdPullerTest(t *testing.T) (*containerdPuller, *namedPuller, string, map[string][]byte) {
+	weights := []byte("weights")
+	configLayer := newTarGz(t, []tarEntry{{name: "config.json", content: `{"model_type":"qwen3"}`}})
+	readme := []byte("# model")
+	layer := func(mediaType, filePath string, data []byte) ocispec.Descriptor {
+		return ocispec.Descriptor{
+			MediaType:   mediaType,
+			Digest:      digest.FromBytes(data),
+			Size:        int64(len(data)),
+			Annotations: map[string]string{modelspec.AnnotationFilepath: filePath},
+		}
+	}
+	manifest := ocispec.Manifest{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: modelspec.ArtifactTypeModelManifest,
+		Config:       ocispec.DescriptorEmptyJSON,
+		Layers: []ocispec.Descriptor{
+			layer(modelspec.MediaTypeModelWeightRaw, "model.safetensors", weights),
+			layer("application/vnd.cncf.model.weight.config.v1.tar", "config.json", configLayer),
+			layer(modelspec.MediaTypeModelDocRaw, "README.md", readme),
+		},
+	}
+	host := newFakeRegistry(t, manifest, map[digest.Digest][]byte{})
+
+	contentDir := t.TempDir()
+	inner := &namedPuller{}
+	p := &containerdPuller{
+		Puller:  inner,
+		pullCfg: &config.PullConfig{Containerd: config.ContainerdConfig{ContentDir: contentDir}},
+		hook:    status.NewHook(context.Background()),
+	}
+	return p, inner, host + "/test/model:latest", map[string][]byte{
+		"weights": weights,
+		"config":  configLayer,
+		"readme":  readme,
+	}
+}
+
+func TestContainerdPuller_ImportLayers(t *testing.T) {
+	p, inner, reference, blobs := newContainerdPullerTest(t)
+	writeContentBlob(t, p.pullCfg.Containerd.ContentDir, blobs["weights"])
+	writeContentBlob(t, p.pullCfg.Containerd.ContentDir, blobs["config"])
+
+	targetDir := filepath.Join(t.TempDir(), "model")
+	require.NoError(t, p.Pull(context.Background(), reference, targetDir, false, nil))
+	require.Equal(t, []string{reference}, inner.references)
+
+	data, err := os.ReadFile(filepath.Join(targetDir, "model.safetensors"))
+	require.NoError(t, err)
+	require.Equal(t, "weights", string(data))
+	require.FileExists(t, filepath.Join(targetDir, "config.json"))
+
+	// The imported layers are recorded in the pull state, so that the puller
+	// only pulls the missing README.md.
+	state, err := readPullState(getPullStatePath(targetDir))
+	require.NoError(t, err)
+	require.Equal(t, pullStateKey(reference, PullOptions{Type: ModelTypeImage}), state.Key)
+	require.Equal(t, map[string]string{
+		"model.safetensors": digest.FromBytes(blobs["weights"]).String(),
+		"config.json":       digest.FromBytes(blobs["config"]).String(),
+	}, state.Layers)
+}
+
+func TestContainerdPuller_ExcludedLayers(t *testing.T) {
+	p, _, reference, blobs := newContainerdPullerTest(t)
+	writeContentBlob(t, p.pullCfg.Containerd.ContentDir, blobs["weights"])
+	writeContentBlob(t, p.pullCfg.Containerd.ContentDir, blobs["config"])
+
+	targetDir := filepath.Join(t.TempDir(), "model")
+	require.NoError(t, p.Pull(context.Background(), reference, targetDir, true, nil))
+	require.NoFileExists(t, filepath.Join(targetDir, "model.safetensors"))
+	require.FileExists(t, filepath.Join(targetDir, "config.json"))
+}
+
+func TestContainerdPuller_CorruptedBlob(t *testing.T) {
+	p, inner, reference, blobs := newContainerdPullerTest(t)
+	// The blob of the same size but different content.
+	weights := blobs["weights"]
+	blobPath := filepath.Join(p.pullCfg.Containerd.ContentDir, "blobs", "sha256", digest.FromBytes(weights).Encoded())
+	require.NoError(t, os.MkdirAll(filepath.Dir(blobPath), 0755))
+	require.NoError(t, os.WriteFile(blobPath, []byte("corrupt"), 0644))
+
+	// The model is pulled from the registry instead.
+	targetDir := filepath.Join(t.TempDir(), "model")
+	require.NoError(t, p.Pull(context.Background(), reference, targetDir, false, nil))
+	require.Equal(t, []string{reference}, inner.references)
+	require.NoFileExists(t, filepath.Join(targetDir, "model.safetensors"))
+	require.NoFileExists(t, getPullStatePath(targetDir))
+}
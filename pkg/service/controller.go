@@ -98,6 +98,46 @@ func (s *Service) DeleteVolume(
 	return resp, nil
 }
 
+func (s *Service) ControllerModifyVolume(
+	ctx context.Context,
+	req *csi.ControllerModifyVolumeRequest) (
+	*csi.ControllerModifyVolumeResponse, error) {
+	if !s.hasControllerCapability(csi.ControllerServiceCapability_RPC_MODIFY_VOLUME) {
+		return nil, status.Error(codes.Unimplemented, "modify volume is not enabled")
+	}
+
+	ctx, span := tracing.Tracer.Start(ctx, "ModifyVolume")
+	defer span.End()
+	span.SetAttributes(attribute.String("mode", s.cfg.Get().Mode))
+
+	ctx = logger.NewContext(ctx, "ModifyVolume", req.GetVolumeId(), "")
+
+	logger.WithContext(ctx).Infof("modifying volume with mutable parameters: %v", req.GetMutableParameters())
+	var resp *csi.ControllerModifyVolumeResponse
+	var isStaticVolume bool
+	var err error
+	start := time.Now()
+	if s.cfg.Get().IsControllerMode() {
+		resp, err = s.remoteModifyVolume(ctx, req)
+		metrics.ControllerOpObserve("modify_volume", start, err)
+	} else {
+		resp, isStaticVolume, err = s.localModifyVolume(ctx, req)
+		if isStaticVolume {
+			metrics.NodeOpObserve("modify_volume", start, err)
+		} else {
+			metrics.NodeOpObserve("modify_dynamic_volume", start, err)
+		}
+	}
+	if err != nil {
+		span.SetStatus(otelCodes.Error, "failed to modify volume")
+		span.RecordError(err)
+		logger.WithContext(ctx).WithError(err).Errorf("failed to modify volume")
+	} else {
+		logger.WithContext(ctx).Infof("modified volume")
+	}
+	return resp, err
+}
+
 func (s *Service) getDynamicVolume(ctx context.Context, volumeName, mountID string) (*modelStatus.Status, error) {
 	ctx = logger.NewContext(ctx, "GetVolume", volumeName, "")
 
@@ -116,10 +156,16 @@ func (s *Service) GetDynamicVolume(ctx context.Context, volumeName, mountID stri
 	start := time.Now()
 	status, err := s.getDynamicVolume(ctx, volumeName, mountID)
 	metrics.NodeOpObserve("get_dynamic_volume", start, err)
+	status = s.withDeletingState(s.worker.withPinned(ctx, status))
+	if status != nil {
+		status.ExpiresAt = s.mountExpiresAt(ctx, volumeName, mountID)
+	}
 	return status, err
 }
 
-func (s *Service) listDynamicVolumes(ctx context.Context, volumeName string) ([]modelStatus.Status, error) {
+// listDynamicVolumes returns the page of the mounts of the dynamic volume
+// matching the request, with the number of the mounts matching it.
+func (s *Service) listDynamicVolumes(ctx context.Context, volumeName string, req ListMountsRequest) ([]modelStatus.Status, int, error) {
 	ctx = logger.NewContext(ctx, "ListVolumes", volumeName, "")
 
 	modelsDir := s.cfg.Get().GetModelsDirForDynamic(volumeName)
@@ -127,12 +173,12 @@ func (s *Service) listDynamicVolumes(ctx context.Context, volumeName string) ([]
 	entries, err := os.ReadDir(modelsDir)
 	if err != nil {
 		logger.WithContext(ctx).WithError(err).Errorf("failed to read models dir")
-		return nil, err
+		return nil, 0, err
 	}
 
 	statuses := []modelStatus.Status{}
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		if !isVolumeDirEntry(modelsDir, entry) {
 			continue
 		}
 
@@ -145,20 +191,49 @@ func (s *Service) listDynamicVolumes(ctx context.Context, volumeName string) ([]
 				continue
 			}
 			logger.WithContext(ctx).WithError(err).Errorf("failed to get volume status")
-			return nil, err
+			return nil, 0, err
+		}
+		// The mounts are sorted and decorated by the mount ID.
+		mount := *status
+		mount.MountID = mountID
+		if !req.match(&mount) {
+			continue
 		}
 
-		statuses = append(statuses, *status)
+		statuses = append(statuses, mount)
 	}
+	req.sort(statuses)
+	total := len(statuses)
 
-	return statuses, err
+	// Only the mounts of the page are decorated, e.g. by the leases.
+	statuses = req.page(statuses)
+	for i := range statuses {
+		statuses[i].ExpiresAt = s.mountExpiresAt(ctx, volumeName, statuses[i].MountID)
+	}
+	s.worker.markPinned(ctx, statuses)
+	s.worker.markScrubbed(statuses)
+
+	return statuses, total, nil
 }
 
 func (s *Service) ListDynamicVolumes(ctx context.Context, volumeName string) ([]modelStatus.Status, error) {
+	statuses, _, err := s.ListMounts(ctx, volumeName, ListMountsRequest{})
+	return statuses, err
+}
+
+// ListMounts returns the page of the mounts of the dynamic volume matching the
+// request, with the number of the mounts matching it.
+func (s *Service) ListMounts(ctx context.Context, volumeName string, req ListMountsRequest) ([]modelStatus.Status, int, error) {
+	if err := req.validate(); err != nil {
+		return nil, 0, status.Error(codes.InvalidArgument, err.Error())
+	}
 	start := time.Now()
-	statuses, err := s.listDynamicVolumes(ctx, volumeName)
+	statuses, total, err := s.listDynamicVolumes(ctx, volumeName, req)
 	metrics.NodeOpObserve("list_dynamic_volumes", start, err)
-	return statuses, err
+	for i := range statuses {
+		s.withDeletingState(&statuses[i])
+	}
+	return statuses, total, err
 }
 
 func (s *Service) ListVolumes(
@@ -170,14 +245,20 @@ func (s *Service) ListVolumes(
 	logger.WithContext(ctx).Infof("listing volumes")
 	var resp *csi.ListVolumesResponse
 	var err error
+	start := time.Now()
 	if s.cfg.Get().IsControllerMode() {
 		resp, err = s.remoteListVolumes(ctx, req)
+		metrics.ControllerOpObserve("list_volumes", start, err)
 	} else {
-		return nil, status.Error(codes.Unimplemented, "local list volumes not implemented")
+		resp, err = s.localListVolumes(ctx, req)
+		metrics.NodeOpObserve("list_volumes", start, err)
 	}
 
 	if err != nil {
 		logger.WithContext(ctx).WithError(err).Errorf("failed to list volumes")
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
 		return nil, status.Error(codes.Internal, err.Error())
 	} else {
 		logger.WithContext(ctx).Infof("listed volumes")
@@ -186,6 +267,37 @@ func (s *Service) ListVolumes(
 	return resp, nil
 }
 
+func (s *Service) ControllerGetVolume(
+	ctx context.Context,
+	req *csi.ControllerGetVolumeRequest) (
+	*csi.ControllerGetVolumeResponse, error) {
+	ctx = logger.NewContext(ctx, "ControllerGetVolume", req.GetVolumeId(), "")
+
+	var resp *csi.ControllerGetVolumeResponse
+	var err error
+	start := time.Now()
+	if s.cfg.Get().IsControllerMode() {
+		resp, err = s.remoteControllerGetVolume(ctx, req)
+		metrics.ControllerOpObserve("get_volume", start, err)
+	} else {
+		resp, err = s.localControllerGetVolume(ctx, req)
+		metrics.NodeOpObserve("get_volume", start, err)
+	}
+
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Errorf("failed to get volume")
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if condition := resp.GetStatus().GetVolumeCondition(); condition.GetAbnormal() {
+		logger.WithContext(ctx).Warnf("volume is abnormal: %s", condition.GetMessage())
+	}
+
+	return resp, nil
+}
+
 func (s *Service) ControllerPublishVolume(
 	ctx context.Context,
 	req *csi.ControllerPublishVolumeRequest) (
@@ -211,7 +323,28 @@ func (s *Service) GetCapacity(
 	ctx context.Context,
 	req *csi.GetCapacityRequest) (
 	*csi.GetCapacityResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	ctx, span := tracing.Tracer.Start(ctx, "GetCapacity")
+	defer span.End()
+	span.SetAttributes(attribute.String("mode", s.cfg.Get().Mode))
+
+	ctx = logger.NewContext(ctx, "GetCapacity", "", "")
+
+	var resp *csi.GetCapacityResponse
+	var err error
+	start := time.Now()
+	if s.cfg.Get().IsControllerMode() {
+		resp, err = s.remoteGetCapacity(ctx, req)
+		metrics.ControllerOpObserve("get_capacity", start, err)
+	} else {
+		resp, err = s.localGetCapacity(ctx, req)
+		metrics.NodeOpObserve("get_capacity", start, err)
+	}
+	if err != nil {
+		span.SetStatus(otelCodes.Error, "failed to get capacity")
+		span.RecordError(err)
+		logger.WithContext(ctx).WithError(err).Errorf("failed to get capacity")
+	}
+	return resp, err
 }
 
 func (s *Service) ControllerGetCapabilities(
@@ -229,13 +362,7 @@ func (s *Service) ControllerGetCapabilities(
 	}
 
 	var caps []*csi.ControllerServiceCapability
-	for _, capability := range []csi.ControllerServiceCapability_RPC_Type{
-		csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
-		// csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME,
-		// csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT,
-		// csi.ControllerServiceCapability_RPC_GET_CAPACITY,
-		// csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
-	} {
+	for _, capability := range s.controllerCapabilities() {
 		caps = append(caps, newCap(capability))
 	}
 
@@ -250,21 +377,94 @@ func (s *Service) CreateSnapshot(
 	ctx context.Context,
 	req *csi.CreateSnapshotRequest) (
 	*csi.CreateSnapshotResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if !s.hasControllerCapability(csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT) {
+		return nil, status.Error(codes.Unimplemented, "snapshot is not enabled")
+	}
+	ctx = logger.NewContext(ctx, "CreateSnapshot", req.GetSourceVolumeId(), "")
+
+	logger.WithContext(ctx).Infof("creating snapshot: %s", req.GetName())
+	var resp *csi.CreateSnapshotResponse
+	var err error
+	start := time.Now()
+	if s.cfg.Get().IsControllerMode() {
+		resp, err = s.remoteCreateSnapshot(ctx, req)
+		metrics.ControllerOpObserve("create_snapshot", start, err)
+	} else {
+		resp, err = s.localCreateSnapshot(ctx, req)
+		metrics.NodeOpObserve("create_snapshot", start, err)
+	}
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Errorf("failed to create snapshot")
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	logger.WithContext(ctx).Infof("created snapshot: %s", resp.GetSnapshot().GetSnapshotId())
+
+	return resp, nil
 }
 
 func (s *Service) DeleteSnapshot(
 	ctx context.Context,
 	req *csi.DeleteSnapshotRequest) (
 	*csi.DeleteSnapshotResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if !s.hasControllerCapability(csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT) {
+		return nil, status.Error(codes.Unimplemented, "snapshot is not enabled")
+	}
+	ctx = logger.NewContext(ctx, "DeleteSnapshot", req.GetSnapshotId(), "")
+
+	logger.WithContext(ctx).Infof("deleting snapshot")
+	var resp *csi.DeleteSnapshotResponse
+	var err error
+	start := time.Now()
+	if s.cfg.Get().IsControllerMode() {
+		resp, err = s.remoteDeleteSnapshot(ctx, req)
+		metrics.ControllerOpObserve("delete_snapshot", start, err)
+	} else {
+		resp, err = s.localDeleteSnapshot(ctx, req)
+		metrics.NodeOpObserve("delete_snapshot", start, err)
+	}
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Errorf("failed to delete snapshot")
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	logger.WithContext(ctx).Infof("deleted snapshot")
+
+	return resp, nil
 }
 
 func (s *Service) ListSnapshots(
 	ctx context.Context,
 	req *csi.ListSnapshotsRequest) (
 	*csi.ListSnapshotsResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if !s.hasControllerCapability(csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS) {
+		return nil, status.Error(codes.Unimplemented, "snapshot is not enabled")
+	}
+	ctx = logger.NewContext(ctx, "ListSnapshots", req.GetSourceVolumeId(), "")
+
+	var resp *csi.ListSnapshotsResponse
+	var err error
+	start := time.Now()
+	if s.cfg.Get().IsControllerMode() {
+		resp, err = s.remoteListSnapshots(ctx, req)
+		metrics.ControllerOpObserve("list_snapshots", start, err)
+	} else {
+		resp, err = s.localListSnapshots(ctx, req)
+		metrics.NodeOpObserve("list_snapshots", start, err)
+	}
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Errorf("failed to list snapshots")
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return resp, nil
 }
 
 func (s *Service) ControllerExpandVolume(
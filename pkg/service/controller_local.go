@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -13,7 +15,9 @@ import (
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/modelpack/model-csi-driver/pkg/logger"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
 	"github.com/modelpack/model-csi-driver/pkg/tracing"
+	"github.com/opencontainers/go-digest"
 	"github.com/pkg/errors"
 	"go.opentelemetry.io/otel/attribute"
 	otelCodes "go.opentelemetry.io/otel/codes"
@@ -34,13 +38,22 @@ func (s *Service) localCreateVolume(ctx context.Context, req *csi.CreateVolumeRe
 	modelReference := strings.TrimSpace(parameters[s.cfg.Get().ParameterKeyReference()])
 	mountID := strings.TrimSpace(parameters[s.cfg.Get().ParameterKeyMountID()])
 	checkDiskQuotaParam := strings.TrimSpace(parameters[s.cfg.Get().ParameterKeyCheckDiskQuota()])
-	excludeModelWeightsParam := strings.TrimSpace(parameters[s.cfg.Get().ParameterKeyExcludeModelWeights()])
+	excludeModelWeightsKey, excludeModelWeightsParam := s.excludeModelWeightsParameter(parameters)
 	isStaticVolume := mountID == ""
 
 	if volumeName == "" {
 		return nil, isStaticVolume, status.Error(codes.InvalidArgument, "missing required parameter: volumeName")
 	}
 
+	// The model of the volume cloned from a volume or a snapshot isn't pulled.
+	if req.GetVolumeContentSource() != nil {
+		if !isStaticVolume {
+			return nil, isStaticVolume, status.Error(codes.InvalidArgument, "volume content source is only supported for static volume")
+		}
+		resp, err := s.localCloneVolume(ctx, req)
+		return resp, isStaticVolume, err
+	}
+
 	if modelType == "" {
 		return nil, isStaticVolume, status.Errorf(codes.InvalidArgument, "missing required parameter: %s", s.cfg.Get().ParameterKeyType())
 	}
@@ -49,9 +62,12 @@ func (s *Service) localCreateVolume(ctx context.Context, req *csi.CreateVolumeRe
 		return nil, isStaticVolume, status.Errorf(codes.InvalidArgument, "missing required parameter: %s", s.cfg.Get().ParameterKeyReference())
 	}
 
-	if modelType != "image" {
+	if !isSupportedModelType(modelType) {
 		return nil, isStaticVolume, status.Error(codes.InvalidArgument, fmt.Sprintf("unsupported model type: %s", modelType))
 	}
+	if err := s.admitModel(ctx, modelType, modelReference, volumeName, mountID); err != nil {
+		return nil, isStaticVolume, err
+	}
 	checkDiskQuota := false
 	if checkDiskQuotaParam != "" {
 		var err error
@@ -65,13 +81,85 @@ func (s *Service) localCreateVolume(ctx context.Context, req *csi.CreateVolumeRe
 		var err error
 		excludeModelWeights, err = strconv.ParseBool(excludeModelWeightsParam)
 		if err != nil {
-			return nil, isStaticVolume, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", s.cfg.Get().ParameterKeyExcludeModelWeights(), err)
+			return nil, isStaticVolume, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", excludeModelWeightsKey, err)
+		}
+	}
+	excludeFilePatterns, err := s.filePatternsParameter(parameters)
+	if err != nil {
+		return nil, isStaticVolume, err
+	}
+	adapters, err := s.adaptersParameter(ctx, parameters, modelType, volumeName, mountID)
+	if err != nil {
+		return nil, isStaticVolume, err
+	}
+
+	priority := 0
+	if priorityParam := strings.TrimSpace(parameters[s.cfg.Get().ParameterKeyPriority()]); priorityParam != "" {
+		var err error
+		priority, err = strconv.Atoi(priorityParam)
+		if err != nil {
+			return nil, isStaticVolume, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", s.cfg.Get().ParameterKeyPriority(), err)
+		}
+	}
+	backgroundWeights := false
+	if backgroundWeightsParam := strings.TrimSpace(parameters[s.cfg.Get().ParameterKeyBackgroundWeights()]); backgroundWeightsParam != "" {
+		var err error
+		backgroundWeights, err = strconv.ParseBool(backgroundWeightsParam)
+		if err != nil {
+			return nil, isStaticVolume, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", s.cfg.Get().ParameterKeyBackgroundWeights(), err)
+		}
+	}
+	pullTimeout := time.Duration(0)
+	if pullTimeoutParam := strings.TrimSpace(parameters[s.cfg.Get().ParameterKeyPullTimeoutInSeconds()]); pullTimeoutParam != "" {
+		seconds, err := strconv.ParseUint(pullTimeoutParam, 10, 32)
+		if err != nil {
+			return nil, isStaticVolume, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", s.cfg.Get().ParameterKeyPullTimeoutInSeconds(), err)
 		}
+		pullTimeout = time.Duration(seconds) * time.Second
 	}
-	excludeFilePatterns := []string{}
-	if excludeFilePatternsParam := strings.TrimSpace(parameters[s.cfg.Get().ParameterKeyExcludeFilePatterns()]); excludeFilePatternsParam != "" {
-		if err := json.Unmarshal([]byte(excludeFilePatternsParam), &excludeFilePatterns); err != nil {
-			return nil, isStaticVolume, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", s.cfg.Get().ParameterKeyExcludeFilePatterns(), err)
+	pinned := false
+	if pinnedParam := strings.TrimSpace(parameters[s.cfg.Get().ParameterKeyPinned()]); pinnedParam != "" {
+		var err error
+		pinned, err = strconv.ParseBool(pinnedParam)
+		if err != nil {
+			return nil, isStaticVolume, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", s.cfg.Get().ParameterKeyPinned(), err)
+		}
+	}
+	retainCache := false
+	if retainCacheParam := strings.TrimSpace(parameters[s.cfg.Get().ParameterKeyRetainCache()]); retainCacheParam != "" {
+		var err error
+		retainCache, err = strconv.ParseBool(retainCacheParam)
+		if err != nil {
+			return nil, isStaticVolume, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", s.cfg.Get().ParameterKeyRetainCache(), err)
+		}
+	}
+
+	pullOpts := PullOptions{
+		Type:                modelType,
+		CheckDiskQuota:      checkDiskQuota,
+		ExcludeModelWeights: excludeModelWeights,
+		ExcludeFilePatterns: excludeFilePatterns,
+		Secrets:             req.GetSecrets(),
+		Priority:            priority,
+		BackgroundWeights:   backgroundWeights,
+		Adapters:            adapters,
+		Timeout:             pullTimeout,
+		RetainCache:         retainCache,
+	}
+
+	if len(req.GetMutableParameters()) > 0 {
+		if !s.hasControllerCapability(csi.ControllerServiceCapability_RPC_MODIFY_VOLUME) {
+			return nil, isStaticVolume, status.Error(codes.InvalidArgument, "mutable parameters are not supported, modify volume is not enabled")
+		}
+		if !isStaticVolume {
+			return nil, isStaticVolume, status.Error(codes.InvalidArgument, "mutable parameters are only supported for static volume")
+		}
+	}
+
+	// The pin outlives the volume, it's removed by the pin API.
+	if pinned {
+		if err := s.PinModel(ctx, modelReference); err != nil {
+			return nil, isStaticVolume, err
 		}
 	}
 
@@ -81,27 +169,89 @@ func (s *Service) localCreateVolume(ctx context.Context, req *csi.CreateVolumeRe
 	parentSpan.SetAttributes(attribute.Bool("static_volume", isStaticVolume))
 
 	if isStaticVolume {
+		// The volume created already by the same parameters is returned as
+		// is, the retried CreateVolume doesn't pull the model again.
+		volumeStatus, err := s.createdVolume(ctx, volumeName, modelReference, parameters, pullOpts)
+		if err != nil {
+			return nil, isStaticVolume, err
+		}
+		if volumeStatus != nil {
+			if err := checkCapacityRange(req.GetCapacityRange(), volumeStatus.SizeInBytes); err != nil {
+				return nil, isStaticVolume, err
+			}
+			if len(req.GetMutableParameters()) > 0 {
+				if err := s.modifyVolume(ctx, volumeName, req.GetMutableParameters()); err != nil {
+					return nil, isStaticVolume, err
+				}
+			}
+			logger.WithContext(ctx).Infof("volume is created already with model: %s", volumeStatus.Reference)
+			volumeContext := map[string]string{}
+			if volumeStatus.Digest != "" {
+				volumeContext[s.cfg.Get().ParameterKeyDigest()] = volumeStatus.Digest
+			}
+			if volumeStatus.Platform != "" {
+				volumeContext[s.cfg.Get().ParameterKeyPlatform()] = volumeStatus.Platform
+			}
+			return &csi.CreateVolumeResponse{
+				Volume: &csi.Volume{
+					VolumeId:      volumeName,
+					CapacityBytes: volumeCapacity(req.GetCapacityRange(), volumeStatus.SizeInBytes),
+					VolumeContext: volumeContext,
+				},
+			}, isStaticVolume, nil
+		}
+
 		modelDir := s.cfg.Get().GetModelDir(volumeName)
+		volumeContext, err := s.pinModelDigest(ctx, modelDir, modelReference, parameters, &pullOpts)
+		if err != nil {
+			return nil, isStaticVolume, err
+		}
+		modelSize := int64(-1)
+		if req.GetCapacityRange() != nil {
+			modelSize = s.worker.estimateModelSize(
+				withRegistrySecrets(ctx, pullOpts.Secrets), pinReference(modelReference, pullOpts.Digest), pullOpts,
+			)
+			if err := checkCapacityRange(req.GetCapacityRange(), modelSize); err != nil {
+				return nil, isStaticVolume, err
+			}
+		}
 		startedAt := time.Now()
 		ctx, span := tracing.Tracer.Start(ctx, "PullModel")
 		span.SetAttributes(attribute.String("model_dir", modelDir))
-		if err := s.worker.PullModel(ctx, isStaticVolume, volumeName, "", modelReference, modelDir, checkDiskQuota, excludeModelWeights, excludeFilePatterns); err != nil {
+		if err := s.worker.PullModel(ctx, isStaticVolume, volumeName, "", modelReference, modelDir, pullOpts); err != nil {
 			span.SetStatus(otelCodes.Error, "failed to pull model")
 			span.RecordError(err)
 			span.End()
 			if errors.Is(err, syscall.ENOSPC) {
-				return nil, isStaticVolume, status.Error(codes.ResourceExhausted, errors.Wrap(err, "pull model for static volume").Error())
+				return nil, isStaticVolume, s.pullErrorStatus(codes.ResourceExhausted, errors.Wrap(err, "pull model for static volume").Error(), err)
+			}
+			if errors.Is(err, ErrSignatureVerification) {
+				return nil, isStaticVolume, s.pullErrorStatus(codes.PermissionDenied, errors.Wrap(err, "pull model for static volume").Error(), err)
 			}
-			return nil, isStaticVolume, status.Error(codes.Internal, errors.Wrap(err, "pull model").Error())
+			return nil, isStaticVolume, s.pullErrorStatus(codes.Internal, errors.Wrap(err, "pull model").Error(), err)
 		}
 		span.End()
 		duration := time.Since(startedAt)
 		logger.WithContext(ctx).Infof("pulled model: %s %s", modelReference, duration)
 
+		if len(req.GetMutableParameters()) > 0 {
+			if err := s.modifyVolume(ctx, volumeName, req.GetMutableParameters()); err != nil {
+				return nil, isStaticVolume, err
+			}
+		}
+
+		// The size of the model pulled if it's unknown before the pull.
+		if modelSize < 0 {
+			if volumeStatus, err := s.sm.Get(filepath.Join(s.cfg.Get().GetVolumeDir(volumeName), "status.json")); err == nil {
+				modelSize = volumeStatus.SizeInBytes
+			}
+		}
+
 		return &csi.CreateVolumeResponse{
 			Volume: &csi.Volume{
 				VolumeId:      volumeName,
-				VolumeContext: map[string]string{},
+				CapacityBytes: volumeCapacity(req.GetCapacityRange(), modelSize),
+				VolumeContext: volumeContext,
 			},
 		}, isStaticVolume, nil
 	}
@@ -115,31 +265,268 @@ func (s *Service) localCreateVolume(ctx context.Context, req *csi.CreateVolumeRe
 	}
 
 	modelDir := s.cfg.Get().GetModelDirForDynamic(volumeName, mountID)
+	pullOpts.Replace = isModelReplaced(ctx)
+	labels, err := s.labelsParameter(parameters)
+	if err != nil {
+		return nil, isStaticVolume, err
+	}
+	pullOpts.Labels = labels
+	volumeContext, err := s.pinModelDigest(ctx, modelDir, modelReference, parameters, &pullOpts)
+	if err != nil {
+		return nil, isStaticVolume, err
+	}
 	startedAt := time.Now()
 	ctx, span := tracing.Tracer.Start(ctx, "PullModel")
 	span.SetAttributes(attribute.String("model_dir", modelDir))
-	if err := s.worker.PullModel(ctx, isStaticVolume, volumeName, mountID, modelReference, modelDir, checkDiskQuota, excludeModelWeights, excludeFilePatterns); err != nil {
+	if err := s.worker.PullModel(ctx, isStaticVolume, volumeName, mountID, modelReference, modelDir, pullOpts); err != nil {
 		span.SetStatus(otelCodes.Error, "failed to pull model")
 		span.RecordError(err)
 		span.End()
 		if errors.Is(err, syscall.ENOSPC) {
-			return nil, isStaticVolume, status.Error(codes.ResourceExhausted, errors.Wrap(err, "pull model for dynamic volume").Error())
+			return nil, isStaticVolume, s.pullErrorStatus(codes.ResourceExhausted, errors.Wrap(err, "pull model for dynamic volume").Error(), err)
 		}
-		return nil, isStaticVolume, status.Error(codes.Internal, errors.Wrap(err, "pull model for dynamic volume").Error())
+		if errors.Is(err, ErrSignatureVerification) {
+			return nil, isStaticVolume, s.pullErrorStatus(codes.PermissionDenied, errors.Wrap(err, "pull model for dynamic volume").Error(), err)
+		}
+		if errors.Is(err, ErrConflict) {
+			return nil, isStaticVolume, s.pullErrorStatus(codes.AlreadyExists, errors.Wrap(err, "pull model for dynamic volume").Error(), err)
+		}
+		return nil, isStaticVolume, s.pullErrorStatus(codes.Internal, errors.Wrap(err, "pull model for dynamic volume").Error(), err)
 	}
 	span.End()
 	duration := time.Since(startedAt)
 	logger.WithContext(ctx).Infof("pulled model: %s, mount id: %s %s", modelReference, mountID, duration)
+
 	volumeID := fmt.Sprintf("%s/%s", volumeName, mountID)
 
 	return &csi.CreateVolumeResponse{
 		Volume: &csi.Volume{
 			VolumeId:      volumeID,
-			VolumeContext: map[string]string{},
+			VolumeContext: volumeContext,
 		},
 	}, isStaticVolume, nil
 }
 
+// createdVolume returns the status of the static volume created already by
+// the same parameters, or nil if the model of the volume is to be pulled,
+// e.g. the volume doesn't exist or its pull is retried. The volume created
+// by other parameters is rejected with AlreadyExists as the CSI spec
+// requires, instead of being pulled again with the model requested.
+func (s *Service) createdVolume(ctx context.Context, volumeName, reference string, parameters map[string]string, opts PullOptions) (*modelStatus.Status, error) {
+	contextKey := fmt.Sprintf("%s/", volumeName)
+	if err := s.worker.kmutex.Lock(ctx, contextKey); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrapf(err, "lock context key: %s", contextKey).Error())
+	}
+	defer s.worker.kmutex.Unlock(contextKey)
+
+	volumeStatus, err := s.sm.Get(filepath.Join(s.cfg.Get().GetVolumeDir(volumeName), "status.json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "get volume status").Error())
+	}
+
+	dgst := strings.TrimSpace(parameters[s.cfg.Get().ParameterKeyDigest()])
+	platform := strings.TrimSpace(parameters[s.cfg.Get().ParameterKeyPlatform()])
+	if !isCompatibleVolume(volumeStatus, reference, dgst, platform, opts) {
+		return nil, status.Errorf(codes.AlreadyExists, "volume %s exists with another model: %s", volumeName, volumeStatus.Reference)
+	}
+	if !isScrubbable(volumeStatus) && volumeStatus.State != modelStatus.StateWeightsPulling {
+		return nil, nil
+	}
+	if missingModelContent(s.cfg.Get().GetModelDir(volumeName), volumeStatus) != "" {
+		return nil, nil
+	}
+
+	return volumeStatus, nil
+}
+
+// isCompatibleVolume returns whether the volume is created by the same
+// parameters, the digest and the platform resolved for the volume are
+// compared only if they're set by the parameters, and the secrets aren't
+// compared so that the rotated credentials don't conflict.
+func isCompatibleVolume(volumeStatus *modelStatus.Status, reference, dgst, platform string, opts PullOptions) bool {
+	if volumeStatus.ClonedFrom != "" {
+		return false
+	}
+	statusReference := volumeStatus.Reference
+	if isImageModelType(opts.Type) {
+		reference = normalizeImageReference(reference)
+		statusReference = normalizeImageReference(statusReference)
+	}
+	if statusReference != reference {
+		return false
+	}
+	if dgst != "" && volumeStatus.Digest != "" && dgst != volumeStatus.Digest {
+		return false
+	}
+	if platform != "" && volumeStatus.Platform != "" && platform != volumeStatus.Platform {
+		return false
+	}
+	return volumeStatus.ExcludeModelWeights == opts.ExcludeModelWeights &&
+		slices.Equal(volumeStatus.ExcludeFilePatterns, opts.ExcludeFilePatterns) &&
+		slices.Equal(volumeStatus.Adapters, opts.Adapters)
+}
+
+// excludeModelWeightsParameter returns the key and value of the parameter
+// excluding the model weights, either the exclude-model-weights or its
+// alias exclude-weights.
+func (s *Service) excludeModelWeightsParameter(parameters map[string]string) (string, string) {
+	for _, key := range []string{s.cfg.Get().ParameterKeyExcludeModelWeights(), s.cfg.Get().ParameterKeyExcludeWeights()} {
+		if value := strings.TrimSpace(parameters[key]); value != "" {
+			return key, value
+		}
+	}
+	return s.cfg.Get().ParameterKeyExcludeModelWeights(), ""
+}
+
+// filePatternsParameter returns the exclude file patterns of the pull, with
+// the include file patterns turned into the patterns excluding all the other
+// files, so that the pullers filter the files by the exclude patterns only.
+func (s *Service) filePatternsParameter(parameters map[string]string) ([]string, error) {
+	parse := func(key string) ([]string, error) {
+		patterns := []string{}
+		if param := strings.TrimSpace(parameters[key]); param != "" {
+			if err := json.Unmarshal([]byte(param), &patterns); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", key, err)
+			}
+		}
+		if err := validateFilePatterns(patterns); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", key, err)
+		}
+		return patterns, nil
+	}
+
+	excludeFilePatterns, err := parse(s.cfg.Get().ParameterKeyExcludeFilePatterns())
+	if err != nil {
+		return nil, err
+	}
+	includeFilePatterns, err := parse(s.cfg.Get().ParameterKeyIncludeFilePatterns())
+	if err != nil {
+		return nil, err
+	}
+	for _, pattern := range includeFilePatterns {
+		if strings.HasPrefix(strings.TrimSpace(pattern), "!") {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: negated pattern: %s", s.cfg.Get().ParameterKeyIncludeFilePatterns(), pattern)
+		}
+	}
+
+	return withIncludeFilePatterns(includeFilePatterns, excludeFilePatterns), nil
+}
+
+// adaptersParameter returns the adapters of the composite mount, which are
+// admitted by the policy like the base model.
+func (s *Service) adaptersParameter(ctx context.Context, parameters map[string]string, modelType, volumeName, mountID string) ([]modelStatus.Adapter, error) {
+	key := s.cfg.Get().ParameterKeyAdapters()
+	adapters := []modelStatus.Adapter{}
+	if param := strings.TrimSpace(parameters[key]); param != "" {
+		if err := json.Unmarshal([]byte(param), &adapters); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", key, err)
+		}
+	}
+	adapters, err := validateAdapters(modelType, adapters)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", key, err)
+	}
+	for _, adapter := range adapters {
+		if err := s.admitModel(ctx, adapter.Type, adapter.Reference, volumeName, mountID); err != nil {
+			return nil, err
+		}
+	}
+
+	return adapters, nil
+}
+
+// pinModelDigest resolves the tag of the image reference to the digest once
+// and pins the pull to it, the digest is reused from the digest parameter or
+// the status of the volume pulled before, so that the re-created volume gets
+// the identical model even if the tag moves. The image index is resolved to
+// the manifest of the platform parameter, or the node platform by default.
+// It returns the volume context holding the digest and the platform.
+func (s *Service) pinModelDigest(ctx context.Context, modelDir, reference string, parameters map[string]string, opts *PullOptions) (map[string]string, error) {
+	volumeContext := map[string]string{}
+	if !isImageModelType(opts.Type) {
+		return volumeContext, nil
+	}
+
+	platform := strings.TrimSpace(parameters[s.cfg.Get().ParameterKeyPlatform()])
+	if platform != "" {
+		if _, err := parsePlatform(platform); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", s.cfg.Get().ParameterKeyPlatform(), err)
+		}
+	}
+	// The platform of the manifest selected from the image index.
+	selectedPlatform := ""
+
+	dgst := strings.TrimSpace(parameters[s.cfg.Get().ParameterKeyDigest()])
+	if dgst != "" {
+		selectedPlatform = platform
+	} else if !opts.Replace {
+		// The model replaced is resolved again, e.g. the tag moved.
+		statusPath := filepath.Join(filepath.Dir(modelDir), "status.json")
+		if modelStatus, err := s.worker.sm.Get(statusPath); err == nil && modelStatus.Reference == reference &&
+			(platform == "" || modelStatus.Platform == "" || modelStatus.Platform == platform) {
+			dgst = modelStatus.Digest
+			selectedPlatform = modelStatus.Platform
+		}
+	}
+	if dgst == "" {
+		if platform == "" {
+			platform = defaultPlatform()
+		}
+		resolved, err := ResolveDigest(withRegistrySecrets(ctx, opts.Secrets), &s.cfg.Get().PullConfig, reference, platform)
+		if err != nil {
+			return nil, status.Error(codes.Internal, errors.Wrap(err, "resolve model digest").Error())
+		}
+		dgst = resolved.Digest.String()
+		if resolved.Platform != nil {
+			selectedPlatform = formatPlatform(resolved.Platform)
+		}
+		logger.WithContext(ctx).Infof("resolved model %s to digest %s", reference, dgst)
+	}
+	if _, err := digest.Parse(dgst); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", s.cfg.Get().ParameterKeyDigest(), err)
+	}
+
+	opts.Digest = dgst
+	opts.Platform = selectedPlatform
+	volumeContext[s.cfg.Get().ParameterKeyDigest()] = dgst
+	if selectedPlatform != "" {
+		volumeContext[s.cfg.Get().ParameterKeyPlatform()] = selectedPlatform
+	}
+
+	return volumeContext, nil
+}
+
+// checkCapacityRange fails the volume whose model is larger than the
+// capacity range of the request, i.e. the limit bytes, or the required bytes
+// (the storage requested by the PVC) if no limit is set. The model of the
+// unknown size (-1) isn't checked.
+func checkCapacityRange(capacityRange *csi.CapacityRange, modelSize int64) error {
+	limit := capacityRange.GetLimitBytes()
+	if limit == 0 {
+		limit = capacityRange.GetRequiredBytes()
+	}
+	if limit > 0 && modelSize > limit {
+		return status.Errorf(codes.OutOfRange, "model size %d exceeds the capacity of volume: %d", modelSize, limit)
+	}
+	return nil
+}
+
+// volumeCapacity returns the model size as the capacity of the volume within
+// the capacity range, as the capacity isn't allowed to be less than the
+// required bytes or more than the limit bytes.
+func volumeCapacity(capacityRange *csi.CapacityRange, modelSize int64) int64 {
+	if required := capacityRange.GetRequiredBytes(); modelSize < required {
+		return required
+	}
+	if limit := capacityRange.GetLimitBytes(); limit > 0 && modelSize > limit {
+		return limit
+	}
+	return max(modelSize, 0)
+}
+
 func (s *Service) localDeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, bool, error) {
 	volumeID := req.GetVolumeId()
 	if volumeID == "" {
@@ -180,41 +567,113 @@ func (s *Service) localDeleteVolume(ctx context.Context, req *csi.DeleteVolumeRe
 	return nil, isStaticVolume, status.Error(codes.InvalidArgument, "invalid volumeId format")
 }
 
+// modifyVolume applies the mutable parameters to the status of the static
+// volume, the changes take effect on the next NodePublishVolume of the volume.
+// The dynamic and inline volumes are not supported as their mounts never
+// honor the mutable parameters.
+func (s *Service) modifyVolume(ctx context.Context, volumeName string, parameters map[string]string) error {
+	if !isStaticVolume(volumeName) {
+		return status.Errorf(codes.InvalidArgument, "modify volume is only supported for static volume: %s", volumeName)
+	}
+	statusPath := filepath.Join(s.cfg.Get().GetVolumeDir(volumeName), "status.json")
+
+	// Avoid racing with the status updates of pulling model.
+	contextKey := fmt.Sprintf("%s/", volumeName)
+	if err := s.worker.kmutex.Lock(ctx, contextKey); err != nil {
+		return status.Error(codes.Internal, errors.Wrapf(err, "lock context key: %s", contextKey).Error())
+	}
+	defer s.worker.kmutex.Unlock(contextKey)
+
+	volumeStatus, err := s.sm.Get(statusPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return status.Errorf(codes.NotFound, "volume not found: %s", volumeName)
+		}
+		return status.Error(codes.Internal, errors.Wrap(err, "get volume status").Error())
+	}
+	if volumeStatus.Inline {
+		return status.Errorf(codes.InvalidArgument, "modify volume is not supported for inline volume: %s", volumeName)
+	}
+
+	for key, value := range parameters {
+		switch key {
+		case s.cfg.Get().ParameterKeyReadOnly():
+			readOnly, err := strconv.ParseBool(strings.TrimSpace(value))
+			if err != nil {
+				return status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", key, err)
+			}
+			volumeStatus.ReadOnly = readOnly
+		default:
+			return status.Errorf(codes.InvalidArgument, "unsupported mutable parameter: %s", key)
+		}
+	}
+
+	if _, err := s.sm.Set(statusPath, *volumeStatus); err != nil {
+		return status.Error(codes.Internal, errors.Wrap(err, "set volume status").Error())
+	}
+
+	logger.WithContext(ctx).Infof("modified volume with mutable parameters: %v", parameters)
+
+	return nil
+}
+
+func (s *Service) localModifyVolume(ctx context.Context, req *csi.ControllerModifyVolumeRequest) (*csi.ControllerModifyVolumeResponse, bool, error) {
+	volumeID := req.GetVolumeId()
+	if volumeID == "" {
+		return nil, true, status.Error(codes.InvalidArgument, "missing required parameter: volumeID")
+	}
+
+	volumeIDs := strings.Split(volumeID, "/")
+	isStaticVolume := len(volumeIDs) == 1
+
+	parentSpan := trace.SpanFromContext(ctx)
+	parentSpan.SetAttributes(attribute.Bool("static_volume", isStaticVolume))
+
+	if !isStaticVolume {
+		return nil, isStaticVolume, status.Errorf(codes.InvalidArgument, "modify volume is only supported for static volume: %s", volumeID)
+	}
+	parentSpan.SetAttributes(attribute.String("volume_name", volumeID))
+
+	if err := s.modifyVolume(ctx, volumeID, req.GetMutableParameters()); err != nil {
+		return nil, isStaticVolume, err
+	}
+
+	return &csi.ControllerModifyVolumeResponse{}, isStaticVolume, nil
+}
+
+// localGetCapacity returns the disk quota left for another model on the
+// node, by disk_usage_limit if it's set, otherwise by the file system, less
+// the size reserved by the in-flight pulls.
+func (s *Service) localGetCapacity(
+	ctx context.Context,
+	req *csi.GetCapacityRequest) (
+	*csi.GetCapacityResponse, error) {
+	usedSize, totalSize, err := diskUsage(s.cfg.Get())
+	if err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "get disk usage").Error())
+	}
+	availSize := max(totalSize-usedSize-s.worker.reservations.Pending(), 0)
+
+	return &csi.GetCapacityResponse{
+		AvailableCapacity: availSize,
+	}, nil
+}
+
+// localListVolumes lists the volumes hosted by the node, including the mounts
+// of the dynamic volumes as $volumeName/$mountID, sorted by the volume ID and
+// paginated by the index of the entry as the starting token.
 // nolint
 func (s *Service) localListVolumes(
 	ctx context.Context,
 	req *csi.ListVolumesRequest) (
 	*csi.ListVolumesResponse, error) {
-	volumesDir := s.cfg.Get().GetVolumesDir()
-
-	getEntryByVolumeName := func(volumeName string) (*csi.ListVolumesResponse_Entry, error) {
-		statusPath := filepath.Join(volumesDir, volumeName, "status.json")
-		modelStatus, err := s.sm.Get(statusPath)
-		if err != nil {
-			if errors.Is(err, os.ErrNotExist) {
-				return nil, err
-			}
-			logger.WithContext(ctx).WithError(err).Errorf("failed to get volume status")
-			return nil, status.Error(codes.Internal, err.Error())
-		}
-		progress := s.worker.sm.HookManager.GetProgress(statusPath)
-		progressStr, err := progress.String()
-		if err != nil {
-			logger.WithContext(ctx).WithError(err).Errorf("failed to marshal progress")
-			return nil, status.Error(codes.Internal, err.Error())
-		}
-		return &csi.ListVolumesResponse_Entry{
-			Volume: &csi.Volume{
-				VolumeId: modelStatus.VolumeName,
-				VolumeContext: map[string]string{
-					s.cfg.Get().ParameterKeyReference():      modelStatus.Reference,
-					s.cfg.Get().ParameterKeyStatusState():    modelStatus.State,
-					s.cfg.Get().ParameterKeyStatusProgress(): progressStr,
-				},
-			},
-		}, nil
+	start, err := parseStartingToken(req.GetStartingToken(), req.GetMaxEntries())
+	if err != nil {
+		return nil, err
 	}
 
+	volumesDir := s.cfg.Get().GetVolumesDir()
+
 	volumeDirEntries, err := os.ReadDir(volumesDir)
 	if err != nil {
 		logger.WithContext(ctx).WithError(err).Errorf("failed to read volumes dir")
@@ -222,23 +681,137 @@ func (s *Service) localListVolumes(
 	}
 
 	entries := []*csi.ListVolumesResponse_Entry{}
+	appendEntry := func(volumeID, volumeDir string) error {
+		volume, _, err := s.getLocalVolume(ctx, volumeID, volumeDir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			logger.WithContext(ctx).WithError(err).Errorf("failed to get entry for volume: %s", volumeID)
+			return err
+		}
+		entries = append(entries, &csi.ListVolumesResponse_Entry{Volume: volume})
+		return nil
+	}
 	for _, entry := range volumeDirEntries {
-		if !entry.IsDir() {
+		// The prefetched models aren't CSI volumes.
+		if !isVolumeDirEntry(volumesDir, entry) || isPrefetchVolume(entry.Name()) {
 			continue
 		}
 		volumeName := entry.Name()
-		entry, err := getEntryByVolumeName(volumeName)
+		if err := appendEntry(volumeName, filepath.Join(volumesDir, volumeName)); err != nil {
+			return nil, err
+		}
+		if !isDynamicVolume(volumeName) {
+			continue
+		}
+		modelsDir := s.cfg.Get().GetModelsDirForDynamic(volumeName)
+		mountDirs, err := os.ReadDir(modelsDir)
 		if err != nil {
-			if errors.Is(err, os.ErrNotExist) {
+			if os.IsNotExist(err) {
 				continue
 			}
-			logger.WithContext(ctx).WithError(err).Errorf("failed to get entry for volume: %s", volumeName)
-			return nil, err
+			logger.WithContext(ctx).WithError(err).Errorf("failed to read models dir: %s", modelsDir)
+			return nil, status.Error(codes.Internal, err.Error())
 		}
-		entries = append(entries, entry)
+		for _, mountDir := range mountDirs {
+			if !isVolumeDirEntry(modelsDir, mountDir) {
+				continue
+			}
+			volumeID := fmt.Sprintf("%s/%s", volumeName, mountDir.Name())
+			if err := appendEntry(volumeID, filepath.Join(modelsDir, mountDir.Name())); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Volume.VolumeId < entries[j].Volume.VolumeId
+	})
+
+	entries, nextToken, err := paginateEntries(entries, start, int(req.GetMaxEntries()))
+	if err != nil {
+		return nil, err
 	}
 
 	return &csi.ListVolumesResponse{
-		Entries: entries,
+		Entries:   entries,
+		NextToken: nextToken,
 	}, nil
 }
+
+// resolveVolumeDir returns the volume dir and the model dir of the volume ID,
+// $volumeName for the static volume and $volumeName/$mountID for the mount of
+// the dynamic volume.
+func (s *Service) resolveVolumeDir(volumeID string) (string, string, error) {
+	cfg := s.cfg.Get()
+	volumeIDs := strings.Split(volumeID, "/")
+	switch {
+	case len(volumeIDs) == 1 && filepath.IsLocal(volumeID) && !isPrefetchVolume(volumeID):
+		return cfg.GetVolumeDir(volumeID), cfg.GetModelDir(volumeID), nil
+	case len(volumeIDs) == 2 && isDynamicVolume(volumeIDs[0]) && filepath.IsLocal(volumeIDs[1]):
+		return cfg.GetMountIDDirForDynamic(volumeIDs[0], volumeIDs[1]), cfg.GetModelDirForDynamic(volumeIDs[0], volumeIDs[1]), nil
+	}
+	return "", "", status.Errorf(codes.InvalidArgument, "invalid volume id: %s", volumeID)
+}
+
+// getLocalVolume returns the volume of the volume dir with the reference, the
+// state and the pull progress in the volume context, the error wraps
+// os.ErrNotExist if the volume has no status.
+func (s *Service) getLocalVolume(ctx context.Context, volumeID, volumeDir string) (*csi.Volume, *modelStatus.Status, error) {
+	statusPath := filepath.Join(volumeDir, "status.json")
+	volumeStatus, err := s.sm.Get(statusPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, err
+		}
+		logger.WithContext(ctx).WithError(err).Errorf("failed to get volume status")
+		return nil, nil, status.Error(codes.Internal, err.Error())
+	}
+	progress := s.worker.sm.HookManager.GetProgress(statusPath)
+	progressStr, err := progress.String()
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Errorf("failed to marshal progress")
+		return nil, nil, status.Error(codes.Internal, err.Error())
+	}
+	return &csi.Volume{
+		VolumeId: volumeID,
+		// The disk usage of the model files once pulled.
+		CapacityBytes: volumeStatus.SizeInBytes,
+		VolumeContext: map[string]string{
+			s.cfg.Get().ParameterKeyReference():      volumeStatus.Reference,
+			s.cfg.Get().ParameterKeyStatusState():    volumeStatus.State,
+			s.cfg.Get().ParameterKeyStatusProgress(): progressStr,
+		},
+	}, volumeStatus, nil
+}
+
+// parseStartingToken returns the index of the entry the page of the list
+// starts from.
+func parseStartingToken(token string, maxEntries int32) (int, error) {
+	if maxEntries < 0 {
+		return 0, status.Errorf(codes.InvalidArgument, "invalid max entries: %d", maxEntries)
+	}
+	if token == "" {
+		return 0, nil
+	}
+	start, err := strconv.Atoi(token)
+	if err != nil || start < 0 {
+		return 0, status.Errorf(codes.Aborted, "invalid starting token: %s", token)
+	}
+	return start, nil
+}
+
+// paginateEntries returns the page of the sorted entries from the start, with
+// the index of the next page as the next token.
+func paginateEntries[T any](entries []T, start, maxEntries int) ([]T, string, error) {
+	if start > len(entries) {
+		return nil, "", status.Errorf(codes.Aborted, "invalid starting token: %d", start)
+	}
+	entries = entries[start:]
+	nextToken := ""
+	if maxEntries > 0 && len(entries) > maxEntries {
+		entries = entries[:maxEntries]
+		nextToken = strconv.Itoa(start + maxEntries)
+	}
+	return entries, nextToken, nil
+}
@@ -3,6 +3,11 @@ package service
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
@@ -16,6 +21,7 @@ import (
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/modelpack/model-csi-driver/pkg/logger"
@@ -25,6 +31,31 @@ import (
 
 const authTokenKey = "authorization"
 
+const (
+	// The timeout to list the volumes or the snapshots of a node.
+	nodeListTimeout = 30 * time.Second
+	// The number of the nodes the volumes or the snapshots are listed from
+	// concurrently.
+	nodeListConcurrency = 16
+	// The number of the volumes or the snapshots listed from a node by a
+	// request.
+	nodeListPageSize = 500
+)
+
+// listNodeVolumes returns all the volumes hosted by the node.
+var listNodeVolumes = func(ctx context.Context, s *Service, nodeInfo *nodeInfo) ([]*csi.ListVolumesResponse_Entry, error) {
+	ctx, cancel := context.WithTimeout(ctx, nodeListTimeout)
+	defer cancel()
+	return s.callNodeListVolumes(ctx, nodeInfo)
+}
+
+// listNodeSnapshots returns all the snapshots of the node matching the request.
+var listNodeSnapshots = func(ctx context.Context, s *Service, nodeInfo *nodeInfo, req *csi.ListSnapshotsRequest) ([]*csi.ListSnapshotsResponse_Entry, error) {
+	ctx, cancel := context.WithTimeout(ctx, nodeListTimeout)
+	defer cancel()
+	return s.callNodeListSnapshots(ctx, nodeInfo, req)
+}
+
 var kacp = keepalive.ClientParameters{
 	Time:                30 * time.Second, // send pings every 30 seconds if there is no activity
 	Timeout:             10 * time.Second, // wait 10 second for ping ack before considering the connection dead
@@ -57,6 +88,71 @@ func (s *Service) getNodeInfoByName(ctx context.Context, nodeName string) (*node
 	return nodeInfo, nil
 }
 
+// topologyHostnames returns the hostnames of the kubernetes.io/hostname
+// segments of the topologies, the topologies without it are skipped.
+func topologyHostnames(topologies []*csi.Topology) []string {
+	hostnames := []string{}
+	for _, topology := range topologies {
+		if hostname := topology.GetSegments()[labelHostname]; hostname != "" && !slices.Contains(hostnames, hostname) {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	return hostnames
+}
+
+// selectNode returns the node to create the volume on, i.e. the node selected
+// by the scheduler for the PVC of WaitForFirstConsumer, which must be in the
+// requisite topologies if any. Otherwise, e.g. for the PVC of Immediate
+// binding with the allowedTopologies of the StorageClass, it's the first node
+// found in the preferred topologies of the accessibility requirements, then
+// in the requisite ones, which are rotated by the volume name to spread the
+// volumes across the nodes.
+func (s *Service) selectNode(ctx context.Context, volumeName, nodeName string, requirements *csi.TopologyRequirement) (*nodeInfo, error) {
+	requisite := topologyHostnames(requirements.GetRequisite())
+
+	if nodeName != "" {
+		nodeInfo, err := s.getNodeInfoByName(ctx, nodeName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get node IP by name: %s", nodeName)
+		}
+		if len(requisite) > 0 && !slices.Contains(requisite, nodeInfo.hostname) {
+			return nil, status.Errorf(
+				codes.ResourceExhausted, "selected node %s isn't in the requisite topologies: %s", nodeName, strings.Join(requisite, ", "),
+			)
+		}
+		return nodeInfo, nil
+	}
+
+	if len(requisite) > 0 {
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(volumeName))
+		offset := int(hash.Sum32() % uint32(len(requisite)))
+		requisite = slices.Concat(requisite[offset:], requisite[:offset])
+	}
+	hostnames := topologyHostnames(requirements.GetPreferred())
+	for _, hostname := range requisite {
+		if !slices.Contains(hostnames, hostname) {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	if len(hostnames) == 0 {
+		return nil, status.Errorf(
+			codes.InvalidArgument, "empty annotation %s in PVC and no %s in accessibility requirements", annotationSelectedNode, labelHostname,
+		)
+	}
+
+	for _, hostname := range hostnames {
+		nodeInfo, err := s.getNodeInfoByHostname(ctx, hostname)
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("skip node in accessibility requirements: %s", hostname)
+			continue
+		}
+		return nodeInfo, nil
+	}
+
+	return nil, status.Errorf(codes.ResourceExhausted, "no node available in accessibility requirements: %s", strings.Join(hostnames, ", "))
+}
+
 func (s *Service) remoteCreateVolume(
 	ctx context.Context,
 	req *csi.CreateVolumeRequest) (
@@ -66,24 +162,26 @@ func (s *Service) remoteCreateVolume(
 		parameters = map[string]string{}
 	}
 
-	nodeName := parameters[annotationSelectedNode]
-	if nodeName == "" {
-		return nil, status.Errorf(codes.InvalidArgument, "empty annotation %s in PVC", annotationSelectedNode)
-	}
-
 	parentSpan := trace.SpanFromContext(ctx)
-	parentSpan.SetAttributes(attribute.String("node_name", nodeName))
+	if nodeName := parameters[annotationSelectedNode]; nodeName != "" {
+		parentSpan.SetAttributes(attribute.String("node_name", nodeName))
+	}
 
-	_, span := tracing.Tracer.Start(ctx, "GetNodeInfoByName")
-	nodeInfo, err := s.getNodeInfoByName(ctx, nodeName)
+	_, span := tracing.Tracer.Start(ctx, "SelectNode")
+	nodeInfo, err := s.selectNode(ctx, req.GetName(), parameters[annotationSelectedNode], req.GetAccessibilityRequirements())
 	if err != nil {
-		span.SetStatus(otelCodes.Error, "failed to get node info")
+		span.SetStatus(otelCodes.Error, "failed to select node")
 		span.RecordError(err)
 		span.End()
-		return nil, errors.Wrapf(err, "get node IP by name: %s", nodeName)
+		return nil, err
 	}
 	span.End()
 
+	contentSource, err := s.nodeContentSource(ctx, req.GetVolumeContentSource(), nodeInfo)
+	if err != nil {
+		return nil, err
+	}
+
 	volumeName := req.GetName()
 	parameters[s.cfg.Get().ParameterVolumeContextNodeIP()] = nodeInfo.ip
 
@@ -108,18 +206,33 @@ func (s *Service) remoteCreateVolume(
 
 	client := csi.NewControllerClient(conn)
 	resp, err := client.CreateVolume(ctx, &csi.CreateVolumeRequest{
-		Name:       volumeName,
-		Parameters: parameters,
+		Name:                volumeName,
+		CapacityRange:       req.GetCapacityRange(),
+		Parameters:          parameters,
+		MutableParameters:   req.GetMutableParameters(),
+		Secrets:             req.GetSecrets(),
+		VolumeContentSource: contentSource,
 	})
 	if err != nil {
 		return nil, errors.Wrapf(err, "call grpc server: %s", addr)
 	}
+	// The capacity returned by the node is of the model size, it's the
+	// required bytes if the node doesn't return it.
+	capacityBytes := resp.GetVolume().GetCapacityBytes()
+	if capacityBytes == 0 {
+		capacityBytes = req.GetCapacityRange().GetRequiredBytes()
+	}
+	// Keep the volume context set by the node, e.g. the pinned digest.
+	for key, value := range resp.GetVolume().GetVolumeContext() {
+		parameters[key] = value
+	}
 
 	return &csi.CreateVolumeResponse{
 		Volume: &csi.Volume{
 			VolumeId:      resp.GetVolume().GetVolumeId(),
-			CapacityBytes: req.GetCapacityRange().GetRequiredBytes(),
+			CapacityBytes: capacityBytes,
 			VolumeContext: parameters,
+			ContentSource: req.GetVolumeContentSource(),
 			AccessibleTopology: []*csi.Topology{
 				{
 					Segments: map[string]string{
@@ -131,40 +244,104 @@ func (s *Service) remoteCreateVolume(
 	}, nil
 }
 
-func (s *Service) remoteDeleteVolume(
-	ctx context.Context,
-	req *csi.DeleteVolumeRequest) (
-	*csi.DeleteVolumeResponse, error) {
-	parameters := req.GetSecrets()
-	if parameters == nil {
-		parameters = map[string]string{}
+// nodeContentSource returns the content source of the volume for the node the
+// volume is created on, the volume is cloned on the node, so the source
+// volume or snapshot must be on the same node.
+func (s *Service) nodeContentSource(ctx context.Context, source *csi.VolumeContentSource, nodeInfo *nodeInfo) (*csi.VolumeContentSource, error) {
+	if source == nil {
+		return nil, nil
 	}
 
-	nodeName := parameters[annotationSelectedNode]
+	hostname := ""
+	nodeSource := &csi.VolumeContentSource{}
+	if snapshot := source.GetSnapshot(); snapshot != nil {
+		var snapshotID string
+		var ok bool
+		hostname, snapshotID, ok = splitRemoteSnapshotID(snapshot.GetSnapshotId())
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid snapshot id: %s", snapshot.GetSnapshotId())
+		}
+		nodeSource.Type = &csi.VolumeContentSource_Snapshot{
+			Snapshot: &csi.VolumeContentSource_SnapshotSource{SnapshotId: snapshotID},
+		}
+	} else {
+		volumeID := source.GetVolume().GetVolumeId()
+		if volumeID == "" {
+			return nil, status.Error(codes.InvalidArgument, "missing volume content source")
+		}
+		sourceNodeInfo, err := s.getNodeInfoByVolume(ctx, volumeID)
+		if err != nil {
+			return nil, status.Error(codes.NotFound, errors.Wrapf(err, "get node IP by volume: %s", volumeID).Error())
+		}
+		hostname = sourceNodeInfo.hostname
+		nodeSource.Type = &csi.VolumeContentSource_Volume{
+			Volume: &csi.VolumeContentSource_VolumeSource{VolumeId: volumeID},
+		}
+	}
+	if hostname != nodeInfo.hostname {
+		return nil, status.Errorf(
+			codes.FailedPrecondition, "volume content source is on node %s instead of the selected node %s", hostname, nodeInfo.hostname,
+		)
+	}
+
+	return nodeSource, nil
+}
+
+// getVolumeNode returns the node of the volume to delete, i.e. the selected
+// node of its PVC, or the node of its PV for the volume created by the
+// accessibility requirements without the selected node. It returns nil if the
+// node is gone.
+func (s *Service) getVolumeNode(ctx context.Context, nodeName, volumeID string) (*nodeInfo, error) {
 	if nodeName == "" {
-		return nil, status.Errorf(codes.InvalidArgument, "empty annotation %s in PVC", annotationSelectedNode)
+		nodeInfo, err := s.getNodeInfoByVolume(ctx, volumeID)
+		if err != nil {
+			if errors.Is(err, errNodeNotFound) {
+				logger.WithContext(ctx).WithError(err).Warnf("node of volume %s not found, return success for deleting volume", volumeID)
+				return nil, nil
+			}
+			return nil, errors.Wrapf(err, "get node IP by volume: %s", volumeID)
+		}
+		return nodeInfo, nil
 	}
+
 	_, span := tracing.Tracer.Start(ctx, "GetNodeInfoByName")
 	span.SetAttributes(attribute.String("node_name", nodeName))
+	defer span.End()
 	nodeInfo, err := s.getNodeInfoByName(ctx, nodeName)
 	if err != nil {
 		span.SetStatus(otelCodes.Error, "failed to get node info")
 		span.RecordError(err)
-		span.End()
-		// If node not found, we just return success to avoid orphaned volume.
 		if apierrors.IsNotFound(err) {
 			logger.WithContext(ctx).WithError(err).Warnf("node %s not found, return success for deleting volume", nodeName)
-			return &csi.DeleteVolumeResponse{}, nil
+			return nil, nil
 		}
 		return nil, errors.Wrapf(err, "get node IP by name: %s", nodeName)
 	}
-	span.End()
-	nodeIP := nodeInfo.ip
+	return nodeInfo, nil
+}
+
+func (s *Service) remoteDeleteVolume(
+	ctx context.Context,
+	req *csi.DeleteVolumeRequest) (
+	*csi.DeleteVolumeResponse, error) {
+	parameters := req.GetSecrets()
+	if parameters == nil {
+		parameters = map[string]string{}
+	}
 
 	volumeID := req.GetVolumeId()
 	if volumeID == "" {
 		return nil, status.Error(codes.InvalidArgument, "empty volumeId")
 	}
+	nodeInfo, err := s.getVolumeNode(ctx, parameters[annotationSelectedNode], volumeID)
+	if err != nil {
+		return nil, err
+	}
+	// If node not found, we just return success to avoid orphaned volume.
+	if nodeInfo == nil {
+		return &csi.DeleteVolumeResponse{}, nil
+	}
+	nodeIP := nodeInfo.ip
 
 	parentSpan := trace.SpanFromContext(ctx)
 	parentSpan.SetAttributes(attribute.String("volume_name", volumeID))
@@ -196,9 +373,514 @@ func (s *Service) remoteDeleteVolume(
 	return resp, nil
 }
 
+func (s *Service) remoteModifyVolume(
+	ctx context.Context,
+	req *csi.ControllerModifyVolumeRequest) (
+	*csi.ControllerModifyVolumeResponse, error) {
+	volumeID := req.GetVolumeId()
+	if volumeID == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty volumeId")
+	}
+
+	// The modify request doesn't carry the selected node of the PVC, resolve
+	// the node from the topology of the PV instead.
+	_, span := tracing.Tracer.Start(ctx, "GetNodeInfoByVolume")
+	nodeInfo, err := s.getNodeInfoByVolume(ctx, volumeID)
+	if err != nil {
+		span.SetStatus(otelCodes.Error, "failed to get node info")
+		span.RecordError(err)
+		span.End()
+		return nil, errors.Wrapf(err, "get node IP by volume: %s", volumeID)
+	}
+	span.SetAttributes(attribute.String("node_hostname", nodeInfo.hostname))
+	span.End()
+	nodeIP := nodeInfo.ip
+
+	parentSpan := trace.SpanFromContext(ctx)
+	parentSpan.SetAttributes(attribute.String("volume_name", volumeID))
+	parentSpan.SetAttributes(attribute.String("node_ip", nodeIP))
+
+	addr := fmt.Sprintf("%s:%s", nodeIP, s.remoteGRPCPort)
+	logger.WithContext(ctx).Infof("calling remote grpc: %s", addr)
+
+	conn, err := grpc.NewClient(
+		addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithKeepaliveParams(kacp),
+		grpc.WithUnaryInterceptor(s.tokenAuthInterceptor),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connect to grpc server: %s", addr)
+	}
+	defer func() { _ = conn.Close() }()
+
+	client := csi.NewControllerClient(conn)
+	resp, err := client.ControllerModifyVolume(ctx, &csi.ControllerModifyVolumeRequest{
+		VolumeId:          volumeID,
+		MutableParameters: req.GetMutableParameters(),
+		Secrets:           req.GetSecrets(),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "call grpc server: %s", addr)
+	}
+
+	return resp, nil
+}
+
+// remoteControllerGetVolume gets the volume from the node of the volume, the
+// node is resolved from the topology of the PV like remoteModifyVolume.
+func (s *Service) remoteControllerGetVolume(
+	ctx context.Context,
+	req *csi.ControllerGetVolumeRequest) (
+	*csi.ControllerGetVolumeResponse, error) {
+	volumeID := req.GetVolumeId()
+	if volumeID == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty volumeId")
+	}
+
+	nodeInfo, err := s.getNodeInfoByVolume(ctx, volumeID)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, errors.Wrapf(err, "get node IP by volume: %s", volumeID).Error())
+	}
+
+	addr := fmt.Sprintf("%s:%s", nodeInfo.ip, s.remoteGRPCPort)
+	logger.WithContext(ctx).Debugf("calling remote grpc: %s", addr)
+
+	conn, err := grpc.NewClient(
+		addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithKeepaliveParams(kacp),
+		grpc.WithUnaryInterceptor(s.tokenAuthInterceptor),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connect to grpc server: %s", addr)
+	}
+	defer func() { _ = conn.Close() }()
+
+	client := csi.NewControllerClient(conn)
+	resp, err := client.ControllerGetVolume(ctx, &csi.ControllerGetVolumeRequest{
+		VolumeId: volumeID,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "call grpc server: %s", addr)
+	}
+	if volume := resp.GetVolume(); volume != nil {
+		if volume.VolumeContext == nil {
+			volume.VolumeContext = map[string]string{}
+		}
+		volume.VolumeContext[s.cfg.Get().ParameterVolumeContextNodeIP()] = nodeInfo.ip
+		volume.VolumeContext[s.cfg.Get().ParameterVolumeContextNodeHostname()] = nodeInfo.hostname
+	}
+
+	return resp, nil
+}
+
+// remoteGetCapacity returns the capacity of the node of the hostname in the
+// accessible topology, the volumes are accessible only from the node pulling
+// the model.
+func (s *Service) remoteGetCapacity(
+	ctx context.Context,
+	req *csi.GetCapacityRequest) (
+	*csi.GetCapacityResponse, error) {
+	hostname := req.GetAccessibleTopology().GetSegments()[labelHostname]
+	if hostname == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "empty topology segment %s", labelHostname)
+	}
+
+	_, span := tracing.Tracer.Start(ctx, "GetNodeInfoByHostname")
+	span.SetAttributes(attribute.String("node_hostname", hostname))
+	nodeInfo, err := s.getNodeInfoByHostname(ctx, hostname)
+	if err != nil {
+		span.SetStatus(otelCodes.Error, "failed to get node info")
+		span.RecordError(err)
+		span.End()
+		return nil, errors.Wrapf(err, "get node IP by hostname: %s", hostname)
+	}
+	span.End()
+
+	parentSpan := trace.SpanFromContext(ctx)
+	parentSpan.SetAttributes(attribute.String("node_ip", nodeInfo.ip))
+
+	return s.callNodeGetCapacity(ctx, nodeInfo, req)
+}
+
+// callNodeGetCapacity gets the capacity of the node over its external gRPC
+// endpoint.
+func (s *Service) callNodeGetCapacity(
+	ctx context.Context,
+	nodeInfo *nodeInfo,
+	req *csi.GetCapacityRequest) (
+	*csi.GetCapacityResponse, error) {
+	addr := fmt.Sprintf("%s:%s", nodeInfo.ip, s.remoteGRPCPort)
+	logger.WithContext(ctx).Debugf("calling remote grpc: %s", addr)
+
+	conn, err := grpc.NewClient(
+		addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithKeepaliveParams(kacp),
+		grpc.WithUnaryInterceptor(s.tokenAuthInterceptor),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connect to grpc server: %s", addr)
+	}
+	defer func() { _ = conn.Close() }()
+
+	client := csi.NewControllerClient(conn)
+	resp, err := client.GetCapacity(ctx, &csi.GetCapacityRequest{
+		VolumeCapabilities: req.GetVolumeCapabilities(),
+		Parameters:         req.GetParameters(),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "call grpc server: %s", addr)
+	}
+
+	return resp, nil
+}
+
+// remoteListVolumes lists the volumes of all the nodes over their external
+// gRPC endpoints, the entries are annotated with the node in the volume
+// context and the accessible topology. The nodes failing to list the volumes
+// are skipped, so that a node down doesn't hide the volumes of the others.
 func (s *Service) remoteListVolumes(
 	ctx context.Context,
 	req *csi.ListVolumesRequest) (
 	*csi.ListVolumesResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "remote list volumes not implemented yet")
+	start, err := parseStartingToken(req.GetStartingToken(), req.GetMaxEntries())
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := s.cfg.Get()
+	entries := []*csi.ListVolumesResponse_Entry{}
+	var mutex sync.Mutex
+	if err := s.forEachNode(ctx, func(nodeInfo *nodeInfo) {
+		nodeEntries, err := listNodeVolumes(ctx, s, nodeInfo)
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("failed to list volumes of node: %s", nodeInfo.hostname)
+			return
+		}
+		for _, entry := range nodeEntries {
+			volume := entry.GetVolume()
+			if volume == nil {
+				continue
+			}
+			if volume.VolumeContext == nil {
+				volume.VolumeContext = map[string]string{}
+			}
+			volume.VolumeContext[cfg.ParameterVolumeContextNodeIP()] = nodeInfo.ip
+			volume.VolumeContext[cfg.ParameterVolumeContextNodeHostname()] = nodeInfo.hostname
+			volume.AccessibleTopology = []*csi.Topology{
+				{
+					Segments: map[string]string{
+						labelHostname: nodeInfo.hostname,
+					},
+				},
+			}
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		entries = append(entries, nodeEntries...)
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		volumeI, volumeJ := entries[i].GetVolume(), entries[j].GetVolume()
+		if volumeI.GetVolumeId() != volumeJ.GetVolumeId() {
+			return volumeI.GetVolumeId() < volumeJ.GetVolumeId()
+		}
+		return volumeI.GetVolumeContext()[cfg.ParameterVolumeContextNodeHostname()] <
+			volumeJ.GetVolumeContext()[cfg.ParameterVolumeContextNodeHostname()]
+	})
+
+	entries, nextToken, err := paginateEntries(entries, start, int(req.GetMaxEntries()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &csi.ListVolumesResponse{
+		Entries:   entries,
+		NextToken: nextToken,
+	}, nil
+}
+
+// forEachNode calls the function for each node concurrently, the nodes
+// without the internal IP or the hostname are skipped.
+func (s *Service) forEachNode(ctx context.Context, fn func(nodeInfo *nodeInfo)) error {
+	nodes, err := s.node.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "list nodes")
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, nodeListConcurrency)
+	for idx := range nodes.Items {
+		nodeInfo, err := getNodeInfo(&nodes.Items[idx])
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Debugf("skip node: %s", nodes.Items[idx].Name)
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(nodeInfo)
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// callNodeListVolumes lists all the volumes of the node over its external
+// gRPC endpoint, page by page.
+func (s *Service) callNodeListVolumes(
+	ctx context.Context,
+	nodeInfo *nodeInfo) (
+	[]*csi.ListVolumesResponse_Entry, error) {
+	addr := fmt.Sprintf("%s:%s", nodeInfo.ip, s.remoteGRPCPort)
+	logger.WithContext(ctx).Debugf("calling remote grpc: %s", addr)
+
+	conn, err := grpc.NewClient(
+		addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithKeepaliveParams(kacp),
+		grpc.WithUnaryInterceptor(s.tokenAuthInterceptor),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connect to grpc server: %s", addr)
+	}
+	defer func() { _ = conn.Close() }()
+
+	client := csi.NewControllerClient(conn)
+	entries := []*csi.ListVolumesResponse_Entry{}
+	nextToken := ""
+	for {
+		resp, err := client.ListVolumes(ctx, &csi.ListVolumesRequest{
+			MaxEntries:    nodeListPageSize,
+			StartingToken: nextToken,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "call grpc server: %s", addr)
+		}
+		entries = append(entries, resp.GetEntries()...)
+		if nextToken = resp.GetNextToken(); nextToken == "" {
+			break
+		}
+	}
+
+	return entries, nil
+}
+
+// The snapshot ID returned by the controller is $hostname/$snapshotID, as the
+// snapshot is only on the node of its source volume.
+func remoteSnapshotID(hostname, snapshotID string) string {
+	return hostname + "/" + snapshotID
+}
+
+func splitRemoteSnapshotID(remoteID string) (string, string, bool) {
+	hostname, snapshotID, ok := strings.Cut(remoteID, "/")
+	return hostname, snapshotID, ok && hostname != "" && snapshotID != ""
+}
+
+// remoteCreateSnapshot creates the snapshot on the node of the source volume,
+// the node is resolved from the topology of the PV like remoteModifyVolume.
+func (s *Service) remoteCreateSnapshot(
+	ctx context.Context,
+	req *csi.CreateSnapshotRequest) (
+	*csi.CreateSnapshotResponse, error) {
+	sourceVolumeID := req.GetSourceVolumeId()
+	if sourceVolumeID == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty sourceVolumeId")
+	}
+
+	nodeInfo, err := s.getNodeInfoByVolume(ctx, sourceVolumeID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get node IP by volume: %s", sourceVolumeID)
+	}
+
+	addr := fmt.Sprintf("%s:%s", nodeInfo.ip, s.remoteGRPCPort)
+	logger.WithContext(ctx).Infof("calling remote grpc: %s", addr)
+
+	conn, err := grpc.NewClient(
+		addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithKeepaliveParams(kacp),
+		grpc.WithUnaryInterceptor(s.tokenAuthInterceptor),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connect to grpc server: %s", addr)
+	}
+	defer func() { _ = conn.Close() }()
+
+	client := csi.NewControllerClient(conn)
+	resp, err := client.CreateSnapshot(ctx, &csi.CreateSnapshotRequest{
+		Name:           req.GetName(),
+		SourceVolumeId: sourceVolumeID,
+		Parameters:     req.GetParameters(),
+		Secrets:        req.GetSecrets(),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "call grpc server: %s", addr)
+	}
+	if snapshot := resp.GetSnapshot(); snapshot != nil {
+		snapshot.SnapshotId = remoteSnapshotID(nodeInfo.hostname, snapshot.SnapshotId)
+	}
+
+	return resp, nil
+}
+
+// remoteDeleteSnapshot deletes the snapshot on the node of the hostname in the
+// snapshot ID, the snapshot of the node gone is deleted with the node.
+func (s *Service) remoteDeleteSnapshot(
+	ctx context.Context,
+	req *csi.DeleteSnapshotRequest) (
+	*csi.DeleteSnapshotResponse, error) {
+	hostname, snapshotID, ok := splitRemoteSnapshotID(req.GetSnapshotId())
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid snapshot id: %s", req.GetSnapshotId())
+	}
+
+	nodeInfo, err := s.getNodeInfoByHostname(ctx, hostname)
+	if err != nil {
+		if errors.Is(err, errNodeNotFound) {
+			logger.WithContext(ctx).WithError(err).Warnf("node %s not found, return success for deleting snapshot", hostname)
+			return &csi.DeleteSnapshotResponse{}, nil
+		}
+		return nil, errors.Wrapf(err, "get node IP by hostname: %s", hostname)
+	}
+
+	addr := fmt.Sprintf("%s:%s", nodeInfo.ip, s.remoteGRPCPort)
+	logger.WithContext(ctx).Infof("calling remote grpc: %s", addr)
+
+	conn, err := grpc.NewClient(
+		addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithKeepaliveParams(kacp),
+		grpc.WithUnaryInterceptor(s.tokenAuthInterceptor),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connect to grpc server: %s", addr)
+	}
+	defer func() { _ = conn.Close() }()
+
+	client := csi.NewControllerClient(conn)
+	resp, err := client.DeleteSnapshot(ctx, &csi.DeleteSnapshotRequest{
+		SnapshotId: snapshotID,
+		Secrets:    req.GetSecrets(),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "call grpc server: %s", addr)
+	}
+
+	return resp, nil
+}
+
+// remoteListSnapshots lists the snapshots of all the nodes, or of the node of
+// the snapshot ID in the request, the same as remoteListVolumes.
+func (s *Service) remoteListSnapshots(
+	ctx context.Context,
+	req *csi.ListSnapshotsRequest) (
+	*csi.ListSnapshotsResponse, error) {
+	start, err := parseStartingToken(req.GetStartingToken(), req.GetMaxEntries())
+	if err != nil {
+		return nil, err
+	}
+	nodeReq := &csi.ListSnapshotsRequest{SourceVolumeId: req.GetSourceVolumeId()}
+	hostname := ""
+	if req.GetSnapshotId() != "" {
+		var ok bool
+		hostname, nodeReq.SnapshotId, ok = splitRemoteSnapshotID(req.GetSnapshotId())
+		if !ok {
+			// No snapshot is found by the invalid snapshot ID.
+			return &csi.ListSnapshotsResponse{}, nil
+		}
+	}
+
+	entries := []*csi.ListSnapshotsResponse_Entry{}
+	var mutex sync.Mutex
+	if err := s.forEachNode(ctx, func(nodeInfo *nodeInfo) {
+		if hostname != "" && nodeInfo.hostname != hostname {
+			return
+		}
+		nodeEntries, err := listNodeSnapshots(ctx, s, nodeInfo, nodeReq)
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("failed to list snapshots of node: %s", nodeInfo.hostname)
+			return
+		}
+		for _, entry := range nodeEntries {
+			if snapshot := entry.GetSnapshot(); snapshot != nil {
+				snapshot.SnapshotId = remoteSnapshotID(nodeInfo.hostname, snapshot.SnapshotId)
+			}
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		entries = append(entries, nodeEntries...)
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].GetSnapshot().GetSnapshotId() < entries[j].GetSnapshot().GetSnapshotId()
+	})
+	entries, nextToken, err := paginateEntries(entries, start, int(req.GetMaxEntries()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &csi.ListSnapshotsResponse{
+		Entries:   entries,
+		NextToken: nextToken,
+	}, nil
+}
+
+// callNodeListSnapshots lists all the snapshots of the node matching the
+// request over its external gRPC endpoint, page by page.
+func (s *Service) callNodeListSnapshots(
+	ctx context.Context,
+	nodeInfo *nodeInfo,
+	req *csi.ListSnapshotsRequest) (
+	[]*csi.ListSnapshotsResponse_Entry, error) {
+	addr := fmt.Sprintf("%s:%s", nodeInfo.ip, s.remoteGRPCPort)
+	logger.WithContext(ctx).Debugf("calling remote grpc: %s", addr)
+
+	conn, err := grpc.NewClient(
+		addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithKeepaliveParams(kacp),
+		grpc.WithUnaryInterceptor(s.tokenAuthInterceptor),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connect to grpc server: %s", addr)
+	}
+	defer func() { _ = conn.Close() }()
+
+	client := csi.NewControllerClient(conn)
+	entries := []*csi.ListSnapshotsResponse_Entry{}
+	nextToken := ""
+	for {
+		resp, err := client.ListSnapshots(ctx, &csi.ListSnapshotsRequest{
+			SnapshotId:     req.GetSnapshotId(),
+			SourceVolumeId: req.GetSourceVolumeId(),
+			MaxEntries:     nodeListPageSize,
+			StartingToken:  nextToken,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "call grpc server: %s", addr)
+		}
+		entries = append(entries, resp.GetEntries()...)
+		if nextToken = resp.GetNextToken(); nextToken == "" {
+			break
+		}
+	}
+
+	return entries, nil
 }
@@ -9,9 +9,15 @@ import (
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/modelpack/model-csi-driver/pkg/config"
 	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc/codes"
 	grpcStatus "google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 // newNodeService creates a Service wired up for node-mode testing.
@@ -26,6 +32,7 @@ func newNodeService(t *testing.T) (*Service, string) {
 	cfg := config.NewWithRaw(rawCfg)
 	sm, err := status.NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 	worker, err := NewWorker(cfg, sm)
 	require.NoError(t, err)
 	return &Service{cfg: cfg, sm: sm, worker: worker}, tmpDir
@@ -55,12 +62,30 @@ func TestValidateVolumeCapabilities_Unimplemented(t *testing.T) {
 	require.Equal(t, codes.Unimplemented, st.Code())
 }
 
-func TestGetCapacity_Unimplemented(t *testing.T) {
-	svc := newTestService(t)
-	_, err := svc.GetCapacity(context.Background(), &csi.GetCapacityRequest{})
-	require.Error(t, err)
-	st, _ := grpcStatus.FromError(err)
-	require.Equal(t, codes.Unimplemented, st.Code())
+func TestGetCapacity(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	cfg := svc.cfg.Get()
+	cfg.Features.DiskUsageLimit = 100 << 20
+
+	resp, err := svc.GetCapacity(ctx, &csi.GetCapacityRequest{})
+	require.NoError(t, err)
+	require.Greater(t, resp.AvailableCapacity, int64(99<<20))
+	require.LessOrEqual(t, resp.AvailableCapacity, int64(100<<20))
+
+	// The size reserved by the in-flight pulls isn't available.
+	volumeDir := cfg.GetVolumeDir("pvc-capacity")
+	require.NoError(t, os.MkdirAll(volumeDir, 0755))
+	checker := svc.worker.newDiskQuotaChecker(volumeDir, false)
+	require.NoError(t, checker.CheckSize(ctx, "test/model:latest", 60<<20))
+	resp, err = svc.GetCapacity(ctx, &csi.GetCapacityRequest{})
+	require.NoError(t, err)
+	require.Less(t, resp.AvailableCapacity, int64(40<<20))
+
+	// The capacity of the controller is of the node in the topology.
+	cfg.Mode = "controller"
+	_, err = svc.GetCapacity(ctx, &csi.GetCapacityRequest{})
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
 }
 
 func TestControllerGetCapabilities(t *testing.T) {
@@ -68,6 +93,55 @@ func TestControllerGetCapabilities(t *testing.T) {
 	resp, err := svc.ControllerGetCapabilities(context.Background(), &csi.ControllerGetCapabilitiesRequest{})
 	require.NoError(t, err)
 	require.NotEmpty(t, resp.Capabilities)
+
+	caps := []csi.ControllerServiceCapability_RPC_Type{}
+	for _, capability := range resp.Capabilities {
+		caps = append(caps, capability.GetRpc().GetType())
+	}
+	require.Equal(t, []csi.ControllerServiceCapability_RPC_Type{
+		csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
+		csi.ControllerServiceCapability_RPC_GET_CAPACITY,
+		csi.ControllerServiceCapability_RPC_GET_VOLUME,
+		csi.ControllerServiceCapability_RPC_VOLUME_CONDITION,
+		csi.ControllerServiceCapability_RPC_CLONE_VOLUME,
+	}, caps)
+
+	svc.cfg.Get().Features.ModifyVolume = true
+	resp, err = svc.ControllerGetCapabilities(context.Background(), &csi.ControllerGetCapabilitiesRequest{})
+	require.NoError(t, err)
+	caps = []csi.ControllerServiceCapability_RPC_Type{}
+	for _, capability := range resp.Capabilities {
+		caps = append(caps, capability.GetRpc().GetType())
+	}
+	require.Equal(t, []csi.ControllerServiceCapability_RPC_Type{
+		csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
+		csi.ControllerServiceCapability_RPC_GET_CAPACITY,
+		csi.ControllerServiceCapability_RPC_GET_VOLUME,
+		csi.ControllerServiceCapability_RPC_VOLUME_CONDITION,
+		csi.ControllerServiceCapability_RPC_CLONE_VOLUME,
+		csi.ControllerServiceCapability_RPC_MODIFY_VOLUME,
+	}, caps)
+}
+
+func TestControllerModifyVolume_Disabled(t *testing.T) {
+	svc, _ := newNodeService(t)
+	_, err := svc.ControllerModifyVolume(context.Background(), &csi.ControllerModifyVolumeRequest{
+		VolumeId:          "pvc-modify",
+		MutableParameters: map[string]string{svc.cfg.Get().ParameterKeyReadOnly(): "true"},
+	})
+	st, _ := grpcStatus.FromError(err)
+	require.Equal(t, codes.Unimplemented, st.Code())
+
+	_, _, err = svc.localCreateVolume(context.Background(), &csi.CreateVolumeRequest{
+		Name: "pvc-modify",
+		Parameters: map[string]string{
+			svc.cfg.Get().ParameterKeyType():      "image",
+			svc.cfg.Get().ParameterKeyReference(): "test/model:latest",
+		},
+		MutableParameters: map[string]string{svc.cfg.Get().ParameterKeyReadOnly(): "true"},
+	})
+	st, _ = grpcStatus.FromError(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
 }
 
 func TestCreateSnapshot_Unimplemented(t *testing.T) {
@@ -242,6 +316,57 @@ func TestLocalCreateVolume_InvalidExcludeModelWeights(t *testing.T) {
 	require.Equal(t, codes.InvalidArgument, st.Code())
 }
 
+func TestLocalCreateVolume_InvalidExcludeWeights(t *testing.T) {
+	svc, _ := newNodeService(t)
+	_, _, err := svc.localCreateVolume(context.Background(), &csi.CreateVolumeRequest{
+		Name: "pvc-test",
+		Parameters: map[string]string{
+			svc.cfg.Get().ParameterKeyType():           "image",
+			svc.cfg.Get().ParameterKeyReference():      "registry/model:v1",
+			svc.cfg.Get().ParameterKeyExcludeWeights(): "notabool",
+		},
+	})
+	require.Error(t, err)
+	st, _ := grpcStatus.FromError(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Contains(t, st.Message(), svc.cfg.Get().ParameterKeyExcludeWeights())
+}
+
+func TestLocalCreateVolume_InvalidBackgroundWeights(t *testing.T) {
+	svc, _ := newNodeService(t)
+	_, _, err := svc.localCreateVolume(context.Background(), &csi.CreateVolumeRequest{
+		Name: "pvc-test",
+		Parameters: map[string]string{
+			svc.cfg.Get().ParameterKeyType():              "image",
+			svc.cfg.Get().ParameterKeyReference():         "registry/model:v1",
+			svc.cfg.Get().ParameterKeyBackgroundWeights(): "notabool",
+		},
+	})
+	require.Error(t, err)
+	st, _ := grpcStatus.FromError(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Contains(t, st.Message(), svc.cfg.Get().ParameterKeyBackgroundWeights())
+}
+
+func TestExcludeModelWeightsParameter(t *testing.T) {
+	svc, _ := newNodeService(t)
+	key, value := svc.excludeModelWeightsParameter(map[string]string{})
+	require.Equal(t, svc.cfg.Get().ParameterKeyExcludeModelWeights(), key)
+	require.Equal(t, "", value)
+
+	key, value = svc.excludeModelWeightsParameter(map[string]string{svc.cfg.Get().ParameterKeyExcludeWeights(): " true "})
+	require.Equal(t, svc.cfg.Get().ParameterKeyExcludeWeights(), key)
+	require.Equal(t, "true", value)
+
+	// The exclude-model-weights takes precedence over its alias.
+	key, value = svc.excludeModelWeightsParameter(map[string]string{
+		svc.cfg.Get().ParameterKeyExcludeModelWeights(): "false",
+		svc.cfg.Get().ParameterKeyExcludeWeights():      "true",
+	})
+	require.Equal(t, svc.cfg.Get().ParameterKeyExcludeModelWeights(), key)
+	require.Equal(t, "false", value)
+}
+
 func TestLocalCreateVolume_InvalidExcludeFilePatterns(t *testing.T) {
 	svc, _ := newNodeService(t)
 	_, _, err := svc.localCreateVolume(context.Background(), &csi.CreateVolumeRequest{
@@ -293,6 +418,86 @@ func TestLocalDeleteVolume_InvalidFormat(t *testing.T) {
 	require.Equal(t, codes.InvalidArgument, st.Code())
 }
 
+func TestLocalModifyVolume_StaticVolume(t *testing.T) {
+	svc, tmpDir := newNodeService(t)
+	statusPath := filepath.Join(tmpDir, "volumes", "pvc-modify", "status.json")
+	_, err := svc.sm.Set(statusPath, status.Status{
+		VolumeName: "pvc-modify",
+		Reference:  "test/model:latest",
+		State:      status.StatePullSucceeded,
+	})
+	require.NoError(t, err)
+
+	_, _, err = svc.localModifyVolume(context.Background(), &csi.ControllerModifyVolumeRequest{
+		VolumeId:          "pvc-modify",
+		MutableParameters: map[string]string{svc.cfg.Get().ParameterKeyReadOnly(): "true"},
+	})
+	require.NoError(t, err)
+	got, err := svc.sm.Get(statusPath)
+	require.NoError(t, err)
+	require.True(t, got.ReadOnly)
+	require.Equal(t, "test/model:latest", got.Reference)
+	require.Equal(t, status.StatePullSucceeded, got.State)
+
+	_, _, err = svc.localModifyVolume(context.Background(), &csi.ControllerModifyVolumeRequest{
+		VolumeId:          "pvc-modify",
+		MutableParameters: map[string]string{svc.cfg.Get().ParameterKeyReadOnly(): "false"},
+	})
+	require.NoError(t, err)
+	got, err = svc.sm.Get(statusPath)
+	require.NoError(t, err)
+	require.False(t, got.ReadOnly)
+}
+
+func TestLocalModifyVolume_Unsupported(t *testing.T) {
+	svc, tmpDir := newNodeService(t)
+	statusPath := filepath.Join(tmpDir, "volumes", "csi-modify", "models", "mount-1", "status.json")
+	_, err := svc.sm.Set(statusPath, status.Status{VolumeName: "csi-modify", MountID: "mount-1"})
+	require.NoError(t, err)
+	inlineStatusPath := filepath.Join(tmpDir, "volumes", "pvc-inline", "status.json")
+	_, err = svc.sm.Set(inlineStatusPath, status.Status{VolumeName: "pvc-inline", Inline: true})
+	require.NoError(t, err)
+
+	for _, volumeID := range []string{"csi-modify/mount-1", "csi-modify", "pvc-inline"} {
+		_, _, err := svc.localModifyVolume(context.Background(), &csi.ControllerModifyVolumeRequest{
+			VolumeId:          volumeID,
+			MutableParameters: map[string]string{svc.cfg.Get().ParameterKeyReadOnly(): "true"},
+		})
+		st, _ := grpcStatus.FromError(err)
+		require.Equal(t, codes.InvalidArgument, st.Code(), volumeID)
+	}
+
+	got, err := svc.sm.Get(statusPath)
+	require.NoError(t, err)
+	require.False(t, got.ReadOnly)
+}
+
+func TestLocalModifyVolume_Errors(t *testing.T) {
+	svc, tmpDir := newNodeService(t)
+	statusPath := filepath.Join(tmpDir, "volumes", "pvc-modify", "status.json")
+	_, err := svc.sm.Set(statusPath, status.Status{VolumeName: "pvc-modify"})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		volumeID   string
+		parameters map[string]string
+		code       codes.Code
+	}{
+		{"", nil, codes.InvalidArgument},
+		{"a/b/c", nil, codes.InvalidArgument},
+		{"pvc-nonexistent", map[string]string{svc.cfg.Get().ParameterKeyReadOnly(): "true"}, codes.NotFound},
+		{"pvc-modify", map[string]string{svc.cfg.Get().ParameterKeyReadOnly(): "invalid"}, codes.InvalidArgument},
+		{"pvc-modify", map[string]string{svc.cfg.Get().ParameterKeyReference(): "test/model:v2"}, codes.InvalidArgument},
+	} {
+		_, _, err := svc.localModifyVolume(context.Background(), &csi.ControllerModifyVolumeRequest{
+			VolumeId:          tc.volumeID,
+			MutableParameters: tc.parameters,
+		})
+		st, _ := grpcStatus.FromError(err)
+		require.Equal(t, tc.code, st.Code(), tc.volumeID)
+	}
+}
+
 // ─── StatusManager helper ────────────────────────────────────────────────────
 
 func TestService_StatusManager(t *testing.T) {
@@ -345,9 +550,131 @@ func TestDeleteVolume_NodeMode_StaticVolume(t *testing.T) {
 func TestListVolumes_NodeMode(t *testing.T) {
 	svc, _ := newNodeService(t)
 	ctx := context.Background()
-	// Node mode always returns Unimplemented for ListVolumes
+	// Node mode lists the volumes hosted by the node
 	_, err := svc.ListVolumes(ctx, &csi.ListVolumesRequest{})
 	require.Error(t, err)
+	require.NoError(t, os.MkdirAll(svc.cfg.Get().GetVolumesDir(), 0750))
+	resp, err := svc.ListVolumes(ctx, &csi.ListVolumesRequest{})
+	require.NoError(t, err)
+	require.Empty(t, resp.Entries)
+	_, err = svc.ListVolumes(ctx, &csi.ListVolumesRequest{StartingToken: "invalid"})
+	require.Equal(t, codes.Aborted, grpcStatus.Code(err))
+}
+
+func TestControllerGetVolume_NodeMode(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	cfg := svc.cfg.Get()
+	newVolume := func(volumeDir string, volumeStatus status.Status) {
+		t.Helper()
+		require.NoError(t, os.MkdirAll(filepath.Join(volumeDir, "model"), 0750))
+		require.NoError(t, os.WriteFile(filepath.Join(volumeDir, "model", "weights.bin"), []byte("weights"), 0644))
+		volumeStatus.Reference = "test/model:latest"
+		volumeStatus.SizeInBytes = 7
+		_, err := svc.sm.Set(filepath.Join(volumeDir, "status.json"), volumeStatus)
+		require.NoError(t, err)
+	}
+	getCondition := func(volumeID string) *csi.VolumeCondition {
+		t.Helper()
+		resp, err := svc.ControllerGetVolume(ctx, &csi.ControllerGetVolumeRequest{VolumeId: volumeID})
+		require.NoError(t, err)
+		require.Equal(t, volumeID, resp.Volume.VolumeId)
+		return resp.Status.VolumeCondition
+	}
+
+	newVolume(cfg.GetVolumeDir("pvc-healthy"), status.Status{State: status.StatePullSucceeded})
+	require.False(t, getCondition("pvc-healthy").Abnormal)
+	newVolume(cfg.GetMountIDDirForDynamic("csi-dyn", "mount-1"), status.Status{State: status.StatePullSucceeded})
+	require.False(t, getCondition("csi-dyn/mount-1").Abnormal)
+	newVolume(cfg.GetVolumeDir("pvc-pulling"), status.Status{State: status.StatePullRunning})
+	require.False(t, getCondition("pvc-pulling").Abnormal)
+
+	// The failed pull, the missing files, the corrupted files and the
+	// unmounted target are abnormal.
+	newVolume(cfg.GetVolumeDir("pvc-failed"), status.Status{State: status.StatePullFailed})
+	require.True(t, getCondition("pvc-failed").Abnormal)
+	newVolume(cfg.GetVolumeDir("pvc-wiped"), status.Status{State: status.StatePullSucceeded})
+	require.NoError(t, os.RemoveAll(cfg.GetModelDir("pvc-wiped")))
+	require.Contains(t, getCondition("pvc-wiped").Message, "missing")
+	newVolume(cfg.GetVolumeDir("pvc-corrupted"), status.Status{State: status.StatePullSucceeded})
+	require.NoError(t, writeScrubResult(cfg.GetVolumeDir("pvc-corrupted"), &status.ScrubResult{CheckedFiles: 1, CorruptedFiles: []string{"weights.bin"}}))
+	condition := getCondition("pvc-corrupted")
+	require.True(t, condition.Abnormal)
+	require.Contains(t, condition.Message, "weights.bin")
+	newVolume(cfg.GetVolumeDir("pvc-unmounted"), status.Status{
+		State:   status.StateMounted,
+		Targets: []status.Target{{Path: filepath.Join(t.TempDir(), "target")}},
+	})
+	condition = getCondition("pvc-unmounted")
+	require.True(t, condition.Abnormal)
+	require.Contains(t, condition.Message, "not mounted")
+
+	_, err := svc.ControllerGetVolume(ctx, &csi.ControllerGetVolumeRequest{VolumeId: "pvc-unknown"})
+	require.Equal(t, codes.NotFound, grpcStatus.Code(err))
+	for _, volumeID := range []string{"", "..", "pvc-a/mount-1", "csi-dyn/..", prefetchVolumePrefix + "model"} {
+		_, err = svc.ControllerGetVolume(ctx, &csi.ControllerGetVolumeRequest{VolumeId: volumeID})
+		require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+	}
+}
+
+func TestRemoteListVolumes(t *testing.T) {
+	newNode := func(name, hostname, ip string) *corev1.Node {
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{labelHostname: hostname}},
+		}
+		node.Status.Addresses = []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: ip}}
+		return node
+	}
+	clientset := fake.NewSimpleClientset(
+		newNode("node-1", "host-1", "10.0.0.1"),
+		newNode("node-2", "host-2", "10.0.0.2"),
+		newNode("node-3", "host-3", "10.0.0.3"),
+	)
+	svc := &Service{
+		cfg:  config.NewWithRaw(&config.RawConfig{ServiceName: "model.csi.modelpack.org", Mode: "controller"}),
+		node: clientset.CoreV1().Nodes(),
+	}
+	ctx := context.Background()
+
+	volumes := map[string][]string{
+		"host-1": {"pvc-b", "csi-dyn/mount-1"},
+		"host-2": {"pvc-a"},
+	}
+	origListNodeVolumes := listNodeVolumes
+	listNodeVolumes = func(ctx context.Context, s *Service, nodeInfo *nodeInfo) ([]*csi.ListVolumesResponse_Entry, error) {
+		volumeIDs, ok := volumes[nodeInfo.hostname]
+		if !ok {
+			return nil, errors.New("connection refused")
+		}
+		entries := []*csi.ListVolumesResponse_Entry{}
+		for _, volumeID := range volumeIDs {
+			entries = append(entries, &csi.ListVolumesResponse_Entry{Volume: &csi.Volume{VolumeId: volumeID}})
+		}
+		return entries, nil
+	}
+	defer func() { listNodeVolumes = origListNodeVolumes }()
+
+	// The volumes of the nodes are merged, the node failing to list the
+	// volumes is skipped.
+	resp, err := svc.ListVolumes(ctx, &csi.ListVolumesRequest{MaxEntries: 2})
+	require.NoError(t, err)
+	require.Len(t, resp.Entries, 2)
+	require.Equal(t, "csi-dyn/mount-1", resp.Entries[0].Volume.VolumeId)
+	require.Equal(t, "host-1", resp.Entries[0].Volume.VolumeContext["model.csi.modelpack.org/node-hostname"])
+	require.Equal(t, "10.0.0.1", resp.Entries[0].Volume.VolumeContext["model.csi.modelpack.org/node-ip"])
+	require.Equal(t, "host-1", resp.Entries[0].Volume.AccessibleTopology[0].Segments[labelHostname])
+	require.Equal(t, "pvc-a", resp.Entries[1].Volume.VolumeId)
+	require.Equal(t, "host-2", resp.Entries[1].Volume.VolumeContext["model.csi.modelpack.org/node-hostname"])
+	require.Equal(t, "2", resp.NextToken)
+
+	resp, err = svc.ListVolumes(ctx, &csi.ListVolumesRequest{MaxEntries: 2, StartingToken: resp.NextToken})
+	require.NoError(t, err)
+	require.Len(t, resp.Entries, 1)
+	require.Equal(t, "pvc-b", resp.Entries[0].Volume.VolumeId)
+	require.Empty(t, resp.NextToken)
+
+	_, err = svc.ListVolumes(ctx, &csi.ListVolumesRequest{StartingToken: "4"})
+	require.Equal(t, codes.Aborted, grpcStatus.Code(err))
 }
 
 // --- NewDynamicServerManager ---
@@ -358,3 +685,190 @@ func TestNewDynamicServerManager(t *testing.T) {
 	require.NotNil(t, mgr)
 }
 
+
+func TestPinModelDigest(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	dgst := digest.FromString("manifest")
+	resolved := 0
+	platforms := []string{}
+	origResolveDigest := ResolveDigest
+	ResolveDigest = func(ctx context.Context, pullCfg *config.PullConfig, reference, platform string) (ocispec.Descriptor, error) {
+		resolved++
+		platforms = append(platforms, platform)
+		parsed, err := parsePlatform(platform)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		return ocispec.Descriptor{Digest: dgst, Platform: parsed}, nil
+	}
+	defer func() { ResolveDigest = origResolveDigest }()
+
+	modelDir := svc.cfg.Get().GetModelDir("pvc-pin")
+	opts := PullOptions{Type: ModelTypeImage}
+	volumeContext, err := svc.pinModelDigest(ctx, modelDir, "test/model:latest", map[string]string{}, &opts)
+	require.NoError(t, err)
+	require.Equal(t, dgst.String(), opts.Digest)
+	require.Equal(t, map[string]string{
+		svc.cfg.Get().ParameterKeyDigest():   dgst.String(),
+		svc.cfg.Get().ParameterKeyPlatform(): defaultPlatform(),
+	}, volumeContext)
+	require.Equal(t, defaultPlatform(), opts.Platform)
+	require.Equal(t, []string{defaultPlatform()}, platforms)
+
+	// The digest recorded in the status is reused by the re-created volume.
+	_, err = svc.sm.Set(filepath.Join(filepath.Dir(modelDir), "status.json"), status.Status{
+		Reference: "test/model:latest",
+		Digest:    dgst.String(),
+	})
+	require.NoError(t, err)
+	opts = PullOptions{Type: ModelTypeImage}
+	_, err = svc.pinModelDigest(ctx, modelDir, "test/model:latest", map[string]string{}, &opts)
+	require.NoError(t, err)
+	require.Equal(t, dgst.String(), opts.Digest)
+	require.Equal(t, 1, resolved)
+
+	// The platform is resolved again if it differs from the status.
+	_, err = svc.sm.Set(filepath.Join(filepath.Dir(modelDir), "status.json"), status.Status{
+		Reference: "test/model:latest",
+		Digest:    dgst.String(),
+		Platform:  "linux/amd64",
+	})
+	require.NoError(t, err)
+	opts = PullOptions{Type: ModelTypeImage}
+	volumeContext, err = svc.pinModelDigest(ctx, modelDir, "test/model:latest", map[string]string{
+		svc.cfg.Get().ParameterKeyPlatform(): "linux/arm64",
+	}, &opts)
+	require.NoError(t, err)
+	require.Equal(t, "linux/arm64", opts.Platform)
+	require.Equal(t, "linux/arm64", volumeContext[svc.cfg.Get().ParameterKeyPlatform()])
+	require.Equal(t, 2, resolved)
+
+	opts = PullOptions{Type: ModelTypeImage}
+	_, err = svc.pinModelDigest(ctx, modelDir, "test/model:latest", map[string]string{
+		svc.cfg.Get().ParameterKeyDigest(): "invalid",
+	}, &opts)
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+
+	opts = PullOptions{Type: ModelTypeImage}
+	_, err = svc.pinModelDigest(ctx, modelDir, "test/model:latest", map[string]string{
+		svc.cfg.Get().ParameterKeyPlatform(): "linux",
+	}, &opts)
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+
+	// The models of other types aren't pinned.
+	opts = PullOptions{Type: ModelTypeHuggingFace}
+	volumeContext, err = svc.pinModelDigest(ctx, modelDir, "org/model", map[string]string{}, &opts)
+	require.NoError(t, err)
+	require.Empty(t, volumeContext)
+	require.Empty(t, opts.Digest)
+	require.Equal(t, 2, resolved)
+}
+
+func TestFilePatternsParameter(t *testing.T) {
+	svc, _ := newNodeService(t)
+	patterns, err := svc.filePatternsParameter(map[string]string{
+		svc.cfg.Get().ParameterKeyExcludeFilePatterns(): `["*.md"]`,
+		svc.cfg.Get().ParameterKeyIncludeFilePatterns(): `["tokenizer*"]`,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"*", "!tokenizer*", "*.md"}, patterns)
+
+	_, err = svc.filePatternsParameter(map[string]string{
+		svc.cfg.Get().ParameterKeyIncludeFilePatterns(): `["!tokenizer*"]`,
+	})
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+
+	_, err = svc.filePatternsParameter(map[string]string{
+		svc.cfg.Get().ParameterKeyIncludeFilePatterns(): `["[tokenizer"]`,
+	})
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+}
+
+func TestCapacityRange(t *testing.T) {
+	require.NoError(t, checkCapacityRange(nil, 2<<30))
+	require.NoError(t, checkCapacityRange(&csi.CapacityRange{RequiredBytes: 1 << 30}, -1))
+	require.NoError(t, checkCapacityRange(&csi.CapacityRange{RequiredBytes: 1 << 30, LimitBytes: 4 << 30}, 2<<30))
+	err := checkCapacityRange(&csi.CapacityRange{RequiredBytes: 1 << 30}, 2<<30)
+	require.Equal(t, codes.OutOfRange, grpcStatus.Code(err))
+	err = checkCapacityRange(&csi.CapacityRange{LimitBytes: 1 << 30}, 2<<30)
+	require.Equal(t, codes.OutOfRange, grpcStatus.Code(err))
+
+	require.Equal(t, int64(2<<30), volumeCapacity(nil, 2<<30))
+	require.Equal(t, int64(0), volumeCapacity(nil, -1))
+	require.Equal(t, int64(1<<30), volumeCapacity(&csi.CapacityRange{RequiredBytes: 1 << 30}, 1<<20))
+	require.Equal(t, int64(2<<30), volumeCapacity(&csi.CapacityRange{RequiredBytes: 1 << 30, LimitBytes: 4 << 30}, 2<<30))
+	require.Equal(t, int64(4<<30), volumeCapacity(&csi.CapacityRange{LimitBytes: 4 << 30}, 5<<30))
+
+	// The model larger than the requested storage isn't pulled.
+	svc, _ := newNodeService(t)
+	origGetModelSize := getModelSize
+	getModelSize = func(ctx context.Context, pullCfg *config.PullConfig, reference string, excludeModelWeights bool, excludeFilePatterns []string) (int64, error) {
+		return 2 << 30, nil
+	}
+	defer func() { getModelSize = origGetModelSize }()
+	_, _, err = svc.localCreateVolume(context.Background(), &csi.CreateVolumeRequest{
+		Name: "pvc-capacity",
+		Parameters: map[string]string{
+			svc.cfg.Get().ParameterKeyType():      "image",
+			svc.cfg.Get().ParameterKeyReference(): "test/model:latest",
+			svc.cfg.Get().ParameterKeyDigest():    digest.FromString("manifest").String(),
+		},
+		CapacityRange: &csi.CapacityRange{RequiredBytes: 1 << 30},
+	})
+	require.Equal(t, codes.OutOfRange, grpcStatus.Code(err))
+	require.NoDirExists(t, svc.cfg.Get().GetVolumeDir("pvc-capacity"))
+}
+
+func TestCreateVolume_Idempotent(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	cfg := svc.cfg.Get()
+	dgst := digest.FromString("manifest").String()
+
+	modelDir := cfg.GetModelDir("pvc-existing")
+	require.NoError(t, os.MkdirAll(modelDir, 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(modelDir, "weights.bin"), []byte("weights"), 0644))
+	_, err := svc.sm.Set(filepath.Join(cfg.GetVolumeDir("pvc-existing"), "status.json"), status.Status{
+		VolumeName:  "pvc-existing",
+		Reference:   "test/model:latest",
+		Digest:      dgst,
+		State:       status.StateMounted,
+		SizeInBytes: 7,
+	})
+	require.NoError(t, err)
+
+	createVolume := func(parameters map[string]string) (*csi.CreateVolumeResponse, error) {
+		parameters[cfg.ParameterKeyType()] = "image"
+		resp, _, err := svc.localCreateVolume(ctx, &csi.CreateVolumeRequest{Name: "pvc-existing", Parameters: parameters})
+		return resp, err
+	}
+
+	// The volume created by the same parameters isn't pulled again.
+	resp, err := createVolume(map[string]string{cfg.ParameterKeyReference(): "docker.io/test/model:latest"})
+	require.NoError(t, err)
+	require.Equal(t, "pvc-existing", resp.Volume.VolumeId)
+	require.Equal(t, int64(7), resp.Volume.CapacityBytes)
+	require.Equal(t, dgst, resp.Volume.VolumeContext[cfg.ParameterKeyDigest()])
+	require.FileExists(t, filepath.Join(modelDir, "weights.bin"))
+	_, err = createVolume(map[string]string{cfg.ParameterKeyReference(): "test/model:latest", cfg.ParameterKeyDigest(): dgst})
+	require.NoError(t, err)
+
+	// The volume created by other parameters isn't pulled with the model requested.
+	for _, parameters := range []map[string]string{
+		{cfg.ParameterKeyReference(): "test/other:latest"},
+		{cfg.ParameterKeyReference(): "test/model:latest", cfg.ParameterKeyDigest(): digest.FromString("other").String()},
+		{cfg.ParameterKeyReference(): "test/model:latest", cfg.ParameterKeyExcludeModelWeights(): "true"},
+		{cfg.ParameterKeyReference(): "test/model:latest", cfg.ParameterKeyIncludeFilePatterns(): `["*.json"]`},
+	} {
+		_, err = createVolume(parameters)
+		require.Equal(t, codes.AlreadyExists, grpcStatus.Code(err), parameters)
+	}
+	volumeStatus, err := svc.sm.Get(filepath.Join(cfg.GetVolumeDir("pvc-existing"), "status.json"))
+	require.NoError(t, err)
+	require.Equal(t, "test/model:latest", volumeStatus.Reference)
+	require.FileExists(t, filepath.Join(modelDir, "weights.bin"))
+
+	// The cloned volume isn't created by the parameters.
+	require.False(t, isCompatibleVolume(&status.Status{Reference: "test/model:latest", ClonedFrom: "volume/pvc-source"}, "test/model:latest", "", "", PullOptions{Type: "image"}))
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/pkg/kmutex"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// The number of the recent errors kept for the admin service.
+const maxRecentErrors = 100
+
+// HeldLock is a context key locked by the worker, e.g. the volume being
+// pulled or deleted.
+type HeldLock struct {
+	Key      string    `json:"key"`
+	LockedAt time.Time `json:"locked_at"`
+}
+
+// keyedLocker is the keyed locker of the worker tracking the held keys, so
+// that the stuck operations waiting for a key are told by the holder.
+type keyedLocker struct {
+	locker kmutex.KeyedLocker
+	mutex  sync.Mutex
+	held   map[string]time.Time
+}
+
+func newKeyedLocker() *keyedLocker {
+	return &keyedLocker{
+		locker: kmutex.New(),
+		held:   map[string]time.Time{},
+	}
+}
+
+func (l *keyedLocker) Lock(ctx context.Context, key string) error {
+	if err := l.locker.Lock(ctx, key); err != nil {
+		return err
+	}
+	l.mutex.Lock()
+	l.held[key] = time.Now()
+	l.mutex.Unlock()
+	return nil
+}
+
+func (l *keyedLocker) Unlock(key string) {
+	l.mutex.Lock()
+	delete(l.held, key)
+	l.mutex.Unlock()
+	l.locker.Unlock(key)
+}
+
+// HeldLocks returns the keys held, the longest held first.
+func (l *keyedLocker) HeldLocks() []HeldLock {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	locks := make([]HeldLock, 0, len(l.held))
+	for key, lockedAt := range l.held {
+		locks = append(locks, HeldLock{Key: key, LockedAt: lockedAt})
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].LockedAt.Before(locks[j].LockedAt)
+	})
+	return locks
+}
+
+// Operation is a CSI call served by the driver.
+type Operation struct {
+	Method    string    `json:"method"`
+	VolumeID  string    `json:"volume_id,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	// Set for the failed operations only.
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Code       string     `json:"code,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// OperationTracker tracks the in-flight CSI calls and keeps the recent
+// errors of them.
+type OperationTracker struct {
+	mutex    sync.Mutex
+	nextID   uint64
+	inflight map[uint64]*Operation
+	failed   []Operation
+}
+
+func NewOperationTracker() *OperationTracker {
+	return &OperationTracker{inflight: map[uint64]*Operation{}}
+}
+
+func (tracker *OperationTracker) begin(method, volumeID string) uint64 {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracker.nextID++
+	tracker.inflight[tracker.nextID] = &Operation{Method: method, VolumeID: volumeID, StartedAt: time.Now()}
+	return tracker.nextID
+}
+
+func (tracker *OperationTracker) end(id uint64, err error) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	operation := tracker.inflight[id]
+	delete(tracker.inflight, id)
+	if err == nil || operation == nil {
+		return
+	}
+	finishedAt := time.Now()
+	operation.FinishedAt = &finishedAt
+	operation.Code = status.Code(err).String()
+	operation.Error = err.Error()
+	if len(tracker.failed) >= maxRecentErrors {
+		tracker.failed = tracker.failed[1:]
+	}
+	tracker.failed = append(tracker.failed, *operation)
+}
+
+// Inflight returns the operations in progress, the longest running first.
+func (tracker *OperationTracker) Inflight() []Operation {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	operations := make([]Operation, 0, len(tracker.inflight))
+	for _, operation := range tracker.inflight {
+		operations = append(operations, *operation)
+	}
+	sort.Slice(operations, func(i, j int) bool {
+		return operations[i].StartedAt.Before(operations[j].StartedAt)
+	})
+	return operations
+}
+
+// RecentErrors returns the recent failed operations, the latest first.
+func (tracker *OperationTracker) RecentErrors() []Operation {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	operations := make([]Operation, 0, len(tracker.failed))
+	for i := len(tracker.failed) - 1; i >= 0; i-- {
+		operations = append(operations, tracker.failed[i])
+	}
+	return operations
+}
+
+// TrackOperation is the unary interceptor tracking the CSI calls of the
+// controller and the node services, the identity calls (e.g. the probes)
+// aren't tracked.
+func (s *Service) TrackOperation(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if s.operations == nil ||
+		!(strings.HasPrefix(info.FullMethod, "/csi.v1.Controller/") || strings.HasPrefix(info.FullMethod, "/csi.v1.Node/")) {
+		return handler(ctx, req)
+	}
+	volumeID := ""
+	switch r := req.(type) {
+	case interface{ GetVolumeId() string }:
+		volumeID = r.GetVolumeId()
+	case interface{ GetName() string }:
+		volumeID = r.GetName()
+	}
+	id := s.operations.begin(info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:], volumeID)
+	resp, err := handler(ctx, req)
+	s.operations.end(id, err)
+	return resp, err
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+func TestTrackOperation(t *testing.T) {
+	svc, _ := newNodeService(t)
+	svc.operations = NewOperationTracker()
+	ctx := context.Background()
+
+	// The in-flight call is listed until it returns.
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = svc.TrackOperation(ctx, &csi.CreateVolumeRequest{Name: "pvc-1"}, &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Controller/CreateVolume"},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				<-release
+				return nil, grpcStatus.Error(codes.Internal, "pull model failed")
+			})
+	}()
+	require.Eventually(t, func() bool { return len(svc.operations.Inflight()) == 1 }, time.Second, 10*time.Millisecond)
+	operation := svc.operations.Inflight()[0]
+	require.Equal(t, "CreateVolume", operation.Method)
+	require.Equal(t, "pvc-1", operation.VolumeID)
+	close(release)
+	<-done
+	require.Empty(t, svc.operations.Inflight())
+
+	// The failed calls are kept as the recent errors, the probes aren't tracked.
+	_, err := svc.TrackOperation(ctx, &csi.NodePublishVolumeRequest{VolumeId: "pvc-2"}, &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Node/NodePublishVolume"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, errors.New("mount failed")
+		})
+	require.Error(t, err)
+	_, _ = svc.TrackOperation(ctx, &csi.ProbeRequest{}, &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Identity/Probe"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, errors.New("not ready")
+		})
+	recentErrors := svc.operations.RecentErrors()
+	require.Len(t, recentErrors, 2)
+	require.Equal(t, "NodePublishVolume", recentErrors[0].Method)
+	require.Equal(t, codes.Unknown.String(), recentErrors[0].Code)
+	require.Equal(t, "CreateVolume", recentErrors[1].Method)
+	require.Equal(t, codes.Internal.String(), recentErrors[1].Code)
+	require.NotNil(t, recentErrors[1].FinishedAt)
+
+	for i := 0; i < maxRecentErrors; i++ {
+		svc.operations.end(svc.operations.begin("DeleteVolume", "pvc-3"), errors.New("busy"))
+	}
+	require.Len(t, svc.operations.RecentErrors(), maxRecentErrors)
+	require.Equal(t, "DeleteVolume", svc.operations.RecentErrors()[maxRecentErrors-1].Method)
+}
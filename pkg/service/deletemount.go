@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// isMountPulling returns true if the dynamic mount is being pulled, including
+// the weights pulled in the background and the mount created in the
+// background.
+func (s *Service) isMountPulling(volumeName, mountID string) bool {
+	if s.worker.contextMap.Get(fmt.Sprintf("%s/%s", volumeName, mountID)) != nil {
+		return true
+	}
+	pending, _ := s.asyncMountStatus(volumeName, mountID)
+	return pending != nil
+}
+
+// DeleteMount deletes the dynamic mount. The mount being pulled isn't deleted
+// unless it's forced, which cancels the pull before deleting the mount. The
+// mount is in CANCELING state until its pull stops and then in DELETING state
+// until it's deleted.
+func (s *Service) DeleteMount(ctx context.Context, volumeName, mountID string, force bool) error {
+	ctx = logger.NewContext(ctx, "DeleteMount", volumeName, mountID)
+	key := asyncMountKey(volumeName, mountID)
+	if s.isMountPulling(volumeName, mountID) {
+		if !force {
+			return status.Errorf(codes.FailedPrecondition, "volume_name %s with mount_id %s is being pulled, cancel it or delete it by force", volumeName, mountID)
+		}
+		s.deletingMounts.Store(key, modelStatus.StateCanceling)
+		defer s.deletingMounts.Delete(key)
+		if s.worker.cancelPull(volumeName, mountID) {
+			logger.WithContext(ctx).Infof("canceled pull by force delete")
+			// Wait for the pull to stop.
+			contextKey := fmt.Sprintf("%s/%s", volumeName, mountID)
+			if err := s.worker.kmutex.Lock(ctx, contextKey); err != nil {
+				return status.Errorf(codes.Internal, "lock context key %s: %s", contextKey, err)
+			}
+			s.worker.kmutex.Unlock(contextKey)
+		}
+	}
+	s.deletingMounts.Store(key, modelStatus.StateDeleting)
+	defer s.deletingMounts.Delete(key)
+
+	if _, err := s.DeleteVolume(ctx, &csi.DeleteVolumeRequest{
+		VolumeId: fmt.Sprintf("%s/%s", volumeName, mountID),
+	}); err != nil {
+		return err
+	}
+	s.forgetAsyncMount(volumeName, mountID)
+	return nil
+}
+
+// withDeletingState returns the mount with the state of it being deleted,
+// e.g. CANCELING.
+func (s *Service) withDeletingState(mount *modelStatus.Status) *modelStatus.Status {
+	if mount == nil {
+		return nil
+	}
+	if state, ok := s.deletingMounts.Load(asyncMountKey(mount.VolumeName, mount.MountID)); ok {
+		mount.State = state.(string)
+	}
+	return mount
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+func TestDeleteMount(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+
+	// The mount not being pulled is deleted.
+	newDynamicMount(t, svc, "csi-vol", "m1")
+	require.NoError(t, svc.DeleteMount(ctx, "csi-vol", "m1", false))
+	_, err := svc.GetDynamicVolume(ctx, "csi-vol", "m1")
+	require.True(t, errors.Is(err, os.ErrNotExist))
+
+	// The pull holds the context key until it's canceled.
+	newDynamicMount(t, svc, "csi-vol", "m2")
+	require.NoError(t, svc.worker.kmutex.Lock(ctx, "csi-vol/m2"))
+	pullCtx, cancel := context.WithCancel(ctx)
+	svc.worker.contextMap.Set("csi-vol/m2", &cancel)
+	canceledState := make(chan string, 1)
+	go func() {
+		<-pullCtx.Done()
+		mount, err := svc.GetDynamicVolume(ctx, "csi-vol", "m2")
+		if err == nil {
+			canceledState <- mount.State
+		}
+		close(canceledState)
+		svc.worker.contextMap.Set("csi-vol/m2", nil)
+		svc.worker.kmutex.Unlock("csi-vol/m2")
+	}()
+
+	err = svc.DeleteMount(ctx, "csi-vol", "m2", false)
+	require.Equal(t, codes.FailedPrecondition, grpcStatus.Code(err))
+	mount, err := svc.GetDynamicVolume(ctx, "csi-vol", "m2")
+	require.NoError(t, err)
+	require.Equal(t, modelStatus.StatePullSucceeded, mount.State)
+
+	// The mount deleted by force is canceling until the pull stops.
+	require.NoError(t, svc.DeleteMount(ctx, "csi-vol", "m2", true))
+	select {
+	case state := <-canceledState:
+		require.Equal(t, modelStatus.StateCanceling, state)
+	case <-time.After(5 * time.Second):
+		t.Fatal("pull isn't canceled")
+	}
+	_, err = svc.GetDynamicVolume(ctx, "csi-vol", "m2")
+	require.True(t, errors.Is(err, os.ErrNotExist))
+	_, ok := svc.deletingMounts.Load(asyncMountKey("csi-vol", "m2"))
+	require.False(t, ok)
+}
+
+func TestWithDeletingState(t *testing.T) {
+	svc, _ := newNodeService(t)
+	require.Nil(t, svc.withDeletingState(nil))
+
+	mount := &modelStatus.Status{VolumeName: "csi-vol", MountID: "m1", State: modelStatus.StatePullRunning}
+	require.Equal(t, modelStatus.StatePullRunning, svc.withDeletingState(mount).State)
+	svc.deletingMounts.Store(asyncMountKey("csi-vol", "m1"), modelStatus.StateDeleting)
+	require.Equal(t, modelStatus.StateDeleting, svc.withDeletingState(mount).State)
+	require.False(t, isPullDone(modelStatus.StateDeleting))
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/pkg/errors"
+)
+
+// The bytes of the random token minted per dynamic volume.
+const dynamicServerTokenSize = 32
+
+// ensureDynamicServerToken mints the bearer token of the dynamic volume if
+// features.dynamic_server_auth is enabled. The token is written beside the
+// csi.sock, so that it's read by the workload mounting the volume, and the
+// token minted already is kept, e.g. the volume published to multiple pods.
+func ensureDynamicServerToken(cfg *config.RawConfig, volumeName string) error {
+	if !cfg.Features.DynamicServerAuth {
+		return nil
+	}
+	tokenPath := cfg.GetCSITokenPathForDynamic(volumeName)
+	if _, err := os.Stat(tokenPath); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return errors.Wrapf(err, "stat token: %s", tokenPath)
+	}
+
+	data := make([]byte, dynamicServerTokenSize)
+	if _, err := rand.Read(data); err != nil {
+		return errors.Wrap(err, "generate token")
+	}
+	if err := os.MkdirAll(filepath.Dir(tokenPath), 0755); err != nil {
+		return errors.Wrapf(err, "create token dir: %s", filepath.Dir(tokenPath))
+	}
+	tmpPath := filepath.Join(filepath.Dir(tokenPath), "."+filepath.Base(tokenPath)+".tmp")
+	if err := os.WriteFile(tmpPath, []byte(hex.EncodeToString(data)), 0644); err != nil {
+		return errors.Wrapf(err, "write token: %s", tmpPath)
+	}
+	if err := os.Rename(tmpPath, tokenPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return errors.Wrapf(err, "rename token: %s", tmpPath)
+	}
+	return nil
+}
+
+// dynamicServerAuth requires the bearer token of the volume on the requests
+// except /healthz if features.dynamic_server_auth is enabled, the feature
+// and the token are read per request, so that the config reloaded applies.
+func dynamicServerAuth(cfg *config.Config, volumeName string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			rawCfg := cfg.Get()
+			if !rawCfg.Features.DynamicServerAuth || volumeName == "" || c.Request().URL.Path == "/healthz" {
+				return next(c)
+			}
+			expected, err := os.ReadFile(rawCfg.GetCSITokenPathForDynamic(volumeName))
+			if err != nil && !os.IsNotExist(err) {
+				return handleError(c, errors.Wrap(err, "read token"))
+			}
+			expected = []byte(strings.TrimSpace(string(expected)))
+			token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if !ok || len(expected) == 0 || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), expected) != 1 {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+				return c.JSON(http.StatusUnauthorized, ErrorResponse{
+					Code:    ERR_CODE_UNAUTHENTICATED,
+					Message: "missing or invalid bearer token",
+				})
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsureDynamicServerToken(t *testing.T) {
+	svc, _ := newNodeService(t)
+	cfg := svc.cfg.Get()
+	tokenPath := cfg.GetCSITokenPathForDynamic("csi-vol")
+
+	// The token isn't minted with the auth disabled.
+	require.NoError(t, ensureDynamicServerToken(cfg, "csi-vol"))
+	_, err := os.Stat(tokenPath)
+	require.True(t, os.IsNotExist(err))
+
+	cfg.Features.DynamicServerAuth = true
+	require.NoError(t, ensureDynamicServerToken(cfg, "csi-vol"))
+	token, err := os.ReadFile(tokenPath)
+	require.NoError(t, err)
+	require.Len(t, token, dynamicServerTokenSize*2)
+
+	// The token minted already is kept.
+	require.NoError(t, ensureDynamicServerToken(cfg, "csi-vol"))
+	kept, err := os.ReadFile(tokenPath)
+	require.NoError(t, err)
+	require.Equal(t, token, kept)
+}
+
+func TestDynamicServerAuth(t *testing.T) {
+	svc, _ := newNodeService(t)
+	cfg := svc.cfg.Get()
+	e := echo.New()
+	e.Use(dynamicServerAuth(svc.cfg, "csi-vol"))
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.GET("/healthz", ok)
+	e.GET("/api/v1/info", ok)
+	serve := func(path, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if authorization != "" {
+			req.Header.Set(echo.HeaderAuthorization, authorization)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The auth is disabled.
+	require.Equal(t, http.StatusOK, serve("/api/v1/info", "").Code)
+
+	cfg.Features.DynamicServerAuth = true
+	// The token isn't minted.
+	require.Equal(t, http.StatusUnauthorized, serve("/api/v1/info", "").Code)
+
+	require.NoError(t, ensureDynamicServerToken(cfg, "csi-vol"))
+	token, err := os.ReadFile(cfg.GetCSITokenPathForDynamic("csi-vol"))
+	require.NoError(t, err)
+
+	rec := serve("/api/v1/info", "")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Equal(t, "Bearer", rec.Header().Get(echo.HeaderWWWAuthenticate))
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, ERR_CODE_UNAUTHENTICATED, resp.Code)
+
+	require.Equal(t, http.StatusUnauthorized, serve("/api/v1/info", "Bearer invalid").Code)
+	require.Equal(t, http.StatusUnauthorized, serve("/api/v1/info", string(token)).Code)
+	require.Equal(t, http.StatusOK, serve("/api/v1/info", "Bearer "+string(token)).Code)
+	// The health check doesn't require the token.
+	require.Equal(t, http.StatusOK, serve("/healthz", "").Code)
+}
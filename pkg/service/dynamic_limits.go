@@ -0,0 +1,85 @@
+package service
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/labstack/echo/v4"
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"golang.org/x/time/rate"
+)
+
+// dynamicServerLimiter limits the requests of a dynamic server by
+// features.dynamic_server_limits, the limits are read per request, so that
+// the config reloaded applies.
+type dynamicServerLimiter struct {
+	cfg     *config.Config
+	svc     *Service
+	limiter *rate.Limiter
+	// The create mount requests in progress.
+	creates atomic.Int64
+}
+
+func newDynamicServerLimiter(cfg *config.Config, svc *Service) *dynamicServerLimiter {
+	return &dynamicServerLimiter{
+		cfg:     cfg,
+		svc:     svc,
+		limiter: rate.NewLimiter(rate.Inf, 0),
+	}
+}
+
+// allow returns false if the request exceeds requests_per_second.
+func (l *dynamicServerLimiter) allow(limits config.DynamicServerLimitsConfig) bool {
+	if limits.RequestsPerSecond <= 0 {
+		return true
+	}
+	burst := int(limits.Burst)
+	if burst == 0 {
+		burst = int(math.Ceil(limits.RequestsPerSecond))
+	}
+	if l.limiter.Limit() != rate.Limit(limits.RequestsPerSecond) {
+		l.limiter.SetLimit(rate.Limit(limits.RequestsPerSecond))
+	}
+	if l.limiter.Burst() != burst {
+		l.limiter.SetBurst(burst)
+	}
+	return l.limiter.Allow()
+}
+
+// isCreateMount returns true if the request creates the mounts.
+func isCreateMount(req *http.Request) bool {
+	return req.Method == http.MethodPost &&
+		(strings.HasSuffix(req.URL.Path, "/mounts") || strings.HasSuffix(req.URL.Path, "/mounts:batch"))
+}
+
+func (l *dynamicServerLimiter) middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if c.Request().URL.Path == "/healthz" {
+			return next(c)
+		}
+		limits := l.cfg.Get().Features.DynamicServerLimits
+		if !l.allow(limits) {
+			return tooManyRequests(c, "requests_per_second of the socket is exceeded")
+		}
+		if limits.MaxConcurrentCreates > 0 && isCreateMount(c.Request()) {
+			creates := l.creates.Add(1)
+			defer l.creates.Add(-1)
+			// The mounts created in the background don't hold the request.
+			pending := l.svc.pendingAsyncMounts(c.Param("volume_name"))
+			if creates+int64(pending) > int64(limits.MaxConcurrentCreates) {
+				return tooManyRequests(c, "max_concurrent_creates of the socket is exceeded")
+			}
+		}
+		return next(c)
+	}
+}
+
+func tooManyRequests(c echo.Context, message string) error {
+	c.Response().Header().Set(echo.HeaderRetryAfter, "1")
+	return c.JSON(http.StatusTooManyRequests, ErrorResponse{
+		Code:    ERR_CODE_TOO_MANY_REQUESTS,
+		Message: message,
+	})
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynamicServerLimiter(t *testing.T) {
+	svc, _ := newNodeService(t)
+	limits := &svc.cfg.Get().Features.DynamicServerLimits
+	e := echo.New()
+	limiter := newDynamicServerLimiter(svc.cfg, svc)
+	e.Use(limiter.middleware)
+	release := make(chan struct{})
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.GET("/healthz", ok)
+	e.GET("/api/v1/info", ok)
+	e.POST("/api/v1/volumes/:volume_name/mounts", func(c echo.Context) error {
+		<-release
+		return c.NoContent(http.StatusOK)
+	})
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	// Unlimited by default.
+	for i := 0; i < 10; i++ {
+		require.Equal(t, http.StatusOK, serve(http.MethodGet, "/api/v1/info").Code)
+	}
+
+	limits.RequestsPerSecond = 0.001
+	limits.Burst = 2
+	require.Equal(t, http.StatusOK, serve(http.MethodGet, "/api/v1/info").Code)
+	require.Equal(t, http.StatusOK, serve(http.MethodGet, "/api/v1/info").Code)
+	rec := serve(http.MethodGet, "/api/v1/info")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "1", rec.Header().Get(echo.HeaderRetryAfter))
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, ERR_CODE_TOO_MANY_REQUESTS, resp.Code)
+	// The health check isn't limited.
+	require.Equal(t, http.StatusOK, serve(http.MethodGet, "/healthz").Code)
+
+	// The limit is lifted on the config reload.
+	limits.RequestsPerSecond = 0
+	limits.MaxConcurrentCreates = 2
+	done := make(chan int)
+	go func() { done <- serve(http.MethodPost, "/api/v1/volumes/csi-vol/mounts").Code }()
+	// The mount created in the background counts as well.
+	svc.createMountAsync(context.Background(), modelStatus.Status{VolumeName: "csi-vol", MountID: "m1"},
+		func(ctx context.Context) error {
+			<-release
+			return nil
+		})
+	require.Eventually(t, func() bool { return limiter.creates.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, http.StatusTooManyRequests, serve(http.MethodPost, "/api/v1/volumes/csi-vol/mounts").Code)
+	// The other requests aren't limited.
+	require.Equal(t, http.StatusOK, serve(http.MethodGet, "/api/v1/info").Code)
+	close(release)
+	require.Equal(t, http.StatusOK, <-done)
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 
 	"github.com/labstack/echo/v4"
 	"github.com/modelpack/model-csi-driver/pkg/config"
@@ -16,10 +17,17 @@ import (
 )
 
 const (
-	ERR_CODE_INVALID_ARGUMENT        = "INVALID_ARGUMENT"
-	ERR_CODE_INTERNAL                = "INTERNAL"
-	ERR_CODE_NOT_FOUND               = "NOT_FOUND"
-	ERR_CODE_INSUFFICIENT_DISK_QUOTA = "INSUFFICIENT_DISK_QUOTA"
+	ERR_CODE_INVALID_ARGUMENT              = "INVALID_ARGUMENT"
+	ERR_CODE_INTERNAL                      = "INTERNAL"
+	ERR_CODE_NOT_FOUND                     = "NOT_FOUND"
+	ERR_CODE_ALREADY_EXISTS                = "ALREADY_EXISTS"
+	ERR_CODE_FAILED_PRECONDITION           = "FAILED_PRECONDITION"
+	ERR_CODE_INSUFFICIENT_DISK_QUOTA       = "INSUFFICIENT_DISK_QUOTA"
+	ERR_CODE_SIGNATURE_VERIFICATION_FAILED = "SIGNATURE_VERIFICATION_FAILED"
+	ERR_CODE_POLICY_DENIED                 = "POLICY_DENIED"
+	ERR_CODE_UNAUTHENTICATED               = "UNAUTHENTICATED"
+	ERR_CODE_TOO_MANY_REQUESTS             = "TOO_MANY_REQUESTS"
+	ERR_CODE_UNAVAILABLE                   = "UNAVAILABLE"
 )
 
 type DynamicServer struct {
@@ -28,11 +36,25 @@ type DynamicServer struct {
 	svc      *Service
 	server   *http.Server
 	listener net.Listener
+	// The dynamic volume of the socket, see dynamicServerVolumeName.
+	volumeName string
+	// Closed once the server stops serving.
+	done chan struct{}
+	// The draining of the manager, see DynamicServerManager.Shutdown.
+	draining *atomic.Bool
+	// The requests in progress.
+	inflight atomic.Int64
 }
 
 type ErrorResponse struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
+	// The reason and the metadata of the google.rpc.ErrorInfo of the error,
+	// e.g. the state and the progress of the failed pull.
+	Reason   string            `json:"reason,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// The X-Request-ID of the request, logged by the driver as well.
+	RequestID string `json:"request_id,omitempty"`
 }
 
 type DynamicServerManager struct {
@@ -41,6 +63,8 @@ type DynamicServerManager struct {
 
 	mutex   sync.Mutex
 	servers map[string]*DynamicServer
+	// Set once the servers are being shut down.
+	draining atomic.Bool
 }
 
 func NewDynamicServerManager(cfg *config.Config, svc *Service) *DynamicServerManager {
@@ -61,12 +85,13 @@ func (m *DynamicServerManager) CreateServer(ctx context.Context, sockPath string
 		delete(m.servers, sockPath)
 	}
 
-	server, err := newDynamicServer(ctx, m.cfg, m.svc, sockPath)
+	server, err := newDynamicServer(ctx, m.cfg, m.svc, sockPath, &m.draining)
 	if err != nil {
 		return nil, errors.Wrapf(err, "create http server on sock: %s", sockPath)
 	}
 
 	go func() {
+		defer close(server.done)
 		if err := server.serve(); err != nil {
 			logger.WithContext(ctx).WithError(err).Warnf("http server unexpected closed: %s", sockPath)
 			return
@@ -104,6 +129,23 @@ func (m *DynamicServerManager) CloseServer(ctx context.Context, sockPath string)
 	return nil
 }
 
+// IsServing returns true if the server on the sock is created and serving.
+func (m *DynamicServerManager) IsServing(sockPath string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	server, exists := m.servers[sockPath]
+	if !exists {
+		return false
+	}
+	select {
+	case <-server.done:
+		return false
+	default:
+		return true
+	}
+}
+
 func (m *DynamicServerManager) RecoverServers(ctx context.Context) error {
 	volumesDir := m.cfg.Get().GetVolumesDir()
 	volumeDirs, err := os.ReadDir(volumesDir)
@@ -138,6 +180,11 @@ func (m *DynamicServerManager) RecoverServers(ctx context.Context) error {
 			logger.WithContext(ctx).Infof("skip recover dynamic csi server on different device: %s", csiSockDir)
 			continue
 		}
+		// The token is minted for the volumes published before the auth is
+		// enabled.
+		if err := ensureDynamicServerToken(m.cfg.Get(), volumeName); err != nil {
+			logger.WithContext(ctx).WithError(err).Errorf("mint dynamic csi server token on: %s", csiSockDir)
+		}
 		if _, err := m.CreateServer(ctx, m.cfg.Get().GetCSISockPathForDynamic(volumeName)); err != nil {
 			logger.WithContext(ctx).WithError(err).Errorf("recover dynamic csi server on: %s", csiSockDir)
 		} else {
@@ -149,7 +196,7 @@ func (m *DynamicServerManager) RecoverServers(ctx context.Context) error {
 }
 
 func newDynamicServer(
-	ctx context.Context, cfg *config.Config, svc *Service, sockPath string,
+	ctx context.Context, cfg *config.Config, svc *Service, sockPath string, draining *atomic.Bool,
 ) (*DynamicServer, error) {
 	if err := utils.EnsureSockNotExists(ctx, sockPath); err != nil {
 		return nil, errors.Wrapf(err, "ensure socket not exists: %s", sockPath)
@@ -175,6 +222,7 @@ func newDynamicServer(
 	}
 
 	echo := echo.New()
+	echo.JSONSerializer = requestIDSerializer{}
 
 	return &DynamicServer{
 		echo: echo,
@@ -183,20 +231,29 @@ func newDynamicServer(
 		server: &http.Server{
 			Handler: echo,
 		},
-		listener: listener,
+		listener:   listener,
+		volumeName: dynamicServerVolumeName(cfg.Get(), sockPath),
+		done:       make(chan struct{}),
+		draining:   draining,
 	}, nil
 }
 
 func (s *DynamicServer) serve() error {
 	handler := &DynamicServerHandler{
-		cfg: s.cfg,
-		svc: s.svc,
+		cfg:        s.cfg,
+		svc:        s.svc,
+		volumeName: s.volumeName,
 	}
 
-	s.echo.POST("/api/v1/volumes/:volume_name/mounts", handler.CreateVolume)
-	s.echo.GET("/api/v1/volumes/:volume_name/mounts/:mount_id", handler.GetVolume)
-	s.echo.DELETE("/api/v1/volumes/:volume_name/mounts/:mount_id", handler.DeleteVolume)
-	s.echo.GET("/api/v1/volumes/:volume_name/mounts", handler.ListVolumes)
+	s.echo.Use(requestLogger(s.volumeName))
+	// The requests are rejected once draining, so that the clients retry
+	// them after the driver is restarted.
+	s.echo.Use(s.drainer)
+	// The requests are limited before authenticated, so that the rejected
+	// requests are limited as well.
+	s.echo.Use(newDynamicServerLimiter(s.cfg, s.svc).middleware)
+	s.echo.Use(dynamicServerAuth(s.cfg, s.volumeName))
+	registerRoutes(s.echo, handler)
 
 	if err := s.server.Serve(s.listener); err != nil && err != http.ErrServerClosed {
 		return errors.Wrap(err, "serve http server")
@@ -204,3 +261,39 @@ func (s *DynamicServer) serve() error {
 
 	return nil
 }
+
+// registerRoutes registers the routes of the HTTP API, see openapi.json.
+func registerRoutes(e *echo.Echo, handler *DynamicServerHandler) {
+	e.GET("/healthz", handler.Healthz)
+	e.GET("/api/v1/info", handler.GetInfo)
+	e.GET("/api/v1/openapi.json", handler.GetOpenAPI)
+	e.POST("/api/v1/volumes/:volume_name/mounts", handler.CreateVolume)
+	e.POST("/api/v1/volumes/:volume_name/mounts\\:batch", handler.CreateVolumes)
+	e.GET("/api/v1/volumes/:volume_name/mounts/:mount_id", handler.GetVolume)
+	e.PUT("/api/v1/volumes/:volume_name/mounts/:mount_id", handler.UpdateVolume)
+	e.DELETE("/api/v1/volumes/:volume_name/mounts/:mount_id", handler.DeleteVolume)
+	e.GET("/api/v1/volumes/:volume_name/mounts/:mount_id/progress/stream", handler.StreamProgress)
+	e.POST("/api/v1/volumes/:volume_name/mounts/:mount_id/refresh", handler.RefreshVolume)
+	e.POST("/api/v1/volumes/:volume_name/mounts/:mount_id/cancel", handler.CancelVolume)
+	e.GET("/api/v1/volumes/:volume_name/mounts", handler.ListVolumes)
+	e.GET("/api/v1/volumes/:volume_name/usage", handler.GetVolumeUsage)
+	// The v2 API returns the mounts as the resources with the timestamps,
+	// the size and the error of the pull.
+	e.POST("/api/v2/volumes/:volume_name/mounts", handler.CreateMountV2)
+	e.GET("/api/v2/volumes/:volume_name/mounts", handler.ListMountsV2)
+	e.GET("/api/v2/volumes/:volume_name/mounts/:mount_id", handler.GetMountV2)
+	e.DELETE("/api/v2/volumes/:volume_name/mounts/:mount_id", handler.DeleteVolume)
+	e.GET("/api/v1/models/inspect", handler.InspectModel)
+	e.GET("/api/v1/models/tags", handler.ListTags)
+	e.POST("/api/v1/prefetch", handler.Prefetch)
+	e.GET("/api/v1/prefetch", handler.ListPrefetches)
+	e.DELETE("/api/v1/prefetch/:name", handler.CancelPrefetch)
+	e.GET("/api/v1/prefetch/:name/export", handler.ExportPrefetch)
+	e.POST("/api/v1/prefetch/import", handler.ImportPrefetch)
+	e.POST("/api/v1/gc", handler.GC)
+	e.POST("/api/v1/reconcile", handler.ReconcileVolumes)
+	// The reference is passed by the query of DELETE, as it contains "/".
+	e.POST("/api/v1/pins", handler.PinModel)
+	e.GET("/api/v1/pins", handler.ListPins)
+	e.DELETE("/api/v1/pins", handler.UnpinModel)
+}
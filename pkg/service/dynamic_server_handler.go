@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,6 +10,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/labstack/echo/v4"
@@ -21,6 +24,9 @@ import (
 type DynamicServerHandler struct {
 	cfg *config.Config
 	svc *Service
+	// The dynamic volume of the socket served, empty if it's not of a
+	// dynamic volume.
+	volumeName string
 }
 
 func checkIdentifier(identifier string) bool {
@@ -35,21 +41,91 @@ func checkIdentifier(identifier string) bool {
 }
 
 func handleError(c echo.Context, err error) error {
-	if e, ok := status.FromError(err); ok && e.Code() == codes.InvalidArgument {
+	httpCode, resp := errorResponse(err)
+	return c.JSON(httpCode, resp)
+}
+
+// errorResponse returns the HTTP code and the response of the error.
+func errorResponse(err error) (int, ErrorResponse) {
+	httpCode, resp := http.StatusInternalServerError, ErrorResponse{
+		Code:    ERR_CODE_INTERNAL,
+		Message: err.Error(),
+	}
+	if e, ok := status.FromError(err); ok {
+		switch e.Code() {
+		case codes.InvalidArgument:
+			httpCode, resp = http.StatusBadRequest, ErrorResponse{Code: ERR_CODE_INVALID_ARGUMENT, Message: e.Message()}
+		case codes.ResourceExhausted:
+			httpCode, resp = http.StatusNotAcceptable, ErrorResponse{Code: ERR_CODE_INSUFFICIENT_DISK_QUOTA, Message: e.Message()}
+		case codes.PermissionDenied:
+			httpCode, resp = http.StatusForbidden, ErrorResponse{Code: ERR_CODE_SIGNATURE_VERIFICATION_FAILED, Message: e.Message()}
+		case codes.NotFound:
+			httpCode, resp = http.StatusNotFound, ErrorResponse{Code: ERR_CODE_NOT_FOUND, Message: e.Message()}
+		case codes.AlreadyExists:
+			httpCode, resp = http.StatusConflict, ErrorResponse{Code: ERR_CODE_ALREADY_EXISTS, Message: e.Message()}
+		case codes.FailedPrecondition:
+			httpCode, resp = http.StatusConflict, ErrorResponse{Code: ERR_CODE_FAILED_PRECONDITION, Message: e.Message()}
+		}
+	}
+	// The reason and the progress of the failed pull.
+	if info := errorInfo(err); info != nil {
+		resp.Reason = info.GetReason()
+		resp.Metadata = info.GetMetadata()
+	}
+	return httpCode, resp
+}
+
+// Healthz checks the driver serving the socket is healthy by the CSI Probe.
+func (h *DynamicServerHandler) Healthz(c echo.Context) error {
+	resp, err := h.svc.Probe(c.Request().Context(), &csi.ProbeRequest{})
+	if err != nil {
+		_, errResp := errorResponse(err)
+		return c.JSON(http.StatusServiceUnavailable, errResp)
+	}
+	if !resp.GetReady().GetValue() {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Code:    ERR_CODE_FAILED_PRECONDITION,
+			Message: "driver isn't ready",
+		})
+	}
+
+	return c.String(http.StatusOK, "ok")
+}
+
+// GetInfo returns the version of the driver, the node, the volume of the
+// socket and the disk quota of the node.
+func (h *DynamicServerHandler) GetInfo(c echo.Context) error {
+	info, err := h.svc.serverInfo(h.volumeName)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, info)
+}
+
+// GetVolumeUsage returns the disk usage of each mount of the volume and the
+// total of the volume.
+func (h *DynamicServerHandler) GetVolumeUsage(c echo.Context) error {
+	volumeName := c.Param("volume_name")
+
+	if !checkIdentifier(volumeName) {
 		return c.JSON(http.StatusBadRequest, ErrorResponse{
 			Code:    ERR_CODE_INVALID_ARGUMENT,
-			Message: e.Message(),
-		})
-	} else if ok && e.Code() == codes.ResourceExhausted {
-		return c.JSON(http.StatusNotAcceptable, ErrorResponse{
-			Code:    ERR_CODE_INSUFFICIENT_DISK_QUOTA,
-			Message: e.Message(),
+			Message: "volume_name is invalid",
 		})
 	}
-	return c.JSON(http.StatusInternalServerError, ErrorResponse{
-		Code:    ERR_CODE_INTERNAL,
-		Message: err.Error(),
-	})
+
+	usage, err := h.svc.GetVolumeUsage(c.Request().Context(), volumeName)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, usage)
+}
+
+// GetOpenAPI returns the OpenAPI specification of the API.
+func (h *DynamicServerHandler) GetOpenAPI(c echo.Context) error {
+	return c.JSONBlob(http.StatusOK, openAPISpec)
 }
 
 func (h *DynamicServerHandler) CreateVolume(c echo.Context) error {
@@ -70,44 +146,187 @@ func (h *DynamicServerHandler) CreateVolume(c echo.Context) error {
 		})
 	}
 
+	// Return once the pull is started instead of blocking until it's done.
+	async, err := queryBool(c, "async")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "async is invalid",
+		})
+	}
+
+	code, resp := h.createMount(c.Request().Context(), volumeName, req, async)
+	return c.JSON(code, resp)
+}
+
+// queryBool returns the bool value of the query parameter, false if it's
+// not set.
+func queryBool(c echo.Context, name string) (bool, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// createMount creates the mount of the request and returns the HTTP code
+// with the mount created, or with the ErrorResponse if it's failed.
+func (h *DynamicServerHandler) createMount(ctx context.Context, volumeName string, req *MountRequest, async bool) (int, interface{}) {
 	req.MountID = strings.TrimSpace(req.MountID)
 	req.Reference = strings.TrimSpace(req.Reference)
+	req.Type = strings.TrimSpace(req.Type)
+	if req.Type == "" {
+		req.Type = ModelTypeImage
+	}
 
 	if !checkIdentifier(req.MountID) {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
+		return http.StatusBadRequest, ErrorResponse{
 			Code:    ERR_CODE_INVALID_ARGUMENT,
 			Message: "mount_id is invalid",
-		})
+		}
 	}
 
 	if req.Reference == "" {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
+		return http.StatusBadRequest, ErrorResponse{
 			Code:    ERR_CODE_INVALID_ARGUMENT,
 			Message: "reference is invalid",
-		})
+		}
+	}
+
+	for _, patterns := range [][]string{req.ExcludeFilePatterns, req.IncludeFilePatterns} {
+		if err := validateFilePatterns(patterns); err != nil {
+			return http.StatusBadRequest, ErrorResponse{
+				Code:    ERR_CODE_INVALID_ARGUMENT,
+				Message: err.Error(),
+			}
+		}
+	}
+
+	adapters, err := validateAdapters(req.Type, req.Adapters)
+	if err != nil {
+		return http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: err.Error(),
+		}
+	}
+
+	if err := validateLabels(req.Labels); err != nil {
+		return http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: err.Error(),
+		}
+	}
+
+	req.CallbackURL = strings.TrimSpace(req.CallbackURL)
+	if err := validateCallback(req.CallbackURL); err != nil {
+		return http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: err.Error(),
+		}
+	}
+	adaptersJSON, err := json.Marshal(adapters)
+	if err != nil {
+		return http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "invalid adapters",
+		}
 	}
 
 	excludeFilePatternsJSON, err := json.Marshal(req.ExcludeFilePatterns)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
+		return http.StatusBadRequest, ErrorResponse{
 			Code:    ERR_CODE_INVALID_ARGUMENT,
 			Message: "invalid exclude_file_patterns",
-		})
+		}
+	}
+	includeFilePatternsJSON, err := json.Marshal(req.IncludeFilePatterns)
+	if err != nil {
+		return http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "invalid include_file_patterns",
+		}
+	}
+
+	// The adapters are admitted by the policy like the base model.
+	for _, model := range append([]modelStatus.Adapter{{Reference: req.Reference, Type: req.Type}}, adapters...) {
+		if err := h.svc.admitModel(ctx, model.Type, model.Reference, volumeName, req.MountID); err != nil {
+			if e, ok := status.FromError(err); ok && e.Code() == codes.PermissionDenied {
+				return http.StatusForbidden, ErrorResponse{
+					Code:    ERR_CODE_POLICY_DENIED,
+					Message: e.Message(),
+				}
+			}
+			return errorResponse(err)
+		}
 	}
 
-	_, err = h.svc.CreateVolume(c.Request().Context(), &csi.CreateVolumeRequest{
+	createReq := &csi.CreateVolumeRequest{
 		Name: volumeName,
 		Parameters: map[string]string{
-			h.cfg.Get().ParameterKeyType():                 "image",
+			h.cfg.Get().ParameterKeyType():                 req.Type,
 			h.cfg.Get().ParameterKeyReference():            req.Reference,
 			h.cfg.Get().ParameterKeyMountID():              req.MountID,
 			h.cfg.Get().ParameterKeyCheckDiskQuota():       strconv.FormatBool(req.CheckDiskQuota),
-			h.cfg.Get().ParameterKeyExcludeModelWeights():  strconv.FormatBool(req.ExcludeModelWeights),
+			h.cfg.Get().ParameterKeyExcludeModelWeights():  strconv.FormatBool(req.ExcludeModelWeights || req.ExcludeWeights),
 			h.cfg.Get().ParameterKeyExcludeFilePatterns():  string(excludeFilePatternsJSON),
+			h.cfg.Get().ParameterKeyIncludeFilePatterns():  string(includeFilePatternsJSON),
+			h.cfg.Get().ParameterKeyPriority():             strconv.Itoa(req.Priority),
+			h.cfg.Get().ParameterKeyBackgroundWeights():    strconv.FormatBool(req.BackgroundWeights),
+			h.cfg.Get().ParameterKeyAdapters():             string(adaptersJSON),
+			h.cfg.Get().ParameterKeyPlatform():             req.Platform,
+			h.cfg.Get().ParameterKeyPullTimeoutInSeconds(): strconv.FormatUint(uint64(req.PullTimeoutInSeconds), 10),
 		},
-	})
-	if err != nil {
-		return handleError(c, err)
+	}
+	// The labels set before are kept if they're not set.
+	if req.Labels != nil {
+		labelsJSON, err := json.Marshal(req.Labels)
+		if err != nil {
+			return http.StatusBadRequest, ErrorResponse{
+				Code:    ERR_CODE_INVALID_ARGUMENT,
+				Message: "invalid labels",
+			}
+		}
+		createReq.Parameters[h.cfg.Get().ParameterKeyLabels()] = string(labelsJSON)
+	}
+	create := func(ctx context.Context) error {
+		err := func() error {
+			if _, err := h.svc.CreateVolume(withPolicyAdmitted(ctx), createReq); err != nil {
+				return err
+			}
+			// Creating the mount again refreshes the TTL.
+			return h.svc.setMountTTL(volumeName, req.MountID, req.TTLSeconds)
+		}()
+		if req.CallbackURL != "" {
+			// The callback outlives the request.
+			go h.svc.notifyMount(context.WithoutCancel(ctx), req.CallbackURL, modelStatus.Status{
+				VolumeName: volumeName,
+				MountID:    req.MountID,
+				Reference:  req.Reference,
+				Adapters:   adapters,
+				Labels:     req.Labels,
+			}, err)
+		}
+		return err
+	}
+
+	if async {
+		// The mount is polled by GetMount until it's pulled or failed.
+		createdAt := time.Now()
+		mount := modelStatus.Status{
+			VolumeName: volumeName,
+			MountID:    req.MountID,
+			Reference:  req.Reference,
+			State:      modelStatus.StatePullRunning,
+			Adapters:   adapters,
+			Labels:     req.Labels,
+			CreatedAt:  &createdAt,
+		}
+		h.svc.createMountAsync(ctx, mount, create)
+		return http.StatusAccepted, mount
+	}
+
+	if err := create(ctx); err != nil {
+		return errorResponse(err)
 	}
 
 	mount := modelStatus.Status{
@@ -115,9 +334,155 @@ func (h *DynamicServerHandler) CreateVolume(c echo.Context) error {
 		MountID:    req.MountID,
 		Reference:  req.Reference,
 		State:      modelStatus.StatePullSucceeded,
+		Adapters:   adapters,
+		Labels:     req.Labels,
+		ExpiresAt:  h.svc.mountExpiresAt(ctx, volumeName, req.MountID),
+	}
+	if req.BackgroundWeights {
+		// The weights may be still pulled in the background.
+		if volumeStatus, err := h.svc.GetDynamicVolume(ctx, volumeName, req.MountID); err == nil {
+			mount.State = volumeStatus.State
+		}
 	}
 
-	return c.JSON(http.StatusCreated, mount)
+	return http.StatusCreated, mount
+}
+
+// CreateVolumes creates the mounts of the batch concurrently, the mounts of
+// the same model share the pull of it. The result of each mount is the same
+// as it's created alone.
+func (h *DynamicServerHandler) CreateVolumes(c echo.Context) error {
+	volumeName := c.Param("volume_name")
+
+	if !checkIdentifier(volumeName) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "volume_name is invalid",
+		})
+	}
+
+	req := new(BatchMountRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "invalid JSON body",
+		})
+	}
+
+	async, err := queryBool(c, "async")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "async is invalid",
+		})
+	}
+
+	if len(req.Mounts) == 0 || len(req.Mounts) > maxBatchMounts {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: fmt.Sprintf("mounts must have 1 to %d items", maxBatchMounts),
+		})
+	}
+	mountIDs := map[string]bool{}
+	for i := range req.Mounts {
+		mountID := strings.TrimSpace(req.Mounts[i].MountID)
+		if mountIDs[mountID] {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Code:    ERR_CODE_INVALID_ARGUMENT,
+				Message: fmt.Sprintf("duplicate mount_id: %s", mountID),
+			})
+		}
+		mountIDs[mountID] = true
+	}
+
+	ctx := c.Request().Context()
+	results := make([]BatchMountResult, len(req.Mounts))
+	var wg sync.WaitGroup
+	for i := range req.Mounts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			code, resp := h.createMount(ctx, volumeName, &req.Mounts[i], async)
+			result := BatchMountResult{MountID: req.Mounts[i].MountID, StatusCode: code}
+			switch resp := resp.(type) {
+			case modelStatus.Status:
+				result.Mount = &resp
+			case ErrorResponse:
+				result.Error = &resp
+			}
+			results[i] = result
+		}(i)
+	}
+	wg.Wait()
+
+	return c.JSON(http.StatusOK, BatchMountResponse{Results: results})
+}
+
+// UpdateVolume pulls the model of the existing mount again. With force, the
+// model of the mount is replaced even by another reference, the pull in
+// progress is canceled and the model pulled before is recorded in the history
+// of the mount.
+func (h *DynamicServerHandler) UpdateVolume(c echo.Context) error {
+	volumeName := c.Param("volume_name")
+	mountID := c.Param("mount_id")
+
+	if !checkIdentifier(volumeName) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "volume_name is invalid",
+		})
+	}
+
+	if !checkIdentifier(mountID) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "mount_id is invalid",
+		})
+	}
+
+	req := new(UpdateMountRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "invalid JSON body",
+		})
+	}
+	if bodyMountID := strings.TrimSpace(req.MountID); bodyMountID != "" && bodyMountID != mountID {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "mount_id mismatches the path",
+		})
+	}
+	req.MountID = mountID
+
+	async, err := queryBool(c, "async")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "async is invalid",
+		})
+	}
+
+	ctx := c.Request().Context()
+	// The failed mount created asynchronously can be updated too.
+	if _, err := h.svc.getMount(ctx, volumeName, mountID); errors.Is(err, os.ErrNotExist) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Code:    ERR_CODE_NOT_FOUND,
+			Message: fmt.Sprintf("volume_name %s with mount_id %s is not found", volumeName, mountID),
+		})
+	}
+
+	if req.Force {
+		// The mount being created asynchronously is replaced as well.
+		h.svc.forgetAsyncMount(volumeName, mountID)
+		ctx = withModelReplaced(ctx)
+	}
+	code, resp := h.createMount(ctx, volumeName, &req.MountRequest, async)
+	if code == http.StatusCreated {
+		code = http.StatusOK
+	}
+
+	return c.JSON(code, resp)
 }
 
 func (h *DynamicServerHandler) GetVolume(c echo.Context) error {
@@ -138,7 +503,7 @@ func (h *DynamicServerHandler) GetVolume(c echo.Context) error {
 		})
 	}
 
-	status, err := h.svc.GetDynamicVolume(c.Request().Context(), volumeName, mountID)
+	status, err := h.svc.getMount(c.Request().Context(), volumeName, mountID)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return c.JSON(http.StatusNotFound, ErrorResponse{
@@ -152,6 +517,100 @@ func (h *DynamicServerHandler) GetVolume(c echo.Context) error {
 	return c.JSON(http.StatusOK, status)
 }
 
+// StreamProgress streams the mount by the server-sent events until its pull
+// is done, so that the callers don't poll GetVolume.
+func (h *DynamicServerHandler) StreamProgress(c echo.Context) error {
+	volumeName := c.Param("volume_name")
+	mountID := c.Param("mount_id")
+
+	if !checkIdentifier(volumeName) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "volume_name is invalid",
+		})
+	}
+
+	if !checkIdentifier(mountID) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "mount_id is invalid",
+		})
+	}
+
+	ctx := c.Request().Context()
+	getMount := func() (*modelStatus.Status, error) {
+		return h.svc.getMount(ctx, volumeName, mountID)
+	}
+	mount, err := getMount()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return c.JSON(http.StatusNotFound, ErrorResponse{
+				Code:    ERR_CODE_NOT_FOUND,
+				Message: fmt.Sprintf("volume_name %s with mount_id %s is not found", volumeName, mountID),
+			})
+		}
+		return handleError(c, err)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	c.Response().WriteHeader(http.StatusOK)
+	return streamProgress(ctx, c.Response(), mount, getMount)
+}
+
+func (h *DynamicServerHandler) RefreshVolume(c echo.Context) error {
+	volumeName := c.Param("volume_name")
+	mountID := c.Param("mount_id")
+
+	if !checkIdentifier(volumeName) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "volume_name is invalid",
+		})
+	}
+
+	if !checkIdentifier(mountID) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "mount_id is invalid",
+		})
+	}
+
+	if err := h.svc.RefreshMount(c.Request().Context(), volumeName, mountID); err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(http.StatusNoContent, nil)
+}
+
+// CancelVolume cancels the pull in progress of the mount, the mount is kept
+// in PULL_CANCELED state instead of being deleted.
+func (h *DynamicServerHandler) CancelVolume(c echo.Context) error {
+	volumeName := c.Param("volume_name")
+	mountID := c.Param("mount_id")
+
+	if !checkIdentifier(volumeName) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "volume_name is invalid",
+		})
+	}
+
+	if !checkIdentifier(mountID) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "mount_id is invalid",
+		})
+	}
+
+	mount, err := h.svc.CancelPull(c.Request().Context(), volumeName, mountID)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, mount)
+}
+
 func (h *DynamicServerHandler) DeleteVolume(c echo.Context) error {
 	volumeName := c.Param("volume_name")
 	mountID := c.Param("mount_id")
@@ -170,11 +629,17 @@ func (h *DynamicServerHandler) DeleteVolume(c echo.Context) error {
 		})
 	}
 
-	volumeID := fmt.Sprintf("%s/%s", volumeName, mountID)
-	_, err := h.svc.DeleteVolume(c.Request().Context(), &csi.DeleteVolumeRequest{
-		VolumeId: volumeID,
-	})
+	// The mount being pulled is only deleted by force, which cancels the
+	// pull first.
+	force, err := queryBool(c, "force")
 	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "force is invalid",
+		})
+	}
+
+	if err := h.svc.DeleteMount(c.Request().Context(), volumeName, mountID, force); err != nil {
 		return handleError(c, err)
 	}
 
@@ -191,10 +656,329 @@ func (h *DynamicServerHandler) ListVolumes(c echo.Context) error {
 		})
 	}
 
-	statuses, err := h.svc.ListDynamicVolumes(c.Request().Context(), volumeName)
+	req := new(ListMountsRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "invalid query",
+		})
+	}
+
+	statuses, total, err := h.svc.ListMounts(c.Request().Context(), volumeName, *req)
 	if err != nil {
 		return handleError(c, err)
 	}
 
+	// The number of the mounts matching the filters, for the pagination.
+	c.Response().Header().Set("X-Total-Count", strconv.Itoa(total))
 	return c.JSON(http.StatusOK, statuses)
 }
+
+// InspectModel returns the manifest-level info of the model of the reference
+// query without pulling it.
+func (h *DynamicServerHandler) InspectModel(c echo.Context) error {
+	inspection, err := h.svc.InspectModel(c.Request().Context(), c.QueryParam("reference"))
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, inspection)
+}
+
+// ListTags lists the tags of the model repository by pages by the registry
+// auth of the node.
+func (h *DynamicServerHandler) ListTags(c echo.Context) error {
+	req := new(ListTagsRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "invalid query",
+		})
+	}
+
+	resp, err := h.svc.ListTags(c.Request().Context(), *req)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *DynamicServerHandler) Prefetch(c echo.Context) error {
+	req := new(PrefetchRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "invalid JSON body",
+		})
+	}
+
+	req.Type = strings.TrimSpace(req.Type)
+	if req.Type == "" {
+		req.Type = ModelTypeImage
+	}
+
+	prefetchStatus, err := h.svc.Prefetch(c.Request().Context(), *req)
+	if err != nil {
+		// The model is only admitted by the policy, the signature is verified
+		// by the pull in the background.
+		if e, ok := status.FromError(err); ok && e.Code() == codes.PermissionDenied {
+			return c.JSON(http.StatusForbidden, ErrorResponse{
+				Code:    ERR_CODE_POLICY_DENIED,
+				Message: e.Message(),
+			})
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(http.StatusAccepted, prefetchStatus)
+}
+
+func (h *DynamicServerHandler) ListPrefetches(c echo.Context) error {
+	statuses, err := h.svc.ListPrefetches(c.Request().Context())
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, statuses)
+}
+
+func (h *DynamicServerHandler) CancelPrefetch(c echo.Context) error {
+	name := c.Param("name")
+
+	if !checkIdentifier(name) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "name is invalid",
+		})
+	}
+
+	if err := h.svc.CancelPrefetch(c.Request().Context(), name); err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *DynamicServerHandler) ExportPrefetch(c echo.Context) error {
+	name := c.Param("name")
+
+	if !checkIdentifier(name) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "name is invalid",
+		})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "application/x-tar")
+	if err := h.svc.ExportCache(c.Request().Context(), name, c.Response()); err != nil {
+		// The tarball written partially is rejected on import by the
+		// files missing from the manifest.
+		if c.Response().Committed {
+			return err
+		}
+		return handleError(c, err)
+	}
+
+	return nil
+}
+
+func (h *DynamicServerHandler) ImportPrefetch(c echo.Context) error {
+	prefetchStatus, err := h.svc.ImportCache(c.Request().Context(), c.Request().Body, c.QueryParam("digest"))
+	if err != nil {
+		if e, ok := status.FromError(err); ok && e.Code() == codes.PermissionDenied {
+			return c.JSON(http.StatusForbidden, ErrorResponse{
+				Code:    ERR_CODE_POLICY_DENIED,
+				Message: e.Message(),
+			})
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, prefetchStatus)
+}
+
+func (h *DynamicServerHandler) GC(c echo.Context) error {
+	req := new(GCRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "invalid JSON body",
+		})
+	}
+
+	resp, err := h.svc.GC(c.Request().Context(), *req)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *DynamicServerHandler) ReconcileVolumes(c echo.Context) error {
+	req := new(ReconcileRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "invalid JSON body",
+		})
+	}
+
+	resp, err := h.svc.ReconcileVolumes(c.Request().Context(), *req)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *DynamicServerHandler) PinModel(c echo.Context) error {
+	req := new(PinRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "invalid JSON body",
+		})
+	}
+
+	if err := h.svc.PinModel(c.Request().Context(), req.Reference); err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *DynamicServerHandler) UnpinModel(c echo.Context) error {
+	if err := h.svc.UnpinModel(c.Request().Context(), c.QueryParam("reference")); err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *DynamicServerHandler) ListPins(c echo.Context) error {
+	references, err := h.svc.ListPins(c.Request().Context())
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, references)
+}
+
+// CreateMountV2 creates the mount like CreateVolume, and returns the mount
+// as the resource of the v2 API.
+func (h *DynamicServerHandler) CreateMountV2(c echo.Context) error {
+	volumeName := c.Param("volume_name")
+
+	if !checkIdentifier(volumeName) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "volume_name is invalid",
+		})
+	}
+
+	req := new(MountRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "invalid JSON body",
+		})
+	}
+
+	async, err := queryBool(c, "async")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "async is invalid",
+		})
+	}
+
+	ctx := c.Request().Context()
+	code, resp := h.createMount(ctx, volumeName, req, async)
+	mount, ok := resp.(modelStatus.Status)
+	if !ok {
+		return c.JSON(code, resp)
+	}
+	// The timestamps and the size of the mount created are in its status.
+	if !async {
+		if volumeStatus, err := h.svc.GetDynamicVolume(ctx, volumeName, req.MountID); err == nil {
+			mount = *volumeStatus
+		}
+	}
+
+	return c.JSON(code, newMountResource(&mount))
+}
+
+// GetMountV2 returns the mount as the resource of the v2 API, the failed
+// mount created in the background is returned with its error.
+func (h *DynamicServerHandler) GetMountV2(c echo.Context) error {
+	volumeName := c.Param("volume_name")
+	mountID := c.Param("mount_id")
+
+	if !checkIdentifier(volumeName) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "volume_name is invalid",
+		})
+	}
+
+	if !checkIdentifier(mountID) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "mount_id is invalid",
+		})
+	}
+
+	mount, err := h.svc.getMount(c.Request().Context(), volumeName, mountID)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return c.JSON(http.StatusNotFound, ErrorResponse{
+				Code:    ERR_CODE_NOT_FOUND,
+				Message: fmt.Sprintf("volume_name %s with mount_id %s is not found", volumeName, mountID),
+			})
+		}
+		failed, pullErr := h.svc.failedAsyncMount(volumeName, mountID)
+		if failed == nil {
+			return handleError(c, err)
+		}
+		resource := newMountResource(failed)
+		_, errResp := errorResponse(pullErr)
+		resource.Error = &errResp
+		return c.JSON(http.StatusOK, resource)
+	}
+
+	return c.JSON(http.StatusOK, newMountResource(mount))
+}
+
+// ListMountsV2 lists the mounts like ListVolumes, and returns them as the
+// resources of the v2 API.
+func (h *DynamicServerHandler) ListMountsV2(c echo.Context) error {
+	volumeName := c.Param("volume_name")
+
+	if !checkIdentifier(volumeName) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "volume_name is invalid",
+		})
+	}
+
+	req := new(ListMountsRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Code:    ERR_CODE_INVALID_ARGUMENT,
+			Message: "invalid query",
+		})
+	}
+
+	statuses, total, err := h.svc.ListMounts(c.Request().Context(), volumeName, *req)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	resources := make([]MountResource, 0, len(statuses))
+	for i := range statuses {
+		resources = append(resources, newMountResource(&statuses[i]))
+	}
+	c.Response().Header().Set("X-Total-Count", strconv.Itoa(total))
+	return c.JSON(http.StatusOK, resources)
+}
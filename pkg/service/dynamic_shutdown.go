@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	// The interval to check the requests in progress and the mounts created
+	// in the background are done on draining the dynamic servers.
+	dynamicServerDrainInterval = 100 * time.Millisecond
+	// The seconds for the clients to retry the requests rejected by the
+	// draining server, i.e. after the driver is restarted.
+	dynamicServerRetryAfter = 5
+)
+
+// IsDraining returns true if the dynamic servers are being shut down.
+func (m *DynamicServerManager) IsDraining() bool {
+	return m.draining.Load()
+}
+
+// Shutdown drains the dynamic servers on the driver exiting, e.g. on SIGTERM.
+// The new requests are rejected by 503 to be retried after the restart, while
+// the servers keep listening, so that the clients tell the draining from the
+// failures. The requests in progress, e.g. the mounts pulled synchronously,
+// and the mounts created in the background are waited for until the context
+// is done, the pulls interrupted after then are resumed by their pull state
+// once the mounts are requested again. The servers are closed at last.
+func (m *DynamicServerManager) Shutdown(ctx context.Context) error {
+	m.draining.Store(true)
+
+	m.mutex.Lock()
+	servers := make(map[string]*DynamicServer, len(m.servers))
+	for sockPath, server := range m.servers {
+		servers[sockPath] = server
+	}
+	m.mutex.Unlock()
+
+	logger.WithContext(ctx).Infof("draining %d dynamic servers", len(servers))
+
+	ticker := time.NewTicker(dynamicServerDrainInterval)
+	defer ticker.Stop()
+	for !m.isDrained(servers) {
+		select {
+		case <-ctx.Done():
+			logger.WithContext(ctx).Warnf("dynamic servers not drained before the shutdown timeout, closing them")
+			m.closeServers(ctx, servers)
+			return errors.Wrap(ctx.Err(), "drain dynamic servers")
+		case <-ticker.C:
+		}
+	}
+
+	eg := errgroup.Group{}
+	for sockPath, server := range servers {
+		eg.Go(func() error {
+			if err := server.server.Shutdown(ctx); err != nil {
+				_ = server.server.Close()
+				return errors.Wrapf(err, "shutdown http server on sock: %s", sockPath)
+			}
+			return nil
+		})
+	}
+	err := eg.Wait()
+	m.closeServers(ctx, servers)
+
+	logger.WithContext(ctx).Infof("drained dynamic servers")
+
+	return err
+}
+
+// isDrained returns true if the servers have no request in progress, and
+// their volumes have no mount created in the background.
+func (m *DynamicServerManager) isDrained(servers map[string]*DynamicServer) bool {
+	for _, server := range servers {
+		if server.inflight.Load() > 0 {
+			return false
+		}
+		if server.volumeName != "" && m.svc.pendingAsyncMounts(server.volumeName) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *DynamicServerManager) closeServers(ctx context.Context, servers map[string]*DynamicServer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for sockPath, server := range servers {
+		_ = server.server.Close()
+		if err := server.listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			logger.WithContext(ctx).WithError(err).Warnf("close listener on sock: %s", sockPath)
+		}
+		if m.servers[sockPath] == server {
+			delete(m.servers, sockPath)
+		}
+	}
+}
+
+// drainer tracks the requests in progress of the dynamic server, and rejects
+// the new requests once the servers are draining. The request is counted
+// before the draining is checked, so that the request seen as not draining
+// is always waited for by Shutdown.
+func (s *DynamicServer) drainer(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		s.inflight.Add(1)
+		defer s.inflight.Add(-1)
+		if s.draining.Load() {
+			c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(dynamicServerRetryAfter))
+			return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+				Code:    ERR_CODE_UNAVAILABLE,
+				Message: "driver is shutting down, retry later",
+			})
+		}
+		return next(c)
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func newUnixHTTPClient(sockPath string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", sockPath)
+			},
+			DisableKeepAlives: true,
+		},
+		Timeout: 5 * time.Second,
+	}
+}
+
+func TestDynamicServerManager_Shutdown(t *testing.T) {
+	origInterval := dynamicServerDrainInterval
+	dynamicServerDrainInterval = 10 * time.Millisecond
+	t.Cleanup(func() { dynamicServerDrainInterval = origInterval })
+
+	mgr, tmpDir := newTestDynamicServerManager(t)
+	sockPath := filepath.Join(tmpDir, "drain.sock")
+	server, err := mgr.CreateServer(context.Background(), sockPath)
+	require.NoError(t, err)
+	client := newUnixHTTPClient(sockPath)
+
+	require.Eventually(t, func() bool {
+		resp, err := client.Get("http://unix/healthz")
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The request in progress is waited for by the shutdown.
+	server.inflight.Add(1)
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- mgr.Shutdown(context.Background()) }()
+	require.Eventually(t, mgr.IsDraining, time.Second, 10*time.Millisecond)
+
+	// The new requests are rejected to be retried while draining.
+	resp, err := client.Get("http://unix/api/v1/info")
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, "5", resp.Header.Get(echo.HeaderRetryAfter))
+	var errResp ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+	require.Equal(t, ERR_CODE_UNAVAILABLE, errResp.Code)
+
+	select {
+	case <-shutdown:
+		t.Fatal("shutdown returned before the request in progress is done")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	server.inflight.Add(-1)
+	select {
+	case err := <-shutdown:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown not returned after the request in progress is done")
+	}
+	require.Eventually(t, func() bool { return !mgr.IsServing(sockPath) }, time.Second, 10*time.Millisecond)
+}
+
+func TestDynamicServerManager_Shutdown_Timeout(t *testing.T) {
+	origInterval := dynamicServerDrainInterval
+	dynamicServerDrainInterval = 10 * time.Millisecond
+	t.Cleanup(func() { dynamicServerDrainInterval = origInterval })
+
+	mgr, tmpDir := newTestDynamicServerManager(t)
+	sockPath := filepath.Join(tmpDir, "timeout.sock")
+	server, err := mgr.CreateServer(context.Background(), sockPath)
+	require.NoError(t, err)
+
+	// The server is closed even if the request in progress isn't done.
+	server.inflight.Add(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, mgr.Shutdown(ctx), context.DeadlineExceeded)
+	require.Eventually(t, func() bool { return !mgr.IsServing(sockPath) }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/metrics"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+)
+
+// The interval to check the disk usage against the high watermark if it's not
+// configured.
+var EvictionInterval = time.Minute
+
+// diskUsage returns the used and total size of the root dir, by the
+// disk_usage_limit if it's set, otherwise by the file system, summed with the
+// storage tiers.
+func diskUsage(cfg *config.RawConfig) (int64, int64, error) {
+	used, total, err := dirDiskUsage(cfg.RootDir, cfg.Features.DiskUsageLimit)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "get root dir disk usage")
+	}
+	for _, tier := range cfg.StorageTiers {
+		tierUsed, tierTotal, err := dirDiskUsage(tier.RootDir, tier.DiskUsageLimit)
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "get disk usage of storage tier: %s", tier.Name)
+		}
+		used += tierUsed
+		total += tierTotal
+	}
+	return used, total, nil
+}
+
+// dirDiskUsage returns the used and total size of the dir by the limit if
+// it's set, otherwise by the file system.
+func dirDiskUsage(dir string, limit config.HumanizeSize) (int64, int64, error) {
+	if limit > 0 {
+		used, err := getUsedSize(dir)
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "get used size: %s", dir)
+		}
+		return used, int64(limit), nil
+	}
+
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, 0, errors.Wrapf(err, "stat dir: %s", dir)
+	}
+	total := int64(st.Blocks) * int64(st.Bsize)
+	return total - int64(st.Bavail)*int64(st.Bsize), total, nil
+}
+
+type evictionCandidate struct {
+	name      string
+	reference string
+	size      int64
+	// The last time the model is pulled or cloned into a volume.
+	lastUsed time.Time
+}
+
+// evictionCandidates returns the prefetched models which can be evicted in
+// the order of the policy, the models being pulled, the warm models and the
+// pinned models are never evicted.
+func (worker *Worker) evictionCandidates(ctx context.Context) ([]evictionCandidate, error) {
+	cfg := worker.cfg.Get()
+	warmModels := map[string]bool{}
+	for _, model := range cfg.WarmModels.Models {
+		warmModels[model.Reference] = true
+	}
+	pins, err := worker.pinnedReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	volumesDir := cfg.GetVolumesDir()
+	entries, err := os.ReadDir(volumesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "read volume dirs from %s", volumesDir)
+	}
+
+	candidates := []evictionCandidate{}
+	for _, entry := range entries {
+		if !isVolumeDirEntry(volumesDir, entry) || !isPrefetchVolume(entry.Name()) {
+			continue
+		}
+		volumeDir := cfg.GetVolumeDir(entry.Name())
+		modelStatus, err := worker.sm.Get(filepath.Join(volumeDir, "status.json"))
+		if err != nil || modelStatus.State != status.StatePullSucceeded || warmModels[modelStatus.Reference] || pins[modelStatus.Reference] {
+			continue
+		}
+		modelDir := filepath.Join(volumeDir, "model")
+		info, err := os.Stat(modelDir)
+		if err != nil {
+			continue
+		}
+		size, err := getUsedSize(volumeDir)
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("failed to get used size: %s", volumeDir)
+			continue
+		}
+		candidates = append(candidates, evictionCandidate{
+			name:      entry.Name(),
+			reference: modelStatus.Reference,
+			size:      size,
+			lastUsed:  info.ModTime(),
+		})
+	}
+
+	if cfg.Features.Eviction.Policy == config.EvictionPolicyLargest {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].size > candidates[j].size
+		})
+	} else {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].lastUsed.Before(candidates[j].lastUsed)
+		})
+	}
+
+	return candidates, nil
+}
+
+// evictModels evicts the prefetched models in the order of the policy until
+// the disk usage drops to the target size, it returns the number of the
+// evicted models. It does nothing unless the eviction is enabled.
+func (worker *Worker) evictModels(ctx context.Context, targetUsedSize int64) (int, error) {
+	if !worker.cfg.Get().Features.Eviction.Enabled {
+		return 0, nil
+	}
+	used, _, err := diskUsage(worker.cfg.Get())
+	if err != nil {
+		return 0, err
+	}
+	if used <= targetUsedSize {
+		return 0, nil
+	}
+
+	candidates, err := worker.evictionCandidates(ctx)
+	if err != nil {
+		return 0, err
+	}
+	evicted := 0
+	for _, candidate := range candidates {
+		if used <= targetUsedSize {
+			break
+		}
+		if err := worker.DeleteModel(ctx, true, candidate.name, ""); err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("failed to evict model: %s", candidate.name)
+			continue
+		}
+		metrics.NodeEvictedModels.Inc()
+		evicted++
+		logger.WithContext(ctx).Infof(
+			"evicted model %s (%s) last used at %s, size: %s",
+			candidate.reference, candidate.name, candidate.lastUsed.Format(time.RFC3339), humanizeBytes(candidate.size),
+		)
+		if used, _, err = diskUsage(worker.cfg.Get()); err != nil {
+			return evicted, err
+		}
+	}
+
+	return evicted, nil
+}
+
+// evictModelsLoop evicts the models once the disk usage exceeds the high
+// watermark, until it drops below the low watermark. The watermarks and the
+// interval are picked up on the config reload.
+func (s *Service) evictModelsLoop() {
+	for {
+		eviction := s.cfg.Get().Features.Eviction
+		interval := time.Duration(eviction.IntervalInSeconds) * time.Second
+		if interval == 0 {
+			interval = EvictionInterval
+		}
+		time.Sleep(interval)
+		if err := s.evictModelsByWatermark(context.Background()); err != nil {
+			logger.Logger().WithError(err).Warnf("evict models failed")
+		}
+	}
+}
+
+func (s *Service) evictModelsByWatermark(ctx context.Context) error {
+	eviction := s.cfg.Get().Features.Eviction
+	if !eviction.Enabled {
+		return nil
+	}
+	used, total, err := diskUsage(s.cfg.Get())
+	if err != nil {
+		return err
+	}
+	if used*100 <= total*int64(eviction.HighWatermarkPercent) {
+		return nil
+	}
+
+	ctx = logger.NewContext(ctx, "EvictModels", "", "")
+	logger.WithContext(ctx).Infof(
+		"disk usage %s of %s exceeds the high watermark %d%%",
+		humanizeBytes(used), humanizeBytes(total), eviction.HighWatermarkPercent,
+	)
+	_, err = s.worker.evictModels(ctx, total*int64(eviction.LowWatermarkPercent)/100)
+	return err
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func newPrefetchedModel(t *testing.T, svc *Service, name, reference string, size int, lastUsed time.Time) {
+	volumeDir := svc.cfg.Get().GetVolumeDir(name)
+	modelDir := filepath.Join(volumeDir, "model")
+	require.NoError(t, os.MkdirAll(modelDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(modelDir, "model.safetensors"), make([]byte, size), 0644))
+	_, err := svc.sm.Set(filepath.Join(volumeDir, "status.json"), status.Status{
+		VolumeName: name,
+		Reference:  reference,
+		State:      status.StatePullSucceeded,
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.Chtimes(modelDir, lastUsed, lastUsed))
+}
+
+func TestEvictModels(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	cfg := svc.cfg.Get()
+	cfg.Features.DiskUsageLimit = 100 << 20
+	cfg.Features.Eviction = config.EvictionConfig{Enabled: true, Policy: config.EvictionPolicyLRU}
+	cfg.WarmModels.Models = []config.WarmModel{{Reference: "test/warm:latest"}}
+
+	now := time.Now()
+	newPrefetchedModel(t, svc, "prefetch-old", "test/old:latest", 1<<20, now.Add(-2*time.Hour))
+	newPrefetchedModel(t, svc, "prefetch-new", "test/new:latest", 2<<20, now.Add(-time.Hour))
+	newPrefetchedModel(t, svc, "prefetch-warm", "test/warm:latest", 1<<20, now.Add(-3*time.Hour))
+	newPrefetchedModel(t, svc, "prefetch-pulling", "test/pulling:latest", 1<<20, now.Add(-3*time.Hour))
+	_, err := svc.sm.Set(filepath.Join(cfg.GetVolumeDir("prefetch-pulling"), "status.json"), status.Status{
+		VolumeName: "prefetch-pulling",
+		Reference:  "test/pulling:latest",
+		State:      status.StatePullRunning,
+	})
+	require.NoError(t, err)
+
+	// The least recently used model is evicted first, the warm model and the
+	// model being pulled are never evicted.
+	used, _, err := diskUsage(cfg)
+	require.NoError(t, err)
+	evicted, err := svc.worker.evictModels(ctx, used-512<<10)
+	require.NoError(t, err)
+	require.Equal(t, 1, evicted)
+	require.NoDirExists(t, cfg.GetVolumeDir("prefetch-old"))
+	require.DirExists(t, cfg.GetVolumeDir("prefetch-new"))
+	require.DirExists(t, cfg.GetVolumeDir("prefetch-warm"))
+	require.DirExists(t, cfg.GetVolumeDir("prefetch-pulling"))
+
+	// The largest model is evicted first by the policy.
+	newPrefetchedModel(t, svc, "prefetch-small", "test/small:latest", 1<<20, now.Add(-4*time.Hour))
+	cfg.Features.Eviction.Policy = config.EvictionPolicyLargest
+	used, _, err = diskUsage(cfg)
+	require.NoError(t, err)
+	evicted, err = svc.worker.evictModels(ctx, used-512<<10)
+	require.NoError(t, err)
+	require.Equal(t, 1, evicted)
+	require.NoDirExists(t, cfg.GetVolumeDir("prefetch-new"))
+	require.DirExists(t, cfg.GetVolumeDir("prefetch-small"))
+
+	// Nothing is evicted once the eviction is disabled.
+	cfg.Features.Eviction.Enabled = false
+	evicted, err = svc.worker.evictModels(ctx, 0)
+	require.NoError(t, err)
+	require.Zero(t, evicted)
+	require.DirExists(t, cfg.GetVolumeDir("prefetch-small"))
+}
+
+func TestEvictModelsByWatermark(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	cfg := svc.cfg.Get()
+	cfg.Features.Eviction = config.EvictionConfig{
+		Enabled:              true,
+		Policy:               config.EvictionPolicyLRU,
+		HighWatermarkPercent: 90,
+		LowWatermarkPercent:  50,
+	}
+	newPrefetchedModel(t, svc, "prefetch-model", "test/model:latest", 4<<20, time.Now())
+	used, err := getUsedSize(cfg.RootDir)
+	require.NoError(t, err)
+
+	// The usage below the high watermark is left as is.
+	cfg.Features.DiskUsageLimit = config.HumanizeSize(used * 2)
+	require.NoError(t, svc.evictModelsByWatermark(ctx))
+	require.DirExists(t, cfg.GetVolumeDir("prefetch-model"))
+
+	cfg.Features.DiskUsageLimit = config.HumanizeSize(used * 100 / 95)
+	require.NoError(t, svc.evictModelsByWatermark(ctx))
+	require.NoDirExists(t, cfg.GetVolumeDir("prefetch-model"))
+}
+
+func TestDiskQuotaChecker_Evict(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	cfg := svc.cfg.Get()
+	cfg.Features.Eviction = config.EvictionConfig{Enabled: true, Policy: config.EvictionPolicyLRU}
+	newPrefetchedModel(t, svc, "prefetch-model", "test/model:latest", 2<<20, time.Now())
+	used, err := getUsedSize(cfg.RootDir)
+	require.NoError(t, err)
+	cfg.Features.DiskUsageLimit = config.HumanizeSize(used + 1<<20)
+
+	// The prefetched model is evicted to make room for the pull.
+	checker := svc.worker.newDiskQuotaChecker("", false)
+	require.NoError(t, checker.CheckSize(ctx, "test/other:latest", 2<<20))
+	require.NoDirExists(t, cfg.GetVolumeDir("prefetch-model"))
+
+	err = checker.CheckSize(ctx, "test/other:latest", int64(cfg.Features.DiskUsageLimit)*2)
+	require.True(t, errors.Is(err, syscall.ENOSPC))
+}
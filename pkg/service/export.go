@@ -0,0 +1,469 @@
+package service
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/metrics"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The file at the head of the exported tarball describing the cached model,
+// followed by the model files under the "model/" dir.
+const cacheManifestFile = "model-cache.json"
+
+// The limit of the size of the manifest read from the imported tarball.
+const maxCacheManifestSize = 64 << 20
+
+// cacheManifest describes the cached model exported from a node, so that it's
+// imported into another node as the same prefetch, the volumes of the model
+// created there are cloned from it as if it's pulled on the node.
+type cacheManifest struct {
+	Reference           string      `json:"reference"`
+	Digest              string      `json:"digest,omitempty"`
+	Platform            string      `json:"platform,omitempty"`
+	PullKey             string      `json:"pull_key"`
+	ExcludeModelWeights bool        `json:"exclude_model_weights,omitempty"`
+	ExcludeFilePatterns []string    `json:"exclude_file_patterns,omitempty"`
+	ExcludedFiles       []string    `json:"excluded_files,omitempty"`
+	Files               []cacheFile `json:"files"`
+}
+
+// cacheFile is a regular file or a symlink of the exported model.
+type cacheFile struct {
+	// The path relative to the model dir.
+	Path   string `json:"path"`
+	Size   int64  `json:"size,omitempty"`
+	Mode   uint32 `json:"mode,omitempty"`
+	Digest string `json:"digest,omitempty"`
+	// The target of the symlink.
+	Link string `json:"link,omitempty"`
+}
+
+// ExportCache writes the prefetched model as a tarball, which is imported by
+// ImportCache to pre-seed the nodes without access to the registry. The files
+// of the model are checksummed in the manifest at the head of the tarball.
+func (s *Service) ExportCache(ctx context.Context, name string, writer io.Writer) error {
+	start := time.Now()
+	ctx = logger.NewContext(ctx, "ExportCache", name, "")
+	err := s.exportCache(ctx, name, writer)
+	metrics.NodeOpObserve("export_cache", start, err)
+	return err
+}
+
+func (s *Service) exportCache(ctx context.Context, name string, writer io.Writer) error {
+	if !isPrefetchVolume(name) || !checkIdentifier(name) {
+		return status.Errorf(codes.InvalidArgument, "invalid prefetch name: %s", name)
+	}
+
+	// The model isn't evicted or taken by a volume while it's exported.
+	contextKey := name + "/"
+	if err := s.worker.kmutex.Lock(ctx, contextKey); err != nil {
+		return status.Error(codes.Internal, errors.Wrapf(err, "lock context key: %s", contextKey).Error())
+	}
+	defer s.worker.kmutex.Unlock(contextKey)
+
+	volumeDir := s.cfg.Get().GetVolumeDir(name)
+	prefetchStatus, err := s.sm.Get(filepath.Join(volumeDir, "status.json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return status.Errorf(codes.NotFound, "prefetch not found: %s", name)
+		}
+		return status.Error(codes.Internal, errors.Wrap(err, "get prefetch status").Error())
+	}
+	if prefetchStatus.State != modelStatus.StatePullSucceeded || prefetchStatus.PullKey == "" {
+		return status.Errorf(codes.InvalidArgument, "prefetch %s is not pulled: %s", name, prefetchStatus.State)
+	}
+
+	// The files are checksummed before written, so that the manifest is
+	// read ahead of the files on import.
+	modelDir := filepath.Join(volumeDir, "model")
+	files, err := listCacheFiles(ctx, modelDir)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	manifest := cacheManifest{
+		Reference:           prefetchStatus.Reference,
+		Digest:              prefetchStatus.Digest,
+		Platform:            prefetchStatus.Platform,
+		PullKey:             prefetchStatus.PullKey,
+		ExcludeModelWeights: prefetchStatus.ExcludeModelWeights,
+		ExcludeFilePatterns: prefetchStatus.ExcludeFilePatterns,
+		ExcludedFiles:       prefetchStatus.ExcludedFiles,
+		Files:               files,
+	}
+	if err := writeCacheTarball(ctx, writer, modelDir, &manifest); err != nil {
+		return status.Error(codes.Internal, errors.Wrap(err, "write tarball").Error())
+	}
+	logger.WithContext(ctx).Infof("exported model %s, files: %d", prefetchStatus.Reference, len(files))
+
+	return nil
+}
+
+// listCacheFiles returns the regular files and the symlinks of the model dir
+// with the checksums of the regular files, sorted by the path.
+func listCacheFiles(ctx context.Context, modelDir string) ([]cacheFile, error) {
+	files := []cacheFile{}
+	if err := filepath.WalkDir(modelDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(modelDir, path)
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.Type().IsRegular():
+			info, err := entry.Info()
+			if err != nil {
+				return errors.Wrapf(err, "stat: %s", path)
+			}
+			checksum, err := sha256File(path)
+			if err != nil {
+				return errors.Wrapf(err, "checksum file: %s", rel)
+			}
+			files = append(files, cacheFile{
+				Path:   filepath.ToSlash(rel),
+				Size:   info.Size(),
+				Mode:   uint32(info.Mode().Perm()),
+				Digest: digest.NewDigestFromEncoded(digest.SHA256, checksum).String(),
+			})
+		case entry.Type()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return errors.Wrapf(err, "read link: %s", path)
+			}
+			files = append(files, cacheFile{Path: filepath.ToSlash(rel), Link: link})
+		}
+		return nil
+	}); err != nil {
+		return nil, errors.Wrapf(err, "walk model dir: %s", modelDir)
+	}
+
+	return files, nil
+}
+
+func writeCacheTarball(ctx context.Context, writer io.Writer, modelDir string, manifest *cacheManifest) error {
+	manifestBytes, err := json.Marshal(manifest)
+	if err != nil {
+		return errors.Wrap(err, "marshal manifest")
+	}
+
+	tarWriter := tar.NewWriter(writer)
+	if err := tarWriter.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     cacheManifestFile,
+		Size:     int64(len(manifestBytes)),
+		Mode:     0644,
+	}); err != nil {
+		return errors.Wrap(err, "write manifest header")
+	}
+	if _, err := tarWriter.Write(manifestBytes); err != nil {
+		return errors.Wrap(err, "write manifest")
+	}
+
+	for _, file := range manifest.Files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := path.Join("model", file.Path)
+		if file.Link != "" {
+			if err := tarWriter.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeSymlink,
+				Name:     name,
+				Linkname: file.Link,
+				Mode:     0777,
+			}); err != nil {
+				return errors.Wrapf(err, "write header: %s", name)
+			}
+			continue
+		}
+		if err := tarWriter.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Size:     file.Size,
+			Mode:     int64(file.Mode),
+		}); err != nil {
+			return errors.Wrapf(err, "write header: %s", name)
+		}
+		if err := copyCacheFile(tarWriter, filepath.Join(modelDir, filepath.FromSlash(file.Path))); err != nil {
+			return err
+		}
+	}
+
+	return tarWriter.Close()
+}
+
+func copyCacheFile(writer io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "open file: %s", path)
+	}
+	defer func() { _ = file.Close() }()
+
+	if _, err := io.Copy(writer, file); err != nil {
+		return errors.Wrapf(err, "copy file: %s", path)
+	}
+
+	return nil
+}
+
+// ImportCache imports the model exported by ExportCache from another node
+// as a prefetch, the files are verified against the checksums of the
+// manifest, and the whole tarball against the digest if it's not empty. The
+// status of the prefetch is returned, the existing prefetch of the same
+// model is returned as is.
+func (s *Service) ImportCache(ctx context.Context, reader io.Reader, dgst string) (*modelStatus.Status, error) {
+	start := time.Now()
+	ctx = logger.NewContext(ctx, "ImportCache", "", "")
+	prefetchStatus, err := s.importCache(ctx, reader, dgst)
+	metrics.NodeOpObserve("import_cache", start, err)
+	return s.worker.withPinned(ctx, prefetchStatus), err
+}
+
+func (s *Service) importCache(ctx context.Context, reader io.Reader, dgst string) (*modelStatus.Status, error) {
+	var digester digest.Digester
+	if dgst != "" {
+		expected, err := digest.Parse(dgst)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid digest: %v", err)
+		}
+		digester = expected.Algorithm().Digester()
+		reader = io.TeeReader(reader, digester.Hash())
+	}
+
+	tarReader := tar.NewReader(reader)
+	manifest, err := readCacheManifest(tarReader)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	// The pull key starts with the model type, see pullKey.
+	modelType, _, _ := strings.Cut(manifest.PullKey, "|")
+	if err := s.admitModel(ctx, modelType, manifest.Reference, "", ""); err != nil {
+		return nil, err
+	}
+
+	name := prefetchName(manifest.PullKey)
+	contextKey := name + "/"
+	if err := s.worker.kmutex.Lock(ctx, contextKey); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrapf(err, "lock context key: %s", contextKey).Error())
+	}
+	defer s.worker.kmutex.Unlock(contextKey)
+
+	volumeDir := s.cfg.Get().GetVolumeDir(name)
+	statusPath := filepath.Join(volumeDir, "status.json")
+	if prefetchStatus, err := s.sm.Get(statusPath); err == nil && prefetchStatus.State == modelStatus.StatePullSucceeded {
+		logger.WithContext(ctx).Infof("model %s is cached already: %s", manifest.Reference, name)
+		return prefetchStatus, nil
+	}
+
+	// The import is canceled by CancelPrefetch, and not started again by
+	// Prefetch in the meantime.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.worker.contextMap.Set(contextKey, &cancel)
+	defer s.worker.contextMap.Set(contextKey, nil)
+
+	prefetchStatus := modelStatus.Status{
+		VolumeName:          name,
+		Reference:           manifest.Reference,
+		Digest:              manifest.Digest,
+		Platform:            manifest.Platform,
+		State:               modelStatus.StatePullRunning,
+		PullKey:             manifest.PullKey,
+		ExcludeModelWeights: manifest.ExcludeModelWeights,
+		ExcludeFilePatterns: manifest.ExcludeFilePatterns,
+		ExcludedFiles:       manifest.ExcludedFiles,
+	}
+	if err := s.importCacheFiles(ctx, tarReader, reader, volumeDir, manifest, &prefetchStatus); err != nil {
+		if err := removeVolumeDir(volumeDir); err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("failed to cleanup volume dir: %s", volumeDir)
+		}
+		s.sm.Invalidate(volumeDir)
+		return nil, err
+	}
+	if digester != nil && digester.Digest().String() != dgst {
+		if err := removeVolumeDir(volumeDir); err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("failed to cleanup volume dir: %s", volumeDir)
+		}
+		s.sm.Invalidate(volumeDir)
+		return nil, status.Errorf(codes.InvalidArgument, "digest mismatch, expected: %s, actual: %s", dgst, digester.Digest())
+	}
+
+	prefetchStatus.State = modelStatus.StatePullSucceeded
+	prefetchStatus.SizeInBytes = modelDirSize(ctx, filepath.Join(volumeDir, "model"))
+	result, err := s.sm.Set(statusPath, prefetchStatus)
+	if err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "set prefetch status").Error())
+	}
+	logger.WithContext(ctx).Infof("imported model %s: %s", manifest.Reference, name)
+
+	return result, nil
+}
+
+// readCacheManifest reads the manifest at the head of the tarball.
+func readCacheManifest(tarReader *tar.Reader) (*cacheManifest, error) {
+	header, err := tarReader.Next()
+	if err != nil {
+		return nil, errors.Wrap(err, "read tar")
+	}
+	if header.Name != cacheManifestFile {
+		return nil, errors.Errorf("missing %s at the head of the tarball", cacheManifestFile)
+	}
+	if header.Size > maxCacheManifestSize {
+		return nil, errors.Errorf("%s is too large: %d", cacheManifestFile, header.Size)
+	}
+
+	var manifest cacheManifest
+	if err := json.NewDecoder(tarReader).Decode(&manifest); err != nil {
+		return nil, errors.Wrapf(err, "decode %s", cacheManifestFile)
+	}
+	if manifest.Reference == "" || manifest.PullKey == "" {
+		return nil, errors.Errorf("missing reference or pull key in %s", cacheManifestFile)
+	}
+	for _, file := range manifest.Files {
+		if !filepath.IsLocal(filepath.FromSlash(file.Path)) {
+			return nil, errors.Errorf("invalid file path in %s: %s", cacheManifestFile, file.Path)
+		}
+		if file.Link == "" {
+			if _, err := digest.Parse(file.Digest); err != nil {
+				return nil, errors.Wrapf(err, "invalid digest of file: %s", file.Path)
+			}
+		}
+	}
+
+	return &manifest, nil
+}
+
+// importCacheFiles extracts the model files of the tarball into the volume
+// dir, each file must be listed in the manifest with the same checksum.
+func (s *Service) importCacheFiles(ctx context.Context, tarReader *tar.Reader, reader io.Reader, volumeDir string, manifest *cacheManifest, prefetchStatus *modelStatus.Status) error {
+	files := map[string]cacheFile{}
+	modelSize := int64(0)
+	for _, file := range manifest.Files {
+		files[file.Path] = file
+		modelSize += file.Size
+	}
+
+	if err := removeVolumeDir(volumeDir); err != nil {
+		return status.Error(codes.Internal, errors.Wrapf(err, "cleanup volume dir: %s", volumeDir).Error())
+	}
+	s.sm.Invalidate(volumeDir)
+	modelType, _, _ := strings.Cut(manifest.PullKey, "|")
+	if tier := s.worker.selectStorageTier(ctx, manifest.Reference, modelSize, PullOptions{Type: modelType}); tier != nil {
+		if err := s.worker.placeVolumeDir(ctx, volumeDir, tier); err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("failed to place model on storage tier %s", tier.Name)
+		}
+	}
+	modelDir := filepath.Join(volumeDir, "model")
+	if err := os.MkdirAll(modelDir, 0755); err != nil {
+		return status.Error(codes.Internal, errors.Wrapf(err, "create model dir: %s", modelDir).Error())
+	}
+	if _, err := s.sm.Set(filepath.Join(volumeDir, "status.json"), *prefetchStatus); err != nil {
+		return status.Error(codes.Internal, errors.Wrap(err, "set prefetch status").Error())
+	}
+
+	if s.cfg.Get().Features.CheckDiskQuota {
+		diskQuotaChecker := s.worker.newDiskQuotaChecker(volumeDir, true)
+		defer diskQuotaChecker.Release()
+		if err := diskQuotaChecker.CheckSize(ctx, manifest.Reference, modelSize); err != nil {
+			if errors.Is(err, syscall.ENOSPC) {
+				return status.Error(codes.ResourceExhausted, errors.Wrap(err, "check disk quota").Error())
+			}
+			return status.Error(codes.Internal, errors.Wrap(err, "check disk quota").Error())
+		}
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return status.Error(codes.Canceled, err.Error())
+		}
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return status.Error(codes.InvalidArgument, errors.Wrap(err, "read tar").Error())
+		}
+		if err := importCacheEntry(tarReader, header, modelDir, files); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	for rel := range files {
+		return status.Errorf(codes.InvalidArgument, "missing file in tarball: %s", rel)
+	}
+	// Read the padding at the end of the tarball for the digest.
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return status.Error(codes.InvalidArgument, errors.Wrap(err, "read tarball").Error())
+	}
+
+	return nil
+}
+
+// importCacheEntry extracts the entry of the tarball, the entry is removed
+// from the files once it's verified.
+func importCacheEntry(tarReader *tar.Reader, header *tar.Header, modelDir string, files map[string]cacheFile) error {
+	if header.Typeflag == tar.TypeDir {
+		return nil
+	}
+	rel, ok := strings.CutPrefix(path.Clean(header.Name), "model/")
+	if !ok {
+		return errors.Errorf("unexpected entry in tarball: %s", header.Name)
+	}
+	file, ok := files[rel]
+	if !ok {
+		return errors.Errorf("file not in %s: %s", cacheManifestFile, rel)
+	}
+	delete(files, rel)
+	target := filepath.Join(modelDir, filepath.FromSlash(rel))
+
+	switch header.Typeflag {
+	case tar.TypeSymlink:
+		if header.Linkname != file.Link || filepath.IsAbs(file.Link) || !filepath.IsLocal(filepath.Join(filepath.Dir(filepath.FromSlash(rel)), file.Link)) {
+			return errors.Errorf("invalid symlink in tarball: %s -> %s", rel, header.Linkname)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return errors.Wrapf(err, "create dir: %s", filepath.Dir(target))
+		}
+		if err := os.Symlink(file.Link, target); err != nil {
+			return errors.Wrapf(err, "create symlink: %s", target)
+		}
+	case tar.TypeReg:
+		if file.Link != "" || header.Size != file.Size {
+			return errors.Errorf("file %s mismatches %s", rel, cacheManifestFile)
+		}
+		hash := sha256.New()
+		if err := writeFile(io.TeeReader(tarReader, hash), target); err != nil {
+			return err
+		}
+		if actual := digest.NewDigestFromEncoded(digest.SHA256, hex.EncodeToString(hash.Sum(nil))); actual.String() != file.Digest {
+			return errors.Errorf("checksum mismatch of file %s, expected: %s, actual: %s", rel, file.Digest, actual)
+		}
+		if err := os.Chmod(target, os.FileMode(file.Mode).Perm()); err != nil {
+			return errors.Wrapf(err, "chmod file: %s", target)
+		}
+	default:
+		return errors.Errorf("unsupported entry in tarball: %s", header.Name)
+	}
+
+	return nil
+}
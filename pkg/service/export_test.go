@@ -0,0 +1,109 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+func newExportedPrefetch(t *testing.T, svc *Service, reference string) (string, string) {
+	t.Helper()
+	key := pullKey(reference, PullOptions{Type: ModelTypeImage})
+	name := prefetchName(key)
+	modelDir := svc.cfg.Get().GetModelDir(name)
+	require.NoError(t, os.MkdirAll(filepath.Join(modelDir, "weights"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(modelDir, "config.json"), []byte(`{"model": "test"}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(modelDir, "weights", "model.safetensors"), []byte("model-weights"), 0600))
+	require.NoError(t, os.Symlink("weights/model.safetensors", filepath.Join(modelDir, "model.safetensors")))
+	_, err := svc.sm.Set(filepath.Join(filepath.Dir(modelDir), "status.json"), status.Status{
+		VolumeName:    name,
+		Reference:     reference,
+		State:         status.StatePullSucceeded,
+		PullKey:       key,
+		ExcludedFiles: []string{"README.md"},
+	})
+	require.NoError(t, err)
+	return name, key
+}
+
+func TestExportImportCache(t *testing.T) {
+	source, _ := newNodeService(t)
+	ctx := context.Background()
+	name, key := newExportedPrefetch(t, source, "test/model:latest")
+
+	var tarball bytes.Buffer
+	require.NoError(t, source.ExportCache(ctx, name, &tarball))
+	dgst := digest.FromBytes(tarball.Bytes()).String()
+
+	target, _ := newNodeService(t)
+	importedStatus, err := target.ImportCache(ctx, bytes.NewReader(tarball.Bytes()), dgst)
+	require.NoError(t, err)
+	require.Equal(t, name, importedStatus.VolumeName)
+	require.Equal(t, "test/model:latest", importedStatus.Reference)
+	require.Equal(t, status.StatePullSucceeded, importedStatus.State)
+	require.Equal(t, key, importedStatus.PullKey)
+	require.Equal(t, []string{"README.md"}, importedStatus.ExcludedFiles)
+	require.NotZero(t, importedStatus.SizeInBytes)
+
+	modelDir := target.cfg.Get().GetModelDir(name)
+	data, err := os.ReadFile(filepath.Join(modelDir, "weights", "model.safetensors"))
+	require.NoError(t, err)
+	require.Equal(t, "model-weights", string(data))
+	info, err := os.Stat(filepath.Join(modelDir, "weights", "model.safetensors"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	link, err := os.Readlink(filepath.Join(modelDir, "model.safetensors"))
+	require.NoError(t, err)
+	require.Equal(t, "weights/model.safetensors", link)
+
+	// The volumes of the model are cloned from the imported model.
+	require.Equal(t, modelDir, target.worker.findPulledModel(ctx, key, target.cfg.Get().GetModelDir("pvc-imported")))
+
+	// The model cached already is returned as is.
+	importedStatus, err = target.ImportCache(ctx, bytes.NewReader(tarball.Bytes()), "")
+	require.NoError(t, err)
+	require.Equal(t, name, importedStatus.VolumeName)
+}
+
+func TestImportCache_Invalid(t *testing.T) {
+	source, _ := newNodeService(t)
+	ctx := context.Background()
+	name, _ := newExportedPrefetch(t, source, "test/model:latest")
+
+	var tarball bytes.Buffer
+	require.NoError(t, source.ExportCache(ctx, name, &tarball))
+
+	requireInvalid := func(data []byte, dgst string, msg string) {
+		target, _ := newNodeService(t)
+		_, err := target.ImportCache(ctx, bytes.NewReader(data), dgst)
+		require.Error(t, err)
+		require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+		require.Contains(t, err.Error(), msg)
+		require.NoDirExists(t, target.cfg.Get().GetVolumeDir(name))
+	}
+
+	requireInvalid(tarball.Bytes(), "sha256:invalid", "invalid digest")
+	requireInvalid(tarball.Bytes(), digest.FromString("other").String(), "digest mismatch")
+	requireInvalid(bytes.Replace(tarball.Bytes(), []byte("model-weights"), []byte("MODEL-weights"), 1), "", "checksum mismatch")
+	requireInvalid(tarball.Bytes()[:tarball.Len()/2], "", "")
+	requireInvalid([]byte("not a tarball"), "", "")
+}
+
+func TestExportCache_NotFound(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+
+	err := svc.ExportCache(ctx, "prefetch-0123456789abcdef", &bytes.Buffer{})
+	require.Equal(t, codes.NotFound, grpcStatus.Code(err))
+
+	err = svc.ExportCache(ctx, "pvc-volume", &bytes.Buffer{})
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+}
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/modelpack/modctl/pkg/backend"
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	modelspec "github.com/modelpack/model-spec/specs-go/v1"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+// filePuller pulls the model files served over HTTP, e.g. by HuggingFace Hub
+// or S3, the model files are described as the layers of the model image, so
+// that the filters, the pull state and the progress hook are reused.
+type filePuller struct {
+	// The model type of the files, e.g. ModelTypeHuggingFace.
+	modelType        string
+	pullCfg          *config.PullConfig
+	hook             *status.Hook
+	diskQuotaChecker *DiskQuotaChecker
+	client           *http.Client
+	// newRequest creates the request to download the model file.
+	newRequest func(ctx context.Context, layer backend.InspectedModelArtifactLayer) (*http.Request, error)
+}
+
+func newHTTPClient(pullCfg *config.PullConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if pullCfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(pullCfg.ProxyURL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse proxy url: %s", pullCfg.ProxyURL)
+		}
+		// The hosts by no_proxy are requested directly, e.g. the in-cluster
+		// storage the registry redirects the blobs to.
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if pullCfg.IsNoProxy(req.URL.Host) {
+				return nil, nil
+			}
+			return proxyURL, nil
+		}
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// logPullProxy logs the proxy the model is pulled from the host through, e.g.
+// to check the host excluded by no_proxy, the credentials of the proxy URL
+// are redacted.
+func logPullProxy(ctx context.Context, pullCfg *config.PullConfig, reference, host string) {
+	proxy := "none"
+	if pullCfg.ProxyURL != "" && !pullCfg.IsNoProxy(host) {
+		proxy = pullCfg.ProxyURL
+		if proxyURL, err := url.Parse(pullCfg.ProxyURL); err == nil {
+			proxy = proxyURL.Redacted()
+		}
+	}
+	logger.WithContext(ctx).Debugf("pulling %s from %s through proxy: %s", reference, host, proxy)
+}
+
+// httpStatusError is the unexpected status code of the response.
+type httpStatusError struct {
+	StatusCode int
+	URL        string
+	Body       string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d from %s: %s", e.StatusCode, e.URL, e.Body)
+}
+
+// doRequest sends the request and returns the response only for 200 OK, or
+// 206 Partial Content of the range request.
+func doRequest(client *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "request: %s", req.URL.Redacted())
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		_ = resp.Body.Close()
+		return nil, errors.WithStack(&httpStatusError{
+			StatusCode: resp.StatusCode,
+			URL:        req.URL.Redacted(),
+			Body:       strings.TrimSpace(string(body)),
+		})
+	}
+	return resp, nil
+}
+
+// getStagingDir returns the hidden dir beside the model dir holding the
+// partially downloaded files, so that they're never exposed in the mounted
+// model dir.
+//
+// /var/lib/dragonfly/model-csi/volumes/$volumeName/.staging
+func getStagingDir(modelDir string) string {
+	return filepath.Join(filepath.Dir(modelDir), ".staging")
+}
+
+// getStagingPath returns the path of the partially downloaded file, it's
+// named by the file path and digest, so that it's never resumed for another
+// version of the file.
+func getStagingPath(modelDir string, layer backend.InspectedModelArtifactLayer) string {
+	sum := sha256.Sum256([]byte(layer.Filepath + "@" + layer.Digest))
+	return filepath.Join(getStagingDir(modelDir), hex.EncodeToString(sum[:]))
+}
+
+func fileDescriptor(layer backend.InspectedModelArtifactLayer) ocispec.Descriptor {
+	return ocispec.Descriptor{
+		Digest:      digest.Digest(layer.Digest),
+		Size:        layer.Size,
+		Annotations: map[string]string{modelspec.AnnotationFilepath: layer.Filepath},
+	}
+}
+
+func (p *filePuller) pull(ctx context.Context, reference, targetDir string, files []backend.InspectedModelArtifactLayer, excludeModelWeights bool, excludeFilePatterns []string) error {
+	layers := []backend.InspectedModelArtifactLayer{}
+	modelSize := int64(0)
+	include := includeLayerFunc(ctx, p.hook, excludeModelWeights, excludeFilePatterns)
+	for _, layer := range files {
+		if !filepath.IsLocal(layer.Filepath) {
+			return errors.Errorf("invalid model file path: %s", layer.Filepath)
+		}
+		if include(layer) {
+			layers = append(layers, layer)
+			modelSize += layer.Size
+		}
+	}
+
+	if p.diskQuotaChecker != nil {
+		if err := p.diskQuotaChecker.CheckSize(ctx, reference, modelSize); err != nil {
+			return errors.Wrap(err, "check disk quota")
+		}
+	}
+
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return errors.Wrapf(err, "create model dir: %s", targetDir)
+	}
+
+	statePath := getPullStatePath(targetDir)
+	state := loadPullState(statePath, pullStateKey(reference, PullOptions{
+		Type:                p.modelType,
+		ExcludeModelWeights: excludeModelWeights,
+		ExcludeFilePatterns: excludeFilePatterns,
+	}))
+	hook := newResumeHook(ctx, p.hook, statePath, state)
+	if err := hook.save(); err != nil {
+		return err
+	}
+	layerHook := newFaultHook(ctx, hook)
+
+	manifest := ocispec.Manifest{}
+	pending := []backend.InspectedModelArtifactLayer{}
+	for _, layer := range layers {
+		manifest.Layers = append(manifest.Layers, fileDescriptor(layer))
+		if state.isPulled(targetDir, layer) {
+			p.hook.MarkPulled(fileDescriptor(layer))
+		} else {
+			pending = append(pending, layer)
+		}
+	}
+
+	logger.WithContext(ctx).Infof("pulling model files: %s, files: %d/%d", reference, len(pending), len(files))
+	p.hook.SetTotal(len(layers))
+	p.hook.SetTotalSize(modelSize)
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	if p.pullCfg.Concurrency > 0 {
+		eg.SetLimit(int(p.pullCfg.Concurrency))
+	}
+	for _, layer := range pending {
+		eg.Go(func() error {
+			desc := fileDescriptor(layer)
+			layerHook.BeforePullLayer(desc, manifest)
+			err := withLayerRetry(egCtx, &p.pullCfg.Retry, layer.Filepath, func() error {
+				return p.download(egCtx, layer, targetDir)
+			})
+			layerHook.AfterPullLayer(desc, err)
+			return err
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		logger.WithContext(ctx).WithError(err).Errorf("failed to pull model files: %s", reference)
+		return errors.Wrap(err, "pull model files")
+	}
+	if err := layerHook.Err(); err != nil {
+		return errors.Wrap(err, "pull model files")
+	}
+
+	stagingDir := getStagingDir(targetDir)
+	if err := os.RemoveAll(stagingDir); err != nil {
+		return errors.Wrapf(err, "remove staging dir: %s", stagingDir)
+	}
+
+	return hook.remove()
+}
+
+// download downloads the model file, the partially downloaded file is kept
+// on failure and resumed by range on retry if the server supports it.
+func (p *filePuller) download(ctx context.Context, layer backend.InspectedModelArtifactLayer, targetDir string) error {
+	filePath := filepath.Join(targetDir, layer.Filepath)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return errors.Wrapf(err, "create dir: %s", filepath.Dir(filePath))
+	}
+
+	tmpPath := getStagingPath(targetDir, layer)
+	if err := os.MkdirAll(filepath.Dir(tmpPath), 0755); err != nil {
+		return errors.Wrapf(err, "create dir: %s", filepath.Dir(tmpPath))
+	}
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "open file: %s", tmpPath)
+	}
+	defer func() { _ = file.Close() }()
+
+	hash := sha256.New()
+	offset, err := io.Copy(hash, file)
+	if err != nil {
+		return errors.Wrapf(err, "read partial file: %s", tmpPath)
+	}
+	if offset >= layer.Size {
+		offset = 0
+	}
+
+	req, err := p.newRequest(ctx, layer)
+	if err != nil {
+		return errors.Wrapf(err, "create request for file: %s", layer.Filepath)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := doRequest(p.client, req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if offset > 0 {
+		if resp.StatusCode == http.StatusPartialContent {
+			if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+				_ = os.Remove(tmpPath)
+				return errors.Errorf("unexpected content range for file %s: %s", layer.Filepath, resp.Header.Get("Content-Range"))
+			}
+			logger.WithContext(ctx).Infof("resuming download of file: %s, from: %d/%d", layer.Filepath, offset, layer.Size)
+		} else {
+			// The server doesn't support the range request.
+			offset = 0
+		}
+	}
+	if offset == 0 {
+		hash.Reset()
+		if err := file.Truncate(0); err != nil {
+			return errors.Wrapf(err, "truncate file: %s", tmpPath)
+		}
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return errors.Wrapf(err, "seek file: %s", tmpPath)
+	}
+
+	p.hook.SetDownloadedBytes(digest.Digest(layer.Digest), offset)
+	_, err = io.Copy(io.MultiWriter(file, hash, p.hook.ProgressWriter(digest.Digest(layer.Digest))), resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "download file: %s", layer.Filepath)
+	}
+
+	// Verify the content only if the source provides the sha256 checksum.
+	if dgst := digest.Digest(layer.Digest); dgst.Algorithm() == digest.SHA256 {
+		if actual := hex.EncodeToString(hash.Sum(nil)); actual != dgst.Encoded() {
+			_ = os.Remove(tmpPath)
+			return errors.Errorf("checksum mismatch for file %s: expected %s, got %s", layer.Filepath, dgst.Encoded(), actual)
+		}
+	}
+
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return errors.Wrapf(err, "rename file: %s", filePath)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/metrics"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type GCRequest struct {
+	// Evict the cached models in the order of the eviction policy until the
+	// free bytes of the disk quota reach the target, 0 to skip.
+	TargetFreeBytes int64 `json:"target_free_bytes"`
+	// Evict the cached models not pulled or cloned for the seconds, 0 to skip.
+	MaxAgeInSeconds int64 `json:"max_age_in_seconds"`
+	// Return what would be deleted without deleting it.
+	DryRun bool `json:"dry_run"`
+}
+
+// GCModel is the cached model evicted, or to be evicted, by the GC.
+type GCModel struct {
+	Name      string    `json:"name"`
+	Reference string    `json:"reference"`
+	Size      int64     `json:"size"`
+	LastUsed  time.Time `json:"last_used"`
+}
+
+type GCResponse struct {
+	DryRun bool      `json:"dry_run"`
+	Models []GCModel `json:"models"`
+	// The blobs of the shared blob store not linked by any volume, the blobs
+	// released by the models to be evicted aren't included on the dry run.
+	Blobs []GCBlob `json:"blobs"`
+	// The bytes released by the models and the blobs.
+	ReclaimedBytes int64 `json:"reclaimed_bytes"`
+}
+
+// GC runs the cleanup of the cached models and the unused blobs at once,
+// regardless of features.eviction. Only the prefetched models are evicted,
+// the same as the eviction by the watermarks.
+func (s *Service) GC(ctx context.Context, req GCRequest) (*GCResponse, error) {
+	ctx = logger.NewContext(ctx, "GC", "", "")
+	if req.TargetFreeBytes < 0 || req.MaxAgeInSeconds < 0 {
+		return nil, status.Error(codes.InvalidArgument, "target_free_bytes and max_age_in_seconds must not be negative")
+	}
+
+	candidates, err := s.worker.evictionCandidates(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "list cached models").Error())
+	}
+	used, total, err := diskUsage(s.cfg.Get())
+	if err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "get disk usage").Error())
+	}
+
+	resp := GCResponse{DryRun: req.DryRun, Models: []GCModel{}}
+	maxAge := time.Duration(req.MaxAgeInSeconds) * time.Second
+	for _, candidate := range candidates {
+		expired := maxAge > 0 && time.Since(candidate.lastUsed) > maxAge
+		if !expired && total-used >= req.TargetFreeBytes {
+			continue
+		}
+		if !req.DryRun {
+			if err := s.worker.DeleteModel(ctx, true, candidate.name, ""); err != nil {
+				return nil, status.Error(codes.Internal, errors.Wrapf(err, "evict model %s", candidate.name).Error())
+			}
+			metrics.NodeEvictedModels.Inc()
+			logger.WithContext(ctx).Infof("evicted model %s (%s), size: %s", candidate.reference, candidate.name, humanizeBytes(candidate.size))
+		}
+		used -= candidate.size
+		resp.ReclaimedBytes += candidate.size
+		resp.Models = append(resp.Models, GCModel{
+			Name:      candidate.name,
+			Reference: candidate.reference,
+			Size:      candidate.size,
+			LastUsed:  candidate.lastUsed,
+		})
+	}
+
+	// The blobs released by the evicted models are collected by the deletion,
+	// collect the blobs left behind.
+	blobs, err := s.worker.blobStore.gc(ctx, req.DryRun)
+	if err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "collect unused blobs").Error())
+	}
+	resp.Blobs = blobs
+	for _, blob := range blobs {
+		resp.ReclaimedBytes += blob.Size
+	}
+
+	return &resp, nil
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGC(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	cfg := svc.cfg.Get()
+	cfg.Features.DiskUsageLimit = 100 << 20
+	cfg.Features.Eviction.Policy = config.EvictionPolicyLRU
+	cfg.WarmModels.Models = []config.WarmModel{{Reference: "test/warm:latest"}}
+
+	now := time.Now()
+	newPrefetchedModel(t, svc, "prefetch-old", "test/old:latest", 1<<20, now.Add(-48*time.Hour))
+	newPrefetchedModel(t, svc, "prefetch-new", "test/new:latest", 2<<20, now.Add(-time.Hour))
+	newPrefetchedModel(t, svc, "prefetch-warm", "test/warm:latest", 1<<20, now.Add(-72*time.Hour))
+
+	// An unused blob left behind.
+	blobPath, err := svc.worker.blobStore.blobPath(testBlobDigest)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(blobPath), 0755))
+	require.NoError(t, os.WriteFile(blobPath, []byte("foo"), 0644))
+
+	// Nothing is deleted on the dry run.
+	resp, err := svc.GC(ctx, GCRequest{MaxAgeInSeconds: 24 * 3600, DryRun: true})
+	require.NoError(t, err)
+	require.True(t, resp.DryRun)
+	require.Len(t, resp.Models, 1)
+	require.Equal(t, "prefetch-old", resp.Models[0].Name)
+	require.Equal(t, []GCBlob{{Digest: testBlobDigest, Size: 3}}, resp.Blobs)
+	require.DirExists(t, cfg.GetVolumeDir("prefetch-old"))
+	require.FileExists(t, blobPath)
+
+	resp, err = svc.GC(ctx, GCRequest{MaxAgeInSeconds: 24 * 3600})
+	require.NoError(t, err)
+	require.Len(t, resp.Models, 1)
+	require.Positive(t, resp.ReclaimedBytes)
+	require.NoDirExists(t, cfg.GetVolumeDir("prefetch-old"))
+	require.DirExists(t, cfg.GetVolumeDir("prefetch-new"))
+	require.NoFileExists(t, blobPath)
+
+	// The models are evicted until the free disk quota reaches the target,
+	// the warm models are kept.
+	resp, err = svc.GC(ctx, GCRequest{TargetFreeBytes: 200 << 20})
+	require.NoError(t, err)
+	require.Len(t, resp.Models, 1)
+	require.Equal(t, "prefetch-new", resp.Models[0].Name)
+	require.DirExists(t, cfg.GetVolumeDir("prefetch-warm"))
+
+	_, err = svc.GC(ctx, GCRequest{MaxAgeInSeconds: -1})
+	require.Error(t, err)
+}
+
+func TestDynamicServerHandler_GC(t *testing.T) {
+	h, _ := newHandler(t)
+
+	c, rec := newHandlerContextWithParam(t, http.MethodPost, "/api/v1/gc", `{"dry_run":true}`, nil, nil)
+	require.NoError(t, h.GC(c))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp GCResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.True(t, resp.DryRun)
+	require.Empty(t, resp.Models)
+
+	c, rec = newHandlerContextWithParam(t, http.MethodPost, "/api/v1/gc", `{"target_free_bytes":-1}`, nil, nil)
+	_ = h.GC(c)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
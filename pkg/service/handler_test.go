@@ -9,6 +9,8 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc/codes"
 	grpcStatus "google.golang.org/grpc/status"
@@ -63,6 +65,34 @@ func TestHandleError_ResourceExhausted(t *testing.T) {
 	require.Equal(t, ERR_CODE_INSUFFICIENT_DISK_QUOTA, resp.Code)
 }
 
+func TestHandleError_AlreadyExists(t *testing.T) {
+	c, rec := newEchoContext(t, "")
+	err := grpcStatus.Error(codes.AlreadyExists, "mount_id is re-used")
+	_ = handleError(c, err)
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, ERR_CODE_ALREADY_EXISTS, resp.Code)
+}
+
+func TestHandleError_ErrorInfo(t *testing.T) {
+	svc := newTestService(t)
+	c, rec := newEchoContext(t, "")
+	err := svc.pullErrorStatus(codes.Internal, "pull model", &PullError{
+		Status: &status.Status{VolumeName: "csi-dyn", MountID: "mount-1", State: status.StatePullFailed},
+		err:    errors.New("unauthorized"),
+	})
+	_ = handleError(c, err)
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, ERR_CODE_INTERNAL, resp.Code)
+	require.Equal(t, status.StatePullFailed, resp.Reason)
+	require.Equal(t, "mount-1", resp.Metadata["mount_id"])
+}
+
 func TestHandleError_Internal(t *testing.T) {
 	c, rec := newEchoContext(t, "")
 	err := grpcStatus.Error(codes.Internal, "boom")
@@ -123,6 +153,30 @@ func TestDynamicServerHandler_CreateVolume_MissingReference(t *testing.T) {
 	require.Equal(t, http.StatusBadRequest, rec.Code)
 }
 
+func TestDynamicServerHandler_CreateVolume_InvalidExcludeFilePatterns(t *testing.T) {
+	h, _ := newHandler(t)
+	c, rec := newHandlerContextWithParam(t, http.MethodPost, "/", `{"mount_id":"m1","reference":"test/model:latest","exclude_file_patterns":["[a-z.md"]}`,
+		[]string{"volume_name"}, []string{"my-volume"})
+	_ = h.CreateVolume(c)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Contains(t, resp.Message, "invalid file pattern")
+}
+
+func TestDynamicServerHandler_CreateVolume_InvalidAdapters(t *testing.T) {
+	h, _ := newHandler(t)
+	c, rec := newHandlerContextWithParam(t, http.MethodPost, "/", `{"mount_id":"m1","reference":"test/model:latest","adapters":[{"reference":"a/sql:v1"},{"reference":"b/sql:v1"}]}`,
+		[]string{"volume_name"}, []string{"my-volume"})
+	_ = h.CreateVolume(c)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Contains(t, resp.Message, "duplicate adapter name")
+}
+
 func TestDynamicServerHandler_CreateVolume_CreatesVolume(t *testing.T) {
 	h, _ := newHandler(t)
 	body := `{"mount_id":"m1","reference":"test/model:latest","check_disk_quota":false}`
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"slices"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// The services checked by the health service, the empty one is the server as
+// a whole.
+var healthServices = []string{"", "csi.v1.Identity", "csi.v1.Controller", "csi.v1.Node"}
+
+// HealthServer serves the gRPC health checking on the gRPC servers of the
+// driver by the CSI Probe, so that the standard health probes and the load
+// balancers check the driver without crafting the CSI requests. The status of
+// all the services is of the Probe, Watch isn't supported.
+type HealthServer struct {
+	healthpb.UnimplementedHealthServer
+	identity csi.IdentityServer
+}
+
+func NewHealthServer(identity csi.IdentityServer) *HealthServer {
+	return &HealthServer{identity: identity}
+}
+
+func (h *HealthServer) probe(ctx context.Context) healthpb.HealthCheckResponse_ServingStatus {
+	resp, err := h.identity.Probe(ctx, &csi.ProbeRequest{})
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("health check failed")
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	if resp.GetReady() != nil && !resp.GetReady().GetValue() {
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	return healthpb.HealthCheckResponse_SERVING
+}
+
+func (h *HealthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if !slices.Contains(healthServices, req.GetService()) {
+		return nil, status.Errorf(codes.NotFound, "unknown service: %s", req.GetService())
+	}
+
+	return &healthpb.HealthCheckResponse{Status: h.probe(ctx)}, nil
+}
+
+func (h *HealthServer) List(ctx context.Context, req *healthpb.HealthListRequest) (*healthpb.HealthListResponse, error) {
+	servingStatus := h.probe(ctx)
+	statuses := map[string]*healthpb.HealthCheckResponse{}
+	for _, service := range healthServices {
+		statuses[service] = &healthpb.HealthCheckResponse{Status: servingStatus}
+	}
+
+	return &healthpb.HealthListResponse{Statuses: statuses}, nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	grpcStatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type probeIdentity struct {
+	csi.UnimplementedIdentityServer
+	resp *csi.ProbeResponse
+	err  error
+}
+
+func (identity *probeIdentity) Probe(ctx context.Context, req *csi.ProbeRequest) (*csi.ProbeResponse, error) {
+	return identity.resp, identity.err
+}
+
+func TestHealthServer(t *testing.T) {
+	ctx := context.Background()
+	identity := &probeIdentity{resp: &csi.ProbeResponse{}}
+	health := NewHealthServer(identity)
+	requireStatus := func(expected healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		for _, service := range []string{"", "csi.v1.Node"} {
+			resp, err := health.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+			require.NoError(t, err)
+			require.Equal(t, expected, resp.Status)
+		}
+		resp, err := health.List(ctx, &healthpb.HealthListRequest{})
+		require.NoError(t, err)
+		require.Len(t, resp.Statuses, 4)
+		require.Equal(t, expected, resp.Statuses["csi.v1.Controller"].Status)
+	}
+
+	requireStatus(healthpb.HealthCheckResponse_SERVING)
+	identity.resp = &csi.ProbeResponse{Ready: wrapperspb.Bool(true)}
+	requireStatus(healthpb.HealthCheckResponse_SERVING)
+	identity.resp = &csi.ProbeResponse{Ready: wrapperspb.Bool(false)}
+	requireStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	identity.resp, identity.err = nil, grpcStatus.Error(codes.FailedPrecondition, "disk full")
+	requireStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+
+	_, err := health.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+	require.Equal(t, codes.NotFound, grpcStatus.Code(err))
+}
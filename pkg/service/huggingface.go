@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/modelpack/modctl/pkg/backend"
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+const huggingFaceDefaultRevision = "main"
+
+var NewHuggingFacePuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+	return &huggingFacePuller{
+		pullCfg:          pullCfg,
+		hook:             hook,
+		diskQuotaChecker: diskQuotaChecker,
+	}
+}
+
+// huggingFacePuller pulls the model files of a HuggingFace Hub repo, the
+// reference is the repo id with an optional revision, e.g.
+// "Qwen/Qwen3-0.6B@main".
+type huggingFacePuller struct {
+	pullCfg          *config.PullConfig
+	hook             *status.Hook
+	diskQuotaChecker *DiskQuotaChecker
+}
+
+type huggingFaceSibling struct {
+	Filename string `json:"rfilename"`
+	Size     int64  `json:"size"`
+	BlobID   string `json:"blobId"`
+	LFS      *struct {
+		SHA256 string `json:"sha256"`
+		Size   int64  `json:"size"`
+	} `json:"lfs"`
+}
+
+type huggingFaceModelInfo struct {
+	SHA      string               `json:"sha"`
+	Siblings []huggingFaceSibling `json:"siblings"`
+}
+
+// parseHuggingFaceReference parses "org/repo[@revision]" into the repo id and revision.
+func parseHuggingFaceReference(reference string) (string, string, error) {
+	repo, revision, found := strings.Cut(reference, "@")
+	if !found || revision == "" {
+		revision = huggingFaceDefaultRevision
+	}
+	parts := strings.Split(repo, "/")
+	if len(parts) > 2 {
+		return "", "", errors.Errorf("invalid huggingface repo id: %s", repo)
+	}
+	for _, part := range parts {
+		if part == "" || part == "." || part == ".." {
+			return "", "", errors.Errorf("invalid huggingface repo id: %s", repo)
+		}
+	}
+	return repo, revision, nil
+}
+
+func (p *huggingFacePuller) newRequest(ctx context.Context, token, rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "create request: %s", rawURL)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+func (p *huggingFacePuller) getModelInfo(ctx context.Context, client *http.Client, token, repo, revision string) (*huggingFaceModelInfo, error) {
+	infoURL := fmt.Sprintf(
+		"%s/api/models/%s/revision/%s?blobs=true",
+		strings.TrimSuffix(p.pullCfg.HuggingFace.Endpoint, "/"), repo, url.PathEscape(revision),
+	)
+	req, err := p.newRequest(ctx, token, infoURL)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := doRequest(client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var info huggingFaceModelInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, errors.Wrapf(err, "decode model info: %s", infoURL)
+	}
+
+	return &info, nil
+}
+
+// toLayer converts the repo file to the model layer, only the LFS files
+// carry the sha256 checksum, the others are identified by the git blob id.
+func (file *huggingFaceSibling) toLayer() backend.InspectedModelArtifactLayer {
+	layer := backend.InspectedModelArtifactLayer{
+		Filepath: file.Filename,
+		Size:     file.Size,
+	}
+	if file.LFS != nil {
+		layer.Digest = digest.NewDigestFromEncoded(digest.SHA256, file.LFS.SHA256).String()
+		layer.Size = file.LFS.Size
+	} else {
+		layer.Digest = digest.NewDigestFromEncoded("sha1", file.BlobID).String()
+	}
+	return layer
+}
+
+func (p *huggingFacePuller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	repo, revision, err := parseHuggingFaceReference(reference)
+	if err != nil {
+		return err
+	}
+
+	token, err := p.pullCfg.HuggingFace.GetToken()
+	if err != nil {
+		return err
+	}
+
+	client, err := newHTTPClient(p.pullCfg)
+	if err != nil {
+		return err
+	}
+	if endpoint, err := url.Parse(p.pullCfg.HuggingFace.Endpoint); err == nil {
+		logPullProxy(ctx, p.pullCfg, reference, endpoint.Host)
+	}
+
+	info, err := p.getModelInfo(ctx, client, token, repo, revision)
+	if err != nil {
+		return errors.Wrapf(err, "get huggingface model info: %s", reference)
+	}
+	// Pin the files to the commit resolved from the revision.
+	if info.SHA != "" {
+		revision = info.SHA
+	}
+	logger.WithContext(ctx).Infof("resolved huggingface model: %s, revision: %s", reference, revision)
+
+	files := []backend.InspectedModelArtifactLayer{}
+	for idx := range info.Siblings {
+		files = append(files, info.Siblings[idx].toLayer())
+	}
+
+	fp := &filePuller{
+		modelType:        ModelTypeHuggingFace,
+		pullCfg:          p.pullCfg,
+		hook:             p.hook,
+		diskQuotaChecker: p.diskQuotaChecker,
+		client:           client,
+		newRequest: func(ctx context.Context, layer backend.InspectedModelArtifactLayer) (*http.Request, error) {
+			segments := strings.Split(layer.Filepath, "/")
+			for idx := range segments {
+				segments[idx] = url.PathEscape(segments[idx])
+			}
+			fileURL := fmt.Sprintf(
+				"%s/%s/resolve/%s/%s",
+				strings.TrimSuffix(p.pullCfg.HuggingFace.Endpoint, "/"), repo, url.PathEscape(revision), strings.Join(segments, "/"),
+			)
+			return p.newRequest(ctx, token, fileURL)
+		},
+	}
+
+	return fp.pull(ctx, reference, targetDir, files, excludeModelWeights, excludeFilePatterns)
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHuggingFaceReference(t *testing.T) {
+	repo, revision, err := parseHuggingFaceReference("Qwen/Qwen3-0.6B")
+	require.NoError(t, err)
+	require.Equal(t, "Qwen/Qwen3-0.6B", repo)
+	require.Equal(t, "main", revision)
+
+	repo, revision, err = parseHuggingFaceReference("gpt2@v1.0")
+	require.NoError(t, err)
+	require.Equal(t, "gpt2", repo)
+	require.Equal(t, "v1.0", revision)
+
+	for _, reference := range []string{"", "a/b/c", "../a", "a/"} {
+		_, _, err := parseHuggingFaceReference(reference)
+		require.Error(t, err, reference)
+	}
+}
+
+func newTestHuggingFaceServer(t *testing.T, files map[string]string, token string) *httptest.Server {
+	t.Helper()
+
+	siblings := []map[string]interface{}{}
+	for name, content := range files {
+		sum := sha256.Sum256([]byte(content))
+		siblings = append(siblings, map[string]interface{}{
+			"rfilename": name,
+			"size":      len(content),
+			"lfs": map[string]interface{}{
+				"sha256": hex.EncodeToString(sum[:]),
+				"size":   len(content),
+			},
+		})
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/models/org/model/revision/main", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"sha":      "abc123",
+			"siblings": siblings,
+		})
+	})
+	mux.HandleFunc("/org/model/resolve/abc123/", func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path[len("/org/model/resolve/abc123/"):]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHuggingFacePuller_Pull(t *testing.T) {
+	files := map[string]string{
+		"config.json":                `{"model_type":"qwen3"}`,
+		"model.safetensors":          "weights",
+		"tokenizer/tokenizer #1.txt": "tokens",
+	}
+	server := newTestHuggingFaceServer(t, files, "secret")
+	t.Setenv("HF_TOKEN", "secret")
+
+	pullCfg := &config.PullConfig{
+		HuggingFace: config.HuggingFaceConfig{Endpoint: server.URL},
+	}
+	ctx := context.Background()
+	hook := status.NewHook(ctx)
+	targetDir := filepath.Join(t.TempDir(), "model")
+
+	puller := NewHuggingFacePuller(ctx, pullCfg, hook, nil)
+	require.NoError(t, puller.Pull(ctx, "org/model", targetDir, true, nil))
+
+	// The file path is escaped in the URL.
+	data, err := os.ReadFile(filepath.Join(targetDir, "tokenizer", "tokenizer #1.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "tokens", string(data))
+	require.FileExists(t, filepath.Join(targetDir, "config.json"))
+	require.NoFileExists(t, filepath.Join(targetDir, "model.safetensors"))
+	require.NoFileExists(t, getPullStatePath(targetDir))
+
+	progress := hook.GetProgress()
+	require.Equal(t, 2, progress.Total)
+	require.Len(t, progress.Items, 2)
+	require.Equal(t, int64(len("tokens")+len(files["config.json"])), progress.TotalBytes)
+	require.Equal(t, progress.TotalBytes, progress.DownloadedBytes)
+}
+
+func TestHuggingFacePuller_Unauthorized(t *testing.T) {
+	server := newTestHuggingFaceServer(t, map[string]string{"config.json": "{}"}, "secret")
+	t.Setenv("HF_TOKEN", "invalid")
+
+	pullCfg := &config.PullConfig{
+		HuggingFace: config.HuggingFaceConfig{Endpoint: server.URL},
+	}
+	ctx := context.Background()
+	puller := NewHuggingFacePuller(ctx, pullCfg, status.NewHook(ctx), nil)
+	err := puller.Pull(ctx, "org/model@main", filepath.Join(t.TempDir(), "model"), false, nil)
+	require.ErrorContains(t, err, "401")
+}
@@ -2,8 +2,15 @@ package service
 
 import (
 	"context"
+	"os"
+	"syscall"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 func (s *Service) GetPluginInfo(
@@ -23,25 +30,70 @@ func (s *Service) GetPluginCapabilities(
 	req *csi.GetPluginCapabilitiesRequest) (
 	*csi.GetPluginCapabilitiesResponse, error) {
 
-	resp := &csi.GetPluginCapabilitiesResponse{
-		Capabilities: []*csi.PluginCapability{
-			{
-				Type: &csi.PluginCapability_Service_{
-					Service: &csi.PluginCapability_Service{
-						Type: csi.PluginCapability_Service_CONTROLLER_SERVICE,
-					},
+	var caps []*csi.PluginCapability
+	for _, capability := range s.pluginCapabilities() {
+		caps = append(caps, &csi.PluginCapability{
+			Type: &csi.PluginCapability_Service_{
+				Service: &csi.PluginCapability_Service{
+					Type: capability,
 				},
 			},
-		},
+		})
+	}
+
+	resp := &csi.GetPluginCapabilitiesResponse{
+		Capabilities: caps,
 	}
 
 	return resp, nil
 }
 
+// The free space of the root dir below which the node can't pull the models.
+var probeMinFreeBytes int64 = 1 << 20
+
+// Probe checks the plugin can actually serve, so that the liveness probe
+// catches the broken node instead of the volumes failing silently: the config
+// file is parsed, and on the node, the root dir is writable, its disk isn't
+// full and the dynamic server is running. It fails with FAILED_PRECONDITION
+// if the plugin is broken, and returns not ready until the dynamic server is
+// started.
 func (s *Service) Probe(
 	ctx context.Context,
 	req *csi.ProbeRequest) (
 	*csi.ProbeResponse, error) {
+	if err := s.cfg.ReloadError(); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, errors.Wrap(err, "reload config").Error())
+	}
+
+	if s.cfg.Get().IsNodeMode() {
+		if err := probeRootDir(s.cfg.Get().RootDir); err != nil {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		if s.dynamicCSISockPath != "" && !s.DynamicServerManager.IsServing(s.dynamicCSISockPath) {
+			logger.WithContext(ctx).Warnf("dynamic server isn't serving: %s", s.dynamicCSISockPath)
+			return &csi.ProbeResponse{Ready: wrapperspb.Bool(false)}, nil
+		}
+	}
+
+	return &csi.ProbeResponse{Ready: wrapperspb.Bool(true)}, nil
+}
+
+// probeRootDir checks the root dir is writable and its disk isn't full.
+func probeRootDir(rootDir string) error {
+	file, err := os.CreateTemp(rootDir, ".probe-")
+	if err != nil {
+		return errors.Wrapf(err, "write root dir: %s", rootDir)
+	}
+	_ = file.Close()
+	_ = os.Remove(file.Name())
+
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(rootDir, &st); err != nil {
+		return errors.Wrapf(err, "stat root dir: %s", rootDir)
+	}
+	if free := int64(st.Bavail) * int64(st.Bsize); free < probeMinFreeBytes {
+		return errors.Errorf("disk of root dir %s is full: %d bytes free", rootDir, free)
+	}
 
-	return &csi.ProbeResponse{}, nil
+	return nil
 }
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	modelServiceName = "modelcsi.model.v1.Model"
+	modelProtoFile   = "modelcsi/model/v1/model.proto"
+)
+
+// inspectModel inspects the model image from the registry without pulling
+// it.
+var inspectModel = func(ctx context.Context, pullCfg *config.PullConfig, reference string) (*ModelInspection, error) {
+	b, plainHTTP, err := newBackend(reference)
+	if err != nil {
+		return nil, err
+	}
+	return NewModelArtifact(b, reference, plainHTTP, pullCfg.TLS.SkipVerify()).Inspect(ctx)
+}
+
+// InspectModel returns the manifest-level info of the model image, e.g. the
+// size of the model and its weights, so that the callers check the capacity
+// before mounting the model.
+func (s *Service) InspectModel(ctx context.Context, reference string) (*ModelInspection, error) {
+	ctx = logger.NewContext(ctx, "InspectModel", "", "")
+	reference = strings.TrimSpace(reference)
+	if reference == "" {
+		return nil, status.Error(codes.InvalidArgument, "reference is required")
+	}
+
+	inspection, err := inspectModel(ctx, registryPullConfig(&s.cfg.Get().PullConfig, reference), reference)
+	if err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrapf(err, "inspect model: %s", reference).Error())
+	}
+
+	return inspection, nil
+}
+
+// The model service is defined by the descriptor registered here instead of
+// a generated proto, the same as the admin service. Its Inspect takes the
+// reference as {"reference": "..."} and returns {"model": ModelInspection}.
+func init() {
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String(modelProtoFile),
+		Package:    proto.String("modelcsi.model.v1"),
+		Dependency: []string{"google/protobuf/struct.proto"},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Model"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Inspect"),
+				InputType:  proto.String(".google.protobuf.Struct"),
+				OutputType: proto.String(".google.protobuf.Struct"),
+			}},
+		}},
+		Syntax: proto.String("proto3"),
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(errors.Wrap(err, "create model service descriptor"))
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(file); err != nil {
+		panic(errors.Wrap(err, "register model service descriptor"))
+	}
+}
+
+// ModelService inspects the models on the external gRPC endpoint.
+type ModelService interface {
+	Inspect(context.Context, *structpb.Struct) (*structpb.Struct, error)
+}
+
+// ModelServer serves the model service by the service.
+type ModelServer struct {
+	svc *Service
+}
+
+func NewModelServer(svc *Service) *ModelServer {
+	return &ModelServer{svc: svc}
+}
+
+func (server *ModelServer) Inspect(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	inspection, err := server.svc.InspectModel(ctx, req.GetFields()["reference"].GetStringValue())
+	if err != nil {
+		return nil, err
+	}
+	return toStruct("model", inspection)
+}
+
+// RegisterModelServer registers the model service to the gRPC server.
+func RegisterModelServer(server *grpc.Server, model ModelService) {
+	fullMethod := "/" + modelServiceName + "/Inspect"
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: modelServiceName,
+		HandlerType: (*ModelService)(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Inspect",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &structpb.Struct{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(ModelService).Inspect(ctx, req.(*structpb.Struct))
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod}, handler)
+			},
+		}},
+		Streams:  []grpc.StreamDesc{},
+		Metadata: modelProtoFile,
+	}, model)
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcStatus "google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func mockInspectModel(t *testing.T) {
+	origInspectModel := inspectModel
+	t.Cleanup(func() { inspectModel = origInspectModel })
+	inspectModel = func(ctx context.Context, pullCfg *config.PullConfig, reference string) (*ModelInspection, error) {
+		if reference != "test/model:latest" {
+			return nil, errors.New("not found")
+		}
+		return &ModelInspection{
+			Reference:   reference,
+			TotalSize:   3,
+			WeightsSize: 2,
+			LayerCount:  2,
+			Files: []InspectedFile{
+				{Path: "model.safetensors", Digest: "sha256:layer1", Size: 2, Weights: true},
+				{Path: "config.json", Digest: "sha256:layer2", Size: 1},
+			},
+		}, nil
+	}
+}
+
+func TestInspectModel(t *testing.T) {
+	svc, _ := newNodeService(t)
+	mockInspectModel(t)
+	ctx := context.Background()
+
+	_, err := svc.InspectModel(ctx, " ")
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+	_, err = svc.InspectModel(ctx, "test/model:missing")
+	require.Equal(t, codes.Internal, grpcStatus.Code(err))
+
+	inspection, err := svc.InspectModel(ctx, " test/model:latest ")
+	require.NoError(t, err)
+	require.Equal(t, "test/model:latest", inspection.Reference)
+	require.Equal(t, int64(2), inspection.WeightsSize)
+	require.Len(t, inspection.Files, 2)
+}
+
+func TestDynamicServerHandler_InspectModel(t *testing.T) {
+	h, _ := newHandler(t)
+	mockInspectModel(t)
+
+	c, rec := newHandlerContextWithParam(t, http.MethodGet, "/", "", nil, nil)
+	_ = h.InspectModel(c)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	c, rec = newHandlerContextWithParam(t, http.MethodGet, "/?reference=test/model:latest", "", nil, nil)
+	_ = h.InspectModel(c)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var inspection ModelInspection
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &inspection))
+	require.Equal(t, int64(3), inspection.TotalSize)
+	require.Equal(t, 2, inspection.LayerCount)
+}
+
+func TestModelServer(t *testing.T) {
+	svc, _ := newNodeService(t)
+	mockInspectModel(t)
+	ctx := context.Background()
+
+	_, err := protoregistry.GlobalFiles.FindDescriptorByName(modelServiceName + ".Inspect")
+	require.NoError(t, err)
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	RegisterModelServer(server, NewModelServer(svc))
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	req, err := structpb.NewStruct(map[string]interface{}{"reference": "test/model:latest"})
+	require.NoError(t, err)
+	resp := &structpb.Struct{}
+	require.NoError(t, conn.Invoke(ctx, "/"+modelServiceName+"/Inspect", req, resp))
+	model := resp.GetFields()["model"].GetStructValue().GetFields()
+	require.Equal(t, float64(3), model["total_size"].GetNumberValue())
+	require.Len(t, model["files"].GetListValue().GetValues(), 2)
+
+	err = conn.Invoke(ctx, "/"+modelServiceName+"/Inspect", &structpb.Struct{}, resp)
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+}
@@ -2,12 +2,16 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 )
 
@@ -26,6 +30,37 @@ func (s *Service) getNode(ctx context.Context, nodeName string) (*corev1.Node, e
 	return s.node.Get(ctx, nodeName, metav1.GetOptions{})
 }
 
+func patchNodeAnnotation(ctx context.Context, node v1.NodeInterface, nodeName, key, value string) error {
+	return patchNodeAnnotations(ctx, node, nodeName, map[string]interface{}{key: value})
+}
+
+// removeNodeAnnotation removes the annotation from the node, it's a no-op if
+// the annotation doesn't exist.
+func removeNodeAnnotation(ctx context.Context, node v1.NodeInterface, nodeName, key string) error {
+	return patchNodeAnnotations(ctx, node, nodeName, map[string]interface{}{key: nil})
+}
+
+// patchNodeAnnotations merges the annotations into the node, the annotation
+// with nil value is removed.
+func patchNodeAnnotations(ctx context.Context, node v1.NodeInterface, nodeName string, annotations map[string]interface{}) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "marshal node annotation patch")
+	}
+
+	if _, err := node.Patch(ctx, nodeName, types.StrategicMergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return errors.Wrapf(err, "patch node: %s", nodeName)
+	}
+
+	return nil
+}
+
+var errNodeNotFound = errors.New("node not found")
+
 type nodeInfo struct {
 	ip       string
 	hostname string
@@ -59,3 +94,61 @@ func getNodeInfo(node *corev1.Node) (*nodeInfo, error) {
 		hostname: hostname,
 	}, nil
 }
+
+// getVolumeHostname returns the hostname of the node the volume is created on,
+// it's resolved from the node affinity of the PV, which is derived from the
+// accessible topology returned by CreateVolume.
+func (s *Service) getVolumeHostname(ctx context.Context, volumeID string) (string, error) {
+	pvs, err := s.pvs.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", errors.Wrap(err, "list persistent volumes")
+	}
+
+	for _, pv := range pvs.Items {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != s.cfg.Get().ServiceName || pv.Spec.CSI.VolumeHandle != volumeID {
+			continue
+		}
+		if pv.Spec.NodeAffinity != nil && pv.Spec.NodeAffinity.Required != nil {
+			for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+				for _, expr := range term.MatchExpressions {
+					if expr.Key == labelHostname && expr.Operator == corev1.NodeSelectorOpIn && len(expr.Values) > 0 {
+						return expr.Values[0], nil
+					}
+				}
+			}
+		}
+		return "", errors.Errorf("no %s node affinity in persistent volume: %s", labelHostname, pv.Name)
+	}
+
+	return "", errors.Errorf("persistent volume not found for volume: %s", volumeID)
+}
+
+func (s *Service) getNodeInfoByVolume(ctx context.Context, volumeID string) (*nodeInfo, error) {
+	hostname, err := s.getVolumeHostname(ctx, volumeID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getNodeInfoByHostname(ctx, hostname)
+}
+
+// getNodeInfoByHostname returns the info of the node labeled with the
+// hostname, the hostname of the topology segment of the volumes.
+func (s *Service) getNodeInfoByHostname(ctx context.Context, hostname string) (*nodeInfo, error) {
+	nodes, err := s.node.List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{labelHostname: hostname}).String(),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "list nodes by hostname: %s", hostname)
+	}
+	if len(nodes.Items) == 0 {
+		return nil, errors.Wrapf(errNodeNotFound, "hostname: %s", hostname)
+	}
+
+	nodeInfo, err := getNodeInfo(&nodes.Items[0])
+	if err != nil {
+		return nil, errors.Wrapf(err, "get node info by hostname: %s", hostname)
+	}
+
+	return nodeInfo, nil
+}
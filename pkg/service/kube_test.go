@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetNodeInfoByVolume(t *testing.T) {
+	newPV := func(name, driver, hostname string) *corev1.PersistentVolume {
+		return &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: corev1.PersistentVolumeSpec{
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					CSI: &corev1.CSIPersistentVolumeSource{Driver: driver, VolumeHandle: name},
+				},
+				NodeAffinity: &corev1.VolumeNodeAffinity{
+					Required: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+							MatchExpressions: []corev1.NodeSelectorRequirement{{
+								Key:      labelHostname,
+								Operator: corev1.NodeSelectorOpIn,
+								Values:   []string{hostname},
+							}},
+						}},
+					},
+				},
+			},
+		}
+	}
+	clientset := fake.NewSimpleClientset(
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{labelHostname: "host-1"}},
+			Status: corev1.NodeStatus{
+				Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.0.0.1"}},
+			},
+		},
+		newPV("pvc-on-node-1", "model.csi.modelpack.org", "host-1"),
+		newPV("pvc-other-driver", "other.csi.example.com", "host-1"),
+		newPV("pvc-unknown-node", "model.csi.modelpack.org", "host-2"),
+	)
+	svc := &Service{
+		cfg:  config.NewWithRaw(&config.RawConfig{ServiceName: "model.csi.modelpack.org"}),
+		node: clientset.CoreV1().Nodes(),
+		pvs:  clientset.CoreV1().PersistentVolumes(),
+	}
+	ctx := context.Background()
+
+	nodeInfo, err := svc.getNodeInfoByVolume(ctx, "pvc-on-node-1")
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.1", nodeInfo.ip)
+	require.Equal(t, "host-1", nodeInfo.hostname)
+
+	for _, volumeID := range []string{"pvc-other-driver", "pvc-unknown-node", "pvc-nonexistent"} {
+		_, err := svc.getNodeInfoByVolume(ctx, volumeID)
+		require.Error(t, err, volumeID)
+	}
+}
+
+func TestSelectNode(t *testing.T) {
+	newNode := func(name, hostname, ip string) *corev1.Node {
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{labelHostname: hostname}},
+		}
+		node.Status.Addresses = []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: ip}}
+		return node
+	}
+	clientset := fake.NewSimpleClientset(
+		newNode("node-1", "host-1", "10.0.0.1"),
+		newNode("node-2", "host-2", "10.0.0.2"),
+		newNode("node-3", "host-3", ""),
+	)
+	svc := &Service{
+		cfg:  config.NewWithRaw(&config.RawConfig{ServiceName: "model.csi.modelpack.org", Mode: "controller"}),
+		node: clientset.CoreV1().Nodes(),
+	}
+	ctx := context.Background()
+	topologies := func(hostnames ...string) []*csi.Topology {
+		topologies := []*csi.Topology{}
+		for _, hostname := range hostnames {
+			topologies = append(topologies, &csi.Topology{Segments: map[string]string{labelHostname: hostname}})
+		}
+		return topologies
+	}
+
+	// The selected node is in the requisite topologies.
+	nodeInfo, err := svc.selectNode(ctx, "pvc-1", "node-2", nil)
+	require.NoError(t, err)
+	require.Equal(t, "host-2", nodeInfo.hostname)
+	nodeInfo, err = svc.selectNode(ctx, "pvc-1", "node-2", &csi.TopologyRequirement{Requisite: topologies("host-1", "host-2")})
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.2", nodeInfo.ip)
+	_, err = svc.selectNode(ctx, "pvc-1", "node-2", &csi.TopologyRequirement{Requisite: topologies("host-1")})
+	require.Equal(t, codes.ResourceExhausted, grpcStatus.Code(err))
+
+	// The preferred node is selected first, skipping the unavailable ones.
+	nodeInfo, err = svc.selectNode(ctx, "pvc-1", "", &csi.TopologyRequirement{
+		Requisite: topologies("host-1", "host-2", "host-3", "host-4"),
+		Preferred: topologies("host-4", "host-3", "host-2"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "host-2", nodeInfo.hostname)
+
+	// The volumes are spread across the requisite nodes, the same volume is
+	// always on the same node.
+	hostnames := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		volumeName := fmt.Sprintf("pvc-%d", i)
+		requirements := &csi.TopologyRequirement{Requisite: topologies("host-1", "host-2")}
+		nodeInfo, err := svc.selectNode(ctx, volumeName, "", requirements)
+		require.NoError(t, err)
+		again, err := svc.selectNode(ctx, volumeName, "", requirements)
+		require.NoError(t, err)
+		require.Equal(t, nodeInfo.hostname, again.hostname)
+		hostnames[nodeInfo.hostname] = true
+	}
+	require.Len(t, hostnames, 2)
+
+	_, err = svc.selectNode(ctx, "pvc-1", "", &csi.TopologyRequirement{Requisite: topologies("host-3", "host-4")})
+	require.Equal(t, codes.ResourceExhausted, grpcStatus.Code(err))
+	_, err = svc.selectNode(ctx, "pvc-1", "", &csi.TopologyRequirement{
+		Requisite: []*csi.Topology{{Segments: map[string]string{"topology.kubernetes.io/zone": "zone-a"}}},
+	})
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+	_, err = svc.selectNode(ctx, "pvc-1", "", nil)
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ListMountsRequest filters, sorts and pages the mounts of the dynamic
+// volume, given by the query of the list request.
+type ListMountsRequest struct {
+	// The states of the mounts to list separated by ",", e.g.
+	// "PULL_QUEUED,PULLING", all the states if empty.
+	State string `query:"state"`
+	// The substring of the references of the mounts to list.
+	Reference string `query:"reference"`
+	// The label selector of the mounts to list, e.g. "tenant=team-a,env!=dev".
+	Labels string `query:"labels"`
+	// The field to sort the mounts by, one of "mount_id" (default),
+	// "reference", "state" and "size_in_bytes", prefixed by "-" to sort them
+	// in descending order.
+	Sort string `query:"sort"`
+	// The max number of the mounts to list, 0 for no limit.
+	Limit int `query:"limit"`
+	// The number of the mounts to skip.
+	Offset int `query:"offset"`
+
+	// The selector parsed from the labels on validate.
+	selector labels.Selector
+}
+
+// The fields to sort the mounts by.
+var mountSortKeys = map[string]func(a, b *modelStatus.Status) int{
+	"mount_id": func(a, b *modelStatus.Status) int {
+		return strings.Compare(a.MountID, b.MountID)
+	},
+	"reference": func(a, b *modelStatus.Status) int {
+		return strings.Compare(a.Reference, b.Reference)
+	},
+	"state": func(a, b *modelStatus.Status) int {
+		return strings.Compare(a.State, b.State)
+	},
+	"size_in_bytes": func(a, b *modelStatus.Status) int {
+		switch {
+		case a.SizeInBytes < b.SizeInBytes:
+			return -1
+		case a.SizeInBytes > b.SizeInBytes:
+			return 1
+		}
+		return 0
+	},
+}
+
+func (req *ListMountsRequest) validate() error {
+	if req.Limit < 0 {
+		return errors.Errorf("invalid limit: %d", req.Limit)
+	}
+	if req.Offset < 0 {
+		return errors.Errorf("invalid offset: %d", req.Offset)
+	}
+	if req.Sort != "" {
+		if _, ok := mountSortKeys[strings.TrimPrefix(req.Sort, "-")]; !ok {
+			return errors.Errorf("invalid sort: %s", req.Sort)
+		}
+	}
+	if req.Labels != "" {
+		selector, err := labels.Parse(req.Labels)
+		if err != nil {
+			return errors.Wrap(err, "invalid labels")
+		}
+		req.selector = selector
+	}
+	return nil
+}
+
+// match returns true if the mount matches the filters of the request.
+func (req *ListMountsRequest) match(mount *modelStatus.Status) bool {
+	if req.State != "" && !slices.Contains(strings.Split(req.State, ","), mount.State) {
+		return false
+	}
+	if req.selector != nil && !req.selector.Matches(labels.Set(mount.Labels)) {
+		return false
+	}
+	return strings.Contains(mount.Reference, req.Reference)
+}
+
+// sort sorts the mounts listed by the mount ID by the field of the request,
+// the mounts of the same field are kept in the order of the mount ID.
+func (req *ListMountsRequest) sort(mounts []modelStatus.Status) {
+	if req.Sort == "" {
+		return
+	}
+	compare := mountSortKeys[strings.TrimPrefix(req.Sort, "-")]
+	descending := strings.HasPrefix(req.Sort, "-")
+	sort.SliceStable(mounts, func(i, j int) bool {
+		if descending {
+			return compare(&mounts[i], &mounts[j]) > 0
+		}
+		return compare(&mounts[i], &mounts[j]) < 0
+	})
+}
+
+// page returns the page of the mounts by the offset and the limit.
+func (req *ListMountsRequest) page(mounts []modelStatus.Status) []modelStatus.Status {
+	if req.Offset >= len(mounts) {
+		return []modelStatus.Status{}
+	}
+	mounts = mounts[req.Offset:]
+	if req.Limit > 0 && len(mounts) > req.Limit {
+		mounts = mounts[:req.Limit]
+	}
+	return mounts
+}
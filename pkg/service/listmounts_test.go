@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+func TestListMounts(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	newMount := func(mountID, reference, state string, size int64) {
+		t.Helper()
+		mountDir := svc.cfg.Get().GetMountIDDirForDynamic("csi-vol", mountID)
+		require.NoError(t, os.MkdirAll(mountDir, 0755))
+		_, err := svc.sm.Set(filepath.Join(mountDir, "status.json"), status.Status{
+			MountID:     mountID,
+			Reference:   reference,
+			State:       state,
+			SizeInBytes: size,
+		})
+		require.NoError(t, err)
+	}
+	newMount("m1", "test/qwen:v1", status.StatePullSucceeded, 30)
+	newMount("m2", "test/llama:v1", status.StatePullRunning, 10)
+	newMount("m3", "test/qwen-lora:v1", status.StatePullFailed, 20)
+	newMount("m4", "test/qwen:v2", status.StatePullRunning, 40)
+	mountIDs := func(req ListMountsRequest) ([]string, int) {
+		t.Helper()
+		mounts, total, err := svc.ListMounts(ctx, "csi-vol", req)
+		require.NoError(t, err)
+		ids := []string{}
+		for _, mount := range mounts {
+			ids = append(ids, mount.MountID)
+		}
+		return ids, total
+	}
+
+	ids, total := mountIDs(ListMountsRequest{})
+	require.Equal(t, []string{"m1", "m2", "m3", "m4"}, ids)
+	require.Equal(t, 4, total)
+
+	ids, total = mountIDs(ListMountsRequest{State: "PULLING,PULL_FAILED"})
+	require.Equal(t, []string{"m2", "m3", "m4"}, ids)
+	require.Equal(t, 3, total)
+	ids, _ = mountIDs(ListMountsRequest{Reference: "qwen"})
+	require.Equal(t, []string{"m1", "m3", "m4"}, ids)
+	ids, _ = mountIDs(ListMountsRequest{State: status.StatePullRunning, Reference: "qwen"})
+	require.Equal(t, []string{"m4"}, ids)
+
+	ids, _ = mountIDs(ListMountsRequest{Sort: "size_in_bytes"})
+	require.Equal(t, []string{"m2", "m3", "m1", "m4"}, ids)
+	ids, _ = mountIDs(ListMountsRequest{Sort: "-size_in_bytes"})
+	require.Equal(t, []string{"m4", "m1", "m3", "m2"}, ids)
+	// The mounts of the same field are kept in the order of the mount ID.
+	ids, _ = mountIDs(ListMountsRequest{Sort: "state"})
+	require.Equal(t, []string{"m2", "m4", "m3", "m1"}, ids)
+
+	ids, total = mountIDs(ListMountsRequest{Sort: "reference", Limit: 2})
+	require.Equal(t, []string{"m2", "m3"}, ids)
+	require.Equal(t, 4, total)
+	ids, _ = mountIDs(ListMountsRequest{Sort: "reference", Limit: 2, Offset: 2})
+	require.Equal(t, []string{"m1", "m4"}, ids)
+	ids, total = mountIDs(ListMountsRequest{Offset: 5})
+	require.Empty(t, ids)
+	require.Equal(t, 4, total)
+
+	for _, req := range []ListMountsRequest{{Limit: -1}, {Offset: -1}, {Sort: "created_at"}} {
+		_, _, err := svc.ListMounts(ctx, "csi-vol", req)
+		require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+	}
+}
+
+func TestListMounts_Labels(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	for mountID, labels := range map[string]map[string]string{
+		"m1": {"tenant": "team-a", "env": "dev"},
+		"m2": {"tenant": "team-a"},
+		"m3": {"tenant": "team-b"},
+	} {
+		mountDir := svc.cfg.Get().GetMountIDDirForDynamic("csi-vol", mountID)
+		require.NoError(t, os.MkdirAll(mountDir, 0755))
+		_, err := svc.sm.Set(filepath.Join(mountDir, "status.json"), status.Status{MountID: mountID, Labels: labels})
+		require.NoError(t, err)
+	}
+
+	mounts, total, err := svc.ListMounts(ctx, "csi-vol", ListMountsRequest{Labels: "tenant=team-a,env!=dev"})
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+	require.Equal(t, "m2", mounts[0].MountID)
+
+	_, _, err = svc.ListMounts(ctx, "csi-vol", ListMountsRequest{Labels: "tenant in (team-a"})
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+}
+
+func TestDynamicServerHandler_ListVolumes_Query(t *testing.T) {
+	h, svc := newHandler(t)
+	for _, mountID := range []string{"m1", "m2", "m3"} {
+		newDynamicMount(t, svc, "csi-vol", mountID)
+	}
+
+	c, rec := newHandlerContextWithParam(t, http.MethodGet, "/?sort=-mount_id&limit=2", "",
+		[]string{"volume_name"}, []string{"csi-vol"})
+	require.NoError(t, h.ListVolumes(c))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "3", rec.Header().Get("X-Total-Count"))
+	var mounts []status.Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &mounts))
+	require.Len(t, mounts, 2)
+	require.Equal(t, "m3", mounts[0].MountID)
+
+	c, rec = newHandlerContextWithParam(t, http.MethodGet, "/?limit=invalid", "",
+		[]string{"volume_name"}, []string{"csi-vol"})
+	_ = h.ListVolumes(c)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	c, rec = newHandlerContextWithParam(t, http.MethodGet, "/?sort=invalid", "",
+		[]string{"volume_name"}, []string{"csi-vol"})
+	_ = h.ListVolumes(c)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
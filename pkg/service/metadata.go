@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"oras.land/oras-go/v2/content"
+)
+
+// The metadata file written at the root of the model dir, holding the config
+// of the model spec, e.g. the name, format, parameter size and quantization
+// of the model, so that the inference server can configure itself without
+// inspecting the model image from the registry.
+const metadataFile = ".model-metadata.json"
+
+// The max size of the model config blob, which is small JSON.
+const maxModelConfigSize = 4 << 20
+
+type modelMetadata struct {
+	Reference string        `json:"reference"`
+	Digest    digest.Digest `json:"digest"`
+	// The config of the model spec as is, see
+	// https://github.com/modelpack/model-spec/blob/main/docs/config.md
+	Config json.RawMessage `json:"config"`
+}
+
+// fetchModelMetadata fetches the model config of the model image, nil is
+// returned if the image isn't a model packed with the model spec.
+func fetchModelMetadata(ctx context.Context, pullCfg *config.PullConfig, reference string) (*modelMetadata, error) {
+	repo, err := newOCIRepository(pullCfg, reference)
+	if err != nil {
+		return nil, err
+	}
+	manifestDesc, manifest, err := fetchManifest(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	if !isModelManifest(manifest) {
+		return nil, nil
+	}
+	if manifest.Config.Size > maxModelConfigSize {
+		return nil, errors.Errorf("model config too large: %d bytes", manifest.Config.Size)
+	}
+
+	rc, err := repo.Fetch(ctx, manifest.Config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fetch model config: %s", manifest.Config.Digest)
+	}
+	defer func() { _ = rc.Close() }()
+	data, err := content.ReadAll(rc, manifest.Config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read model config: %s", manifest.Config.Digest)
+	}
+	if !json.Valid(data) {
+		return nil, errors.Errorf("invalid model config: %s", manifest.Config.Digest)
+	}
+
+	return &modelMetadata{
+		Reference: reference,
+		Digest:    manifestDesc.Digest,
+		Config:    data,
+	}, nil
+}
+
+// writeModelMetadata writes the metadata file into the model dir of the model
+// image, if enabled by write_metadata_file. The metadata is best effort, the
+// failure is logged without failing the pull.
+func (worker *Worker) writeModelMetadata(ctx context.Context, reference, modelDir string, opts PullOptions, reused bool) {
+	pullCfg := worker.cfg.Get().PullConfig
+	if !pullCfg.WriteMetadataFile || !isImageModelType(opts.Type) {
+		return
+	}
+	metadataPath := filepath.Join(modelDir, metadataFile)
+	// The metadata file is cloned with the model dir from another volume.
+	if reused {
+		if _, err := os.Stat(metadataPath); err == nil {
+			return
+		}
+	}
+
+	err := func() error {
+		metadata, err := fetchModelMetadata(ctx, &pullCfg, reference)
+		if err != nil || metadata == nil {
+			return err
+		}
+		data, err := json.MarshalIndent(metadata, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "marshal model metadata")
+		}
+		// Replace the file atomically, so that the metadata file hardlinked
+		// from another model dir is never changed.
+		tmpPath := metadataPath + ".tmp"
+		if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+			return errors.Wrapf(err, "write metadata file: %s", tmpPath)
+		}
+		if err := os.Rename(tmpPath, metadataPath); err != nil {
+			_ = os.Remove(tmpPath)
+			return errors.Wrapf(err, "rename metadata file: %s", tmpPath)
+		}
+		return nil
+	}()
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("failed to write model metadata: %s", reference)
+	}
+}
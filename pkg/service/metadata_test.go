@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	modelspec "github.com/modelpack/model-spec/specs-go/v1"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullModel_MetadataFile(t *testing.T) {
+	modelConfig := []byte(`{"descriptor":{"name":"qwen3"},"config":{"format":"safetensors","paramSize":"0.6b","quantization":"awq"}}`)
+	manifest := ocispec.Manifest{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: modelspec.ArtifactTypeModelManifest,
+		Config: ocispec.Descriptor{
+			MediaType: modelspec.MediaTypeModelConfig,
+			Digest:    digest.FromBytes(modelConfig),
+			Size:      int64(len(modelConfig)),
+		},
+	}
+	manifestData, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	host := newFakeRegistry(t, manifest, map[digest.Digest][]byte{digest.FromBytes(modelConfig): modelConfig})
+	reference := host + "/test/model:latest"
+
+	worker := newWorkerWithMockPuller(t, nil)
+	worker.cfg.Get().PullConfig.WriteMetadataFile = true
+	worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return &namedPuller{}
+	}
+
+	modelDir := worker.cfg.Get().GetModelDir("pvc-metadata")
+	require.NoError(t, worker.PullModel(context.Background(), true, "pvc-metadata", "", reference, modelDir, PullOptions{}))
+	data, err := os.ReadFile(filepath.Join(modelDir, metadataFile))
+	require.NoError(t, err)
+
+	var metadata modelMetadata
+	require.NoError(t, json.Unmarshal(data, &metadata))
+	require.Equal(t, reference, metadata.Reference)
+	require.Equal(t, digest.FromBytes(manifestData), metadata.Digest)
+	require.JSONEq(t, string(modelConfig), string(metadata.Config))
+
+	// The metadata isn't written for the other model types.
+	hfDir := t.TempDir()
+	worker.writeModelMetadata(context.Background(), "Qwen/Qwen3-0.6B", hfDir, PullOptions{Type: ModelTypeHuggingFace}, false)
+	require.NoFileExists(t, filepath.Join(hfDir, metadataFile))
+}
+
+func TestFetchModelMetadata_GenericArtifact(t *testing.T) {
+	host := newFakeRegistry(t, ocispec.Manifest{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.example.model",
+		Config:       ocispec.DescriptorEmptyJSON,
+	}, map[digest.Digest][]byte{})
+
+	metadata, err := fetchModelMetadata(context.Background(), &config.PullConfig{}, host+"/test/model:latest")
+	require.NoError(t, err)
+	require.Nil(t, metadata)
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/mounter"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/modelpack/model-csi-driver/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+type MigrateResult struct {
+	// The volumes copied and verified under the new root dir.
+	Volumes []string
+	// The target paths re-mounted from the new root dir on the host, the running
+	// containers still see the old root dir as the bind mounts are not propagated
+	// into them, so their pods need to be restarted.
+	Remounted []string
+	// The volumes still mounted from the old root dir because the target path
+	// is unknown (published by the old version), their pods need to be restarted.
+	Stale []string
+}
+
+// MigrateRootDir relocates the volumes tree from the configured root dir to the
+// new root dir (e.g. on a bigger cache disk) without evicting the models, it must
+// be run while the driver is stopped, then the driver can be started with the
+// new root_dir, which recovers the dynamic csi.sock servers under it.
+//
+// The old root dir is kept as is, it's still used by the running pods of the
+// remounted and stale volumes in the result until they are restarted.
+func MigrateRootDir(ctx context.Context, cfg *config.Config, newRootDir string) (*MigrateResult, error) {
+	oldRootDir := cfg.Get().RootDir
+	if newRootDir == "" {
+		return nil, errors.New("new root dir is required")
+	}
+	if filepath.Clean(newRootDir) == filepath.Clean(oldRootDir) {
+		return nil, errors.Errorf("new root dir is the same as the old one: %s", newRootDir)
+	}
+	for _, pair := range [][2]string{{oldRootDir, newRootDir}, {newRootDir, oldRootDir}} {
+		under, err := utils.IsPathUnder(pair[0], pair[1])
+		if err != nil {
+			return nil, errors.Wrap(err, "check root dirs")
+		}
+		if under {
+			return nil, errors.Errorf("root dir %s and %s must not be nested", oldRootDir, newRootDir)
+		}
+	}
+
+	// Make sure the driver or another migration isn't running on both root dirs.
+	oldLock, err := utils.LockDir(oldRootDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "lock old root dir")
+	}
+	defer func() { _ = oldLock.Close() }()
+	newLock, err := utils.LockDir(newRootDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "lock new root dir")
+	}
+	defer func() { _ = newLock.Close() }()
+
+	oldCfg := cfg.Get()
+	newCfg := *oldCfg
+	newCfg.RootDir = newRootDir
+
+	newVolumesDir := newCfg.GetVolumesDir()
+	newBlobsDir := newCfg.GetBlobsDir()
+	for _, dir := range []string{newVolumesDir, newBlobsDir} {
+		if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+			return nil, errors.Errorf("dir is not empty: %s", dir)
+		} else if err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "read dir: %s", dir)
+		}
+	}
+
+	oldVolumesDir := oldCfg.GetVolumesDir()
+	volumeDirs, err := os.ReadDir(oldVolumesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &MigrateResult{}, nil
+		}
+		return nil, errors.Wrapf(err, "read volume dirs from %s", oldVolumesDir)
+	}
+
+	volumeNames := []string{}
+	for _, volumeDir := range volumeDirs {
+		if isVolumeDirEntry(oldVolumesDir, volumeDir) {
+			volumeNames = append(volumeNames, volumeDir.Name())
+		}
+	}
+
+	// Copy all the volumes before remounting any of them, so that the partially
+	// copied tree can be removed on failure without breaking the target paths.
+	if err := copyRootDir(ctx, oldCfg, &newCfg, volumeNames); err != nil {
+		for _, dir := range []string{newVolumesDir, newBlobsDir} {
+			if err := os.RemoveAll(dir); err != nil {
+				logger.WithContext(ctx).WithError(err).Warnf("failed to cleanup dir: %s", dir)
+			}
+		}
+		return nil, err
+	}
+
+	sm, err := modelStatus.NewStatusManager()
+	if err != nil {
+		return nil, errors.Wrap(err, "create status manager")
+	}
+	defer func() { _ = sm.Close() }()
+
+	result := &MigrateResult{Volumes: volumeNames}
+	for _, volumeName := range volumeNames {
+		ctx := logger.NewContext(ctx, "MigrateRootDir", volumeName, "")
+
+		newVolumeDir := newCfg.GetVolumeDir(volumeName)
+		volumeStatus, err := sm.Get(filepath.Join(newVolumeDir, "status.json"))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "get volume status: %s", volumeName)
+		}
+		if !isDynamicVolume(volumeName) || volumeStatus.Inline {
+			if volumeStatus.State != modelStatus.StateMounted && volumeStatus.State != modelStatus.StateWeightsPulling {
+				continue
+			}
+		}
+		if len(volumeStatus.Targets) == 0 {
+			result.Stale = append(result.Stale, volumeName)
+			continue
+		}
+
+		for _, target := range volumeStatus.Targets {
+			remounted, err := remountVolume(ctx, &newCfg, volumeName, volumeStatus, target)
+			if err != nil {
+				return nil, errors.Wrapf(err, "remount volume: %s", volumeName)
+			}
+			if remounted {
+				result.Remounted = append(result.Remounted, target.Path)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// copyRootDir copies the blobs and the volume dirs to the new root dir, the
+// model files hardlinked to the blobs are still hardlinked after the copy.
+func copyRootDir(ctx context.Context, oldCfg, newCfg *config.RawConfig, volumeNames []string) error {
+	copier := utils.NewDirCopier()
+
+	oldBlobsDir := oldCfg.GetBlobsDir()
+	if _, err := os.Stat(oldBlobsDir); err == nil {
+		logger.WithContext(ctx).Infof("copying blobs dir to %s", newCfg.GetBlobsDir())
+		if err := copier.Copy(oldBlobsDir, newCfg.GetBlobsDir()); err != nil {
+			return errors.Wrapf(err, "copy blobs dir: %s", oldBlobsDir)
+		}
+		if err := verifyDir(oldBlobsDir, newCfg.GetBlobsDir()); err != nil {
+			return errors.Wrapf(err, "verify blobs dir: %s", newCfg.GetBlobsDir())
+		}
+	} else if !os.IsNotExist(err) {
+		return errors.Wrapf(err, "stat blobs dir: %s", oldBlobsDir)
+	}
+
+	for _, volumeName := range volumeNames {
+		ctx := logger.NewContext(ctx, "MigrateRootDir", volumeName, "")
+		oldVolumeDir := oldCfg.GetVolumeDir(volumeName)
+		newVolumeDir := newCfg.GetVolumeDir(volumeName)
+		logger.WithContext(ctx).Infof("copying volume dir to %s", newVolumeDir)
+		// The volume dir placed on a storage tier is kept there, only the
+		// link to it is copied.
+		if err := os.MkdirAll(filepath.Dir(newVolumeDir), 0755); err != nil {
+			return errors.Wrapf(err, "create volumes dir: %s", filepath.Dir(newVolumeDir))
+		}
+		if err := copier.Copy(oldVolumeDir, newVolumeDir); err != nil {
+			return errors.Wrapf(err, "copy volume dir: %s", oldVolumeDir)
+		}
+		if err := verifyDir(oldVolumeDir, newVolumeDir); err != nil {
+			return errors.Wrapf(err, "verify volume dir: %s", newVolumeDir)
+		}
+	}
+
+	return nil
+}
+
+// remountVolume switches the bind mount of the target path to the source
+// under the new root dir.
+func remountVolume(ctx context.Context, newCfg *config.RawConfig, volumeName string, volumeStatus *modelStatus.Status, target modelStatus.Target) (bool, error) {
+	targetPath := target.Path
+	isMounted, err := mounter.IsMounted(ctx, targetPath)
+	if err != nil {
+		return false, errors.Wrap(err, "check if target path is mounted")
+	}
+	if !isMounted {
+		return false, nil
+	}
+
+	builder := mounter.NewBuilder()
+	var mountBuilder mounter.Builder
+	if isDynamicVolume(volumeName) && !volumeStatus.Inline {
+		if target.ReadOnly || isReadOnlyMount(newCfg, false) {
+			builder = builder.ReadOnly()
+		}
+		mountBuilder = builder.RBind().From(newCfg.GetVolumeDirForDynamic(volumeName)).MountPoint(targetPath)
+	} else {
+		if target.ReadOnly || isReadOnlyMount(newCfg, volumeStatus.ReadOnly) {
+			builder = builder.ReadOnly()
+		}
+		mountBuilder = builder.Bind().From(newCfg.GetModelDir(volumeName)).MountPoint(targetPath)
+	}
+
+	if err := mounter.UMount(ctx, targetPath, true); err != nil {
+		return false, errors.Wrap(err, "unmount target path")
+	}
+	if err := mounter.Mount(ctx, mountBuilder); err != nil {
+		return false, errors.Wrap(err, "bind mount target path")
+	}
+	logger.WithContext(ctx).Infof("remounted target path: %s", targetPath)
+
+	return true, nil
+}
+
+// verifyDir ensures the regular files and symlinks in the src directory tree
+// are identical in the dst directory tree.
+func verifyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch mode := entry.Type(); {
+		case mode&os.ModeSymlink != 0:
+			srcLink, err := os.Readlink(path)
+			if err != nil {
+				return errors.Wrapf(err, "read link: %s", path)
+			}
+			dstLink, err := os.Readlink(target)
+			if err != nil {
+				return errors.Wrapf(err, "read link: %s", target)
+			}
+			if srcLink != dstLink {
+				return errors.Errorf("link mismatched: %s", target)
+			}
+		case mode.IsRegular():
+			srcSum, err := fileChecksum(path)
+			if err != nil {
+				return err
+			}
+			dstSum, err := fileChecksum(target)
+			if err != nil {
+				return err
+			}
+			if srcSum != dstSum {
+				return errors.Errorf("checksum mismatched: %s", target)
+			}
+		}
+
+		return nil
+	})
+}
+
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "open file: %s", path)
+	}
+	defer func() { _ = file.Close() }()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", errors.Wrapf(err, "read file: %s", path)
+	}
+
+	return string(hash.Sum(nil)), nil
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/modelpack/model-csi-driver/pkg/mounter"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/modelpack/model-csi-driver/pkg/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateRootDir(t *testing.T) {
+	svc, oldRootDir := newNodeService(t)
+	newRootDir := filepath.Join(t.TempDir(), "new")
+	cfg := svc.cfg.Get()
+
+	// Static volume mounted to a target path.
+	staticModelDir := cfg.GetModelDir("pvc-static")
+	require.NoError(t, os.MkdirAll(staticModelDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(staticModelDir, "model.safetensors"), []byte("weights"), 0644))
+	require.NoError(t, os.Symlink("model.safetensors", filepath.Join(staticModelDir, "link")))
+	// The model file shared with the blob store.
+	blobPath := filepath.Join(cfg.GetBlobsDir(), "sha256", "abc")
+	require.NoError(t, os.MkdirAll(filepath.Dir(blobPath), 0755))
+	require.NoError(t, os.Link(filepath.Join(staticModelDir, "model.safetensors"), blobPath))
+	_, err := svc.sm.Set(filepath.Join(cfg.GetVolumeDir("pvc-static"), "status.json"), modelStatus.Status{
+		VolumeName: "pvc-static",
+		State:      modelStatus.StateMounted,
+		Targets:    []modelStatus.Target{{Path: "/pods/uid-static/mount"}},
+		ReadOnly:   true,
+	})
+	require.NoError(t, err)
+
+	// Dynamic root volume with a csi.sock server.
+	_, err = svc.sm.Set(filepath.Join(cfg.GetVolumeDirForDynamic("csi-dynamic"), "status.json"), modelStatus.Status{
+		VolumeName: "csi-dynamic",
+		Targets: []modelStatus.Target{
+			{Path: "/pods/uid-dynamic/mount"},
+			{Path: "/pods/uid-dynamic-2/mount"},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(cfg.GetCSISockDirForDynamic("csi-dynamic"), 0755))
+	listener, err := net.Listen("unix", cfg.GetCSISockPathForDynamic("csi-dynamic"))
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+
+	// Dynamic root volume published by the old version without target path.
+	_, err = svc.sm.Set(filepath.Join(cfg.GetVolumeDirForDynamic("csi-legacy"), "status.json"), modelStatus.Status{
+		VolumeName: "csi-legacy",
+	})
+	require.NoError(t, err)
+
+	patchIsMounted := gomonkey.ApplyFunc(mounter.IsMounted, func(ctx context.Context, mountPoint string) (bool, error) {
+		return true, nil
+	})
+	defer patchIsMounted.Reset()
+	umounted := []string{}
+	patchUMount := gomonkey.ApplyFunc(mounter.UMount, func(ctx context.Context, mountPoint string, lazy bool) error {
+		umounted = append(umounted, mountPoint)
+		return nil
+	})
+	defer patchUMount.Reset()
+	mounted := []string{}
+	patchMount := gomonkey.ApplyFunc(mounter.Mount, func(ctx context.Context, builder mounter.Builder) error {
+		cmd, err := builder.Build()
+		require.NoError(t, err)
+		mounted = append(mounted, cmd.String())
+		return nil
+	})
+	defer patchMount.Reset()
+
+	result, err := MigrateRootDir(context.Background(), svc.cfg, newRootDir)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"pvc-static", "csi-dynamic", "csi-legacy"}, result.Volumes)
+	require.ElementsMatch(t, []string{"/pods/uid-static/mount", "/pods/uid-dynamic/mount", "/pods/uid-dynamic-2/mount"}, result.Remounted)
+	require.Equal(t, []string{"csi-legacy"}, result.Stale)
+	require.ElementsMatch(t, result.Remounted, umounted)
+	require.Len(t, mounted, 3)
+
+	newModelDir := filepath.Join(newRootDir, "volumes", "pvc-static", "model")
+	data, err := os.ReadFile(filepath.Join(newModelDir, "model.safetensors"))
+	require.NoError(t, err)
+	require.Equal(t, "weights", string(data))
+	link, err := os.Readlink(filepath.Join(newModelDir, "link"))
+	require.NoError(t, err)
+	require.Equal(t, "model.safetensors", link)
+	// The hardlinks to the blob are preserved.
+	newBlobInfo, err := os.Stat(filepath.Join(newRootDir, "blobs", "sha256", "abc"))
+	require.NoError(t, err)
+	newFileInfo, err := os.Stat(filepath.Join(newModelDir, "model.safetensors"))
+	require.NoError(t, err)
+	require.True(t, os.SameFile(newBlobInfo, newFileInfo))
+	// The csi.sock is re-created by the driver on startup.
+	require.DirExists(t, filepath.Join(newRootDir, "volumes", "csi-dynamic", "csi"))
+	require.NoFileExists(t, filepath.Join(newRootDir, "volumes", "csi-dynamic", "csi", "csi.sock"))
+	// The old root dir is kept.
+	require.FileExists(t, filepath.Join(oldRootDir, "volumes", "pvc-static", "model", "model.safetensors"))
+
+	// The new volumes dir is not empty anymore.
+	_, err = MigrateRootDir(context.Background(), svc.cfg, newRootDir)
+	require.ErrorContains(t, err, "dir is not empty")
+}
+
+func TestMigrateRootDir_CleanupOnFailure(t *testing.T) {
+	svc, _ := newNodeService(t)
+	newRootDir := filepath.Join(t.TempDir(), "new")
+	cfg := svc.cfg.Get()
+
+	modelDir := cfg.GetModelDir("pvc-static")
+	require.NoError(t, os.MkdirAll(modelDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(modelDir, "model.safetensors"), []byte("weights"), 0644))
+	blobPath := filepath.Join(cfg.GetBlobsDir(), "sha256", "abc")
+	require.NoError(t, os.MkdirAll(filepath.Dir(blobPath), 0755))
+	require.NoError(t, os.Link(filepath.Join(modelDir, "model.safetensors"), blobPath))
+
+	patchVerifyDir := gomonkey.ApplyFunc(verifyDir, func(src, dst string) error {
+		return errors.New("checksum mismatched")
+	})
+	defer patchVerifyDir.Reset()
+
+	_, err := MigrateRootDir(context.Background(), svc.cfg, newRootDir)
+	require.ErrorContains(t, err, "checksum mismatched")
+	require.NoDirExists(t, filepath.Join(newRootDir, "volumes"))
+	require.NoDirExists(t, filepath.Join(newRootDir, "blobs"))
+	require.FileExists(t, filepath.Join(modelDir, "model.safetensors"))
+}
+
+func TestMigrateRootDir_InvalidNewRootDir(t *testing.T) {
+	svc, oldRootDir := newNodeService(t)
+
+	_, err := MigrateRootDir(context.Background(), svc.cfg, "")
+	require.Error(t, err)
+	_, err = MigrateRootDir(context.Background(), svc.cfg, oldRootDir+"/")
+	require.ErrorContains(t, err, "same as the old one")
+	_, err = MigrateRootDir(context.Background(), svc.cfg, filepath.Join(oldRootDir, "new"))
+	require.ErrorContains(t, err, "must not be nested")
+}
+
+func TestVerifyDir_Mismatched(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "file"), []byte("data"), 0644))
+	require.NoError(t, utils.CopyDir(src, dst))
+	require.NoError(t, verifyDir(src, dst))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dst, "file"), []byte("corrupted"), 0644))
+	require.ErrorContains(t, verifyDir(src, dst), "checksum mismatched")
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,15 +11,40 @@ import (
 	"github.com/modelpack/modctl/pkg/backend"
 	modctlConfig "github.com/modelpack/modctl/pkg/config"
 	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/status"
 	"github.com/modelpack/model-csi-driver/pkg/utils"
 	"github.com/pkg/errors"
 )
 
+const (
+	ModelTypeImage       = "image"
+	ModelTypeHuggingFace = "huggingface"
+	ModelTypeS3          = "s3"
+	ModelTypeArchive     = "archive"
+)
+
+// isImageModelType checks if the model is a model image (OCI artifact), the
+// empty type is treated as image for backward compatibility.
+func isImageModelType(modelType string) bool {
+	return modelType == "" || modelType == ModelTypeImage
+}
+
+func isSupportedModelType(modelType string) bool {
+	switch {
+	case isImageModelType(modelType):
+		return true
+	case modelType == ModelTypeHuggingFace, modelType == ModelTypeS3, modelType == ModelTypeArchive:
+		return true
+	}
+	return false
+}
+
 type ModelArtifact struct {
 	Reference string
 
 	b         backend.Backend
 	plainHTTP bool
+	insecure  bool
 
 	mutex    sync.Mutex
 	artifact *backend.InspectedModelArtifact
@@ -57,11 +83,12 @@ func matchFilePatterns(filename string, patterns []string) (matched bool, exclud
 	return
 }
 
-func NewModelArtifact(b backend.Backend, reference string, plainHTTP bool) *ModelArtifact {
+func NewModelArtifact(b backend.Backend, reference string, plainHTTP, insecure bool) *ModelArtifact {
 	return &ModelArtifact{
 		Reference: reference,
 		b:         b,
 		plainHTTP: plainHTTP,
+		insecure:  insecure,
 	}
 }
 
@@ -84,7 +111,7 @@ func (m *ModelArtifact) inspect(ctx context.Context) error {
 		var err error
 		result, err = m.b.Inspect(ctx, m.Reference, &modctlConfig.Inspect{
 			Remote:    true,
-			Insecure:  true,
+			Insecure:  m.insecure,
 			PlainHTTP: m.plainHTTP,
 		})
 		return err
@@ -101,7 +128,7 @@ func (m *ModelArtifact) inspect(ctx context.Context) error {
 	return nil
 }
 
-func (m *ModelArtifact) getLayers(ctx context.Context, excludeWeights bool, excludeFilePatterns []string) (
+func (m *ModelArtifact) getLayers(ctx context.Context, include func(layer backend.InspectedModelArtifactLayer) bool) (
 	[]backend.InspectedModelArtifactLayer, int, error,
 ) {
 	if err := m.inspect(ctx); err != nil {
@@ -111,41 +138,87 @@ func (m *ModelArtifact) getLayers(ctx context.Context, excludeWeights bool, excl
 	layers := []backend.InspectedModelArtifactLayer{}
 	for idx := range m.artifact.Layers {
 		layer := m.artifact.Layers[idx]
-
-		// If no filtering is requested, include all layers without further checks.
-		if !excludeWeights && len(excludeFilePatterns) == 0 {
+		if include(layer) {
 			layers = append(layers, layer)
-			continue
 		}
+	}
 
-		if layer.Filepath == "" {
-			logger.Logger().WithContext(ctx).Warnf(
-				"layer %s has no file path, skip", layer.Digest,
-			)
-			continue
-		}
+	return layers, len(m.artifact.Layers), nil
+}
 
-		filename := filepath.Base(layer.Filepath)
+// includeLayer checks if the layer (model file) should be pulled according to
+// exclude_model_weights and exclude_file_patterns.
+func includeLayer(ctx context.Context, layer backend.InspectedModelArtifactLayer, excludeWeights bool, excludeFilePatterns []string) bool {
+	// If no filtering is requested, include all layers without further checks.
+	if !excludeWeights && len(excludeFilePatterns) == 0 {
+		return true
+	}
 
-		// exclude_file_patterns takes precedence over exclude_model_weights.
-		if matched, excluded := matchFilePatterns(filename, excludeFilePatterns); matched {
-			if !excluded {
-				layers = append(layers, layer)
-			}
-			continue
+	if layer.Filepath == "" {
+		logger.Logger().WithContext(ctx).Warnf(
+			"layer %s has no file path, skip", layer.Digest,
+		)
+		return false
+	}
+
+	filename := filepath.Base(layer.Filepath)
+
+	// exclude_file_patterns takes precedence over exclude_model_weights.
+	if matched, excluded := matchFilePatterns(filename, excludeFilePatterns); matched {
+		return !excluded
+	}
+
+	// Fallback: apply weight-based exclusion.
+	return !excludeWeights || !isWeightLayer(layer)
+}
+
+// includeLayerFunc returns the includeLayer of the filters, which records the
+// excluded files into the hook if it's not nil.
+func includeLayerFunc(ctx context.Context, hook *status.Hook, excludeWeights bool, excludeFilePatterns []string) func(layer backend.InspectedModelArtifactLayer) bool {
+	return func(layer backend.InspectedModelArtifactLayer) bool {
+		if includeLayer(ctx, layer, excludeWeights, excludeFilePatterns) {
+			return true
 		}
+		if hook != nil && layer.Filepath != "" {
+			hook.AddExcludedFile(layer.Filepath)
+		}
+		return false
+	}
+}
 
-		// Fallback: apply weight-based exclusion.
-		if !excludeWeights || !isWeightLayer(layer) {
-			layers = append(layers, layer)
+// validateFilePatterns checks the exclude_file_patterns, which are matched
+// against the file names, so that the malformed pattern is rejected instead
+// of never matching.
+func validateFilePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		glob := strings.TrimPrefix(strings.TrimSpace(pattern), "!")
+		if glob == "" {
+			return errors.Errorf("empty file pattern: %q", pattern)
+		}
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return errors.Wrapf(err, "invalid file pattern: %s", pattern)
 		}
 	}
+	return nil
+}
 
-	return layers, len(m.artifact.Layers), nil
+// withIncludeFilePatterns prepends the patterns excluding all the files
+// except the ones matching the include patterns to the exclude patterns, as
+// the last matching pattern wins, the exclude patterns still apply to the
+// included files.
+func withIncludeFilePatterns(includeFilePatterns, excludeFilePatterns []string) []string {
+	if len(includeFilePatterns) == 0 {
+		return excludeFilePatterns
+	}
+	patterns := []string{"*"}
+	for _, pattern := range includeFilePatterns {
+		patterns = append(patterns, "!"+strings.TrimSpace(pattern))
+	}
+	return append(patterns, excludeFilePatterns...)
 }
 
 func (m *ModelArtifact) GetSize(ctx context.Context, excludeWeights bool, excludeFilePatterns []string) (int64, error) {
-	layers, _, err := m.getLayers(ctx, excludeWeights, excludeFilePatterns)
+	layers, _, err := m.getLayers(ctx, includeLayerFunc(ctx, nil, excludeWeights, excludeFilePatterns))
 	if err != nil {
 		return 0, errors.Wrapf(err, "get layers for model: %s", m.Reference)
 	}
@@ -165,7 +238,7 @@ func (m *ModelArtifact) GetSize(ctx context.Context, excludeWeights bool, exclud
 }
 
 func (m *ModelArtifact) GetPatterns(ctx context.Context, excludeWeights bool, excludeFilePatterns []string) ([]string, int, error) {
-	layers, total, err := m.getLayers(ctx, excludeWeights, excludeFilePatterns)
+	layers, total, err := m.getLayers(ctx, includeLayerFunc(ctx, nil, excludeWeights, excludeFilePatterns))
 	if err != nil {
 		return nil, 0, errors.Wrapf(err, "get layers for model: %s", m.Reference)
 	}
@@ -177,3 +250,57 @@ func (m *ModelArtifact) GetPatterns(ctx context.Context, excludeWeights bool, ex
 
 	return paths, total, nil
 }
+
+// ModelInspection is the manifest-level info of the model inspected without
+// pulling it, e.g. for the pre-flight capacity checks.
+type ModelInspection struct {
+	Reference string `json:"reference"`
+	// The size of the model files, the layers of the same digest are counted
+	// once as they're pulled once.
+	TotalSize int64 `json:"total_size"`
+	// The size of the model weights in the total size, see isWeightLayer.
+	WeightsSize int64           `json:"weights_size"`
+	LayerCount  int             `json:"layer_count"`
+	Files       []InspectedFile `json:"files"`
+}
+
+// InspectedFile is a model file of the inspected model.
+type InspectedFile struct {
+	Path    string `json:"path"`
+	Digest  string `json:"digest"`
+	Size    int64  `json:"size"`
+	Weights bool   `json:"weights,omitempty"`
+}
+
+// Inspect returns the manifest-level info of the model.
+func (m *ModelArtifact) Inspect(ctx context.Context) (*ModelInspection, error) {
+	if err := m.inspect(ctx); err != nil {
+		return nil, err
+	}
+
+	inspection := &ModelInspection{
+		Reference:  m.Reference,
+		LayerCount: len(m.artifact.Layers),
+		Files:      []InspectedFile{},
+	}
+	digestMap := make(map[string]bool)
+	for _, layer := range m.artifact.Layers {
+		weights := isWeightLayer(layer)
+		inspection.Files = append(inspection.Files, InspectedFile{
+			Path:    layer.Filepath,
+			Digest:  layer.Digest,
+			Size:    layer.Size,
+			Weights: weights,
+		})
+		if digestMap[layer.Digest] {
+			continue
+		}
+		digestMap[layer.Digest] = true
+		inspection.TotalSize += layer.Size
+		if weights {
+			inspection.WeightsSize += layer.Size
+		}
+	}
+
+	return inspection, nil
+}
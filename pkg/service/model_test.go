@@ -9,6 +9,7 @@ import (
 	"github.com/agiledragon/gomonkey/v2"
 	"github.com/modelpack/modctl/pkg/backend"
 	modctlConfig "github.com/modelpack/modctl/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
 	modelspec "github.com/modelpack/model-spec/specs-go/v1"
 	"github.com/stretchr/testify/require"
 )
@@ -48,7 +49,7 @@ func TestModelArtifact(t *testing.T) {
 		})
 	defer patch.Reset()
 
-	modelArtifact := NewModelArtifact(b, "test/model:latest", true)
+	modelArtifact := NewModelArtifact(b, "test/model:latest", true, true)
 
 	size, err := modelArtifact.GetSize(ctx, false, nil)
 	require.NoError(t, err)
@@ -146,3 +147,70 @@ func TestMatchFilePatterns(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateFilePatterns(t *testing.T) {
+	require.NoError(t, validateFilePatterns(nil))
+	require.NoError(t, validateFilePatterns([]string{"*.md", "!README.md", "tokenizer*", "[a-z]*.json"}))
+	require.Error(t, validateFilePatterns([]string{""}))
+	require.Error(t, validateFilePatterns([]string{"!"}))
+	require.Error(t, validateFilePatterns([]string{"[a-z.json"}))
+}
+
+func TestIncludeLayerFunc(t *testing.T) {
+	ctx := context.Background()
+	hook := status.NewHook(ctx)
+	include := includeLayerFunc(ctx, hook, true, []string{"*.md", "!README.md"})
+	require.True(t, include(backend.InspectedModelArtifactLayer{Filepath: "config.json"}))
+	require.True(t, include(backend.InspectedModelArtifactLayer{Filepath: "README.md"}))
+	require.False(t, include(backend.InspectedModelArtifactLayer{Filepath: "docs/usage.md"}))
+	require.False(t, include(backend.InspectedModelArtifactLayer{Filepath: "model.safetensors"}))
+	require.Equal(t, []string{"docs/usage.md", "model.safetensors"}, hook.GetExcludedFiles())
+}
+
+func TestWithIncludeFilePatterns(t *testing.T) {
+	require.Equal(t, []string{"*.md"}, withIncludeFilePatterns(nil, []string{"*.md"}))
+
+	patterns := withIncludeFilePatterns([]string{"tokenizer*", "config.json"}, []string{"tokenizer.md"})
+	require.Equal(t, []string{"*", "!tokenizer*", "!config.json", "tokenizer.md"}, patterns)
+
+	ctx := context.Background()
+	for file, included := range map[string]bool{
+		"config.json":            true,
+		"tokenizer.json":         true,
+		"tokenizer_config.json":  true,
+		"tokenizer.md":           false,
+		"model.safetensors":      false,
+		"generation_config.json": false,
+	} {
+		require.Equal(t, included, includeLayer(ctx, backend.InspectedModelArtifactLayer{Filepath: file}, false, patterns), file)
+	}
+}
+
+func TestModelArtifact_Inspect(t *testing.T) {
+	b, err := backend.New(filepath.Join(t.TempDir(), "modctl"))
+	require.NoError(t, err)
+	patch := gomonkey.ApplyMethod(b, "Inspect",
+		func(backend.Backend, context.Context, string, *modctlConfig.Inspect) (interface{}, error) {
+			return &backend.InspectedModelArtifact{
+				Layers: []backend.InspectedModelArtifactLayer{
+					{Digest: "sha256:layer1", Size: 3 * 1024 * 1024, Filepath: "foo.safetensors"},
+					{Digest: "sha256:layer2", Size: 2 * 1024 * 1024, Filepath: "README.md"},
+					{Digest: "sha256:layer1", Size: 3 * 1024 * 1024, Filepath: "bar.safetensors"},
+				},
+			}, nil
+		})
+	defer patch.Reset()
+
+	inspection, err := NewModelArtifact(b, "test/model:latest", true, true).Inspect(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "test/model:latest", inspection.Reference)
+	require.Equal(t, 3, inspection.LayerCount)
+	// The layers of the same digest are counted once.
+	require.Equal(t, int64(5*1024*1024), inspection.TotalSize)
+	require.Equal(t, int64(3*1024*1024), inspection.WeightsSize)
+	require.Equal(t, []InspectedFile{
+		{Path: "foo.safetensors", Digest: "sha256:layer1", Size: 3 * 1024 * 1024, Weights: true},
+		{Path: "README.md", Digest: "sha256:layer2", Size: 2 * 1024 * 1024},
+		{Path: "bar.safetensors", Digest: "sha256:layer1", Size: 3 * 1024 * 1024, Weights: true},
+	}, inspection.Files)
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The interval to reap the dynamic mounts not refreshed within the TTL.
+var MountTTLScanInterval = time.Minute
+
+// The lease of the dynamic mount created with the TTL, stored beside the
+// status of the mount.
+const mountLeaseFile = "lease.json"
+
+type mountLease struct {
+	TTLSeconds  uint      `json:"ttl_seconds"`
+	RefreshedAt time.Time `json:"refreshed_at"`
+}
+
+func (lease *mountLease) expiresAt() time.Time {
+	return lease.RefreshedAt.Add(time.Duration(lease.TTLSeconds) * time.Second)
+}
+
+func (s *Service) mountLeasePath(volumeName, mountID string) string {
+	return filepath.Join(s.cfg.Get().GetMountIDDirForDynamic(volumeName, mountID), mountLeaseFile)
+}
+
+// getMountLease returns the lease of the dynamic mount, nil if the mount is
+// created without the TTL.
+func (s *Service) getMountLease(volumeName, mountID string) (*mountLease, error) {
+	leasePath := s.mountLeasePath(volumeName, mountID)
+	data, err := os.ReadFile(leasePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "read mount lease: %s", leasePath)
+	}
+	var lease mountLease
+	if err := json.Unmarshal(data, &lease); err != nil {
+		return nil, errors.Wrapf(err, "unmarshal mount lease: %s", leasePath)
+	}
+	return &lease, nil
+}
+
+// setMountTTL sets the TTL of the dynamic mount from now on, the TTL of 0
+// removes the lease so that the mount is kept until deleted.
+func (s *Service) setMountTTL(volumeName, mountID string, ttlSeconds uint) error {
+	leasePath := s.mountLeasePath(volumeName, mountID)
+	if ttlSeconds == 0 {
+		if err := os.Remove(leasePath); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "remove mount lease: %s", leasePath)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(mountLease{TTLSeconds: ttlSeconds, RefreshedAt: time.Now()})
+	if err != nil {
+		return errors.Wrap(err, "marshal mount lease")
+	}
+	tmpPath := leasePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return errors.Wrapf(err, "write mount lease: %s", tmpPath)
+	}
+	if err := os.Rename(tmpPath, leasePath); err != nil {
+		return errors.Wrapf(err, "rename mount lease: %s", leasePath)
+	}
+
+	return nil
+}
+
+// RefreshMount extends the lease of the dynamic mount by its TTL from now on,
+// it's a no-op for the mount created without the TTL.
+func (s *Service) RefreshMount(ctx context.Context, volumeName, mountID string) error {
+	ctx = logger.NewContext(ctx, "RefreshMount", volumeName, mountID)
+	if _, err := s.getDynamicVolume(ctx, volumeName, mountID); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return status.Errorf(codes.NotFound, "volume_name %s with mount_id %s is not found", volumeName, mountID)
+		}
+		return status.Error(codes.Internal, errors.Wrap(err, "get mount status").Error())
+	}
+
+	lease, err := s.getMountLease(volumeName, mountID)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if lease == nil {
+		return nil
+	}
+	if err := s.setMountTTL(volumeName, mountID, lease.TTLSeconds); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+// mountExpiresAt returns the time the dynamic mount expires at, nil if the
+// mount is created without the TTL.
+func (s *Service) mountExpiresAt(ctx context.Context, volumeName, mountID string) *time.Time {
+	lease, err := s.getMountLease(volumeName, mountID)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("failed to get mount lease")
+		return nil
+	}
+	if lease == nil {
+		return nil
+	}
+	expiresAt := lease.expiresAt()
+	return &expiresAt
+}
+
+func (s *Service) reapExpiredMountsLoop() {
+	for {
+		time.Sleep(MountTTLScanInterval)
+		if err := s.reapExpiredMounts(context.Background()); err != nil {
+			logger.Logger().WithError(err).Warnf("reap expired mounts failed")
+		}
+	}
+}
+
+// reapExpiredMounts deletes the dynamic mounts not refreshed within the TTL,
+// e.g. the mounts leaked by the notebooks.
+func (s *Service) reapExpiredMounts(ctx context.Context) error {
+	volumesDir := s.cfg.Get().GetVolumesDir()
+	volumeDirs, err := os.ReadDir(volumesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "read volume dirs from %s", volumesDir)
+	}
+
+	for _, volumeDir := range volumeDirs {
+		volumeName := volumeDir.Name()
+		if !volumeDir.IsDir() || !isDynamicVolume(volumeName) {
+			continue
+		}
+		modelsDir := s.cfg.Get().GetModelsDirForDynamic(volumeName)
+		mountDirs, err := os.ReadDir(modelsDir)
+		if err != nil {
+			continue
+		}
+		for _, mountDir := range mountDirs {
+			mountID := mountDir.Name()
+			if !isVolumeDirEntry(modelsDir, mountDir) {
+				continue
+			}
+			ctx := logger.NewContext(ctx, "ReapExpiredMount", volumeName, mountID)
+			lease, err := s.getMountLease(volumeName, mountID)
+			if err != nil {
+				logger.WithContext(ctx).WithError(err).Warnf("failed to get mount lease")
+				continue
+			}
+			if lease == nil || time.Now().Before(lease.expiresAt()) {
+				continue
+			}
+
+			logger.WithContext(ctx).Infof("deleting mount not refreshed since %s, ttl: %ds", lease.RefreshedAt.Format(time.RFC3339), lease.TTLSeconds)
+			if _, err := s.DeleteVolume(ctx, &csi.DeleteVolumeRequest{
+				VolumeId: fmt.Sprintf("%s/%s", volumeName, mountID),
+			}); err != nil {
+				logger.WithContext(ctx).WithError(err).Errorf("failed to delete expired mount")
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+func newDynamicMount(t *testing.T, svc *Service, volumeName, mountID string) {
+	mountDir := svc.cfg.Get().GetMountIDDirForDynamic(volumeName, mountID)
+	require.NoError(t, os.MkdirAll(filepath.Join(mountDir, "model"), 0755))
+	_, err := svc.sm.Set(filepath.Join(mountDir, "status.json"), status.Status{
+		VolumeName: volumeName,
+		MountID:    mountID,
+		Reference:  "test/model:latest",
+		State:      status.StatePullSucceeded,
+	})
+	require.NoError(t, err)
+}
+
+func expireMountLease(t *testing.T, svc *Service, volumeName, mountID string) {
+	lease, err := svc.getMountLease(volumeName, mountID)
+	require.NoError(t, err)
+	lease.RefreshedAt = time.Now().Add(-time.Duration(lease.TTLSeconds+1) * time.Second)
+	data, err := json.Marshal(lease)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(svc.mountLeasePath(volumeName, mountID), data, 0644))
+}
+
+func TestMountTTL(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	newDynamicMount(t, svc, "csi-vol", "m1")
+	newDynamicMount(t, svc, "csi-vol", "m2")
+	newDynamicMount(t, svc, "csi-vol", "m3")
+
+	// The mount created without the TTL never expires.
+	mount, err := svc.GetDynamicVolume(ctx, "csi-vol", "m1")
+	require.NoError(t, err)
+	require.Nil(t, mount.ExpiresAt)
+	require.NoError(t, svc.RefreshMount(ctx, "csi-vol", "m1"))
+
+	require.NoError(t, svc.setMountTTL("csi-vol", "m2", 60))
+	require.NoError(t, svc.setMountTTL("csi-vol", "m3", 60))
+	mount, err = svc.GetDynamicVolume(ctx, "csi-vol", "m2")
+	require.NoError(t, err)
+	require.NotNil(t, mount.ExpiresAt)
+	require.WithinDuration(t, time.Now().Add(time.Minute), *mount.ExpiresAt, 5*time.Second)
+
+	// The refreshed mount is kept, the other expired mount is deleted.
+	expireMountLease(t, svc, "csi-vol", "m2")
+	expireMountLease(t, svc, "csi-vol", "m3")
+	require.NoError(t, svc.RefreshMount(ctx, "csi-vol", "m2"))
+	require.NoError(t, svc.reapExpiredMounts(ctx))
+	require.DirExists(t, svc.cfg.Get().GetMountIDDirForDynamic("csi-vol", "m1"))
+	require.DirExists(t, svc.cfg.Get().GetMountIDDirForDynamic("csi-vol", "m2"))
+	require.NoDirExists(t, svc.cfg.Get().GetMountIDDirForDynamic("csi-vol", "m3"))
+
+	// The TTL of 0 removes the lease.
+	require.NoError(t, svc.setMountTTL("csi-vol", "m2", 0))
+	statuses, err := svc.ListDynamicVolumes(ctx, "csi-vol")
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	for _, st := range statuses {
+		require.Nil(t, st.ExpiresAt)
+	}
+
+	err = svc.RefreshMount(ctx, "csi-vol", "m3")
+	require.Equal(t, codes.NotFound, grpcStatus.Code(err))
+}
+
+func TestDynamicServerHandler_RefreshVolume(t *testing.T) {
+	h, svc := newHandler(t)
+	newDynamicMount(t, svc, "csi-vol", "m1")
+
+	c, rec := newHandlerContextWithParam(t, http.MethodPost, "/", "",
+		[]string{"volume_name", "mount_id"}, []string{"csi-vol", "m1"})
+	require.NoError(t, h.RefreshVolume(c))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	c, rec = newHandlerContextWithParam(t, http.MethodPost, "/", "",
+		[]string{"volume_name", "mount_id"}, []string{"csi-vol", "m2"})
+	_ = h.RefreshVolume(c)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	c, rec = newHandlerContextWithParam(t, http.MethodPost, "/", "",
+		[]string{"volume_name", "mount_id"}, []string{"bad/vol", "m1"})
+	_ = h.RefreshVolume(c)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// The max number of the labels of a dynamic mount.
+const maxMountLabels = 64
+
+// MountResource is the dynamic mount of the v2 API, which has the
+// timestamps, the size and the error of the pull besides the state of the
+// mount, while the v1 API returns the status of the mount as is.
+type MountResource struct {
+	VolumeName string `json:"volume_name"`
+	MountID    string `json:"mount_id"`
+	Reference  string `json:"reference"`
+	// The digest the reference is pinned to, empty until it's resolved.
+	Digest   string            `json:"digest,omitempty"`
+	Platform string            `json:"platform,omitempty"`
+	State    string            `json:"state"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	// The time the mount is created at, and the time the last pull of the
+	// model is started and done at.
+	CreatedAt      *time.Time `json:"created_at,omitempty"`
+	PullStartedAt  *time.Time `json:"pull_started_at,omitempty"`
+	PullFinishedAt *time.Time `json:"pull_finished_at,omitempty"`
+	// The time the mount created with the TTL expires at unless refreshed.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// The disk usage of the model files once the model is pulled.
+	SizeBytes int64                     `json:"size_bytes"`
+	Progress  MountProgress             `json:"progress"`
+	Adapters  []modelStatus.Adapter     `json:"adapters,omitempty"`
+	History   []modelStatus.HistoryItem `json:"history,omitempty"`
+	// The error of the failed pull.
+	Error *ErrorResponse `json:"error,omitempty"`
+}
+
+// MountProgress is the progress of the pull of the mount in bytes.
+type MountProgress struct {
+	TotalBytes      int64   `json:"total_bytes"`
+	DownloadedBytes int64   `json:"downloaded_bytes"`
+	Percentage      float64 `json:"percentage"`
+	// The estimated seconds to download the rest of the bytes.
+	RemainingSeconds int64 `json:"remaining_seconds,omitempty"`
+	// The 1-based position in the node-wide pull queue while the state is
+	// PULL_QUEUED.
+	QueuePosition int `json:"queue_position,omitempty"`
+}
+
+func newMountResource(mount *modelStatus.Status) MountResource {
+	resource := MountResource{
+		VolumeName:     mount.VolumeName,
+		MountID:        mount.MountID,
+		Reference:      mount.Reference,
+		Digest:         mount.Digest,
+		Platform:       mount.Platform,
+		State:          mount.State,
+		Labels:         mount.Labels,
+		CreatedAt:      mount.CreatedAt,
+		PullStartedAt:  mount.PullStartedAt,
+		PullFinishedAt: mount.PullFinishedAt,
+		ExpiresAt:      mount.ExpiresAt,
+		SizeBytes:      mount.SizeInBytes,
+		Progress: MountProgress{
+			TotalBytes:       mount.Progress.TotalBytes,
+			DownloadedBytes:  mount.Progress.DownloadedBytes,
+			Percentage:       mount.Progress.Percentage(),
+			RemainingSeconds: mount.Progress.RemainingSeconds,
+			QueuePosition:    mount.Progress.QueuePosition,
+		},
+		Adapters: mount.Adapters,
+		History:  mount.History,
+	}
+	if mount.Error != nil {
+		resource.Error = &ErrorResponse{
+			Code:    pullErrorCode(mount.Error.Reason),
+			Message: mount.Error.Message,
+			Reason:  mount.Error.Reason,
+		}
+	}
+	return resource
+}
+
+// pullErrorCode returns the error code of the reason of the failed pull, see
+// pullErrorReason.
+func pullErrorCode(reason string) string {
+	switch reason {
+	case ERR_CODE_INSUFFICIENT_DISK_QUOTA, ERR_CODE_SIGNATURE_VERIFICATION_FAILED, ERR_CODE_ALREADY_EXISTS:
+		return reason
+	}
+	return ERR_CODE_INTERNAL
+}
+
+// validateLabels checks the labels of the dynamic mount are valid as the
+// labels of Kubernetes, e.g. to be copied to the pods.
+func validateLabels(labels map[string]string) error {
+	if len(labels) > maxMountLabels {
+		return errors.Errorf("labels must have at most %d items", maxMountLabels)
+	}
+	for key, value := range labels {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return errors.Errorf("invalid label key %q: %s", key, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return errors.Errorf("invalid label value %q: %s", value, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// labelsParameter returns the labels of the dynamic mount, nil if they're
+// not set so that the labels set before are kept.
+func (s *Service) labelsParameter(parameters map[string]string) (map[string]string, error) {
+	key := s.cfg.Get().ParameterKeyLabels()
+	param := strings.TrimSpace(parameters[key])
+	if param == "" {
+		return nil, nil
+	}
+	labels := map[string]string{}
+	if err := json.Unmarshal([]byte(param), &labels); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", key, err)
+	}
+	if err := validateLabels(labels); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", key, err)
+	}
+	return labels, nil
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateLabels(t *testing.T) {
+	require.NoError(t, validateLabels(nil))
+	require.NoError(t, validateLabels(map[string]string{"tenant": "team-a", "example.com/env": ""}))
+	require.Error(t, validateLabels(map[string]string{"": "team-a"}))
+	require.Error(t, validateLabels(map[string]string{"tenant": "team a"}))
+	require.Error(t, validateLabels(map[string]string{"tenant": strings.Repeat("a", 64)}))
+}
+
+func TestNewMountResource(t *testing.T) {
+	now := time.Now()
+	resource := newMountResource(&modelStatus.Status{
+		VolumeName:     "csi-vol",
+		MountID:        "m1",
+		Reference:      "test/model:latest",
+		State:          modelStatus.StatePullTimeout,
+		Labels:         map[string]string{"tenant": "team-a"},
+		CreatedAt:      &now,
+		PullStartedAt:  &now,
+		PullFinishedAt: &now,
+		SizeInBytes:    100,
+		Progress:       modelStatus.Progress{TotalBytes: 200, DownloadedBytes: 50},
+		Error:          &modelStatus.ErrorDetail{Reason: modelStatus.StatePullTimeout, Message: "pull model timeout"},
+	})
+	require.Equal(t, "m1", resource.MountID)
+	require.Equal(t, map[string]string{"tenant": "team-a"}, resource.Labels)
+	require.Equal(t, &now, resource.PullFinishedAt)
+	require.Equal(t, int64(100), resource.SizeBytes)
+	require.Equal(t, float64(25), resource.Progress.Percentage)
+	require.Equal(t, &ErrorResponse{
+		Code:    ERR_CODE_INTERNAL,
+		Message: "pull model timeout",
+		Reason:  modelStatus.StatePullTimeout,
+	}, resource.Error)
+
+	resource = newMountResource(&modelStatus.Status{
+		Error: &modelStatus.ErrorDetail{Reason: ERR_CODE_INSUFFICIENT_DISK_QUOTA, Message: "no space left on device"},
+	})
+	require.Equal(t, ERR_CODE_INSUFFICIENT_DISK_QUOTA, resource.Error.Code)
+}
+
+func TestDynamicServerHandler_MountV2(t *testing.T) {
+	h, svc := newHandler(t)
+	createdAt := time.Now().Add(-time.Minute)
+	for _, mountID := range []string{"m1", "m2"} {
+		newDynamicMount(t, svc, "csi-vol", mountID)
+	}
+	statusPath := filepath.Join(svc.cfg.Get().GetMountIDDirForDynamic("csi-vol", "m1"), "status.json")
+	mount, err := svc.sm.Get(statusPath)
+	require.NoError(t, err)
+	mount.Labels = map[string]string{"tenant": "team-a"}
+	mount.CreatedAt = &createdAt
+	mount.SizeInBytes = 100
+	_, err = svc.sm.Set(statusPath, *mount)
+	require.NoError(t, err)
+
+	c, rec := newHandlerContextWithParam(t, http.MethodGet, "/", "",
+		[]string{"volume_name", "mount_id"}, []string{"csi-vol", "m1"})
+	require.NoError(t, h.GetMountV2(c))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resource MountResource
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resource))
+	require.Equal(t, "m1", resource.MountID)
+	require.Equal(t, modelStatus.StatePullSucceeded, resource.State)
+	require.Equal(t, map[string]string{"tenant": "team-a"}, resource.Labels)
+	require.WithinDuration(t, createdAt, *resource.CreatedAt, time.Second)
+	require.Equal(t, int64(100), resource.SizeBytes)
+	require.Nil(t, resource.Error)
+
+	c, rec = newHandlerContextWithParam(t, http.MethodGet, "/?labels=tenant%3Dteam-a", "",
+		[]string{"volume_name"}, []string{"csi-vol"})
+	require.NoError(t, h.ListMountsV2(c))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "1", rec.Header().Get("X-Total-Count"))
+	var resources []MountResource
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resources))
+	require.Len(t, resources, 1)
+	require.Equal(t, "m1", resources[0].MountID)
+
+	c, rec = newHandlerContextWithParam(t, http.MethodGet, "/", "",
+		[]string{"volume_name", "mount_id"}, []string{"csi-vol", "m3"})
+	_ = h.GetMountV2(c)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	c, rec = newHandlerContextWithParam(t, http.MethodPost, "/",
+		`{"mount_id":"m3","reference":"test/model:latest","labels":{"tenant":"team a"}}`,
+		[]string{"volume_name"}, []string{"csi-vol"})
+	_ = h.CreateMountV2(c)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestDynamicServerHandler_GetMountV2_AsyncFailed(t *testing.T) {
+	h, svc := newHandler(t)
+	mount := modelStatus.Status{VolumeName: "csi-vol", MountID: "m1", Reference: "test/model:latest", State: modelStatus.StatePullRunning}
+	svc.createMountAsync(context.Background(), mount, func(ctx context.Context) error {
+		return errors.New("pull model failed")
+	})
+	require.Eventually(t, func() bool {
+		failed, _ := svc.failedAsyncMount("csi-vol", "m1")
+		return failed != nil
+	}, time.Second, 10*time.Millisecond)
+
+	c, rec := newHandlerContextWithParam(t, http.MethodGet, "/", "",
+		[]string{"volume_name", "mount_id"}, []string{"csi-vol", "m1"})
+	require.NoError(t, h.GetMountV2(c))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resource MountResource
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resource))
+	require.Equal(t, modelStatus.StatePullFailed, resource.State)
+	require.Equal(t, "test/model:latest", resource.Reference)
+	require.Equal(t, ERR_CODE_INTERNAL, resource.Error.Code)
+	require.Equal(t, "pull model failed", resource.Error.Message)
+}
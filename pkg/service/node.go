@@ -2,7 +2,8 @@ package service
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -21,22 +22,221 @@ import (
 	"github.com/modelpack/model-csi-driver/pkg/metrics"
 	"github.com/modelpack/model-csi-driver/pkg/mounter"
 	"github.com/modelpack/model-csi-driver/pkg/tracing"
+	"github.com/modelpack/model-csi-driver/pkg/utils"
 )
 
+// validateStagingPath ensures the staging path is located under the kubelet
+// plugins directory, the same as validateTargetPath.
+func (s *Service) validateStagingPath(stagingPath string) error {
+	if s.cfg.Get().KubeletRootDir == "" {
+		return nil
+	}
+
+	pluginsDir := s.cfg.Get().GetKubeletPluginsDir()
+	under, err := utils.IsPathUnder(pluginsDir, stagingPath)
+	if err != nil {
+		return status.Error(codes.Internal, errors.Wrap(err, "check staging path").Error())
+	}
+	if !under {
+		return status.Errorf(codes.InvalidArgument, "invalid parameter: stagingTargetPath %s is not under %s", stagingPath, pluginsDir)
+	}
+
+	return nil
+}
+
+func (s *Service) nodeStageVolume(
+	ctx context.Context,
+	req *csi.NodeStageVolumeRequest) (
+	*csi.NodeStageVolumeResponse, error) {
+	volumeID := req.GetVolumeId()
+	stagingPath := req.GetStagingTargetPath()
+
+	if volumeID == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing required parameter: volumeId")
+	}
+	if stagingPath == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing required parameter: stagingTargetPath")
+	}
+	if err := s.validateStagingPath(stagingPath); err != nil {
+		return nil, err
+	}
+
+	// Only the model of the static volume is staged, the dynamic volume is
+	// published by its own mounts.
+	if !isStaticVolume(volumeID) {
+		return &csi.NodeStageVolumeResponse{}, nil
+	}
+
+	parentSpan := trace.SpanFromContext(ctx)
+	parentSpan.SetAttributes(attribute.String("volume_name", volumeID))
+	parentSpan.SetAttributes(attribute.String("staging_path", stagingPath))
+
+	unlock, err := s.lockVolume(ctx, volumeID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	defer unlock()
+
+	isMounted, err := mounter.IsMounted(ctx, stagingPath)
+	if err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "check if staging path is mounted").Error())
+	}
+	if isMounted {
+		logger.WithContext(ctx).Info("staging path is already mounted")
+		return &csi.NodeStageVolumeResponse{}, nil
+	}
+
+	if err := mounter.EnsureMountPoint(ctx, stagingPath); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "ensure mount point").Error())
+	}
+
+	return s.nodeStageVolumeStatic(ctx, volumeID, stagingPath, req.GetSecrets())
+}
+
 func (s *Service) NodeStageVolume(
 	ctx context.Context,
 	req *csi.NodeStageVolumeRequest) (
 	*csi.NodeStageVolumeResponse, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "NodeStageVolume")
+	defer span.End()
+
+	volumeID := req.GetVolumeId()
+	stagingPath := req.GetStagingTargetPath()
+
+	ctx = logger.NewContext(ctx, "NodeStageVolume", volumeID, stagingPath)
+
+	logger.WithContext(ctx).Infof("staging node volume")
+	start := time.Now()
+	resp, err := s.nodeStageVolume(ctx, req)
+	metrics.NodeOpObserve("stage_volume", start, err)
+	if err != nil {
+		span.SetStatus(otelCodes.Error, "failed to stage node volume")
+		span.RecordError(err)
+		logger.WithContext(ctx).Errorf("failed to stage node volume: %v", err)
+		return nil, err
+	}
+	logger.WithContext(ctx).Infof("staged node volume")
+
+	return resp, nil
+}
+
+func (s *Service) nodeUnstageVolume(
+	ctx context.Context,
+	req *csi.NodeUnstageVolumeRequest) (
+	*csi.NodeUnstageVolumeResponse, error) {
+	volumeID := req.GetVolumeId()
+	stagingPath := req.GetStagingTargetPath()
+
+	if volumeID == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing required parameter: volumeId")
+	}
+	if stagingPath == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing required parameter: stagingTargetPath")
+	}
+	if !isStaticVolume(volumeID) {
+		return &csi.NodeUnstageVolumeResponse{}, nil
+	}
+	if err := s.validateStagingPath(stagingPath); err != nil {
+		if _, statErr := os.Lstat(stagingPath); os.IsNotExist(statErr) {
+			logger.WithContext(ctx).Infof("staging path not found, skip unstage: %s", stagingPath)
+			return &csi.NodeUnstageVolumeResponse{}, nil
+		}
+		return nil, err
+	}
+
+	parentSpan := trace.SpanFromContext(ctx)
+	parentSpan.SetAttributes(attribute.String("volume_name", volumeID))
+	parentSpan.SetAttributes(attribute.String("staging_path", stagingPath))
+
+	unlock, err := s.lockVolume(ctx, volumeID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	defer unlock()
+
+	isMounted, err := mounter.IsMounted(ctx, stagingPath)
+	if err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "check if staging path is mounted").Error())
+	}
+	if !isMounted {
+		logger.WithContext(ctx).Warnf("staging path is already umounted")
+	}
 
-	return &csi.NodeStageVolumeResponse{}, nil
+	return s.nodeUnstageVolumeStatic(ctx, volumeID, stagingPath, isMounted)
 }
 
 func (s *Service) NodeUnstageVolume(
 	ctx context.Context,
 	req *csi.NodeUnstageVolumeRequest) (
 	*csi.NodeUnstageVolumeResponse, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "NodeUnstageVolume")
+	defer span.End()
+
+	volumeID := req.GetVolumeId()
+	stagingPath := req.GetStagingTargetPath()
+
+	ctx = logger.NewContext(ctx, "NodeUnstageVolume", volumeID, stagingPath)
+
+	logger.WithContext(ctx).Infof("unstaging node volume")
+	start := time.Now()
+	resp, err := s.nodeUnstageVolume(ctx, req)
+	metrics.NodeOpObserve("unstage_volume", start, err)
+	if err != nil {
+		span.SetStatus(otelCodes.Error, "failed to unstage node volume")
+		span.RecordError(err)
+		logger.WithContext(ctx).Errorf("failed to unstage node volume: %v", err)
+		return nil, err
+	}
+	logger.WithContext(ctx).Infof("unstaged node volume")
 
-	return &csi.NodeUnstageVolumeResponse{}, nil
+	return resp, nil
+}
+
+// publishAccess is the access to the volume requested by NodePublishVolume.
+type publishAccess struct {
+	// The pod mounts the volume read-only, or the access mode only reads it.
+	readOnly bool
+	// The access mode writes the volume.
+	writer bool
+	// The owner of the model files set by the mount flags and the volume
+	// mount group, or nil.
+	owner *mountOwner
+}
+
+func newPublishAccess(ctx context.Context, req *csi.NodePublishVolumeRequest) (publishAccess, error) {
+	mount := req.GetVolumeCapability().GetMount()
+	readOnly, owner, err := parseMountFlags(ctx, mount.GetMountFlags())
+	if err != nil {
+		return publishAccess{}, err
+	}
+	owner, err = parseMountGroup(mount.GetVolumeMountGroup(), owner)
+	if err != nil {
+		return publishAccess{}, err
+	}
+	access := publishAccess{readOnly: req.GetReadonly() || readOnly, owner: owner}
+	switch req.GetVolumeCapability().GetAccessMode().GetMode() {
+	case csi.VolumeCapability_AccessMode_UNKNOWN:
+	case csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY, csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY:
+		access.readOnly = true
+	default:
+		access.writer = true
+	}
+	return access, nil
+}
+
+// isPublishReadOnly returns true if the volume is published read-only to the
+// target path: the volume which must be read-only (see isReadOnlyMount) is
+// published read-only unless the access mode writes it, which is rejected,
+// and the other volumes are published read-only if the pod requests it, or
+// by default with read_only_mount unless the access mode writes them.
+func (s *Service) isPublishReadOnly(access publishAccess, volumeReadOnly bool) (bool, error) {
+	if isReadOnlyMount(s.cfg.Get(), volumeReadOnly) {
+		if access.writer && !access.readOnly {
+			return false, status.Error(codes.InvalidArgument, "volume is read-only but the access mode writes it")
+		}
+		return true, nil
+	}
+	return access.readOnly || (s.cfg.Get().Features.ReadOnlyMount && !access.writer), nil
 }
 
 func isStaticVolume(volumeID string) bool {
@@ -47,6 +247,35 @@ func isDynamicVolume(volumeID string) bool {
 	return strings.HasPrefix(volumeID, "csi-")
 }
 
+// validateTargetPath ensures the target path is located under the kubelet pods
+// directory, so that the caller can't mount over or unmount arbitrary host paths.
+func (s *Service) validateTargetPath(targetPath string) error {
+	if s.cfg.Get().KubeletRootDir == "" {
+		return nil
+	}
+
+	podsDir := s.cfg.Get().GetKubeletPodsDir()
+	under, err := utils.IsPathUnder(podsDir, targetPath)
+	if err != nil {
+		return status.Error(codes.Internal, errors.Wrap(err, "check target path").Error())
+	}
+	if !under {
+		return status.Errorf(codes.InvalidArgument, "invalid parameter: targetPath %s is not under %s", targetPath, podsDir)
+	}
+
+	return nil
+}
+
+// lockVolume serializes the publish and unpublish of the volume, including
+// the cleanup of the orphaned volume, it returns the function to unlock.
+func (s *Service) lockVolume(ctx context.Context, volumeID string) (func(), error) {
+	key := fmt.Sprintf("publish-%s", volumeID)
+	if err := s.worker.kmutex.Lock(ctx, key); err != nil {
+		return nil, errors.Wrapf(err, "lock volume: %s", volumeID)
+	}
+	return func() { s.worker.kmutex.Unlock(key) }, nil
+}
+
 func (s *Service) nodePublishVolume(
 	ctx context.Context,
 	req *csi.NodePublishVolumeRequest) (
@@ -69,11 +298,21 @@ func (s *Service) nodePublishVolume(
 		return nil, isStaticVolume, status.Error(codes.InvalidArgument, "missing required parameter: targetPath")
 	}
 
+	if err := s.validateTargetPath(targetPath); err != nil {
+		return nil, isStaticVolume, err
+	}
+
 	parentSpan := trace.SpanFromContext(ctx)
 	parentSpan.SetAttributes(attribute.String("volume_name", volumeID))
 	parentSpan.SetAttributes(attribute.String("target_path", targetPath))
 	parentSpan.SetAttributes(attribute.Bool("static_volume", isStaticVolume))
 
+	unlock, err := s.lockVolume(ctx, volumeID)
+	if err != nil {
+		return nil, isStaticVolume, status.Error(codes.Internal, err.Error())
+	}
+	defer unlock()
+
 	isMounted, err := mounter.IsMounted(ctx, targetPath)
 	if err != nil {
 		return nil, isStaticVolume, status.Error(codes.Internal, errors.Wrap(err, "check if target path is mounted").Error())
@@ -88,35 +327,100 @@ func (s *Service) nodePublishVolume(
 		return nil, isStaticVolume, status.Error(codes.Internal, errors.Wrap(err, "ensure mount point").Error())
 	}
 
+	access, err := newPublishAccess(ctx, req)
+	if err != nil {
+		return nil, isStaticVolume, err
+	}
 	if isStaticVolume {
-		resp, err := s.nodePublishVolumeStatic(ctx, volumeID, targetPath)
+		// The staging path is given only if STAGE_UNSTAGE_VOLUME is advertised.
+		if stagingPath := req.GetStagingTargetPath(); stagingPath != "" {
+			resp, err := s.nodePublishVolumeStaged(ctx, volumeID, stagingPath, targetPath, access)
+			return resp, isStaticVolume, err
+		}
+		resp, err := s.nodePublishVolumeStatic(ctx, volumeID, targetPath, req.GetSecrets(), access)
 		return resp, isStaticVolume, err
 	}
 
 	staticInlineModelReference := volumeAttributes[s.cfg.Get().ParameterKeyReference()]
-	excludeModelWeightsParam := volumeAttributes[s.cfg.Get().ParameterKeyExcludeModelWeights()]
+	excludeModelWeightsKey, excludeModelWeightsParam := s.excludeModelWeightsParameter(volumeAttributes)
 	if staticInlineModelReference != "" {
 		excludeModelWeights := false
 		if excludeModelWeightsParam != "" {
 			var err error
 			excludeModelWeights, err = strconv.ParseBool(excludeModelWeightsParam)
 			if err != nil {
-				return nil, isStaticVolume, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", s.cfg.Get().ParameterKeyExcludeModelWeights(), err)
+				return nil, isStaticVolume, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", excludeModelWeightsKey, err)
 			}
 		}
-		excludeFilePatterns := []string{}
-		if excludeFilePatternsParam := strings.TrimSpace(volumeAttributes[s.cfg.Get().ParameterKeyExcludeFilePatterns()]); excludeFilePatternsParam != "" {
-			if err := json.Unmarshal([]byte(excludeFilePatternsParam), &excludeFilePatterns); err != nil {
-				return nil, isStaticVolume, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", s.cfg.Get().ParameterKeyExcludeFilePatterns(), err)
+		excludeFilePatterns, err := s.filePatternsParameter(volumeAttributes)
+		if err != nil {
+			return nil, isStaticVolume, err
+		}
+
+		priority := 0
+		if priorityParam := strings.TrimSpace(volumeAttributes[s.cfg.Get().ParameterKeyPriority()]); priorityParam != "" {
+			var err error
+			priority, err = strconv.Atoi(priorityParam)
+			if err != nil {
+				return nil, isStaticVolume, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", s.cfg.Get().ParameterKeyPriority(), err)
 			}
 		}
+		backgroundWeights := false
+		if backgroundWeightsParam := strings.TrimSpace(volumeAttributes[s.cfg.Get().ParameterKeyBackgroundWeights()]); backgroundWeightsParam != "" {
+			var err error
+			backgroundWeights, err = strconv.ParseBool(backgroundWeightsParam)
+			if err != nil {
+				return nil, isStaticVolume, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", s.cfg.Get().ParameterKeyBackgroundWeights(), err)
+			}
+		}
+		pullTimeout := time.Duration(0)
+		if pullTimeoutParam := strings.TrimSpace(volumeAttributes[s.cfg.Get().ParameterKeyPullTimeoutInSeconds()]); pullTimeoutParam != "" {
+			seconds, err := strconv.ParseUint(pullTimeoutParam, 10, 32)
+			if err != nil {
+				return nil, isStaticVolume, status.Errorf(codes.InvalidArgument, "invalid parameter:%s: %v", s.cfg.Get().ParameterKeyPullTimeoutInSeconds(), err)
+			}
+			pullTimeout = time.Duration(seconds) * time.Second
+		}
+
+		modelType := strings.TrimSpace(volumeAttributes[s.cfg.Get().ParameterKeyType()])
+		if !isSupportedModelType(modelType) {
+			return nil, isStaticVolume, status.Errorf(codes.InvalidArgument, "unsupported model type: %s", modelType)
+		}
+		if err := s.admitModel(ctx, modelType, staticInlineModelReference, volumeID, ""); err != nil {
+			return nil, isStaticVolume, err
+		}
+		adapters, err := s.adaptersParameter(ctx, volumeAttributes, modelType, volumeID, "")
+		if err != nil {
+			return nil, isStaticVolume, err
+		}
 
 		logger.WithContext(ctx).Infof("publishing static inline volume: %s", staticInlineModelReference)
-		resp, err := s.nodePublishVolumeStaticInlineVolume(ctx, volumeID, targetPath, staticInlineModelReference, excludeModelWeights, excludeFilePatterns)
+		resp, err := s.nodePublishVolumeStaticInlineVolume(ctx, volumeID, targetPath, staticInlineModelReference, access, PullOptions{
+			Type:                modelType,
+			ExcludeModelWeights: excludeModelWeights,
+			ExcludeFilePatterns: excludeFilePatterns,
+			Secrets:             req.GetSecrets(),
+			Digest:              strings.TrimSpace(volumeAttributes[s.cfg.Get().ParameterKeyDigest()]),
+			Priority:            priority,
+			BackgroundWeights:   backgroundWeights,
+			Adapters:            adapters,
+			Timeout:             pullTimeout,
+		})
 		return resp, isStaticVolume, err
 	}
 
-	resp, err := s.nodePublishVolumeDynamicForRootMount(ctx, volumeID, targetPath)
+	if access.owner != nil {
+		if !access.owner.groupOnly {
+			return nil, isStaticVolume, status.Error(codes.InvalidArgument, "mount flags uid, gid and mode are not supported by the dynamic volume")
+		}
+		logger.WithContext(ctx).Warnf("volume mount group is ignored by the dynamic volume")
+	}
+	pod := podInfo{
+		namespace: volumeAttributes[volumeContextPodNamespace],
+		name:      volumeAttributes[volumeContextPodName],
+		uid:       volumeAttributes[volumeContextPodUID],
+	}
+	resp, err := s.nodePublishVolumeDynamicForRootMount(ctx, volumeID, targetPath, pod, access)
 	return resp, isStaticVolume, err
 }
 
@@ -168,11 +472,27 @@ func (s *Service) nodeUnpublishVolume(
 		return nil, isStaticVolume, status.Error(codes.InvalidArgument, "missing required parameter: targetPath")
 	}
 
+	if err := s.validateTargetPath(targetPath); err != nil {
+		// Nothing can be mounted on a nonexistent target path, e.g. the one
+		// left under a previous kubelet root dir, so unpublish it as a no-op.
+		if _, statErr := os.Lstat(targetPath); os.IsNotExist(statErr) {
+			logger.WithContext(ctx).Infof("target path not found, skip unpublish: %s", targetPath)
+			return &csi.NodeUnpublishVolumeResponse{}, isStaticVolume, nil
+		}
+		return nil, isStaticVolume, err
+	}
+
 	parentSpan := trace.SpanFromContext(ctx)
 	parentSpan.SetAttributes(attribute.String("volume_name", volumeID))
 	parentSpan.SetAttributes(attribute.String("target_path", targetPath))
 	parentSpan.SetAttributes(attribute.Bool("static_volume", isStaticVolume))
 
+	unlock, err := s.lockVolume(ctx, volumeID)
+	if err != nil {
+		return nil, isStaticVolume, status.Error(codes.Internal, err.Error())
+	}
+	defer unlock()
+
 	isMounted, err := mounter.IsMounted(ctx, targetPath)
 	if err != nil {
 		return nil, isStaticVolume, status.Error(codes.Internal, errors.Wrap(err, "check if target path is mounted").Error())
@@ -251,18 +571,19 @@ func (s *Service) NodeGetCapabilities(
 	req *csi.NodeGetCapabilitiesRequest) (
 	*csi.NodeGetCapabilitiesResponse, error) {
 
-	nscap := &csi.NodeServiceCapability{
-		Type: &csi.NodeServiceCapability_Rpc{
-			Rpc: &csi.NodeServiceCapability_RPC{
-				Type: csi.NodeServiceCapability_RPC_UNKNOWN,
+	var caps []*csi.NodeServiceCapability
+	for _, capability := range s.nodeCapabilities() {
+		caps = append(caps, &csi.NodeServiceCapability{
+			Type: &csi.NodeServiceCapability_Rpc{
+				Rpc: &csi.NodeServiceCapability_RPC{
+					Type: capability,
+				},
 			},
-		},
+		})
 	}
 
 	return &csi.NodeGetCapabilitiesResponse{
-		Capabilities: []*csi.NodeServiceCapability{
-			nscap,
-		},
+		Capabilities: caps,
 	}, nil
 }
 
@@ -15,30 +15,55 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-func (s *Service) nodePublishVolumeDynamicForRootMount(ctx context.Context, volumeName, targetPath string) (*csi.NodePublishVolumeResponse, error) {
+func (s *Service) nodePublishVolumeDynamicForRootMount(ctx context.Context, volumeName, targetPath string, pod podInfo, access publishAccess) (*csi.NodePublishVolumeResponse, error) {
+	readOnly, err := s.isPublishReadOnly(access, false)
+	if err != nil {
+		return nil, err
+	}
+
 	sourceModelsDir := s.cfg.Get().GetModelsDirForDynamic(volumeName)
 	if err := os.MkdirAll(sourceModelsDir, 0755); err != nil {
 		return nil, status.Error(codes.Internal, errors.Wrap(err, "create source models dir").Error())
 	}
 
+	if err := ensureDynamicServerToken(s.cfg.Get(), volumeName); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "mint dynamic csi server token").Error())
+	}
+
 	sourceCSISockPath := s.cfg.Get().GetCSISockPathForDynamic(volumeName)
-	_, err := s.DynamicServerManager.CreateServer(ctx, sourceCSISockPath)
+	_, err = s.DynamicServerManager.CreateServer(ctx, sourceCSISockPath)
 	if err != nil {
 		return nil, status.Error(codes.Internal, errors.Wrap(err, "create dynamic csi server").Error())
 	}
 
 	sourceVolumeDir := s.cfg.Get().GetVolumeDirForDynamic(volumeName)
 	statusPath := filepath.Join(sourceVolumeDir, "status.json")
-	_, err = s.sm.Set(statusPath, modelStatus.Status{
-		VolumeName: volumeName,
-	})
+	// The volume may be published to the target paths of multiple pods.
+	volumeStatus, err := s.sm.Get(statusPath)
 	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, status.Error(codes.Internal, errors.Wrap(err, "get volume status").Error())
+		}
+		volumeStatus = &modelStatus.Status{VolumeName: volumeName}
+	}
+	volumeStatus.AddTarget(modelStatus.Target{
+		Path:         targetPath,
+		PodNamespace: pod.namespace,
+		PodName:      pod.name,
+		PodUID:       pod.uid,
+		ReadOnly:     readOnly,
+	})
+	if _, err = s.sm.Set(statusPath, *volumeStatus); err != nil {
 		return nil, status.Error(codes.Internal, errors.Wrap(err, "create volume status").Error())
 	}
 
+	builder := mounter.NewBuilder()
+	if readOnly {
+		builder = builder.ReadOnly()
+	}
 	if err = mounter.Mount(
 		ctx,
-		mounter.NewBuilder().
+		builder.
 			RBind().
 			From(sourceVolumeDir).
 			MountPoint(targetPath),
@@ -50,6 +75,25 @@ func (s *Service) nodePublishVolumeDynamicForRootMount(ctx context.Context, volu
 }
 
 func (s *Service) nodeUnPublishVolumeDynamic(ctx context.Context, volumeName, targetPath string, isMounted bool) (*csi.NodeUnpublishVolumeResponse, error) {
+	if isMounted {
+		if err := mounter.UMount(ctx, targetPath, true); err != nil {
+			return nil, status.Error(codes.Internal, errors.Wrapf(err, "unmount target path").Error())
+		}
+	}
+
+	// Keep the volume if it's still published to the target paths of other pods.
+	statusPath := filepath.Join(s.cfg.Get().GetVolumeDirForDynamic(volumeName), "status.json")
+	if volumeStatus, err := s.sm.Get(statusPath); err == nil {
+		volumeStatus.RemoveTarget(targetPath)
+		if len(volumeStatus.Targets) > 0 {
+			if _, err := s.sm.Set(statusPath, *volumeStatus); err != nil {
+				return nil, status.Error(codes.Internal, errors.Wrap(err, "set volume status").Error())
+			}
+			logger.WithContext(ctx).Infof("volume is still published to %d target paths", len(volumeStatus.Targets))
+			return &csi.NodeUnpublishVolumeResponse{}, nil
+		}
+	}
+
 	sourceCSIDir := s.cfg.Get().GetCSISockDirForDynamic(volumeName)
 	volumeDir := s.cfg.Get().GetVolumeDirForDynamic(volumeName)
 
@@ -71,16 +115,11 @@ func (s *Service) nodeUnPublishVolumeDynamic(ctx context.Context, volumeName, ta
 		}
 	}
 
-	if isMounted {
-		if err := mounter.UMount(ctx, targetPath, true); err != nil {
-			return nil, status.Error(codes.Internal, errors.Wrapf(err, "unmount target path").Error())
-		}
-	}
-
 	sourceVolumeDir := s.cfg.Get().GetVolumeDirForDynamic(volumeName)
-	if err := os.RemoveAll(sourceVolumeDir); err != nil {
+	if err := removeVolumeDir(sourceVolumeDir); err != nil {
 		return nil, status.Error(codes.Internal, errors.Wrapf(err, "remove dynamic volume dir").Error())
 	}
+	s.sm.Invalidate(sourceVolumeDir)
 
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
@@ -8,10 +8,13 @@ import (
 
 	"github.com/agiledragon/gomonkey/v2"
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/modelpack/model-csi-driver/pkg/config"
 	"github.com/modelpack/model-csi-driver/pkg/mounter"
 	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
 )
 
 // nodeUnPublishVolumeStatic with isMounted=false and non-existent status.json
@@ -49,7 +52,8 @@ func TestNodePublishVolumeStatic_MockMount(t *testing.T) {
 	ctx := context.Background()
 	volumeName := "pvc-mount-test"
 	volumeDir := filepath.Join(tmpDir, "volumes", volumeName)
-	require.NoError(t, os.MkdirAll(volumeDir, 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(volumeDir, "model"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(volumeDir, "model", "weights.bin"), []byte("weights"), 0644))
 	statusPath := filepath.Join(volumeDir, "status.json")
 	_, err := svc.sm.Set(statusPath, modelStatus.Status{
 		VolumeName: volumeName,
@@ -64,18 +68,95 @@ func TestNodePublishVolumeStatic_MockMount(t *testing.T) {
 	})
 	defer patch.Reset()
 
-	resp, err := svc.nodePublishVolumeStatic(ctx, volumeName, t.TempDir())
+	resp, err := svc.nodePublishVolumeStatic(ctx, volumeName, t.TempDir(), nil, publishAccess{})
 	require.NoError(t, err)
 	require.NotNil(t, resp)
 }
 
+// nodePublishVolumeStatic bind mounts read-only if the volume is modified to read-only
+func TestNodePublishVolumeStatic_ReadOnly(t *testing.T) {
+	svc, tmpDir := newNodeService(t)
+	ctx := context.Background()
+	volumeName := "pvc-readonly-test"
+	statusPath := filepath.Join(tmpDir, "volumes", volumeName, "status.json")
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "volumes", volumeName, "model"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "volumes", volumeName, "model", "weights.bin"), []byte("weights"), 0644))
+	_, err := svc.sm.Set(statusPath, modelStatus.Status{
+		VolumeName: volumeName,
+		Reference:  "test/model:latest",
+		State:      modelStatus.StatePullSucceeded,
+		ReadOnly:   true,
+	})
+	require.NoError(t, err)
+
+	var mountCmd string
+	patch := gomonkey.ApplyFunc(mounter.Mount, func(ctx context.Context, builder mounter.Builder) error {
+		cmd, err := builder.Build()
+		mountCmd = cmd.String()
+		return err
+	})
+	defer patch.Reset()
+
+	_, err = svc.nodePublishVolumeStatic(ctx, volumeName, t.TempDir(), nil, publishAccess{})
+	require.NoError(t, err)
+	require.Contains(t, mountCmd, "-o|ro|--bind")
+}
+
+// nodePublishVolumeStatic pulls the model wiped from the volume dir again
+func TestNodePublishVolumeStatic_RestoreMissingModel(t *testing.T) {
+	svc, _ := newNodeService(t)
+	puller := &blockingPuller{started: make(chan struct{}), release: make(chan struct{})}
+	close(puller.release)
+	svc.worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *modelStatus.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return puller
+	}
+	ctx := context.Background()
+	cfg := svc.cfg.Get()
+	cfg.PullConfig.WriteChecksumFile = true
+	volumeName := "pvc-restore-test"
+	modelDir := cfg.GetModelDir(volumeName)
+	require.NoError(t, svc.worker.PullModel(ctx, true, volumeName, "", "test/model:latest", modelDir, PullOptions{}))
+	require.Equal(t, int32(1), puller.calls.Load())
+
+	patch := gomonkey.ApplyFunc(mounter.Mount, func(ctx context.Context, builder mounter.Builder) error {
+		return nil
+	})
+	defer patch.Reset()
+
+	// The complete model is mounted as is.
+	firstTarget := t.TempDir()
+	_, err := svc.nodePublishVolumeStatic(ctx, volumeName, firstTarget, nil, publishAccess{})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), puller.calls.Load())
+
+	// The model missing the files is pulled again, the targets are kept.
+	require.NoError(t, os.Remove(filepath.Join(modelDir, "weights.bin")))
+	secondTarget := t.TempDir()
+	_, err = svc.nodePublishVolumeStatic(ctx, volumeName, secondTarget, nil, publishAccess{})
+	require.NoError(t, err)
+	require.Equal(t, int32(2), puller.calls.Load())
+	require.FileExists(t, filepath.Join(modelDir, "weights.bin"))
+	volumeStatus, err := svc.sm.Get(filepath.Join(cfg.GetVolumeDir(volumeName), "status.json"))
+	require.NoError(t, err)
+	require.Equal(t, modelStatus.StateMounted, volumeStatus.State)
+	require.ElementsMatch(t, []modelStatus.Target{{Path: firstTarget}, {Path: secondTarget}}, volumeStatus.Targets)
+
+	// So is the model wiped with the model dir.
+	require.NoError(t, os.RemoveAll(modelDir))
+	_, err = svc.nodePublishVolumeStatic(ctx, volumeName, t.TempDir(), nil, publishAccess{})
+	require.NoError(t, err)
+	require.Equal(t, int32(3), puller.calls.Load())
+	require.FileExists(t, filepath.Join(modelDir, "weights.bin"))
+}
+
 // Test NodePublishVolume via full path with mocked IsMounted
 func TestNodePublishVolume_WithMockedMounter(t *testing.T) {
 	svc, tmpDir := newNodeService(t)
 	ctx := context.Background()
 	volumeName := "pvc-publish-test"
 	volumeDir := filepath.Join(tmpDir, "volumes", volumeName)
-	require.NoError(t, os.MkdirAll(volumeDir, 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(volumeDir, "model"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(volumeDir, "model", "weights.bin"), []byte("weights"), 0644))
 	statusPath := filepath.Join(volumeDir, "status.json")
 	_, err := svc.sm.Set(statusPath, modelStatus.Status{
 		VolumeName: volumeName,
@@ -194,6 +275,105 @@ func TestNodePublishVolumeDynamicForRootMount_ServerError(t *testing.T) {
 	})
 	defer patchMount.Reset()
 
-	_, _ = svc.nodePublishVolumeDynamicForRootMount(ctx, volumeName, targetPath)
+	_, _ = svc.nodePublishVolumeDynamicForRootMount(ctx, volumeName, targetPath, podInfo{}, publishAccess{})
 	// Just ensure no panic; the function will attempt dirs/server creation
 }
+
+// The static volume is staged once and bind mounted from the staging path to
+// each target path.
+func TestNodeStageVolume_Static(t *testing.T) {
+	svc, tmpDir := newNodeService(t)
+	ctx := context.Background()
+	volumeName := "pvc-stage-test"
+	volumeDir := filepath.Join(tmpDir, "volumes", volumeName)
+	require.NoError(t, os.MkdirAll(filepath.Join(volumeDir, "model"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(volumeDir, "model", "weights.bin"), []byte("weights"), 0644))
+	statusPath := filepath.Join(volumeDir, "status.json")
+	_, err := svc.sm.Set(statusPath, modelStatus.Status{
+		VolumeName: volumeName,
+		Reference:  "test/model:latest",
+		State:      modelStatus.StatePullSucceeded,
+	})
+	require.NoError(t, err)
+
+	mounted := map[string]bool{}
+	mounts := []string{}
+	patchIsMounted := gomonkey.ApplyFunc(mounter.IsMounted, func(ctx context.Context, mountPoint string) (bool, error) {
+		return mounted[mountPoint], nil
+	})
+	defer patchIsMounted.Reset()
+	patchMount := gomonkey.ApplyFunc(mounter.Mount, func(ctx context.Context, builder mounter.Builder) error {
+		cmd, err := builder.Build()
+		if err != nil {
+			return err
+		}
+		mounts = append(mounts, cmd.String())
+		return nil
+	})
+	defer patchMount.Reset()
+	patchUMount := gomonkey.ApplyFunc(mounter.UMount, func(ctx context.Context, mountPoint string, lazy bool) error {
+		delete(mounted, mountPoint)
+		return nil
+	})
+	defer patchUMount.Reset()
+
+	stagingPath := filepath.Join(t.TempDir(), "globalmount")
+	targetPath := filepath.Join(t.TempDir(), "mount")
+
+	// The volume not staged isn't published from the staging path.
+	_, err = svc.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+		VolumeId:          volumeName,
+		StagingTargetPath: stagingPath,
+		TargetPath:        targetPath,
+	})
+	require.Equal(t, codes.FailedPrecondition, grpcStatus.Code(err))
+
+	_, err = svc.NodeStageVolume(ctx, &csi.NodeStageVolumeRequest{
+		VolumeId:          volumeName,
+		StagingTargetPath: stagingPath,
+	})
+	require.NoError(t, err)
+	require.Len(t, mounts, 1)
+	require.Contains(t, mounts[0], filepath.Join(volumeDir, "model")+"|"+stagingPath)
+	mounted[stagingPath] = true
+	volumeStatus, err := svc.sm.Get(statusPath)
+	require.NoError(t, err)
+	require.Equal(t, stagingPath, volumeStatus.StagingPath)
+
+	// The staging is idempotent.
+	_, err = svc.NodeStageVolume(ctx, &csi.NodeStageVolumeRequest{
+		VolumeId:          volumeName,
+		StagingTargetPath: stagingPath,
+	})
+	require.NoError(t, err)
+	require.Len(t, mounts, 1)
+
+	_, err = svc.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+		VolumeId:          volumeName,
+		StagingTargetPath: stagingPath,
+		TargetPath:        targetPath,
+	})
+	require.NoError(t, err)
+	require.Len(t, mounts, 2)
+	require.Contains(t, mounts[1], stagingPath+"|"+targetPath)
+	volumeStatus, err = svc.sm.Get(statusPath)
+	require.NoError(t, err)
+	require.Equal(t, modelStatus.StateMounted, volumeStatus.State)
+	require.Equal(t, []modelStatus.Target{{Path: targetPath}}, volumeStatus.Targets)
+
+	_, err = svc.NodeUnpublishVolume(ctx, &csi.NodeUnpublishVolumeRequest{
+		VolumeId:   volumeName,
+		TargetPath: targetPath,
+	})
+	require.NoError(t, err)
+	_, err = svc.NodeUnstageVolume(ctx, &csi.NodeUnstageVolumeRequest{
+		VolumeId:          volumeName,
+		StagingTargetPath: stagingPath,
+	})
+	require.NoError(t, err)
+	require.False(t, mounted[stagingPath])
+	volumeStatus, err = svc.sm.Get(statusPath)
+	require.NoError(t, err)
+	require.Equal(t, modelStatus.StateUmounted, volumeStatus.State)
+	require.Empty(t, volumeStatus.StagingPath)
+}
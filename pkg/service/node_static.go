@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
 	"github.com/modelpack/model-csi-driver/pkg/mounter"
 	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
 	"github.com/pkg/errors"
@@ -13,7 +14,51 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-func (s *Service) nodePublishVolumeStatic(ctx context.Context, volumeName, targetPath string) (*csi.NodePublishVolumeResponse, error) {
+func (s *Service) nodePublishVolumeStatic(ctx context.Context, volumeName, targetPath string, secrets map[string]string, access publishAccess) (*csi.NodePublishVolumeResponse, error) {
+	statusPath := filepath.Join(s.cfg.Get().GetVolumeDir(volumeName), "status.json")
+	volumeStatus, err := s.getStaticModel(ctx, volumeName, secrets)
+	if err != nil {
+		return nil, err
+	}
+	readOnly, err := s.isPublishReadOnly(access, volumeStatus.ReadOnly)
+	if err != nil {
+		return nil, err
+	}
+	sourcePath := s.cfg.Get().GetModelDir(volumeStatus.VolumeName)
+	if err := s.applyMountOwner(ctx, volumeStatus, sourcePath, targetPath, access.owner); err != nil {
+		return nil, err
+	}
+
+	builder := mounter.NewBuilder()
+	if readOnly {
+		builder = builder.ReadOnly()
+	}
+	if err = mounter.Mount(
+		ctx,
+		builder.
+			Bind().
+			From(sourcePath).
+			MountPoint(targetPath),
+	); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrapf(err, "bind mount %s to target", sourcePath).Error())
+	}
+
+	// The state is set to MOUNTED once the weights pulled in the background
+	// are ready.
+	if volumeStatus.State != modelStatus.StateWeightsPulling {
+		volumeStatus.State = modelStatus.StateMounted
+	}
+	volumeStatus.AddTarget(modelStatus.Target{Path: targetPath, ReadOnly: readOnly})
+	if _, err := s.sm.Set(statusPath, *volumeStatus); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "set volume status").Error())
+	}
+
+	return &csi.NodePublishVolumeResponse{}, nil
+}
+
+// getStaticModel returns the status of the static volume whose model is ready
+// to be mounted.
+func (s *Service) getStaticModel(ctx context.Context, volumeName string, secrets map[string]string) (*modelStatus.Status, error) {
 	statusPath := filepath.Join(s.cfg.Get().GetVolumeDir(volumeName), "status.json")
 	volumeStatus, err := s.sm.Get(statusPath)
 	if err != nil {
@@ -21,17 +66,98 @@ func (s *Service) nodePublishVolumeStatic(ctx context.Context, volumeName, targe
 	}
 	sourcePath := s.cfg.Get().GetModelDir(volumeStatus.VolumeName)
 
+	// The model may be wiped from the node after it's pulled, e.g. by the
+	// reset of the node image or a manual cleanup, it's pulled again instead
+	// of mounting the empty dir to the pod.
+	if reason := missingModelContent(sourcePath, volumeStatus); reason != "" {
+		if volumeStatus.ClonedFrom != "" {
+			return nil, status.Errorf(codes.FailedPrecondition, "model of volume %s cloned from %s is %s", volumeName, volumeStatus.ClonedFrom, reason)
+		}
+		logger.WithContext(ctx).Warnf("model of volume %s is %s, pulling it again", volumeName, reason)
+		volumeStatus, err = s.restoreStaticModel(ctx, volumeName, volumeStatus, secrets)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return volumeStatus, nil
+}
+
+// nodeStageVolumeStatic mounts the model of the static volume to the staging
+// path, which is bind mounted to the target path of each pod then, so that
+// the model is checked and mounted once for all the pods on the node.
+func (s *Service) nodeStageVolumeStatic(ctx context.Context, volumeName, stagingPath string, secrets map[string]string) (*csi.NodeStageVolumeResponse, error) {
+	statusPath := filepath.Join(s.cfg.Get().GetVolumeDir(volumeName), "status.json")
+	volumeStatus, err := s.getStaticModel(ctx, volumeName, secrets)
+	if err != nil {
+		return nil, err
+	}
+	sourcePath := s.cfg.Get().GetModelDir(volumeStatus.VolumeName)
+
+	builder := mounter.NewBuilder()
+	if isReadOnlyMount(s.cfg.Get(), volumeStatus.ReadOnly) {
+		builder = builder.ReadOnly()
+	}
 	if err = mounter.Mount(
 		ctx,
-		mounter.NewBuilder().
+		builder.
 			Bind().
 			From(sourcePath).
+			MountPoint(stagingPath),
+	); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrapf(err, "bind mount %s to staging path", sourcePath).Error())
+	}
+
+	volumeStatus.StagingPath = stagingPath
+	if _, err := s.sm.Set(statusPath, *volumeStatus); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "set volume status").Error())
+	}
+
+	return &csi.NodeStageVolumeResponse{}, nil
+}
+
+// nodePublishVolumeStaged bind mounts the staging path of the static volume
+// to the target path of the pod.
+func (s *Service) nodePublishVolumeStaged(ctx context.Context, volumeName, stagingPath, targetPath string, access publishAccess) (*csi.NodePublishVolumeResponse, error) {
+	isStaged, err := mounter.IsMounted(ctx, stagingPath)
+	if err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "check if staging path is mounted").Error())
+	}
+	if !isStaged {
+		return nil, status.Errorf(codes.FailedPrecondition, "volume %s is not staged to %s", volumeName, stagingPath)
+	}
+
+	statusPath := filepath.Join(s.cfg.Get().GetVolumeDir(volumeName), "status.json")
+	volumeStatus, err := s.sm.Get(statusPath)
+	if err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "get volume status").Error())
+	}
+	readOnly, err := s.isPublishReadOnly(access, volumeStatus.ReadOnly)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyMountOwner(ctx, volumeStatus, s.cfg.Get().GetModelDir(volumeName), targetPath, access.owner); err != nil {
+		return nil, err
+	}
+
+	builder := mounter.NewBuilder()
+	if readOnly {
+		builder = builder.ReadOnly()
+	}
+	if err = mounter.Mount(
+		ctx,
+		builder.
+			Bind().
+			From(stagingPath).
 			MountPoint(targetPath),
 	); err != nil {
-		return nil, status.Error(codes.Internal, errors.Wrapf(err, "bind mount %s to target", sourcePath).Error())
+		return nil, status.Error(codes.Internal, errors.Wrapf(err, "bind mount %s to target", stagingPath).Error())
 	}
 
-	volumeStatus.State = modelStatus.StateMounted
+	if volumeStatus.State != modelStatus.StateWeightsPulling {
+		volumeStatus.State = modelStatus.StateMounted
+	}
+	volumeStatus.AddTarget(modelStatus.Target{Path: targetPath, ReadOnly: readOnly})
 	if _, err := s.sm.Set(statusPath, *volumeStatus); err != nil {
 		return nil, status.Error(codes.Internal, errors.Wrap(err, "set volume status").Error())
 	}
@@ -39,6 +165,93 @@ func (s *Service) nodePublishVolumeStatic(ctx context.Context, volumeName, targe
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
+func (s *Service) nodeUnstageVolumeStatic(ctx context.Context, volumeName, stagingPath string, isMounted bool) (*csi.NodeUnstageVolumeResponse, error) {
+	if isMounted {
+		if err := mounter.UMount(ctx, stagingPath, true); err != nil {
+			return nil, status.Error(codes.Internal, errors.Wrapf(err, "unmount staging path").Error())
+		}
+	}
+
+	statusPath := filepath.Join(s.cfg.Get().GetVolumeDir(volumeName), "status.json")
+	volumeStatus, err := s.sm.Get(statusPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &csi.NodeUnstageVolumeResponse{}, nil
+		}
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "get volume status").Error())
+	}
+
+	if volumeStatus.StagingPath == stagingPath {
+		volumeStatus.StagingPath = ""
+		if _, err := s.sm.Set(statusPath, *volumeStatus); err != nil {
+			return nil, status.Error(codes.Internal, errors.Wrap(err, "set volume status").Error())
+		}
+	}
+
+	return &csi.NodeUnstageVolumeResponse{}, nil
+}
+
+// missingModelContent returns why the model pulled for the volume is missing
+// from the model dir, or empty if it's complete as far as it can be told
+// without hashing the files.
+func missingModelContent(modelDir string, volumeStatus *modelStatus.Status) string {
+	switch volumeStatus.State {
+	case modelStatus.StatePullSucceeded, modelStatus.StateMounted, modelStatus.StateUmounted:
+	default:
+		// The weights pulled in the background are still arriving.
+		return ""
+	}
+	if _, err := os.Stat(getPullStatePath(modelDir)); err == nil {
+		return "incomplete"
+	}
+	entries, err := os.ReadDir(modelDir)
+	if err != nil {
+		return "missing"
+	}
+	if len(entries) == 0 && volumeStatus.SizeInBytes > 0 {
+		return "missing"
+	}
+	checksums, err := readChecksumFile(filepath.Join(modelDir, checksumFile))
+	if err != nil {
+		return ""
+	}
+	for path := range checksums {
+		if _, err := os.Lstat(filepath.Join(modelDir, filepath.FromSlash(path))); err != nil {
+			return "incomplete"
+		}
+	}
+	return ""
+}
+
+// restoreStaticModel pulls the missing model of the static volume again with
+// the options it was pulled with, the targets of the volume are kept.
+func (s *Service) restoreStaticModel(ctx context.Context, volumeName string, volumeStatus *modelStatus.Status, secrets map[string]string) (*modelStatus.Status, error) {
+	statusPath := filepath.Join(s.cfg.Get().GetVolumeDir(volumeName), "status.json")
+	opts := statusPullOptions(volumeStatus)
+	opts.Secrets = secrets
+	// The other volumes of the model may be wiped as well.
+	opts.NoReuse = true
+	if err := s.worker.PullModel(ctx, true, volumeName, "", volumeStatus.Reference, s.cfg.Get().GetModelDir(volumeName), opts); err != nil {
+		// The status removed by the failed pull is written back, so that the
+		// retried publish pulls the model again.
+		if _, err2 := s.sm.Set(statusPath, *volumeStatus); err2 != nil {
+			logger.WithContext(ctx).WithError(err2).Warnf("failed to restore volume status: %s", volumeName)
+		}
+		return nil, status.Error(codes.Unavailable, errors.Wrap(err, "pull missing model").Error())
+	}
+	newStatus, err := s.sm.Get(statusPath)
+	if err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "get volume status").Error())
+	}
+	for _, target := range volumeStatus.Targets {
+		newStatus.AddTarget(target)
+	}
+	newStatus.StagingPath = volumeStatus.StagingPath
+	logger.WithContext(ctx).Infof("restored missing model of volume %s: %s", volumeName, volumeStatus.Reference)
+
+	return newStatus, nil
+}
+
 func (s *Service) nodeUnPublishVolumeStatic(ctx context.Context, volumeName, targetPath string, isMounted bool) (*csi.NodeUnpublishVolumeResponse, error) {
 	if isMounted {
 		if err := mounter.UMount(ctx, targetPath, true); err != nil {
@@ -55,7 +268,10 @@ func (s *Service) nodeUnPublishVolumeStatic(ctx context.Context, volumeName, tar
 		return nil, status.Error(codes.Internal, errors.Wrap(err, "get volume status").Error())
 	}
 
-	volumeStatus.State = modelStatus.StateUmounted
+	volumeStatus.RemoveTarget(targetPath)
+	if len(volumeStatus.Targets) == 0 && volumeStatus.State != modelStatus.StateWeightsPulling {
+		volumeStatus.State = modelStatus.StateUmounted
+	}
 	if _, err := s.sm.Set(statusPath, *volumeStatus); err != nil {
 		return nil, status.Error(codes.Internal, errors.Wrap(err, "set volume status").Error())
 	}
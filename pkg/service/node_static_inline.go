@@ -2,7 +2,6 @@ package service
 
 import (
 	"context"
-	"os"
 	"path/filepath"
 	"time"
 
@@ -15,19 +14,39 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-func (s *Service) nodePublishVolumeStaticInlineVolume(ctx context.Context, volumeName, targetPath, reference string, excludeModelWeights bool, excludeFilePatterns []string) (*csi.NodePublishVolumeResponse, error) {
+func (s *Service) nodePublishVolumeStaticInlineVolume(ctx context.Context, volumeName, targetPath, reference string, access publishAccess, opts PullOptions) (*csi.NodePublishVolumeResponse, error) {
 	modelDir := s.cfg.Get().GetModelDir(volumeName)
+	readOnly, err := s.isPublishReadOnly(access, false)
+	if err != nil {
+		return nil, err
+	}
 
 	startedAt := time.Now()
-	if err := s.worker.PullModel(ctx, true, volumeName, "", reference, modelDir, false, excludeModelWeights, excludeFilePatterns); err != nil {
+	if err := s.worker.PullModel(ctx, true, volumeName, "", reference, modelDir, opts); err != nil {
+		if errors.Is(err, ErrSignatureVerification) {
+			return nil, status.Error(codes.PermissionDenied, errors.Wrap(err, "pull model").Error())
+		}
 		return nil, status.Error(codes.Internal, errors.Wrap(err, "pull model").Error())
 	}
 	duration := time.Since(startedAt)
 	logger.WithContext(ctx).Infof("pulled model: %s %s", reference, duration)
 
+	statusPath := filepath.Join(s.cfg.Get().GetVolumeDir(volumeName), "status.json")
+	volumeStatus, err := s.sm.Get(statusPath)
+	if err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "get volume status").Error())
+	}
+	if err := s.applyMountOwner(ctx, volumeStatus, modelDir, targetPath, access.owner); err != nil {
+		return nil, err
+	}
+
+	builder := mounter.NewBuilder()
+	if readOnly {
+		builder = builder.ReadOnly()
+	}
 	if err := mounter.Mount(
 		ctx,
-		mounter.NewBuilder().
+		builder.
 			Bind().
 			From(modelDir).
 			MountPoint(targetPath),
@@ -35,15 +54,12 @@ func (s *Service) nodePublishVolumeStaticInlineVolume(ctx context.Context, volum
 		return nil, status.Error(codes.Internal, errors.Wrapf(err, "bind mount %s to target %s", modelDir, targetPath).Error())
 	}
 
-	statusPath := filepath.Join(s.cfg.Get().GetVolumeDir(volumeName), "status.json")
-	volumeStatus, err := s.sm.Get(statusPath)
-	if err != nil {
-		return nil, status.Error(codes.Internal, errors.Wrap(err, "get volume status").Error())
-	}
-
 	// The field distinguishes inline and PVC based volume.
 	volumeStatus.Inline = true
-	volumeStatus.State = modelStatus.StateMounted
+	if volumeStatus.State != modelStatus.StateWeightsPulling {
+		volumeStatus.State = modelStatus.StateMounted
+	}
+	volumeStatus.AddTarget(modelStatus.Target{Path: targetPath, ReadOnly: readOnly})
 	if _, err := s.sm.Set(statusPath, *volumeStatus); err != nil {
 		return nil, status.Error(codes.Internal, errors.Wrap(err, "set volume status").Error())
 	}
@@ -59,9 +75,10 @@ func (s *Service) nodeUnPublishVolumeStaticInlineVolume(ctx context.Context, vol
 	}
 
 	sourceVolumeDir := s.cfg.Get().GetVolumeDir(volumeName)
-	if err := os.RemoveAll(sourceVolumeDir); err != nil {
+	if err := removeVolumeDir(sourceVolumeDir); err != nil {
 		return nil, status.Error(codes.Internal, errors.Wrapf(err, "remove static inline volume dir").Error())
 	}
+	s.sm.Invalidate(sourceVolumeDir)
 
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
@@ -2,10 +2,13 @@ package service
 
 import (
 	"context"
+	"path/filepath"
 	"testing"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestNodePublishVolume_EmptyVolumeID(t *testing.T) {
@@ -60,3 +63,100 @@ func TestNodeUnpublishVolume_EmptyTargetPath(t *testing.T) {
 	})
 	require.Error(t, err)
 }
+
+func TestNodePublishVolume_TargetPathOutsideKubeletDir(t *testing.T) {
+	svc, tmpDir := newNodeService(t)
+	svc.cfg.Get().KubeletRootDir = filepath.Join(tmpDir, "kubelet")
+	ctx := context.Background()
+
+	for _, targetPath := range []string{
+		"/etc",
+		filepath.Join(tmpDir, "kubelet", "plugins", "mount"),
+		filepath.Join(tmpDir, "kubelet", "pods", "..", "..", "volumes"),
+	} {
+		_, err := svc.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+			VolumeId:   "pvc-test-vol",
+			TargetPath: targetPath,
+		})
+		require.Equal(t, codes.InvalidArgument, status.Code(err), targetPath)
+
+	}
+
+	_, err := svc.NodeUnpublishVolume(ctx, &csi.NodeUnpublishVolumeRequest{
+		VolumeId:   "pvc-test-vol",
+		TargetPath: "/etc",
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// The nonexistent target path has nothing mounted, unpublish succeeds.
+	resp, err := svc.NodeUnpublishVolume(ctx, &csi.NodeUnpublishVolumeRequest{
+		VolumeId:   "pvc-test-vol",
+		TargetPath: filepath.Join(tmpDir, "kubelet", "plugins", "mount"),
+	})
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+}
+
+func TestNodeUnpublishVolume_TargetPathUnderKubeletDir(t *testing.T) {
+	svc, tmpDir := newNodeService(t)
+	svc.cfg.Get().KubeletRootDir = filepath.Join(tmpDir, "kubelet")
+	ctx := context.Background()
+
+	resp, err := svc.NodeUnpublishVolume(ctx, &csi.NodeUnpublishVolumeRequest{
+		VolumeId:   "pvc-test-vol",
+		TargetPath: filepath.Join(tmpDir, "kubelet", "pods", "uid-1", "volumes", "kubernetes.io~csi", "pvc-test-vol", "mount"),
+	})
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+}
+
+func TestIsPublishReadOnly(t *testing.T) {
+	svc, _ := newNodeService(t)
+	capability := func(mode csi.VolumeCapability_AccessMode_Mode) *csi.VolumeCapability {
+		return &csi.VolumeCapability{AccessMode: &csi.VolumeCapability_AccessMode{Mode: mode}}
+	}
+	newAccess := func(req *csi.NodePublishVolumeRequest) publishAccess {
+		t.Helper()
+		access, err := newPublishAccess(context.Background(), req)
+		require.NoError(t, err)
+		return access
+	}
+	rwo := newAccess(&csi.NodePublishVolumeRequest{VolumeCapability: capability(csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER)})
+	rox := newAccess(&csi.NodePublishVolumeRequest{VolumeCapability: capability(csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY)})
+	rwoReadOnly := newAccess(&csi.NodePublishVolumeRequest{
+		VolumeCapability: capability(csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+		Readonly:         true,
+	})
+	require.Equal(t, publishAccess{writer: true}, rwo)
+	require.Equal(t, publishAccess{readOnly: true}, rox)
+	require.Equal(t, publishAccess{}, newAccess(&csi.NodePublishVolumeRequest{}))
+
+	for _, tc := range []struct {
+		name           string
+		access         publishAccess
+		volumeReadOnly bool
+		readOnlyMount  bool
+		expected       bool
+		code           codes.Code
+	}{
+		{name: "default", access: publishAccess{}, expected: false},
+		{name: "requested", access: rox, expected: true},
+		{name: "writer", access: rwo, expected: false},
+		{name: "read-only by default", access: publishAccess{}, readOnlyMount: true, expected: true},
+		{name: "writer against the default", access: rwo, readOnlyMount: true, expected: false},
+		{name: "read-only volume", access: publishAccess{}, volumeReadOnly: true, expected: true},
+		{name: "writer of read-only volume", access: rwo, volumeReadOnly: true, code: codes.InvalidArgument},
+		{name: "read-only writer of read-only volume", access: rwoReadOnly, volumeReadOnly: true, expected: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			svc.cfg.Get().Features.ReadOnlyMount = tc.readOnlyMount
+			readOnly, err := svc.isPublishReadOnly(tc.access, tc.volumeReadOnly)
+			if tc.code != codes.OK {
+				require.Equal(t, tc.code, status.Code(err))
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, readOnly)
+		})
+	}
+}
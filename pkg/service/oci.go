@@ -0,0 +1,680 @@
+package service
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	// nolint
+	"github.com/containerd/containerd/reference/docker"
+	oldModelspec "github.com/dragonflyoss/model-spec/specs-go/v1"
+	"github.com/klauspost/compress/zstd"
+	"github.com/modelpack/modctl/pkg/backend"
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/config/auth"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/modelpack/model-csi-driver/pkg/utils"
+	modelspec "github.com/modelpack/model-spec/specs-go/v1"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry/remote"
+	orasauth "oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// The annotation set by oras on the layer of a pushed dir, the layer is a
+// tar+gzip archive of the dir named by the title annotation.
+const annotationOrasUnpack = "io.deis.oras.content.unpack"
+
+// The registry host of docker hub, the "docker.io" domain of the reference
+// isn't a registry.
+const dockerHubRegistry = "registry-1.docker.io"
+
+// registryPullConfig returns the pull config overridden for the registry of
+// the image reference, e.g. "docker.io" for "ubuntu".
+func registryPullConfig(pullCfg *config.PullConfig, reference string) *config.PullConfig {
+	// nolint
+	named, err := docker.ParseDockerRef(reference)
+	if err != nil {
+		return pullCfg
+	}
+	// nolint
+	return pullCfg.ForRegistry(docker.Domain(named))
+}
+
+// newOCIRepository creates the client of the remote repository of the model
+// image, with the auth and the server scheme found in the docker config.
+func newOCIRepository(pullCfg *config.PullConfig, reference string) (*remote.Repository, error) {
+	// nolint
+	named, err := docker.ParseDockerRef(reference)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse reference: %s", reference)
+	}
+	// nolint
+	pullCfg = pullCfg.ForRegistry(docker.Domain(named))
+
+	repo, err := remote.NewRepository(named.String())
+	if err != nil {
+		return nil, errors.Wrapf(err, "create repository: %s", reference)
+	}
+	if repo.Reference.Registry == "docker.io" {
+		repo.Reference.Registry = dockerHubRegistry
+	}
+
+	keyChain, err := auth.GetKeyChainByRef(reference)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get auth for model: %s", reference)
+	}
+	repo.PlainHTTP = keyChain.ServerScheme == "http"
+
+	client, err := newHTTPClient(pullCfg)
+	if err != nil {
+		return nil, err
+	}
+	if transport, ok := client.Transport.(*http.Transport); ok {
+		transport.TLSClientConfig, err = registryTLSConfig(&pullCfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+	}
+	repo.Client = &orasauth.Client{
+		Client:     client,
+		Cache:      orasauth.NewCache(),
+		Credential: registryCredential(reference, repo.Reference.Registry, keyChain),
+	}
+
+	return repo, nil
+}
+
+// registryTLSConfig returns the TLS config of the registry client, the CA
+// bundle and the client certificate are read on each call, so that the rotated
+// files take effect on the next pull.
+func registryTLSConfig(cfg *config.TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.SkipVerify()} // nolint
+	if cfg.CAFile != "" {
+		data, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read ca file: %s", cfg.CAFile)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.Errorf("no certificate found in ca file: %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "load client certificate: %s", cfg.CertFile)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// registryCredential returns the credential of the registry for the auth
+// client. The credential is requested again once the bearer token is expired
+// or rejected by the registry with 401, e.g. during the pull of a large model
+// outliving the short-lived token, then the auth is read from docker's
+// config.json again, so that the credentials renewed on the node in the
+// meantime are used without failing the pull. The registry credentials in
+// the CSI secrets of the volume carried by the context of the request take
+// precedence over the auth of the node.
+func registryCredential(reference, registry string, keyChain *auth.PassKeyChain) orasauth.CredentialFunc {
+	var mutex sync.Mutex
+	requested := false
+	return func(ctx context.Context, hostport string) (orasauth.Credential, error) {
+		if hostport != registry {
+			return orasauth.EmptyCredential, nil
+		}
+		if volumeKeyChain, ok, err := registrySecretsKeyChain(ctx, hostport); ok {
+			if err != nil {
+				return orasauth.EmptyCredential, err
+			}
+			return orasauth.Credential{
+				Username: volumeKeyChain.Username,
+				Password: volumeKeyChain.Password,
+			}, nil
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		if requested {
+			refreshed, err := auth.RefreshKeyChainByRef(reference)
+			if err != nil {
+				logger.WithContext(ctx).WithError(err).Warnf("failed to refresh auth for model: %s", reference)
+			} else {
+				keyChain = refreshed
+			}
+		}
+		requested = true
+
+		return orasauth.Credential{
+			Username:     keyChain.Username,
+			Password:     keyChain.Password,
+			RefreshToken: keyChain.IdentityToken,
+		}, nil
+	}
+}
+
+// ResolveDigest resolves the tag of the image reference to the descriptor of
+// the manifest. The reference of the image index (e.g. the multi-arch model
+// image) is resolved to the manifest of the platform, e.g. "linux/amd64",
+// whose platform is set in the returned descriptor.
+var ResolveDigest = func(ctx context.Context, pullCfg *config.PullConfig, reference, platform string) (ocispec.Descriptor, error) {
+	repo, err := newOCIRepository(pullCfg, reference)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	var desc ocispec.Descriptor
+	if err := utils.WithRetry(ctx, func() error {
+		desc, err = repo.Resolve(ctx, repo.Reference.Reference)
+		return err
+	}, 3, 1*time.Second); err != nil {
+		return ocispec.Descriptor{}, errors.Wrapf(err, "resolve reference: %s", reference)
+	}
+	if !isIndexMediaType(desc.MediaType) {
+		return desc, nil
+	}
+
+	return selectPlatformManifest(ctx, repo, desc, platform)
+}
+
+func isIndexMediaType(mediaType string) bool {
+	return mediaType == ocispec.MediaTypeImageIndex || mediaType == "application/vnd.docker.distribution.manifest.list.v2+json"
+}
+
+// defaultPlatform returns the platform of the node, e.g. "linux/amd64".
+func defaultPlatform() string {
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
+// parsePlatform parses the platform in the form of "os/arch[/variant]".
+func parsePlatform(platform string) (*ocispec.Platform, error) {
+	parts := strings.Split(strings.TrimSpace(platform), "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, errors.Errorf("invalid platform %q, expected os/arch[/variant]", platform)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return nil, errors.Errorf("invalid platform %q, expected os/arch[/variant]", platform)
+		}
+	}
+	parsed := &ocispec.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		parsed.Variant = parts[2]
+	}
+	return parsed, nil
+}
+
+// formatPlatform formats the platform in the form of "os/arch[/variant]".
+func formatPlatform(platform *ocispec.Platform) string {
+	formatted := platform.OS + "/" + platform.Architecture
+	if platform.Variant != "" {
+		formatted += "/" + platform.Variant
+	}
+	return formatted
+}
+
+// selectPlatformManifest returns the descriptor of the manifest of the
+// platform in the image index, the variant is matched only if it's given.
+func selectPlatformManifest(ctx context.Context, repo *remote.Repository, indexDesc ocispec.Descriptor, platform string) (ocispec.Descriptor, error) {
+	want, err := parsePlatform(platform)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	rc, err := repo.Fetch(ctx, indexDesc)
+	if err != nil {
+		return ocispec.Descriptor{}, errors.Wrapf(err, "fetch index: %s", repo.Reference)
+	}
+	defer func() { _ = rc.Close() }()
+	data, err := content.ReadAll(rc, indexDesc)
+	if err != nil {
+		return ocispec.Descriptor{}, errors.Wrapf(err, "read index: %s", repo.Reference)
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return ocispec.Descriptor{}, errors.Wrapf(err, "unmarshal index: %s", repo.Reference)
+	}
+
+	for _, desc := range index.Manifests {
+		if desc.Platform == nil || desc.Platform.OS != want.OS || desc.Platform.Architecture != want.Architecture {
+			continue
+		}
+		if want.Variant != "" && desc.Platform.Variant != want.Variant {
+			continue
+		}
+		logger.WithContext(ctx).Infof("selected manifest %s of platform %s from index %s", desc.Digest, formatPlatform(desc.Platform), indexDesc.Digest)
+		return desc, nil
+	}
+
+	return ocispec.Descriptor{}, errors.Errorf("no manifest of platform %s in index: %s", platform, repo.Reference)
+}
+
+// fetchManifest fetches the image manifest of the reference and returns it
+// with its descriptor, the image index isn't supported as the model isn't
+// platform specific.
+func fetchManifest(ctx context.Context, repo *remote.Repository) (ocispec.Descriptor, *ocispec.Manifest, error) {
+	desc, rc, err := repo.FetchReference(ctx, repo.Reference.Reference)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, errors.Wrapf(err, "fetch manifest: %s", repo.Reference)
+	}
+	defer func() { _ = rc.Close() }()
+
+	if desc.MediaType != ocispec.MediaTypeImageManifest && desc.MediaType != "application/vnd.docker.distribution.manifest.v2+json" {
+		return ocispec.Descriptor{}, nil, errors.Errorf("unsupported manifest media type %s: %s", desc.MediaType, repo.Reference)
+	}
+
+	data, err := content.ReadAll(rc, desc)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, errors.Wrapf(err, "read manifest: %s", repo.Reference)
+	}
+
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return ocispec.Descriptor{}, nil, errors.Wrapf(err, "unmarshal manifest: %s", repo.Reference)
+	}
+
+	return desc, &manifest, nil
+}
+
+// isModelManifest checks if the manifest is a model artifact packed with the
+// model spec, including the one of the old model spec.
+func isModelManifest(manifest *ocispec.Manifest) bool {
+	switch {
+	case manifest.ArtifactType == modelspec.ArtifactTypeModelManifest,
+		manifest.ArtifactType == oldModelspec.ArtifactTypeModelManifest,
+		manifest.Config.MediaType == modelspec.MediaTypeModelConfig,
+		manifest.Config.MediaType == oldModelspec.MediaTypeModelConfig:
+		return true
+	}
+	return false
+}
+
+// ociPuller pulls the generic OCI artifact which isn't packed with the model
+// spec, e.g. pushed by oras. The tar (optionally compressed) layers are
+// extracted into the model dir, the other layers are written as the files
+// named by the title annotation. The interrupted pull isn't resumed.
+type ociPuller struct {
+	pullCfg          *config.PullConfig
+	hook             *status.Hook
+	diskQuotaChecker *DiskQuotaChecker
+}
+
+type ociLayerKind int
+
+const (
+	ociLayerFile ociLayerKind = iota
+	ociLayerTar
+)
+
+// ociLayerPath returns the path of the layer in the model dir, by the title
+// annotation of oras or the file path annotation of the model spec.
+func ociLayerPath(desc ocispec.Descriptor) string {
+	if title := desc.Annotations[ocispec.AnnotationTitle]; title != "" {
+		return title
+	}
+	return status.LayerFilepath(desc)
+}
+
+// ociLayerKindOf returns how the layer is written into the model dir.
+func ociLayerKindOf(desc ocispec.Descriptor) (ociLayerKind, error) {
+	title := desc.Annotations[ocispec.AnnotationTitle]
+	switch {
+	// The raw layer of the model spec, e.g. the weight file which isn't
+	// archived.
+	case isRawLayer(desc) && status.LayerFilepath(desc) != "":
+		return ociLayerFile, nil
+	case title != "" && desc.Annotations[annotationOrasUnpack] != "true":
+		return ociLayerFile, nil
+	case strings.HasSuffix(desc.MediaType, "tar"),
+		strings.HasSuffix(desc.MediaType, "tar+gzip"),
+		strings.HasSuffix(desc.MediaType, "tar.gzip"),
+		strings.HasSuffix(desc.MediaType, "tar+zstd"),
+		desc.Annotations[annotationOrasUnpack] == "true":
+		return ociLayerTar, nil
+	}
+	return 0, errors.Errorf("unsupported layer media type %s of layer %s", desc.MediaType, desc.Digest)
+}
+
+// ociLayerDescriptor returns the layer descriptor with the file path
+// annotation of the model spec, so that the progress shows the file name.
+func ociLayerDescriptor(desc ocispec.Descriptor) ocispec.Descriptor {
+	title := desc.Annotations[ocispec.AnnotationTitle]
+	if title == "" {
+		return desc
+	}
+	annotations := map[string]string{}
+	for key, value := range desc.Annotations {
+		annotations[key] = value
+	}
+	annotations[modelspec.AnnotationFilepath] = title
+	desc.Annotations = annotations
+	return desc
+}
+
+func (p *ociPuller) pull(ctx context.Context, repo *remote.Repository, manifest *ocispec.Manifest, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	includeFile := includeLayerFunc(ctx, p.hook, excludeModelWeights, excludeFilePatterns)
+	include := func(name string) bool {
+		return includeFile(backend.InspectedModelArtifactLayer{Filepath: name})
+	}
+
+	layers := []ocispec.Descriptor{}
+	modelSize := int64(0)
+	for _, desc := range manifest.Layers {
+		kind, err := ociLayerKindOf(desc)
+		if err != nil {
+			return err
+		}
+		// The layer of the model spec holds a single file, whether it's
+		// archived or not.
+		if kind == ociLayerFile || status.LayerFilepath(desc) != "" {
+			path := ociLayerPath(desc)
+			if !filepath.IsLocal(path) {
+				return errors.Errorf("invalid model file path: %s", path)
+			}
+			if !include(path) {
+				continue
+			}
+		}
+		layers = append(layers, desc)
+		modelSize += desc.Size
+	}
+
+	// The size of the compressed layers is less than the extracted files.
+	if p.diskQuotaChecker != nil {
+		if err := p.diskQuotaChecker.CheckMinSize(ctx, reference, modelSize); err != nil {
+			return errors.Wrap(err, "check disk quota")
+		}
+	}
+
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return errors.Wrapf(err, "create model dir: %s", targetDir)
+	}
+
+	logger.WithContext(ctx).Infof("pulling generic oci artifact: %s, layers: %d/%d", reference, len(layers), len(manifest.Layers))
+	p.hook.SetTotal(len(layers))
+	p.hook.SetTotalSize(modelSize)
+
+	pullCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	eg, egCtx := errgroup.WithContext(pullCtx)
+	if p.pullCfg.Concurrency > 0 {
+		eg.SetLimit(int(p.pullCfg.Concurrency))
+	}
+	// The tar layers are extracted by the extraction pool once downloaded.
+	var extractGroup errgroup.Group
+	extractConcurrency := p.pullCfg.Extraction.Concurrency
+	if extractConcurrency > 0 {
+		extractGroup.SetLimit(int(extractConcurrency))
+	}
+	for _, desc := range layers {
+		eg.Go(func() error {
+			hookDesc := ociLayerDescriptor(desc)
+			p.hook.BeforePullLayer(hookDesc, *manifest)
+			if kind, _ := ociLayerKindOf(desc); kind != ociLayerTar || extractConcurrency == 0 {
+				err := withLayerRetry(egCtx, &p.pullCfg.Retry, desc.Digest.String(), func() error {
+					return p.pullLayer(egCtx, repo, desc, targetDir, include)
+				})
+				p.hook.AfterPullLayer(hookDesc, err)
+				return err
+			}
+
+			spoolPath := filepath.Join(filepath.Dir(targetDir), ".layer-"+desc.Digest.Encoded())
+			err := withLayerRetry(egCtx, &p.pullCfg.Retry, desc.Digest.String(), func() error {
+				return p.downloadLayer(egCtx, repo, desc, spoolPath)
+			})
+			if err != nil {
+				_ = os.Remove(spoolPath)
+				p.hook.AfterPullLayer(hookDesc, err)
+				return err
+			}
+			// Block the download until the extraction pool is available, so
+			// that the spool files are bounded.
+			extractGroup.Go(func() error {
+				err := p.extractLayer(egCtx, desc, spoolPath, targetDir, include)
+				p.hook.AfterPullLayer(hookDesc, err)
+				if err != nil {
+					cancel()
+				}
+				return err
+			})
+			return nil
+		})
+	}
+	err := eg.Wait()
+	// The failed extraction cancels the downloads.
+	if extractErr := extractGroup.Wait(); extractErr != nil && (err == nil || errors.Is(err, context.Canceled)) {
+		err = extractErr
+	}
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Errorf("failed to pull generic oci artifact: %s", reference)
+		return errors.Wrap(err, "pull generic oci artifact")
+	}
+
+	return nil
+}
+
+// downloadLayer downloads the layer into the spool file, verified by the
+// digest of the layer.
+func (p *ociPuller) downloadLayer(ctx context.Context, repo *remote.Repository, desc ocispec.Descriptor, spoolPath string) error {
+	if err := desc.Digest.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid layer digest: %s", desc.Digest)
+	}
+
+	rc, err := repo.Fetch(ctx, desc)
+	if err != nil {
+		return errors.Wrapf(err, "fetch layer: %s", desc.Digest)
+	}
+	defer func() { _ = rc.Close() }()
+
+	// The layer is downloaded from the start on retry.
+	p.hook.SetDownloadedBytes(desc.Digest, 0)
+	verifier := desc.Digest.Verifier()
+	reader := io.TeeReader(rc, io.MultiWriter(verifier, p.hook.ProgressWriter(desc.Digest)))
+	if err := writeFile(reader, spoolPath); err != nil {
+		return errors.Wrapf(err, "download layer: %s", desc.Digest)
+	}
+	if !verifier.Verified() {
+		return errors.Errorf("digest mismatch for layer: %s", desc.Digest)
+	}
+
+	return nil
+}
+
+// extractLayer extracts the layer from the spool file, which is removed once
+// extracted.
+func (p *ociPuller) extractLayer(ctx context.Context, desc ocispec.Descriptor, spoolPath, targetDir string, include func(name string) bool) error {
+	defer func() { _ = os.Remove(spoolPath) }()
+
+	file, err := os.Open(spoolPath)
+	if err != nil {
+		return errors.Wrapf(err, "open spool file: %s", spoolPath)
+	}
+	defer func() { _ = file.Close() }()
+
+	if err := extractTar(ctx, file, targetDir, include, &p.pullCfg.Extraction); err != nil {
+		return errors.Wrapf(err, "extract layer: %s", desc.Digest)
+	}
+
+	return nil
+}
+
+func (p *ociPuller) pullLayer(ctx context.Context, repo *remote.Repository, desc ocispec.Descriptor, targetDir string, include func(name string) bool) error {
+	if err := desc.Digest.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid layer digest: %s", desc.Digest)
+	}
+	kind, err := ociLayerKindOf(desc)
+	if err != nil {
+		return err
+	}
+
+	rc, err := repo.Fetch(ctx, desc)
+	if err != nil {
+		return errors.Wrapf(err, "fetch layer: %s", desc.Digest)
+	}
+	defer func() { _ = rc.Close() }()
+
+	// The layer is downloaded from the start on retry.
+	p.hook.SetDownloadedBytes(desc.Digest, 0)
+	verifier := desc.Digest.Verifier()
+	reader := io.TeeReader(rc, io.MultiWriter(verifier, p.hook.ProgressWriter(desc.Digest)))
+
+	if kind == ociLayerFile {
+		err = writeFile(reader, filepath.Join(targetDir, ociLayerPath(desc)))
+	} else {
+		err = extractTar(ctx, reader, targetDir, include, &p.pullCfg.Extraction)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "write layer: %s", desc.Digest)
+	}
+
+	// Consume the rest of the layer, e.g. the padding after the end of the
+	// tar archive, to verify the whole layer.
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return errors.Wrapf(err, "read layer: %s", desc.Digest)
+	}
+	if !verifier.Verified() {
+		return errors.Errorf("digest mismatch for layer: %s", desc.Digest)
+	}
+
+	return nil
+}
+
+func writeFile(reader io.Reader, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrapf(err, "create dir: %s", filepath.Dir(path))
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "create file: %s", path)
+	}
+	defer func() { _ = file.Close() }()
+
+	if _, err := io.Copy(file, reader); err != nil {
+		return errors.Wrapf(err, "write file: %s", path)
+	}
+
+	return file.Close()
+}
+
+// The magic number of the zstd frame.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// extractTar extracts the tar archive, optionally gzipped or zstd compressed,
+// into the target dir, the entries which would be written outside of the
+// target dir are rejected, and only the dirs, regular files and symlinks are
+// extracted.
+func extractTar(ctx context.Context, reader io.Reader, targetDir string, include func(name string) bool, extractCfg *config.ExtractionConfig) error {
+	buffered := bufio.NewReader(reader)
+	// Detect the compression by the magic number instead of the media type,
+	// which is set arbitrarily by the tools pushing the artifact.
+	magic, _ := buffered.Peek(len(zstdMagic))
+	switch {
+	case len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return errors.Wrap(err, "create gzip reader")
+		}
+		defer func() { _ = gzipReader.Close() }()
+		reader = gzipReader
+	case bytes.Equal(magic, zstdMagic):
+		concurrency := 1
+		if extractCfg != nil && extractCfg.ZstdConcurrency > 1 {
+			concurrency = int(extractCfg.ZstdConcurrency)
+		}
+		zstdReader, err := zstd.NewReader(buffered, zstd.WithDecoderConcurrency(concurrency))
+		if err != nil {
+			return errors.Wrap(err, "create zstd reader")
+		}
+		defer zstdReader.Close()
+		reader = zstdReader
+	default:
+		reader = buffered
+	}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "read tar")
+		}
+
+		name := filepath.Clean(header.Name)
+		if name == "." {
+			continue
+		}
+		if !filepath.IsLocal(name) {
+			return errors.Errorf("invalid file path in tar: %s", header.Name)
+		}
+		target := filepath.Join(targetDir, name)
+		// The target may be under a symlink extracted before.
+		under, err := utils.IsPathUnder(targetDir, target)
+		if err != nil {
+			return errors.Wrapf(err, "check file path in tar: %s", header.Name)
+		}
+		if !under {
+			return errors.Errorf("invalid file path in tar: %s", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return errors.Wrapf(err, "create dir: %s", target)
+			}
+		case tar.TypeReg:
+			if !include(name) {
+				continue
+			}
+			if err := writeFile(tarReader, target); err != nil {
+				return err
+			}
+			if err := os.Chmod(target, header.FileInfo().Mode().Perm()); err != nil {
+				return errors.Wrapf(err, "chmod file: %s", target)
+			}
+		case tar.TypeSymlink:
+			if !include(name) {
+				continue
+			}
+			if filepath.IsAbs(header.Linkname) || !filepath.IsLocal(filepath.Join(filepath.Dir(name), header.Linkname)) {
+				return errors.Errorf("invalid symlink in tar: %s -> %s", header.Name, header.Linkname)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return errors.Wrapf(err, "create dir: %s", filepath.Dir(target))
+			}
+			// The symlink may be extracted by the failed attempt of the layer.
+			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+				return errors.Wrapf(err, "remove symlink: %s", target)
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return errors.Wrapf(err, "create symlink: %s", target)
+			}
+		default:
+			logger.WithContext(ctx).Warnf("skip unsupported tar entry %s of type %c", header.Name, header.Typeflag)
+		}
+	}
+}
@@ -0,0 +1,425 @@
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+type tarEntry struct {
+	name     string
+	content  string
+	linkname string
+}
+
+func newTarGz(t *testing.T, entries []tarEntry) []byte {
+	buf := bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(&buf)
+	writeTar(t, gzipWriter, entries)
+	require.NoError(t, gzipWriter.Close())
+	return buf.Bytes()
+}
+
+func newTarZstd(t *testing.T, entries []tarEntry) []byte {
+	buf := bytes.Buffer{}
+	zstdWriter, err := zstd.NewWriter(&buf)
+	require.NoError(t, err)
+	writeTar(t, zstdWriter, entries)
+	require.NoError(t, zstdWriter.Close())
+	return buf.Bytes()
+}
+
+func writeTar(t *testing.T, writer io.Writer, entries []tarEntry) {
+	tarWriter := tar.NewWriter(writer)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.content)), Typeflag: tar.TypeReg}
+		if entry.linkname != "" {
+			header = &tar.Header{Name: entry.name, Mode: 0777, Linkname: entry.linkname, Typeflag: tar.TypeSymlink}
+		}
+		require.NoError(t, tarWriter.WriteHeader(header))
+		_, err := tarWriter.Write([]byte(entry.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+}
+
+// newFakeRegistry serves the manifest of "test/model:latest" and its blobs,
+// the registry is accessed with plain http by the docker config.
+func newFakeRegistry(t *testing.T, manifest ocispec.Manifest, blobs map[digest.Digest][]byte) string {
+	return newFakeRegistryWithManifests(t, map[string]ocispec.Manifest{"latest": manifest}, blobs)
+}
+
+// newFakeRegistryWithManifests serves the manifests of "test/model" by tag
+// and their blobs.
+func newFakeRegistryWithManifests(t *testing.T, manifests map[string]ocispec.Manifest, blobs map[digest.Digest][]byte) string {
+	manifestData := map[string][]byte{}
+	for tag, manifest := range manifests {
+		data, err := json.Marshal(manifest)
+		require.NoError(t, err)
+		manifestData[tag] = data
+	}
+
+	return serveFakeRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/v2/test/model/manifests/"):
+			data, ok := manifestData[strings.TrimPrefix(r.URL.Path, "/v2/test/model/manifests/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", ocispec.MediaTypeImageManifest)
+			w.Header().Set("Docker-Content-Digest", digest.FromBytes(data).String())
+			_, _ = w.Write(data)
+		case strings.HasPrefix(r.URL.Path, "/v2/test/model/blobs/"):
+			blob, ok := blobs[digest.Digest(strings.TrimPrefix(r.URL.Path, "/v2/test/model/blobs/"))]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(blob)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+// newFakeIndexRegistry serves the image index of "test/model:latest".
+func newFakeIndexRegistry(t *testing.T, index ocispec.Index) string {
+	indexData, err := json.Marshal(index)
+	require.NoError(t, err)
+
+	return serveFakeRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/test/model/manifests/latest":
+			w.Header().Set("Content-Type", ocispec.MediaTypeImageIndex)
+			w.Header().Set("Docker-Content-Digest", digest.FromBytes(indexData).String())
+			_, _ = w.Write(indexData)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+// serveFakeRegistry serves the registry by the handler, the registry is
+// accessed with plain http by the docker config.
+func serveFakeRegistry(t *testing.T, handler http.HandlerFunc) string {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	dockerConfigDir := t.TempDir()
+	require.NoError(t, os.WriteFile(
+		filepath.Join(dockerConfigDir, "config.json"),
+		[]byte(fmt.Sprintf(`{"auths":{"%s":{"serverscheme":"http"}}}`, host)), 0600,
+	))
+	t.Setenv("DOCKER_CONFIG", dockerConfigDir)
+
+	return host
+}
+
+func TestPuller_GenericOCIArtifact(t *testing.T) {
+	dirLayer := newTarGz(t, []tarEntry{
+		{name: "model/config.json", content: `{"model_type":"qwen3"}`},
+		{name: "model/model.safetensors", content: "weights"},
+		{name: "model/latest", linkname: "config.json"},
+	})
+	readme := []byte("# model")
+	blobs := map[digest.Digest][]byte{
+		digest.FromBytes(dirLayer): dirLayer,
+		digest.FromBytes(readme):   readme,
+	}
+	manifest := ocispec.Manifest{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.example.model",
+		Config:       ocispec.DescriptorEmptyJSON,
+		Layers: []ocispec.Descriptor{
+			{
+				MediaType: ocispec.MediaTypeImageLayerGzip,
+				Digest:    digest.FromBytes(dirLayer),
+				Size:      int64(len(dirLayer)),
+				Annotations: map[string]string{
+					ocispec.AnnotationTitle: "model",
+					annotationOrasUnpack:    "true",
+				},
+			},
+			{
+				MediaType:   "application/vnd.example.file",
+				Digest:      digest.FromBytes(readme),
+				Size:        int64(len(readme)),
+				Annotations: map[string]string{ocispec.AnnotationTitle: "README.md"},
+			},
+		},
+	}
+	reference := newFakeRegistry(t, manifest, blobs) + "/test/model:latest"
+
+	ctx := context.Background()
+	p := &puller{pullCfg: &config.PullConfig{}, hook: status.NewHook(ctx)}
+
+	targetDir := filepath.Join(t.TempDir(), "model")
+	require.NoError(t, p.Pull(ctx, reference, targetDir, false, nil))
+	data, err := os.ReadFile(filepath.Join(targetDir, "model", "config.json"))
+	require.NoError(t, err)
+	require.Equal(t, `{"model_type":"qwen3"}`, string(data))
+	require.FileExists(t, filepath.Join(targetDir, "model", "model.safetensors"))
+	link, err := os.Readlink(filepath.Join(targetDir, "model", "latest"))
+	require.NoError(t, err)
+	require.Equal(t, "config.json", link)
+	data, err = os.ReadFile(filepath.Join(targetDir, "README.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# model", string(data))
+	progress := p.hook.GetProgress()
+	require.Equal(t, int64(len(dirLayer)+len(readme)), progress.TotalBytes)
+	require.Equal(t, progress.TotalBytes, progress.DownloadedBytes)
+
+	// The filters are applied to the extracted files.
+	targetDir = filepath.Join(t.TempDir(), "model")
+	require.NoError(t, p.Pull(ctx, reference, targetDir, true, []string{"*.md"}))
+	require.FileExists(t, filepath.Join(targetDir, "model", "config.json"))
+	require.NoFileExists(t, filepath.Join(targetDir, "model", "model.safetensors"))
+	require.NoFileExists(t, filepath.Join(targetDir, "README.md"))
+}
+
+func TestPuller_GenericOCIArtifact_ExtractionPool(t *testing.T) {
+	gzipLayer := newTarGz(t, []tarEntry{{name: "config.json", content: `{"model_type":"qwen3"}`}})
+	zstdLayer := newTarZstd(t, []tarEntry{{name: "weights/model.safetensors", content: "weights"}})
+	blobs := map[digest.Digest][]byte{
+		digest.FromBytes(gzipLayer): gzipLayer,
+		digest.FromBytes(zstdLayer): zstdLayer,
+	}
+	layer := func(mediaType string, data []byte) ocispec.Descriptor {
+		return ocispec.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(data), Size: int64(len(data))}
+	}
+	manifest := ocispec.Manifest{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.example.model",
+		Config:       ocispec.DescriptorEmptyJSON,
+		Layers: []ocispec.Descriptor{
+			layer(ocispec.MediaTypeImageLayerGzip, gzipLayer),
+			layer(ocispec.MediaTypeImageLayerZstd, zstdLayer),
+		},
+	}
+	reference := newFakeRegistry(t, manifest, blobs) + "/test/model:latest"
+
+	ctx := context.Background()
+	p := &puller{
+		pullCfg: &config.PullConfig{Extraction: config.ExtractionConfig{Concurrency: 1, ZstdConcurrency: 2}},
+		hook:    status.NewHook(ctx),
+	}
+
+	volumeDir := t.TempDir()
+	targetDir := filepath.Join(volumeDir, "model")
+	require.NoError(t, p.Pull(ctx, reference, targetDir, false, nil))
+	require.FileExists(t, filepath.Join(targetDir, "config.json"))
+	data, err := os.ReadFile(filepath.Join(targetDir, "weights", "model.safetensors"))
+	require.NoError(t, err)
+	require.Equal(t, "weights", string(data))
+	require.Equal(t, 2, p.hook.GetProgress().Total)
+
+	// The spool files are removed once extracted.
+	entries, err := os.ReadDir(volumeDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestExtractTar_Zstd(t *testing.T) {
+	targetDir := filepath.Join(t.TempDir(), "model")
+	layer := newTarZstd(t, []tarEntry{{name: "config.json", content: `{"model_type":"qwen3"}`}})
+	require.NoError(t, extractTar(context.Background(), bytes.NewReader(layer), targetDir, func(name string) bool { return true }, nil))
+	data, err := os.ReadFile(filepath.Join(targetDir, "config.json"))
+	require.NoError(t, err)
+	require.Equal(t, `{"model_type":"qwen3"}`, string(data))
+}
+
+func TestExtractTar_InvalidPath(t *testing.T) {
+	ctx := context.Background()
+	include := func(name string) bool { return true }
+
+	for _, entries := range [][]tarEntry{
+		{{name: "../escape", content: "escape"}},
+		{{name: "link", linkname: "/etc"}},
+		{{name: "link", linkname: "../../etc"}},
+	} {
+		targetDir := filepath.Join(t.TempDir(), "model")
+		err := extractTar(ctx, bytes.NewReader(newTarGz(t, entries)), targetDir, include, nil)
+		require.ErrorContains(t, err, "invalid", entries[0].name)
+	}
+}
+
+func TestIsModelManifest(t *testing.T) {
+	require.True(t, isModelManifest(&ocispec.Manifest{ArtifactType: "application/vnd.cncf.model.manifest.v1+json"}))
+	require.True(t, isModelManifest(&ocispec.Manifest{Config: ocispec.Descriptor{MediaType: "application/vnd.cnai.model.config.v1+json"}}))
+	require.False(t, isModelManifest(&ocispec.Manifest{Config: ocispec.DescriptorEmptyJSON}))
+}
+
+func TestRegistryPullConfig(t *testing.T) {
+	pullCfg := &config.PullConfig{
+		Concurrency: 5,
+		Registries: map[string]config.RegistryPullConfig{
+			"docker.io":              {Concurrency: 2},
+			"registry.internal:5000": {Concurrency: 20},
+		},
+	}
+
+	require.Equal(t, uint(2), registryPullConfig(pullCfg, "ubuntu:latest").Concurrency)
+	require.Equal(t, uint(20), registryPullConfig(pullCfg, "registry.internal:5000/test/model:latest").Concurrency)
+	require.Equal(t, uint(5), registryPullConfig(pullCfg, "registry.internal/test/model:latest").Concurrency)
+	require.Equal(t, uint(5), registryPullConfig(pullCfg, "INVALID").Concurrency)
+}
+
+func TestParsePlatform(t *testing.T) {
+	platform, err := parsePlatform("linux/arm64/v8")
+	require.NoError(t, err)
+	require.Equal(t, &ocispec.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, platform)
+	require.Equal(t, "linux/arm64/v8", formatPlatform(platform))
+
+	for _, invalid := range []string{"", "linux", "linux/", "linux/arm64/v8/x"} {
+		_, err := parsePlatform(invalid)
+		require.ErrorContains(t, err, "invalid platform")
+	}
+}
+
+func TestResolveDigest_Index(t *testing.T) {
+	amd64 := digest.FromString("amd64")
+	arm64 := digest.FromString("arm64")
+	armv7 := digest.FromString("armv7")
+	host := newFakeIndexRegistry(t, ocispec.Index{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{
+			{MediaType: ocispec.MediaTypeImageManifest, Digest: amd64, Size: 5, Platform: &ocispec.Platform{OS: "linux", Architecture: "amd64"}},
+			{MediaType: ocispec.MediaTypeImageManifest, Digest: arm64, Size: 5, Platform: &ocispec.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}},
+			{MediaType: ocispec.MediaTypeImageManifest, Digest: armv7, Size: 5, Platform: &ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		},
+	})
+	reference := host + "/test/model:latest"
+	ctx := context.Background()
+
+	desc, err := ResolveDigest(ctx, &config.PullConfig{}, reference, "linux/amd64")
+	require.NoError(t, err)
+	require.Equal(t, amd64, desc.Digest)
+	require.Equal(t, "linux/amd64", formatPlatform(desc.Platform))
+
+	// The variant is matched only if it's given.
+	desc, err = ResolveDigest(ctx, &config.PullConfig{}, reference, "linux/arm64")
+	require.NoError(t, err)
+	require.Equal(t, arm64, desc.Digest)
+	require.Equal(t, "linux/arm64/v8", formatPlatform(desc.Platform))
+
+	_, err = ResolveDigest(ctx, &config.PullConfig{}, reference, "linux/arm/v6")
+	require.ErrorContains(t, err, "no manifest of platform linux/arm/v6")
+}
+
+func TestNewOCIRepository_RefreshCredential(t *testing.T) {
+	manifestData, err := json.Marshal(ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    ocispec.DescriptorEmptyJSON,
+	})
+	require.NoError(t, err)
+
+	var password atomic.Value
+	password.Store("token-1")
+	host := serveFakeRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+		username, pass, ok := r.BasicAuth()
+		if !ok || username != "user" || pass != password.Load().(string) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", ocispec.MediaTypeImageManifest)
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(manifestData).String())
+		_, _ = w.Write(manifestData)
+	})
+	writeAuth := func(pass string) {
+		auth := base64.StdEncoding.EncodeToString([]byte("user:" + pass))
+		require.NoError(t, os.WriteFile(
+			filepath.Join(os.Getenv("DOCKER_CONFIG"), "config.json"),
+			[]byte(fmt.Sprintf(`{"auths":{"%s":{"auth":"%s","serverscheme":"http"}}}`, host, auth)), 0600,
+		))
+	}
+	writeAuth("token-1")
+
+	ctx := context.Background()
+	repo, err := newOCIRepository(&config.PullConfig{}, host+"/test/model:latest")
+	require.NoError(t, err)
+	_, _, err = fetchManifest(ctx, repo)
+	require.NoError(t, err)
+
+	// The token is renewed on the node during the pull.
+	password.Store("token-2")
+	writeAuth("token-2")
+	_, _, err = fetchManifest(ctx, repo)
+	require.NoError(t, err)
+}
+
+func TestNewOCIRepository_TLS(t *testing.T) {
+	manifestData, err := json.Marshal(ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    ocispec.DescriptorEmptyJSON,
+	})
+	require.NoError(t, err)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ocispec.MediaTypeImageManifest)
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(manifestData).String())
+		_, _ = w.Write(manifestData)
+	}))
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "https://")
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	require.NoError(t, os.WriteFile(filepath.Join(os.Getenv("DOCKER_CONFIG"), "config.json"), []byte(`{"auths":{}}`), 0600))
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	}), 0600))
+
+	ctx := context.Background()
+	reference := host + "/test/model:latest"
+	fetch := func(pullCfg *config.PullConfig) error {
+		repo, err := newOCIRepository(pullCfg, reference)
+		if err != nil {
+			return err
+		}
+		_, _, err = fetchManifest(ctx, repo)
+		return err
+	}
+
+	// The certificate isn't verified by default.
+	require.NoError(t, fetch(&config.PullConfig{}))
+	verify := false
+	require.Error(t, fetch(&config.PullConfig{TLS: config.TLSConfig{InsecureSkipVerify: &verify}}))
+	require.NoError(t, fetch(&config.PullConfig{TLS: config.TLSConfig{CAFile: caFile}}))
+	require.NoError(t, fetch(&config.PullConfig{
+		TLS: config.TLSConfig{InsecureSkipVerify: &verify},
+		Registries: map[string]config.RegistryPullConfig{
+			host: {TLS: &config.TLSConfig{CAFile: caFile}},
+		},
+	}))
+
+	require.Error(t, fetch(&config.PullConfig{TLS: config.TLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}}))
+	require.Error(t, fetch(&config.PullConfig{TLS: config.TLSConfig{CertFile: caFile, KeyFile: caFile}}))
+
+	repo, err := newOCIRepository(&config.PullConfig{}, reference)
+	require.NoError(t, err)
+	require.Equal(t, "the tls config of the registry", pullByLayersReason(ctx, &config.PullConfig{TLS: config.TLSConfig{CAFile: caFile}}, repo))
+	require.Empty(t, pullByLayersReason(ctx, &config.PullConfig{}, repo))
+}
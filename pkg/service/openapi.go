@@ -0,0 +1,13 @@
+package service
+
+import (
+	_ "embed"
+)
+
+// openAPISpec is the OpenAPI specification of the HTTP API of the dynamic
+// server, served at /api/v1/openapi.json for the client SDKs in other
+// languages to be generated from. It's checked against the routes and the
+// request and response types by the tests, so update it with them.
+//
+//go:embed openapi.json
+var openAPISpec []byte
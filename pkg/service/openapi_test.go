@@ -0,0 +1,137 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+)
+
+type openAPIDocument struct {
+	OpenAPI    string                                `json:"openapi"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadOpenAPISpec(t *testing.T) *openAPIDocument {
+	t.Helper()
+	doc := &openAPIDocument{}
+	require.NoError(t, json.Unmarshal(openAPISpec, doc))
+	return doc
+}
+
+func TestOpenAPISpec_Routes(t *testing.T) {
+	doc := loadOpenAPISpec(t)
+	require.Equal(t, "3.0.3", doc.OpenAPI)
+
+	e := echo.New()
+	registerRoutes(e, &DynamicServerHandler{})
+	paramRegexp := regexp.MustCompile(`:([a-z_]+)`)
+	routes := []string{}
+	for _, route := range e.Routes() {
+		// The escaped ":" of the path isn't a param, e.g. "mounts\:batch".
+		path := strings.ReplaceAll(route.Path, `\:`, "\x00")
+		path = paramRegexp.ReplaceAllString(path, "{$1}")
+		path = strings.ReplaceAll(path, "\x00", ":")
+		routes = append(routes, route.Method+" "+path)
+	}
+	operations := []string{}
+	for path, methods := range doc.Paths {
+		for method := range methods {
+			operations = append(operations, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(routes)
+	sort.Strings(operations)
+	require.Equal(t, routes, operations)
+}
+
+// jsonFields returns the JSON names of the fields of the struct, including
+// the fields of the embedded structs.
+func jsonFields(typ reflect.Type) []string {
+	fields := []string{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Anonymous {
+			fields = append(fields, jsonFields(field.Type)...)
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+func TestOpenAPISpec_Schemas(t *testing.T) {
+	doc := loadOpenAPISpec(t)
+
+	types := map[string]interface{}{
+		"ErrorResponse":      ErrorResponse{},
+		"Adapter":            modelStatus.Adapter{},
+		"MountRequest":       MountRequest{},
+		"UpdateMountRequest": UpdateMountRequest{},
+		"BatchMountRequest":  BatchMountRequest{},
+		"BatchMountResult":   BatchMountResult{},
+		"BatchMountResponse": BatchMountResponse{},
+		"ProgressItem":       modelStatus.ProgressItem{},
+		"PreheatProgress":    modelStatus.PreheatProgress{},
+		"Progress":           modelStatus.Progress{},
+		"Target":             modelStatus.Target{},
+		"ScrubResult":        modelStatus.ScrubResult{},
+		"HistoryItem":        modelStatus.HistoryItem{},
+		"Status":             modelStatus.Status{},
+		"ErrorDetail":        modelStatus.ErrorDetail{},
+		"MountProgress":      MountProgress{},
+		"MountResource":      MountResource{},
+		"DiskQuotaInfo":      DiskQuotaInfo{},
+		"ServerInfo":         ServerInfo{},
+		"MountUsage":         MountUsage{},
+		"VolumeUsage":        VolumeUsage{},
+		"InspectedFile":      InspectedFile{},
+		"ModelInspection":    ModelInspection{},
+		"ListTagsResponse":   ListTagsResponse{},
+		"PrefetchRequest":    PrefetchRequest{},
+		"GCRequest":          GCRequest{},
+		"GCModel":            GCModel{},
+		"GCBlob":             GCBlob{},
+		"GCResponse":         GCResponse{},
+		"ReconcileRequest":   ReconcileRequest{},
+		"ReconciledVolume":   ReconciledVolume{},
+		"ReconcileResponse":  ReconcileResponse{},
+		"PinRequest":         PinRequest{},
+	}
+	require.Len(t, doc.Components.Schemas, len(types))
+	for name, value := range types {
+		schema, ok := doc.Components.Schemas[name]
+		require.True(t, ok, "schema %s isn't found", name)
+		properties := []string{}
+		for property := range schema.Properties {
+			properties = append(properties, property)
+		}
+		require.ElementsMatch(t, jsonFields(reflect.TypeOf(value)), properties, "properties of schema %s", name)
+	}
+}
+
+func TestDynamicServerHandler_GetOpenAPI(t *testing.T) {
+	h, _ := newHandler(t)
+
+	c, rec := newHandlerContextWithParam(t, http.MethodGet, "/", "", nil, nil)
+	_ = h.GetOpenAPI(c)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON)
+	require.JSONEq(t, string(openAPISpec), rec.Body.String())
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/metrics"
+	"github.com/modelpack/model-csi-driver/pkg/mounter"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	OrphanScanInterval = 5 * time.Minute
+	// The dynamic root volume published within the grace period is never
+	// considered as orphaned, to avoid racing with an in-progress publish.
+	OrphanVolumeGracePeriod = 10 * time.Minute
+)
+
+type podInfo struct {
+	namespace string
+	name      string
+	uid       string
+}
+
+func (s *Service) cleanupOrphanedVolumesLoop() {
+	for {
+		time.Sleep(OrphanScanInterval)
+		if !s.cfg.Get().Features.CleanupOrphanedVolumes {
+			continue
+		}
+		if err := s.cleanupOrphanedVolumes(context.Background()); err != nil {
+			logger.Logger().WithError(err).Warnf("cleanup orphaned volumes failed")
+		}
+	}
+}
+
+// cleanupOrphanedVolumes unpublishes the target paths of the dynamic root volumes
+// whose pod is gone without NodeUnpublishVolume being called, for example the pod
+// is force deleted, the volume dir, mounts and csi.sock server will be left forever
+// otherwise.
+func (s *Service) cleanupOrphanedVolumes(ctx context.Context) error {
+	volumesDir := s.cfg.Get().GetVolumesDir()
+	volumeDirs, err := os.ReadDir(volumesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "read volume dirs from %s", volumesDir)
+	}
+
+	for _, volumeDir := range volumeDirs {
+		volumeName := volumeDir.Name()
+		if !volumeDir.IsDir() || !isDynamicVolume(volumeName) {
+			continue
+		}
+
+		statusPath := filepath.Join(s.cfg.Get().GetVolumeDirForDynamic(volumeName), "status.json")
+		volumeStatus, err := s.sm.Get(statusPath)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				logger.Logger().WithError(err).Warnf("get dynamic volume status: %s", statusPath)
+			}
+			continue
+		}
+		if volumeStatus.Inline {
+			continue
+		}
+
+		info, err := os.Stat(statusPath)
+		if err != nil {
+			logger.Logger().WithError(err).Warnf("stat dynamic volume status: %s", statusPath)
+			continue
+		}
+		if time.Since(info.ModTime()) < OrphanVolumeGracePeriod {
+			continue
+		}
+
+		for _, target := range volumeStatus.Targets {
+			ctx := logger.NewContext(ctx, "CleanupOrphanedVolume", volumeName, target.Path)
+
+			reason, err := s.getOrphanedPodReason(ctx, podInfo{
+				namespace: target.PodNamespace,
+				name:      target.PodName,
+				uid:       target.PodUID,
+			})
+			if err != nil {
+				logger.WithContext(ctx).WithError(err).Warnf("check pod of dynamic volume")
+				continue
+			}
+			if reason == "" {
+				continue
+			}
+
+			if err := s.cleanupOrphanedTarget(ctx, volumeName, target, reason); err != nil {
+				logger.WithContext(ctx).WithError(err).Errorf("failed to cleanup orphaned volume")
+			}
+		}
+	}
+
+	return nil
+}
+
+// cleanupOrphanedTarget unpublishes the orphaned target path of the dynamic root
+// volume, the volume is locked against the concurrent NodePublishVolume and
+// NodeUnpublishVolume, and the target is skipped if it has been unpublished or
+// re-published in the meantime.
+func (s *Service) cleanupOrphanedTarget(ctx context.Context, volumeName string, target modelStatus.Target, reason string) error {
+	unlock, err := s.lockVolume(ctx, volumeName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	statusPath := filepath.Join(s.cfg.Get().GetVolumeDirForDynamic(volumeName), "status.json")
+	volumeStatus, err := s.sm.Get(statusPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return errors.Wrap(err, "get dynamic volume status")
+	}
+	if !slices.Contains(volumeStatus.Targets, target) {
+		return nil
+	}
+
+	isMounted, err := mounter.IsMounted(ctx, target.Path)
+	if err != nil {
+		return errors.Wrap(err, "check if target path is mounted")
+	}
+
+	logger.WithContext(ctx).Infof("cleaning up orphaned volume: %s", reason)
+	start := time.Now()
+	_, err = s.nodeUnPublishVolumeDynamic(ctx, volumeName, target.Path, isMounted)
+	metrics.NodeOpObserve("cleanup_orphaned_volume", start, err)
+	if err != nil {
+		return err
+	}
+	logger.WithContext(ctx).Infof("cleaned up orphaned volume")
+
+	return nil
+}
+
+// getOrphanedPodReason returns a non-empty reason if the pod of the target doesn't
+// exist anymore or has been re-created, it returns an empty reason if the pod can't
+// be checked, e.g. the pod info is not passed by kubelet (podInfoOnMount disabled).
+func (s *Service) getOrphanedPodReason(ctx context.Context, pod podInfo) (string, error) {
+	if s.pods == nil || pod.namespace == "" || pod.name == "" || pod.uid == "" {
+		return "", nil
+	}
+
+	got, err := s.pods.Pods(pod.namespace).Get(ctx, pod.name, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return "pod not found", nil
+		}
+		return "", errors.Wrapf(err, "get pod: %s/%s", pod.namespace, pod.name)
+	}
+
+	if string(got.UID) != pod.uid {
+		return "pod uid changed", nil
+	}
+
+	return "", nil
+}
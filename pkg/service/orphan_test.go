@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/modelpack/model-csi-driver/pkg/mounter"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func setDynamicRootVolumeStatus(t *testing.T, svc *Service, volumeName string, status modelStatus.Status, age time.Duration) string {
+	t.Helper()
+	volumeDir := svc.cfg.Get().GetVolumeDirForDynamic(volumeName)
+	statusPath := filepath.Join(volumeDir, "status.json")
+	_, err := svc.sm.Set(statusPath, status)
+	require.NoError(t, err)
+	modTime := time.Now().Add(-age)
+	require.NoError(t, os.Chtimes(statusPath, modTime, modTime))
+	return volumeDir
+}
+
+func TestCleanupOrphanedVolumes_NoVolumesDir(t *testing.T) {
+	svc, _ := newNodeService(t)
+	require.NoError(t, svc.cleanupOrphanedVolumes(context.Background()))
+}
+
+func TestCleanupOrphanedVolumes_Skipped(t *testing.T) {
+	svc, _ := newNodeService(t)
+	svc.DynamicServerManager = NewDynamicServerManager(svc.cfg, svc)
+	// No pod exists.
+	svc.pods = fake.NewSimpleClientset().CoreV1()
+
+	patchUMount := gomonkey.ApplyFunc(mounter.UMount, func(ctx context.Context, mountPoint string, lazy bool) error {
+		return nil
+	})
+	defer patchUMount.Reset()
+
+	target := modelStatus.Target{
+		Path: filepath.Join(t.TempDir(), "mount"), PodNamespace: "default", PodName: "deleted", PodUID: "uid-deleted",
+	}
+	orphanedDir := setDynamicRootVolumeStatus(t, svc, "csi-orphaned", modelStatus.Status{
+		VolumeName: "csi-orphaned",
+		Targets:    []modelStatus.Target{target},
+	}, 2*OrphanVolumeGracePeriod)
+	// Recently published volume is kept.
+	recentDir := setDynamicRootVolumeStatus(t, svc, "csi-recent", modelStatus.Status{
+		VolumeName: "csi-recent",
+		Targets:    []modelStatus.Target{target},
+	}, 0)
+	// Volume published by the old version without target path is kept.
+	legacyDir := setDynamicRootVolumeStatus(t, svc, "csi-legacy", modelStatus.Status{
+		VolumeName: "csi-legacy",
+	}, 2*OrphanVolumeGracePeriod)
+	// Inline volume is kept.
+	inlineDir := setDynamicRootVolumeStatus(t, svc, "csi-inline", modelStatus.Status{
+		VolumeName: "csi-inline",
+		Targets:    []modelStatus.Target{target},
+		Inline:     true,
+	}, 2*OrphanVolumeGracePeriod)
+	// Pod can't be checked without pod info even if the target path is not mounted.
+	noPodInfoDir := setDynamicRootVolumeStatus(t, svc, "csi-no-pod-info", modelStatus.Status{
+		VolumeName: "csi-no-pod-info",
+		Targets:    []modelStatus.Target{{Path: target.Path}},
+	}, 2*OrphanVolumeGracePeriod)
+
+	require.NoError(t, svc.cleanupOrphanedVolumes(context.Background()))
+
+	require.NoDirExists(t, orphanedDir)
+	require.DirExists(t, recentDir)
+	require.DirExists(t, legacyDir)
+	require.DirExists(t, inlineDir)
+	require.DirExists(t, noPodInfoDir)
+}
+
+func TestCleanupOrphanedVolumes_PodGone(t *testing.T) {
+	svc, _ := newNodeService(t)
+	svc.DynamicServerManager = NewDynamicServerManager(svc.cfg, svc)
+	svc.pods = fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "running", UID: types.UID("uid-running")},
+	}, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "recreated", UID: types.UID("uid-new")},
+	}).CoreV1()
+
+	patchIsMounted := gomonkey.ApplyFunc(mounter.IsMounted, func(ctx context.Context, mountPoint string) (bool, error) {
+		return true, nil
+	})
+	defer patchIsMounted.Reset()
+	umounted := []string{}
+	patchUMount := gomonkey.ApplyFunc(mounter.UMount, func(ctx context.Context, mountPoint string, lazy bool) error {
+		umounted = append(umounted, mountPoint)
+		return nil
+	})
+	defer patchUMount.Reset()
+
+	age := 2 * OrphanVolumeGracePeriod
+	runningDir := setDynamicRootVolumeStatus(t, svc, "csi-running", modelStatus.Status{
+		Targets: []modelStatus.Target{
+			{Path: "/pods/uid-running/mount", PodNamespace: "default", PodName: "running", PodUID: "uid-running"},
+		},
+	}, age)
+	deletedDir := setDynamicRootVolumeStatus(t, svc, "csi-deleted", modelStatus.Status{
+		Targets: []modelStatus.Target{
+			{Path: "/pods/uid-deleted/mount", PodNamespace: "default", PodName: "deleted", PodUID: "uid-deleted"},
+		},
+	}, age)
+	recreatedDir := setDynamicRootVolumeStatus(t, svc, "csi-recreated", modelStatus.Status{
+		Targets: []modelStatus.Target{
+			{Path: "/pods/uid-old/mount", PodNamespace: "default", PodName: "recreated", PodUID: "uid-old"},
+		},
+	}, age)
+	// Pod can't be checked without pod info.
+	noPodInfoDir := setDynamicRootVolumeStatus(t, svc, "csi-no-pod-info", modelStatus.Status{
+		Targets: []modelStatus.Target{{Path: "/pods/unknown/mount"}},
+	}, age)
+	// Volume shared by the running pod and the deleted pod.
+	sharedDir := setDynamicRootVolumeStatus(t, svc, "csi-shared", modelStatus.Status{
+		Targets: []modelStatus.Target{
+			{Path: "/pods/uid-running/shared", PodNamespace: "default", PodName: "running", PodUID: "uid-running"},
+			{Path: "/pods/uid-deleted/shared", PodNamespace: "default", PodName: "deleted", PodUID: "uid-deleted"},
+		},
+	}, age)
+
+	require.NoError(t, svc.cleanupOrphanedVolumes(context.Background()))
+
+	require.DirExists(t, runningDir)
+	require.NoDirExists(t, deletedDir)
+	require.NoDirExists(t, recreatedDir)
+	require.DirExists(t, noPodInfoDir)
+	require.Contains(t, umounted, "/pods/uid-deleted/mount")
+	require.Contains(t, umounted, "/pods/uid-old/mount")
+	require.NotContains(t, umounted, "/pods/uid-running/mount")
+
+	require.DirExists(t, sharedDir)
+	require.Contains(t, umounted, "/pods/uid-deleted/shared")
+	require.NotContains(t, umounted, "/pods/uid-running/shared")
+	sharedStatus, err := svc.sm.Get(filepath.Join(sharedDir, "status.json"))
+	require.NoError(t, err)
+	require.Equal(t, []modelStatus.Target{
+		{Path: "/pods/uid-running/shared", PodNamespace: "default", PodName: "running", PodUID: "uid-running"},
+	}, sharedStatus.Targets)
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mountOwner is the ownership and the permission of the model files set by
+// the uid, gid and mode mount flags of the volume capability, so that the
+// pods running as non-root can read the model.
+type mountOwner struct {
+	// -1 if not set.
+	uid int
+	gid int
+	// 0 if not set.
+	mode os.FileMode
+	// Add the group read permission, set for the volume mount group.
+	groupRead bool
+	// The owner is set only by the volume mount group (fsGroup), which is
+	// ignored instead of being rejected where it can't be applied.
+	groupOnly bool
+}
+
+func (owner *mountOwner) String() string {
+	s := fmt.Sprintf("uid=%d,gid=%d,mode=%04o", owner.uid, owner.gid, owner.mode)
+	if owner.groupRead {
+		s += ",group-read"
+	}
+	return s
+}
+
+// parseMountFlags parses the mount flags of the volume capability, e.g. the
+// mountOptions of the PV, the flags other than ro, uid, gid and mode are
+// ignored as the model is bind mounted.
+func parseMountFlags(ctx context.Context, flags []string) (bool, *mountOwner, error) {
+	readOnly := false
+	owner := &mountOwner{uid: -1, gid: -1}
+	for _, flag := range flags {
+		for _, option := range strings.Split(flag, ",") {
+			option = strings.TrimSpace(option)
+			key, value, _ := strings.Cut(option, "=")
+			switch key {
+			case "":
+			case "ro":
+				readOnly = true
+			case "uid", "gid":
+				id, err := strconv.ParseUint(value, 10, 31)
+				if err != nil {
+					return false, nil, status.Errorf(codes.InvalidArgument, "invalid mount flag: %s", option)
+				}
+				if key == "uid" {
+					owner.uid = int(id)
+				} else {
+					owner.gid = int(id)
+				}
+			case "mode":
+				mode, err := strconv.ParseUint(value, 8, 32)
+				if err != nil || mode == 0 || mode > 0777 {
+					return false, nil, status.Errorf(codes.InvalidArgument, "invalid mount flag: %s", option)
+				}
+				owner.mode = os.FileMode(mode)
+			default:
+				logger.WithContext(ctx).Warnf("ignored unsupported mount flag: %s", option)
+			}
+		}
+	}
+	if owner.uid == -1 && owner.gid == -1 && owner.mode == 0 {
+		return readOnly, nil, nil
+	}
+	return readOnly, owner, nil
+}
+
+// applyMountOwner sets the ownership and the permission of the mount flags
+// to the model dir of the volume before it's published, the owner applied is
+// recorded in the status, and another owner is rejected while the volume is
+// published to other target paths.
+func (s *Service) applyMountOwner(ctx context.Context, volumeStatus *modelStatus.Status, modelDir, targetPath string, owner *mountOwner) error {
+	if owner == nil {
+		return nil
+	}
+	// The model files hardlinked to the blobs are shared by the volumes of
+	// the same model.
+	if s.cfg.Get().Features.SharedBlobStore {
+		if owner.groupOnly {
+			logger.WithContext(ctx).Warnf("volume mount group is ignored with the shared blob store")
+			return nil
+		}
+		return status.Error(codes.InvalidArgument, "mount flags uid, gid and mode are not supported with the shared blob store")
+	}
+	for _, target := range volumeStatus.Targets {
+		if target.Path != targetPath && volumeStatus.MountOwner != owner.String() {
+			return status.Errorf(
+				codes.FailedPrecondition, "volume is published to %s with another owner: %s", target.Path, volumeStatus.MountOwner,
+			)
+		}
+	}
+
+	if err := chownModelDir(modelDir, owner); err != nil {
+		return status.Error(codes.Internal, errors.Wrapf(err, "apply mount owner %s", owner).Error())
+	}
+	volumeStatus.MountOwner = owner.String()
+	logger.WithContext(ctx).Infof("applied mount owner %s to %s", owner, modelDir)
+
+	return nil
+}
+
+// parseMountGroup parses the volume mount group of the volume capability,
+// i.e. the fsGroup of the pod, which conflicts with another gid mount flag.
+func parseMountGroup(mountGroup string, owner *mountOwner) (*mountOwner, error) {
+	if mountGroup == "" {
+		return owner, nil
+	}
+	gid, err := strconv.ParseUint(mountGroup, 10, 31)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid volume mount group: %s", mountGroup)
+	}
+	if owner == nil {
+		owner = &mountOwner{uid: -1, gid: -1, groupOnly: true}
+	}
+	if owner.gid != -1 && owner.gid != int(gid) {
+		return nil, status.Errorf(codes.InvalidArgument, "volume mount group %s conflicts with mount flag gid=%d", mountGroup, owner.gid)
+	}
+	owner.gid = int(gid)
+	owner.groupRead = true
+	return owner, nil
+}
+
+// chownModelDir sets the owner to the files and the dirs of the model dir,
+// the dirs get the execute bits of the read bits of the mode to be entered.
+func chownModelDir(modelDir string, owner *mountOwner) error {
+	return filepath.WalkDir(modelDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if owner.uid != -1 || owner.gid != -1 {
+			if err := os.Lchown(path, owner.uid, owner.gid); err != nil {
+				return errors.Wrapf(err, "chown %s", path)
+			}
+		}
+		if (owner.mode == 0 && !owner.groupRead) || entry.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		mode := owner.mode
+		if mode == 0 {
+			info, err := entry.Info()
+			if err != nil {
+				return errors.Wrapf(err, "stat %s", path)
+			}
+			mode = info.Mode().Perm()
+		}
+		if owner.groupRead {
+			mode |= 0040
+		}
+		if entry.IsDir() {
+			mode |= (mode & 0444) >> 2
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			return errors.Wrapf(err, "chmod %s", path)
+		}
+		return nil
+	})
+}
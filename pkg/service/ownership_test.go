@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+func TestParseMountFlags(t *testing.T) {
+	ctx := context.Background()
+
+	readOnly, owner, err := parseMountFlags(ctx, []string{"noatime", "uid=1000,gid=2000", "mode=0440", "ro"})
+	require.NoError(t, err)
+	require.True(t, readOnly)
+	require.Equal(t, &mountOwner{uid: 1000, gid: 2000, mode: 0440}, owner)
+	require.Equal(t, "uid=1000,gid=2000,mode=0440", owner.String())
+
+	readOnly, owner, err = parseMountFlags(ctx, []string{"noatime"})
+	require.NoError(t, err)
+	require.False(t, readOnly)
+	require.Nil(t, owner)
+
+	_, owner, err = parseMountFlags(ctx, []string{"gid=1000"})
+	require.NoError(t, err)
+	require.Equal(t, &mountOwner{uid: -1, gid: 1000}, owner)
+
+	for _, flag := range []string{"uid=-1", "gid=abc", "mode=0", "mode=1777", "mode=9"} {
+		_, _, err := parseMountFlags(ctx, []string{flag})
+		require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err), flag)
+	}
+
+	// The flags are parsed from the volume capability of the publish.
+	access, err := newPublishAccess(ctx, &csi.NodePublishVolumeRequest{
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{MountFlags: []string{"ro", "mode=0444"}}},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, publishAccess{readOnly: true, owner: &mountOwner{uid: -1, gid: -1, mode: 0444}}, access)
+}
+
+func TestApplyMountOwner(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+
+	modelDir := filepath.Join(t.TempDir(), "model")
+	require.NoError(t, os.MkdirAll(filepath.Join(modelDir, "tokenizer"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(modelDir, "tokenizer", "vocab.json"), []byte("{}"), 0600))
+	require.NoError(t, os.Symlink("tokenizer/vocab.json", filepath.Join(modelDir, "vocab.json")))
+	// The dirs made read-only are removed with the temp dir.
+	t.Cleanup(func() {
+		_ = os.Chmod(modelDir, 0750)
+		_ = os.Chmod(filepath.Join(modelDir, "tokenizer"), 0750)
+	})
+
+	// The owner of the current user is always permitted.
+	owner := &mountOwner{uid: os.Getuid(), gid: os.Getgid(), mode: 0440}
+	volumeStatus := &modelStatus.Status{VolumeName: "pvc-owner"}
+	require.NoError(t, svc.applyMountOwner(ctx, volumeStatus, modelDir, "/target-1", owner))
+	require.Equal(t, owner.String(), volumeStatus.MountOwner)
+	info, err := os.Stat(filepath.Join(modelDir, "tokenizer", "vocab.json"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0440), info.Mode().Perm())
+	info, err = os.Stat(filepath.Join(modelDir, "tokenizer"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0550), info.Mode().Perm())
+
+	// Another owner is rejected while the volume is published to another
+	// target path.
+	volumeStatus.AddTarget(modelStatus.Target{Path: "/target-1"})
+	require.NoError(t, svc.applyMountOwner(ctx, volumeStatus, modelDir, "/target-2", owner))
+	err = svc.applyMountOwner(ctx, volumeStatus, modelDir, "/target-2", &mountOwner{uid: -1, gid: -1, mode: 0444})
+	require.Equal(t, codes.FailedPrecondition, grpcStatus.Code(err))
+	require.NoError(t, svc.applyMountOwner(ctx, volumeStatus, modelDir, "/target-1", &mountOwner{uid: -1, gid: -1, mode: 0444}))
+
+	svc.cfg.Get().Features.SharedBlobStore = true
+	err = svc.applyMountOwner(ctx, volumeStatus, modelDir, "/target-1", owner)
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+}
+
+func TestMountGroup(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+
+	owner, err := parseMountGroup("", nil)
+	require.NoError(t, err)
+	require.Nil(t, owner)
+	owner, err = parseMountGroup("2000", nil)
+	require.NoError(t, err)
+	require.Equal(t, &mountOwner{uid: -1, gid: 2000, groupRead: true, groupOnly: true}, owner)
+	require.Equal(t, "uid=-1,gid=2000,mode=0000,group-read", owner.String())
+	owner, err = parseMountGroup("2000", &mountOwner{uid: 1000, gid: 2000, mode: 0400})
+	require.NoError(t, err)
+	require.Equal(t, &mountOwner{uid: 1000, gid: 2000, mode: 0400, groupRead: true}, owner)
+	_, err = parseMountGroup("3000", &mountOwner{uid: -1, gid: 2000})
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+	_, err = parseMountGroup("abc", nil)
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+
+	// The group read permission is added to the permission of the files.
+	modelDir := filepath.Join(t.TempDir(), "model")
+	require.NoError(t, os.MkdirAll(filepath.Join(modelDir, "tokenizer"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(modelDir, "tokenizer", "vocab.json"), []byte("{}"), 0600))
+	owner = &mountOwner{uid: -1, gid: os.Getgid(), groupRead: true, groupOnly: true}
+	require.NoError(t, svc.applyMountOwner(ctx, &modelStatus.Status{}, modelDir, "/target", owner))
+	info, err := os.Stat(filepath.Join(modelDir, "tokenizer", "vocab.json"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	info, err = os.Stat(filepath.Join(modelDir, "tokenizer"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0750), info.Mode().Perm())
+
+	// The volume mount group is ignored with the shared blob store.
+	svc.cfg.Get().Features.SharedBlobStore = true
+	volumeStatus := &modelStatus.Status{}
+	require.NoError(t, svc.applyMountOwner(ctx, volumeStatus, modelDir, "/target", owner))
+	require.Empty(t, volumeStatus.MountOwner)
+
+	access, err := newPublishAccess(ctx, &csi.NodePublishVolumeRequest{
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{VolumeMountGroup: "2000"}},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, publishAccess{owner: &mountOwner{uid: -1, gid: 2000, groupRead: true, groupOnly: true}}, access)
+}
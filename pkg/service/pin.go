@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type PinRequest struct {
+	Reference string `json:"reference"`
+}
+
+// pinnedReferences returns the references of the models pinned on the node,
+// the cached copies of which are never evicted.
+func (worker *Worker) pinnedReferences() (map[string]bool, error) {
+	pinsPath := worker.cfg.Get().GetPinsPath()
+	data, err := os.ReadFile(pinsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]bool{}, nil
+		}
+		return nil, errors.Wrapf(err, "read pins: %s", pinsPath)
+	}
+	references := []string{}
+	if err := json.Unmarshal(data, &references); err != nil {
+		return nil, errors.Wrapf(err, "unmarshal pins: %s", pinsPath)
+	}
+	pins := map[string]bool{}
+	for _, reference := range references {
+		pins[reference] = true
+	}
+	return pins, nil
+}
+
+// updatePins applies the update to the pinned references and saves them, it
+// returns false if the update is a no-op.
+func (worker *Worker) updatePins(update func(pins map[string]bool) bool) (bool, error) {
+	worker.pinMutex.Lock()
+	defer worker.pinMutex.Unlock()
+
+	pins, err := worker.pinnedReferences()
+	if err != nil {
+		return false, err
+	}
+	if !update(pins) {
+		return false, nil
+	}
+
+	references := []string{}
+	for reference := range pins {
+		references = append(references, reference)
+	}
+	sort.Strings(references)
+	data, err := json.Marshal(references)
+	if err != nil {
+		return false, errors.Wrap(err, "marshal pins")
+	}
+
+	pinsPath := worker.cfg.Get().GetPinsPath()
+	if err := os.MkdirAll(filepath.Dir(pinsPath), 0755); err != nil {
+		return false, errors.Wrapf(err, "create dir: %s", filepath.Dir(pinsPath))
+	}
+	tmpPath := pinsPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return false, errors.Wrapf(err, "write pins: %s", tmpPath)
+	}
+	if err := os.Rename(tmpPath, pinsPath); err != nil {
+		return false, errors.Wrapf(err, "rename pins: %s", pinsPath)
+	}
+
+	return true, nil
+}
+
+// markPinned sets the pinned flag of the statuses by the pinned references,
+// the statuses are left as is if the pins can't be read.
+func (worker *Worker) markPinned(ctx context.Context, statuses []modelStatus.Status) {
+	pins, err := worker.pinnedReferences()
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("failed to get pinned models")
+		return
+	}
+	for idx := range statuses {
+		statuses[idx].Pinned = pins[statuses[idx].Reference]
+	}
+}
+
+// withPinned returns a copy of the status with the pinned flag and the scrub
+// result set, so that the status cached by the status manager isn't modified.
+func (worker *Worker) withPinned(ctx context.Context, st *modelStatus.Status) *modelStatus.Status {
+	if st == nil {
+		return nil
+	}
+	statuses := []modelStatus.Status{*st}
+	worker.markPinned(ctx, statuses)
+	worker.markScrubbed(statuses)
+	return &statuses[0]
+}
+
+// PinModel pins the model of the reference on the node, so that the cached
+// copies of the model (e.g. prefetched) are never evicted by the eviction or
+// the GC. The model isn't pulled by the pin.
+func (s *Service) PinModel(ctx context.Context, reference string) error {
+	ctx = logger.NewContext(ctx, "PinModel", "", "")
+	reference = strings.TrimSpace(reference)
+	if reference == "" {
+		return status.Error(codes.InvalidArgument, "missing required parameter: reference")
+	}
+
+	pinned, err := s.worker.updatePins(func(pins map[string]bool) bool {
+		if pins[reference] {
+			return false
+		}
+		pins[reference] = true
+		return true
+	})
+	if err != nil {
+		return status.Error(codes.Internal, errors.Wrap(err, "pin model").Error())
+	}
+	if pinned {
+		logger.WithContext(ctx).Infof("pinned model: %s", reference)
+	}
+
+	return nil
+}
+
+// UnpinModel unpins the model of the reference, so that its cached copies
+// can be evicted again.
+func (s *Service) UnpinModel(ctx context.Context, reference string) error {
+	ctx = logger.NewContext(ctx, "UnpinModel", "", "")
+	reference = strings.TrimSpace(reference)
+	if reference == "" {
+		return status.Error(codes.InvalidArgument, "missing required parameter: reference")
+	}
+
+	unpinned, err := s.worker.updatePins(func(pins map[string]bool) bool {
+		if !pins[reference] {
+			return false
+		}
+		delete(pins, reference)
+		return true
+	})
+	if err != nil {
+		return status.Error(codes.Internal, errors.Wrap(err, "unpin model").Error())
+	}
+	if !unpinned {
+		return status.Errorf(codes.NotFound, "pin not found: %s", reference)
+	}
+	logger.WithContext(ctx).Infof("unpinned model: %s", reference)
+
+	return nil
+}
+
+// ListPins returns the references of the pinned models, sorted by the
+// reference.
+func (s *Service) ListPins(ctx context.Context) ([]string, error) {
+	pins, err := s.worker.pinnedReferences()
+	if err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "list pins").Error())
+	}
+	references := []string{}
+	for reference := range pins {
+		references = append(references, reference)
+	}
+	sort.Strings(references)
+
+	return references, nil
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPinModel(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+
+	references, err := svc.ListPins(ctx)
+	require.NoError(t, err)
+	require.Empty(t, references)
+
+	require.NoError(t, svc.PinModel(ctx, "test/b:latest"))
+	require.NoError(t, svc.PinModel(ctx, "test/a:latest"))
+	// Pinning the pinned model is a no-op.
+	require.NoError(t, svc.PinModel(ctx, "test/a:latest"))
+	references, err = svc.ListPins(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"test/a:latest", "test/b:latest"}, references)
+
+	require.NoError(t, svc.UnpinModel(ctx, "test/a:latest"))
+	err = svc.UnpinModel(ctx, "test/a:latest")
+	require.Equal(t, codes.NotFound, status.Code(err))
+	references, err = svc.ListPins(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"test/b:latest"}, references)
+
+	require.Equal(t, codes.InvalidArgument, status.Code(svc.PinModel(ctx, " ")))
+}
+
+func TestPinModel_Eviction(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	cfg := svc.cfg.Get()
+	cfg.Features.DiskUsageLimit = 100 << 20
+	cfg.Features.Eviction = config.EvictionConfig{Enabled: true, Policy: config.EvictionPolicyLRU}
+
+	now := time.Now()
+	newPrefetchedModel(t, svc, "prefetch-pinned", "test/pinned:latest", 1<<20, now.Add(-2*time.Hour))
+	newPrefetchedModel(t, svc, "prefetch-model", "test/model:latest", 1<<20, now.Add(-time.Hour))
+	require.NoError(t, svc.PinModel(ctx, "test/pinned:latest"))
+
+	statuses, err := svc.ListPrefetches(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	require.Equal(t, "prefetch-model", statuses[0].VolumeName)
+	require.False(t, statuses[0].Pinned)
+	require.Equal(t, "prefetch-pinned", statuses[1].VolumeName)
+	require.True(t, statuses[1].Pinned)
+
+	// The pinned model is skipped by both the eviction and the GC.
+	evicted, err := svc.worker.evictModels(ctx, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, evicted)
+	require.NoDirExists(t, cfg.GetVolumeDir("prefetch-model"))
+	require.DirExists(t, cfg.GetVolumeDir("prefetch-pinned"))
+
+	resp, err := svc.GC(ctx, GCRequest{MaxAgeInSeconds: 1})
+	require.NoError(t, err)
+	require.Empty(t, resp.Models)
+
+	require.NoError(t, svc.UnpinModel(ctx, "test/pinned:latest"))
+	resp, err = svc.GC(ctx, GCRequest{MaxAgeInSeconds: 1})
+	require.NoError(t, err)
+	require.Len(t, resp.Models, 1)
+	require.NoDirExists(t, cfg.GetVolumeDir("prefetch-pinned"))
+}
+
+func TestDynamicServerHandler_Pins(t *testing.T) {
+	h, _ := newHandler(t)
+
+	c, rec := newHandlerContextWithParam(t, http.MethodPost, "/api/v1/pins", `{"reference":"test/model:latest"}`, nil, nil)
+	require.NoError(t, h.PinModel(c))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	c, rec = newHandlerContextWithParam(t, http.MethodGet, "/api/v1/pins", "", nil, nil)
+	require.NoError(t, h.ListPins(c))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `["test/model:latest"]`, rec.Body.String())
+
+	c, rec = newHandlerContextWithParam(t, http.MethodDelete, "/api/v1/pins?reference=test/model:latest", "", nil, nil)
+	require.NoError(t, h.UnpinModel(c))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	c, rec = newHandlerContextWithParam(t, http.MethodDelete, "/api/v1/pins?reference=test/model:latest", "", nil, nil)
+	_ = h.UnpinModel(c)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
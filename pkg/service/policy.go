@@ -0,0 +1,258 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	// nolint
+	"github.com/containerd/containerd/reference/docker"
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultPolicyWebhookTimeout = 5 * time.Second
+
+// ErrPolicyDenied is returned if the model reference isn't allowed to be
+// mounted on the node by the admission policy.
+var ErrPolicyDenied = errors.New("denied by policy")
+
+// PolicyInput is the model reference to be admitted, it's posted to the
+// policy webhook as the input.
+type PolicyInput struct {
+	Type      string `json:"type"`
+	Reference string `json:"reference"`
+	// The reference without the tag, digest or revision, which is matched
+	// by the rules, e.g. registry.example.com/models/qwen3.
+	Name       string `json:"name"`
+	VolumeName string `json:"volume_name"`
+	MountID    string `json:"mount_id,omitempty"`
+	NodeID     string `json:"node_id"`
+}
+
+// Policy decides if the model reference is allowed to be mounted on the
+// node, the denied reference is reported by ErrPolicyDenied.
+type Policy interface {
+	Admit(ctx context.Context, input *PolicyInput) error
+}
+
+// NewPolicy creates the policy of the builtin rules followed by the
+// webhook, it can be replaced to plug in the other policies.
+var NewPolicy = func(cfg *config.PolicyConfig) Policy {
+	policies := policyChain{&rulePolicy{allow: cfg.Allow, deny: cfg.Deny}}
+	if cfg.Webhook.URL != "" {
+		policies = append(policies, &webhookPolicy{cfg: cfg.Webhook, client: http.DefaultClient})
+	}
+	return policies
+}
+
+type policyChain []Policy
+
+func (policies policyChain) Admit(ctx context.Context, input *PolicyInput) error {
+	for _, policy := range policies {
+		if err := policy.Admit(ctx, input); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// policyName returns the name of the reference matched by the rules, e.g.
+// docker.io/library/model for "model:latest" or Qwen/Qwen3-0.6B for
+// "Qwen/Qwen3-0.6B@main".
+func policyName(modelType, reference string) (string, error) {
+	switch modelType {
+	case ModelTypeHuggingFace:
+		repo, _, err := parseHuggingFaceReference(reference)
+		return repo, err
+	case ModelTypeS3:
+		bucket, prefix, err := parseS3Reference(reference)
+		if err != nil {
+			return "", err
+		}
+		return "s3://" + path.Join(bucket, prefix), nil
+	case ModelTypeArchive:
+		return filepath.Clean(reference), nil
+	}
+	// nolint
+	named, err := docker.ParseDockerRef(reference)
+	if err != nil {
+		return "", errors.Wrapf(err, "parse reference: %s", reference)
+	}
+	return named.Name(), nil
+}
+
+// matchPolicyPattern matches the name against the glob pattern, the pattern
+// with the trailing "/**" matches all the names under the prefix.
+func matchPolicyPattern(pattern, name string) bool {
+	prefix, recursive := strings.CutSuffix(pattern, "/**")
+	if !recursive {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	}
+	segments := strings.Split(name, "/")
+	for i := 1; i < len(segments); i++ {
+		if matched, _ := path.Match(prefix, strings.Join(segments[:i], "/")); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPolicyRules(rules []config.PolicyRule, input *PolicyInput) bool {
+	for _, rule := range rules {
+		ruleType := rule.Type
+		if ruleType == "" {
+			ruleType = ModelTypeImage
+		}
+		if ruleType == input.Type && matchPolicyPattern(rule.Pattern, input.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// rulePolicy is the builtin policy of the allow and deny lists, the deny
+// rules take precedence.
+type rulePolicy struct {
+	allow []config.PolicyRule
+	deny  []config.PolicyRule
+}
+
+func (p *rulePolicy) Admit(ctx context.Context, input *PolicyInput) error {
+	if matchPolicyRules(p.deny, input) {
+		return errors.Wrapf(ErrPolicyDenied, "%s matches the deny rules", input.Name)
+	}
+	if len(p.allow) > 0 && !matchPolicyRules(p.allow, input) {
+		return errors.Wrapf(ErrPolicyDenied, "%s doesn't match the allow rules", input.Name)
+	}
+	return nil
+}
+
+type policyWebhookResult struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason"`
+}
+
+// webhookPolicy posts the input to the external policy, e.g. the OPA data
+// API, the undefined result is treated as denied.
+type webhookPolicy struct {
+	cfg    config.PolicyWebhook
+	client *http.Client
+}
+
+func (p *webhookPolicy) evaluate(ctx context.Context, input *PolicyInput) (*policyWebhookResult, error) {
+	timeout := defaultPolicyWebhookTimeout
+	if p.cfg.TimeoutInSeconds > 0 {
+		timeout = time.Duration(p.cfg.TimeoutInSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body, err := json.Marshal(map[string]*PolicyInput{"input": input})
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal policy input")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "create policy request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "request policy webhook")
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, errors.Errorf("unexpected status code of policy webhook: %d, body: %s", resp.StatusCode, string(data))
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, errors.Wrap(err, "decode policy response")
+	}
+	result := &policyWebhookResult{}
+	if len(response.Result) == 0 {
+		result.Reason = "undefined policy result"
+		return result, nil
+	}
+	if err := json.Unmarshal(response.Result, &result.Allow); err == nil {
+		return result, nil
+	}
+	if err := json.Unmarshal(response.Result, result); err != nil {
+		return nil, errors.Wrap(err, "unmarshal policy result")
+	}
+	return result, nil
+}
+
+func (p *webhookPolicy) Admit(ctx context.Context, input *PolicyInput) error {
+	result, err := p.evaluate(ctx, input)
+	if err != nil {
+		if p.cfg.FailOpen {
+			logger.WithContext(ctx).WithError(err).Warnf("policy webhook is unavailable, allow %s", input.Reference)
+			return nil
+		}
+		return err
+	}
+	if !result.Allow {
+		if result.Reason == "" {
+			result.Reason = "denied by webhook"
+		}
+		return errors.Wrapf(ErrPolicyDenied, "%s: %s", input.Name, result.Reason)
+	}
+	return nil
+}
+
+type policyAdmittedKey struct{}
+
+// withPolicyAdmitted marks the context as admitted by the policy, so that
+// the policy isn't evaluated again on CreateVolume of the dynamic mount.
+func withPolicyAdmitted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, policyAdmittedKey{}, true)
+}
+
+// admitModel evaluates the admission policy of the model reference, the
+// denied reference is returned as PermissionDenied.
+func (s *Service) admitModel(ctx context.Context, modelType, reference, volumeName, mountID string) error {
+	if admitted, _ := ctx.Value(policyAdmittedKey{}).(bool); admitted {
+		return nil
+	}
+	if isImageModelType(modelType) {
+		modelType = ModelTypeImage
+	}
+
+	name, err := policyName(modelType, reference)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	input := &PolicyInput{
+		Type:       modelType,
+		Reference:  reference,
+		Name:       name,
+		VolumeName: volumeName,
+		MountID:    mountID,
+		NodeID:     s.cfg.Get().NodeID,
+	}
+	if err := NewPolicy(&s.cfg.Get().Policy).Admit(ctx, input); err != nil {
+		if errors.Is(err, ErrPolicyDenied) {
+			logger.WithContext(ctx).WithError(err).Warnf("model %s is denied by policy", reference)
+			return status.Error(codes.PermissionDenied, err.Error())
+		}
+		return status.Error(codes.Unavailable, errors.Wrap(err, "evaluate policy").Error())
+	}
+
+	return nil
+}
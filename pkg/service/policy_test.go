@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+func TestPolicyName(t *testing.T) {
+	for _, tc := range []struct {
+		modelType string
+		reference string
+		name      string
+	}{
+		{ModelTypeImage, "registry.example.com/models/qwen3:latest", "registry.example.com/models/qwen3"},
+		{ModelTypeImage, "qwen3@sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "docker.io/library/qwen3"},
+		{ModelTypeHuggingFace, "Qwen/Qwen3-0.6B@main", "Qwen/Qwen3-0.6B"},
+		{ModelTypeS3, "s3://models/qwen3/", "s3://models/qwen3"},
+		{ModelTypeArchive, "qwen3/../qwen3.tar.gz", "qwen3.tar.gz"},
+	} {
+		name, err := policyName(tc.modelType, tc.reference)
+		require.NoError(t, err)
+		require.Equal(t, tc.name, name)
+	}
+}
+
+func TestMatchPolicyPattern(t *testing.T) {
+	require.True(t, matchPolicyPattern("registry.example.com/models/*", "registry.example.com/models/qwen3"))
+	require.False(t, matchPolicyPattern("registry.example.com/models/*", "registry.example.com/models/qwen/qwen3"))
+	require.True(t, matchPolicyPattern("registry.example.com/models/**", "registry.example.com/models/qwen/qwen3"))
+	require.True(t, matchPolicyPattern("*.example.com/**", "registry.example.com/models/qwen3"))
+	require.False(t, matchPolicyPattern("registry.example.com/models/**", "registry.example.com/models"))
+	require.False(t, matchPolicyPattern("registry.example.com/models/**", "registry.example.com/modelsx/qwen3"))
+	require.True(t, matchPolicyPattern("s3://models/**", "s3://models/qwen3"))
+}
+
+func TestAdmitModel_Rules(t *testing.T) {
+	svc, _ := newNodeService(t)
+	svc.cfg.Get().Policy = config.PolicyConfig{
+		Allow: []config.PolicyRule{
+			{Pattern: "registry.example.com/models/**"},
+			{Type: ModelTypeHuggingFace, Pattern: "Qwen/*"},
+		},
+		Deny: []config.PolicyRule{{Pattern: "registry.example.com/models/untrusted/**"}},
+	}
+	ctx := context.Background()
+
+	require.NoError(t, svc.admitModel(ctx, "", "registry.example.com/models/qwen3:latest", "vol", ""))
+	require.NoError(t, svc.admitModel(ctx, ModelTypeHuggingFace, "Qwen/Qwen3-0.6B", "vol", ""))
+
+	err := svc.admitModel(ctx, ModelTypeImage, "registry.example.com/models/untrusted/qwen3:latest", "vol", "")
+	require.Equal(t, codes.PermissionDenied, grpcStatus.Code(err))
+	require.Contains(t, err.Error(), "matches the deny rules")
+
+	err = svc.admitModel(ctx, ModelTypeImage, "docker.io/qwen3:latest", "vol", "")
+	require.Equal(t, codes.PermissionDenied, grpcStatus.Code(err))
+	require.Contains(t, err.Error(), "doesn't match the allow rules")
+
+	// The rules are matched by the model type.
+	err = svc.admitModel(ctx, ModelTypeS3, "s3://registry.example.com/models/qwen3", "vol", "")
+	require.Equal(t, codes.PermissionDenied, grpcStatus.Code(err))
+
+	err = svc.admitModel(ctx, ModelTypeImage, "Invalid Reference", "vol", "")
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+
+	// The admitted context skips the evaluation.
+	require.NoError(t, svc.admitModel(withPolicyAdmitted(ctx), ModelTypeImage, "docker.io/qwen3:latest", "vol", ""))
+}
+
+func TestAdmitModel_Webhook(t *testing.T) {
+	var inputs []PolicyInput
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input PolicyInput `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		inputs = append(inputs, body.Input)
+		switch body.Input.Name {
+		case "docker.io/library/allowed":
+			_, _ = w.Write([]byte(`{"result":true}`))
+		case "docker.io/library/denied":
+			_, _ = w.Write([]byte(`{"result":{"allow":false,"reason":"not approved"}}`))
+		case "docker.io/library/undefined":
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	svc, _ := newNodeService(t)
+	svc.cfg.Get().Policy = config.PolicyConfig{Webhook: config.PolicyWebhook{URL: server.URL}}
+	ctx := context.Background()
+
+	require.NoError(t, svc.admitModel(ctx, "", "allowed:latest", "vol", "mount"))
+	require.Equal(t, PolicyInput{
+		Type:       ModelTypeImage,
+		Reference:  "allowed:latest",
+		Name:       "docker.io/library/allowed",
+		VolumeName: "vol",
+		MountID:    "mount",
+		NodeID:     "test-node-1",
+	}, inputs[0])
+
+	err := svc.admitModel(ctx, "", "denied:latest", "vol", "")
+	require.Equal(t, codes.PermissionDenied, grpcStatus.Code(err))
+	require.Contains(t, err.Error(), "not approved")
+
+	err = svc.admitModel(ctx, "", "undefined:latest", "vol", "")
+	require.Equal(t, codes.PermissionDenied, grpcStatus.Code(err))
+
+	// The unavailable webhook fails closed unless fail open.
+	err = svc.admitModel(ctx, "", "error:latest", "vol", "")
+	require.Equal(t, codes.Unavailable, grpcStatus.Code(err))
+	svc.cfg.Get().Policy.Webhook.FailOpen = true
+	require.NoError(t, svc.admitModel(ctx, "", "error:latest", "vol", ""))
+}
+
+func TestDynamicServerHandler_CreateVolume_PolicyDenied(t *testing.T) {
+	h, svc := newHandler(t)
+	svc.cfg.Get().Policy = config.PolicyConfig{Deny: []config.PolicyRule{{Pattern: "docker.io/test/*"}}}
+	c, rec := newHandlerContextWithParam(t, http.MethodPost, "/", `{"mount_id":"m1","reference":"test/model:latest"}`,
+		[]string{"volume_name"}, []string{"my-volume"})
+	_ = h.CreateVolume(c)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, ERR_CODE_POLICY_DENIED, resp.Code)
+}
+
+func TestDynamicServerHandler_CreateVolume_AdapterPolicyDenied(t *testing.T) {
+	h, svc := newHandler(t)
+	svc.cfg.Get().Policy = config.PolicyConfig{Deny: []config.PolicyRule{{Pattern: "docker.io/untrusted/*"}}}
+	c, rec := newHandlerContextWithParam(t, http.MethodPost, "/", `{"mount_id":"m1","reference":"test/model:latest","adapters":[{"reference":"untrusted/lora:v1"}]}`,
+		[]string{"volume_name"}, []string{"my-volume"})
+	_ = h.CreateVolume(c)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, ERR_CODE_POLICY_DENIED, resp.Code)
+	require.Contains(t, resp.Message, "docker.io/untrusted/lora")
+}
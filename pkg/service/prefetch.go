@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/metrics"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The prefix of the volume dirs holding the models prefetched without a
+// volume, e.g. "prefetch-0123456789abcdef".
+const prefetchVolumePrefix = "prefetch-"
+
+// The priority of the prefetch in the node-wide pull queue by default, so that
+// the pulls of the mounts are admitted first.
+const defaultPrefetchPriority = -1
+
+func isPrefetchVolume(volumeName string) bool {
+	return strings.HasPrefix(volumeName, prefetchVolumePrefix)
+}
+
+type PrefetchRequest struct {
+	// The model type, "image" by default.
+	Type      string `json:"type"`
+	Reference string `json:"reference"`
+	// The platform selected from the image index, e.g. "linux/arm64", the
+	// node platform by default.
+	Platform string `json:"platform"`
+	// The priority in the node-wide pull queue, -1 by default.
+	Priority *int `json:"priority,omitempty"`
+}
+
+// prefetchName returns the name of the prefetch by the pull key of the model,
+// so that the prefetches of the same model are deduplicated.
+func prefetchName(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return prefetchVolumePrefix + hex.EncodeToString(hash[:])[:16]
+}
+
+func (s *Service) prefetch(ctx context.Context, req PrefetchRequest) (*modelStatus.Status, error) {
+	reference := strings.TrimSpace(req.Reference)
+	if reference == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing required parameter: reference")
+	}
+	if !isSupportedModelType(req.Type) {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported model type: %s", req.Type)
+	}
+	platform := strings.TrimSpace(req.Platform)
+	if platform != "" {
+		if _, err := parsePlatform(platform); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid platform: %v", err)
+		}
+	}
+	if err := s.admitModel(ctx, req.Type, reference, "", ""); err != nil {
+		return nil, err
+	}
+
+	opts := PullOptions{Type: req.Type, Priority: defaultPrefetchPriority}
+	if req.Priority != nil {
+		opts.Priority = *req.Priority
+	}
+	// The model is pinned to the digest the same as the volumes, so that the
+	// volumes created later find the prefetched model by the pull key.
+	if isImageModelType(req.Type) {
+		if platform == "" {
+			platform = defaultPlatform()
+		}
+		resolved, err := ResolveDigest(ctx, &s.cfg.Get().PullConfig, reference, platform)
+		if err != nil {
+			return nil, status.Error(codes.Internal, errors.Wrap(err, "resolve model digest").Error())
+		}
+		opts.Digest = resolved.Digest.String()
+		if resolved.Platform != nil {
+			opts.Platform = formatPlatform(resolved.Platform)
+		}
+	}
+
+	name := prefetchName(pullKey(pinReference(reference, opts.Digest), opts))
+	modelDir := s.cfg.Get().GetModelDir(name)
+	statusPath := filepath.Join(filepath.Dir(modelDir), "status.json")
+	if prefetchStatus, err := s.sm.Get(statusPath); err == nil {
+		switch prefetchStatus.State {
+		case modelStatus.StatePullSucceeded:
+			return prefetchStatus, nil
+		case modelStatus.StatePullQueued, modelStatus.StatePullRunning:
+			// The pull interrupted by the driver restart is started again.
+			if s.worker.contextMap.Get(name+"/") != nil {
+				return prefetchStatus, nil
+			}
+		}
+	}
+
+	prefetchStatus, err := s.sm.Set(statusPath, modelStatus.Status{
+		VolumeName: name,
+		Reference:  reference,
+		Digest:     opts.Digest,
+		Platform:   opts.Platform,
+		State:      modelStatus.StatePullQueued,
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "set prefetch status").Error())
+	}
+
+	// The pull outlives the request, it's canceled by CancelPrefetch.
+	pullCtx := context.WithoutCancel(ctx)
+	go func() {
+		err := s.worker.PullModel(pullCtx, true, name, "", reference, modelDir, opts)
+		if err == nil {
+			logger.WithContext(pullCtx).Infof("prefetched model: %s", reference)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			logger.WithContext(pullCtx).WithError(err).Infof("prefetch canceled: %s", reference)
+			return
+		}
+		logger.WithContext(pullCtx).WithError(err).Errorf("failed to prefetch model: %s", reference)
+		// Keep the failed prefetch in the list, the failed pull is cleaned up
+		// by the worker unless it can be resumed.
+		state := modelStatus.StatePullFailed
+		if errors.Is(err, context.DeadlineExceeded) {
+			state = modelStatus.StatePullTimeout
+		}
+		if _, err := s.sm.Set(statusPath, modelStatus.Status{
+			VolumeName: name,
+			Reference:  reference,
+			Digest:     opts.Digest,
+			Platform:   opts.Platform,
+			State:      state,
+		}); err != nil {
+			logger.WithContext(pullCtx).WithError(err).Errorf("failed to set prefetch status")
+		}
+	}()
+
+	return prefetchStatus, nil
+}
+
+// Prefetch pulls the model into the node without creating a volume, so that
+// the nodes are warmed ahead of the rollout, the volumes of the same model
+// created later are cloned from the prefetched model instead of pulling it.
+// The model is pulled in the background, the status of the prefetch is
+// returned.
+func (s *Service) Prefetch(ctx context.Context, req PrefetchRequest) (*modelStatus.Status, error) {
+	start := time.Now()
+	ctx = logger.NewContext(ctx, "Prefetch", "", "")
+	prefetchStatus, err := s.prefetch(ctx, req)
+	metrics.NodeOpObserve("prefetch", start, err)
+	return s.worker.withPinned(ctx, prefetchStatus), err
+}
+
+// ListPrefetches returns the status of the prefetches on the node, sorted by
+// the name.
+func (s *Service) ListPrefetches(ctx context.Context) ([]modelStatus.Status, error) {
+	volumesDir := s.cfg.Get().GetVolumesDir()
+	entries, err := os.ReadDir(volumesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []modelStatus.Status{}, nil
+		}
+		return nil, errors.Wrapf(err, "read volume dirs from %s", volumesDir)
+	}
+
+	statuses := []modelStatus.Status{}
+	for _, entry := range entries {
+		if !isVolumeDirEntry(volumesDir, entry) || !isPrefetchVolume(entry.Name()) {
+			continue
+		}
+		statusPath := filepath.Join(s.cfg.Get().GetVolumeDir(entry.Name()), "status.json")
+		prefetchStatus, err := s.sm.Get(statusPath)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				logger.WithContext(ctx).WithError(err).Errorf("failed to get prefetch status: %s", statusPath)
+			}
+			continue
+		}
+		statuses = append(statuses, *prefetchStatus)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].VolumeName < statuses[j].VolumeName
+	})
+	s.worker.markPinned(ctx, statuses)
+	s.worker.markScrubbed(statuses)
+
+	return statuses, nil
+}
+
+// CancelPrefetch cancels the pull of the prefetch and removes the prefetched
+// model, the volumes cloned from it are kept.
+func (s *Service) CancelPrefetch(ctx context.Context, name string) error {
+	ctx = logger.NewContext(ctx, "CancelPrefetch", name, "")
+	if !isPrefetchVolume(name) || !checkIdentifier(name) {
+		return status.Errorf(codes.InvalidArgument, "invalid prefetch name: %s", name)
+	}
+	statusPath := filepath.Join(s.cfg.Get().GetVolumeDir(name), "status.json")
+	if _, err := s.sm.Get(statusPath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return status.Errorf(codes.NotFound, "prefetch not found: %s", name)
+		}
+		return status.Error(codes.Internal, errors.Wrap(err, "get prefetch status").Error())
+	}
+
+	if err := s.worker.DeleteModel(ctx, true, name, ""); err != nil {
+		return status.Error(codes.Internal, errors.Wrap(err, "delete prefetched model").Error())
+	}
+	logger.WithContext(ctx).Infof("canceled prefetch: %s", name)
+
+	return nil
+}
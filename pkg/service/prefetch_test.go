@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+type countingPuller struct {
+	pulls atomic.Int32
+}
+
+func (p *countingPuller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	p.pulls.Add(1)
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(targetDir, "model"), []byte(reference), 0644)
+}
+
+func mockResolveDigest(t *testing.T, dgst digest.Digest) {
+	origResolveDigest := ResolveDigest
+	ResolveDigest = func(ctx context.Context, pullCfg *config.PullConfig, reference, platform string) (ocispec.Descriptor, error) {
+		parsed, err := parsePlatform(platform)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		return ocispec.Descriptor{Digest: dgst, Platform: parsed}, nil
+	}
+	t.Cleanup(func() { ResolveDigest = origResolveDigest })
+}
+
+func TestPrefetch(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	dgst := digest.FromString("manifest")
+	mockResolveDigest(t, dgst)
+	puller := &countingPuller{}
+	svc.worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return puller
+	}
+
+	prefetchStatus, err := svc.Prefetch(ctx, PrefetchRequest{Type: ModelTypeImage, Reference: "test/model:latest"})
+	require.NoError(t, err)
+	require.True(t, isPrefetchVolume(prefetchStatus.VolumeName))
+	require.Equal(t, dgst.String(), prefetchStatus.Digest)
+
+	name := prefetchStatus.VolumeName
+	statusPath := filepath.Join(svc.cfg.Get().GetVolumeDir(name), "status.json")
+	require.Eventually(t, func() bool {
+		prefetchStatus, err := svc.sm.Get(statusPath)
+		return err == nil && prefetchStatus.State == status.StatePullSucceeded
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The prefetch of the same model is deduplicated.
+	prefetchStatus, err = svc.Prefetch(ctx, PrefetchRequest{Reference: "test/model:latest"})
+	require.NoError(t, err)
+	require.Equal(t, name, prefetchStatus.VolumeName)
+	require.Equal(t, status.StatePullSucceeded, prefetchStatus.State)
+
+	prefetches, err := svc.ListPrefetches(ctx)
+	require.NoError(t, err)
+	require.Len(t, prefetches, 1)
+	require.Equal(t, name, prefetches[0].VolumeName)
+
+	// The volume of the same model is cloned from the prefetched model.
+	modelDir := svc.cfg.Get().GetModelDir("pvc-prefetched")
+	require.NoError(t, svc.worker.PullModel(ctx, true, "pvc-prefetched", "", "test/model:latest", modelDir, PullOptions{
+		Type:     ModelTypeImage,
+		Digest:   dgst.String(),
+		Platform: defaultPlatform(),
+	}))
+	require.Equal(t, int32(1), puller.pulls.Load())
+	require.FileExists(t, filepath.Join(modelDir, "model"))
+
+	// The volume is kept after the prefetch is canceled.
+	require.NoError(t, svc.CancelPrefetch(ctx, name))
+	require.NoDirExists(t, svc.cfg.Get().GetVolumeDir(name))
+	require.FileExists(t, filepath.Join(modelDir, "model"))
+	require.Equal(t, codes.NotFound, grpcStatus.Code(svc.CancelPrefetch(ctx, name)))
+
+	prefetches, err = svc.ListPrefetches(ctx)
+	require.NoError(t, err)
+	require.Empty(t, prefetches)
+}
+
+func TestPrefetch_InvalidRequest(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+
+	_, err := svc.Prefetch(ctx, PrefetchRequest{Type: ModelTypeImage})
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+	_, err = svc.Prefetch(ctx, PrefetchRequest{Type: "unknown", Reference: "test/model:latest"})
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+	_, err = svc.Prefetch(ctx, PrefetchRequest{Type: ModelTypeImage, Reference: "test/model:latest", Platform: "invalid/"})
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(svc.CancelPrefetch(ctx, "pvc-volume")))
+}
+
+func TestDynamicServerHandler_Prefetch(t *testing.T) {
+	h, _ := newHandler(t)
+
+	c, rec := newHandlerContextWithParam(t, http.MethodPost, "/api/v1/prefetch", `{"reference":""}`, nil, nil)
+	require.NoError(t, h.Prefetch(c))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	c, rec = newHandlerContextWithParam(t, http.MethodGet, "/api/v1/prefetch", "", nil, nil)
+	require.NoError(t, h.ListPrefetches(c))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, "[]", rec.Body.String())
+
+	c, rec = newHandlerContextWithParam(t, http.MethodDelete, "/api/v1/prefetch/prefetch-0123456789abcdef", "", []string{"name"}, []string{"prefetch-0123456789abcdef"})
+	require.NoError(t, h.CancelPrefetch(c))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/config/auth"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+)
+
+const defaultPreheatTimeout = 600 * time.Second
+
+// The interval of polling the state of the preheat job.
+var preheatPollInterval = 3 * time.Second
+
+// The states of the job in the Dragonfly manager.
+const (
+	dragonflyJobSucceeded = "SUCCESS"
+	dragonflyJobFailed    = "FAILURE"
+)
+
+type dragonflyPreheatArgs struct {
+	Type     string `json:"type"`
+	URL      string `json:"url"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+type dragonflyJob struct {
+	ID    uint   `json:"id"`
+	State string `json:"state"`
+}
+
+// preheatPuller preheats the model image by the Dragonfly manager before or
+// while pulling it through Dragonfly, so that the layers are fetched from the
+// P2P network instead of the registry. The preheat is best effort, its
+// failure never fails the pull.
+type preheatPuller struct {
+	Puller
+	pullCfg *config.PullConfig
+	hook    *status.Hook
+	client  *http.Client
+}
+
+func (p *preheatPuller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	// The preheat is useless for the registry not pulled through Dragonfly.
+	if registryPullConfig(p.pullCfg, reference).DragonflyEndpoint == "" {
+		return p.Puller.Pull(ctx, reference, targetDir, excludeModelWeights, excludeFilePatterns)
+	}
+
+	timeout := defaultPreheatTimeout
+	if p.pullCfg.Preheat.TimeoutInSeconds > 0 {
+		timeout = time.Duration(p.pullCfg.Preheat.TimeoutInSeconds) * time.Second
+	}
+	preheatCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.preheat(preheatCtx, reference)
+	}()
+	if p.pullCfg.Preheat.Wait {
+		<-done
+	}
+
+	return p.Puller.Pull(ctx, reference, targetDir, excludeModelWeights, excludeFilePatterns)
+}
+
+// preheat creates the preheat job of the reference and waits for it, the
+// progress is recorded in the hook.
+func (p *preheatPuller) preheat(ctx context.Context, reference string) {
+	progress := status.PreheatProgress{
+		State:     status.PreheatRunning,
+		StartedAt: time.Now(),
+	}
+	p.hook.SetPreheat(progress)
+
+	err := func() error {
+		job, err := p.createJob(ctx, reference)
+		if err != nil {
+			return err
+		}
+		progress.JobID = job.ID
+		p.hook.SetPreheat(progress)
+		logger.WithContext(ctx).Infof("created preheat job %d of model: %s", job.ID, reference)
+
+		for job.State != dragonflyJobSucceeded {
+			if job.State == dragonflyJobFailed {
+				return errors.Errorf("preheat job %d failed", job.ID)
+			}
+			select {
+			case <-ctx.Done():
+				return errors.Wrapf(ctx.Err(), "wait for preheat job %d", job.ID)
+			case <-time.After(preheatPollInterval):
+			}
+			if job, err = p.getJob(ctx, job.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+
+	finishedAt := time.Now()
+	progress.FinishedAt = &finishedAt
+	progress.State = status.PreheatSucceeded
+	if err != nil {
+		// The pull may finish before the preheat.
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		logger.WithContext(ctx).WithError(err).Warnf("failed to preheat model: %s", reference)
+		progress.State = status.PreheatFailed
+		progress.Error = err.Error()
+	} else {
+		logger.WithContext(ctx).Infof("preheated model %s, duration: %s", reference, finishedAt.Sub(progress.StartedAt))
+	}
+	p.hook.SetPreheat(progress)
+}
+
+// preheatArgs returns the args of the preheat job of the model image, which
+// is preheated by the manifest URL with the registry credentials.
+func preheatArgs(ctx context.Context, pullCfg *config.PullConfig, reference string) (*dragonflyPreheatArgs, error) {
+	repo, err := newOCIRepository(pullCfg, reference)
+	if err != nil {
+		return nil, err
+	}
+	keyChain, ok, err := registrySecretsKeyChain(ctx, repo.Reference.Registry)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		keyChain, err = auth.GetKeyChainByRef(reference)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get auth for model: %s", reference)
+		}
+	}
+
+	scheme := "https"
+	if repo.PlainHTTP {
+		scheme = "http"
+	}
+	return &dragonflyPreheatArgs{
+		Type:     "image",
+		URL:      fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, repo.Reference.Registry, repo.Reference.Repository, repo.Reference.Reference),
+		Username: keyChain.Username,
+		Password: keyChain.Password,
+	}, nil
+}
+
+func (p *preheatPuller) createJob(ctx context.Context, reference string) (*dragonflyJob, error) {
+	args, err := preheatArgs(ctx, p.pullCfg, reference)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(map[string]any{"type": "preheat", "args": args})
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal preheat job")
+	}
+	return p.doJob(ctx, http.MethodPost, "/oapi/v1/jobs", body)
+}
+
+func (p *preheatPuller) getJob(ctx context.Context, id uint) (*dragonflyJob, error) {
+	return p.doJob(ctx, http.MethodGet, fmt.Sprintf("/oapi/v1/jobs/%d", id), nil)
+}
+
+func (p *preheatPuller) doJob(ctx context.Context, method, path string, body []byte) (*dragonflyJob, error) {
+	token, err := p.pullCfg.Preheat.GetToken()
+	if err != nil {
+		return nil, err
+	}
+
+	url := strings.TrimSuffix(p.pullCfg.Preheat.ManagerURL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "create preheat request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "request dragonfly manager")
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, errors.Errorf("unexpected status code of dragonfly manager: %d, body: %s", resp.StatusCode, string(data))
+	}
+
+	var job dragonflyJob
+	if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+		return nil, errors.Wrap(err, "decode preheat job")
+	}
+
+	return &job, nil
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeManager serves the preheat jobs of the Dragonfly manager, the job
+// ends in the state after it's polled once.
+func newFakeManager(t *testing.T, state string, requests *[]map[string]any) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/oapi/v1/jobs":
+			var body map[string]any
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			*requests = append(*requests, body)
+			_, _ = w.Write([]byte(`{"id":1,"state":"PENDING"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/oapi/v1/jobs/1":
+			_, _ = w.Write([]byte(`{"id":1,"state":"` + state + `"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func newPreheatPuller(t *testing.T, managerURL string) (*preheatPuller, *namedPuller) {
+	origInterval := preheatPollInterval
+	preheatPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { preheatPollInterval = origInterval })
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("token\n"), 0600))
+
+	inner := &namedPuller{}
+	return &preheatPuller{
+		Puller: inner,
+		pullCfg: &config.PullConfig{
+			DragonflyEndpoint: "unix:///var/run/dragonfly/dfdaemon.sock",
+			Preheat: config.PreheatConfig{
+				ManagerURL: managerURL,
+				TokenFile:  tokenFile,
+				Wait:       true,
+			},
+		},
+		hook:   status.NewHook(context.Background()),
+		client: http.DefaultClient,
+	}, inner
+}
+
+func TestPreheatPuller(t *testing.T) {
+	host := newFakeRegistry(t, ocispec.Manifest{}, map[digest.Digest][]byte{})
+	requests := []map[string]any{}
+	p, inner := newPreheatPuller(t, newFakeManager(t, dragonflyJobSucceeded, &requests))
+
+	reference := host + "/test/model:latest"
+	require.NoError(t, p.Pull(context.Background(), reference, filepath.Join(t.TempDir(), "model"), false, nil))
+	require.Equal(t, []string{reference}, inner.references)
+
+	require.Len(t, requests, 1)
+	require.Equal(t, "preheat", requests[0]["type"])
+	require.Equal(t, map[string]any{
+		"type": "image",
+		"url":  "http://" + host + "/v2/test/model/manifests/latest",
+	}, requests[0]["args"])
+
+	preheat := p.hook.GetProgress().Preheat
+	require.NotNil(t, preheat)
+	require.Equal(t, status.PreheatSucceeded, preheat.State)
+	require.Equal(t, uint(1), preheat.JobID)
+	require.NotNil(t, preheat.FinishedAt)
+}
+
+func TestPreheatPuller_Failed(t *testing.T) {
+	host := newFakeRegistry(t, ocispec.Manifest{}, map[digest.Digest][]byte{})
+	requests := []map[string]any{}
+	p, inner := newPreheatPuller(t, newFakeManager(t, dragonflyJobFailed, &requests))
+
+	// The failed preheat doesn't fail the pull.
+	reference := host + "/test/model:latest"
+	require.NoError(t, p.Pull(context.Background(), reference, filepath.Join(t.TempDir(), "model"), false, nil))
+	require.Equal(t, []string{reference}, inner.references)
+
+	preheat := p.hook.GetProgress().Preheat
+	require.NotNil(t, preheat)
+	require.Equal(t, status.PreheatFailed, preheat.State)
+	require.Contains(t, preheat.Error, "preheat job 1 failed")
+}
+
+func TestPreheatPuller_WithoutDragonfly(t *testing.T) {
+	requests := []map[string]any{}
+	p, inner := newPreheatPuller(t, newFakeManager(t, dragonflyJobSucceeded, &requests))
+	p.pullCfg.DragonflyEndpoint = ""
+
+	require.NoError(t, p.Pull(context.Background(), "test/model:latest", filepath.Join(t.TempDir(), "model"), false, nil))
+	require.Equal(t, []string{"test/model:latest"}, inner.references)
+	require.Empty(t, requests)
+	require.Nil(t, p.hook.GetProgress().Preheat)
+}
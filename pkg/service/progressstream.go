@@ -0,0 +1,85 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+)
+
+// The interval to check the mount for the progress stream.
+var ProgressStreamInterval = time.Second
+
+// isPullDone returns true if the pull of the model is done, whether it's
+// succeeded or not. The mount being deleted isn't done until it's gone.
+func isPullDone(state string) bool {
+	switch state {
+	case modelStatus.StatePullQueued, modelStatus.StatePullRunning, modelStatus.StateWeightsPulling,
+		modelStatus.StateCanceling, modelStatus.StateDeleting:
+		return false
+	}
+	return true
+}
+
+// writeEvent writes the server-sent event with the JSON data and flushes it.
+func writeEvent(w http.ResponseWriter, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return errors.Wrapf(err, "marshal %s event", event)
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return errors.Wrapf(err, "write %s event", event)
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// streamProgress writes a progress event of the mount each time it changes,
+// e.g. a layer is pulled or the state changes, until its pull is done. The
+// error of the mount, e.g. the failed mount cleaned up, is written as an
+// error event which ends the stream.
+func streamProgress(ctx context.Context, w http.ResponseWriter, mount *modelStatus.Status, getMount func() (*modelStatus.Status, error)) error {
+	ticker := time.NewTicker(ProgressStreamInterval)
+	defer ticker.Stop()
+
+	var last []byte
+	for {
+		payload, err := json.Marshal(mount)
+		if err != nil {
+			return errors.Wrap(err, "marshal mount")
+		}
+		if !bytes.Equal(payload, last) {
+			if err := writeEvent(w, "progress", json.RawMessage(payload)); err != nil {
+				return err
+			}
+			last = payload
+		}
+		if isPullDone(mount.State) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if mount, err = getMount(); err != nil {
+			_, resp := errorResponse(err)
+			if errors.Is(err, os.ErrNotExist) {
+				resp = ErrorResponse{Code: ERR_CODE_NOT_FOUND, Message: err.Error()}
+			}
+			resp.RequestID = logger.RequestID(ctx)
+			return writeEvent(w, "error", resp)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+func TestStreamProgress(t *testing.T) {
+	interval := ProgressStreamInterval
+	ProgressStreamInterval = time.Millisecond
+	defer func() { ProgressStreamInterval = interval }()
+	ctx := context.Background()
+
+	mounts := []*modelStatus.Status{
+		{MountID: "m1", State: modelStatus.StatePullRunning, Progress: modelStatus.Progress{Total: 2}},
+		{MountID: "m1", State: modelStatus.StatePullRunning, Progress: modelStatus.Progress{Total: 2}},
+		{MountID: "m1", State: modelStatus.StatePullSucceeded, Progress: modelStatus.Progress{Total: 2}},
+	}
+	getMount := func() (*modelStatus.Status, error) {
+		mount := mounts[0]
+		mounts = mounts[1:]
+		return mount, nil
+	}
+	rec := httptest.NewRecorder()
+	mount, _ := getMount()
+	require.NoError(t, streamProgress(ctx, rec, mount, getMount))
+	// The unchanged mount isn't written again.
+	events := strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n")
+	require.Len(t, events, 2)
+	require.Contains(t, events[0], "event: progress\ndata: ")
+	require.Contains(t, events[0], modelStatus.StatePullRunning)
+	require.Contains(t, events[1], modelStatus.StatePullSucceeded)
+	require.Empty(t, mounts)
+
+	// The error ends the stream.
+	rec = httptest.NewRecorder()
+	require.NoError(t, streamProgress(ctx, rec, &modelStatus.Status{State: modelStatus.StatePullRunning}, func() (*modelStatus.Status, error) {
+		return nil, grpcStatus.Error(codes.Internal, "pull model failed")
+	}))
+	require.Contains(t, rec.Body.String(), "event: error\ndata: ")
+	require.Contains(t, rec.Body.String(), `"code":"INTERNAL","message":"pull model failed"`)
+}
+
+func TestDynamicServerHandler_StreamProgress(t *testing.T) {
+	h, svc := newHandler(t)
+
+	c, rec := newHandlerContextWithParam(t, http.MethodGet, "/", "",
+		[]string{"volume_name", "mount_id"}, []string{"my-volume", "m1"})
+	_ = h.StreamProgress(c)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	mountIDDir := svc.cfg.Get().GetMountIDDirForDynamic("my-volume", "m1")
+	require.NoError(t, os.MkdirAll(mountIDDir, 0755))
+	_, err := svc.sm.Set(filepath.Join(mountIDDir, "status.json"), modelStatus.Status{
+		MountID: "m1",
+		State:   modelStatus.StatePullSucceeded,
+	})
+	require.NoError(t, err)
+	c, rec = newHandlerContextWithParam(t, http.MethodGet, "/", "",
+		[]string{"volume_name", "mount_id"}, []string{"my-volume", "m1"})
+	require.NoError(t, h.StreamProgress(c))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/event-stream", rec.Header().Get(echo.HeaderContentType))
+	require.Equal(t, 1, strings.Count(rec.Body.String(), "event: progress"))
+}
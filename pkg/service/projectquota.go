@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// The project IDs of the volumes are derived from the volume dirs within
+// the range, so that they don't clash with the project IDs set by the
+// administrator, which are usually small.
+const (
+	minProjectID   = 1 << 24
+	projectIDRange = 1 << 30
+)
+
+// See linux/fs.h and linux/quota.h.
+const (
+	fsXFlagProjInherit = 0x00000200
+	// quotactl_fd(2) since Linux 5.14, the same number on all architectures.
+	sysQuotactlFd = 443
+	qSetQuota     = 0x800008
+	prjQuota      = 2
+	subCmdShift   = 8
+	qifBLimits    = 1
+	// The unit of the block limits of if_dqblk.
+	qifDqblkSize = 1024
+)
+
+// fsxattr of FS_IOC_FSGETXATTR and FS_IOC_FSSETXATTR.
+type fsxattr struct {
+	xflags     uint32
+	extsize    uint32
+	nextents   uint32
+	projid     uint32
+	cowextsize uint32
+	pad        [8]byte
+}
+
+// ifDqblk of Q_SETQUOTA.
+type ifDqblk struct {
+	bhardlimit uint64
+	bsoftlimit uint64
+	curspace   uint64
+	ihardlimit uint64
+	isoftlimit uint64
+	curinodes  uint64
+	btime      uint64
+	itime      uint64
+	valid      uint32
+}
+
+// volumeProjectID returns the project ID of the volume dir.
+func volumeProjectID(volumeDir string) uint32 {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(filepath.Clean(volumeDir)))
+	return minProjectID + hash.Sum32()%projectIDRange
+}
+
+// setProjectQuota limits the size of the files created under the dir by the
+// project quota of XFS or ext4, the file system must be mounted with the
+// project quota enabled (e.g. prjquota). The project ID of 0 removes the
+// limit of the project the dir is in.
+var setProjectQuota = func(dir string, projectID uint32, limit int64) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return errors.Wrapf(err, "open dir: %s", dir)
+	}
+	defer func() { _ = file.Close() }()
+
+	var attr fsxattr
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, file.Fd(), unix.FS_IOC_FSGETXATTR, uintptr(unsafe.Pointer(&attr))); errno != 0 {
+		return errors.Wrapf(errno, "get project of dir: %s", dir)
+	}
+	if projectID == 0 {
+		// Release the limit of the project the dir is in.
+		if attr.projid == 0 {
+			return nil
+		}
+		projectID = attr.projid
+	} else {
+		// The files created under the dir inherit the project.
+		attr.projid = projectID
+		attr.xflags |= fsXFlagProjInherit
+		if _, _, errno := unix.Syscall(unix.SYS_IOCTL, file.Fd(), unix.FS_IOC_FSSETXATTR, uintptr(unsafe.Pointer(&attr))); errno != 0 {
+			return errors.Wrapf(errno, "set project %d of dir: %s", projectID, dir)
+		}
+	}
+
+	blocks := uint64(0)
+	if limit > 0 {
+		blocks = (uint64(limit) + qifDqblkSize - 1) / qifDqblkSize
+	}
+	dqblk := ifDqblk{bhardlimit: blocks, bsoftlimit: blocks, valid: qifBLimits}
+	cmd := uintptr(qSetQuota<<subCmdShift | prjQuota)
+	if _, _, errno := unix.Syscall6(sysQuotactlFd, file.Fd(), cmd, uintptr(projectID), uintptr(unsafe.Pointer(&dqblk)), 0, 0); errno != 0 {
+		return errors.Wrapf(errno, "set quota of project %d", projectID)
+	}
+
+	return nil
+}
+
+// applyProjectQuota limits the volume dir to the size of the model with the
+// headroom, the pull fails with EDQUOT instead of filling the disk if the
+// model exceeds its declared size. The file systems without the project
+// quota are skipped with a warning.
+func (d *DiskQuotaChecker) applyProjectQuota(ctx context.Context, reference string, modelSize int64) {
+	projectQuota := d.cfg.Get().Features.ProjectQuota
+	if !projectQuota.Enabled || !d.projectQuota || d.volumeDir == "" {
+		return
+	}
+	limit := modelSize * int64(100+projectQuota.HeadroomPercent) / 100
+	projectID := volumeProjectID(d.volumeDir)
+	if err := setProjectQuota(d.volumeDir, projectID, limit); err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("failed to apply project quota for %s", reference)
+		return
+	}
+	logger.WithContext(ctx).Infof("applied project quota %d for %s: %s", projectID, reference, humanizeBytes(limit))
+}
+
+// releaseProjectQuota removes the limit of the project of the volume dir
+// before the dir is removed.
+func releaseProjectQuota(ctx context.Context, cfg *config.RawConfig, volumeDir string) {
+	if !cfg.Features.ProjectQuota.Enabled {
+		return
+	}
+	if err := setProjectQuota(volumeDir, 0, 0); err != nil && !os.IsNotExist(errors.Cause(err)) {
+		logger.WithContext(ctx).WithError(err).Warnf("failed to release project quota: %s", volumeDir)
+	}
+}
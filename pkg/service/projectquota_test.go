@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVolumeProjectID(t *testing.T) {
+	projectID := volumeProjectID("/var/lib/model-csi/volumes/csi-vol")
+	require.Equal(t, projectID, volumeProjectID("/var/lib/model-csi/volumes/csi-vol/"))
+	require.NotEqual(t, projectID, volumeProjectID("/var/lib/model-csi/volumes/csi-vol-2"))
+	require.GreaterOrEqual(t, projectID, uint32(minProjectID))
+	require.Less(t, projectID, uint32(minProjectID+projectIDRange))
+}
+
+func TestProjectQuota(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	cfg := svc.cfg.Get()
+	cfg.Features.DiskUsageLimit = 100 << 20
+	cfg.Features.ProjectQuota.HeadroomPercent = 10
+
+	type quota struct {
+		dir       string
+		projectID uint32
+		limit     int64
+	}
+	var quotas []quota
+	var quotaErr error
+	origSetProjectQuota := setProjectQuota
+	setProjectQuota = func(dir string, projectID uint32, limit int64) error {
+		quotas = append(quotas, quota{dir, projectID, limit})
+		return quotaErr
+	}
+	defer func() { setProjectQuota = origSetProjectQuota }()
+
+	volumeDir := cfg.GetMountIDDirForDynamic("csi-vol", "m1")
+	checker := svc.worker.newDiskQuotaChecker(volumeDir, true)
+
+	// The project quota is disabled by default.
+	require.NoError(t, checker.CheckSize(ctx, "test/model:latest", 1000))
+	require.Empty(t, quotas)
+
+	cfg.Features.ProjectQuota.Enabled = true
+	require.NoError(t, checker.CheckSize(ctx, "test/model:latest", 1000))
+	require.Equal(t, []quota{{volumeDir, volumeProjectID(volumeDir), 1100}}, quotas)
+
+	// The size less than the model doesn't limit the volume dir.
+	quotas = nil
+	require.NoError(t, checker.CheckMinSize(ctx, "test/model:latest", 1000))
+	require.NoError(t, svc.worker.newDiskQuotaChecker(volumeDir, false).CheckSize(ctx, "test/model:latest", 1000))
+	require.Empty(t, quotas)
+
+	// The pull goes on without the project quota supported.
+	quotaErr = errors.New("operation not supported")
+	require.NoError(t, checker.CheckSize(ctx, "test/model:latest", 1000))
+	require.Len(t, quotas, 1)
+
+	// The quota is released with the volume.
+	quotas = nil
+	quotaErr = nil
+	newDynamicMount(t, svc, "csi-vol", "m1")
+	require.NoError(t, svc.worker.deleteModel(ctx, false, "csi-vol", "m1"))
+	require.Equal(t, []quota{{volumeDir, 0, 0}}, quotas)
+	require.NoDirExists(t, volumeDir)
+}
@@ -4,16 +4,21 @@ import (
 	"context"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/modelpack/modctl/pkg/backend"
 	modctlConfig "github.com/modelpack/modctl/pkg/config"
 	"github.com/modelpack/model-csi-driver/pkg/config"
 	"github.com/modelpack/model-csi-driver/pkg/config/auth"
+	"github.com/modelpack/model-csi-driver/pkg/fault"
 	"github.com/modelpack/model-csi-driver/pkg/logger"
 	"github.com/modelpack/model-csi-driver/pkg/status"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
+	"oras.land/oras-go/v2/registry/remote"
 )
 
 type PullHook interface {
@@ -39,19 +44,93 @@ type puller struct {
 	diskQuotaChecker *DiskQuotaChecker
 }
 
-func (p *puller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+func newBackend(reference string) (backend.Backend, bool, error) {
 	keyChain, err := auth.GetKeyChainByRef(reference)
 	if err != nil {
-		return errors.Wrapf(err, "get auth for model: %s", reference)
+		return nil, false, errors.Wrapf(err, "get auth for model: %s", reference)
 	}
 	plainHTTP := keyChain.ServerScheme == "http"
 
 	b, err := backend.New("")
 	if err != nil {
-		return errors.Wrap(err, "create modctl backend")
+		return nil, false, errors.Wrap(err, "create modctl backend")
+	}
+
+	return b, plainHTTP, nil
+}
+
+func (p *puller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	pullCfg := registryPullConfig(p.pullCfg, reference)
+	repo, err := newOCIRepository(pullCfg, reference)
+	if err != nil {
+		return err
+	}
+	logPullProxy(ctx, pullCfg, reference, repo.Reference.Registry)
+	manifestDesc, manifest, err := fetchManifest(ctx, repo)
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(ctx, &pullCfg.Signature, repo, manifestDesc); err != nil {
+		return err
+	}
+	op := &ociPuller{
+		pullCfg:          pullCfg,
+		hook:             p.hook,
+		diskQuotaChecker: p.diskQuotaChecker,
+	}
+	if !isModelManifest(manifest) {
+		logger.WithContext(ctx).Infof("%s isn't a model artifact, pull it as generic oci artifact", reference)
+		if err := discardPullState(targetDir); err != nil {
+			return err
+		}
+		return op.pull(ctx, repo, manifest, reference, targetDir, excludeModelWeights, excludeFilePatterns)
+	}
+
+	if reason := pullByLayersReason(ctx, pullCfg, repo); reason != "" {
+		logger.WithContext(ctx).Infof("pull %s by the layers with %s", reference, reason)
+		if err := discardPullState(targetDir); err != nil {
+			return err
+		}
+		if err := op.pull(ctx, repo, manifest, reference, targetDir, excludeModelWeights, excludeFilePatterns); err != nil {
+			return err
+		}
+	} else if err := withLayerRetry(ctx, &pullCfg.Retry, reference, func() error {
+		// The layers are pulled by modctl, retry the pull on the transient
+		// error of a layer instead, the layers pulled before are resumed by
+		// the pull state on retry.
+		return p.pullModel(ctx, pullCfg, reference, targetDir, excludeModelWeights, excludeFilePatterns)
+	}); err != nil {
+		return err
+	}
+
+	if pullCfg.DisableFileVerification {
+		return nil
+	}
+	return verifyModelFiles(ctx, manifest, targetDir, pullCfg.Concurrency, excludeModelWeights, excludeFilePatterns)
+}
+
+// pullByLayersReason returns why the model is pulled by the layers with the
+// registry client instead of modctl, which reads the auth only from docker's
+// config.json of the node and verifies the registry only by the system CAs,
+// or empty to pull it by modctl. The pull by the layers isn't resumed on
+// retry.
+func pullByLayersReason(ctx context.Context, pullCfg *config.PullConfig, repo *remote.Repository) string {
+	if _, ok, _ := registrySecretsKeyChain(ctx, repo.Reference.Registry); ok {
+		return "the registry credentials of the volume"
+	}
+	if !repo.PlainHTTP && (pullCfg.TLS.CAFile != "" || pullCfg.TLS.CertFile != "") {
+		return "the tls config of the registry"
+	}
+	return ""
+}
+
+func (p *puller) pullModel(ctx context.Context, pullCfg *config.PullConfig, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	b, plainHTTP, err := newBackend(reference)
+	if err != nil {
+		return err
 	}
 
-	modelArtifact := NewModelArtifact(b, reference, plainHTTP)
+	modelArtifact := NewModelArtifact(b, reference, plainHTTP, pullCfg.TLS.SkipVerify())
 
 	if p.diskQuotaChecker != nil {
 		if err := p.diskQuotaChecker.Check(ctx, modelArtifact, excludeModelWeights, excludeFilePatterns); err != nil {
@@ -63,54 +142,373 @@ func (p *puller) Pull(ctx context.Context, reference, targetDir string, excludeM
 		return errors.Wrapf(err, "create model dir: %s", targetDir)
 	}
 
-	if !excludeModelWeights && len(excludeFilePatterns) == 0 {
+	statePath := getPullStatePath(targetDir)
+	state := loadPullState(statePath, pullStateKey(reference, PullOptions{
+		Type:                ModelTypeImage,
+		ExcludeModelWeights: excludeModelWeights,
+		ExcludeFilePatterns: excludeFilePatterns,
+	}))
+	hook := newResumeHook(ctx, p.hook, statePath, state)
+	if err := hook.save(); err != nil {
+		return err
+	}
+	layerHook := newFaultHook(ctx, hook)
+
+	if !excludeModelWeights && len(excludeFilePatterns) == 0 && len(state.Layers) == 0 {
 		pullConfig := modctlConfig.NewPull()
-		pullConfig.Concurrency = int(p.pullCfg.Concurrency)
+		pullConfig.Concurrency = int(pullCfg.Concurrency)
 		pullConfig.PlainHTTP = plainHTTP
-		pullConfig.Proxy = p.pullCfg.ProxyURL
-		pullConfig.DragonflyEndpoint = p.pullCfg.DragonflyEndpoint
-		pullConfig.Insecure = true
+		pullConfig.Proxy = pullCfg.ProxyURL
+		pullConfig.DragonflyEndpoint = pullCfg.DragonflyEndpoint
+		pullConfig.Insecure = pullCfg.TLS.SkipVerify()
 		pullConfig.ExtractDir = targetDir
 		pullConfig.ExtractFromRemote = true
-		pullConfig.Hooks = p.hook
+		pullConfig.Hooks = layerHook
 		pullConfig.ProgressWriter = io.Discard
 		pullConfig.DisableProgress = true
 
-		if err := b.Pull(ctx, reference, pullConfig); err != nil {
+		stopWatch := watchLayerFiles(ctx, p.hook, targetDir)
+		err := b.Pull(ctx, reference, pullConfig)
+		stopWatch()
+		if err != nil {
 			logger.WithContext(ctx).WithError(err).Errorf("failed to pull model image: %s", reference)
 			return errors.Wrap(err, "pull model image")
 		}
+		if err := layerHook.Err(); err != nil {
+			return errors.Wrap(err, "pull model image")
+		}
 
-		return nil
+		return hook.complete()
 	}
 
-	patterns, total, err := modelArtifact.GetPatterns(ctx, excludeModelWeights, excludeFilePatterns)
+	layers, total, err := modelArtifact.getLayers(ctx, includeLayerFunc(ctx, p.hook, excludeModelWeights, excludeFilePatterns))
 	if err != nil {
 		return errors.Wrap(err, "get model file patterns without weights")
 	}
 
+	// The files of the layers pulled before but not in the model any more,
+	// e.g. by another tag of the model pulled incrementally, are removed.
+	layerPaths := map[string]bool{}
+	for _, layer := range layers {
+		layerPaths[layer.Filepath] = true
+	}
+	for filePath := range state.Layers {
+		if !layerPaths[filePath] {
+			if err := removeLayerFile(targetDir, filePath); err != nil {
+				return err
+			}
+			delete(state.Layers, filePath)
+		}
+	}
+
+	patterns := []string{}
+	for _, layer := range layers {
+		if state.isPulled(targetDir, layer) {
+			continue
+		}
+		// The file of the changed layer is replaced instead of written in
+		// place, as it may be hardlinked from another model dir.
+		if _, ok := state.Layers[layer.Filepath]; ok && layer.Filepath != "" {
+			if err := removeLayerFile(targetDir, layer.Filepath); err != nil {
+				return err
+			}
+			delete(state.Layers, layer.Filepath)
+		}
+		if layer.Filepath == "" && len(state.Layers) > 0 {
+			// The layer can't be fetched by pattern, pull the whole model again.
+			logger.WithContext(ctx).Warnf("layer %s has no file path, discard the pulled layers", layer.Digest)
+			if err := hook.remove(); err != nil {
+				return err
+			}
+			if err := os.RemoveAll(targetDir); err != nil {
+				return errors.Wrapf(err, "cleanup model dir: %s", targetDir)
+			}
+			return p.pullModel(ctx, pullCfg, reference, targetDir, excludeModelWeights, excludeFilePatterns)
+		}
+		patterns = append(patterns, layer.Filepath)
+	}
+	for _, layer := range layers {
+		if state.isPulled(targetDir, layer) {
+			p.hook.MarkPulled(fileDescriptor(layer))
+		}
+	}
+	if resumed := len(layers) - len(patterns); resumed > 0 {
+		logger.WithContext(ctx).Infof("resuming pull of model: %s, %d/%d files already pulled", reference, resumed, len(layers))
+	}
+
 	logger.WithContext(ctx).Infof(
 		"fetching partial files from model: %s, files: %s (%d/%d)",
 		reference, strings.Join(patterns, ", "), len(patterns), total,
 	)
-	p.hook.SetTotal(len(patterns))
+	p.hook.SetTotal(len(layers))
+	totalSize := int64(0)
+	for _, layer := range layers {
+		totalSize += layer.Size
+	}
+	p.hook.SetTotalSize(totalSize)
 
-	fetchConfig := modctlConfig.NewFetch()
-	fetchConfig.Concurrency = int(p.pullCfg.Concurrency)
-	fetchConfig.PlainHTTP = plainHTTP
-	fetchConfig.Proxy = p.pullCfg.ProxyURL
-	fetchConfig.DragonflyEndpoint = p.pullCfg.DragonflyEndpoint
-	fetchConfig.Insecure = true
-	fetchConfig.Output = targetDir
-	fetchConfig.Hooks = p.hook
-	fetchConfig.ProgressWriter = io.Discard
-	fetchConfig.DisableProgress = true
-	fetchConfig.Patterns = patterns
+	if len(patterns) > 0 {
+		fetchConfig := modctlConfig.NewFetch()
+		fetchConfig.Concurrency = int(pullCfg.Concurrency)
+		fetchConfig.PlainHTTP = plainHTTP
+		fetchConfig.Proxy = pullCfg.ProxyURL
+		fetchConfig.DragonflyEndpoint = pullCfg.DragonflyEndpoint
+		fetchConfig.Insecure = pullCfg.TLS.SkipVerify()
+		fetchConfig.Output = targetDir
+		fetchConfig.Hooks = layerHook
+		fetchConfig.ProgressWriter = io.Discard
+		fetchConfig.DisableProgress = true
+		fetchConfig.Patterns = patterns
 
-	if err := b.Fetch(ctx, reference, fetchConfig); err != nil {
-		logger.WithContext(ctx).WithError(err).Errorf("failed to fetch model: %s", reference)
-		return errors.Wrap(err, "fetch model")
+		stopWatch := watchLayerFiles(ctx, p.hook, targetDir)
+		err := b.Fetch(ctx, reference, fetchConfig)
+		stopWatch()
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Errorf("failed to fetch model: %s", reference)
+			return errors.Wrap(err, "fetch model")
+		}
+		if err := layerHook.Err(); err != nil {
+			return errors.Wrap(err, "fetch model")
+		}
 	}
 
+	return hook.complete()
+}
+
+// discardPullState drops the layers pulled into the model dir before, e.g.
+// by an interrupted pull or the model pulled incrementally, for the pull by
+// the layers, which isn't resumed and expects an empty dir.
+func discardPullState(targetDir string) error {
+	statePath := getPullStatePath(targetDir)
+	if _, err := os.Stat(statePath); err != nil {
+		return nil
+	}
+	if err := os.RemoveAll(targetDir); err != nil {
+		return errors.Wrapf(err, "cleanup model dir: %s", targetDir)
+	}
+	if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "remove pull state: %s", statePath)
+	}
 	return nil
 }
+
+// removeLayerFile removes the file, or the dir, of the layer from the model
+// dir.
+func removeLayerFile(targetDir, filePath string) error {
+	if !filepath.IsLocal(filePath) {
+		return nil
+	}
+	if err := os.RemoveAll(filepath.Join(targetDir, filePath)); err != nil {
+		return errors.Wrapf(err, "remove layer file: %s", filePath)
+	}
+	return nil
+}
+
+// The interval of reporting the downloaded bytes of the layers pulled by
+// modctl.
+var layerWatchInterval = time.Second
+
+// watchLayerFiles reports the downloaded bytes of the layers being pulled by
+// modctl, which doesn't report the bytes, by the size of their files in the
+// model dir, until the returned stop is called.
+func watchLayerFiles(ctx context.Context, hook *status.Hook, targetDir string) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(layerWatchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			for _, item := range hook.GetProgress().Items {
+				if item.FinishedAt != nil || item.Path == "" {
+					continue
+				}
+				if info, err := os.Stat(filepath.Join(targetDir, item.Path)); err == nil && info.Mode().IsRegular() {
+					hook.SetDownloadedBytes(item.Digest, info.Size())
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// blobStorePuller links the model files from the blob store if all of them
+// are there, otherwise it pulls the model and ingests the pulled files into
+// the blob store for the next volume of the same model.
+type blobStorePuller struct {
+	Puller
+	pullCfg *config.PullConfig
+	store   *BlobStore
+	hook    *status.Hook
+	// The size of the model files linked from the blob store without pulling.
+	reusedSize int64
+}
+
+func (p *blobStorePuller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	// The files of the generic oci artifact aren't identified by the layer
+	// digests, so they're never shared by the blob store.
+	repo, err := newOCIRepository(p.pullCfg, reference)
+	if err != nil {
+		return err
+	}
+	manifestDesc, manifest, err := fetchManifest(ctx, repo)
+	if err != nil {
+		return err
+	}
+	// Verify the signature before linking the model files from the blob
+	// store, which bypasses the puller.
+	if err := verifySignature(ctx, &p.pullCfg.Signature, repo, manifestDesc); err != nil {
+		return err
+	}
+	if !isModelManifest(manifest) {
+		return p.Puller.Pull(ctx, reference, targetDir, excludeModelWeights, excludeFilePatterns)
+	}
+
+	// The layers are read from the manifest fetched above instead of
+	// inspected by modctl, so that the registry credentials of the volume
+	// are used.
+	include := includeLayerFunc(ctx, p.hook, excludeModelWeights, excludeFilePatterns)
+	layers := []backend.InspectedModelArtifactLayer{}
+	for _, desc := range manifest.Layers {
+		layer := backend.InspectedModelArtifactLayer{
+			Digest:   desc.Digest.String(),
+			Size:     desc.Size,
+			Filepath: status.LayerFilepath(desc),
+		}
+		if include(layer) {
+			layers = append(layers, layer)
+		}
+	}
+
+	// The model dir holding the layers of an interrupted pull is resumed by
+	// the puller instead.
+	stateKey := pullStateKey(reference, PullOptions{
+		Type:                ModelTypeImage,
+		ExcludeModelWeights: excludeModelWeights,
+		ExcludeFilePatterns: excludeFilePatterns,
+	})
+	if !canResumePull(targetDir, stateKey) {
+		linked, err := p.link(layers, targetDir)
+		if err != nil {
+			return errors.Wrap(err, "link model files from blob store")
+		}
+		if linked {
+			for _, layer := range layers {
+				p.reusedSize += layer.Size
+			}
+			logger.WithContext(ctx).Infof("linked %d model files from blob store: %s", len(layers), reference)
+			return nil
+		}
+
+		// Drop the files linked partially, the puller expects an empty dir.
+		if err := os.RemoveAll(targetDir); err != nil {
+			return errors.Wrapf(err, "cleanup model dir: %s", targetDir)
+		}
+	}
+
+	if err := p.Puller.Pull(ctx, reference, targetDir, excludeModelWeights, excludeFilePatterns); err != nil {
+		return err
+	}
+
+	for _, layer := range layers {
+		if layer.Filepath == "" || !filepath.IsLocal(layer.Filepath) {
+			continue
+		}
+		if err := p.store.Ingest(ctx, layer.Digest, filepath.Join(targetDir, layer.Filepath)); err != nil {
+			return errors.Wrapf(err, "ingest model file: %s", layer.Filepath)
+		}
+	}
+
+	return nil
+}
+
+// link returns true only if all the model files are linked from the blob store.
+func (p *blobStorePuller) link(layers []backend.InspectedModelArtifactLayer, targetDir string) (bool, error) {
+	if len(layers) == 0 {
+		return false, nil
+	}
+
+	for _, layer := range layers {
+		if layer.Filepath == "" || !filepath.IsLocal(layer.Filepath) {
+			return false, nil
+		}
+		linked, err := p.store.Link(layer.Digest, filepath.Join(targetDir, layer.Filepath))
+		if err != nil || !linked {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// faultPuller injects the no space fault configured by fault_injection into
+// the pull, the layer faults are injected by faultHook.
+type faultPuller struct {
+	Puller
+}
+
+func (p *faultPuller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	if err := fault.BeforePull(ctx); err != nil {
+		return err
+	}
+	return p.Puller.Pull(ctx, reference, targetDir, excludeModelWeights, excludeFilePatterns)
+}
+
+// faultHook injects the layer faults configured by fault_injection around
+// the pull of each layer. The hooks of modctl can't fail the pull, so the
+// injected layer failure is passed to the wrapped hook as the layer error,
+// which keeps the layer out of the pull state, and is returned by Err after
+// the pull.
+type faultHook struct {
+	PullHook
+
+	ctx   context.Context
+	mutex sync.Mutex
+	err   error
+}
+
+func newFaultHook(ctx context.Context, hook PullHook) *faultHook {
+	return &faultHook{
+		PullHook: hook,
+		ctx:      ctx,
+	}
+}
+
+func (h *faultHook) setErr(err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.err == nil {
+		h.err = err
+	}
+}
+
+func (h *faultHook) BeforePullLayer(desc ocispec.Descriptor, manifest ocispec.Manifest) {
+	if err := fault.BeforePullLayer(h.ctx, status.LayerFilepath(desc)); err != nil {
+		h.setErr(err)
+	}
+	h.PullHook.BeforePullLayer(desc, manifest)
+}
+
+func (h *faultHook) AfterPullLayer(desc ocispec.Descriptor, err error) {
+	if err == nil {
+		if err = fault.AfterPullLayer(h.ctx, status.LayerFilepath(desc)); err != nil {
+			h.setErr(err)
+		}
+	}
+	h.PullHook.AfterPullLayer(desc, err)
+}
+
+// Err returns the first injected layer fault.
+func (h *faultHook) Err() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.err
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"syscall"
+
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PullError is the error of the pull of the model with the status of the
+// model at the time the pull failed, which is kept after the model dir is
+// cleaned up.
+type PullError struct {
+	Status *modelStatus.Status
+	err    error
+}
+
+func (e *PullError) Error() string {
+	return e.err.Error()
+}
+
+func (e *PullError) Unwrap() error {
+	return e.err
+}
+
+// pullErrorReason returns the reason of the ErrorInfo of the failed pull, the
+// error codes of the dynamic server for the errors they're defined for, and
+// the state of the model otherwise, e.g. PULL_TIMEOUT.
+func pullErrorReason(err error) string {
+	switch {
+	case errors.Is(err, syscall.ENOSPC):
+		return ERR_CODE_INSUFFICIENT_DISK_QUOTA
+	case errors.Is(err, ErrSignatureVerification):
+		return ERR_CODE_SIGNATURE_VERIFICATION_FAILED
+	case errors.Is(err, ErrConflict):
+		return ERR_CODE_ALREADY_EXISTS
+	}
+	var pullErr *PullError
+	if errors.As(err, &pullErr) && pullErr.Status.State != "" {
+		return pullErr.Status.State
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return modelStatus.StatePullTimeout
+	case errors.Is(err, context.Canceled):
+		return modelStatus.StatePullCanceled
+	}
+	return modelStatus.StatePullFailed
+}
+
+// pullErrorStatus returns the gRPC error of the failed pull with the
+// google.rpc.ErrorInfo detail, which carries the reason and the progress of
+// the pull, so that the callers don't parse the message.
+func (s *Service) pullErrorStatus(code codes.Code, message string, err error) error {
+	metadata := map[string]string{}
+	var pullErr *PullError
+	if errors.As(err, &pullErr) {
+		volumeStatus := pullErr.Status
+		metadata["volume_name"] = volumeStatus.VolumeName
+		if volumeStatus.MountID != "" {
+			metadata["mount_id"] = volumeStatus.MountID
+		}
+		metadata["reference"] = volumeStatus.Reference
+		if volumeStatus.Digest != "" {
+			metadata["digest"] = volumeStatus.Digest
+		}
+		metadata["state"] = volumeStatus.State
+		pulledLayers := 0
+		for _, item := range volumeStatus.Progress.Items {
+			if item.FinishedAt != nil && item.Error == nil {
+				pulledLayers++
+			}
+		}
+		metadata["pulled_layers"] = strconv.Itoa(pulledLayers)
+		metadata["total_layers"] = strconv.Itoa(volumeStatus.Progress.Total)
+		metadata["downloaded_bytes"] = strconv.FormatInt(volumeStatus.Progress.DownloadedBytes, 10)
+		metadata["total_bytes"] = strconv.FormatInt(volumeStatus.Progress.TotalBytes, 10)
+	}
+
+	st, detailErr := status.New(code, message).WithDetails(&errdetails.ErrorInfo{
+		Reason:   pullErrorReason(err),
+		Domain:   s.cfg.Get().ServiceName,
+		Metadata: metadata,
+	})
+	if detailErr != nil {
+		return status.Error(code, message)
+	}
+	return st.Err()
+}
+
+// errorInfo returns the ErrorInfo detail of the gRPC error, nil if it has
+// none.
+func errorInfo(err error) *errdetails.ErrorInfo {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+func TestPullErrorStatus(t *testing.T) {
+	svc := newTestService(t)
+	finishedAt := time.Now()
+	pullErr := &PullError{
+		Status: &status.Status{
+			VolumeName: "pvc-1",
+			Reference:  "test/model:latest",
+			State:      status.StatePullTimeout,
+			Progress: status.Progress{
+				Total: 2,
+				Items: []status.ProgressItem{
+					{Path: "config.json", FinishedAt: &finishedAt},
+					{Path: "weights.bin"},
+				},
+				TotalBytes:      100,
+				DownloadedBytes: 60,
+			},
+		},
+		err: errors.New("pull model timeout: context deadline exceeded"),
+	}
+
+	// The detail is found through the wrapped errors.
+	err := errors.Wrap(svc.pullErrorStatus(codes.Internal, "pull model", errors.Wrap(pullErr, "pull model image")), "call grpc server")
+	require.Equal(t, codes.Internal, grpcStatus.Code(err))
+	info := errorInfo(err)
+	require.NotNil(t, info)
+	require.Equal(t, status.StatePullTimeout, info.GetReason())
+	require.Equal(t, "test.csi.example.com", info.GetDomain())
+	require.Equal(t, map[string]string{
+		"volume_name":      "pvc-1",
+		"reference":        "test/model:latest",
+		"state":            status.StatePullTimeout,
+		"pulled_layers":    "1",
+		"total_layers":     "2",
+		"downloaded_bytes": "60",
+		"total_bytes":      "100",
+	}, info.GetMetadata())
+
+	// The error codes of the dynamic server are the reasons of their errors.
+	err = svc.pullErrorStatus(codes.ResourceExhausted, "pull model", errors.Wrap(syscall.ENOSPC, "write file"))
+	require.Equal(t, ERR_CODE_INSUFFICIENT_DISK_QUOTA, errorInfo(err).GetReason())
+	err = svc.pullErrorStatus(codes.AlreadyExists, "pull model", errors.Wrap(ErrConflict, "mount_id is re-used"))
+	require.Equal(t, ERR_CODE_ALREADY_EXISTS, errorInfo(err).GetReason())
+	require.Equal(t, status.StatePullFailed, errorInfo(svc.pullErrorStatus(codes.Internal, "pull model", errors.New("unauthorized"))).GetReason())
+	require.Nil(t, errorInfo(grpcStatus.Error(codes.Internal, "pull model")))
+}
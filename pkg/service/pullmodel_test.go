@@ -2,12 +2,22 @@ package service
 
 import (
 	"context"
+	"io"
+	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/modelpack/modctl/pkg/backend"
 	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/fault"
+	"github.com/modelpack/model-csi-driver/pkg/metrics"
 	"github.com/modelpack/model-csi-driver/pkg/status"
 	pkgerrors "github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -26,6 +36,7 @@ func newWorkerWithMockPuller(t *testing.T, pullErr error) *Worker {
 	cfg := config.NewWithRaw(rawCfg)
 	sm, err := status.NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 
 	worker, err := NewWorker(cfg, sm)
 	require.NoError(t, err)
@@ -42,7 +53,7 @@ func TestPullModel_Success(t *testing.T) {
 	volumeName := "pvc-pull-test"
 	modelDir := filepath.Join(worker.cfg.Get().GetVolumeDir(volumeName), "model")
 
-	err := worker.PullModel(ctx, true, volumeName, "", "test/model:latest", modelDir, false, false, nil)
+	err := worker.PullModel(ctx, true, volumeName, "", "test/model:latest", modelDir, PullOptions{})
 	require.NoError(t, err)
 }
 
@@ -52,7 +63,7 @@ func TestPullModel_Failure(t *testing.T) {
 	volumeName := "pvc-pull-fail"
 	modelDir := filepath.Join(worker.cfg.Get().GetVolumeDir(volumeName), "model")
 
-	err := worker.PullModel(ctx, true, volumeName, "", "test/model:latest", modelDir, false, false, nil)
+	err := worker.PullModel(ctx, true, volumeName, "", "test/model:latest", modelDir, PullOptions{})
 	require.Error(t, err)
 }
 
@@ -63,7 +74,7 @@ func TestPullModel_DynamicVolume_Success(t *testing.T) {
 	mountID := "mount-1"
 	modelDir := worker.cfg.Get().GetModelDirForDynamic(volumeName, mountID)
 
-	err := worker.PullModel(ctx, false, volumeName, mountID, "test/model:latest", modelDir, false, false, nil)
+	err := worker.PullModel(ctx, false, volumeName, mountID, "test/model:latest", modelDir, PullOptions{})
 	require.NoError(t, err)
 }
 
@@ -74,6 +85,494 @@ func TestPullModel_DynamicVolume_Failure(t *testing.T) {
 	mountID := "mount-2"
 	modelDir := worker.cfg.Get().GetModelDirForDynamic(volumeName, mountID)
 
-	err := worker.PullModel(ctx, false, volumeName, mountID, "test/model:latest", modelDir, false, false, nil)
+	err := worker.PullModel(ctx, false, volumeName, mountID, "test/model:latest", modelDir, PullOptions{})
 	require.Error(t, err)
 }
+
+func TestPullModel_FaultInjection(t *testing.T) {
+	worker := newWorkerWithMockPuller(t, nil)
+	worker.cfg.Get().FaultInjection = config.FaultInjection{Enabled: true, NoSpaceRate: 1}
+	fault.Setup(worker.cfg)
+	defer fault.Setup(config.NewWithRaw(&config.RawConfig{}))
+
+	volumeName := "pvc-pull-fault"
+	modelDir := filepath.Join(worker.cfg.Get().GetVolumeDir(volumeName), "model")
+
+	err := worker.PullModel(context.Background(), true, volumeName, "", "test/model:latest", modelDir, PullOptions{})
+	require.ErrorIs(t, err, syscall.ENOSPC)
+	// The volume is cleaned up after the failure.
+	require.NoDirExists(t, worker.cfg.Get().GetVolumeDir(volumeName))
+}
+
+// interruptedPuller pulls a layer then fails with the network error.
+type interruptedPuller struct {
+	key string
+}
+
+func (p *interruptedPuller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "config.json"), []byte("{}"), 0644); err != nil {
+		return err
+	}
+	statePath := getPullStatePath(targetDir)
+	state := loadPullState(statePath, p.key)
+	state.Layers["config.json"] = "sha256:config"
+	if err := newResumeHook(ctx, status.NewHook(ctx), statePath, state).save(); err != nil {
+		return err
+	}
+	return pkgerrors.Wrap(io.ErrUnexpectedEOF, "pull layer")
+}
+
+func TestPullModel_KeepInterruptedPull(t *testing.T) {
+	worker := newWorkerWithMockPuller(t, nil)
+	reference := "test/model:latest"
+	worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return &interruptedPuller{key: pullStateKey(reference, PullOptions{})}
+	}
+
+	volumeName := "pvc-pull-interrupted"
+	modelDir := filepath.Join(worker.cfg.Get().GetVolumeDir(volumeName), "model")
+
+	err := worker.PullModel(context.Background(), true, volumeName, "", reference, modelDir, PullOptions{})
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	// The pulled layers are kept for the retry to resume.
+	require.FileExists(t, filepath.Join(modelDir, "config.json"))
+	require.True(t, canResumePull(modelDir, pullStateKey(reference, PullOptions{})))
+}
+
+// layeredPuller pulls the layers of the references not pulled yet by the
+// pull state, the files are written with the reference.
+type layeredPuller struct {
+	layers map[string]map[string]string
+	pulled []string
+}
+
+func (p *layeredPuller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+	statePath := getPullStatePath(targetDir)
+	state := loadPullState(statePath, pullStateKey(reference, PullOptions{}))
+	hook := newResumeHook(ctx, status.NewHook(ctx), statePath, state)
+	for filePath, dgst := range p.layers[reference] {
+		if state.isPulled(targetDir, backend.InspectedModelArtifactLayer{Filepath: filePath, Digest: dgst}) {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(targetDir, filePath), []byte(reference), 0644); err != nil {
+			return err
+		}
+		state.Layers[filePath] = dgst
+		p.pulled = append(p.pulled, filePath)
+	}
+	if err := hook.save(); err != nil {
+		return err
+	}
+	return hook.complete()
+}
+
+func TestPullModel_Incremental(t *testing.T) {
+	worker := newWorkerWithMockPuller(t, nil)
+	worker.cfg.Get().PullConfig.WriteChecksumFile = true
+	puller := &layeredPuller{layers: map[string]map[string]string{
+		"test/model:v1": {"config.json": "sha256:config-v1", "weights.bin": "sha256:weights"},
+		"test/model:v2": {"config.json": "sha256:config-v2", "weights.bin": "sha256:weights"},
+	}}
+	worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return puller
+	}
+
+	volumeName := "pvc-incremental"
+	modelDir := worker.cfg.Get().GetModelDir(volumeName)
+	require.NoError(t, worker.PullModel(context.Background(), true, volumeName, "", "test/model:v1", modelDir, PullOptions{}))
+	require.ElementsMatch(t, []string{"config.json", "weights.bin"}, puller.pulled)
+	require.FileExists(t, getPulledLayersPath(modelDir))
+	require.NoFileExists(t, getPullStatePath(modelDir))
+
+	// Only the changed layer is pulled by the other tag of the model.
+	puller.pulled = nil
+	require.NoError(t, worker.PullModel(context.Background(), true, volumeName, "", "test/model:v2", modelDir, PullOptions{}))
+	require.Equal(t, []string{"config.json"}, puller.pulled)
+	for filePath, reference := range map[string]string{"config.json": "test/model:v2", "weights.bin": "test/model:v1"} {
+		data, err := os.ReadFile(filepath.Join(modelDir, filePath))
+		require.NoError(t, err)
+		require.Equal(t, reference, string(data))
+	}
+	// The checksum file is written again for the new model.
+	checksums, err := readChecksumFile(filepath.Join(modelDir, checksumFile))
+	require.NoError(t, err)
+	require.Len(t, checksums, 2)
+	modelStatus, err := worker.sm.Get(filepath.Join(filepath.Dir(modelDir), "status.json"))
+	require.NoError(t, err)
+	require.Equal(t, "test/model:v2", modelStatus.Reference)
+	require.Equal(t, status.StatePullSucceeded, modelStatus.State)
+
+	// The model isn't pulled incrementally with the shared blob store.
+	worker.cfg.Get().Features.SharedBlobStore = true
+	require.False(t, worker.canPullIncrementally(context.Background(), pullKey("test/model:v1", PullOptions{}), modelDir, PullOptions{}))
+}
+
+func TestPinReference(t *testing.T) {
+	dgst := "sha256:" + strings.Repeat("a", 64)
+	require.Equal(t, "docker.io/foo/bar@"+dgst, pinReference("foo/bar:v1", dgst))
+	require.Equal(t, "registry.example.com/foo/bar@"+dgst, pinReference("registry.example.com/foo/bar", dgst))
+	require.Equal(t, "foo/bar:v1", pinReference("foo/bar:v1", ""))
+	// The tags pinned to the same digest get the same pull key.
+	require.Equal(t, pullKey(pinReference("foo/bar:v1", dgst), PullOptions{}), pullKey(pinReference("foo/bar:v2", dgst), PullOptions{}))
+}
+
+func TestPullKey(t *testing.T) {
+	require.Equal(t, pullKey("foo/bar", PullOptions{}), pullKey("docker.io/foo/bar:latest", PullOptions{}))
+	require.Equal(t, pullKey("foo/bar", PullOptions{}), pullKey("foo/bar", PullOptions{Type: ModelTypeImage}))
+	require.NotEqual(t, pullKey("foo/bar:v1", PullOptions{}), pullKey("foo/bar:v2", PullOptions{}))
+	require.NotEqual(t, pullKey("foo/bar", PullOptions{}), pullKey("foo/bar", PullOptions{ExcludeModelWeights: true}))
+	require.NotEqual(t, pullKey("foo/bar", PullOptions{}), pullKey("foo/bar", PullOptions{ExcludeFilePatterns: []string{"*.bin"}}))
+	require.NotEqual(t, pullKey("foo/bar", PullOptions{}), pullKey("foo/bar", PullOptions{Type: ModelTypeHuggingFace}))
+
+	withSecrets := PullOptions{Type: ModelTypeS3, Secrets: map[string]string{secretKeyS3AccessKeyID: "ak", secretKeyS3SecretAccessKey: "sk"}}
+	require.Equal(t, pullKey("s3://models/qwen3", withSecrets), pullKey("s3://models/qwen3", withSecrets))
+	require.NotEqual(t, pullKey("s3://models/qwen3", PullOptions{Type: ModelTypeS3}), pullKey("s3://models/qwen3", withSecrets))
+}
+
+type blockingPuller struct {
+	started chan struct{}
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (p *blockingPuller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	if p.calls.Add(1) == 1 {
+		close(p.started)
+	}
+	<-p.release
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(targetDir, "weights.bin"), []byte("weights"), 0644)
+}
+
+func TestPullModel_SharedPull(t *testing.T) {
+	worker := newWorkerWithMockPuller(t, nil)
+	puller := &blockingPuller{started: make(chan struct{}), release: make(chan struct{})}
+	worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return puller
+	}
+
+	firstDir := worker.cfg.Get().GetModelDir("pvc-shared-1")
+	secondDir := worker.cfg.Get().GetModelDir("pvc-shared-2")
+
+	errs := make(chan error, 2)
+	go func() {
+		errs <- worker.PullModel(context.Background(), true, "pvc-shared-1", "", "test/model:latest", firstDir, PullOptions{})
+	}()
+	<-puller.started
+	go func() {
+		errs <- worker.PullModel(context.Background(), true, "pvc-shared-2", "", "docker.io/test/model:latest", secondDir, PullOptions{})
+	}()
+	// The second request joins the shared pull right after it's running.
+	secondStatusPath := filepath.Join(worker.cfg.Get().GetVolumeDir("pvc-shared-2"), "status.json")
+	require.Eventually(t, func() bool {
+		modelStatus, err := worker.sm.Get(secondStatusPath)
+		return err == nil && modelStatus.State == status.StatePullRunning
+	}, 5*time.Second, time.Millisecond)
+	close(puller.release)
+
+	require.NoError(t, <-errs)
+	require.NoError(t, <-errs)
+	require.Equal(t, int32(1), puller.calls.Load())
+
+	firstInfo, err := os.Stat(filepath.Join(firstDir, "weights.bin"))
+	require.NoError(t, err)
+	secondInfo, err := os.Stat(filepath.Join(secondDir, "weights.bin"))
+	require.NoError(t, err)
+	// The model files are copied unless the shared blob store is enabled.
+	require.False(t, os.SameFile(firstInfo, secondInfo))
+	data, err := os.ReadFile(filepath.Join(secondDir, "weights.bin"))
+	require.NoError(t, err)
+	require.Equal(t, "weights", string(data))
+}
+
+func TestPullModel_ReuseModel(t *testing.T) {
+	worker := newWorkerWithMockPuller(t, nil)
+	puller := &blockingPuller{started: make(chan struct{}), release: make(chan struct{})}
+	close(puller.release)
+	worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return puller
+	}
+
+	hits := testutil.ToFloat64(metrics.NodePullCacheLookup.WithLabelValues(metrics.CacheResultHit))
+	misses := testutil.ToFloat64(metrics.NodePullCacheLookup.WithLabelValues(metrics.CacheResultMiss))
+
+	firstDir := worker.cfg.Get().GetModelDir("pvc-reuse-1")
+	secondDir := worker.cfg.Get().GetModelDirForDynamic("csi-reuse-2", "mount-1")
+	require.NoError(t, worker.PullModel(context.Background(), true, "pvc-reuse-1", "", "test/model:latest", firstDir, PullOptions{}))
+	require.NoError(t, worker.PullModel(context.Background(), false, "csi-reuse-2", "mount-1", "docker.io/test/model", secondDir, PullOptions{}))
+	require.Equal(t, int32(1), puller.calls.Load())
+	// The cloned model is counted as the cache hit.
+	require.Equal(t, hits+1, testutil.ToFloat64(metrics.NodePullCacheLookup.WithLabelValues(metrics.CacheResultHit)))
+	require.Equal(t, misses+1, testutil.ToFloat64(metrics.NodePullCacheLookup.WithLabelValues(metrics.CacheResultMiss)))
+
+	data, err := os.ReadFile(filepath.Join(secondDir, "weights.bin"))
+	require.NoError(t, err)
+	require.Equal(t, "weights", string(data))
+	modelStatus, err := worker.sm.Get(filepath.Join(filepath.Dir(secondDir), "status.json"))
+	require.NoError(t, err)
+	require.Equal(t, status.StatePullSucceeded, modelStatus.State)
+
+	// The model pulled with different options isn't reused.
+	thirdDir := worker.cfg.Get().GetModelDir("pvc-reuse-3")
+	require.NoError(t, worker.PullModel(context.Background(), true, "pvc-reuse-3", "", "test/model:latest", thirdDir, PullOptions{ExcludeModelWeights: true}))
+	require.Equal(t, int32(2), puller.calls.Load())
+}
+
+func TestPullModel_KeepReadOnly(t *testing.T) {
+	worker := newWorkerWithMockPuller(t, nil)
+	volumeName := "pvc-pull-read-only"
+	modelDir := worker.cfg.Get().GetModelDir(volumeName)
+	statusPath := filepath.Join(worker.cfg.Get().GetVolumeDir(volumeName), "status.json")
+	_, err := worker.sm.Set(statusPath, status.Status{VolumeName: volumeName, ReadOnly: true})
+	require.NoError(t, err)
+
+	require.NoError(t, worker.PullModel(context.Background(), true, volumeName, "", "test/model:latest", modelDir, PullOptions{}))
+	modelStatus, err := worker.sm.Get(statusPath)
+	require.NoError(t, err)
+	require.True(t, modelStatus.ReadOnly)
+	require.Equal(t, status.StatePullSucceeded, modelStatus.State)
+}
+
+func TestPullModel_Timestamps(t *testing.T) {
+	worker := newWorkerWithMockPuller(t, nil)
+	volumeName := "csi-pull-timestamps"
+	modelDir := worker.cfg.Get().GetModelDirForDynamic(volumeName, "m1")
+	statusPath := filepath.Join(filepath.Dir(modelDir), "status.json")
+	labels := map[string]string{"tenant": "team-a"}
+
+	start := time.Now()
+	require.NoError(t, worker.PullModel(context.Background(), false, volumeName, "m1", "test/model:latest", modelDir, PullOptions{Labels: labels}))
+	modelStatus, err := worker.sm.Get(statusPath)
+	require.NoError(t, err)
+	require.Equal(t, labels, modelStatus.Labels)
+	require.Nil(t, modelStatus.Error)
+	createdAt := *modelStatus.CreatedAt
+	require.False(t, createdAt.Before(start))
+	require.False(t, modelStatus.PullStartedAt.Before(createdAt))
+	require.False(t, modelStatus.PullFinishedAt.Before(*modelStatus.PullStartedAt))
+
+	// The creation time and the labels are kept by the model pulled again.
+	require.NoError(t, worker.PullModel(context.Background(), false, volumeName, "m1", "test/model:latest", modelDir, PullOptions{}))
+	modelStatus, err = worker.sm.Get(statusPath)
+	require.NoError(t, err)
+	require.True(t, createdAt.Equal(*modelStatus.CreatedAt))
+	require.Equal(t, labels, modelStatus.Labels)
+}
+
+func TestPullModel_KeepPullError(t *testing.T) {
+	worker := newWorkerWithMockPuller(t, nil)
+	reference := "test/model:latest"
+	worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return &interruptedPuller{key: pullStateKey(reference, PullOptions{})}
+	}
+	volumeName := "pvc-pull-error"
+	modelDir := filepath.Join(worker.cfg.Get().GetVolumeDir(volumeName), "model")
+
+	err := worker.PullModel(context.Background(), true, volumeName, "", reference, modelDir, PullOptions{})
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	// The error is kept with the status of the interrupted pull.
+	modelStatus, err := worker.sm.Get(filepath.Join(worker.cfg.Get().GetVolumeDir(volumeName), "status.json"))
+	require.NoError(t, err)
+	require.Equal(t, status.StatePullFailed, modelStatus.State)
+	require.Equal(t, status.StatePullFailed, modelStatus.Error.Reason)
+	require.Contains(t, modelStatus.Error.Message, "unexpected EOF")
+	require.NotNil(t, modelStatus.PullFinishedAt)
+}
+
+type referencePuller struct {
+	references []string
+}
+
+func (p *referencePuller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	p.references = append(p.references, reference)
+	return os.MkdirAll(targetDir, 0755)
+}
+
+func TestPullModel_PinnedDigest(t *testing.T) {
+	worker := newWorkerWithMockPuller(t, nil)
+	puller := &referencePuller{}
+	worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return puller
+	}
+
+	dgst := "sha256:" + strings.Repeat("a", 64)
+	modelDir := worker.cfg.Get().GetModelDir("pvc-pinned")
+	require.NoError(t, worker.PullModel(context.Background(), true, "pvc-pinned", "", "test/model:latest", modelDir, PullOptions{Digest: dgst}))
+	require.Equal(t, []string{"docker.io/test/model@" + dgst}, puller.references)
+
+	modelStatus, err := worker.sm.Get(filepath.Join(filepath.Dir(modelDir), "status.json"))
+	require.NoError(t, err)
+	require.Equal(t, "test/model:latest", modelStatus.Reference)
+	require.Equal(t, dgst, modelStatus.Digest)
+}
+
+// filteringPuller pulls the files included by the filters, the excluded ones
+// are recorded in the hook.
+type filteringPuller struct {
+	hook  *status.Hook
+	files []string
+}
+
+func (p *filteringPuller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	include := includeLayerFunc(ctx, p.hook, excludeModelWeights, excludeFilePatterns)
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+	for _, file := range p.files {
+		if include(backend.InspectedModelArtifactLayer{Filepath: file}) {
+			if err := os.WriteFile(filepath.Join(targetDir, file), []byte(file), 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func TestPullModel_ExcludedFiles(t *testing.T) {
+	worker := newWorkerWithMockPuller(t, nil)
+	worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return &filteringPuller{hook: hook, files: []string{"config.json", "model.safetensors", "README.md"}}
+	}
+
+	opts := PullOptions{ExcludeModelWeights: true, ExcludeFilePatterns: []string{"*.md"}}
+	modelDir := worker.cfg.Get().GetModelDir("pvc-excluded")
+	require.NoError(t, worker.PullModel(context.Background(), true, "pvc-excluded", "", "test/model:latest", modelDir, opts))
+	require.FileExists(t, filepath.Join(modelDir, "config.json"))
+	require.NoFileExists(t, filepath.Join(modelDir, "model.safetensors"))
+
+	modelStatus, err := worker.sm.Get(filepath.Join(filepath.Dir(modelDir), "status.json"))
+	require.NoError(t, err)
+	require.True(t, modelStatus.ExcludeModelWeights)
+	require.Equal(t, []string{"*.md"}, modelStatus.ExcludeFilePatterns)
+	require.Equal(t, []string{"README.md", "model.safetensors"}, modelStatus.ExcludedFiles)
+	require.Positive(t, modelStatus.SizeInBytes)
+
+	// The excluded files are kept for the model reused from another volume.
+	modelDir = worker.cfg.Get().GetModelDir("pvc-excluded-reused")
+	require.NoError(t, worker.PullModel(context.Background(), true, "pvc-excluded-reused", "", "test/model:latest", modelDir, opts))
+	modelStatus, err = worker.sm.Get(filepath.Join(filepath.Dir(modelDir), "status.json"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"README.md", "model.safetensors"}, modelStatus.ExcludedFiles)
+}
+
+// weightsPuller blocks the pull of the weights until released.
+type weightsPuller struct {
+	filteringPuller
+	release chan struct{}
+}
+
+func (p *weightsPuller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	if !excludeModelWeights {
+		select {
+		case <-p.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return p.filteringPuller.Pull(ctx, reference, targetDir, excludeModelWeights, excludeFilePatterns)
+}
+
+func TestPullModel_BackgroundWeights(t *testing.T) {
+	worker := newWorkerWithMockPuller(t, nil)
+	release := make(chan struct{})
+	worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return &weightsPuller{
+			filteringPuller: filteringPuller{hook: hook, files: []string{"config.json", "model.safetensors"}},
+			release:         release,
+		}
+	}
+
+	ctx := context.Background()
+	opts := PullOptions{BackgroundWeights: true}
+	modelDir := worker.cfg.Get().GetModelDir("pvc-background")
+	statusPath := filepath.Join(filepath.Dir(modelDir), "status.json")
+	require.NoError(t, worker.PullModel(ctx, true, "pvc-background", "", "test/model:latest", modelDir, opts))
+
+	// The volume is ready once the files except the weights are pulled.
+	require.FileExists(t, filepath.Join(modelDir, "config.json"))
+	require.NoFileExists(t, filepath.Join(modelDir, "model.safetensors"))
+	modelStatus, err := worker.sm.Get(statusPath)
+	require.NoError(t, err)
+	require.Equal(t, status.StateWeightsPulling, modelStatus.State)
+	require.Empty(t, modelStatus.ExcludedFiles)
+	require.Zero(t, modelStatus.SizeInBytes)
+
+	// The volume mounted in the meantime is MOUNTED once the weights are pulled.
+	modelStatus.AddTarget(status.Target{Path: "/target"})
+	_, err = worker.sm.Set(statusPath, *modelStatus)
+	require.NoError(t, err)
+	close(release)
+	require.Eventually(t, func() bool {
+		modelStatus, err := worker.sm.Get(statusPath)
+		return err == nil && modelStatus.State == status.StateMounted
+	}, 5*time.Second, 10*time.Millisecond)
+	modelStatus, err = worker.sm.Get(statusPath)
+	require.NoError(t, err)
+	require.Positive(t, modelStatus.SizeInBytes)
+	require.FileExists(t, filepath.Join(modelDir, "model.safetensors"))
+	require.FileExists(t, filepath.Join(modelDir, "config.json"))
+
+	// The pull of the weights is canceled on deleting the volume.
+	release = make(chan struct{})
+	modelDir = worker.cfg.Get().GetModelDir("pvc-background-deleted")
+	require.NoError(t, worker.PullModel(ctx, true, "pvc-background-deleted", "", "test/other:latest", modelDir, opts))
+	require.NoError(t, worker.DeleteModel(ctx, true, "pvc-background-deleted", ""))
+	require.NoDirExists(t, worker.cfg.Get().GetVolumeDir("pvc-background-deleted"))
+}
+
+func TestFailInterruptedWeightsPulls(t *testing.T) {
+	worker := newWorkerWithMockPuller(t, nil)
+	statusPath := filepath.Join(worker.cfg.Get().GetVolumeDir("pvc-interrupted-weights"), "status.json")
+	_, err := worker.sm.Set(statusPath, status.Status{VolumeName: "pvc-interrupted-weights", State: status.StateWeightsPulling})
+	require.NoError(t, err)
+	pulledPath := filepath.Join(worker.cfg.Get().GetVolumeDir("pvc-pulled"), "status.json")
+	_, err = worker.sm.Set(pulledPath, status.Status{VolumeName: "pvc-pulled", State: status.StatePullSucceeded})
+	require.NoError(t, err)
+
+	worker.FailInterruptedWeightsPulls(context.Background())
+	modelStatus, err := worker.sm.Get(statusPath)
+	require.NoError(t, err)
+	require.Equal(t, status.StatePullFailed, modelStatus.State)
+	modelStatus, err = worker.sm.Get(pulledPath)
+	require.NoError(t, err)
+	require.Equal(t, status.StatePullSucceeded, modelStatus.State)
+}
+
+func TestPullModel_Timeout(t *testing.T) {
+	worker := newWorkerWithMockPuller(t, nil)
+	worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return &weightsPuller{
+			filteringPuller: filteringPuller{hook: hook, files: []string{"config.json", "model.safetensors"}},
+			release:         make(chan struct{}),
+		}
+	}
+
+	ctx := context.Background()
+	modelDir := worker.cfg.Get().GetModelDir("pvc-timeout")
+	err := worker.PullModel(ctx, true, "pvc-timeout", "", "test/model:latest", modelDir, PullOptions{Timeout: 50 * time.Millisecond})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorContains(t, err, "pull model timeout")
+	// The status of the failed pull is kept by the error.
+	var pullErr *PullError
+	require.ErrorAs(t, err, &pullErr)
+	require.Equal(t, "pvc-timeout", pullErr.Status.VolumeName)
+	require.Equal(t, status.StatePullTimeout, pullErrorReason(err))
+
+	// The weights pulled in the background are bounded by the same deadline.
+	modelDir = worker.cfg.Get().GetModelDir("pvc-timeout-background")
+	statusPath := filepath.Join(filepath.Dir(modelDir), "status.json")
+	opts := PullOptions{BackgroundWeights: true, Timeout: 200 * time.Millisecond}
+	require.NoError(t, worker.PullModel(ctx, true, "pvc-timeout-background", "", "test/model:latest", modelDir, opts))
+	require.Eventually(t, func() bool {
+		modelStatus, err := worker.sm.Get(statusPath)
+		return err == nil && modelStatus.State == status.StatePullTimeout
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/modelpack/modctl/pkg/backend"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+const pullStateFile = "pull_state.json"
+
+// The pull state of the completed pull of the model image, kept beside
+// status.json, so that the model pulled again into the model dir (e.g.
+// another tag of the same model) only fetches the changed layers.
+const pulledLayersFile = "pulled_layers.json"
+
+// pullState records the layers pulled into the model dir, it's persisted
+// beside status.json during the pull, so that the pull interrupted by a
+// driver restart or a retryable error resumes from the pulled layers
+// instead of from zero.
+//
+// The model image pulled by modctl is resumed at layer granularity, as modctl
+// can't resume a partially pulled layer, which is pulled again. The model
+// files served over HTTP (e.g. HuggingFace and S3) are resumed by range from
+// the partially downloaded files.
+type pullState struct {
+	// The key of the model content being pulled, see pullStateKey.
+	Key string `json:"key"`
+	// The digests of the pulled layers keyed by file path.
+	Layers map[string]string `json:"layers"`
+}
+
+// pullStateKey returns the key of the model content being pulled, the layers
+// pulled with different model types or filters are not resumed.
+func pullStateKey(reference string, opts PullOptions) string {
+	return pullKey(reference, PullOptions{
+		Type:                opts.Type,
+		ExcludeModelWeights: opts.ExcludeModelWeights,
+		ExcludeFilePatterns: opts.ExcludeFilePatterns,
+	})
+}
+
+// /var/lib/dragonfly/model-csi/volumes/$volumeName/pull_state.json
+func getPullStatePath(modelDir string) string {
+	return filepath.Join(filepath.Dir(modelDir), pullStateFile)
+}
+
+// /var/lib/dragonfly/model-csi/volumes/$volumeName/pulled_layers.json
+func getPulledLayersPath(modelDir string) string {
+	return filepath.Join(filepath.Dir(modelDir), pulledLayersFile)
+}
+
+func readPullState(statePath string) (*pullState, error) {
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var state pullState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, errors.Wrapf(err, "unmarshal pull state: %s", statePath)
+	}
+	if state.Layers == nil {
+		state.Layers = map[string]string{}
+	}
+
+	return &state, nil
+}
+
+func loadPullState(statePath, key string) *pullState {
+	state, err := readPullState(statePath)
+	if err != nil || state.Key != key {
+		return &pullState{
+			Key:    key,
+			Layers: map[string]string{},
+		}
+	}
+	return state
+}
+
+// canResumePull checks if the model dir is left by an interrupted pull of
+// the same model content.
+func canResumePull(modelDir, key string) bool {
+	state, err := readPullState(getPullStatePath(modelDir))
+	return err == nil && state.Key == key
+}
+
+func (state *pullState) isPulled(modelDir string, layer backend.InspectedModelArtifactLayer) bool {
+	if layer.Filepath == "" || state.Layers[layer.Filepath] != layer.Digest {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(modelDir, layer.Filepath))
+	return err == nil
+}
+
+// resumeHook persists the pulled layers into the pull state.
+type resumeHook struct {
+	*status.Hook
+
+	ctx       context.Context
+	mutex     sync.Mutex
+	statePath string
+	state     *pullState
+}
+
+func newResumeHook(ctx context.Context, hook *status.Hook, statePath string, state *pullState) *resumeHook {
+	return &resumeHook{
+		Hook:      hook,
+		ctx:       ctx,
+		statePath: statePath,
+		state:     state,
+	}
+}
+
+func (h *resumeHook) AfterPullLayer(desc ocispec.Descriptor, err error) {
+	h.Hook.AfterPullLayer(desc, err)
+
+	filePath := status.LayerFilepath(desc)
+	if err != nil || filePath == "" {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.state.Layers[filePath] = desc.Digest.String()
+	if err := h.save(); err != nil {
+		logger.WithContext(h.ctx).WithError(err).Warnf("failed to save pull state")
+	}
+}
+
+func (h *resumeHook) save() error {
+	data, err := json.Marshal(h.state)
+	if err != nil {
+		return errors.Wrap(err, "marshal pull state")
+	}
+
+	tmpPath := h.statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return errors.Wrapf(err, "write pull state: %s", tmpPath)
+	}
+	if err := os.Rename(tmpPath, h.statePath); err != nil {
+		return errors.Wrapf(err, "rename pull state: %s", h.statePath)
+	}
+
+	return nil
+}
+
+// complete keeps the pull state as the pulled layers of the model dir once
+// the pull succeeded, see seedPullState.
+func (h *resumeHook) complete() error {
+	if err := os.Rename(h.statePath, filepath.Join(filepath.Dir(h.statePath), pulledLayersFile)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "rename pull state: %s", h.statePath)
+	}
+	return nil
+}
+
+func (h *resumeHook) remove() error {
+	if err := os.Remove(h.statePath); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "remove pull state: %s", h.statePath)
+	}
+	return nil
+}
+
+// seedPullState prepares the model dir holding the model image pulled before
+// to be pulled incrementally, e.g. by another tag of the same model: the files
+// of the layers pulled before are kept and recorded in the pull state of the
+// new pull, so that the puller only fetches the layers whose digest differs,
+// the other files in the model dir are removed. It returns false if there are
+// no layers pulled before.
+func seedPullState(ctx context.Context, modelDir, key string) (bool, error) {
+	pulledLayersPath := getPulledLayersPath(modelDir)
+	previous, err := readPullState(pulledLayersPath)
+	if err != nil || len(previous.Layers) == 0 {
+		return false, nil
+	}
+	if _, err := os.Stat(modelDir); err != nil {
+		return false, nil
+	}
+
+	// The layer may be a dir, whose files are kept with it.
+	layerPaths := map[string]bool{}
+	for filePath := range previous.Layers {
+		layerPaths[path.Clean(filepath.ToSlash(filePath))] = true
+	}
+	isLayerFile := func(relPath string) bool {
+		for ; relPath != "." && relPath != "/"; relPath = path.Dir(relPath) {
+			if layerPaths[relPath] {
+				return true
+			}
+		}
+		return false
+	}
+
+	// The files not of the layers, e.g. the checksum file and the adapters,
+	// are written again by the new pull.
+	err = filepath.WalkDir(modelDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(modelDir, filePath)
+		if err != nil {
+			return err
+		}
+		if isLayerFile(filepath.ToSlash(relPath)) {
+			return nil
+		}
+		return os.Remove(filePath)
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "cleanup model dir: %s", modelDir)
+	}
+
+	state := &pullState{Key: key, Layers: previous.Layers}
+	if err := newResumeHook(ctx, nil, getPullStatePath(modelDir), state).save(); err != nil {
+		return false, err
+	}
+	if err := os.Remove(pulledLayersPath); err != nil && !os.IsNotExist(err) {
+		return false, errors.Wrapf(err, "remove pulled layers: %s", pulledLayersPath)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelpack/modctl/pkg/backend"
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/fault"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	modelspec "github.com/modelpack/model-spec/specs-go/v1"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullState_ResumeHook(t *testing.T) {
+	modelDir := filepath.Join(t.TempDir(), "model")
+	require.NoError(t, os.MkdirAll(modelDir, 0755))
+	statePath := getPullStatePath(modelDir)
+	key := pullStateKey("test/model:latest", PullOptions{})
+
+	require.False(t, canResumePull(modelDir, key))
+
+	ctx := context.Background()
+	state := loadPullState(statePath, key)
+	hook := newResumeHook(ctx, status.NewHook(ctx), statePath, state)
+
+	desc := ocispec.Descriptor{
+		Digest:      digest.FromString("weights"),
+		Annotations: map[string]string{modelspec.AnnotationFilepath: "weights.bin"},
+	}
+	hook.BeforePullLayer(desc, ocispec.Manifest{Layers: []ocispec.Descriptor{desc}})
+	hook.AfterPullLayer(desc, nil)
+
+	failed := ocispec.Descriptor{
+		Digest:      digest.FromString("config"),
+		Annotations: map[string]string{modelspec.AnnotationFilepath: "config.json"},
+	}
+	hook.BeforePullLayer(failed, ocispec.Manifest{Layers: []ocispec.Descriptor{desc, failed}})
+	hook.AfterPullLayer(failed, errors.New("network error"))
+
+	require.True(t, canResumePull(modelDir, key))
+	require.False(t, canResumePull(modelDir, pullStateKey("test/model:v2", PullOptions{})))
+	require.False(t, canResumePull(modelDir, pullStateKey("test/model:latest", PullOptions{ExcludeModelWeights: true})))
+
+	loaded := loadPullState(statePath, key)
+	require.Equal(t, map[string]string{"weights.bin": desc.Digest.String()}, loaded.Layers)
+
+	layer := backend.InspectedModelArtifactLayer{Filepath: "weights.bin", Digest: desc.Digest.String()}
+	// The pulled file is removed.
+	require.False(t, loaded.isPulled(modelDir, layer))
+	require.NoError(t, os.WriteFile(filepath.Join(modelDir, "weights.bin"), []byte("weights"), 0644))
+	require.True(t, loaded.isPulled(modelDir, layer))
+	require.False(t, loaded.isPulled(modelDir, backend.InspectedModelArtifactLayer{Filepath: "config.json", Digest: failed.Digest.String()}))
+
+	require.NoError(t, hook.remove())
+	require.NoError(t, hook.remove())
+	require.False(t, canResumePull(modelDir, key))
+}
+
+func TestSeedPullState(t *testing.T) {
+	ctx := context.Background()
+	modelDir := filepath.Join(t.TempDir(), "model")
+	key := pullStateKey("test/model:v2", PullOptions{})
+
+	// Nothing is pulled before.
+	seeded, err := seedPullState(ctx, modelDir, key)
+	require.NoError(t, err)
+	require.False(t, seeded)
+
+	for _, filePath := range []string{"weights.bin", "tokenizer/vocab.json", "SHA256SUMS", "adapters/sql/weights.bin"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(modelDir, filePath)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(modelDir, filePath), []byte(filePath), 0644))
+	}
+	layers := map[string]string{"weights.bin": "sha256:weights", "tokenizer": "sha256:tokenizer"}
+	statePath := getPullStatePath(modelDir)
+	hook := newResumeHook(ctx, nil, statePath, &pullState{Key: pullStateKey("test/model:v1", PullOptions{}), Layers: layers})
+	require.NoError(t, hook.save())
+	require.NoError(t, hook.complete())
+	require.NoFileExists(t, statePath)
+
+	seeded, err = seedPullState(ctx, modelDir, key)
+	require.NoError(t, err)
+	require.True(t, seeded)
+	// The files of the layers are kept for the pull resuming from them.
+	require.FileExists(t, filepath.Join(modelDir, "weights.bin"))
+	require.FileExists(t, filepath.Join(modelDir, "tokenizer", "vocab.json"))
+	require.NoFileExists(t, filepath.Join(modelDir, "SHA256SUMS"))
+	require.NoFileExists(t, filepath.Join(modelDir, "adapters", "sql", "weights.bin"))
+	require.True(t, canResumePull(modelDir, key))
+	require.Equal(t, layers, loadPullState(statePath, key).Layers)
+	require.NoFileExists(t, getPulledLayersPath(modelDir))
+}
+
+func TestPullState_FaultHook(t *testing.T) {
+	fault.Setup(config.NewWithRaw(&config.RawConfig{
+		FaultInjection: config.FaultInjection{Enabled: true, LayerFailureRate: 1},
+	}))
+	defer fault.Setup(config.NewWithRaw(&config.RawConfig{}))
+
+	modelDir := filepath.Join(t.TempDir(), "model")
+	require.NoError(t, os.MkdirAll(modelDir, 0755))
+	statePath := getPullStatePath(modelDir)
+	key := pullStateKey("test/model:latest", PullOptions{})
+
+	ctx := context.Background()
+	hook := newResumeHook(ctx, status.NewHook(ctx), statePath, loadPullState(statePath, key))
+	layerHook := newFaultHook(ctx, hook)
+	require.NoError(t, layerHook.Err())
+
+	desc := ocispec.Descriptor{
+		Digest:      digest.FromString("weights"),
+		Annotations: map[string]string{modelspec.AnnotationFilepath: "weights.bin"},
+	}
+	layerHook.BeforePullLayer(desc, ocispec.Manifest{Layers: []ocispec.Descriptor{desc}})
+	layerHook.AfterPullLayer(desc, nil)
+
+	// The failed layer is not recorded to be resumed.
+	require.ErrorIs(t, layerHook.Err(), fault.ErrInjected)
+	require.Empty(t, loadPullState(statePath, key).Layers)
+}
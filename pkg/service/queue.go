@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+)
+
+// PullQueue admits the model pulls on the node with bounded parallelism, the
+// pulls beyond the limit wait in the queue ordered by the priority, and in
+// FIFO order for the same priority.
+type PullQueue struct {
+	mutex   sync.Mutex
+	limit   func() int
+	running int
+	waiters []*pullWaiter
+}
+
+type pullWaiter struct {
+	priority   int
+	ready      chan struct{}
+	admitted   bool
+	onPosition func(position int)
+}
+
+// NewPullQueue creates the pull queue, the limit is read on each admission so
+// that it follows the config reload, the non-positive limit is unlimited.
+func NewPullQueue(limit func() int) *PullQueue {
+	return &PullQueue{
+		limit: limit,
+	}
+}
+
+func (q *PullQueue) admittable() bool {
+	limit := q.limit()
+	return limit <= 0 || q.running < limit
+}
+
+// dispatch admits the waiters while there are free slots, and reports the
+// new positions to the remaining waiters, it must be called with the lock.
+func (q *PullQueue) dispatch() {
+	for len(q.waiters) > 0 && q.admittable() {
+		waiter := q.waiters[0]
+		q.waiters = q.waiters[1:]
+		q.running++
+		waiter.admitted = true
+		close(waiter.ready)
+	}
+	for idx, waiter := range q.waiters {
+		waiter.onPosition(idx + 1)
+	}
+}
+
+// Acquire waits until the pull is admitted, the 1-based position of the
+// queued pull is reported by onPosition whenever it changes, e.g. the pull of
+// the higher priority jumps ahead, the returned release must be called once
+// the pull is done.
+func (q *PullQueue) Acquire(ctx context.Context, priority int, onPosition func(position int)) (func(), error) {
+	q.mutex.Lock()
+	q.dispatch()
+	if len(q.waiters) == 0 && q.admittable() {
+		q.running++
+		q.mutex.Unlock()
+		return q.releaser(), nil
+	}
+	waiter := &pullWaiter{
+		priority:   priority,
+		ready:      make(chan struct{}),
+		onPosition: onPosition,
+	}
+	idx := sort.Search(len(q.waiters), func(i int) bool {
+		return q.waiters[i].priority < priority
+	})
+	q.waiters = append(q.waiters[:idx], append([]*pullWaiter{waiter}, q.waiters[idx:]...)...)
+	q.dispatch()
+	q.mutex.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return q.releaser(), nil
+	case <-ctx.Done():
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if waiter.admitted {
+		// Admitted in the meantime, hand over the slot to the next waiter.
+		q.running--
+	} else {
+		for idx := range q.waiters {
+			if q.waiters[idx] == waiter {
+				q.waiters = append(q.waiters[:idx], q.waiters[idx+1:]...)
+				break
+			}
+		}
+	}
+	q.dispatch()
+
+	return nil, ctx.Err()
+}
+
+func (q *PullQueue) releaser() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mutex.Lock()
+			defer q.mutex.Unlock()
+			q.running--
+			q.dispatch()
+		})
+	}
+}
+
+// admittedPuller pulls the model once it's admitted by the node-wide pull
+// queue, the volume is in PULL_QUEUED state with the queue position in the
+// progress while it's waiting.
+type admittedPuller struct {
+	Puller
+	queue    *PullQueue
+	priority int
+	hook     *status.Hook
+	setState func(state status.State) error
+}
+
+func (p *admittedPuller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	// The onPosition is called with the lock of the queue, so that the
+	// positions are reported in order.
+	queued := false
+	release, err := p.queue.Acquire(ctx, p.priority, func(position int) {
+		if !queued {
+			queued = true
+			logger.WithContext(ctx).Infof("pull of model %s is queued at position %d", reference, position)
+			if err := p.setState(status.StatePullQueued); err != nil {
+				logger.WithContext(ctx).WithError(err).Warnf("failed to set queued status")
+			}
+		}
+		p.hook.SetQueuePosition(position)
+	})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if queued {
+		p.hook.SetQueuePosition(0)
+		if err := p.setState(status.StatePullRunning); err != nil {
+			return err
+		}
+	}
+
+	return p.Puller.Pull(ctx, reference, targetDir, excludeModelWeights, excludeFilePatterns)
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullQueue(t *testing.T) {
+	ctx := context.Background()
+	queue := NewPullQueue(func() int { return 1 })
+
+	release1, err := queue.Acquire(ctx, 0, func(position int) {
+		t.Fatalf("unexpected queued position: %d", position)
+	})
+	require.NoError(t, err)
+
+	var mutex sync.Mutex
+	positions := map[string]int{}
+	onPosition := func(name string) func(int) {
+		return func(position int) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			positions[name] = position
+		}
+	}
+	getPosition := func(name string) int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return positions[name]
+	}
+
+	ctx2, cancel2 := context.WithCancel(ctx)
+	errCh2 := make(chan error, 1)
+	go func() {
+		_, err := queue.Acquire(ctx2, 0, onPosition("pull2"))
+		errCh2 <- err
+	}()
+	require.Eventually(t, func() bool { return getPosition("pull2") == 1 }, 5*time.Second, time.Millisecond)
+
+	releaseCh3 := make(chan func(), 1)
+	go func() {
+		// The release is nil on error.
+		release, _ := queue.Acquire(ctx, 0, onPosition("pull3"))
+		releaseCh3 <- release
+	}()
+	require.Eventually(t, func() bool { return getPosition("pull3") == 2 }, 5*time.Second, time.Millisecond)
+
+	// The canceled pull leaves the queue.
+	cancel2()
+	require.ErrorIs(t, <-errCh2, context.Canceled)
+	require.Equal(t, 1, getPosition("pull3"))
+
+	select {
+	case <-releaseCh3:
+		t.Fatal("pull3 is admitted beyond the limit")
+	default:
+	}
+
+	release1()
+	// The release is idempotent.
+	release1()
+	release3 := <-releaseCh3
+	require.NotNil(t, release3)
+	release3()
+
+	release4, err := queue.Acquire(ctx, 0, func(position int) {
+		t.Fatalf("unexpected queued position: %d", position)
+	})
+	require.NoError(t, err)
+	release4()
+}
+
+func TestPullQueue_Priority(t *testing.T) {
+	ctx := context.Background()
+	queue := NewPullQueue(func() int { return 1 })
+
+	release, err := queue.Acquire(ctx, 0, func(position int) {})
+	require.NoError(t, err)
+
+	var mutex sync.Mutex
+	positions := map[string]int{}
+	admitted := make(chan string, 4)
+	acquire := func(name string, priority int) {
+		release, err := queue.Acquire(ctx, priority, func(position int) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			positions[name] = position
+		})
+		if err == nil {
+			admitted <- name
+			release()
+		}
+	}
+	getPosition := func(name string) int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return positions[name]
+	}
+
+	go acquire("prefetch", -10)
+	require.Eventually(t, func() bool { return getPosition("prefetch") == 1 }, 5*time.Second, time.Millisecond)
+	go acquire("normal", 0)
+	require.Eventually(t, func() bool { return getPosition("normal") == 1 }, 5*time.Second, time.Millisecond)
+	go acquire("normal-2", 0)
+	require.Eventually(t, func() bool { return getPosition("normal-2") == 2 }, 5*time.Second, time.Millisecond)
+	go acquire("inference", 10)
+	// The pull of the higher priority jumps ahead of the queued pulls.
+	require.Eventually(t, func() bool {
+		return getPosition("inference") == 1 && getPosition("normal") == 2 &&
+			getPosition("normal-2") == 3 && getPosition("prefetch") == 4
+	}, 5*time.Second, time.Millisecond)
+
+	release()
+	for _, name := range []string{"inference", "normal", "normal-2", "prefetch"} {
+		require.Equal(t, name, <-admitted)
+	}
+}
+
+func TestPullModel_Queued(t *testing.T) {
+	worker := newWorkerWithMockPuller(t, nil)
+	worker.cfg.Get().PullConfig.MaxConcurrentPulls = 1
+	puller := &blockingPuller{started: make(chan struct{}), release: make(chan struct{})}
+	worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return puller
+	}
+
+	ctx := context.Background()
+	errCh := make(chan error, 2)
+	pull := func(volumeName, reference string) {
+		modelDir := filepath.Join(worker.cfg.Get().GetVolumeDir(volumeName), "model")
+		errCh <- worker.PullModel(ctx, true, volumeName, "", reference, modelDir, PullOptions{})
+	}
+	getStatus := func(volumeName string) *status.Status {
+		modelStatus, _ := worker.sm.Get(filepath.Join(worker.cfg.Get().GetVolumeDir(volumeName), "status.json"))
+		return modelStatus
+	}
+
+	go pull("pvc-queued-1", "test/model-1:latest")
+	require.Eventually(t, func() bool {
+		modelStatus := getStatus("pvc-queued-1")
+		return modelStatus != nil && modelStatus.State == status.StatePullRunning
+	}, 5*time.Second, time.Millisecond)
+
+	go pull("pvc-queued-2", "test/model-2:latest")
+	require.Eventually(t, func() bool {
+		modelStatus := getStatus("pvc-queued-2")
+		return modelStatus != nil && modelStatus.State == status.StatePullQueued && modelStatus.Progress.QueuePosition == 1
+	}, 5*time.Second, time.Millisecond)
+
+	close(puller.release)
+	require.NoError(t, <-errCh)
+	require.NoError(t, <-errCh)
+
+	modelStatus := getStatus("pvc-queued-2")
+	require.Equal(t, status.StatePullSucceeded, modelStatus.State)
+	require.Equal(t, 0, modelStatus.Progress.QueuePosition)
+}
@@ -16,13 +16,27 @@ import (
 
 type DiskQuotaChecker struct {
 	cfg *config.Config
+	// evict evicts the models cached on the node until the used size drops
+	// to the target, it returns the number of the evicted models.
+	evict func(ctx context.Context, targetUsedSize int64) (int, error)
+	// The ledger the checked size is reserved in for the volume dir until
+	// the pull is finished.
+	reservations *QuotaReservations
+	volumeDir    string
+	// Limit the volume dir to the checked size by the project quota.
+	projectQuota bool
 }
 
 func getUsedSize(path string) (int64, error) {
 	var total int64 = 0
 	inodes := make(map[uint64]bool)
 
-	err := filepath.Walk(path, func(fname string, info os.FileInfo, err error) error {
+	// The volume dir placed on a storage tier is linked from root_dir.
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return 0, err
+	}
+	err = filepath.Walk(path, func(fname string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -64,23 +78,10 @@ func humanizeBytes(size int64) string {
 // If cfg.Features.CheckDiskQuota is enabled and the Mount request specifies checkDiskQuota = true:
 // - When cfg.Features.DiskUsageLimit == 0: reject if available disk space < model size;
 // - When cfg.Features.DiskUsageLimit > 0: reject if (cfg.Features.DiskUsageLimit - used space) < model size;
+//
+// The size checked for the in-flight pulls is reserved until the pulls are
+// finished, and the reserved size not written yet isn't available.
 func (d *DiskQuotaChecker) Check(ctx context.Context, modelArtifact *ModelArtifact, excludeModelWeights bool, excludeFilePatterns []string) error {
-	availSize := int64(0)
-
-	if d.cfg.Get().Features.DiskUsageLimit > 0 {
-		usedSize, err := getUsedSize(d.cfg.Get().RootDir)
-		if err != nil {
-			return errors.Wrap(err, "get root dir used size")
-		}
-		availSize = int64(d.cfg.Get().Features.DiskUsageLimit) - usedSize
-	} else {
-		var st syscall.Statfs_t
-		if err := syscall.Statfs(d.cfg.Get().RootDir, &st); err != nil {
-			return errors.Wrap(err, "stat root dir")
-		}
-		availSize = int64(st.Bavail) * int64(st.Bsize)
-	}
-
 	start := time.Now()
 	modelSize, err := modelArtifact.GetSize(ctx, excludeModelWeights, excludeFilePatterns)
 	if err != nil {
@@ -88,17 +89,79 @@ func (d *DiskQuotaChecker) Check(ctx context.Context, modelArtifact *ModelArtifa
 	}
 	logger.WithContext(ctx).Infof("get model %s, size: %s, duration: %s", modelArtifact.Reference, humanizeBytes(modelSize), time.Since(start))
 
+	return d.CheckSize(ctx, modelArtifact.Reference, modelSize)
+}
+
+// CheckSize checks if there is enough disk quota for the model of the size,
+// it's used by the pullers of the models not in image format.
+func (d *DiskQuotaChecker) CheckSize(ctx context.Context, reference string, modelSize int64) error {
+	if err := d.checkSize(ctx, reference, modelSize); err != nil {
+		return err
+	}
+	d.applyProjectQuota(ctx, reference, modelSize)
+	return nil
+}
+
+// CheckMinSize is CheckSize for the size less than the model, e.g. the size
+// of the compressed layers, the volume dir isn't limited by the size.
+func (d *DiskQuotaChecker) CheckMinSize(ctx context.Context, reference string, minSize int64) error {
+	return d.checkSize(ctx, reference, minSize)
+}
+
+func (d *DiskQuotaChecker) checkSize(ctx context.Context, reference string, modelSize int64) error {
+	// The check and the reservation are atomic among the pulls, the size
+	// reserved by the other pulls isn't available.
+	reserve := d.reservations != nil && d.volumeDir != ""
+	var reservedSize int64
+	if reserve {
+		d.reservations.mutex.Lock()
+		defer d.reservations.mutex.Unlock()
+		reservedSize = d.reservations.pendingSize(d.volumeDir)
+	}
+
+	usedSize, totalSize, err := diskUsage(d.cfg.Get())
+	if err != nil {
+		return err
+	}
+	availSize := totalSize - usedSize - reservedSize
+
+	// Make room for the model by evicting the cached models instead of
+	// failing the pull.
+	if modelSize > availSize && d.evict != nil {
+		evicted, err := d.evict(ctx, totalSize-reservedSize-modelSize)
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("failed to evict models for %s", reference)
+		}
+		if evicted > 0 {
+			if usedSize, totalSize, err = diskUsage(d.cfg.Get()); err != nil {
+				return err
+			}
+			availSize = totalSize - usedSize - reservedSize
+		}
+	}
+
 	logger.WithContext(ctx).Infof(
-		"root dir maximum limit size: %s, available: %s, model: %s",
-		humanizeBytes(int64(d.cfg.Get().Features.DiskUsageLimit)), humanizeBytes(availSize), humanizeBytes(modelSize),
+		"root dir maximum limit size: %s, available: %s, reserved: %s, model: %s",
+		humanizeBytes(int64(d.cfg.Get().Features.DiskUsageLimit)), humanizeBytes(availSize), humanizeBytes(reservedSize), humanizeBytes(modelSize),
 	)
 
 	if modelSize > availSize {
 		return errors.Wrapf(
-			syscall.ENOSPC, "model image %s is %s, but only %s of disk quota is available",
-			modelArtifact.Reference, humanizeBytes(modelSize), humanizeBytes(availSize),
+			syscall.ENOSPC, "model image %s is %s, but only %s of disk quota is available, %s is reserved by the in-flight pulls",
+			reference, humanizeBytes(modelSize), humanizeBytes(availSize), humanizeBytes(reservedSize),
 		)
 	}
 
+	if reserve {
+		d.reservations.sizes[d.volumeDir] = modelSize
+	}
+
 	return nil
 }
+
+// Release releases the size reserved for the pull once it's finished.
+func (d *DiskQuotaChecker) Release() {
+	if d.reservations != nil && d.volumeDir != "" {
+		d.reservations.Release(d.volumeDir)
+	}
+}
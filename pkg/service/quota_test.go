@@ -144,7 +144,7 @@ func TestDiskQuotaChecker(t *testing.T) {
 		},
 	})
 
-	modelArtifact := NewModelArtifact(b, "test/model:latest", true)
+	modelArtifact := NewModelArtifact(b, "test/model:latest", true, true)
 
 	checker := NewDiskQuotaChecker(cfg)
 	err = checker.Check(ctx, modelArtifact, false, nil)
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/mounter"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	reconcileReasonStatusMissing   = "status missing"
+	reconcileReasonStatusCorrupted = "status corrupted"
+	reconcileReasonPVNotFound      = "persistent volume not found"
+)
+
+type ReconcileRequest struct {
+	// Return the orphaned volume dirs without removing them.
+	DryRun bool `json:"dry_run"`
+}
+
+// ReconciledVolume is the orphaned volume dir, or the dir of the mount of the
+// dynamic volume, found by the reconciliation.
+type ReconciledVolume struct {
+	VolumeName string `json:"volume_name"`
+	MountID    string `json:"mount_id,omitempty"`
+	Reason     string `json:"reason"`
+	Size       int64  `json:"size"`
+	// The dir is only flagged if it's false on the non dry run, e.g. it's
+	// still mounted or it's the dynamic volume serving the csi.sock.
+	Removed bool `json:"removed"`
+}
+
+type ReconcileResponse struct {
+	DryRun  bool               `json:"dry_run"`
+	Volumes []ReconciledVolume `json:"volumes"`
+	// The bytes released by the removed dirs, or to be released on the dry
+	// run.
+	ReclaimedBytes int64 `json:"reclaimed_bytes"`
+}
+
+// reconcileVolumesOnStart removes the orphaned volume dirs left by the crashes
+// before the driver serves the requests, or only reports them on the dry run.
+func (s *Service) reconcileVolumesOnStart(ctx context.Context) {
+	cfg := s.cfg.Get().Features.ReconcileVolumes
+	if !cfg.Enabled {
+		return
+	}
+	resp, err := s.reconcileVolumes(ctx, cfg.DryRun, 0)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("reconcile volumes failed")
+		return
+	}
+	for _, volume := range resp.Volumes {
+		logger.WithContext(ctx).Warnf(
+			"orphaned volume %s/%s: %s, size: %s, removed: %t",
+			volume.VolumeName, volume.MountID, volume.Reason, humanizeBytes(volume.Size), volume.Removed,
+		)
+	}
+}
+
+// ReconcileVolumes removes the orphaned volume dirs, whose status is missing
+// or corrupted, or whose PV is gone from Kubernetes. The dirs created within
+// OrphanVolumeGracePeriod are skipped, as their volumes may be being created.
+func (s *Service) ReconcileVolumes(ctx context.Context, req ReconcileRequest) (*ReconcileResponse, error) {
+	resp, err := s.reconcileVolumes(ctx, req.DryRun, OrphanVolumeGracePeriod)
+	if err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "reconcile volumes").Error())
+	}
+	return resp, nil
+}
+
+func (s *Service) reconcileVolumes(ctx context.Context, dryRun bool, gracePeriod time.Duration) (*ReconcileResponse, error) {
+	ctx = logger.NewContext(ctx, "ReconcileVolumes", "", "")
+	volumesDir := s.cfg.Get().GetVolumesDir()
+	entries, err := os.ReadDir(volumesDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "read volume dirs from %s", volumesDir)
+	}
+	volumeHandles, err := s.listVolumeHandles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := ReconcileResponse{DryRun: dryRun, Volumes: []ReconciledVolume{}}
+	reconcile := func(volume ReconciledVolume, dir string, removable bool) {
+		if info, err := os.Lstat(dir); err != nil || time.Since(info.ModTime()) < gracePeriod {
+			return
+		}
+		volume.Size = modelDirSize(ctx, dir)
+		resp.Volumes = append(resp.Volumes, volume)
+		if !removable {
+			return
+		}
+		if !dryRun {
+			isStatic := volume.MountID == ""
+			if err := s.worker.DeleteModel(ctx, isStatic, volume.VolumeName, volume.MountID); err != nil {
+				logger.WithContext(ctx).WithError(err).Warnf("failed to remove orphaned volume dir: %s", dir)
+				return
+			}
+			resp.Volumes[len(resp.Volumes)-1].Removed = true
+			logger.WithContext(ctx).Infof("removed orphaned volume dir %s: %s", dir, volume.Reason)
+		}
+		resp.ReclaimedBytes += volume.Size
+	}
+
+	for _, entry := range entries {
+		volumeName := entry.Name()
+		if !isVolumeDirEntry(volumesDir, entry) {
+			continue
+		}
+		if !isStaticVolume(volumeName) && !isDynamicVolume(volumeName) && !isPrefetchVolume(volumeName) {
+			continue
+		}
+		volumeDir := s.cfg.Get().GetVolumeDir(volumeName)
+		volumeStatus, reason := s.reconcileStatus(filepath.Join(volumeDir, "status.json"))
+
+		modelsDir := s.cfg.Get().GetModelsDirForDynamic(volumeName)
+		if _, err := os.Stat(modelsDir); isDynamicVolume(volumeName) && err == nil {
+			// The dynamic volume may still serve the csi.sock, it's cleaned
+			// up by cleanup_orphaned_volumes once its pods are gone.
+			if reason != "" {
+				reconcile(ReconciledVolume{VolumeName: volumeName, Reason: reason}, volumeDir, false)
+			}
+			mountDirs, err := os.ReadDir(modelsDir)
+			if err != nil {
+				logger.WithContext(ctx).WithError(err).Warnf("read model dirs from %s", modelsDir)
+				continue
+			}
+			for _, mountDir := range mountDirs {
+				if !isVolumeDirEntry(modelsDir, mountDir) {
+					continue
+				}
+				mountIDDir := filepath.Join(modelsDir, mountDir.Name())
+				if _, reason := s.reconcileStatus(filepath.Join(mountIDDir, "status.json")); reason != "" {
+					reconcile(ReconciledVolume{VolumeName: volumeName, MountID: mountDir.Name(), Reason: reason}, mountIDDir, true)
+				}
+			}
+			continue
+		}
+
+		if reason == "" && isStaticVolume(volumeName) && volumeHandles != nil && !volumeHandles[volumeName] {
+			reason = reconcileReasonPVNotFound
+		}
+		if reason == "" {
+			continue
+		}
+		reconcile(ReconciledVolume{VolumeName: volumeName, Reason: reason}, volumeDir, !s.isVolumeMounted(ctx, volumeStatus))
+	}
+
+	return &resp, nil
+}
+
+// reconcileStatus returns the status of the volume, or the reason if the
+// status is missing or corrupted.
+func (s *Service) reconcileStatus(statusPath string) (*modelStatus.Status, string) {
+	volumeStatus, err := s.sm.Get(statusPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, reconcileReasonStatusMissing
+		}
+		return nil, reconcileReasonStatusCorrupted
+	}
+	return volumeStatus, ""
+}
+
+// isVolumeMounted returns true if any target path or the staging path of the
+// volume is still mounted, the volume is kept then as it's used by the pods.
+func (s *Service) isVolumeMounted(ctx context.Context, volumeStatus *modelStatus.Status) bool {
+	if volumeStatus == nil {
+		return false
+	}
+	paths := []string{}
+	for _, target := range volumeStatus.Targets {
+		paths = append(paths, target.Path)
+	}
+	if volumeStatus.StagingPath != "" {
+		paths = append(paths, volumeStatus.StagingPath)
+	}
+	for _, path := range paths {
+		isMounted, err := mounter.IsMounted(ctx, path)
+		if err != nil || isMounted {
+			return true
+		}
+	}
+	return false
+}
+
+// listVolumeHandles returns the volume handles of the PVs of the driver, or
+// nil if the PVs can't be listed by the node.
+func (s *Service) listVolumeHandles(ctx context.Context) (map[string]bool, error) {
+	if s.pvs == nil {
+		return nil, nil
+	}
+	pvs, err := s.pvs.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "list persistent volumes")
+	}
+	handles := map[string]bool{}
+	for _, pv := range pvs.Items {
+		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == s.cfg.Get().ServiceName {
+			handles[pv.Spec.CSI.VolumeHandle] = true
+		}
+	}
+	return handles, nil
+}
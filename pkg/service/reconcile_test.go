@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestReconcileVolumes(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	cfg := svc.cfg.Get()
+	clientset := fake.NewSimpleClientset(&corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc-known"},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: cfg.ServiceName, VolumeHandle: "pvc-known"},
+			},
+		},
+	})
+	svc.pvs = clientset.CoreV1().PersistentVolumes()
+
+	newVolumeDir := func(volumeDir string, statusData string) {
+		t.Helper()
+		require.NoError(t, os.MkdirAll(filepath.Join(volumeDir, "model"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(volumeDir, "model", "weights.bin"), []byte("weights"), 0644))
+		if statusData != "" {
+			require.NoError(t, os.WriteFile(filepath.Join(volumeDir, "status.json"), []byte(statusData), 0644))
+		}
+	}
+	succeeded := `{"state": "` + status.StatePullSucceeded + `"}`
+	newVolumeDir(cfg.GetVolumeDir("pvc-known"), succeeded)
+	newVolumeDir(cfg.GetVolumeDir("pvc-deleted"), succeeded)
+	newVolumeDir(cfg.GetVolumeDir("pvc-missing"), "")
+	newVolumeDir(cfg.GetVolumeDir("prefetch-corrupted"), "{")
+	newVolumeDir(cfg.GetVolumeDir("csi-dynamic"), "")
+	newVolumeDir(cfg.GetMountIDDirForDynamic("csi-dynamic", "mount-ok"), succeeded)
+	newVolumeDir(cfg.GetMountIDDirForDynamic("csi-dynamic", "mount-missing"), "")
+	require.NoError(t, os.MkdirAll(cfg.GetVolumeDir("unknown"), 0755))
+
+	requireVolumes := func(resp *ReconcileResponse, removed bool) {
+		t.Helper()
+		require.ElementsMatch(t, []ReconciledVolume{
+			{VolumeName: "pvc-deleted", Reason: reconcileReasonPVNotFound, Removed: removed},
+			{VolumeName: "pvc-missing", Reason: reconcileReasonStatusMissing, Removed: removed},
+			{VolumeName: "prefetch-corrupted", Reason: reconcileReasonStatusCorrupted, Removed: removed},
+			{VolumeName: "csi-dynamic", Reason: reconcileReasonStatusMissing},
+			{VolumeName: "csi-dynamic", MountID: "mount-missing", Reason: reconcileReasonStatusMissing, Removed: removed},
+		}, func() []ReconciledVolume {
+			volumes := []ReconciledVolume{}
+			for _, volume := range resp.Volumes {
+				require.Positive(t, volume.Size)
+				volume.Size = 0
+				volumes = append(volumes, volume)
+			}
+			return volumes
+		}())
+		require.Positive(t, resp.ReclaimedBytes)
+	}
+
+	// The dirs created within the grace period are skipped.
+	resp, err := svc.ReconcileVolumes(ctx, ReconcileRequest{})
+	require.NoError(t, err)
+	require.Empty(t, resp.Volumes)
+
+	// Nothing is removed on the dry run.
+	resp, err = svc.reconcileVolumes(ctx, true, 0)
+	require.NoError(t, err)
+	require.True(t, resp.DryRun)
+	requireVolumes(resp, false)
+	require.DirExists(t, cfg.GetVolumeDir("pvc-missing"))
+
+	resp, err = svc.reconcileVolumes(ctx, false, 0)
+	require.NoError(t, err)
+	requireVolumes(resp, true)
+	for _, volumeName := range []string{"pvc-deleted", "pvc-missing", "prefetch-corrupted"} {
+		require.NoDirExists(t, cfg.GetVolumeDir(volumeName))
+	}
+	require.NoDirExists(t, cfg.GetMountIDDirForDynamic("csi-dynamic", "mount-missing"))
+	require.DirExists(t, cfg.GetMountIDDirForDynamic("csi-dynamic", "mount-ok"))
+	require.DirExists(t, cfg.GetVolumeDir("pvc-known"))
+	require.DirExists(t, cfg.GetVolumeDir("unknown"))
+
+	// The dirs are reconciled once on the start if it's enabled.
+	newVolumeDir(cfg.GetVolumeDir("pvc-missing"), "")
+	svc.reconcileVolumesOnStart(ctx)
+	require.DirExists(t, cfg.GetVolumeDir("pvc-missing"))
+	cfg.Features.ReconcileVolumes.Enabled = true
+	svc.reconcileVolumesOnStart(ctx)
+	require.NoDirExists(t, cfg.GetVolumeDir("pvc-missing"))
+}
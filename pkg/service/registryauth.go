@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config/auth"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// The interval to read the registry auth from the Secrets again if it's not
+// configured.
+var RegistryAuthResyncInterval = time.Minute
+
+// syncRegistryAuthLoop reads the registry auth from the Secrets again at the
+// resync interval, the Secrets and the interval are picked up on the config
+// reload.
+func (s *Service) syncRegistryAuthLoop(secrets v1.SecretsGetter) {
+	for {
+		interval := time.Duration(s.cfg.Get().PullConfig.RegistryAuth.ResyncIntervalInSeconds) * time.Second
+		if interval == 0 {
+			interval = RegistryAuthResyncInterval
+		}
+		time.Sleep(interval)
+		if err := s.syncRegistryAuth(context.Background(), secrets); err != nil {
+			logger.Logger().WithError(err).Warnf("sync registry auth failed")
+		}
+	}
+}
+
+// syncRegistryAuth reads the registry auth from the mounted Secrets and the
+// Secrets by the Kubernetes API, the previous auth is kept if any of them
+// can't be read.
+func (s *Service) syncRegistryAuth(ctx context.Context, secrets v1.SecretsGetter) error {
+	registryAuth := s.cfg.Get().PullConfig.RegistryAuth
+
+	raw := map[string][]byte{}
+	for _, path := range registryAuth.Files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "read registry auth file: %s", path)
+		}
+		raw[path] = data
+	}
+	for _, secret := range registryAuth.Secrets {
+		if secrets == nil {
+			return errors.Errorf("kube client isn't initialized for secret %s, restart the driver", secret)
+		}
+		namespace, name, _ := strings.Cut(secret, "/")
+		got, err := secrets.Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "get registry auth secret: %s", secret)
+		}
+		data, ok := got.Data[corev1.DockerConfigJsonKey]
+		if !ok {
+			return errors.Errorf("registry auth secret %s has no %s", secret, corev1.DockerConfigJsonKey)
+		}
+		raw[secret] = data
+	}
+
+	changed, err := auth.SetSecretConfigs(raw)
+	if err != nil {
+		return err
+	}
+	if changed {
+		logger.Logger().Infof("registry auth reloaded from %d secrets", len(raw))
+	}
+
+	return nil
+}
+
+// The keys of the registry credentials in the CSI secrets of the volume,
+// besides the docker config by corev1.DockerConfigJsonKey, e.g. the Secret of
+// type kubernetes.io/dockerconfigjson.
+const (
+	secretKeyRegistryUsername = "username"
+	secretKeyRegistryPassword = "password"
+)
+
+type registrySecretsKey struct{}
+
+func hasRegistrySecrets(secrets map[string]string) bool {
+	return secrets[corev1.DockerConfigJsonKey] != "" || secrets[secretKeyRegistryUsername] != ""
+}
+
+// withRegistrySecrets returns the context carrying the registry credentials
+// in the CSI secrets of the volume, e.g. by the provisioner-secret-* or the
+// node-publish-secret-* parameters of the StorageClass, which take precedence
+// over the registry auth of the node for the pull of the volume.
+func withRegistrySecrets(ctx context.Context, secrets map[string]string) context.Context {
+	if !hasRegistrySecrets(secrets) {
+		return ctx
+	}
+	return context.WithValue(ctx, registrySecretsKey{}, secrets)
+}
+
+// registrySecretsKeyChain returns the auth of the registry host in the CSI
+// secrets carried by the context, false if the volume has no registry
+// credentials. The username and password are used for the registry of the
+// model image, the empty auth is returned if the docker config in the secrets
+// has no auth of the host.
+func registrySecretsKeyChain(ctx context.Context, host string) (*auth.PassKeyChain, bool, error) {
+	secrets, _ := ctx.Value(registrySecretsKey{}).(map[string]string)
+	if !hasRegistrySecrets(secrets) {
+		return nil, false, nil
+	}
+	if data := secrets[corev1.DockerConfigJsonKey]; data != "" {
+		keyChain, err := auth.FromDockerConfigData([]byte(data), host)
+		if err != nil {
+			return nil, true, errors.Wrap(err, "get registry auth from volume secrets")
+		}
+		return keyChain, true, nil
+	}
+	return &auth.PassKeyChain{
+		Username: secrets[secretKeyRegistryUsername],
+		Password: secrets[secretKeyRegistryPassword],
+	}, true, nil
+}
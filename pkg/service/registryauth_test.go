@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/config/auth"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	modelspec "github.com/modelpack/model-spec/specs-go/v1"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func dockerConfigJSON(host, username, password string) []byte {
+	encoded := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return []byte(fmt.Sprintf(`{"auths":{"%s":{"auth":"%s"}}}`, host, encoded))
+}
+
+func TestSyncRegistryAuth(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	t.Cleanup(func() { _, _ = auth.SetSecretConfigs(nil) })
+	dockerConfigDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dockerConfigDir, "config.json"), []byte(`{"auths":{}}`), 0600))
+	t.Setenv("DOCKER_CONFIG", dockerConfigDir)
+
+	authFile := filepath.Join(t.TempDir(), ".dockerconfigjson")
+	require.NoError(t, os.WriteFile(authFile, dockerConfigJSON("file.registry.io", "file-user", "pass-1"), 0600))
+	clientset := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "model-csi", Name: "registry-auth"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: dockerConfigJSON("secret.registry.io", "secret-user", "pass-1"),
+		},
+	})
+	svc.cfg.Get().PullConfig.RegistryAuth.Files = []string{authFile}
+	svc.cfg.Get().PullConfig.RegistryAuth.Secrets = []string{"model-csi/registry-auth"}
+
+	require.NoError(t, svc.syncRegistryAuth(ctx, clientset.CoreV1()))
+	keyChain, err := auth.FromDockerConfig("file.registry.io")
+	require.NoError(t, err)
+	require.Equal(t, "pass-1", keyChain.Password)
+	keyChain, err = auth.FromDockerConfig("secret.registry.io")
+	require.NoError(t, err)
+	require.Equal(t, "secret-user", keyChain.Username)
+	require.Equal(t, "pass-1", keyChain.Password)
+
+	// The rotated credentials take effect once synced.
+	_, err = clientset.CoreV1().Secrets("model-csi").Update(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "model-csi", Name: "registry-auth"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: dockerConfigJSON("secret.registry.io", "secret-user", "pass-2"),
+		},
+	}, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(authFile, dockerConfigJSON("file.registry.io", "file-user", "pass-2"), 0600))
+	require.NoError(t, svc.syncRegistryAuth(ctx, clientset.CoreV1()))
+	keyChain, err = auth.FromDockerConfig("file.registry.io")
+	require.NoError(t, err)
+	require.Equal(t, "pass-2", keyChain.Password)
+	keyChain, err = auth.FromDockerConfig("secret.registry.io")
+	require.NoError(t, err)
+	require.Equal(t, "pass-2", keyChain.Password)
+
+	// The previous auth is kept if the secret can't be read.
+	svc.cfg.Get().PullConfig.RegistryAuth.Secrets = []string{"model-csi/missing"}
+	require.Error(t, svc.syncRegistryAuth(ctx, clientset.CoreV1()))
+	keyChain, err = auth.FromDockerConfig("secret.registry.io")
+	require.NoError(t, err)
+	require.Equal(t, "pass-2", keyChain.Password)
+
+	// The docker config is the fallback once the secrets are removed.
+	svc.cfg.Get().PullConfig.RegistryAuth.Files = nil
+	svc.cfg.Get().PullConfig.RegistryAuth.Secrets = nil
+	require.NoError(t, svc.syncRegistryAuth(ctx, nil))
+	keyChain, err = auth.FromDockerConfig("secret.registry.io")
+	require.NoError(t, err)
+	require.Equal(t, &auth.PassKeyChain{}, keyChain)
+}
+
+func TestRegistrySecretsKeyChain(t *testing.T) {
+	ctx := context.Background()
+	_, ok, err := registrySecretsKeyChain(withRegistrySecrets(ctx, map[string]string{secretKeyS3AccessKeyID: "key"}), "volume.test.io")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	keyChain, ok, err := registrySecretsKeyChain(withRegistrySecrets(ctx, map[string]string{
+		secretKeyRegistryUsername: "user",
+		secretKeyRegistryPassword: "pass",
+	}), "volume.test.io")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, &auth.PassKeyChain{Username: "user", Password: "pass"}, keyChain)
+
+	secretsCtx := withRegistrySecrets(ctx, map[string]string{
+		corev1.DockerConfigJsonKey: string(dockerConfigJSON("volume.test.io", "user", "pass")),
+	})
+	keyChain, ok, err = registrySecretsKeyChain(secretsCtx, "volume.test.io")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "pass", keyChain.Password)
+	keyChain, ok, err = registrySecretsKeyChain(secretsCtx, "other.test.io")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, &auth.PassKeyChain{}, keyChain)
+
+	_, ok, err = registrySecretsKeyChain(withRegistrySecrets(ctx, map[string]string{corev1.DockerConfigJsonKey: "{"}), "volume.test.io")
+	require.Error(t, err)
+	require.True(t, ok)
+}
+
+func TestPuller_ModelWithRegistrySecrets(t *testing.T) {
+	configFile := []byte(`{"model_type":"qwen3"}`)
+	docLayer := newTarGz(t, []tarEntry{{name: "README.md", content: "# model"}})
+	modelConfig := []byte(`{}`)
+	blobs := map[digest.Digest][]byte{
+		digest.FromBytes(configFile):  configFile,
+		digest.FromBytes(docLayer):    docLayer,
+		digest.FromBytes(modelConfig): modelConfig,
+	}
+	manifestData, err := json.Marshal(ocispec.Manifest{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: modelspec.ArtifactTypeModelManifest,
+		Config: ocispec.Descriptor{
+			MediaType: modelspec.MediaTypeModelConfig,
+			Digest:    digest.FromBytes(modelConfig),
+			Size:      int64(len(modelConfig)),
+		},
+		Layers: []ocispec.Descriptor{
+			{
+				MediaType:   "application/vnd.cncf.model.weight.config.v1.raw",
+				Digest:      digest.FromBytes(configFile),
+				Size:        int64(len(configFile)),
+				Annotations: map[string]string{modelspec.AnnotationFilepath: "config.json"},
+			},
+			{
+				MediaType:   "application/vnd.cncf.model.doc.v1.tar+gzip",
+				Digest:      digest.FromBytes(docLayer),
+				Size:        int64(len(docLayer)),
+				Annotations: map[string]string{modelspec.AnnotationFilepath: "README.md"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	// The registry accepts the credentials of the volume only.
+	host := serveFakeRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "pass" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/v2/test/model/manifests/latest":
+			w.Header().Set("Content-Type", ocispec.MediaTypeImageManifest)
+			w.Header().Set("Docker-Content-Digest", digest.FromBytes(manifestData).String())
+			_, _ = w.Write(manifestData)
+		case strings.HasPrefix(r.URL.Path, "/v2/test/model/blobs/"):
+			blob, ok := blobs[digest.Digest(strings.TrimPrefix(r.URL.Path, "/v2/test/model/blobs/"))]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(blob)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	reference := host + "/test/model:latest"
+
+	ctx := context.Background()
+	p := &puller{pullCfg: &config.PullConfig{}, hook: status.NewHook(ctx)}
+	require.Error(t, p.Pull(ctx, reference, filepath.Join(t.TempDir(), "model"), false, nil))
+
+	secretsCtx := withRegistrySecrets(ctx, map[string]string{
+		corev1.DockerConfigJsonKey: string(dockerConfigJSON(host, "user", "pass")),
+	})
+	targetDir := filepath.Join(t.TempDir(), "model")
+	require.NoError(t, p.Pull(secretsCtx, reference, targetDir, false, nil))
+	data, err := os.ReadFile(filepath.Join(targetDir, "config.json"))
+	require.NoError(t, err)
+	require.Equal(t, string(configFile), string(data))
+	data, err = os.ReadFile(filepath.Join(targetDir, "README.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# model", string(data))
+
+	// The filters are applied to the layers of the model spec.
+	targetDir = filepath.Join(t.TempDir(), "model")
+	require.NoError(t, p.Pull(secretsCtx, reference, targetDir, false, []string{"*.md"}))
+	require.FileExists(t, filepath.Join(targetDir, "config.json"))
+	require.NoFileExists(t, filepath.Join(targetDir, "README.md"))
+
+	_, err = ResolveDigest(secretsCtx, &config.PullConfig{}, reference, defaultPlatform())
+	require.NoError(t, err)
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+)
+
+// The max number of the replaced models kept in the history of the mount.
+const maxMountHistory = 10
+
+type modelReplacedKey struct{}
+
+// withModelReplaced marks the context to replace the model of the dynamic
+// mount on CreateVolume, instead of rejecting the mount_id re-used for another
+// reference.
+func withModelReplaced(ctx context.Context) context.Context {
+	return context.WithValue(ctx, modelReplacedKey{}, true)
+}
+
+func isModelReplaced(ctx context.Context) bool {
+	replaced, _ := ctx.Value(modelReplacedKey{}).(bool)
+	return replaced
+}
+
+// replaceModel records the model of the dynamic mount pulled before into the
+// history of the mount, the model is then pulled from scratch by the caller
+// holding the context key.
+func (worker *Worker) replaceModel(ctx context.Context, statusPath string) error {
+	oldStatus, err := worker.sm.Get(statusPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return errors.Wrap(err, "get model status")
+	}
+	if oldStatus.Reference == "" {
+		return nil
+	}
+
+	oldStatus.History = append(oldStatus.History, modelStatus.HistoryItem{
+		Reference:  oldStatus.Reference,
+		Digest:     oldStatus.Digest,
+		State:      oldStatus.State,
+		ReplacedAt: time.Now(),
+	})
+	if len(oldStatus.History) > maxMountHistory {
+		oldStatus.History = oldStatus.History[len(oldStatus.History)-maxMountHistory:]
+	}
+	if _, err := worker.sm.Set(statusPath, *oldStatus); err != nil {
+		return errors.Wrap(err, "set model status")
+	}
+	logger.WithContext(ctx).Infof("replacing model: %s", oldStatus.Reference)
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+)
+
+// replacingPuller writes the reference pulled into the model dir, the pull of
+// the blocked reference blocks until it's canceled.
+type replacingPuller struct {
+	blocked string
+}
+
+func (p *replacingPuller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	if reference == p.blocked {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(targetDir, "reference"), []byte(reference), 0644)
+}
+
+func TestPullModel_Replace(t *testing.T) {
+	svc, _ := newNodeService(t)
+	svc.worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return &replacingPuller{blocked: "test/model:blocked"}
+	}
+	ctx := context.Background()
+	modelDir := svc.cfg.Get().GetModelDirForDynamic("csi-dyn", "mount-1")
+	requireModel := func(reference string) *status.Status {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(modelDir, "reference"))
+		require.NoError(t, err)
+		require.Equal(t, reference, string(data))
+		mountStatus, err := svc.getDynamicVolume(ctx, "csi-dyn", "mount-1")
+		require.NoError(t, err)
+		require.Equal(t, reference, mountStatus.Reference)
+		require.Equal(t, status.StatePullSucceeded, mountStatus.State)
+		return mountStatus
+	}
+
+	require.NoError(t, svc.worker.PullModel(ctx, false, "csi-dyn", "mount-1", "test/model:v1", modelDir, PullOptions{}))
+	require.Empty(t, requireModel("test/model:v1").History)
+
+	// The mount_id re-used for another reference is rejected unless replaced.
+	require.ErrorIs(t, svc.worker.PullModel(ctx, false, "csi-dyn", "mount-1", "test/model:v2", modelDir, PullOptions{}), ErrConflict)
+	require.NoError(t, svc.worker.PullModel(ctx, false, "csi-dyn", "mount-1", "test/model:v2", modelDir, PullOptions{Replace: true}))
+	history := requireModel("test/model:v2").History
+	require.Len(t, history, 1)
+	require.Equal(t, "test/model:v1", history[0].Reference)
+	require.Equal(t, status.StatePullSucceeded, history[0].State)
+
+	// The pull in progress is canceled and kept for the replacement.
+	pulled := make(chan error)
+	go func() {
+		pulled <- svc.worker.PullModel(ctx, false, "csi-dyn", "mount-1", "test/model:blocked", modelDir, PullOptions{Replace: true})
+	}()
+	require.Eventually(t, func() bool {
+		mountStatus, err := svc.getDynamicVolume(ctx, "csi-dyn", "mount-1")
+		return err == nil && mountStatus.Reference == "test/model:blocked" && mountStatus.State == status.StatePullRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, svc.worker.PullModel(ctx, false, "csi-dyn", "mount-1", "test/model:v3", modelDir, PullOptions{Replace: true}))
+	require.ErrorIs(t, <-pulled, context.Canceled)
+	history = requireModel("test/model:v3").History
+	require.Len(t, history, 3)
+	require.Equal(t, "test/model:blocked", history[2].Reference)
+	require.Equal(t, status.StatePullCanceled, history[2].State)
+}
+
+func TestDynamicServerHandler_UpdateVolume(t *testing.T) {
+	h, svc := newHandler(t)
+	body := `{"reference":"test/model:v2","force":true}`
+
+	c, rec := newHandlerContextWithParam(t, http.MethodPut, "/?async=true", body,
+		[]string{"volume_name", "mount_id"}, []string{"my-volume", "m1"})
+	_ = h.UpdateVolume(c)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	newDynamicMount(t, svc, "my-volume", "m1")
+	c, rec = newHandlerContextWithParam(t, http.MethodPut, "/?async=true", `{"mount_id":"m2","reference":"test/model:v2","force":true}`,
+		[]string{"volume_name", "mount_id"}, []string{"my-volume", "m1"})
+	_ = h.UpdateVolume(c)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	c, rec = newHandlerContextWithParam(t, http.MethodPut, "/?async=true", body,
+		[]string{"volume_name", "mount_id"}, []string{"my-volume", "m1"})
+	_ = h.UpdateVolume(c)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	var mount status.Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &mount))
+	require.Equal(t, "m1", mount.MountID)
+	require.Equal(t, "test/model:v2", mount.Reference)
+	require.Equal(t, status.StatePullRunning, mount.State)
+}
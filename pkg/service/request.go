@@ -1,9 +1,72 @@
 package service
 
+import "github.com/modelpack/model-csi-driver/pkg/status"
+
 type MountRequest struct {
+	// The model type, "image" by default.
+	Type                 string   `json:"type"`
 	MountID              string   `json:"mount_id"`
 	Reference            string   `json:"reference"`
 	CheckDiskQuota       bool     `json:"check_disk_quota"`
 	ExcludeModelWeights  bool     `json:"exclude_model_weights"`
+	// The short alias of exclude_model_weights.
+	ExcludeWeights       bool     `json:"exclude_weights"`
 	ExcludeFilePatterns  []string `json:"exclude_file_patterns"`
+	// The patterns of the only files to pull, e.g. "tokenizer*".
+	IncludeFilePatterns  []string `json:"include_file_patterns"`
+	// The priority in the node-wide pull queue, the higher is admitted first.
+	Priority             int      `json:"priority"`
+	// Pull the weights in the background, the mount is ready once the other
+	// files are pulled.
+	BackgroundWeights    bool     `json:"background_weights"`
+	// The adapters (e.g. LoRA) mounted beside the base model.
+	Adapters             []status.Adapter `json:"adapters"`
+	// The platform selected from the image index, e.g. "linux/arm64", the
+	// node platform by default.
+	Platform             string   `json:"platform"`
+	// The timeout of the whole pull, the default of the node if 0.
+	PullTimeoutInSeconds uint     `json:"pull_timeout_in_seconds"`
+	// Delete the mount once it's not refreshed for the seconds, 0 to keep it
+	// until deleted.
+	TTLSeconds           uint     `json:"ttl_seconds"`
+	// The labels of the mount, e.g. the tenant of the mount, the labels set
+	// before are kept if it's not set.
+	Labels               map[string]string `json:"labels"`
+	// The callback posted with the mount once its pull is done or failed,
+	// an http(s) URL or "unix:<path>" of the socket in the volume.
+	CallbackURL          string   `json:"callback_url"`
+}
+
+// The max number of the mounts created by a batch.
+const maxBatchMounts = 100
+
+// BatchMountRequest creates the mounts of the dynamic volume by a batch, e.g.
+// the adapters loaded at once by the serving stack.
+type BatchMountRequest struct {
+	Mounts []MountRequest `json:"mounts"`
+}
+
+// BatchMountResult is the result of a mount of the batch, the same as the
+// mount is created alone.
+type BatchMountResult struct {
+	MountID string `json:"mount_id"`
+	// The HTTP status code of the mount, e.g. 201 once it's created.
+	StatusCode int `json:"status_code"`
+	// The mount created, nil if it's failed.
+	Mount *status.Status `json:"mount,omitempty"`
+	// The error of the failed mount.
+	Error *ErrorResponse `json:"error,omitempty"`
+}
+
+// BatchMountResponse has the results in the order of the mounts of the batch.
+type BatchMountResponse struct {
+	Results []BatchMountResult `json:"results"`
+}
+
+// UpdateMountRequest pulls the model of the existing mount again.
+type UpdateMountRequest struct {
+	MountRequest
+	// Replace the model of the mount even by another reference, instead of
+	// rejecting the mount_id re-used for another reference.
+	Force bool `json:"force"`
 }
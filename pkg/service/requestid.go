@@ -0,0 +1,78 @@
+package service
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// The X-Request-ID set by the client is kept if it's printable and short
+// enough to be logged, otherwise a new one is generated.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestID returns the ID of the request set by the requestLogger.
+func requestID(c echo.Context) string {
+	return c.Response().Header().Get(echo.HeaderXRequestID)
+}
+
+// requestLogger sets the X-Request-ID of the request to the context logged
+// by the logger and to the response, and logs the access of the request once
+// it's served.
+func requestLogger(volumeName string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			req := c.Request()
+			id := req.Header.Get(echo.HeaderXRequestID)
+			if !validRequestID.MatchString(id) {
+				id = uuid.New().String()
+			}
+			c.Response().Header().Set(echo.HeaderXRequestID, id)
+			c.SetRequest(req.WithContext(logger.WithRequestID(req.Context(), id)))
+
+			// The error is handled here, so that its status is logged.
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+
+			logger.Logger().WithFields(logrus.Fields{
+				"request":    id,
+				"volumeName": volumeName,
+				"method":     req.Method,
+				"path":       req.URL.Path,
+				"query":      req.URL.RawQuery,
+				"status":     c.Response().Status,
+				"size":       c.Response().Size,
+				"latency":    time.Since(start).String(),
+			}).Info("served http request")
+			return nil
+		}
+	}
+}
+
+// requestIDSerializer sets the ID of the request to the error responses, so
+// that the errors seen by the clients are found in the logs of the driver.
+type requestIDSerializer struct {
+	echo.DefaultJSONSerializer
+}
+
+func (s requestIDSerializer) Serialize(c echo.Context, i interface{}, indent string) error {
+	switch resp := i.(type) {
+	case ErrorResponse:
+		if resp.RequestID == "" {
+			resp.RequestID = requestID(c)
+		}
+		i = resp
+	case *ErrorResponse:
+		if resp != nil && resp.RequestID == "" {
+			copied := *resp
+			copied.RequestID = requestID(c)
+			i = &copied
+		}
+	}
+	return s.DefaultJSONSerializer.Serialize(c, i, indent)
+}
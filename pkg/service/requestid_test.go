@@ -0,0 +1,64 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+func TestRequestLogger(t *testing.T) {
+	e := echo.New()
+	e.JSONSerializer = requestIDSerializer{}
+	e.Use(requestLogger("csi-vol"))
+	var contextID string
+	e.GET("/api/v1/info", func(c echo.Context) error {
+		contextID = logger.RequestID(c.Request().Context())
+		return handleError(c, grpcStatus.Error(codes.NotFound, "not found"))
+	})
+	serve := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/info", nil)
+		if id != "" {
+			req.Header.Set(echo.HeaderXRequestID, id)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The ID of the client is kept.
+	rec := serve("req-1")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Equal(t, "req-1", rec.Header().Get(echo.HeaderXRequestID))
+	require.Equal(t, "req-1", contextID)
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, ERR_CODE_NOT_FOUND, resp.Code)
+	require.Equal(t, "req-1", resp.RequestID)
+
+	// The ID is generated for the request without a valid one.
+	for _, id := range []string{"", "req 1\n"} {
+		rec = serve(id)
+		generated := rec.Header().Get(echo.HeaderXRequestID)
+		require.NotEmpty(t, generated)
+		require.NotEqual(t, id, generated)
+		require.Equal(t, generated, contextID)
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		require.Equal(t, generated, resp.RequestID)
+	}
+
+	// The error returned by the handler is served and logged.
+	e.GET("/api/v1/error", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusTeapot)
+	})
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/error", nil))
+	require.Equal(t, http.StatusTeapot, rec.Code)
+	require.NotEmpty(t, rec.Header().Get(echo.HeaderXRequestID))
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"sync"
+)
+
+// QuotaReservations is the ledger of the disk quota reserved by the in-flight
+// pulls, so that the concurrent pulls can't pass the check of the disk quota
+// with the same free space and fill the disk later.
+type QuotaReservations struct {
+	mutex sync.Mutex
+	// The expected size of the pulls keyed by the volume dir.
+	sizes map[string]int64
+}
+
+func NewQuotaReservations() *QuotaReservations {
+	return &QuotaReservations{
+		sizes: map[string]int64{},
+	}
+}
+
+// pendingSize returns the size reserved by the pulls but not written to the
+// volume dirs yet, excluding the volume dir checked again. The caller must
+// hold the mutex.
+func (r *QuotaReservations) pendingSize(excludeDir string) int64 {
+	var total int64
+	for volumeDir, size := range r.sizes {
+		if volumeDir == excludeDir {
+			continue
+		}
+		// The size written by the pull is counted by the disk usage, the
+		// dir being written is counted as empty on the error.
+		usedSize, err := getUsedSize(volumeDir)
+		if err != nil {
+			usedSize = 0
+		}
+		if size > usedSize {
+			total += size - usedSize
+		}
+	}
+	return total
+}
+
+// Release releases the size reserved for the volume dir once the pull is
+// finished, either succeeded or failed.
+func (r *QuotaReservations) Release(volumeDir string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.sizes, volumeDir)
+}
+
+// Pending returns the size reserved by the in-flight pulls but not written
+// yet.
+func (r *QuotaReservations) Pending() int64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.pendingSize("")
+}
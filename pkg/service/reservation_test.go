@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaReservations(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	cfg := svc.cfg.Get()
+	cfg.Features.DiskUsageLimit = 100 << 20
+
+	newChecker := func(mountID string) (*DiskQuotaChecker, string) {
+		volumeDir := cfg.GetMountIDDirForDynamic("csi-vol", mountID)
+		require.NoError(t, os.MkdirAll(volumeDir, 0755))
+		return svc.worker.newDiskQuotaChecker(volumeDir, false), volumeDir
+	}
+	checker1, volumeDir1 := newChecker("m1")
+	checker2, _ := newChecker("m2")
+	checker3, _ := newChecker("m3")
+
+	// The concurrent pulls can't pass the check with the same free space.
+	require.NoError(t, checker1.CheckSize(ctx, "test/model-1:latest", 60<<20))
+	err := checker2.CheckSize(ctx, "test/model-2:latest", 50<<20)
+	require.True(t, errors.Is(err, syscall.ENOSPC))
+	// The pull checked again doesn't count its own reservation.
+	require.NoError(t, checker1.CheckSize(ctx, "test/model-1:latest", 60<<20))
+
+	// The size written by the pull isn't counted twice.
+	require.NoError(t, os.WriteFile(filepath.Join(volumeDir1, "weights"), make([]byte, 20<<20), 0644))
+	require.NoError(t, checker2.CheckSize(ctx, "test/model-2:latest", 35<<20))
+	err = checker3.CheckSize(ctx, "test/model-3:latest", 20<<20)
+	require.True(t, errors.Is(err, syscall.ENOSPC))
+
+	// The reservation is released once the pull is finished.
+	checker1.Release()
+	require.NoError(t, checker3.CheckSize(ctx, "test/model-3:latest", 20<<20))
+}
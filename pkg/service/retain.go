@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+)
+
+// retainModel moves the model of the volume being deleted into the prefetch
+// of the model if the volume is created with the retain-cache parameter, so
+// that the model is kept on the node as the cache, which is evicted or
+// collected the same as the prefetched models. The model is removed with the
+// volume if it's not pulled completely, or it's cached already.
+func (worker *Worker) retainModel(ctx context.Context, volumeDir string) bool {
+	volumeStatus, err := worker.sm.Get(filepath.Join(volumeDir, "status.json"))
+	if err != nil || !volumeStatus.RetainCache || volumeStatus.PullKey == "" || len(volumeStatus.Adapters) > 0 {
+		return false
+	}
+	switch volumeStatus.State {
+	case status.StatePullSucceeded, status.StateMounted, status.StateUmounted:
+	default:
+		return false
+	}
+
+	name := prefetchName(volumeStatus.PullKey)
+	contextKey := name + "/"
+	if err := worker.kmutex.Lock(context.Background(), contextKey); err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("lock context key: %s", contextKey)
+		return false
+	}
+	defer worker.kmutex.Unlock(contextKey)
+
+	cacheDir := worker.cfg.Get().GetVolumeDir(name)
+	if _, err := os.Stat(cacheDir); err == nil {
+		return false
+	}
+	cacheModelDir := filepath.Join(cacheDir, "model")
+	// The model is moved within the storage tier it's placed on.
+	if tier := volumeStorageTier(worker.cfg.Get(), volumeDir); tier != nil {
+		if err := worker.placeVolumeDir(ctx, cacheDir, tier); err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("failed to place cache dir on storage tier %s", tier.Name)
+			return false
+		}
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("failed to create cache dir: %s", cacheDir)
+		return false
+	}
+	if err := os.Rename(filepath.Join(volumeDir, "model"), cacheModelDir); err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("failed to retain model in %s", cacheDir)
+		_ = removeVolumeDir(cacheDir)
+		return false
+	}
+	// The model is evicted by LRU since it's retained.
+	now := time.Now()
+	if err := os.Chtimes(cacheModelDir, now, now); err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("failed to touch model dir: %s", cacheModelDir)
+	}
+	if _, err := worker.sm.Set(filepath.Join(cacheDir, "status.json"), status.Status{
+		VolumeName:          name,
+		Reference:           volumeStatus.Reference,
+		Digest:              volumeStatus.Digest,
+		Platform:            volumeStatus.Platform,
+		State:               status.StatePullSucceeded,
+		PullKey:             volumeStatus.PullKey,
+		ExcludeModelWeights: volumeStatus.ExcludeModelWeights,
+		ExcludeFilePatterns: volumeStatus.ExcludeFilePatterns,
+		ExcludedFiles:       volumeStatus.ExcludedFiles,
+		SizeInBytes:         volumeStatus.SizeInBytes,
+		Retained:            true,
+	}); err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("failed to set status of retained model: %s", cacheDir)
+		_ = removeVolumeDir(cacheDir)
+		return false
+	}
+	logger.WithContext(ctx).Infof("retained model %s in %s", volumeStatus.Reference, cacheDir)
+
+	return true
+}
+
+// takeRetainedModel moves the model retained from a deleted volume into the
+// model dir, so that the volume of the same model is created instantly, it
+// returns false if the model is gone in the meantime.
+func (worker *Worker) takeRetainedModel(ctx context.Context, sourceDir, modelDir string) bool {
+	cacheDir := filepath.Dir(sourceDir)
+	contextKey := filepath.Base(cacheDir) + "/"
+	if err := worker.kmutex.Lock(context.Background(), contextKey); err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("lock context key: %s", contextKey)
+		return false
+	}
+	defer worker.kmutex.Unlock(contextKey)
+
+	if err := os.RemoveAll(modelDir); err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("failed to cleanup model dir: %s", modelDir)
+		return false
+	}
+	if err := os.Rename(sourceDir, modelDir); err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("failed to take retained model from %s", cacheDir)
+		return false
+	}
+	if err := removeVolumeDir(cacheDir); err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("failed to remove cache dir: %s", cacheDir)
+	}
+	worker.sm.Invalidate(cacheDir)
+	logger.WithContext(ctx).Infof("took retained model from %s", cacheDir)
+
+	return true
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetainModel(t *testing.T) {
+	worker := newWorkerWithMockPuller(t, nil)
+	puller := &blockingPuller{started: make(chan struct{}), release: make(chan struct{})}
+	close(puller.release)
+	worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return puller
+	}
+	ctx := context.Background()
+	cfg := worker.cfg.Get()
+	opts := PullOptions{RetainCache: true}
+	cacheName := prefetchName(pullKey("test/model:latest", opts))
+
+	// The model of the volume without retain-cache is removed with it.
+	require.NoError(t, worker.PullModel(ctx, true, "pvc-removed", "", "test/model:latest", cfg.GetModelDir("pvc-removed"), PullOptions{}))
+	require.NoError(t, worker.DeleteModel(ctx, true, "pvc-removed", ""))
+	require.NoDirExists(t, cfg.GetVolumeDir(cacheName))
+
+	// The model is kept as the cache once the volume is deleted.
+	require.NoError(t, worker.PullModel(ctx, true, "pvc-retained", "", "test/model:latest", cfg.GetModelDir("pvc-retained"), opts))
+	require.Equal(t, int32(2), puller.calls.Load())
+	require.NoError(t, worker.DeleteModel(ctx, true, "pvc-retained", ""))
+	require.NoDirExists(t, cfg.GetVolumeDir("pvc-retained"))
+	require.FileExists(t, filepath.Join(cfg.GetModelDir(cacheName), "weights.bin"))
+	cacheStatus, err := worker.sm.Get(filepath.Join(cfg.GetVolumeDir(cacheName), "status.json"))
+	require.NoError(t, err)
+	require.True(t, cacheStatus.Retained)
+	require.Equal(t, status.StatePullSucceeded, cacheStatus.State)
+	require.Equal(t, "test/model:latest", cacheStatus.Reference)
+
+	// The retained model is evictable as a prefetched model.
+	candidates, err := worker.evictionCandidates(ctx)
+	require.NoError(t, err)
+	require.Len(t, candidates, 1)
+	require.Equal(t, cacheName, candidates[0].name)
+
+	// The volume of the same model takes the retained model without a pull.
+	modelDir := cfg.GetModelDir("pvc-recreated")
+	require.NoError(t, worker.PullModel(ctx, true, "pvc-recreated", "", "test/model:latest", modelDir, opts))
+	require.Equal(t, int32(2), puller.calls.Load())
+	data, err := os.ReadFile(filepath.Join(modelDir, "weights.bin"))
+	require.NoError(t, err)
+	require.Equal(t, "weights", string(data))
+	require.NoDirExists(t, cfg.GetVolumeDir(cacheName))
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/metrics"
+	"github.com/pkg/errors"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+const (
+	defaultRetryBackoffBase = time.Second
+	defaultRetryMaxBackoff  = 30 * time.Second
+)
+
+func isTransientStatusCode(statusCode int) bool {
+	return statusCode >= http.StatusInternalServerError || statusCode == http.StatusTooManyRequests
+}
+
+// isTransientLayerError returns true if the layer pull failed by the error
+// which is likely gone on retry, e.g. the connection reset or 5xx response.
+func isTransientLayerError(err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return isTransientStatusCode(statusErr.StatusCode)
+	}
+	var errResp *errcode.ErrorResponse
+	if errors.As(err, &errResp) {
+		return isTransientStatusCode(errResp.StatusCode)
+	}
+	return !errors.Is(err, context.Canceled) && isRetryablePullError(err)
+}
+
+// isUnauthorizedLayerError returns true if the layer pull is rejected by the
+// registry with 401, e.g. the credentials expired during the long pull.
+func isUnauthorizedLayerError(err error) bool {
+	var errResp *errcode.ErrorResponse
+	return errors.As(err, &errResp) && errResp.StatusCode == http.StatusUnauthorized
+}
+
+// retryBackoff returns the delay before the retry, which is doubled on each
+// retry (1-based) up to the max backoff.
+func retryBackoff(cfg *config.RetryConfig, retry int) time.Duration {
+	base := defaultRetryBackoffBase
+	if cfg.BackoffBaseInMilliseconds > 0 {
+		base = time.Duration(cfg.BackoffBaseInMilliseconds) * time.Millisecond
+	}
+	maxBackoff := defaultRetryMaxBackoff
+	if cfg.MaxBackoffInSeconds > 0 {
+		maxBackoff = time.Duration(cfg.MaxBackoffInSeconds) * time.Second
+	}
+
+	delay := base
+	for i := 1; i < retry && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxBackoff)
+}
+
+// withLayerRetry pulls the layer, and retries it with the exponential backoff
+// on the transient error until the max retries is reached. The layer rejected
+// with 401 is retried once, with the credentials read again by the auth client,
+// the invalid credentials fail on the retry again.
+func withLayerRetry(ctx context.Context, cfg *config.RetryConfig, name string, pull func() error) error {
+	unauthorizedRetried := false
+	for retry := 1; ; retry++ {
+		err := pull()
+		if err == nil || retry > int(cfg.MaxRetries) || ctx.Err() != nil {
+			return err
+		}
+		if isUnauthorizedLayerError(err) {
+			if unauthorizedRetried {
+				return err
+			}
+			unauthorizedRetried = true
+		} else if !isTransientLayerError(err) {
+			return err
+		}
+
+		delay := retryBackoff(cfg, retry)
+		logger.WithContext(ctx).WithError(err).Warnf("retry pulling layer %s in %s (%d/%d)", name, delay, retry, cfg.MaxRetries)
+		metrics.NodePullLayerRetried.Inc()
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/modelpack/modctl/pkg/backend"
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/opencontainers/go-digest"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	cfg := &config.RetryConfig{}
+	require.Equal(t, time.Second, retryBackoff(cfg, 1))
+	require.Equal(t, 4*time.Second, retryBackoff(cfg, 3))
+	require.Equal(t, 30*time.Second, retryBackoff(cfg, 100))
+
+	cfg = &config.RetryConfig{BackoffBaseInMilliseconds: 100, MaxBackoffInSeconds: 1}
+	require.Equal(t, 100*time.Millisecond, retryBackoff(cfg, 1))
+	require.Equal(t, 800*time.Millisecond, retryBackoff(cfg, 4))
+	require.Equal(t, time.Second, retryBackoff(cfg, 5))
+}
+
+func TestIsTransientLayerError(t *testing.T) {
+	require.True(t, isTransientLayerError(pkgerrors.Wrap(syscall.ECONNRESET, "read layer")))
+	require.True(t, isTransientLayerError(pkgerrors.Wrap(io.ErrUnexpectedEOF, "read layer")))
+	require.True(t, isTransientLayerError(pkgerrors.WithStack(&httpStatusError{StatusCode: http.StatusServiceUnavailable})))
+	require.True(t, isTransientLayerError(pkgerrors.WithStack(&httpStatusError{StatusCode: http.StatusTooManyRequests})))
+	require.True(t, isTransientLayerError(pkgerrors.Wrap(&errcode.ErrorResponse{StatusCode: http.StatusBadGateway}, "fetch layer")))
+
+	require.False(t, isTransientLayerError(pkgerrors.WithStack(&httpStatusError{StatusCode: http.StatusNotFound})))
+	require.False(t, isTransientLayerError(pkgerrors.Wrap(&errcode.ErrorResponse{StatusCode: http.StatusUnauthorized}, "fetch layer")))
+	require.False(t, isTransientLayerError(pkgerrors.Wrap(context.Canceled, "pull layer")))
+	require.False(t, isTransientLayerError(pkgerrors.New("checksum mismatch")))
+}
+
+func TestWithLayerRetry(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.RetryConfig{MaxRetries: 2, BackoffBaseInMilliseconds: 1}
+
+	// Succeeds on the last retry.
+	calls := 0
+	require.NoError(t, withLayerRetry(ctx, cfg, "layer", func() error {
+		calls++
+		if calls <= 2 {
+			return syscall.ECONNRESET
+		}
+		return nil
+	}))
+	require.Equal(t, 3, calls)
+
+	// Fails after the max retries.
+	calls = 0
+	require.ErrorIs(t, withLayerRetry(ctx, cfg, "layer", func() error {
+		calls++
+		return syscall.ECONNRESET
+	}), syscall.ECONNRESET)
+	require.Equal(t, 3, calls)
+
+	// The permanent error isn't retried.
+	calls = 0
+	require.Error(t, withLayerRetry(ctx, cfg, "layer", func() error {
+		calls++
+		return pkgerrors.New("checksum mismatch")
+	}))
+	require.Equal(t, 1, calls)
+
+	// The layer rejected with 401 is retried once with the refreshed credentials.
+	calls = 0
+	unauthorized := pkgerrors.Wrap(&errcode.ErrorResponse{StatusCode: http.StatusUnauthorized}, "fetch layer")
+	require.NoError(t, withLayerRetry(ctx, cfg, "layer", func() error {
+		calls++
+		if calls == 1 {
+			return unauthorized
+		}
+		return nil
+	}))
+	require.Equal(t, 2, calls)
+	calls = 0
+	require.ErrorIs(t, withLayerRetry(ctx, cfg, "layer", func() error {
+		calls++
+		return unauthorized
+	}), unauthorized)
+	require.Equal(t, 2, calls)
+
+	// The retry is disabled by default.
+	calls = 0
+	require.Error(t, withLayerRetry(ctx, &config.RetryConfig{}, "layer", func() error {
+		calls++
+		return syscall.ECONNRESET
+	}))
+	require.Equal(t, 1, calls)
+}
+
+func TestFilePuller_RetryLayer(t *testing.T) {
+	content := `{"model_type":"qwen3"}`
+	sum := sha256.Sum256([]byte(content))
+	layer := backend.InspectedModelArtifactLayer{
+		Filepath: "config.json",
+		Size:     int64(len(content)),
+		Digest:   digest.NewDigestFromEncoded(digest.SHA256, hex.EncodeToString(sum[:])).String(),
+	}
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	fp := &filePuller{
+		modelType: ModelTypeHuggingFace,
+		pullCfg:   &config.PullConfig{Retry: config.RetryConfig{MaxRetries: 1, BackoffBaseInMilliseconds: 1}},
+		hook:      status.NewHook(ctx),
+		client:    server.Client(),
+		newRequest: func(ctx context.Context, layer backend.InspectedModelArtifactLayer) (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/"+layer.Filepath, nil)
+		},
+	}
+
+	targetDir := filepath.Join(t.TempDir(), "model")
+	require.NoError(t, fp.pull(ctx, "org/model", targetDir, []backend.InspectedModelArtifactLayer{layer}, false, nil))
+	require.Equal(t, int32(2), requests.Load())
+	data, err := os.ReadFile(filepath.Join(targetDir, "config.json"))
+	require.NoError(t, err)
+	require.Equal(t, content, string(data))
+}
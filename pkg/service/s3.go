@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/modelpack/modctl/pkg/backend"
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// The keys of the S3 credentials in the CSI secrets of the volume.
+const (
+	secretKeyS3AccessKeyID     = "accessKeyID"
+	secretKeyS3SecretAccessKey = "secretAccessKey"
+	secretKeyS3SessionToken    = "sessionToken"
+)
+
+var NewS3Puller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker, secrets map[string]string) Puller {
+	return &s3Puller{
+		pullCfg:          pullCfg,
+		hook:             hook,
+		diskQuotaChecker: diskQuotaChecker,
+		secrets:          secrets,
+	}
+}
+
+// s3Puller pulls the model files under a bucket prefix of the S3 compatible
+// object storage, the reference is "s3://<bucket>/<prefix>".
+type s3Puller struct {
+	pullCfg          *config.PullConfig
+	hook             *status.Hook
+	diskQuotaChecker *DiskQuotaChecker
+	secrets          map[string]string
+}
+
+type s3Credentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+type s3Object struct {
+	Key  string `xml:"Key"`
+	Size int64  `xml:"Size"`
+	ETag string `xml:"ETag"`
+}
+
+type s3ListBucketResult struct {
+	Contents              []s3Object `xml:"Contents"`
+	IsTruncated           bool       `xml:"IsTruncated"`
+	NextContinuationToken string     `xml:"NextContinuationToken"`
+}
+
+// parseS3Reference parses "s3://<bucket>/<prefix>" into the bucket and the
+// prefix, the prefix is treated as a dir.
+func parseS3Reference(reference string) (string, string, error) {
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(reference, "s3://"), "/")
+	if bucket == "" {
+		return "", "", errors.Errorf("invalid s3 reference: %s", reference)
+	}
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return bucket, prefix, nil
+}
+
+func (p *s3Puller) getCredentials() (*s3Credentials, error) {
+	if p.secrets[secretKeyS3AccessKeyID] != "" {
+		return &s3Credentials{
+			accessKeyID:     p.secrets[secretKeyS3AccessKeyID],
+			secretAccessKey: p.secrets[secretKeyS3SecretAccessKey],
+			sessionToken:    p.secrets[secretKeyS3SessionToken],
+		}, nil
+	}
+
+	accessKeyID, secretAccessKey, sessionToken, err := p.pullCfg.S3.GetCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Credentials{
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		sessionToken:    sessionToken,
+	}, nil
+}
+
+// s3Escape escapes the string as the URI encoding of AWS signature version 4.
+func s3Escape(str string, escapeSlash bool) string {
+	var buf strings.Builder
+	for _, b := range []byte(str) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') ||
+			b == '-' || b == '_' || b == '.' || b == '~' || (b == '/' && !escapeSlash) {
+			buf.WriteByte(b)
+			continue
+		}
+		fmt.Fprintf(&buf, "%%%02X", b)
+	}
+	return buf.String()
+}
+
+func (p *s3Puller) newRequest(ctx context.Context, creds *s3Credentials, bucket, key string, query url.Values) (*http.Request, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(p.pullCfg.S3.Endpoint, "/"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse s3 endpoint: %s", p.pullCfg.S3.Endpoint)
+	}
+
+	host := endpoint.Host
+	path := "/" + key
+	if p.pullCfg.S3.ForcePathStyle {
+		path = "/" + bucket + path
+	} else {
+		host = bucket + "." + host
+	}
+
+	rawQuery := []string{}
+	for name, values := range query {
+		for _, value := range values {
+			rawQuery = append(rawQuery, s3Escape(name, true)+"="+s3Escape(value, true))
+		}
+	}
+	sort.Strings(rawQuery)
+
+	rawURL := fmt.Sprintf("%s://%s%s", endpoint.Scheme, host, s3Escape(path, false))
+	if len(rawQuery) > 0 {
+		rawURL += "?" + strings.Join(rawQuery, "&")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "create request: %s", rawURL)
+	}
+
+	if creds.accessKeyID != "" {
+		signS3Request(req, creds, p.pullCfg.S3.Region, time.Now())
+	}
+
+	return req, nil
+}
+
+// signS3Request signs the request with AWS signature version 4, the payload
+// is left unsigned as only GET requests are sent.
+func signS3Request(req *http.Request, creds *s3Credentials, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := "UNSIGNED-PAYLOAD"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := strings.Builder{}
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, region)
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	hmacSHA256 := func(key []byte, data string) []byte {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(data))
+		return mac.Sum(nil)
+	}
+	signingKey := hmacSHA256([]byte("AWS4"+creds.secretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+// listObjects lists the objects under the prefix with ListObjectsV2.
+func (p *s3Puller) listObjects(ctx context.Context, client *http.Client, creds *s3Credentials, bucket, prefix string) ([]s3Object, error) {
+	objects := []s3Object{}
+	continuationToken := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+
+		req, err := p.newRequest(ctx, creds, bucket, "", query)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := doRequest(client, req)
+		if err != nil {
+			return nil, err
+		}
+
+		var result s3ListBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "decode list objects result of bucket: %s", bucket)
+		}
+
+		objects = append(objects, result.Contents...)
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		continuationToken = result.NextContinuationToken
+	}
+}
+
+func (p *s3Puller) Pull(ctx context.Context, reference, targetDir string, excludeModelWeights bool, excludeFilePatterns []string) error {
+	bucket, prefix, err := parseS3Reference(reference)
+	if err != nil {
+		return err
+	}
+
+	creds, err := p.getCredentials()
+	if err != nil {
+		return errors.Wrap(err, "get s3 credentials")
+	}
+
+	client, err := newHTTPClient(p.pullCfg)
+	if err != nil {
+		return err
+	}
+	if endpoint, err := url.Parse(p.pullCfg.S3.Endpoint); err == nil {
+		logPullProxy(ctx, p.pullCfg, reference, endpoint.Host)
+	}
+
+	objects, err := p.listObjects(ctx, client, creds, bucket, prefix)
+	if err != nil {
+		return errors.Wrapf(err, "list s3 objects: %s", reference)
+	}
+
+	files := []backend.InspectedModelArtifactLayer{}
+	for _, object := range objects {
+		// Skip the dir markers.
+		if strings.HasSuffix(object.Key, "/") {
+			continue
+		}
+		files = append(files, backend.InspectedModelArtifactLayer{
+			Filepath: strings.TrimPrefix(object.Key, prefix),
+			Size:     object.Size,
+			// The ETag identifies the object content for the resumed pull,
+			// it's not the checksum of the multipart uploaded objects.
+			Digest: digest.NewDigestFromEncoded("etag", strings.Trim(object.ETag, `"`)).String(),
+		})
+	}
+	if len(files) == 0 {
+		return errors.Errorf("no model files found in s3: %s", reference)
+	}
+
+	fp := &filePuller{
+		modelType:        ModelTypeS3,
+		pullCfg:          p.pullCfg,
+		hook:             p.hook,
+		diskQuotaChecker: p.diskQuotaChecker,
+		client:           client,
+		newRequest: func(ctx context.Context, layer backend.InspectedModelArtifactLayer) (*http.Request, error) {
+			return p.newRequest(ctx, creds, bucket, prefix+layer.Filepath, nil)
+		},
+	}
+
+	return fp.pull(ctx, reference, targetDir, files, excludeModelWeights, excludeFilePatterns)
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/metrics"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+)
+
+// The scrub record written beside the status of the volume, it's removed
+// with the volume dir.
+const scrubFile = "scrub.json"
+
+var ScrubInterval = 24 * time.Hour
+
+// scrubThrottle limits the read throughput of a scrub, the files of all the
+// models scrubbed by the scrub share the limit.
+type scrubThrottle struct {
+	bytesPerSecond int64
+	start          time.Time
+	read           int64
+}
+
+// throttledReader sleeps once the bytes read exceed the limit since the scrub
+// is started.
+type throttledReader struct {
+	reader   io.Reader
+	throttle *scrubThrottle
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	throttle := r.throttle
+	if throttle.bytesPerSecond > 0 {
+		throttle.read += int64(n)
+		expected := time.Duration(float64(throttle.read) / float64(throttle.bytesPerSecond) * float64(time.Second))
+		if wait := expected - time.Since(throttle.start); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+	return n, err
+}
+
+func (s *Service) scrubModelsLoop() {
+	for {
+		interval := time.Duration(s.cfg.Get().Features.Scrub.IntervalInSeconds) * time.Second
+		if interval == 0 {
+			interval = ScrubInterval
+		}
+		time.Sleep(interval)
+		if !s.cfg.Get().Features.Scrub.Enabled {
+			continue
+		}
+		s.scrubModels(context.Background())
+	}
+}
+
+// scrubModels verifies the model files of the pulled models against their
+// checksum files one by one, the corrupted files are recorded for the
+// status of the volume and the metrics, and the prefetched models holding
+// them are re-pulled if repair is enabled.
+func (s *Service) scrubModels(ctx context.Context) {
+	ctx = logger.NewContext(ctx, "ScrubModels", "", "")
+	cfg := s.cfg.Get().Features.Scrub
+	throttle := &scrubThrottle{bytesPerSecond: int64(cfg.BytesPerSecond), start: time.Now()}
+	start := time.Now()
+
+	volumeDirs := []string{}
+	s.worker.walkVolumeDirs(ctx, func(volumeDir string) bool {
+		volumeDirs = append(volumeDirs, volumeDir)
+		return false
+	})
+	corrupted := 0
+	for _, volumeDir := range volumeDirs {
+		result, volumeStatus, err := s.scrubModel(ctx, volumeDir, int(cfg.SampleFiles), throttle)
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("failed to scrub model: %s", volumeDir)
+			continue
+		}
+		if result == nil || len(result.CorruptedFiles) == 0 {
+			continue
+		}
+		corrupted++
+		logger.WithContext(ctx).Errorf(
+			"model %s in %s has corrupted files: %s", volumeStatus.Reference, volumeDir, strings.Join(result.CorruptedFiles, ", "),
+		)
+		if cfg.Repair {
+			if s.repairModel(ctx, volumeDir, volumeStatus) {
+				corrupted--
+			}
+		}
+	}
+	metrics.NodeCorruptedModels.Set(float64(corrupted))
+	logger.WithContext(ctx).Infof("scrubbed %d models, corrupted: %d, duration: %s", len(volumeDirs), corrupted, time.Since(start))
+}
+
+// scrubModel verifies the model files of the volume dir against the checksum
+// file, a sample of them if the sample is set, it returns nil if the model
+// isn't pulled or has no checksum file.
+func (s *Service) scrubModel(ctx context.Context, volumeDir string, sample int, throttle *scrubThrottle) (*modelStatus.ScrubResult, *modelStatus.Status, error) {
+	statusPath := filepath.Join(volumeDir, "status.json")
+	volumeStatus, err := s.sm.Get(statusPath)
+	if err != nil || !isScrubbable(volumeStatus) {
+		return nil, nil, nil
+	}
+	modelDir := filepath.Join(volumeDir, "model")
+	checksums, err := readChecksumFile(filepath.Join(modelDir, checksumFile))
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	paths := make([]string, 0, len(checksums))
+	for path := range checksums {
+		paths = append(paths, path)
+	}
+	rand.Shuffle(len(paths), func(i, j int) { paths[i], paths[j] = paths[j], paths[i] })
+	if sample > 0 && len(paths) > sample {
+		paths = paths[:sample]
+	}
+
+	result := modelStatus.ScrubResult{CheckedFiles: len(paths), CorruptedFiles: []string{}}
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		checksum, err := scrubFileChecksum(filepath.Join(modelDir, filepath.FromSlash(path)), throttle)
+		metrics.NodeScrubbedFiles.Inc()
+		if err != nil || checksum != checksums[path] {
+			result.CorruptedFiles = append(result.CorruptedFiles, path)
+		}
+	}
+	result.ScrubbedAt = time.Now()
+
+	// The model may be deleted or pulled again while it's scrubbed, the
+	// result is only recorded for the same model.
+	contextKey := volumeContextKey(s.cfg.Get(), volumeDir)
+	if err := s.worker.kmutex.Lock(ctx, contextKey); err != nil {
+		return nil, nil, errors.Wrapf(err, "lock context key: %s", contextKey)
+	}
+	defer s.worker.kmutex.Unlock(contextKey)
+	currentStatus, err := s.sm.Get(statusPath)
+	if err != nil || !isScrubbable(currentStatus) || currentStatus.PullKey != volumeStatus.PullKey {
+		return nil, nil, nil
+	}
+	metrics.NodeScrubCorruptedFiles.Add(float64(len(result.CorruptedFiles)))
+	if err := writeScrubResult(volumeDir, &result); err != nil {
+		return nil, nil, err
+	}
+
+	return &result, currentStatus, nil
+}
+
+// repairModel re-pulls the prefetched model holding the corrupted files, the
+// models of the volumes are used by the pods, and the files of the shared
+// blob store are shared with the other volumes, so they're only reported.
+func (s *Service) repairModel(ctx context.Context, volumeDir string, volumeStatus *modelStatus.Status) bool {
+	name := filepath.Base(volumeDir)
+	if !isPrefetchVolume(name) || s.cfg.Get().Features.SharedBlobStore {
+		return false
+	}
+	opts := statusPullOptions(volumeStatus)
+	opts.Priority = defaultPrefetchPriority
+	opts.NoReuse = true
+	logger.WithContext(ctx).Infof("re-pulling corrupted model %s: %s", volumeStatus.Reference, name)
+	if err := s.worker.PullModel(ctx, true, name, "", volumeStatus.Reference, filepath.Join(volumeDir, "model"), opts); err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("failed to re-pull corrupted model: %s", name)
+		return false
+	}
+	if err := os.Remove(filepath.Join(volumeDir, scrubFile)); err != nil && !os.IsNotExist(err) {
+		logger.WithContext(ctx).WithError(err).Warnf("failed to remove scrub record: %s", name)
+	}
+	logger.WithContext(ctx).Infof("re-pulled corrupted model %s: %s", volumeStatus.Reference, name)
+
+	return true
+}
+
+func isScrubbable(volumeStatus *modelStatus.Status) bool {
+	switch volumeStatus.State {
+	case modelStatus.StatePullSucceeded, modelStatus.StateMounted, modelStatus.StateUmounted:
+		return true
+	}
+	return false
+}
+
+// volumeContextKey returns the key the pull and the deletion of the volume
+// dir are locked by, i.e. $volumeName/$mountID.
+func volumeContextKey(cfg *config.RawConfig, volumeDir string) string {
+	rel, err := filepath.Rel(cfg.GetVolumesDir(), volumeDir)
+	if err != nil {
+		return volumeDir
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) == 3 && parts[1] == "models" {
+		return parts[0] + "/" + parts[2]
+	}
+	return parts[0] + "/"
+}
+
+// readChecksumFile reads the checksums of the files in the format of
+// sha256sum, keyed by the slash separated path.
+func readChecksumFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "open checksum file")
+	}
+	defer func() { _ = file.Close() }()
+
+	checksums := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		checksum, relPath, ok := strings.Cut(scanner.Text(), "  ")
+		if !ok || !filepath.IsLocal(filepath.FromSlash(relPath)) {
+			return nil, errors.Errorf("invalid line of checksum file: %s", scanner.Text())
+		}
+		checksums[relPath] = checksum
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "read checksum file")
+	}
+
+	return checksums, nil
+}
+
+func scrubFileChecksum(path string, throttle *scrubThrottle) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrap(err, "open file")
+	}
+	defer func() { _ = file.Close() }()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, &throttledReader{reader: file, throttle: throttle}); err != nil {
+		return "", errors.Wrap(err, "read file")
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func writeScrubResult(volumeDir string, result *modelStatus.ScrubResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return errors.Wrap(err, "marshal scrub result")
+	}
+	tmpPath := filepath.Join(volumeDir, "."+scrubFile+".tmp")
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return errors.Wrapf(err, "write scrub result: %s", tmpPath)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(volumeDir, scrubFile)); err != nil {
+		_ = os.Remove(tmpPath)
+		return errors.Wrapf(err, "rename scrub result: %s", tmpPath)
+	}
+	return nil
+}
+
+// markScrubbed sets the result of the last scrub of the statuses by the scrub
+// records of their volume dirs.
+func (worker *Worker) markScrubbed(statuses []modelStatus.Status) {
+	cfg := worker.cfg.Get()
+	for idx := range statuses {
+		volumeDir := cfg.GetVolumeDir(statuses[idx].VolumeName)
+		if statuses[idx].MountID != "" {
+			volumeDir = cfg.GetMountIDDirForDynamic(statuses[idx].VolumeName, statuses[idx].MountID)
+		}
+		data, err := os.ReadFile(filepath.Join(volumeDir, scrubFile))
+		if err != nil {
+			continue
+		}
+		result := modelStatus.ScrubResult{}
+		if err := json.Unmarshal(data, &result); err == nil {
+			statuses[idx].Scrub = &result
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/metrics"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrubModels(t *testing.T) {
+	svc, _ := newNodeService(t)
+	puller := &blockingPuller{started: make(chan struct{}), release: make(chan struct{})}
+	close(puller.release)
+	svc.worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return puller
+	}
+	ctx := context.Background()
+	cfg := svc.cfg.Get()
+	cfg.PullConfig.WriteChecksumFile = true
+	cfg.Features.Scrub.Enabled = true
+
+	prefetchName := prefetchVolumePrefix + "scrub"
+	for _, volumeName := range []string{"pvc-scrub", prefetchName} {
+		require.NoError(t, svc.worker.PullModel(ctx, true, volumeName, "", "test/model:latest", cfg.GetModelDir(volumeName), PullOptions{}))
+		require.FileExists(t, filepath.Join(cfg.GetModelDir(volumeName), checksumFile))
+	}
+	getScrub := func(volumeName string) *status.ScrubResult {
+		t.Helper()
+		volumeStatus, err := svc.sm.Get(filepath.Join(cfg.GetVolumeDir(volumeName), "status.json"))
+		require.NoError(t, err)
+		return svc.worker.withPinned(ctx, volumeStatus).Scrub
+	}
+
+	// The intact models are scrubbed without the corrupted files.
+	svc.scrubModels(ctx)
+	for _, volumeName := range []string{"pvc-scrub", prefetchName} {
+		scrub := getScrub(volumeName)
+		require.NotNil(t, scrub)
+		require.Equal(t, 1, scrub.CheckedFiles)
+		require.Empty(t, scrub.CorruptedFiles)
+	}
+	require.Zero(t, testutil.ToFloat64(metrics.NodeCorruptedModels))
+
+	// The corrupted files are reported without repair.
+	for _, volumeName := range []string{"pvc-scrub", prefetchName} {
+		require.NoError(t, os.WriteFile(filepath.Join(cfg.GetModelDir(volumeName), "weights.bin"), []byte("corrupted"), 0644))
+	}
+	svc.scrubModels(ctx)
+	for _, volumeName := range []string{"pvc-scrub", prefetchName} {
+		require.Equal(t, []string{"weights.bin"}, getScrub(volumeName).CorruptedFiles)
+	}
+	require.Equal(t, float64(2), testutil.ToFloat64(metrics.NodeCorruptedModels))
+	statuses := []status.Status{{VolumeName: "pvc-scrub"}, {VolumeName: "csi-dynamic", MountID: "mount-1"}}
+	svc.worker.markScrubbed(statuses)
+	require.NotNil(t, statuses[0].Scrub)
+	require.Nil(t, statuses[1].Scrub)
+
+	// Only the prefetched model is re-pulled on repair.
+	cfg.Features.Scrub.Repair = true
+	svc.scrubModels(ctx)
+	require.Equal(t, float64(1), testutil.ToFloat64(metrics.NodeCorruptedModels))
+	require.Nil(t, getScrub(prefetchName))
+	data, err := os.ReadFile(filepath.Join(cfg.GetModelDir(prefetchName), "weights.bin"))
+	require.NoError(t, err)
+	require.Equal(t, "weights", string(data))
+	require.Equal(t, []string{"weights.bin"}, getScrub("pvc-scrub").CorruptedFiles)
+}
+
+func TestReadChecksumFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, checksumFile)
+	require.NoError(t, os.WriteFile(path, []byte("abc  config.json\ndef  sub/weights.bin\n"), 0644))
+	checksums, err := readChecksumFile(path)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"config.json": "abc", "sub/weights.bin": "def"}, checksums)
+
+	require.NoError(t, os.WriteFile(path, []byte("abc  ../escape\n"), 0644))
+	_, err = readChecksumFile(path)
+	require.Error(t, err)
+}
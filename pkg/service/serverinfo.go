@@ -0,0 +1,67 @@
+package service
+
+import (
+	"path/filepath"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/pkg/errors"
+)
+
+// ServerInfo is the info of the driver serving the dynamic server, for the
+// clients in the pod to check the socket before creating the mounts.
+type ServerInfo struct {
+	ServiceName string `json:"service_name"`
+	Version     string `json:"version"`
+	NodeID      string `json:"node_id"`
+	// The dynamic volume of the socket, empty for the deprecated dynamic
+	// csi endpoint shared by the volumes.
+	VolumeName string        `json:"volume_name,omitempty"`
+	DiskQuota  DiskQuotaInfo `json:"disk_quota"`
+}
+
+// DiskQuotaInfo is the disk quota of the node for the models, the same as
+// checked by the mounts with check_disk_quota.
+type DiskQuotaInfo struct {
+	// The check of the disk quota is enabled by features.check_disk_quota.
+	Enabled bool `json:"enabled"`
+	// The disk_usage_limit (or the size of the file system of root_dir) and
+	// the storage tiers.
+	TotalSize int64 `json:"total_size"`
+	UsedSize  int64 `json:"used_size"`
+	// The size reserved by the in-flight pulls.
+	ReservedSize  int64 `json:"reserved_size"`
+	AvailableSize int64 `json:"available_size"`
+}
+
+// dynamicServerVolumeName returns the dynamic volume of the socket of the
+// dynamic server, empty if the socket isn't of a dynamic volume.
+func dynamicServerVolumeName(cfg *config.RawConfig, sockPath string) string {
+	volumeName := filepath.Base(filepath.Dir(filepath.Dir(sockPath)))
+	if cfg.GetCSISockPathForDynamic(volumeName) != sockPath {
+		return ""
+	}
+	return volumeName
+}
+
+func (s *Service) serverInfo(volumeName string) (*ServerInfo, error) {
+	cfg := s.cfg.Get()
+	usedSize, totalSize, err := diskUsage(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "get disk usage")
+	}
+	reservedSize := s.worker.reservations.Pending()
+
+	return &ServerInfo{
+		ServiceName: cfg.ServiceName,
+		Version:     VendorVersion,
+		NodeID:      cfg.NodeID,
+		VolumeName:  volumeName,
+		DiskQuota: DiskQuotaInfo{
+			Enabled:       cfg.Features.CheckDiskQuota,
+			TotalSize:     totalSize,
+			UsedSize:      usedSize,
+			ReservedSize:  reservedSize,
+			AvailableSize: max(totalSize-usedSize-reservedSize, 0),
+		},
+	}, nil
+}
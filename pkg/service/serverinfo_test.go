@@ -0,0 +1,50 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynamicServerVolumeName(t *testing.T) {
+	svc, _ := newNodeService(t)
+	cfg := svc.cfg.Get()
+
+	require.Equal(t, "csi-vol", dynamicServerVolumeName(cfg, cfg.GetCSISockPathForDynamic("csi-vol")))
+	require.Empty(t, dynamicServerVolumeName(cfg, "/run/model-csi/csi.sock"))
+}
+
+func TestDynamicServerHandler_Healthz(t *testing.T) {
+	h, svc := newHandler(t)
+
+	c, rec := newHandlerContextWithParam(t, http.MethodGet, "/", "", nil, nil)
+	_ = h.Healthz(c)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// The root dir isn't writable.
+	require.NoError(t, os.RemoveAll(svc.cfg.Get().RootDir))
+	c, rec = newHandlerContextWithParam(t, http.MethodGet, "/", "", nil, nil)
+	_ = h.Healthz(c)
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestDynamicServerHandler_GetInfo(t *testing.T) {
+	h, svc := newHandler(t)
+	h.volumeName = "csi-vol"
+	svc.cfg.Get().Features.CheckDiskQuota = true
+
+	c, rec := newHandlerContextWithParam(t, http.MethodGet, "/", "", nil, nil)
+	_ = h.GetInfo(c)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var info ServerInfo
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+	require.Equal(t, VendorVersion, info.Version)
+	require.Equal(t, "test-node-1", info.NodeID)
+	require.Equal(t, "csi-vol", info.VolumeName)
+	require.True(t, info.DiskQuota.Enabled)
+	require.Positive(t, info.DiskQuota.TotalSize)
+	require.Equal(t, info.DiskQuota.TotalSize-info.DiskQuota.UsedSize, info.DiskQuota.AvailableSize)
+}
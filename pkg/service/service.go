@@ -1,14 +1,19 @@
 package service
 
 import (
+	"context"
 	"net/url"
+	"sync"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/fault"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
 	"github.com/modelpack/model-csi-driver/pkg/status"
 	"github.com/modelpack/model-csi-driver/pkg/tracing"
 	"github.com/pkg/errors"
 	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	storagev1 "k8s.io/client-go/kubernetes/typed/storage/v1"
 )
 
 const (
@@ -17,6 +22,11 @@ const (
 
 	labelHostname          = "kubernetes.io/hostname"
 	annotationSelectedNode = "volume.kubernetes.io/selected-node"
+
+	// Pod info passed in the volume context by kubelet if podInfoOnMount is enabled.
+	volumeContextPodNamespace = "csi.storage.k8s.io/pod.namespace"
+	volumeContextPodName      = "csi.storage.k8s.io/pod.name"
+	volumeContextPodUID       = "csi.storage.k8s.io/pod.uid"
 )
 
 type Service struct {
@@ -25,6 +35,14 @@ type Service struct {
 	csi.UnimplementedNodeServer
 
 	cfg *config.Config
+	// The in-flight CSI calls and their recent errors.
+	operations *OperationTracker
+	// The dynamic mounts created in the background, keyed by
+	// volume_name/mount_id.
+	asyncMounts sync.Map
+	// The states of the dynamic mounts being deleted, keyed by
+	// volume_name/mount_id.
+	deletingMounts sync.Map
 
 	// only for node mode
 	dynamicCSISockPath   string
@@ -32,23 +50,35 @@ type Service struct {
 	cm                   *CacheManager
 	worker               *Worker
 	DynamicServerManager *DynamicServerManager
+	pods                 v1.PodsGetter
 
 	// only for controller mode
 	remoteGRPCPort string
 	node           v1.NodeInterface
+	pvs            v1.PersistentVolumeInterface
+	storage        storagev1.StorageV1Interface
 }
 
 func (svc *Service) StatusManager() *status.StatusManager {
 	return svc.sm
 }
 
+// Close releases the resources held by the service, e.g. the status watcher.
+func (svc *Service) Close() error {
+	if svc.sm != nil {
+		return svc.sm.Close()
+	}
+	return nil
+}
+
 func New(cfg *config.Config) (*Service, error) {
 	if err := tracing.Init(cfg); err != nil {
 		return nil, errors.Wrap(err, "initialize tracing")
 	}
 
 	svc := Service{
-		cfg: cfg,
+		cfg:        cfg,
+		operations: NewOperationTracker(),
 	}
 
 	if cfg.Get().IsControllerMode() {
@@ -66,6 +96,10 @@ func New(cfg *config.Config) (*Service, error) {
 		}
 		svc.remoteGRPCPort = url.Port()
 		svc.node = clientset.CoreV1().Nodes()
+		svc.pvs = clientset.CoreV1().PersistentVolumes()
+		svc.storage = clientset.StorageV1()
+
+		go svc.publishStorageCapacityLoop()
 	} else {
 		sm, err := status.NewStatusManager()
 		if err != nil {
@@ -75,7 +109,31 @@ func New(cfg *config.Config) (*Service, error) {
 		if err != nil {
 			return nil, errors.Wrap(err, "create worker")
 		}
-		cm, err := NewCacheManager(cfg, sm)
+		var node v1.NodeInterface
+		if cfg.Get().Features.PublishCachedModels || cfg.Get().Features.CleanupOrphanedVolumes {
+			clientset, err := loadKubeConfig()
+			if err != nil {
+				return nil, errors.Wrap(err, "load kube config")
+			}
+			node = clientset.CoreV1().Nodes()
+			svc.pods = clientset.CoreV1()
+		}
+		if cfg.Get().Features.ReconcileVolumes.Enabled {
+			clientset, err := loadKubeConfig()
+			if err != nil {
+				return nil, errors.Wrap(err, "load kube config")
+			}
+			svc.pvs = clientset.CoreV1().PersistentVolumes()
+		}
+		var secrets v1.SecretsGetter
+		if len(cfg.Get().PullConfig.RegistryAuth.Secrets) > 0 {
+			clientset, err := loadKubeConfig()
+			if err != nil {
+				return nil, errors.Wrap(err, "load kube config")
+			}
+			secrets = clientset.CoreV1()
+		}
+		cm, err := NewCacheManager(cfg, sm, node)
 		if err != nil {
 			return nil, errors.Wrap(err, "create cache manager")
 		}
@@ -94,10 +152,33 @@ func New(cfg *config.Config) (*Service, error) {
 
 		dsm := NewDynamicServerManager(cfg, &svc)
 
+		fault.Setup(cfg)
+		if fault.Enabled() {
+			logger.Logger().Warnf("fault injection is enabled, never enable it in production")
+		}
+
 		svc.sm = sm
 		svc.cm = cm
 		svc.worker = worker
 		svc.DynamicServerManager = dsm
+
+		worker.FailInterruptedWeightsPulls(context.Background())
+		svc.reconcileVolumesOnStart(context.Background())
+
+		if cfg.Get().Features.CleanupOrphanedVolumes {
+			go svc.cleanupOrphanedVolumesLoop()
+		}
+
+		// The registry auth is read before serving the pulls.
+		if err := svc.syncRegistryAuth(context.Background(), secrets); err != nil {
+			logger.Logger().WithError(err).Warnf("sync registry auth failed")
+		}
+		go svc.syncRegistryAuthLoop(secrets)
+		go svc.reconcileWarmModelsLoop()
+		go svc.evictModelsLoop()
+		go svc.gcBlobsLoop()
+		go svc.reapExpiredMountsLoop()
+		go svc.scrubModelsLoop()
 	}
 
 	return &svc, nil
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"path/filepath"
 	"testing"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
@@ -40,6 +41,18 @@ func TestGetPluginCapabilities(t *testing.T) {
 		csi.PluginCapability_Service_CONTROLLER_SERVICE,
 		resp.Capabilities[0].GetService().Type,
 	)
+
+	svc.cfg.Get().Mode = "controller"
+	resp, err = svc.GetPluginCapabilities(context.Background(), &csi.GetPluginCapabilitiesRequest{})
+	require.NoError(t, err)
+	caps := []csi.PluginCapability_Service_Type{}
+	for _, capability := range resp.Capabilities {
+		caps = append(caps, capability.GetService().GetType())
+	}
+	require.Equal(t, []csi.PluginCapability_Service_Type{
+		csi.PluginCapability_Service_CONTROLLER_SERVICE,
+		csi.PluginCapability_Service_VOLUME_ACCESSIBILITY_CONSTRAINTS,
+	}, caps)
 }
 
 func TestProbe(t *testing.T) {
@@ -49,18 +62,66 @@ func TestProbe(t *testing.T) {
 	require.NotNil(t, resp)
 }
 
-// Node stubs
+func TestProbe_Node(t *testing.T) {
+	svc, tmpDir := newNodeService(t)
+	ctx := context.Background()
+	svc.cfg.Get().Mode = "node"
+
+	resp, err := svc.Probe(ctx, &csi.ProbeRequest{})
+	require.NoError(t, err)
+	require.True(t, resp.GetReady().GetValue())
+
+	// Not ready until the dynamic server is serving.
+	svc.dynamicCSISockPath = filepath.Join(tmpDir, "dynamic-csi.sock")
+	svc.DynamicServerManager = NewDynamicServerManager(svc.cfg, svc)
+	resp, err = svc.Probe(ctx, &csi.ProbeRequest{})
+	require.NoError(t, err)
+	require.False(t, resp.GetReady().GetValue())
+	_, err = svc.DynamicServerManager.CreateServer(ctx, svc.dynamicCSISockPath)
+	require.NoError(t, err)
+	resp, err = svc.Probe(ctx, &csi.ProbeRequest{})
+	require.NoError(t, err)
+	require.True(t, resp.GetReady().GetValue())
+	require.NoError(t, svc.DynamicServerManager.CloseServer(ctx, svc.dynamicCSISockPath))
+	resp, err = svc.Probe(ctx, &csi.ProbeRequest{})
+	require.NoError(t, err)
+	require.False(t, resp.GetReady().GetValue())
+	svc.dynamicCSISockPath = ""
+
+	// The node with the disk full or the root dir gone is broken.
+	origMinFreeBytes := probeMinFreeBytes
+	probeMinFreeBytes = 1 << 62
+	_, err = svc.Probe(ctx, &csi.ProbeRequest{})
+	probeMinFreeBytes = origMinFreeBytes
+	require.Equal(t, codes.FailedPrecondition, grpcStatus.Code(err))
+	svc.cfg.Get().RootDir = filepath.Join(tmpDir, "nonexistent")
+	_, err = svc.Probe(ctx, &csi.ProbeRequest{})
+	require.Equal(t, codes.FailedPrecondition, grpcStatus.Code(err))
+}
+
+// Node staging
 
 func TestNodeStageVolume(t *testing.T) {
 	svc := newTestService(t)
-	resp, err := svc.NodeStageVolume(context.Background(), &csi.NodeStageVolumeRequest{})
+	_, err := svc.NodeStageVolume(context.Background(), &csi.NodeStageVolumeRequest{})
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+	// The dynamic volume isn't staged.
+	resp, err := svc.NodeStageVolume(context.Background(), &csi.NodeStageVolumeRequest{
+		VolumeId:          "csi-dyn",
+		StagingTargetPath: t.TempDir(),
+	})
 	require.NoError(t, err)
 	require.NotNil(t, resp)
 }
 
 func TestNodeUnstageVolume(t *testing.T) {
 	svc := newTestService(t)
-	resp, err := svc.NodeUnstageVolume(context.Background(), &csi.NodeUnstageVolumeRequest{})
+	_, err := svc.NodeUnstageVolume(context.Background(), &csi.NodeUnstageVolumeRequest{})
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+	resp, err := svc.NodeUnstageVolume(context.Background(), &csi.NodeUnstageVolumeRequest{
+		VolumeId:          "csi-dyn",
+		StagingTargetPath: t.TempDir(),
+	})
 	require.NoError(t, err)
 	require.NotNil(t, resp)
 }
@@ -70,7 +131,19 @@ func TestNodeGetCapabilities(t *testing.T) {
 	resp, err := svc.NodeGetCapabilities(context.Background(), &csi.NodeGetCapabilitiesRequest{})
 	require.NoError(t, err)
 	require.NotNil(t, resp)
+	require.Empty(t, resp.Capabilities)
+
+	svc.cfg.Get().Features.StageVolume = true
+	resp, err = svc.NodeGetCapabilities(context.Background(), &csi.NodeGetCapabilitiesRequest{})
+	require.NoError(t, err)
 	require.Len(t, resp.Capabilities, 1)
+	require.Equal(t, csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME, resp.Capabilities[0].GetRpc().GetType())
+
+	svc.cfg.Get().Features.VolumeMountGroup = true
+	resp, err = svc.NodeGetCapabilities(context.Background(), &csi.NodeGetCapabilitiesRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Capabilities, 2)
+	require.Equal(t, csi.NodeServiceCapability_RPC_VOLUME_MOUNT_GROUP, resp.Capabilities[1].GetRpc().GetType())
 }
 
 func TestNodeGetInfo(t *testing.T) {
@@ -0,0 +1,344 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// ErrSignatureVerification is returned if the model image is unsigned or
+// none of its signatures is valid.
+var ErrSignatureVerification = errors.New("signature verification failed")
+
+// The annotations and media type of the cosign signature layer.
+const (
+	cosignSignatureAnnotation    = "dev.cosignproject.cosign/signature"
+	cosignCertificateAnnotation  = "dev.sigstore.cosign/certificate"
+	cosignChainAnnotation        = "dev.sigstore.cosign/chain"
+	cosignBundleAnnotation       = "dev.sigstore.cosign/bundle"
+	cosignSimpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+)
+
+// The extensions of the Fulcio certificate holding the OIDC issuer.
+var (
+	oidFulcioIssuer   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidFulcioIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// cosignPayload is the simple signing payload signed by cosign.
+type cosignPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// rekorPayload is the transparency log entry, the fields are ordered so that
+// it's marshaled to the canonical JSON signed by the Rekor.
+type rekorPayload struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+}
+
+type cosignBundle struct {
+	SignedEntryTimestamp []byte       `json:"SignedEntryTimestamp"`
+	Payload              rekorPayload `json:"Payload"`
+}
+
+// hashedRekord is the body of the transparency log entry of the signature.
+type hashedRekord struct {
+	Spec struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+		Signature struct {
+			Content string `json:"content"`
+		} `json:"signature"`
+	} `json:"spec"`
+}
+
+type signatureVerifier struct {
+	cfg        *config.SignatureConfig
+	publicKeys []crypto.PublicKey
+	roots      *x509.CertPool
+	rekorKey   crypto.PublicKey
+}
+
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read public key: %s", path)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.Errorf("invalid pem of public key: %s", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse public key: %s", path)
+	}
+	return key, nil
+}
+
+func newSignatureVerifier(cfg *config.SignatureConfig) (*signatureVerifier, error) {
+	verifier := &signatureVerifier{cfg: cfg}
+
+	for _, path := range cfg.PublicKeyFiles {
+		key, err := loadPublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+		verifier.publicKeys = append(verifier.publicKeys, key)
+	}
+
+	if len(cfg.Keyless) > 0 {
+		data, err := os.ReadFile(cfg.FulcioRootCertsFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read fulcio root certs: %s", cfg.FulcioRootCertsFile)
+		}
+		verifier.roots = x509.NewCertPool()
+		if !verifier.roots.AppendCertsFromPEM(data) {
+			return nil, errors.Errorf("no cert found in fulcio root certs: %s", cfg.FulcioRootCertsFile)
+		}
+		verifier.rekorKey, err = loadPublicKey(cfg.RekorPublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return verifier, nil
+}
+
+// verifySignature verifies that the manifest is signed by cosign with any of
+// the configured public keys or keyless identities, the signature is found by
+// the tag "sha256-<hex>.sig" in the same repository.
+func verifySignature(ctx context.Context, cfg *config.SignatureConfig, repo *remote.Repository, manifestDesc ocispec.Descriptor) error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	verifier, err := newSignatureVerifier(cfg)
+	if err != nil {
+		return err
+	}
+
+	dgst := manifestDesc.Digest
+	sigTag := fmt.Sprintf("%s-%s.sig", dgst.Algorithm(), dgst.Encoded())
+	sigDesc, rc, err := repo.FetchReference(ctx, sigTag)
+	if err != nil {
+		if errors.Is(err, errdef.ErrNotFound) {
+			return errors.Wrapf(ErrSignatureVerification, "no signature found for %s@%s", repo.Reference.Repository, dgst)
+		}
+		return errors.Wrapf(err, "fetch signature: %s", sigTag)
+	}
+	defer func() { _ = rc.Close() }()
+	data, err := content.ReadAll(rc, sigDesc)
+	if err != nil {
+		return errors.Wrapf(err, "read signature: %s", sigTag)
+	}
+	var sigManifest ocispec.Manifest
+	if err := json.Unmarshal(data, &sigManifest); err != nil {
+		return errors.Wrapf(err, "unmarshal signature: %s", sigTag)
+	}
+
+	reasons := []string{}
+	for _, layer := range sigManifest.Layers {
+		if layer.MediaType != cosignSimpleSigningMediaType {
+			continue
+		}
+		payload, err := content.FetchAll(ctx, repo, layer)
+		if err != nil {
+			return errors.Wrapf(err, "fetch signature payload: %s", layer.Digest)
+		}
+		if err := verifier.verify(layer, payload, manifestDesc); err != nil {
+			reasons = append(reasons, err.Error())
+			continue
+		}
+		logger.WithContext(ctx).Infof("verified signature of %s@%s", repo.Reference.Repository, dgst)
+		return nil
+	}
+
+	if len(reasons) == 0 {
+		return errors.Wrapf(ErrSignatureVerification, "no signature found for %s@%s", repo.Reference.Repository, dgst)
+	}
+	return errors.Wrapf(ErrSignatureVerification, "no valid signature for %s@%s: %s", repo.Reference.Repository, dgst, strings.Join(reasons, "; "))
+}
+
+// verify verifies the signature of the payload, which must be signed for
+// the manifest.
+func (v *signatureVerifier) verify(layer ocispec.Descriptor, payload []byte, manifestDesc ocispec.Descriptor) error {
+	var simpleSigning cosignPayload
+	if err := json.Unmarshal(payload, &simpleSigning); err != nil {
+		return errors.Wrap(err, "unmarshal payload")
+	}
+	if simpleSigning.Critical.Image.DockerManifestDigest != manifestDesc.Digest.String() {
+		return errors.Errorf("payload is signed for %s", simpleSigning.Critical.Image.DockerManifestDigest)
+	}
+
+	sigBase64 := layer.Annotations[cosignSignatureAnnotation]
+	sig, err := base64.StdEncoding.DecodeString(sigBase64)
+	if err != nil {
+		return errors.Wrap(err, "decode signature")
+	}
+
+	if certPEM := layer.Annotations[cosignCertificateAnnotation]; certPEM != "" {
+		return v.verifyKeyless(layer, certPEM, payload, sigBase64, sig)
+	}
+
+	for _, key := range v.publicKeys {
+		if verifyPayload(key, payload, sig) {
+			return nil
+		}
+	}
+	return errors.New("signature isn't signed by the public keys")
+}
+
+// verifyKeyless verifies the signature signed by the short-lived Fulcio
+// certificate, which must be valid at the time the signature is recorded in
+// the Rekor transparency log.
+func (v *signatureVerifier) verifyKeyless(layer ocispec.Descriptor, certPEM string, payload []byte, sigBase64 string, sig []byte) error {
+	if len(v.cfg.Keyless) == 0 {
+		return errors.New("keyless signature isn't allowed")
+	}
+
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return errors.New("invalid pem of certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return errors.Wrap(err, "parse certificate")
+	}
+
+	integratedTime, err := v.verifyBundle(layer.Annotations[cosignBundleAnnotation], payload, sigBase64)
+	if err != nil {
+		return err
+	}
+
+	intermediates := x509.NewCertPool()
+	intermediates.AppendCertsFromPEM([]byte(layer.Annotations[cosignChainAnnotation]))
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		CurrentTime:   integratedTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return errors.Wrap(err, "verify certificate")
+	}
+
+	issuer := certIssuer(cert)
+	subjects := append([]string{}, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		subjects = append(subjects, uri.String())
+	}
+	matched := slices.ContainsFunc(v.cfg.Keyless, func(identity config.KeylessIdentity) bool {
+		return identity.Issuer == issuer && slices.Contains(subjects, identity.Subject)
+	})
+	if !matched {
+		return errors.Errorf("certificate identity %v issued by %s isn't allowed", subjects, issuer)
+	}
+
+	if !verifyPayload(cert.PublicKey, payload, sig) {
+		return errors.New("signature isn't signed by the certificate")
+	}
+
+	return nil
+}
+
+// verifyBundle verifies the signed entry timestamp of the Rekor transparency
+// log entry recording the signature, and returns the time it's recorded.
+func (v *signatureVerifier) verifyBundle(bundleJSON string, payload []byte, sigBase64 string) (time.Time, error) {
+	if bundleJSON == "" {
+		return time.Time{}, errors.New("no rekor bundle found")
+	}
+	var bundle cosignBundle
+	if err := json.Unmarshal([]byte(bundleJSON), &bundle); err != nil {
+		return time.Time{}, errors.Wrap(err, "unmarshal rekor bundle")
+	}
+
+	canonical, err := json.Marshal(bundle.Payload)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "marshal rekor payload")
+	}
+	if !verifyPayload(v.rekorKey, canonical, bundle.SignedEntryTimestamp) {
+		return time.Time{}, errors.New("invalid signed entry timestamp of rekor bundle")
+	}
+
+	body, err := base64.StdEncoding.DecodeString(bundle.Payload.Body)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "decode rekor entry")
+	}
+	var entry hashedRekord
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return time.Time{}, errors.Wrap(err, "unmarshal rekor entry")
+	}
+	payloadHash := sha256.Sum256(payload)
+	if entry.Spec.Data.Hash.Algorithm != "sha256" || entry.Spec.Data.Hash.Value != hex.EncodeToString(payloadHash[:]) ||
+		entry.Spec.Signature.Content != sigBase64 {
+		return time.Time{}, errors.New("rekor entry doesn't match the signature")
+	}
+
+	return time.Unix(bundle.Payload.IntegratedTime, 0), nil
+}
+
+// certIssuer returns the OIDC issuer recorded in the Fulcio certificate.
+func certIssuer(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidFulcioIssuerV2) {
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+				return issuer
+			}
+		}
+	}
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidFulcioIssuer) {
+			return string(bytes.TrimSpace(ext.Value))
+		}
+	}
+	return ""
+}
+
+// verifyPayload verifies the signature of the payload signed with the sha256
+// digest, or the payload itself for the ed25519 key.
+func verifyPayload(key crypto.PublicKey, payload, sig []byte) bool {
+	digest := sha256.Sum256(payload)
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, digest[:], sig)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, payload, sig)
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func writePublicKey(t *testing.T, key crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "key.pub")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644))
+	return path
+}
+
+func signPayload(t *testing.T, key *ecdsa.PrivateKey, payload []byte) []byte {
+	sum := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+	require.NoError(t, err)
+	return sig
+}
+
+// newSignedRegistry serves the model manifest of "test/model:latest" signed
+// by the signature layer built with the payload of the manifest digest.
+func newSignedRegistry(t *testing.T, sign func(payload []byte) map[string]string) string {
+	manifest := ocispec.Manifest{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.example.model",
+		Config:       ocispec.DescriptorEmptyJSON,
+		Layers:       []ocispec.Descriptor{},
+	}
+	manifestData, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	manifestDigest := digest.FromBytes(manifestData)
+
+	manifests := map[string]ocispec.Manifest{"latest": manifest}
+	blobs := map[digest.Digest][]byte{}
+	if sign != nil {
+		payload := []byte(fmt.Sprintf(
+			`{"critical":{"identity":{"docker-reference":"test/model"},"image":{"docker-manifest-digest":"%s"},"type":"cosign container image signature"},"optional":null}`,
+			manifestDigest,
+		))
+		blobs[digest.FromBytes(payload)] = payload
+		manifests[fmt.Sprintf("sha256-%s.sig", manifestDigest.Encoded())] = ocispec.Manifest{
+			MediaType: ocispec.MediaTypeImageManifest,
+			Config:    ocispec.DescriptorEmptyJSON,
+			Layers: []ocispec.Descriptor{{
+				MediaType:   cosignSimpleSigningMediaType,
+				Digest:      digest.FromBytes(payload),
+				Size:        int64(len(payload)),
+				Annotations: sign(payload),
+			}},
+		}
+	}
+
+	return newFakeRegistryWithManifests(t, manifests, blobs) + "/test/model:latest"
+}
+
+func verifyRegistrySignature(t *testing.T, reference string, cfg *config.SignatureConfig) error {
+	ctx := context.Background()
+	repo, err := newOCIRepository(&config.PullConfig{}, reference)
+	require.NoError(t, err)
+	manifestDesc, _, err := fetchManifest(ctx, repo)
+	require.NoError(t, err)
+	return verifySignature(ctx, cfg, repo, manifestDesc)
+}
+
+func TestVerifySignature_PublicKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	reference := newSignedRegistry(t, func(payload []byte) map[string]string {
+		return map[string]string{cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(signPayload(t, key, payload))}
+	})
+
+	// The verification is skipped if disabled.
+	require.NoError(t, verifyRegistrySignature(t, reference, &config.SignatureConfig{}))
+
+	cfg := &config.SignatureConfig{
+		Enabled:        true,
+		PublicKeyFiles: []string{writePublicKey(t, &otherKey.PublicKey), writePublicKey(t, &key.PublicKey)},
+	}
+	require.NoError(t, verifyRegistrySignature(t, reference, cfg))
+
+	// Signed by the untrusted key.
+	cfg.PublicKeyFiles = []string{writePublicKey(t, &otherKey.PublicKey)}
+	err = verifyRegistrySignature(t, reference, cfg)
+	require.ErrorIs(t, err, ErrSignatureVerification)
+	require.ErrorContains(t, err, "signature isn't signed by the public keys")
+
+	// Unsigned.
+	reference = newSignedRegistry(t, nil)
+	err = verifyRegistrySignature(t, reference, cfg)
+	require.ErrorIs(t, err, ErrSignatureVerification)
+	require.ErrorContains(t, err, "no signature found")
+}
+
+func TestVerifySignature_Keyless(t *testing.T) {
+	now := time.Now()
+
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fulcio"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	require.NoError(t, err)
+	root, err := x509.ParseCertificate(rootDER)
+	require.NoError(t, err)
+	rootsFile := filepath.Join(t.TempDir(), "fulcio.pem")
+	require.NoError(t, os.WriteFile(rootsFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rootDER}), 0644))
+
+	// The short-lived certificate expired after the signing.
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	issuer, err := asn1.Marshal("https://accounts.example.com")
+	require.NoError(t, err)
+	leafTemplate := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       now.Add(-30 * time.Minute),
+		NotAfter:        now.Add(-20 * time.Minute),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		EmailAddresses:  []string{"signer@example.com"},
+		ExtraExtensions: []pkix.Extension{{Id: oidFulcioIssuerV2, Value: issuer}},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, root, &signingKey.PublicKey, rootKey)
+	require.NoError(t, err)
+
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	reference := newSignedRegistry(t, func(payload []byte) map[string]string {
+		sig := base64.StdEncoding.EncodeToString(signPayload(t, signingKey, payload))
+		payloadHash := sha256.Sum256(payload)
+		body, err := json.Marshal(map[string]any{
+			"apiVersion": "0.0.1",
+			"kind":       "hashedrekord",
+			"spec": map[string]any{
+				"data":      map[string]any{"hash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(payloadHash[:])}},
+				"signature": map[string]any{"content": sig},
+			},
+		})
+		require.NoError(t, err)
+		entry := rekorPayload{
+			Body:           base64.StdEncoding.EncodeToString(body),
+			IntegratedTime: now.Add(-25 * time.Minute).Unix(),
+			LogID:          "log",
+			LogIndex:       1,
+		}
+		canonical, err := json.Marshal(entry)
+		require.NoError(t, err)
+		bundle, err := json.Marshal(cosignBundle{SignedEntryTimestamp: signPayload(t, rekorKey, canonical), Payload: entry})
+		require.NoError(t, err)
+		return map[string]string{
+			cosignSignatureAnnotation:   sig,
+			cosignCertificateAnnotation: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})),
+			cosignBundleAnnotation:      string(bundle),
+		}
+	})
+
+	cfg := &config.SignatureConfig{
+		Enabled:             true,
+		Keyless:             []config.KeylessIdentity{{Issuer: "https://accounts.example.com", Subject: "signer@example.com"}},
+		FulcioRootCertsFile: rootsFile,
+		RekorPublicKeyFile:  writePublicKey(t, &rekorKey.PublicKey),
+	}
+	require.NoError(t, verifyRegistrySignature(t, reference, cfg))
+
+	// Signed by the other identity.
+	cfg.Keyless = []config.KeylessIdentity{{Issuer: "https://accounts.example.com", Subject: "other@example.com"}}
+	err = verifyRegistrySignature(t, reference, cfg)
+	require.ErrorIs(t, err, ErrSignatureVerification)
+	require.ErrorContains(t, err, "isn't allowed")
+
+	// The entry isn't recorded by the trusted Rekor.
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	cfg.Keyless = []config.KeylessIdentity{{Issuer: "https://accounts.example.com", Subject: "signer@example.com"}}
+	cfg.RekorPublicKeyFile = writePublicKey(t, &otherKey.PublicKey)
+	err = verifyRegistrySignature(t, reference, cfg)
+	require.ErrorIs(t, err, ErrSignatureVerification)
+	require.ErrorContains(t, err, "invalid signed entry timestamp")
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The record of the snapshot written beside its model dir once the model dir
+// is snapshotted, the snapshot dir without it is incomplete.
+const snapshotFile = "snapshot.json"
+
+// snapshotRecord is the snapshot of the model dir of a volume, kept in
+// $root_dir/snapshots/$snapshotName.
+type snapshotRecord struct {
+	SnapshotID     string    `json:"snapshot_id"`
+	SourceVolumeID string    `json:"source_volume_id"`
+	SizeInBytes    int64     `json:"size_in_bytes"`
+	CreatedAt      time.Time `json:"created_at"`
+	// The status of the source volume at the time of the snapshot, without
+	// the targets, used to create the volumes from the snapshot.
+	Status modelStatus.Status `json:"status"`
+}
+
+func (record *snapshotRecord) toCSI() *csi.Snapshot {
+	return &csi.Snapshot{
+		SnapshotId:     record.SnapshotID,
+		SourceVolumeId: record.SourceVolumeID,
+		SizeBytes:      record.SizeInBytes,
+		CreationTime:   timestamppb.New(record.CreatedAt),
+		ReadyToUse:     true,
+	}
+}
+
+func readSnapshotRecord(snapshotDir string) (*snapshotRecord, error) {
+	data, err := os.ReadFile(filepath.Join(snapshotDir, snapshotFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "read snapshot record")
+	}
+	record := snapshotRecord{}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, errors.Wrap(err, "unmarshal snapshot record")
+	}
+	return &record, nil
+}
+
+func writeSnapshotRecord(snapshotDir string, record *snapshotRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "marshal snapshot record")
+	}
+	tmpPath := filepath.Join(snapshotDir, "."+snapshotFile+".tmp")
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return errors.Wrapf(err, "write snapshot record: %s", tmpPath)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(snapshotDir, snapshotFile)); err != nil {
+		_ = os.Remove(tmpPath)
+		return errors.Wrapf(err, "rename snapshot record: %s", tmpPath)
+	}
+	return nil
+}
+
+// snapshotContextKey returns the key the creation and the deletion of the
+// snapshot are locked by.
+func snapshotContextKey(snapshotName string) string {
+	return "snapshots/" + snapshotName
+}
+
+func isValidSnapshotName(snapshotName string) bool {
+	return snapshotName != "" && !strings.Contains(snapshotName, "/") && filepath.IsLocal(snapshotName)
+}
+
+// localCreateSnapshot snapshots the model dir of the pulled volume into the
+// snapshot dir, the model files are cloned the same as the models reused by
+// the volumes, i.e. hardlinked only if the shared blob store is enabled.
+func (s *Service) localCreateSnapshot(
+	ctx context.Context,
+	req *csi.CreateSnapshotRequest) (
+	*csi.CreateSnapshotResponse, error) {
+	snapshotName := req.GetName()
+	if !isValidSnapshotName(snapshotName) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid snapshot name: %s", snapshotName)
+	}
+	sourceVolumeID := req.GetSourceVolumeId()
+	if sourceVolumeID == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing required parameter: sourceVolumeID")
+	}
+	volumeDir, modelDir, err := s.resolveVolumeDir(sourceVolumeID)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotKey := snapshotContextKey(snapshotName)
+	if err := s.worker.kmutex.Lock(ctx, snapshotKey); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrapf(err, "lock context key: %s", snapshotKey).Error())
+	}
+	defer s.worker.kmutex.Unlock(snapshotKey)
+
+	snapshotDir := s.cfg.Get().GetSnapshotDir(snapshotName)
+	if record, err := readSnapshotRecord(snapshotDir); err == nil {
+		if record.SourceVolumeID != sourceVolumeID {
+			return nil, status.Errorf(codes.AlreadyExists, "snapshot %s exists for another volume: %s", snapshotName, record.SourceVolumeID)
+		}
+		return &csi.CreateSnapshotResponse{Snapshot: record.toCSI()}, nil
+	}
+
+	// The model dir isn't deleted or pulled again while it's snapshotted.
+	volumeKey := volumeContextKey(s.cfg.Get(), volumeDir)
+	if err := s.worker.kmutex.Lock(ctx, volumeKey); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrapf(err, "lock context key: %s", volumeKey).Error())
+	}
+	defer s.worker.kmutex.Unlock(volumeKey)
+
+	volumeStatus, err := s.sm.Get(filepath.Join(volumeDir, "status.json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, status.Errorf(codes.NotFound, "source volume not found: %s", sourceVolumeID)
+		}
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "get source volume status").Error())
+	}
+	if !isScrubbable(volumeStatus) {
+		return nil, status.Errorf(codes.FailedPrecondition, "model of source volume %s isn't pulled: %s", sourceVolumeID, volumeStatus.State)
+	}
+	if reason := missingModelContent(modelDir, volumeStatus); reason != "" {
+		return nil, status.Errorf(codes.FailedPrecondition, "model of source volume %s is %s", sourceVolumeID, reason)
+	}
+
+	// The incomplete snapshot left by a crash is replaced.
+	if err := os.RemoveAll(snapshotDir); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrapf(err, "cleanup snapshot dir: %s", snapshotDir).Error())
+	}
+	if err := os.MkdirAll(snapshotDir, 0755); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrapf(err, "create snapshot dir: %s", snapshotDir).Error())
+	}
+	snapshotModelDir := filepath.Join(snapshotDir, "model")
+	if err := s.worker.cloneModelDir(modelDir, snapshotModelDir); err != nil {
+		_ = os.RemoveAll(snapshotDir)
+		return nil, status.Error(codes.Internal, errors.Wrapf(err, "clone model dir: %s", modelDir).Error())
+	}
+
+	snapshotStatus := *volumeStatus
+	snapshotStatus.Targets = nil
+	snapshotStatus.StagingPath = ""
+	snapshotStatus.Progress = modelStatus.Progress{}
+	snapshotStatus.State = modelStatus.StatePullSucceeded
+	record := snapshotRecord{
+		SnapshotID:     snapshotName,
+		SourceVolumeID: sourceVolumeID,
+		SizeInBytes:    modelDirSize(ctx, snapshotModelDir),
+		CreatedAt:      time.Now(),
+		Status:         snapshotStatus,
+	}
+	if err := writeSnapshotRecord(snapshotDir, &record); err != nil {
+		_ = os.RemoveAll(snapshotDir)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	logger.WithContext(ctx).Infof("snapshotted model %s of volume %s into %s", volumeStatus.Reference, sourceVolumeID, snapshotDir)
+
+	return &csi.CreateSnapshotResponse{Snapshot: record.toCSI()}, nil
+}
+
+// localDeleteSnapshot removes the snapshot dir, it's a no-op if the snapshot
+// doesn't exist.
+func (s *Service) localDeleteSnapshot(
+	ctx context.Context,
+	req *csi.DeleteSnapshotRequest) (
+	*csi.DeleteSnapshotResponse, error) {
+	snapshotID := req.GetSnapshotId()
+	if !isValidSnapshotName(snapshotID) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid snapshot id: %s", snapshotID)
+	}
+
+	snapshotKey := snapshotContextKey(snapshotID)
+	if err := s.worker.kmutex.Lock(ctx, snapshotKey); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrapf(err, "lock context key: %s", snapshotKey).Error())
+	}
+	defer s.worker.kmutex.Unlock(snapshotKey)
+
+	snapshotDir := s.cfg.Get().GetSnapshotDir(snapshotID)
+	if err := os.RemoveAll(snapshotDir); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrapf(err, "remove snapshot dir: %s", snapshotDir).Error())
+	}
+	logger.WithContext(ctx).Infof("deleted snapshot: %s", snapshotDir)
+
+	return &csi.DeleteSnapshotResponse{}, nil
+}
+
+// localListSnapshots lists the complete snapshots on the node, filtered by
+// the snapshot ID or the source volume ID of the request, sorted by the
+// snapshot ID and paginated the same as the volumes.
+func (s *Service) localListSnapshots(
+	ctx context.Context,
+	req *csi.ListSnapshotsRequest) (
+	*csi.ListSnapshotsResponse, error) {
+	start, err := parseStartingToken(req.GetStartingToken(), req.GetMaxEntries())
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotsDir := s.cfg.Get().GetSnapshotsDir()
+	dirEntries, err := os.ReadDir(snapshotsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, status.Error(codes.Internal, errors.Wrapf(err, "read snapshots dir: %s", snapshotsDir).Error())
+	}
+
+	entries := []*csi.ListSnapshotsResponse_Entry{}
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() {
+			continue
+		}
+		if req.GetSnapshotId() != "" && dirEntry.Name() != req.GetSnapshotId() {
+			continue
+		}
+		record, err := readSnapshotRecord(filepath.Join(snapshotsDir, dirEntry.Name()))
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				logger.WithContext(ctx).WithError(err).Warnf("failed to read snapshot: %s", dirEntry.Name())
+			}
+			continue
+		}
+		if req.GetSourceVolumeId() != "" && record.SourceVolumeID != req.GetSourceVolumeId() {
+			continue
+		}
+		entries = append(entries, &csi.ListSnapshotsResponse_Entry{Snapshot: record.toCSI()})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Snapshot.SnapshotId < entries[j].Snapshot.SnapshotId
+	})
+
+	entries, nextToken, err := paginateEntries(entries, start, int(req.GetMaxEntries()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &csi.ListSnapshotsResponse{
+		Entries:   entries,
+		NextToken: nextToken,
+	}, nil
+}
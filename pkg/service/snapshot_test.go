@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSnapshots(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	cfg := svc.cfg.Get()
+
+	_, err := svc.CreateSnapshot(ctx, &csi.CreateSnapshotRequest{Name: "snapshot-1", SourceVolumeId: "pvc-source"})
+	require.Equal(t, codes.Unimplemented, grpcStatus.Code(err))
+	cfg.Features.Snapshot = true
+
+	newVolume := func(volumeDir, state string) {
+		t.Helper()
+		require.NoError(t, os.MkdirAll(filepath.Join(volumeDir, "model"), 0750))
+		require.NoError(t, os.WriteFile(filepath.Join(volumeDir, "model", "weights.bin"), []byte("weights"), 0644))
+		_, err := svc.sm.Set(filepath.Join(volumeDir, "status.json"), status.Status{
+			Reference:   "test/model:latest",
+			State:       state,
+			SizeInBytes: 7,
+			Targets:     []status.Target{{Path: "/target"}},
+		})
+		require.NoError(t, err)
+	}
+	newVolume(cfg.GetVolumeDir("pvc-source"), status.StateMounted)
+	newVolume(cfg.GetMountIDDirForDynamic("csi-dyn", "mount-1"), status.StatePullSucceeded)
+	newVolume(cfg.GetVolumeDir("pvc-pulling"), status.StatePullRunning)
+
+	// The model dir is snapshotted with the status of the source volume.
+	resp, err := svc.CreateSnapshot(ctx, &csi.CreateSnapshotRequest{Name: "snapshot-1", SourceVolumeId: "pvc-source"})
+	require.NoError(t, err)
+	require.Equal(t, "snapshot-1", resp.Snapshot.SnapshotId)
+	require.Equal(t, "pvc-source", resp.Snapshot.SourceVolumeId)
+	require.True(t, resp.Snapshot.ReadyToUse)
+	require.Positive(t, resp.Snapshot.SizeBytes)
+	data, err := os.ReadFile(filepath.Join(cfg.GetSnapshotDir("snapshot-1"), "model", "weights.bin"))
+	require.NoError(t, err)
+	require.Equal(t, "weights", string(data))
+	record, err := readSnapshotRecord(cfg.GetSnapshotDir("snapshot-1"))
+	require.NoError(t, err)
+	require.Equal(t, status.StatePullSucceeded, record.Status.State)
+	require.Empty(t, record.Status.Targets)
+
+	// The snapshot is independent of the source volume.
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.GetModelDir("pvc-source"), "weights.bin"), []byte("modified"), 0644))
+	data, err = os.ReadFile(filepath.Join(cfg.GetSnapshotDir("snapshot-1"), "model", "weights.bin"))
+	require.NoError(t, err)
+	require.Equal(t, "weights", string(data))
+
+	// The creation is idempotent for the same source volume.
+	again, err := svc.CreateSnapshot(ctx, &csi.CreateSnapshotRequest{Name: "snapshot-1", SourceVolumeId: "pvc-source"})
+	require.NoError(t, err)
+	require.Equal(t, resp.Snapshot.CreationTime.AsTime(), again.Snapshot.CreationTime.AsTime())
+	_, err = svc.CreateSnapshot(ctx, &csi.CreateSnapshotRequest{Name: "snapshot-1", SourceVolumeId: "csi-dyn/mount-1"})
+	require.Equal(t, codes.AlreadyExists, grpcStatus.Code(err))
+
+	_, err = svc.CreateSnapshot(ctx, &csi.CreateSnapshotRequest{Name: "snapshot-2", SourceVolumeId: "csi-dyn/mount-1"})
+	require.NoError(t, err)
+	_, err = svc.CreateSnapshot(ctx, &csi.CreateSnapshotRequest{Name: "snapshot-3", SourceVolumeId: "pvc-pulling"})
+	require.Equal(t, codes.FailedPrecondition, grpcStatus.Code(err))
+	require.NoDirExists(t, cfg.GetSnapshotDir("snapshot-3"))
+	_, err = svc.CreateSnapshot(ctx, &csi.CreateSnapshotRequest{Name: "snapshot-3", SourceVolumeId: "pvc-unknown"})
+	require.Equal(t, codes.NotFound, grpcStatus.Code(err))
+	_, err = svc.CreateSnapshot(ctx, &csi.CreateSnapshotRequest{Name: "../snapshot", SourceVolumeId: "pvc-source"})
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+
+	// The incomplete snapshot isn't listed.
+	require.NoError(t, os.MkdirAll(cfg.GetSnapshotDir("snapshot-incomplete"), 0750))
+	listSnapshotIDs := func(req *csi.ListSnapshotsRequest) ([]string, string) {
+		t.Helper()
+		resp, err := svc.ListSnapshots(ctx, req)
+		require.NoError(t, err)
+		snapshotIDs := []string{}
+		for _, entry := range resp.Entries {
+			snapshotIDs = append(snapshotIDs, entry.Snapshot.SnapshotId)
+		}
+		return snapshotIDs, resp.NextToken
+	}
+	snapshotIDs, nextToken := listSnapshotIDs(&csi.ListSnapshotsRequest{})
+	require.Equal(t, []string{"snapshot-1", "snapshot-2"}, snapshotIDs)
+	require.Empty(t, nextToken)
+	snapshotIDs, nextToken = listSnapshotIDs(&csi.ListSnapshotsRequest{MaxEntries: 1})
+	require.Equal(t, []string{"snapshot-1"}, snapshotIDs)
+	require.Equal(t, "1", nextToken)
+	snapshotIDs, _ = listSnapshotIDs(&csi.ListSnapshotsRequest{SourceVolumeId: "csi-dyn/mount-1"})
+	require.Equal(t, []string{"snapshot-2"}, snapshotIDs)
+	snapshotIDs, _ = listSnapshotIDs(&csi.ListSnapshotsRequest{SnapshotId: "snapshot-1"})
+	require.Equal(t, []string{"snapshot-1"}, snapshotIDs)
+
+	// The deletion is idempotent.
+	for i := 0; i < 2; i++ {
+		_, err = svc.DeleteSnapshot(ctx, &csi.DeleteSnapshotRequest{SnapshotId: "snapshot-1"})
+		require.NoError(t, err)
+	}
+	require.NoDirExists(t, cfg.GetSnapshotDir("snapshot-1"))
+	snapshotIDs, _ = listSnapshotIDs(&csi.ListSnapshotsRequest{})
+	require.Equal(t, []string{"snapshot-2"}, snapshotIDs)
+}
+
+func TestRemoteListSnapshots(t *testing.T) {
+	newNode := func(name, hostname, ip string) *corev1.Node {
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{labelHostname: hostname}},
+		}
+		node.Status.Addresses = []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: ip}}
+		return node
+	}
+	clientset := fake.NewSimpleClientset(
+		newNode("node-1", "host-1", "10.0.0.1"),
+		newNode("node-2", "host-2", "10.0.0.2"),
+		newNode("node-3", "host-3", "10.0.0.3"),
+	)
+	svc := &Service{
+		cfg: config.NewWithRaw(&config.RawConfig{
+			ServiceName: "model.csi.modelpack.org",
+			Mode:        "controller",
+			Features:    config.Features{Snapshot: true},
+		}),
+		node: clientset.CoreV1().Nodes(),
+	}
+	ctx := context.Background()
+
+	snapshots := map[string][]string{
+		"host-1": {"snapshot-b"},
+		"host-2": {"snapshot-a", "snapshot-c"},
+	}
+	origListNodeSnapshots := listNodeSnapshots
+	listNodeSnapshots = func(ctx context.Context, s *Service, nodeInfo *nodeInfo, req *csi.ListSnapshotsRequest) ([]*csi.ListSnapshotsResponse_Entry, error) {
+		snapshotIDs, ok := snapshots[nodeInfo.hostname]
+		if !ok {
+			return nil, errors.New("connection refused")
+		}
+		entries := []*csi.ListSnapshotsResponse_Entry{}
+		for _, snapshotID := range snapshotIDs {
+			if req.GetSnapshotId() == "" || req.GetSnapshotId() == snapshotID {
+				entries = append(entries, &csi.ListSnapshotsResponse_Entry{Snapshot: &csi.Snapshot{SnapshotId: snapshotID}})
+			}
+		}
+		return entries, nil
+	}
+	defer func() { listNodeSnapshots = origListNodeSnapshots }()
+
+	listSnapshotIDs := func(req *csi.ListSnapshotsRequest) ([]string, string) {
+		t.Helper()
+		resp, err := svc.ListSnapshots(ctx, req)
+		require.NoError(t, err)
+		snapshotIDs := []string{}
+		for _, entry := range resp.Entries {
+			snapshotIDs = append(snapshotIDs, entry.Snapshot.SnapshotId)
+		}
+		return snapshotIDs, resp.NextToken
+	}
+
+	// The snapshot IDs are prefixed by the hostname of their node.
+	snapshotIDs, nextToken := listSnapshotIDs(&csi.ListSnapshotsRequest{MaxEntries: 2})
+	require.Equal(t, []string{"host-1/snapshot-b", "host-2/snapshot-a"}, snapshotIDs)
+	require.Equal(t, "2", nextToken)
+	snapshotIDs, nextToken = listSnapshotIDs(&csi.ListSnapshotsRequest{MaxEntries: 2, StartingToken: nextToken})
+	require.Equal(t, []string{"host-2/snapshot-c"}, snapshotIDs)
+	require.Empty(t, nextToken)
+
+	snapshotIDs, _ = listSnapshotIDs(&csi.ListSnapshotsRequest{SnapshotId: "host-2/snapshot-c"})
+	require.Equal(t, []string{"host-2/snapshot-c"}, snapshotIDs)
+	snapshotIDs, _ = listSnapshotIDs(&csi.ListSnapshotsRequest{SnapshotId: "host-1/snapshot-c"})
+	require.Empty(t, snapshotIDs)
+	snapshotIDs, _ = listSnapshotIDs(&csi.ListSnapshotsRequest{SnapshotId: "snapshot-c"})
+	require.Empty(t, snapshotIDs)
+
+	// The snapshot of the node gone is deleted with the node.
+	_, err := svc.DeleteSnapshot(ctx, &csi.DeleteSnapshotRequest{SnapshotId: "host-gone/snapshot-a"})
+	require.NoError(t, err)
+	_, err = svc.DeleteSnapshot(ctx, &csi.DeleteSnapshotRequest{SnapshotId: "snapshot-a"})
+	require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err))
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	dockerref "github.com/distribution/reference"
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// The number of the tags listed by default and at most.
+	defaultTagsLimit = 100
+	maxTagsLimit     = 1000
+)
+
+// errTagsListed stops listing the tags once the page is full.
+var errTagsListed = errors.New("tags listed")
+
+// ListTagsRequest lists the tags of the model repository by pages, given by
+// the query of the list request.
+type ListTagsRequest struct {
+	// The repository of the model images, e.g. "registry.example.com/models/qwen3".
+	Repository string `query:"repository"`
+	// The tags are listed after the tag, the next of the previous page.
+	Last string `query:"last"`
+	// The max number of the tags to list, 100 by default.
+	Limit int `query:"limit"`
+}
+
+type ListTagsResponse struct {
+	Repository string   `json:"repository"`
+	Tags       []string `json:"tags"`
+	// The last tag of the page if there may be more tags, to be passed as
+	// last to list the next page.
+	Next string `json:"next,omitempty"`
+}
+
+// listRepositoryTags lists at most limit tags of the repository after the
+// last one from the registry, it returns true if there may be more tags.
+var listRepositoryTags = func(ctx context.Context, pullCfg *config.PullConfig, repository, last string, limit int) ([]string, bool, error) {
+	repo, err := newOCIRepository(pullCfg, repository)
+	if err != nil {
+		return nil, false, err
+	}
+
+	tags := []string{}
+	more := false
+	err = repo.Tags(ctx, last, func(page []string) error {
+		for _, tag := range page {
+			if len(tags) == limit {
+				more = true
+				return errTagsListed
+			}
+			tags = append(tags, tag)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errTagsListed) {
+		return nil, false, errors.Wrapf(err, "list tags of repository: %s", repository)
+	}
+
+	return tags, more, nil
+}
+
+// ListTags lists the tags of the model repository by the registry auth of the
+// node, so that the callers pick the versions of the model without the
+// registry credentials.
+func (s *Service) ListTags(ctx context.Context, req ListTagsRequest) (*ListTagsResponse, error) {
+	ctx = logger.NewContext(ctx, "ListTags", "", "")
+	repository := strings.TrimSpace(req.Repository)
+	if repository == "" {
+		return nil, status.Error(codes.InvalidArgument, "repository is required")
+	}
+	named, err := dockerref.ParseNormalizedNamed(repository)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid repository: %s", repository)
+	}
+	if _, ok := named.(dockerref.NamedTagged); ok {
+		return nil, status.Errorf(codes.InvalidArgument, "repository has a tag: %s", repository)
+	}
+	if _, ok := named.(dockerref.Digested); ok {
+		return nil, status.Errorf(codes.InvalidArgument, "repository has a digest: %s", repository)
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultTagsLimit
+	}
+	if limit < 0 || limit > maxTagsLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid limit: %d, at most %d", req.Limit, maxTagsLimit)
+	}
+
+	tags, more, err := listRepositoryTags(ctx, registryPullConfig(&s.cfg.Get().PullConfig, repository), repository, req.Last, limit)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &ListTagsResponse{Repository: repository, Tags: tags}
+	if more {
+		resp.Next = tags[len(tags)-1]
+	}
+
+	return resp, nil
+}
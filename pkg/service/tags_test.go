@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+func mockListRepositoryTags(t *testing.T, allTags []string) {
+	origListRepositoryTags := listRepositoryTags
+	t.Cleanup(func() { listRepositoryTags = origListRepositoryTags })
+	listRepositoryTags = func(ctx context.Context, pullCfg *config.PullConfig, repository, last string, limit int) ([]string, bool, error) {
+		tags := []string{}
+		for _, tag := range allTags {
+			if tag > last {
+				tags = append(tags, tag)
+			}
+		}
+		if len(tags) > limit {
+			return tags[:limit], true, nil
+		}
+		return tags, false, nil
+	}
+}
+
+func TestListTags(t *testing.T) {
+	svc, _ := newNodeService(t)
+	mockListRepositoryTags(t, []string{"v1", "v2", "v3"})
+	ctx := context.Background()
+
+	for _, req := range []ListTagsRequest{
+		{},
+		{Repository: "test/model:v1"},
+		{Repository: "test/model@sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+		{Repository: "Test/Model"},
+		{Repository: "test/model", Limit: -1},
+		{Repository: "test/model", Limit: maxTagsLimit + 1},
+	} {
+		_, err := svc.ListTags(ctx, req)
+		require.Equal(t, codes.InvalidArgument, grpcStatus.Code(err), req.Repository)
+	}
+
+	resp, err := svc.ListTags(ctx, ListTagsRequest{Repository: "test/model"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"v1", "v2", "v3"}, resp.Tags)
+	require.Empty(t, resp.Next)
+
+	// The next page is listed after the last tag of the page.
+	resp, err = svc.ListTags(ctx, ListTagsRequest{Repository: "test/model", Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{"v1", "v2"}, resp.Tags)
+	require.Equal(t, "v2", resp.Next)
+	resp, err = svc.ListTags(ctx, ListTagsRequest{Repository: "test/model", Last: resp.Next, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{"v3"}, resp.Tags)
+	require.Empty(t, resp.Next)
+}
+
+func TestDynamicServerHandler_ListTags(t *testing.T) {
+	h, _ := newHandler(t)
+	mockListRepositoryTags(t, []string{"v1", "v2"})
+
+	c, rec := newHandlerContextWithParam(t, http.MethodGet, "/?repository=test/model:v1", "", nil, nil)
+	_ = h.ListTags(c)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	c, rec = newHandlerContextWithParam(t, http.MethodGet, "/?repository=test/model&limit=1", "", nil, nil)
+	_ = h.ListTags(c)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp ListTagsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, "test/model", resp.Repository)
+	require.Equal(t, []string{"v1"}, resp.Tags)
+	require.Equal(t, "v1", resp.Next)
+}
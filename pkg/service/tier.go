@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/modelpack/modctl/pkg/backend"
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/modelpack/model-csi-driver/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+// getModelSize returns the size of the layers of the model image included by
+// the filters, which is used to place the model on the storage tiers before
+// it's pulled.
+var getModelSize = func(ctx context.Context, pullCfg *config.PullConfig, reference string, excludeModelWeights bool, excludeFilePatterns []string) (int64, error) {
+	repo, err := newOCIRepository(pullCfg, reference)
+	if err != nil {
+		return 0, err
+	}
+	_, manifest, err := fetchManifest(ctx, repo)
+	if err != nil {
+		return 0, err
+	}
+
+	size := int64(0)
+	for _, desc := range manifest.Layers {
+		if filePath := status.LayerFilepath(desc); filePath != "" {
+			if !includeLayer(ctx, backend.InspectedModelArtifactLayer{Filepath: filePath}, excludeModelWeights, excludeFilePatterns) {
+				continue
+			}
+		}
+		size += desc.Size
+	}
+
+	return size, nil
+}
+
+// selectStorageTier returns the first storage tier matching the model with
+// the room for it, or nil to keep the model in root_dir.
+func (worker *Worker) selectStorageTier(ctx context.Context, reference string, size int64, opts PullOptions) *config.StorageTier {
+	cfg := worker.cfg.Get()
+	if len(cfg.StorageTiers) == 0 {
+		return nil
+	}
+
+	modelType := opts.Type
+	if isImageModelType(modelType) {
+		modelType = ModelTypeImage
+	}
+	name, err := policyName(modelType, reference)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("failed to get name of model: %s", reference)
+	}
+	// The size is only got once a tier is limited by the size.
+	sized := size >= 0
+	for idx := range cfg.StorageTiers {
+		tier := &cfg.StorageTiers[idx]
+		if len(tier.References) > 0 && (name == "" || !matchPolicyRules(tier.References, &PolicyInput{Type: modelType, Name: name})) {
+			continue
+		}
+		if tier.MinModelSize > 0 || tier.MaxModelSize > 0 {
+			if !sized {
+				size = worker.estimateModelSize(ctx, reference, opts)
+				sized = true
+			}
+			if size < 0 || size < int64(tier.MinModelSize) || (tier.MaxModelSize > 0 && size > int64(tier.MaxModelSize)) {
+				continue
+			}
+		}
+		if size > 0 {
+			used, total, err := dirDiskUsage(tier.RootDir, tier.DiskUsageLimit)
+			if err != nil {
+				logger.WithContext(ctx).WithError(err).Warnf("failed to get disk usage of storage tier: %s", tier.Name)
+				continue
+			}
+			if total-used < size {
+				logger.WithContext(ctx).Infof("skip storage tier %s for %s: %s available", tier.Name, reference, humanizeBytes(total-used))
+				continue
+			}
+		}
+		return tier
+	}
+
+	return nil
+}
+
+// estimateModelSize returns the size of the model before it's pulled, or -1
+// if it's unknown, e.g. for the models not in image type.
+func (worker *Worker) estimateModelSize(ctx context.Context, reference string, opts PullOptions) int64 {
+	if !isImageModelType(opts.Type) {
+		return -1
+	}
+	size, err := getModelSize(ctx, &worker.cfg.Get().PullConfig, reference, opts.ExcludeModelWeights, opts.ExcludeFilePatterns)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("failed to get size of model: %s", reference)
+		return -1
+	}
+	return size
+}
+
+// placeVolumeDir moves the volume dir, or the dir of the mount of the
+// dynamic volume, onto the storage tier and links it from root_dir, so that
+// the model is pulled onto the tier. The dir holding a model already, or
+// placed already, is kept where it is.
+func (worker *Worker) placeVolumeDir(ctx context.Context, volumeDir string, tier *config.StorageTier) error {
+	info, err := os.Lstat(volumeDir)
+	if err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+	if _, err := os.Lstat(filepath.Join(volumeDir, "model")); err == nil {
+		return nil
+	}
+
+	rel, err := filepath.Rel(worker.cfg.Get().GetVolumesDir(), volumeDir)
+	if err != nil || !filepath.IsLocal(rel) {
+		return errors.Errorf("volume dir %s is not under the volumes dir", volumeDir)
+	}
+	tierDir := filepath.Join(tier.GetVolumesDir(), rel)
+	// The dir left by the volume deleted before the tier is configured.
+	if err := os.RemoveAll(tierDir); err != nil {
+		return errors.Wrapf(err, "cleanup tier dir: %s", tierDir)
+	}
+	if info != nil {
+		// The status written before the pull is moved with the dir.
+		if err := utils.CopyDir(volumeDir, tierDir); err != nil {
+			return errors.Wrapf(err, "copy volume dir to %s", tierDir)
+		}
+		if err := os.RemoveAll(volumeDir); err != nil {
+			return errors.Wrapf(err, "remove volume dir: %s", volumeDir)
+		}
+	} else if err := os.MkdirAll(tierDir, 0755); err != nil {
+		return errors.Wrapf(err, "create tier dir: %s", tierDir)
+	}
+	if err := os.MkdirAll(filepath.Dir(volumeDir), 0755); err != nil {
+		return errors.Wrapf(err, "create dir: %s", filepath.Dir(volumeDir))
+	}
+	if err := os.Symlink(tierDir, volumeDir); err != nil {
+		return errors.Wrapf(err, "link volume dir to %s", tierDir)
+	}
+	worker.sm.Invalidate(volumeDir)
+	logger.WithContext(ctx).Infof("placed volume dir on storage tier %s: %s", tier.Name, tierDir)
+
+	return nil
+}
+
+// volumeStorageTier returns the storage tier the volume dir is placed on, or
+// nil if it's in root_dir.
+func volumeStorageTier(cfg *config.RawConfig, volumeDir string) *config.StorageTier {
+	target, err := os.Readlink(volumeDir)
+	if err != nil {
+		return nil
+	}
+	for idx := range cfg.StorageTiers {
+		tier := &cfg.StorageTiers[idx]
+		if strings.HasPrefix(target, tier.GetVolumesDir()+string(filepath.Separator)) {
+			return tier
+		}
+	}
+	return nil
+}
+
+// isVolumeDirEntry returns true if the entry of the volumes dir (or the
+// models dir of the dynamic volume) is a dir, or links to the dir placed on a
+// storage tier.
+func isVolumeDirEntry(dir string, entry fs.DirEntry) bool {
+	if entry.IsDir() {
+		return true
+	}
+	if entry.Type()&fs.ModeSymlink == 0 {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(dir, entry.Name()))
+	return err == nil && info.IsDir()
+}
+
+// removeVolumeDir removes the volume dir with the dirs placed on the storage
+// tiers linked from it, i.e. the volume dir itself or the mounts of the
+// dynamic volume.
+func removeVolumeDir(volumeDir string) error {
+	info, err := os.Lstat(volumeDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "stat volume dir: %s", volumeDir)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(volumeDir)
+		if err != nil {
+			return errors.Wrapf(err, "read link: %s", volumeDir)
+		}
+		if err := os.RemoveAll(target); err != nil {
+			return errors.Wrapf(err, "remove tier dir: %s", target)
+		}
+		return os.Remove(volumeDir)
+	}
+
+	modelsDir := filepath.Join(volumeDir, "models")
+	entries, err := os.ReadDir(modelsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "read models dir: %s", modelsDir)
+	}
+	for _, entry := range entries {
+		if entry.Type()&fs.ModeSymlink != 0 {
+			if err := removeVolumeDir(filepath.Join(modelsDir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return os.RemoveAll(volumeDir)
+}
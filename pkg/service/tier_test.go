@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageTiers(t *testing.T) {
+	worker := newWorkerWithMockPuller(t, nil)
+	puller := &blockingPuller{started: make(chan struct{}), release: make(chan struct{})}
+	close(puller.release)
+	worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return puller
+	}
+	origGetModelSize := getModelSize
+	t.Cleanup(func() { getModelSize = origGetModelSize })
+	getModelSize = func(ctx context.Context, pullCfg *config.PullConfig, reference string, excludeModelWeights bool, excludeFilePatterns []string) (int64, error) {
+		if reference == "test/large:latest" {
+			return 10 << 30, nil
+		}
+		return 1 << 20, nil
+	}
+
+	ctx := context.Background()
+	cfg := worker.cfg.Get()
+	cfg.Features.DiskUsageLimit = 100 << 20
+	cfg.StorageTiers = []config.StorageTier{
+		{
+			Name:           "nvme",
+			RootDir:        t.TempDir(),
+			DiskUsageLimit: 100 << 20,
+			References:     []config.PolicyRule{{Pattern: "docker.io/test/hot"}},
+		},
+		{
+			Name:           "hdd",
+			RootDir:        t.TempDir(),
+			DiskUsageLimit: 100 << 30,
+			MinModelSize:   1 << 30,
+		},
+	}
+	nvme, hdd := &cfg.StorageTiers[0], &cfg.StorageTiers[1]
+
+	requirePlaced := func(volumeName string, tier *config.StorageTier) {
+		t.Helper()
+		volumeDir := cfg.GetVolumeDir(volumeName)
+		target, err := os.Readlink(volumeDir)
+		if tier == nil {
+			require.Error(t, err)
+		} else {
+			require.NoError(t, err)
+			require.Equal(t, filepath.Join(tier.GetVolumesDir(), volumeName), target)
+		}
+		data, err := os.ReadFile(filepath.Join(volumeDir, "model", "weights.bin"))
+		require.NoError(t, err)
+		require.Equal(t, "weights", string(data))
+		volumeStatus, err := worker.sm.Get(filepath.Join(volumeDir, "status.json"))
+		require.NoError(t, err)
+		require.Equal(t, status.StatePullSucceeded, volumeStatus.State)
+	}
+
+	// The models are placed by the reference pattern and the size.
+	require.NoError(t, worker.PullModel(ctx, true, "pvc-hot", "", "test/hot:latest", cfg.GetModelDir("pvc-hot"), PullOptions{}))
+	requirePlaced("pvc-hot", nvme)
+	require.NoError(t, worker.PullModel(ctx, true, "pvc-large", "", "test/large:latest", cfg.GetModelDir("pvc-large"), PullOptions{}))
+	requirePlaced("pvc-large", hdd)
+	require.NoError(t, worker.PullModel(ctx, true, "pvc-small", "", "test/small:latest", cfg.GetModelDir("pvc-small"), PullOptions{}))
+	requirePlaced("pvc-small", nil)
+
+	// The model of unknown size isn't matched by the size.
+	require.Nil(t, worker.selectStorageTier(ctx, "Qwen/Qwen3-0.6B", -1, PullOptions{Type: ModelTypeHuggingFace}))
+	// The tier without the room for the model is skipped.
+	require.Nil(t, worker.selectStorageTier(ctx, "test/hot:latest", 200<<20, PullOptions{}))
+
+	// The disk usage is summed over root_dir and the tiers.
+	used, total, err := diskUsage(cfg)
+	require.NoError(t, err)
+	require.Equal(t, int64(100<<20+100<<20+100<<30), total)
+	tierUsed, err := getUsedSize(hdd.RootDir)
+	require.NoError(t, err)
+	require.Greater(t, used, tierUsed)
+	volumeSize, err := getUsedSize(cfg.GetVolumeDir("pvc-large"))
+	require.NoError(t, err)
+	require.NotZero(t, volumeSize)
+
+	// The placed volumes are listed as the volume dirs.
+	volumeDirs := []string{}
+	worker.walkVolumeDirs(ctx, func(volumeDir string) bool {
+		volumeDirs = append(volumeDirs, filepath.Base(volumeDir))
+		return false
+	})
+	require.ElementsMatch(t, []string{"pvc-hot", "pvc-large", "pvc-small"}, volumeDirs)
+
+	// The dir on the tier is removed with the volume.
+	require.NoError(t, worker.DeleteModel(ctx, true, "pvc-large", ""))
+	require.NoDirExists(t, filepath.Join(hdd.GetVolumesDir(), "pvc-large"))
+	_, err = os.Lstat(cfg.GetVolumeDir("pvc-large"))
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"os"
+
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// VolumeUsage is the disk usage of the mounts of the dynamic volume.
+type VolumeUsage struct {
+	VolumeName string `json:"volume_name"`
+	// The bytes used by all the mounts of the volume.
+	UsedBytes int64        `json:"used_bytes"`
+	Mounts    []MountUsage `json:"mounts"`
+}
+
+// MountUsage is the disk usage of a dynamic mount.
+type MountUsage struct {
+	MountID   string `json:"mount_id"`
+	Reference string `json:"reference"`
+	State     string `json:"state"`
+	// The size of the model files once the model is pulled, or the bytes
+	// downloaded so far while it's pulled.
+	UsedBytes int64 `json:"used_bytes"`
+}
+
+// mountUsedBytes returns the bytes used by the mount by the size tracked by
+// its status instead of walking the model files.
+func mountUsedBytes(mount *modelStatus.Status) int64 {
+	if mount.SizeInBytes > 0 {
+		return mount.SizeInBytes
+	}
+	return mount.Progress.DownloadedBytes
+}
+
+// GetVolumeUsage returns the disk usage of each mount of the dynamic volume
+// and the total of the volume.
+func (s *Service) GetVolumeUsage(ctx context.Context, volumeName string) (*VolumeUsage, error) {
+	mounts, err := s.ListDynamicVolumes(ctx, volumeName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, status.Errorf(codes.NotFound, "volume_name %s is not found", volumeName)
+		}
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		return nil, status.Error(codes.Internal, errors.Wrap(err, "list mounts").Error())
+	}
+
+	usage := &VolumeUsage{VolumeName: volumeName, Mounts: []MountUsage{}}
+	for i := range mounts {
+		mount := MountUsage{
+			MountID:   mounts[i].MountID,
+			Reference: mounts[i].Reference,
+			State:     mounts[i].State,
+			UsedBytes: mountUsedBytes(&mounts[i]),
+		}
+		usage.UsedBytes += mount.UsedBytes
+		usage.Mounts = append(usage.Mounts, mount)
+	}
+	return usage, nil
+}
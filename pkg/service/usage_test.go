@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+func setMountStatus(t *testing.T, svc *Service, volumeName, mountID string, update func(*modelStatus.Status)) {
+	t.Helper()
+	statusPath := filepath.Join(svc.cfg.Get().GetMountIDDirForDynamic(volumeName, mountID), "status.json")
+	mount, err := svc.sm.Get(statusPath)
+	require.NoError(t, err)
+	update(mount)
+	_, err = svc.sm.Set(statusPath, *mount)
+	require.NoError(t, err)
+}
+
+func TestGetVolumeUsage(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+
+	_, err := svc.GetVolumeUsage(ctx, "csi-vol")
+	require.Equal(t, codes.NotFound, grpcStatus.Code(err))
+
+	newDynamicMount(t, svc, "csi-vol", "m1")
+	setMountStatus(t, svc, "csi-vol", "m1", func(mount *modelStatus.Status) {
+		mount.SizeInBytes = 1000
+		mount.Progress.DownloadedBytes = 800
+	})
+	// The mount being pulled uses the bytes downloaded so far.
+	newDynamicMount(t, svc, "csi-vol", "m2")
+	setMountStatus(t, svc, "csi-vol", "m2", func(mount *modelStatus.Status) {
+		mount.State = modelStatus.StatePullRunning
+		mount.Progress.DownloadedBytes = 300
+	})
+
+	usage, err := svc.GetVolumeUsage(ctx, "csi-vol")
+	require.NoError(t, err)
+	require.Equal(t, "csi-vol", usage.VolumeName)
+	require.Equal(t, int64(1300), usage.UsedBytes)
+	require.Equal(t, []MountUsage{
+		{MountID: "m1", Reference: "test/model:latest", State: modelStatus.StatePullSucceeded, UsedBytes: 1000},
+		{MountID: "m2", Reference: "test/model:latest", State: modelStatus.StatePullRunning, UsedBytes: 300},
+	}, usage.Mounts)
+}
+
+func TestDynamicServerHandler_GetVolumeUsage(t *testing.T) {
+	h, svc := newHandler(t)
+
+	c, rec := newHandlerContextWithParam(t, http.MethodGet, "/", "",
+		[]string{"volume_name"}, []string{"bad/vol"})
+	_ = h.GetVolumeUsage(c)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	c, rec = newHandlerContextWithParam(t, http.MethodGet, "/", "",
+		[]string{"volume_name"}, []string{"my-volume"})
+	_ = h.GetVolumeUsage(c)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	newDynamicMount(t, svc, "my-volume", "m1")
+	c, rec = newHandlerContextWithParam(t, http.MethodGet, "/", "",
+		[]string{"volume_name"}, []string{"my-volume"})
+	_ = h.GetVolumeUsage(c)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var usage VolumeUsage
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &usage))
+	require.Len(t, usage.Mounts, 1)
+	require.Equal(t, "m1", usage.Mounts[0].MountID)
+}
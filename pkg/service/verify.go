@@ -0,0 +1,135 @@
+package service
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/modelpack/modctl/pkg/backend"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+// The annotation of model-spec holding the metadata of the file archived in
+// the layer, e.g. the size of the extracted file.
+const annotationFileMetadata = "org.cncf.model.file.metadata+json"
+
+type fileMetadata struct {
+	Size     int64 `json:"size"`
+	Typeflag byte  `json:"typeflag"`
+}
+
+// isRawLayer checks if the layer is the file itself instead of a tar archive
+// of the file, e.g. application/vnd.cncf.model.weight.v1.raw.
+func isRawLayer(desc ocispec.Descriptor) bool {
+	return strings.HasSuffix(desc.MediaType, ".raw")
+}
+
+// verifyLayerFile verifies the file extracted from the layer, the digest and
+// size of the raw layer are those of the file, and for the archived layer,
+// only the size recorded in the file metadata is verified.
+func verifyLayerFile(desc ocispec.Descriptor, path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return errors.Wrap(err, "stat file")
+	}
+
+	if !isRawLayer(desc) {
+		metadataJSON := desc.Annotations[annotationFileMetadata]
+		if metadataJSON == "" {
+			return nil
+		}
+		metadata := fileMetadata{}
+		if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+			return errors.Wrap(err, "unmarshal file metadata")
+		}
+		// The size is verified only for the regular file.
+		if metadata.Typeflag == 0 || metadata.Typeflag == tar.TypeReg {
+			if !info.Mode().IsRegular() || info.Size() != metadata.Size {
+				return errors.Errorf("size mismatch: expected %d, got %d", metadata.Size, info.Size())
+			}
+		}
+		return nil
+	}
+
+	if info.Size() != desc.Size {
+		return errors.Errorf("size mismatch: expected %d, got %d", desc.Size, info.Size())
+	}
+	if err := desc.Digest.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid layer digest: %s", desc.Digest)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "open file")
+	}
+	defer func() { _ = file.Close() }()
+	digester := desc.Digest.Algorithm().Digester()
+	if _, err := io.Copy(digester.Hash(), file); err != nil {
+		return errors.Wrap(err, "read file")
+	}
+	if actual := digester.Digest(); actual != desc.Digest {
+		return errors.Errorf("digest mismatch: expected %s, got %s", desc.Digest, actual)
+	}
+
+	return nil
+}
+
+// verifyModelFiles verifies the model files pulled into the model dir against
+// the layers of the manifest, all the mismatched files are reported in the
+// error.
+func verifyModelFiles(ctx context.Context, manifest *ocispec.Manifest, targetDir string, concurrency uint, excludeModelWeights bool, excludeFilePatterns []string) error {
+	var mutex sync.Mutex
+	mismatches := []string{}
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	if concurrency > 0 {
+		eg.SetLimit(int(concurrency))
+	}
+	for _, desc := range manifest.Layers {
+		filePath := status.LayerFilepath(desc)
+		if filePath == "" {
+			continue
+		}
+		layer := backend.InspectedModelArtifactLayer{Filepath: filePath}
+		if !includeLayer(ctx, layer, excludeModelWeights, excludeFilePatterns) {
+			continue
+		}
+		eg.Go(func() error {
+			if err := egCtx.Err(); err != nil {
+				return err
+			}
+			var err error
+			if filepath.IsLocal(filePath) {
+				err = verifyLayerFile(desc, filepath.Join(targetDir, filePath))
+			} else {
+				err = errors.New("invalid file path")
+			}
+			if err != nil {
+				mutex.Lock()
+				defer mutex.Unlock()
+				mismatches = append(mismatches, filePath+": "+err.Error())
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	if len(mismatches) > 0 {
+		sort.Strings(mismatches)
+		logger.WithContext(ctx).Errorf("model files mismatch the manifest: %s", strings.Join(mismatches, "; "))
+		return errors.Errorf("verify model files: %s", strings.Join(mismatches, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	modelspec "github.com/modelpack/model-spec/specs-go/v1"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyModelFiles(t *testing.T) {
+	weights := []byte("weights")
+	configData := []byte(`{"model_type":"qwen3"}`)
+	manifest := &ocispec.Manifest{
+		Layers: []ocispec.Descriptor{
+			{
+				MediaType:   modelspec.MediaTypeModelWeightRaw,
+				Digest:      digest.FromBytes(weights),
+				Size:        int64(len(weights)),
+				Annotations: map[string]string{modelspec.AnnotationFilepath: "model.safetensors"},
+			},
+			{
+				MediaType: "application/vnd.cncf.model.weight.config.v1.tar",
+				Digest:    digest.FromString("tar"),
+				Size:      1024,
+				Annotations: map[string]string{
+					modelspec.AnnotationFilepath: "config.json",
+					annotationFileMetadata:       `{"name":"config.json","size":22,"typeflag":48}`,
+				},
+			},
+			{
+				MediaType:   "application/vnd.cncf.model.doc.v1.tar",
+				Digest:      digest.FromString("doc"),
+				Size:        1024,
+				Annotations: map[string]string{modelspec.AnnotationFilepath: "README.md"},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	targetDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(targetDir, "model.safetensors"), weights, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(targetDir, "config.json"), configData, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(targetDir, "README.md"), []byte("# model"), 0644))
+	require.NoError(t, verifyModelFiles(ctx, manifest, targetDir, 2, false, nil))
+
+	// The excluded files aren't verified.
+	require.NoError(t, os.Remove(filepath.Join(targetDir, "model.safetensors")))
+	require.NoError(t, verifyModelFiles(ctx, manifest, targetDir, 2, true, nil))
+	require.ErrorContains(t, verifyModelFiles(ctx, manifest, targetDir, 2, false, nil), "model.safetensors: stat file")
+
+	// The corrupted files are reported.
+	require.NoError(t, os.WriteFile(filepath.Join(targetDir, "model.safetensors"), []byte("weighty"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(targetDir, "config.json"), []byte("{}"), 0644))
+	err := verifyModelFiles(ctx, manifest, targetDir, 2, false, nil)
+	require.ErrorContains(t, err, "config.json: size mismatch: expected 22, got 2")
+	require.ErrorContains(t, err, "model.safetensors: digest mismatch: expected "+digest.FromBytes(weights).String())
+	require.NotContains(t, err.Error(), "README.md")
+}
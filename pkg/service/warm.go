@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+	modelStatus "github.com/modelpack/model-csi-driver/pkg/status"
+)
+
+// The interval of the warm models reconciliation if it's not configured.
+var WarmModelsReconcileInterval = 5 * time.Minute
+
+// reconcileWarmModelsLoop keeps the warm models of the config pulled on the
+// node, the list and the interval are picked up on the config reload.
+func (s *Service) reconcileWarmModelsLoop() {
+	for {
+		s.reconcileWarmModels(context.Background())
+		interval := time.Duration(s.cfg.Get().WarmModels.ReconcileIntervalInSeconds) * time.Second
+		if interval == 0 {
+			interval = WarmModelsReconcileInterval
+		}
+		time.Sleep(interval)
+	}
+}
+
+// reconcileWarmModels prefetches each warm model which isn't pulled on the
+// node, e.g. it's never pulled, the pull failed, or the prefetched model is
+// removed. The warm models being pulled or pulled are left as is.
+func (s *Service) reconcileWarmModels(ctx context.Context) {
+	models := s.cfg.Get().WarmModels.Models
+	if len(models) == 0 {
+		return
+	}
+	ctx = logger.NewContext(ctx, "ReconcileWarmModels", "", "")
+
+	for _, model := range models {
+		modelType := strings.TrimSpace(model.Type)
+		if modelType == "" {
+			modelType = ModelTypeImage
+		}
+		prefetchStatus, err := s.prefetch(ctx, PrefetchRequest{
+			Type:      modelType,
+			Reference: model.Reference,
+			Platform:  model.Platform,
+		})
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("failed to prefetch warm model: %s", model.Reference)
+			continue
+		}
+		if prefetchStatus.State != modelStatus.StatePullSucceeded {
+			logger.WithContext(ctx).Infof("warm model %s is %s: %s", model.Reference, prefetchStatus.State, prefetchStatus.VolumeName)
+		}
+	}
+}
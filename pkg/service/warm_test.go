@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/status"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcileWarmModels(t *testing.T) {
+	svc, _ := newNodeService(t)
+	ctx := context.Background()
+	mockResolveDigest(t, digest.FromString("manifest"))
+	puller := &countingPuller{}
+	svc.worker.newPuller = func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller {
+		return puller
+	}
+	svc.cfg.Get().WarmModels.Models = []config.WarmModel{{Reference: "test/model:latest"}}
+
+	waitPrefetched := func() string {
+		var name string
+		require.Eventually(t, func() bool {
+			prefetches, err := svc.ListPrefetches(ctx)
+			if err != nil || len(prefetches) != 1 {
+				return false
+			}
+			name = prefetches[0].VolumeName
+			return prefetches[0].State == status.StatePullSucceeded
+		}, 5*time.Second, 10*time.Millisecond)
+		return name
+	}
+
+	svc.reconcileWarmModels(ctx)
+	name := waitPrefetched()
+	require.Equal(t, int32(1), puller.pulls.Load())
+
+	// The pulled warm model is left as is.
+	svc.reconcileWarmModels(ctx)
+	require.Equal(t, name, waitPrefetched())
+	require.Equal(t, int32(1), puller.pulls.Load())
+
+	// The warm model is pulled again once it's removed.
+	require.NoError(t, svc.CancelPrefetch(ctx, name))
+	require.NoDirExists(t, filepath.Join(svc.cfg.Get().GetVolumesDir(), name))
+	svc.reconcileWarmModels(ctx)
+	require.Equal(t, name, waitPrefetched())
+	require.Equal(t, int32(2), puller.pulls.Load())
+}
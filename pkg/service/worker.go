@@ -2,18 +2,28 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
-	"github.com/containerd/containerd/pkg/kmutex"
+	dockerref "github.com/distribution/reference"
 	"github.com/modelpack/model-csi-driver/pkg/config"
+	"github.com/modelpack/model-csi-driver/pkg/fault"
 	"github.com/modelpack/model-csi-driver/pkg/logger"
 	"github.com/modelpack/model-csi-driver/pkg/metrics"
 	"github.com/modelpack/model-csi-driver/pkg/status"
 	"github.com/modelpack/model-csi-driver/pkg/utils"
+	"github.com/opencontainers/go-digest"
 	"github.com/pkg/errors"
 	"golang.org/x/sync/singleflight"
 )
@@ -50,13 +60,72 @@ func (cm *ContextMap) Get(key string) *context.CancelFunc {
 	return cm.cancelFuncs[key]
 }
 
+// PullOptions are the options of pulling a model, given by the parameters
+// of the volume or the mount request.
+type PullOptions struct {
+	// The model type, e.g. "image" (default), "huggingface" or "s3".
+	Type                string
+	CheckDiskQuota      bool
+	ExcludeModelWeights bool
+	ExcludeFilePatterns []string
+	// The CSI secrets of the volume, e.g. the credentials of S3 or the registry.
+	Secrets map[string]string
+	// The digest the tag of the image reference is resolved to on
+	// CreateVolume, the model is pulled by the digest, so that it never
+	// changes even if the tag moves.
+	Digest string
+	// The platform of the manifest the digest is selected for from the image
+	// index, empty if the reference isn't an index.
+	Platform string
+	// The priority of the pull in the node-wide pull queue, e.g. higher for
+	// the inference mounts than the background prefetch, 0 by default.
+	Priority int
+	// Pull the model weights in the background once the other files (e.g.
+	// config and tokenizer) are pulled, so that the volume can be mounted
+	// before the weights are ready, see StateWeightsPulling.
+	BackgroundWeights bool
+	// The adapters (e.g. LoRA) pulled beside the base model of the composite
+	// mount, see pullAdapters.
+	Adapters []status.Adapter
+	// The timeout of the whole pull, pull_timeout_in_seconds of the pull
+	// config by default.
+	Timeout time.Duration
+	// Keep the model on the node as the cache once the volume is deleted,
+	// see retainModel.
+	RetainCache bool
+	// Pull the model instead of cloning it from another volume, e.g. to
+	// repair the corrupted files the clones may share, see repairModel.
+	NoReuse bool
+	// Replace the model of the dynamic mount pulled before, even by another
+	// reference, instead of rejecting it, see replaceModel.
+	Replace bool
+	// The labels of the dynamic mount, the labels set before are kept if
+	// it's nil.
+	Labels map[string]string
+}
+
+type pullDeadlineKey struct{}
+
 type Worker struct {
-	cfg        *config.Config
-	newPuller  func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller
-	sm         *status.StatusManager
-	inflight   singleflight.Group
+	cfg       *config.Config
+	newPuller func(ctx context.Context, pullCfg *config.PullConfig, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) Puller
+	sm        *status.StatusManager
+	inflight  singleflight.Group
+	// The pulls keyed by the normalized reference, shared by the volumes
+	// requesting the same model concurrently.
+	pulls      singleflight.Group
 	contextMap *ContextMap
-	kmutex     kmutex.KeyedLocker
+	kmutex     *keyedLocker
+	blobStore  *BlobStore
+	// Admits the model pulls on the node with bounded parallelism.
+	queue *PullQueue
+	// Serializes the updates of the pinned references.
+	pinMutex sync.Mutex
+	// The disk quota reserved by the in-flight pulls.
+	reservations *QuotaReservations
+	// The context keys of the pulls canceled by CancelPull, whose models
+	// are kept instead of being deleted.
+	canceledPulls sync.Map
 }
 
 func NewWorker(cfg *config.Config, sm *status.StatusManager) (*Worker, error) {
@@ -66,7 +135,12 @@ func NewWorker(cfg *config.Config, sm *status.StatusManager) (*Worker, error) {
 		sm:         sm,
 		inflight:   singleflight.Group{},
 		contextMap: NewContextMap(),
-		kmutex:     kmutex.New(),
+		kmutex:     newKeyedLocker(),
+		blobStore:  NewBlobStore(cfg),
+		queue: NewPullQueue(func() int {
+			return int(cfg.Get().PullConfig.MaxConcurrentPulls)
+		}),
+		reservations: NewQuotaReservations(),
 	}, nil
 }
 
@@ -87,11 +161,13 @@ func (worker *Worker) deleteModel(ctx context.Context, isStaticVolume bool, volu
 		if !isStaticVolume {
 			volumeDir = worker.cfg.Get().GetMountIDDirForDynamic(volumeName, mountID)
 		}
+		worker.retainModel(ctx, volumeDir)
+		releaseProjectQuota(ctx, worker.cfg.Get(), volumeDir)
 		// Retry as much as possible to ensure that the "directory not empty"
 		// error does not occur, such as when other processes are still writing
 		// files to the directory.
 		if err := utils.WithRetry(ctx, func() error {
-			if err := os.RemoveAll(volumeDir); err != nil {
+			if err := removeVolumeDir(volumeDir); err != nil {
 				return errors.Wrapf(err, "remove volume dir: %s", volumeDir)
 			}
 			return nil
@@ -99,10 +175,17 @@ func (worker *Worker) deleteModel(ctx context.Context, isStaticVolume bool, volu
 			return nil, errors.Wrapf(err, "retry remove volume dir: %s", volumeDir)
 		}
 		logger.WithContext(ctx).Infof("removed volume dir: %s", volumeDir)
+		worker.sm.Invalidate(volumeDir)
 
 		statusPath := filepath.Join(volumeDir, "status.json")
 		worker.sm.HookManager.Delete(statusPath)
 
+		// The blob store may be disabled after ingesting, always collect the
+		// blobs released by the volume.
+		if err := worker.blobStore.GC(ctx); err != nil {
+			logger.WithContext(ctx).WithError(err).Warnf("failed to collect unused blobs")
+		}
+
 		return nil, nil
 	})
 
@@ -124,17 +207,56 @@ func (worker *Worker) PullModel(
 	volumeName, mountID,
 	reference,
 	modelDir string,
-	checkDiskQuota bool,
-	excludeModelWeights bool,
-	excludeFilePatterns []string,
+	opts PullOptions,
 ) error {
 	start := time.Now()
 
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = time.Duration(worker.cfg.Get().PullConfig.PullTimeoutInSeconds) * time.Second
+	}
+	// The model is cleaned up by the context of the request instead of the
+	// pull, which may be past the deadline.
+	pullCtx := withRegistrySecrets(ctx, opts.Secrets)
+	if timeout > 0 {
+		// The deadline is kept for the weights pulled in the background,
+		// which outlive the context of the request.
+		deadline := start.Add(timeout)
+		var cancel context.CancelFunc
+		pullCtx, cancel = context.WithDeadline(context.WithValue(pullCtx, pullDeadlineKey{}, deadline), deadline)
+		defer cancel()
+	}
+
 	statusPath := filepath.Join(filepath.Dir(modelDir), "status.json")
-	err := worker.pullModel(ctx, statusPath, volumeName, mountID, reference, modelDir, checkDiskQuota, excludeModelWeights, excludeFilePatterns)
+	contextKey := fmt.Sprintf("%s/%s", volumeName, mountID)
+	// The pull replacing the model cancels the pull in progress, the mark of
+	// which is left to the pull canceled unless the replacement fails.
+	if !opts.Replace {
+		worker.canceledPulls.Delete(contextKey)
+	}
+	err := worker.pullModel(pullCtx, statusPath, volumeName, mountID, reference, modelDir, opts)
 	metrics.NodeOpObserve("pull_image", start, err)
+	canceled := false
+	if err != nil || !opts.Replace {
+		_, canceled = worker.canceledPulls.LoadAndDelete(contextKey)
+	}
 
 	if err != nil && !errors.Is(err, ErrConflict) {
+		// The status of the failed pull is kept by the error, as the model
+		// dir is deleted below.
+		if volumeStatus, err2 := worker.sm.Get(statusPath); err2 == nil {
+			err = &PullError{Status: volumeStatus, err: err}
+		}
+		// Keep the mount canceled by the request for the audit.
+		if canceled && errors.Is(err, context.Canceled) {
+			logger.WithContext(ctx).WithError(err).Infof("keep the canceled pull in %s", modelDir)
+			return err
+		}
+		// Keep the pulled layers for the retried request to resume the pull.
+		if isRetryablePullError(err) && canResumePull(modelDir, pullStateKey(pinReference(reference, opts.Digest), opts)) {
+			logger.WithContext(ctx).WithError(err).Warnf("keep the interrupted pull in %s to resume", modelDir)
+			return err
+		}
 		if err2 := worker.DeleteModel(ctx, isStaticVolume, volumeName, mountID); err2 != nil {
 			return errors.Wrapf(err, "delete model: %v", err2)
 		}
@@ -143,14 +265,62 @@ func (worker *Worker) PullModel(
 	return err
 }
 
-func (worker *Worker) pullModel(ctx context.Context, statusPath, volumeName, mountID, reference, modelDir string, checkDiskQuota, excludeModelWeights bool, excludeFilePatterns []string) error {
+func (worker *Worker) pullModel(ctx context.Context, statusPath, volumeName, mountID, reference, modelDir string, opts PullOptions) error {
+	pullReference := pinReference(reference, opts.Digest)
+	key := pullKey(pullReference, opts)
+	// The options of the pull of the base model, before the weights pulled
+	// in the background.
+	pullOpts := opts
+	pullOpts.Adapters = nil
+	backgroundWeights := opts.BackgroundWeights && !opts.ExcludeModelWeights
+	// The files excluded by the filters and the size of the model files, set
+	// once the pull succeeded.
+	var excludedFiles []string
+	var sizeInBytes int64
+	// The time the pull is started and done at, and the error of the failed
+	// pull.
+	var pullStartedAt, pullFinishedAt *time.Time
+	var pullError *status.ErrorDetail
 	setStatus := func(state status.State) (*status.Status, error) {
-		status, err := worker.sm.Set(statusPath, status.Status{
-			VolumeName: volumeName,
-			MountID:    mountID,
-			Reference:  reference,
-			State:      state,
-		})
+		now := time.Now()
+		if state == status.StatePullRunning && pullStartedAt == nil {
+			pullStartedAt = &now
+		}
+		if isPullDone(state) {
+			pullFinishedAt = &now
+		}
+		newStatus := status.Status{
+			VolumeName:          volumeName,
+			MountID:             mountID,
+			Reference:           reference,
+			Digest:              opts.Digest,
+			Platform:            opts.Platform,
+			State:               state,
+			PullKey:             key,
+			ExcludeModelWeights: opts.ExcludeModelWeights,
+			ExcludeFilePatterns: opts.ExcludeFilePatterns,
+			ExcludedFiles:       excludedFiles,
+			Adapters:            opts.Adapters,
+			SizeInBytes:         sizeInBytes,
+			RetainCache:         opts.RetainCache,
+			CreatedAt:           &now,
+			PullStartedAt:       pullStartedAt,
+			PullFinishedAt:      pullFinishedAt,
+			Error:               pullError,
+			Labels:              opts.Labels,
+		}
+		// Keep the mutable parameters modified before, e.g. on retried CreateVolume.
+		if oldStatus, err := worker.sm.Get(statusPath); err == nil {
+			newStatus.ReadOnly = oldStatus.ReadOnly
+			newStatus.History = oldStatus.History
+			if oldStatus.CreatedAt != nil {
+				newStatus.CreatedAt = oldStatus.CreatedAt
+			}
+			if newStatus.Labels == nil {
+				newStatus.Labels = oldStatus.Labels
+			}
+		}
+		status, err := worker.sm.Set(statusPath, newStatus)
 		if err != nil {
 			return nil, errors.Wrapf(err, "set model status")
 		}
@@ -159,6 +329,15 @@ func (worker *Worker) pullModel(ctx context.Context, statusPath, volumeName, mou
 
 	inflightKey := fmt.Sprintf("pull-%s/%s", volumeName, mountID)
 	contextKey := fmt.Sprintf("%s/%s", volumeName, mountID)
+	if opts.Replace {
+		// The pull replacing the model doesn't join the pull in progress,
+		// which is canceled and kept for the replacement instead of being
+		// deleted.
+		inflightKey = fmt.Sprintf("replace-%s/%s", volumeName, mountID)
+		if worker.cancelPull(volumeName, mountID) {
+			logger.WithContext(ctx).Infof("canceled pull to replace the model")
+		}
+	}
 	_, err, shared := worker.inflight.Do(inflightKey, func() (interface{}, error) {
 		if err := worker.kmutex.Lock(context.Background(), contextKey); err != nil {
 			return nil, errors.Wrapf(err, "lock context key: %s", contextKey)
@@ -168,10 +347,18 @@ func (worker *Worker) pullModel(ctx context.Context, statusPath, volumeName, mou
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithCancel(ctx)
 		worker.contextMap.Set(contextKey, &cancel)
-		defer worker.contextMap.Set(contextKey, nil)
-
-		// re-mount with different reference is not supported.
-		if mountID != "" {
+		// The background pull of the weights takes over the cancel function,
+		// so that it's canceled on deleting the volume.
+		var weightsCancel *context.CancelFunc
+		defer func() { worker.contextMap.Set(contextKey, weightsCancel) }()
+
+		// re-mount with different reference is not supported unless the
+		// model is replaced.
+		if mountID != "" && opts.Replace {
+			if err := worker.replaceModel(ctx, statusPath); err != nil {
+				return nil, err
+			}
+		} else if mountID != "" {
 			if status, _ := worker.sm.Get(statusPath); status != nil {
 				if status.Reference != "" && status.Reference != reference {
 					return nil, errors.Wrapf(ErrConflict, "mount_id is re-used for different reference, origin: %s, want: %s", status.Reference, reference)
@@ -179,9 +366,35 @@ func (worker *Worker) pullModel(ctx context.Context, statusPath, volumeName, mou
 			}
 		}
 
+		// The model is pulled onto the storage tier matching it, the volume
+		// dir pulled already stays where it is.
+		if tier := worker.selectStorageTier(ctx, reference, -1, opts); tier != nil {
+			if err := worker.placeVolumeDir(ctx, filepath.Dir(modelDir), tier); err != nil {
+				logger.WithContext(ctx).WithError(err).Warnf("failed to place model on storage tier %s", tier.Name)
+			}
+		}
+
 		// For hardlinked model files, we need to ensure the model
-		// directory is empty before pulling.
-		if err := os.RemoveAll(modelDir); err != nil {
+		// directory is empty before pulling, unless the model dir holds
+		// the layers of an interrupted pull to resume.
+		resuming := !opts.Replace && canResumePull(modelDir, pullStateKey(pullReference, opts))
+		if resuming {
+			logger.WithContext(ctx).Infof("found interrupted pull in %s, resuming it", modelDir)
+		} else if !opts.Replace && worker.canPullIncrementally(ctx, key, modelDir, opts) {
+			// The model pulled before, e.g. another tag of the model, is
+			// pulled again by the changed layers, the same as resumed.
+			seeded, err := seedPullState(ctx, modelDir, pullStateKey(pullReference, opts))
+			if err != nil {
+				logger.WithContext(ctx).WithError(err).Warnf("failed to pull model incrementally in %s, pull it again", modelDir)
+			} else if seeded {
+				logger.WithContext(ctx).Infof("found model pulled before in %s, pulling the changed layers", modelDir)
+				resuming = true
+			}
+		}
+		if resuming {
+			// The interrupted pull of the weights is resumed in place.
+			backgroundWeights = false
+		} else if err := os.RemoveAll(modelDir); err != nil {
 			return nil, errors.Wrapf(err, "cleanup model directory before pull: %s", modelDir)
 		}
 
@@ -189,16 +402,55 @@ func (worker *Worker) pullModel(ctx context.Context, statusPath, volumeName, mou
 		worker.sm.HookManager.Set(statusPath, hook)
 
 		var diskQuotaChecker *DiskQuotaChecker
-		checkDiskQuota := worker.cfg.Get().Features.CheckDiskQuota && checkDiskQuota && !worker.isModelExisted(ctx, reference)
+		checkDiskQuota := worker.cfg.Get().Features.CheckDiskQuota && opts.CheckDiskQuota && worker.findPulledModel(ctx, key, modelDir) == ""
 		if checkDiskQuota {
-			diskQuotaChecker = NewDiskQuotaChecker(worker.cfg)
+			// The size of the adapters and the weights pulled in the background
+			// isn't known by the check, so the volume isn't limited by the
+			// project quota.
+			projectQuota := len(opts.Adapters) == 0 && !backgroundWeights
+			diskQuotaChecker = worker.newDiskQuotaChecker(filepath.Dir(modelDir), projectQuota)
+			defer diskQuotaChecker.Release()
 		}
-		puller := worker.newPuller(ctx, &worker.cfg.Get().PullConfig, hook, diskQuotaChecker)
-		_, err := setStatus(status.StatePullRunning)
+		setState := func(state status.State) error {
+			_, err := setStatus(state)
+			return err
+		}
+		puller, blobPuller, err := worker.newModelPuller(ctx, opts, hook, diskQuotaChecker, setState)
+		if err != nil {
+			return nil, err
+		}
+		_, err = setStatus(status.StatePullRunning)
 		if err != nil {
 			return nil, errors.Wrapf(err, "set status before pull model")
 		}
-		if err := puller.Pull(ctx, reference, modelDir, excludeModelWeights, excludeFilePatterns); err != nil {
+		var sharedFrom string
+		var sharedExcludedFiles []string
+		reused := false
+		if !resuming && !opts.NoReuse {
+			sharedFrom, sharedExcludedFiles, err = worker.reuseModel(ctx, key, modelDir)
+			if sharedFrom != "" {
+				// The whole model is cloned from another volume.
+				reused = true
+				backgroundWeights = false
+			}
+		}
+		if backgroundWeights {
+			pullOpts.ExcludeModelWeights = true
+		}
+		if err == nil && sharedFrom == "" {
+			sharedFrom, err = worker.pullShared(ctx, puller, hook, pullReference, modelDir, pullOpts)
+		}
+		if err == nil && !reused {
+			err = worker.pullAdapters(ctx, hook, modelDir, opts, setState)
+		}
+		if err == nil {
+			worker.writeModelMetadata(ctx, pullReference, modelDir, opts, reused)
+		}
+		if err == nil && !backgroundWeights {
+			err = worker.writeModelChecksums(ctx, modelDir, reused)
+		}
+		if err != nil {
+			pullError = &status.ErrorDetail{Reason: pullErrorReason(err), Message: err.Error()}
 			if errors.Is(err, context.Canceled) {
 				err = errors.Wrapf(err, "pull model canceled")
 				if _, err2 := setStatus(status.StatePullCanceled); err2 != nil {
@@ -217,9 +469,44 @@ func (worker *Worker) pullModel(ctx context.Context, statusPath, volumeName, mou
 			}
 			return nil, err
 		}
-		_, err = setStatus(status.StatePullSucceeded)
-		if err != nil {
-			return nil, errors.Wrapf(err, "set status after pull model succeeded")
+		if backgroundWeights {
+			// The volume can be mounted once the other files are pulled, the
+			// excluded files are set once the weights are pulled.
+			_, err = setStatus(status.StateWeightsPulling)
+			if err != nil {
+				return nil, errors.Wrapf(err, "set status after pull model files succeeded")
+			}
+			var weightsCtx context.Context
+			var cancelWeights context.CancelFunc
+			if deadline, ok := ctx.Value(pullDeadlineKey{}).(time.Time); ok {
+				weightsCtx, cancelWeights = context.WithDeadline(context.WithoutCancel(ctx), deadline)
+			} else {
+				weightsCtx, cancelWeights = context.WithCancel(context.WithoutCancel(ctx))
+			}
+			weightsCancel = &cancelWeights
+			go worker.pullWeights(weightsCtx, cancelWeights, statusPath, contextKey, pullReference, modelDir, opts, hook)
+		} else {
+			excludedFiles = hook.GetExcludedFiles()
+			if sharedFrom != "" && len(excludedFiles) == 0 {
+				// The model is cloned from another volume without being pulled.
+				excludedFiles = sharedExcludedFiles
+			}
+			sizeInBytes = modelDirSize(ctx, modelDir)
+			_, err = setStatus(status.StatePullSucceeded)
+			if err != nil {
+				return nil, errors.Wrapf(err, "set status after pull model succeeded")
+			}
+		}
+		if sharedFrom != "" {
+			size, err := getUsedSize(modelDir)
+			if err != nil {
+				logger.WithContext(ctx).WithError(err).Warnf("get used size: %s", modelDir)
+			}
+			metrics.NodePullCacheObserve(true, size)
+		} else if blobPuller != nil && blobPuller.reusedSize > 0 {
+			metrics.NodePullCacheObserve(true, blobPuller.reusedSize)
+		} else {
+			metrics.NodePullCacheObserve(false, hook.GetPulledSize())
 		}
 		return nil, nil
 	})
@@ -232,38 +519,454 @@ func (worker *Worker) pullModel(ctx context.Context, statusPath, volumeName, mou
 	return nil
 }
 
-func (worker *Worker) isModelExisted(ctx context.Context, reference string) bool {
-	volumesDir := worker.cfg.Get().GetVolumesDir()
-	volumeDirs, err := os.ReadDir(volumesDir)
+// pullWeights pulls the model weights excluded by the pull of the other files
+// in the background, the files pulled before are kept in place as the volume
+// may be mounted already, the volume is in WEIGHTS_PULLING state until the
+// weights are pulled.
+func (worker *Worker) pullWeights(ctx context.Context, cancel context.CancelFunc, statusPath, contextKey, reference, modelDir string, opts PullOptions, pulledHook *status.Hook) {
+	defer cancel()
+	start := time.Now()
+
+	if err := worker.kmutex.Lock(context.Background(), contextKey); err != nil {
+		logger.WithContext(ctx).WithError(err).Errorf("lock context key: %s", contextKey)
+		return
+	}
+	defer worker.kmutex.Unlock(contextKey)
+	if errors.Is(ctx.Err(), context.Canceled) {
+		// The volume is deleted before the weights are pulled.
+		return
+	}
+	defer worker.contextMap.Set(contextKey, nil)
+
+	hook := status.NewHook(ctx)
+	worker.sm.HookManager.Set(statusPath, hook)
+	setState := func(state status.State, excludedFiles []string, pullErr error) error {
+		volumeStatus, err := worker.sm.Get(statusPath)
+		if err != nil {
+			return errors.Wrap(err, "get model status")
+		}
+		if state == status.StatePullSucceeded {
+			volumeStatus.SizeInBytes = modelDirSize(ctx, modelDir)
+			if len(volumeStatus.Targets) > 0 {
+				state = status.StateMounted
+			}
+		}
+		volumeStatus.State = state
+		volumeStatus.ExcludedFiles = excludedFiles
+		finishedAt := time.Now()
+		volumeStatus.PullFinishedAt = &finishedAt
+		if pullErr != nil {
+			volumeStatus.Error = &status.ErrorDetail{Reason: state, Message: pullErr.Error()}
+		}
+		if _, err := worker.sm.Set(statusPath, *volumeStatus); err != nil {
+			return errors.Wrap(err, "set model status")
+		}
+		return nil
+	}
+
+	err := func() error {
+		// Record the files pulled before as the pull state, so that the pull
+		// of the whole model resumes from them instead of pulling them again.
+		state := &pullState{Key: pullStateKey(reference, opts), Layers: map[string]string{}}
+		for _, item := range pulledHook.GetProgress().Items {
+			if item.FinishedAt != nil && item.Error == nil && item.Path != "" {
+				state.Layers[strings.TrimPrefix(item.Path, "/")] = item.Digest.String()
+			}
+		}
+		if err := newResumeHook(ctx, hook, getPullStatePath(modelDir), state).save(); err != nil {
+			return err
+		}
+
+		var diskQuotaChecker *DiskQuotaChecker
+		if worker.cfg.Get().Features.CheckDiskQuota && opts.CheckDiskQuota {
+			diskQuotaChecker = worker.newDiskQuotaChecker(filepath.Dir(modelDir), false)
+			defer diskQuotaChecker.Release()
+		}
+		// The volume stays in WEIGHTS_PULLING state while the pull is queued.
+		puller, _, err := worker.newModelPuller(ctx, opts, hook, diskQuotaChecker, func(status.State) error {
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if err := puller.Pull(ctx, reference, modelDir, opts.ExcludeModelWeights, opts.ExcludeFilePatterns); err != nil {
+			return err
+		}
+		return worker.writeModelChecksums(ctx, modelDir, false)
+	}()
+	metrics.NodeOpObserve("pull_weights", start, err)
 	if err != nil {
-		if !os.IsNotExist(err) {
-			logger.WithContext(ctx).WithError(err).Errorf("read volume dirs from %s", volumesDir)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			logger.WithContext(ctx).WithError(err).Errorf("pull model weights timeout")
+			if err := setState(status.StatePullTimeout, nil, err); err != nil {
+				logger.WithContext(ctx).WithError(err).Errorf("failed to set status after pull model weights timeout")
+			}
+			return
 		}
+		if ctx.Err() != nil {
+			logger.WithContext(ctx).WithError(err).Infof("pull model weights canceled")
+			return
+		}
+		logger.WithContext(ctx).WithError(err).Errorf("pull model weights failed")
+		if err := setState(status.StatePullFailed, nil, err); err != nil {
+			logger.WithContext(ctx).WithError(err).Errorf("failed to set status after pull model weights failed")
+		}
+		return
+	}
+
+	if err := setState(status.StatePullSucceeded, hook.GetExcludedFiles(), nil); err != nil {
+		logger.WithContext(ctx).WithError(err).Errorf("failed to set status after pull model weights succeeded")
+		return
+	}
+	logger.WithContext(ctx).Infof("pull model weights succeeded: %s", time.Since(start))
+}
+
+// modelDirSize returns the disk usage of the pulled model files, 0 if it
+// can't be got.
+func modelDirSize(ctx context.Context, modelDir string) int64 {
+	size, err := getUsedSize(modelDir)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("get used size: %s", modelDir)
+		return 0
+	}
+	return size
+}
+
+// isRetryablePullError returns true if the pull is interrupted by the error
+// which is likely gone on retry, e.g. the network error.
+func isRetryablePullError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.As(err, &netErr)
+}
+
+// normalizeImageReference returns the image reference in the normalized
+// form, e.g. "docker.io/foo/bar:latest" for "foo/bar".
+func normalizeImageReference(reference string) string {
+	if named, err := dockerref.ParseNormalizedNamed(reference); err == nil {
+		return dockerref.TagNameOnly(named).String()
+	}
+	return reference
+}
+
+// pinReference returns the image reference pinned to the digest with the
+// tag dropped, e.g. "docker.io/foo/bar@sha256:...", the reference is
+// returned as is if the digest is empty.
+func pinReference(reference, dgst string) string {
+	if dgst == "" {
+		return reference
+	}
+	named, err := dockerref.ParseNormalizedNamed(reference)
+	if err != nil {
+		return reference
+	}
+	pinned, err := dockerref.WithDigest(dockerref.TrimNamed(named), digest.Digest(dgst))
+	if err != nil {
+		return reference
+	}
+	return pinned.String()
+}
+
+// pullKey returns the key identifying the model content to pull, the
+// references of the same model in different forms (e.g. "foo/bar" and
+// "docker.io/foo/bar:latest") get the same key.
+func pullKey(reference string, opts PullOptions) string {
+	if isImageModelType(opts.Type) {
+		opts.Type = ModelTypeImage
+		reference = normalizeImageReference(reference)
+	}
+	key := fmt.Sprintf("%s|%s|%v|%s", opts.Type, reference, opts.ExcludeModelWeights, strings.Join(opts.ExcludeFilePatterns, ","))
+	for _, adapter := range opts.Adapters {
+		key += fmt.Sprintf("|%s=%s", adapter.Name, pullKey(adapter.Reference, PullOptions{Type: adapter.Type}))
+	}
+	// The volumes pulling with different credentials don't share the pull,
+	// otherwise a volume could get the model it's not authorized to access.
+	if len(opts.Secrets) > 0 {
+		names := make([]string, 0, len(opts.Secrets))
+		for name := range opts.Secrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		hash := sha256.New()
+		for _, name := range names {
+			fmt.Fprintf(hash, "%s=%s\n", name, opts.Secrets[name])
+		}
+		key += "|" + hex.EncodeToString(hash.Sum(nil))
+	}
+	return key
+}
+
+// canPullIncrementally returns true if the model image pulled before into
+// the model dir can be pulled again by the changed layers, unless the same
+// model is on the node to be cloned from, or the model files are shared by
+// the blob store.
+func (worker *Worker) canPullIncrementally(ctx context.Context, key, modelDir string, opts PullOptions) bool {
+	if !isImageModelType(opts.Type) || worker.cfg.Get().Features.SharedBlobStore || opts.NoReuse {
 		return false
 	}
+	if _, err := os.Stat(getPulledLayersPath(modelDir)); err != nil {
+		return false
+	}
+	return worker.findPulledModel(ctx, key, modelDir) == ""
+}
 
-	isModelMountedHere := func(modelDir string) bool {
-		status, err := worker.sm.Get(filepath.Join(modelDir, "status.json"))
+// statusPullOptions returns the options the model of the status was pulled
+// with, so that the model is pulled again the same, e.g. to repair or restore
+// its files.
+func statusPullOptions(st *status.Status) PullOptions {
+	modelType, _, _ := strings.Cut(st.PullKey, "|")
+	return PullOptions{
+		Type:                modelType,
+		ExcludeModelWeights: st.ExcludeModelWeights,
+		ExcludeFilePatterns: st.ExcludeFilePatterns,
+		Digest:              st.Digest,
+		Platform:            st.Platform,
+		Adapters:            st.Adapters,
+		RetainCache:         st.RetainCache,
+	}
+}
+
+// pullShared pulls the model into the model dir, the concurrent requests of the
+// same model share one pull and then clone the pulled files into their own
+// model dir, it returns the model dir of the shared pull if it's cloned from.
+func (worker *Worker) pullShared(ctx context.Context, puller Puller, hook *status.Hook, reference, modelDir string, opts PullOptions) (string, error) {
+	leading := false
+	key := pullKey(reference, opts)
+	ch := worker.pulls.DoChan(key, func() (interface{}, error) {
+		leading = true
+		if err := puller.Pull(ctx, reference, modelDir, opts.ExcludeModelWeights, opts.ExcludeFilePatterns); err != nil {
+			return nil, err
+		}
+		return &sharedPull{modelDir: modelDir, excludedFiles: hook.GetExcludedFiles()}, nil
+	})
+
+	var result singleflight.Result
+	select {
+	case result = <-ch:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if leading {
+		return "", result.Err
+	}
+
+	if result.Err != nil {
+		if ctx.Err() != nil {
+			return "", result.Err
+		}
+		// The shared pull may fail for the reason of the leading request, e.g.
+		// it's canceled by deleting its volume.
+		logger.WithContext(ctx).WithError(result.Err).Warnf("shared pull failed, pull the model again")
+	} else {
+		sourceDir := result.Val.(*sharedPull).modelDir
+		err := worker.cloneModelDir(sourceDir, modelDir)
+		if err == nil {
+			for _, path := range result.Val.(*sharedPull).excludedFiles {
+				hook.AddExcludedFile(path)
+			}
+			logger.WithContext(ctx).Infof("cloned model from the shared pull: %s", sourceDir)
+			return sourceDir, nil
+		}
+		// The volume of the shared pull may be deleted in the meantime.
+		logger.WithContext(ctx).WithError(err).Warnf("clone model from the shared pull: %s, pull the model again", sourceDir)
+	}
+
+	if err := os.RemoveAll(modelDir); err != nil {
+		return "", errors.Wrapf(err, "cleanup model directory before pull: %s", modelDir)
+	}
+	if err := puller.Pull(ctx, reference, modelDir, opts.ExcludeModelWeights, opts.ExcludeFilePatterns); err != nil {
+		return "", err
+	}
+
+	return "", nil
+}
+
+// sharedPull is the result of the pull shared by the concurrent requests.
+type sharedPull struct {
+	modelDir      string
+	excludedFiles []string
+}
+
+// newModelPuller creates the puller of the model type, which pulls through
+// the shared blob store and the containerd content store and preheats the
+// model by Dragonfly if enabled, and once admitted by the pull queue, the
+// blob store puller is returned to report the reused size.
+func (worker *Worker) newModelPuller(
+	ctx context.Context,
+	opts PullOptions,
+	hook *status.Hook,
+	diskQuotaChecker *DiskQuotaChecker,
+	setState func(state status.State) error,
+) (Puller, *blobStorePuller, error) {
+	puller, err := worker.getPuller(ctx, opts, hook, diskQuotaChecker)
+	if err != nil {
+		return nil, nil, err
+	}
+	if worker.cfg.Get().PullConfig.Preheat.ManagerURL != "" && isImageModelType(opts.Type) {
+		puller = &preheatPuller{Puller: puller, pullCfg: &worker.cfg.Get().PullConfig, hook: hook, client: http.DefaultClient}
+	}
+	if worker.cfg.Get().PullConfig.Containerd.ContentDir != "" && isImageModelType(opts.Type) {
+		puller = &containerdPuller{Puller: puller, pullCfg: &worker.cfg.Get().PullConfig, hook: hook}
+	}
+	var blobPuller *blobStorePuller
+	if worker.cfg.Get().Features.SharedBlobStore && isImageModelType(opts.Type) {
+		blobPuller = &blobStorePuller{Puller: puller, pullCfg: &worker.cfg.Get().PullConfig, store: worker.blobStore, hook: hook}
+		puller = blobPuller
+	}
+	if fault.Enabled() {
+		puller = &faultPuller{Puller: puller}
+	}
+	puller = &admittedPuller{
+		Puller:   puller,
+		queue:    worker.queue,
+		priority: opts.Priority,
+		hook:     hook,
+		setState: setState,
+	}
+	return puller, blobPuller, nil
+}
+
+func (worker *Worker) getPuller(ctx context.Context, opts PullOptions, hook *status.Hook, diskQuotaChecker *DiskQuotaChecker) (Puller, error) {
+	pullCfg := &worker.cfg.Get().PullConfig
+	switch {
+	case isImageModelType(opts.Type):
+		return worker.newPuller(ctx, pullCfg, hook, diskQuotaChecker), nil
+	case opts.Type == ModelTypeHuggingFace:
+		return NewHuggingFacePuller(ctx, pullCfg, hook, diskQuotaChecker), nil
+	case opts.Type == ModelTypeS3:
+		return NewS3Puller(ctx, pullCfg, hook, diskQuotaChecker, opts.Secrets), nil
+	case opts.Type == ModelTypeArchive:
+		return NewArchivePuller(ctx, pullCfg, hook, diskQuotaChecker), nil
+	}
+	return nil, errors.Errorf("unsupported model type: %s", opts.Type)
+}
+
+// newDiskQuotaChecker returns the disk quota checker of the pull into the
+// volume dir, which evicts the cached models to make room for the pull if the
+// eviction is enabled, reserves the checked size until released, and limits
+// the volume dir to the size by the project quota if projectQuota is set.
+func (worker *Worker) newDiskQuotaChecker(volumeDir string, projectQuota bool) *DiskQuotaChecker {
+	checker := NewDiskQuotaChecker(worker.cfg)
+	checker.evict = worker.evictModels
+	checker.reservations = worker.reservations
+	checker.volumeDir = volumeDir
+	checker.projectQuota = projectQuota
+	return checker
+}
+
+// cloneModelDir clones the model dir pulled for another volume, the model
+// files are hardlinked only if the shared blob store is enabled, as the model
+// dirs are mounted read-only then, otherwise they are copied so that a write
+// from one volume never changes another volume.
+func (worker *Worker) cloneModelDir(srcDir, dstDir string) error {
+	if err := os.RemoveAll(dstDir); err != nil {
+		return errors.Wrapf(err, "cleanup model dir: %s", dstDir)
+	}
+	if worker.cfg.Get().Features.SharedBlobStore {
+		return utils.LinkDir(srcDir, dstDir)
+	}
+	return utils.CopyDir(srcDir, dstDir)
+}
+
+// reuseModel clones the identical model already pulled for another volume on
+// the node into the model dir instead of pulling it from the remote, the model
+// retained from a deleted volume is moved instead. It returns the model dir
+// cloned from and the files excluded from the model, or empty if there is no
+// such model.
+func (worker *Worker) reuseModel(ctx context.Context, key, modelDir string) (string, []string, error) {
+	sourceDir := worker.findPulledModel(ctx, key, modelDir)
+	if sourceDir == "" {
+		return "", nil, nil
+	}
+	var excludedFiles []string
+	if sourceStatus, err := worker.sm.Get(filepath.Join(filepath.Dir(sourceDir), "status.json")); err == nil {
+		excludedFiles = sourceStatus.ExcludedFiles
+		if sourceStatus.Retained && worker.takeRetainedModel(ctx, sourceDir, modelDir) {
+			return sourceDir, excludedFiles, nil
+		}
+	}
+
+	if err := worker.cloneModelDir(sourceDir, modelDir); err != nil {
+		// The source volume may be deleted in the meantime.
+		logger.WithContext(ctx).WithError(err).Warnf("clone model from %s, pull the model instead", sourceDir)
+		if err := os.RemoveAll(modelDir); err != nil {
+			return "", nil, errors.Wrapf(err, "cleanup model directory before pull: %s", modelDir)
+		}
+		return "", nil, nil
+	}
+	logger.WithContext(ctx).Infof("cloned model from existing model dir: %s", sourceDir)
+	// Mark the source model as used for the LRU eviction.
+	now := time.Now()
+	if err := os.Chtimes(sourceDir, now, now); err != nil {
+		logger.WithContext(ctx).WithError(err).Warnf("failed to touch model dir: %s", sourceDir)
+	}
+
+	return sourceDir, excludedFiles, nil
+}
+
+// findPulledModel returns the model dir of another volume on the node which
+// holds the model pulled completely with the same pull key, or empty if
+// there is no such volume.
+func (worker *Worker) findPulledModel(ctx context.Context, key, excludeModelDir string) string {
+	isModelPulledHere := func(volumeDir string) bool {
+		modelDir := filepath.Join(volumeDir, "model")
+		if modelDir == excludeModelDir {
+			return false
+		}
+		modelStatus, err := worker.sm.Get(filepath.Join(volumeDir, "status.json"))
 		if err != nil {
 			if !errors.Is(err, os.ErrNotExist) {
 				logger.WithContext(ctx).WithError(err).Error("failed to get volume status")
 			}
 			return false
 		}
-		if status.Reference == reference {
-			if _, err := os.Stat(filepath.Join(modelDir, "model")); err == nil {
-				return true
-			}
+		if modelStatus.PullKey != key {
+			return false
+		}
+		switch modelStatus.State {
+		case status.StatePullSucceeded, status.StateMounted, status.StateUmounted:
+		default:
+			return false
+		}
+		_, err = os.Stat(modelDir)
+		return err == nil
+	}
+
+	pulledModelDir := ""
+	worker.walkVolumeDirs(ctx, func(volumeDir string) bool {
+		if isModelPulledHere(volumeDir) {
+			pulledModelDir = filepath.Join(volumeDir, "model")
+			return true
 		}
 		return false
+	})
+
+	return pulledModelDir
+}
+
+// walkVolumeDirs calls the fn with the dir of each static volume, each
+// prefetched model and each mount of the dynamic volumes on the node, until
+// the fn returns true.
+func (worker *Worker) walkVolumeDirs(ctx context.Context, fn func(volumeDir string) bool) {
+	volumesDir := worker.cfg.Get().GetVolumesDir()
+	volumeDirs, err := os.ReadDir(volumesDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.WithContext(ctx).WithError(err).Errorf("read volume dirs from %s", volumesDir)
+		}
+		return
 	}
+
 	for _, volumeDir := range volumeDirs {
-		if !volumeDir.IsDir() {
+		if !isVolumeDirEntry(volumesDir, volumeDir) {
 			continue
 		}
-		if isStaticVolume(volumeDir.Name()) {
-			if isModelMountedHere(worker.cfg.Get().GetVolumeDir(volumeDir.Name())) {
-				return true
+		if isStaticVolume(volumeDir.Name()) || isPrefetchVolume(volumeDir.Name()) {
+			if fn(worker.cfg.Get().GetVolumeDir(volumeDir.Name())) {
+				return
 			}
 		}
 		if isDynamicVolume(volumeDir.Name()) {
@@ -274,17 +977,38 @@ func (worker *Worker) isModelExisted(ctx context.Context, reference string) bool
 				continue
 			}
 			for _, modelDir := range modelDirs {
-				if !modelDir.IsDir() {
+				if !isVolumeDirEntry(modelsDirForDynamic, modelDir) {
 					continue
 				}
 
-				mountID := modelDir.Name()
-				if isModelMountedHere(worker.cfg.Get().GetMountIDDirForDynamic(volumeDir.Name(), mountID)) {
-					return true
+				if fn(worker.cfg.Get().GetMountIDDirForDynamic(volumeDir.Name(), modelDir.Name())) {
+					return
 				}
 			}
 		}
 	}
+}
 
-	return false
+// FailInterruptedWeightsPulls marks the volumes whose weights were being
+// pulled in the background as PULL_FAILED on startup, as the background pull
+// isn't resumed after the driver restarts, the pulled files are kept for the
+// retried pull to resume.
+func (worker *Worker) FailInterruptedWeightsPulls(ctx context.Context) {
+	worker.walkVolumeDirs(ctx, func(volumeDir string) bool {
+		statusPath := filepath.Join(volumeDir, "status.json")
+		volumeStatus, err := worker.sm.Get(statusPath)
+		if err != nil || volumeStatus.State != status.StateWeightsPulling {
+			return false
+		}
+		volumeStatus.State = status.StatePullFailed
+		finishedAt := time.Now()
+		volumeStatus.PullFinishedAt = &finishedAt
+		volumeStatus.Error = &status.ErrorDetail{Reason: status.StatePullFailed, Message: "weights pull is interrupted by restart"}
+		if _, err := worker.sm.Set(statusPath, *volumeStatus); err != nil {
+			logger.WithContext(ctx).WithError(err).Errorf("failed to set status of interrupted weights pull: %s", volumeDir)
+			return false
+		}
+		logger.WithContext(ctx).Warnf("weights pull is interrupted by restart: %s", volumeDir)
+		return false
+	})
 }
@@ -49,35 +49,38 @@ func TestNewWorker(t *testing.T) {
 	cfg := config.NewWithRaw(rawCfg)
 	sm, err := status.NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 
 	worker, err := NewWorker(cfg, sm)
 	require.NoError(t, err)
 	require.NotNil(t, worker)
 }
 
-// ─── isModelExisted ───────────────────────────────────────────────────────────
+// ─── findPulledModel ──────────────────────────────────────────────────────────
 
-func TestIsModelExisted_EmptyDir(t *testing.T) {
+func TestFindPulledModel_EmptyDir(t *testing.T) {
 	tmpDir := t.TempDir()
 	rawCfg := &config.RawConfig{ServiceName: "test", RootDir: tmpDir}
 	cfg := config.NewWithRaw(rawCfg)
 	sm, err := status.NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 
 	worker, err := NewWorker(cfg, sm)
 	require.NoError(t, err)
 
 	// volumes dir doesn't exist yet, should return false without error.
-	exists := worker.isModelExisted(context.Background(), "registry/model:v1")
-	require.False(t, exists)
+	sourceDir := worker.findPulledModel(context.Background(), pullKey("registry/model:v1", PullOptions{}), "")
+	require.Empty(t, sourceDir)
 }
 
-func TestIsModelExisted_StaticVolumeMatch(t *testing.T) {
+func TestFindPulledModel_StaticVolumeMatch(t *testing.T) {
 	tmpDir := t.TempDir()
 	rawCfg := &config.RawConfig{ServiceName: "test", RootDir: tmpDir}
 	cfg := config.NewWithRaw(rawCfg)
 	sm, err := status.NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 
 	worker, err := NewWorker(cfg, sm)
 	require.NoError(t, err)
@@ -93,19 +96,25 @@ func TestIsModelExisted_StaticVolumeMatch(t *testing.T) {
 		VolumeName: volumeName,
 		Reference:  "registry/model:v1",
 		State:      status.StatePullSucceeded,
+		PullKey:    pullKey("registry/model:v1", PullOptions{}),
 	})
 	require.NoError(t, err)
 
-	exists := worker.isModelExisted(context.Background(), "registry/model:v1")
-	require.True(t, exists)
+	sourceDir := worker.findPulledModel(context.Background(), pullKey("registry/model:v1", PullOptions{}), "")
+	require.Equal(t, modelDir, sourceDir)
+
+	// The model dir of the volume itself is excluded.
+	sourceDir = worker.findPulledModel(context.Background(), pullKey("registry/model:v1", PullOptions{}), modelDir)
+	require.Empty(t, sourceDir)
 }
 
-func TestIsModelExisted_StaticVolume_NoMatch(t *testing.T) {
+func TestFindPulledModel_StaticVolume_NoMatch(t *testing.T) {
 	tmpDir := t.TempDir()
 	rawCfg := &config.RawConfig{ServiceName: "test", RootDir: tmpDir}
 	cfg := config.NewWithRaw(rawCfg)
 	sm, err := status.NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 
 	worker, err := NewWorker(cfg, sm)
 	require.NoError(t, err)
@@ -120,20 +129,22 @@ func TestIsModelExisted_StaticVolume_NoMatch(t *testing.T) {
 		VolumeName: volumeName,
 		Reference:  "registry/other-model:v2",
 		State:      status.StatePullSucceeded,
+		PullKey:    pullKey("registry/other-model:v2", PullOptions{}),
 	})
 	require.NoError(t, err)
 
 	// Looking for a different reference.
-	exists := worker.isModelExisted(context.Background(), "registry/model:v1")
-	require.False(t, exists)
+	sourceDir := worker.findPulledModel(context.Background(), pullKey("registry/model:v1", PullOptions{}), "")
+	require.Empty(t, sourceDir)
 }
 
-func TestIsModelExisted_DynamicVolume(t *testing.T) {
+func TestFindPulledModel_DynamicVolume(t *testing.T) {
 	tmpDir := t.TempDir()
 	rawCfg := &config.RawConfig{ServiceName: "test", RootDir: tmpDir}
 	cfg := config.NewWithRaw(rawCfg)
 	sm, err := status.NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 
 	worker, err := NewWorker(cfg, sm)
 	require.NoError(t, err)
@@ -149,11 +160,12 @@ func TestIsModelExisted_DynamicVolume(t *testing.T) {
 	_, err = sm.Set(statusPath, status.Status{
 		Reference: "registry/model:dyn",
 		State:     status.StatePullSucceeded,
+		PullKey:   pullKey("registry/model:dyn", PullOptions{}),
 	})
 	require.NoError(t, err)
 
-	exists := worker.isModelExisted(context.Background(), "registry/model:dyn")
-	require.True(t, exists)
+	sourceDir := worker.findPulledModel(context.Background(), pullKey("registry/model:dyn", PullOptions{}), "")
+	require.Equal(t, modelDir, sourceDir)
 }
 
 // ─── DeleteModel ──────────────────────────────────────────────────────────────
@@ -164,6 +176,7 @@ func TestDeleteModel_NonExistentDir(t *testing.T) {
 	cfg := config.NewWithRaw(rawCfg)
 	sm, err := status.NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 
 	worker, err := NewWorker(cfg, sm)
 	require.NoError(t, err)
@@ -179,6 +192,7 @@ func TestDeleteModel_ExistingDir(t *testing.T) {
 	cfg := config.NewWithRaw(rawCfg)
 	sm, err := status.NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 
 	worker, err := NewWorker(cfg, sm)
 	require.NoError(t, err)
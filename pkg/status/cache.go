@@ -0,0 +1,164 @@
+package status
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/modelpack/model-csi-driver/pkg/logger"
+)
+
+// statusCache keeps the parsed status.json files in memory, so that frequent
+// reads (e.g. GetMount polling) don't need to re-read and re-parse the file.
+//
+// Entries are invalidated on every write through StatusManager, and are
+// validated by the modification time and size of the status file to catch
+// changes made outside of the StatusManager. An fsnotify watcher on the
+// parent directory of the volume dirs drops the entries of the removed
+// volumes eagerly, one watch covers all the volumes in the directory.
+type statusCache struct {
+	mutex   sync.RWMutex
+	items   map[string]cachedStatus
+	watched map[string]bool
+	// version is increased on every invalidation, it's used to avoid
+	// populating the cache with a status read before a concurrent change.
+	version uint64
+	watcher *fsnotify.Watcher
+}
+
+type cachedStatus struct {
+	status  Status
+	modTime time.Time
+	size    int64
+}
+
+func newStatusCache() *statusCache {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		// The cache is disabled if the watcher is unavailable (e.g. the inotify
+		// instance limit is reached), all reads fall back to the status file.
+		logger.Logger().WithError(err).Warn("failed to create status watcher, status cache is disabled")
+		return nil
+	}
+
+	cache := &statusCache{
+		items:   make(map[string]cachedStatus),
+		watched: make(map[string]bool),
+		watcher: watcher,
+	}
+
+	go cache.watch()
+
+	return cache
+}
+
+func (c *statusCache) watch() {
+	for {
+		select {
+		case event, ok := <-c.watcher.Events:
+			if !ok {
+				return
+			}
+			c.mutex.Lock()
+			if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 && c.watched[event.Name] {
+				// The watch is dropped by the kernel when the directory is removed.
+				delete(c.watched, event.Name)
+			}
+			c.mutex.Unlock()
+			c.invalidate(event.Name)
+		case err, ok := <-c.watcher.Errors:
+			if !ok {
+				return
+			}
+			// Events may be lost on watcher overflow, drop all entries to be safe.
+			logger.Logger().WithError(err).Warn("status watcher error, reset status cache")
+			c.mutex.Lock()
+			c.items = make(map[string]cachedStatus)
+			c.version++
+			c.mutex.Unlock()
+		}
+	}
+}
+
+// close stops the watcher, the cache must not be used after it's closed.
+func (c *statusCache) close() error {
+	return c.watcher.Close()
+}
+
+func (c *statusCache) get(statusPath string) (*Status, bool) {
+	c.mutex.RLock()
+	item, ok := c.items[statusPath]
+	c.mutex.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	info, err := os.Stat(statusPath)
+	if err != nil || !info.ModTime().Equal(item.modTime) || info.Size() != item.size {
+		return nil, false
+	}
+
+	return &item.status, true
+}
+
+// prepare starts watching the parent directory of the volume dir holding the
+// status file, it must be called before reading the status file, the returned
+// version should be passed to set.
+func (c *statusCache) prepare(statusPath string) uint64 {
+	dir := filepath.Dir(filepath.Dir(statusPath))
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.watched[dir]; !ok {
+		err := c.watcher.Add(dir)
+		if err != nil && !os.IsNotExist(err) {
+			// Only log once for the directory, the cache still works by the
+			// validation of the status file without the watch.
+			logger.Logger().WithError(err).Warnf("failed to watch status dir: %s", dir)
+		}
+		if !os.IsNotExist(err) {
+			c.watched[dir] = err == nil
+		}
+	}
+
+	return c.version
+}
+
+// set caches the status read from the status file, the info must be stat
+// before reading the status file.
+func (c *statusCache) set(statusPath string, status Status, info os.FileInfo, version uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.version != version {
+		return
+	}
+
+	c.items[statusPath] = cachedStatus{
+		status:  status,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+	}
+}
+
+// invalidate drops the cached status of the path and all the status
+// under the path if it's a directory.
+func (c *statusCache) invalidate(path string) {
+	path = filepath.Clean(path)
+	prefix := path + string(filepath.Separator)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.version++
+	delete(c.items, path)
+	for key := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.items, key)
+		}
+	}
+}
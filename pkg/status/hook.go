@@ -3,6 +3,7 @@ package status
 import (
 	"context"
 	"fmt"
+	"io"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -62,8 +63,19 @@ type Hook struct {
 	mutex    sync.RWMutex
 	manifest *ocispec.Manifest
 	total    int
-	pulled   atomic.Uint32
-	progress map[digest.Digest]*ProgressItem
+	// The total size of the layers to pull, set externally by SetTotalSize.
+	totalSize int64
+	// The bytes transferred by the pull and the samples of them in the
+	// rolling window of the throughput.
+	transferred int64
+	samples     []throughputSample
+	pulled      atomic.Uint32
+	progress    map[digest.Digest]*ProgressItem
+	// The position in the node-wide pull queue, 0 if it's not queued.
+	queuePosition int
+	// The files excluded from the pull by the filters.
+	excludedFiles map[string]struct{}
+	preheat       *PreheatProgress
 }
 
 func NewHook(ctx context.Context) *Hook {
@@ -104,18 +116,193 @@ func (h *Hook) SetTotal(total int) {
 	h.total = total
 }
 
+// SetTotalSize sets the total size of the layers to pull, e.g. the layers
+// left by the filters.
+func (h *Hook) SetTotalSize(size int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.totalSize = size
+}
+
+func (h *Hook) getTotalSize() int64 {
+	if h.totalSize > 0 {
+		return h.totalSize
+	}
+
+	size := int64(0)
+	if h.total == 0 && h.manifest != nil && len(h.manifest.Layers) > 0 {
+		for _, layer := range h.manifest.Layers {
+			size += layer.Size
+		}
+		return size
+	}
+	for _, item := range h.progress {
+		size += item.Size
+	}
+	return size
+}
+
+// SetDownloadedBytes sets the bytes of the layer downloaded so far, e.g. the
+// partially downloaded file being resumed.
+func (h *Hook) SetDownloadedBytes(dgst digest.Digest, downloaded int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if progress := h.progress[dgst]; progress != nil {
+		downloaded = min(downloaded, progress.Size)
+		h.addTransferred(downloaded - progress.DownloadedBytes)
+		progress.DownloadedBytes = downloaded
+	}
+}
+
+// AddDownloadedBytes adds the bytes of the layer downloaded.
+func (h *Hook) AddDownloadedBytes(dgst digest.Digest, downloaded int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if progress := h.progress[dgst]; progress != nil {
+		downloaded = min(progress.DownloadedBytes+downloaded, progress.Size)
+		h.addTransferred(downloaded - progress.DownloadedBytes)
+		progress.DownloadedBytes = downloaded
+	}
+}
+
+const (
+	// The window of the rolling throughput.
+	throughputWindow         = 10 * time.Second
+	throughputSampleInterval = time.Second
+)
+
+type throughputSample struct {
+	at    time.Time
+	bytes int64
+}
+
+// addTransferred records the bytes transferred for the rolling throughput,
+// the reset of the downloaded bytes (e.g. by the retry) isn't counted. It's
+// called with the mutex held.
+func (h *Hook) addTransferred(transferred int64) {
+	if transferred <= 0 {
+		return
+	}
+
+	now := time.Now()
+	if len(h.samples) == 0 {
+		h.samples = append(h.samples, throughputSample{at: now, bytes: h.transferred})
+	}
+	h.transferred += transferred
+	if now.Sub(h.samples[len(h.samples)-1].at) >= throughputSampleInterval {
+		h.samples = append(h.samples, throughputSample{at: now, bytes: h.transferred})
+	}
+	// Keep the last sample older than the window as the base of the
+	// throughput.
+	for len(h.samples) > 2 && now.Sub(h.samples[1].at) >= throughputWindow {
+		h.samples = h.samples[1:]
+	}
+}
+
+// getThroughput returns the bytes per second transferred in the rolling
+// window, which drops as the transfer stalls.
+func (h *Hook) getThroughput(now time.Time) int64 {
+	if len(h.samples) == 0 {
+		return 0
+	}
+
+	base := h.samples[0]
+	for _, sample := range h.samples[1:] {
+		if now.Sub(sample.at) < throughputWindow {
+			break
+		}
+		base = sample
+	}
+	elapsed := now.Sub(base.at)
+	if elapsed < throughputSampleInterval {
+		return 0
+	}
+
+	return int64(float64(h.transferred-base.bytes) / elapsed.Seconds())
+}
+
+type progressWriter struct {
+	hook   *Hook
+	digest digest.Digest
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.hook.AddDownloadedBytes(w.digest, int64(len(p)))
+	return len(p), nil
+}
+
+// ProgressWriter returns the writer counting the bytes written as the bytes
+// of the layer downloaded, e.g. teed from the layer being downloaded.
+func (h *Hook) ProgressWriter(dgst digest.Digest) io.Writer {
+	return &progressWriter{hook: h, digest: dgst}
+}
+
+// SetQueuePosition sets the position of the pull in the node-wide pull
+// queue, 0 once it's admitted.
+func (h *Hook) SetQueuePosition(position int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.queuePosition = position
+}
+
+// SetPreheat records the progress of the Dragonfly preheat of the model.
+func (h *Hook) SetPreheat(preheat PreheatProgress) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.preheat = &preheat
+}
+
+// AddExcludedFile records the file excluded from the pull by the filters,
+// e.g. exclude_model_weights or exclude_file_patterns.
+func (h *Hook) AddExcludedFile(path string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.excludedFiles == nil {
+		h.excludedFiles = make(map[string]struct{})
+	}
+	h.excludedFiles[path] = struct{}{}
+}
+
+// GetExcludedFiles returns the sorted files excluded from the pull.
+func (h *Hook) GetExcludedFiles() []string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	files := make([]string, 0, len(h.excludedFiles))
+	for path := range h.excludedFiles {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+
+	return files
+}
+
+// LayerFilepath returns the file path of the model layer relative to the
+// model dir, it's empty if the layer has no file path annotation.
+func LayerFilepath(desc ocispec.Descriptor) string {
+	if desc.Annotations == nil {
+		return ""
+	}
+	if desc.Annotations[modelspec.AnnotationFilepath] != "" {
+		return desc.Annotations[modelspec.AnnotationFilepath]
+	}
+	// Support old annotation for backward compatibility
+	return desc.Annotations[oldModelspec.AnnotationFilepath]
+}
+
 func (h *Hook) BeforePullLayer(desc ocispec.Descriptor, manifest ocispec.Manifest) {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
 	filePath := ""
-	if desc.Annotations != nil {
-		if desc.Annotations[modelspec.AnnotationFilepath] != "" {
-			filePath = fmt.Sprintf("/%s", desc.Annotations[modelspec.AnnotationFilepath])
-		} else if desc.Annotations[oldModelspec.AnnotationFilepath] != "" {
-			// Support old annotation for backward compatibility
-			filePath = fmt.Sprintf("/%s", desc.Annotations[oldModelspec.AnnotationFilepath])
-		}
+	if layerFilePath := LayerFilepath(desc); layerFilePath != "" {
+		filePath = fmt.Sprintf("/%s", layerFilePath)
 	}
 
 	_, span := tracing.Tracer.Start(h.ctx, "PullLayer")
@@ -136,6 +323,29 @@ func (h *Hook) BeforePullLayer(desc ocispec.Descriptor, manifest ocispec.Manifes
 	}
 }
 
+// MarkPulled records the layer pulled before, e.g. by the interrupted pull
+// being resumed, so that the progress and the pulled size count it.
+func (h *Hook) MarkPulled(desc ocispec.Descriptor) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	filePath := ""
+	if layerFilePath := LayerFilepath(desc); layerFilePath != "" {
+		filePath = fmt.Sprintf("/%s", layerFilePath)
+	}
+
+	now := time.Now()
+	h.progress[desc.Digest] = &ProgressItem{
+		Digest:          desc.Digest,
+		Path:            filePath,
+		Size:            desc.Size,
+		StartedAt:       now,
+		FinishedAt:      &now,
+		DownloadedBytes: desc.Size,
+	}
+	h.pulled.Add(1)
+}
+
 func (h *Hook) AfterPullLayer(desc ocispec.Descriptor, err error) {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
@@ -154,6 +364,9 @@ func (h *Hook) AfterPullLayer(desc ocispec.Descriptor, err error) {
 		now := time.Now()
 		finishedAt = &now
 		h.pulled.Add(1)
+		// The layer pulled by modctl may be reported only once finished.
+		h.addTransferred(progress.Size - progress.DownloadedBytes)
+		progress.DownloadedBytes = progress.Size
 		duration := time.Since(progress.StartedAt)
 		logger.WithContext(h.ctx).Infof(
 			"pulled layer: %s %s %s %s (%s) %s",
@@ -185,11 +398,54 @@ func (h *Hook) getProgress() Progress {
 	})
 
 	total := h.getTotal()
+	downloaded := int64(0)
+	for _, item := range items {
+		downloaded += item.DownloadedBytes
+	}
+
+	totalBytes := h.getTotalSize()
+	now := time.Now()
+	throughput := h.getThroughput(now)
+	var remaining int64
+	var eta *time.Time
+	if throughput > 0 && totalBytes > downloaded {
+		remaining = (totalBytes - downloaded + throughput - 1) / throughput
+		finishAt := now.Add(time.Duration(remaining) * time.Second)
+		eta = &finishAt
+	}
+
+	var preheat *PreheatProgress
+	if h.preheat != nil {
+		copied := *h.preheat
+		preheat = &copied
+	}
 
 	return Progress{
-		Total: total,
-		Items: items,
+		Total:            total,
+		Items:            items,
+		TotalBytes:       totalBytes,
+		DownloadedBytes:  downloaded,
+		Throughput:       throughput,
+		RemainingSeconds: remaining,
+		ETA:              eta,
+		QueuePosition:    h.queuePosition,
+		Preheat:          preheat,
+	}
+}
+
+// GetPulledSize returns the total size of the layers pulled successfully.
+func (h *Hook) GetPulledSize() int64 {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	var size int64
+	for _, item := range h.progress {
+		if item.FinishedAt != nil {
+			size += item.Size
+		}
 	}
+
+	return size
 }
 
 func (h *Hook) GetProgress() Progress {
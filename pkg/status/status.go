@@ -16,17 +16,27 @@ import (
 type State = string
 
 const (
+	StatePullQueued    = "PULL_QUEUED"
 	StatePullRunning   = "PULLING"
 	StatePullSucceeded = "PULL_SUCCEEDED"
 	StatePullFailed    = "PULL_FAILED"
 	StatePullTimeout   = "PULL_TIMEOUT"
 	StatePullCanceled  = "PULL_CANCELED"
-	StateMounted       = "MOUNTED"
-	StateUmounted      = "UMOUNTED"
+	// The model files except the weights are pulled and the volume can be
+	// mounted, while the weights are still pulled in the background.
+	StateWeightsPulling = "WEIGHTS_PULLING"
+	StateMounted        = "MOUNTED"
+	StateUmounted       = "UMOUNTED"
+	// The dynamic mount is being deleted by force, its pull is being
+	// canceled before it's deleted.
+	StateCanceling = "CANCELING"
+	// The dynamic mount is being deleted.
+	StateDeleting = "DELETING"
 )
 
 type StatusManager struct {
 	mutex sync.Mutex
+	cache *statusCache
 
 	HookManager *HookManager
 }
@@ -36,6 +46,8 @@ type ProgressItem struct {
 	Path      string        `json:"path"`
 	Size      int64         `json:"size"`
 	StartedAt time.Time     `json:"started_at"`
+	// The bytes of the layer downloaded so far, the size once it's pulled.
+	DownloadedBytes int64 `json:"downloaded_bytes"`
 
 	FinishedAt *time.Time `json:"finished_at,omitempty"`
 	Error      error      `json:"error,omitempty"`
@@ -43,9 +55,49 @@ type ProgressItem struct {
 	Span trace.Span `json:"-"`
 }
 
+// The states of the Dragonfly preheat of the model.
+const (
+	PreheatRunning   = "RUNNING"
+	PreheatSucceeded = "SUCCEEDED"
+	PreheatFailed    = "FAILED"
+)
+
+// PreheatProgress is the progress of the Dragonfly preheat of the model, so
+// that the P2P warm-up is told apart from the pull of the layers.
+type PreheatProgress struct {
+	// The ID of the preheat job in the Dragonfly manager.
+	JobID      uint       `json:"job_id,omitempty"`
+	State      string     `json:"state"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
 type Progress struct {
 	Total int            `json:"total"`
 	Items []ProgressItem `json:"items"`
+	// The total size of the layers to pull and the bytes downloaded so far.
+	TotalBytes      int64 `json:"total_bytes"`
+	DownloadedBytes int64 `json:"downloaded_bytes"`
+	// The rolling throughput of the pull in bytes per second, and the
+	// estimated time to download the rest of the bytes at the throughput.
+	Throughput       int64      `json:"throughput,omitempty"`
+	RemainingSeconds int64      `json:"remaining_seconds,omitempty"`
+	ETA              *time.Time `json:"eta,omitempty"`
+	// The 1-based position in the node-wide pull queue while the state is
+	// PULL_QUEUED.
+	QueuePosition int `json:"queue_position,omitempty"`
+	// The Dragonfly preheat of the model, nil if it's not preheated.
+	Preheat *PreheatProgress `json:"preheat,omitempty"`
+}
+
+// Percentage returns the percentage of the downloaded bytes, 0 if the total
+// size is unknown.
+func (p *Progress) Percentage() float64 {
+	if p.TotalBytes <= 0 {
+		return 0
+	}
+	return float64(p.DownloadedBytes) * 100 / float64(p.TotalBytes)
 }
 
 func (p *Progress) String() (string, error) {
@@ -57,20 +109,162 @@ func (p *Progress) String() (string, error) {
 }
 
 type Status struct {
-	VolumeName string   `json:"volume_name,omitempty"`
-	MountID    string   `json:"mount_id,omitempty"`
-	Reference  string   `json:"reference,omitempty"`
-	State      State    `json:"state,omitempty"`
-	Inline     bool     `json:"inline,omitempty"`
-	Progress   Progress `json:"progress,omitempty"`
+	VolumeName string `json:"volume_name,omitempty"`
+	MountID    string `json:"mount_id,omitempty"`
+	Reference  string `json:"reference,omitempty"`
+	Digest     string `json:"digest,omitempty"` // The digest the image reference is pinned to.
+	// The platform of the manifest selected from the image index, e.g.
+	// "linux/amd64", empty if the reference isn't an index.
+	Platform string   `json:"platform,omitempty"`
+	State    State    `json:"state,omitempty"`
+	Inline   bool     `json:"inline,omitempty"`
+	ReadOnly bool     `json:"read_only,omitempty"`
+	Progress Progress `json:"progress,omitempty"`
+	// The key of the pulled model content, e.g. the normalized reference and
+	// the pull options, used to find the identical model of other volumes.
+	PullKey string `json:"pull_key,omitempty"`
+	// The filters applied to the pull and the files excluded by them.
+	ExcludeModelWeights bool     `json:"exclude_model_weights,omitempty"`
+	ExcludeFilePatterns []string `json:"exclude_file_patterns,omitempty"`
+	ExcludedFiles       []string `json:"excluded_files,omitempty"`
+	// The adapters mounted beside the base model of the composite mount.
+	Adapters []Adapter `json:"adapters,omitempty"`
+	// The target paths the volume is published to, used to re-create the bind
+	// mounts on root dir migration, and for dynamic root volume, to detect the
+	// orphaned target whose pod is gone without NodeUnpublishVolume being called.
+	Targets []Target `json:"targets,omitempty"`
+	// The staging path the model is mounted to by NodeStageVolume, the
+	// targets are bind mounted from it then.
+	StagingPath string `json:"staging_path,omitempty"`
+	// The ownership and the permission applied to the model files by the
+	// uid, gid and mode mount flags, e.g. "uid=1000,gid=-1,mode=0440".
+	MountOwner string `json:"mount_owner,omitempty"`
+	// The disk usage of the model files once the model is pulled, the files
+	// shared with other volumes by the hardlinks are counted for each volume.
+	SizeInBytes int64 `json:"size_in_bytes,omitempty"`
+	// Keep the model as the cache once the volume is deleted, and the model
+	// is retained from a deleted volume, the latter is set for the prefetch
+	// holding the retained model.
+	RetainCache bool `json:"retain_cache,omitempty"`
+	Retained    bool `json:"retained,omitempty"`
+	// The volume or the snapshot the model is cloned from, as "volume/$id" or
+	// "snapshot/$id". The cloned model may be modified, so it's neither
+	// shared with other volumes nor pulled again, and it has no pull key.
+	ClonedFrom string `json:"cloned_from,omitempty"`
+	// The model is pinned on the node against the eviction, it's set by the
+	// pins of the node on read instead of being stored.
+	Pinned bool `json:"pinned,omitempty"`
+	// The time the dynamic mount created with the TTL expires at unless
+	// refreshed, it's set by the lease of the mount on read.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// The time the pull is canceled at by the cancel request of the dynamic
+	// mount, the mount is kept in PULL_CANCELED state until deleted or
+	// created again.
+	CanceledAt *time.Time `json:"canceled_at,omitempty"`
+	// The result of the last scrub of the model files, it's set by the scrub
+	// record of the volume dir on read instead of being stored.
+	Scrub *ScrubResult `json:"scrub,omitempty"`
+	// The models of the dynamic mount replaced by the forced re-pulls, the
+	// latest last.
+	History []HistoryItem `json:"history,omitempty"`
+	// The time the volume or the dynamic mount is created at, kept by the
+	// models pulled again.
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	// The time the last pull of the model is started and done at, the
+	// latter is nil until the pull is done, including the weights pulled in
+	// the background.
+	PullStartedAt  *time.Time `json:"pull_started_at,omitempty"`
+	PullFinishedAt *time.Time `json:"pull_finished_at,omitempty"`
+	// The error of the failed pull, for the status kept after the pull
+	// failed, e.g. the pull canceled or interrupted.
+	Error *ErrorDetail `json:"error,omitempty"`
+	// The labels of the dynamic mount set by the creator, e.g. the tenant
+	// of the mount.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// ErrorDetail is the error of the failed pull.
+type ErrorDetail struct {
+	// The reason of the failed pull, e.g. PULL_TIMEOUT or
+	// INSUFFICIENT_DISK_QUOTA.
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// HistoryItem is the model of the dynamic mount replaced by a forced re-pull.
+type HistoryItem struct {
+	Reference  string    `json:"reference"`
+	Digest     string    `json:"digest,omitempty"`
+	State      State     `json:"state,omitempty"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// ScrubResult is the result of the scrub verifying the model files against
+// the checksum file.
+type ScrubResult struct {
+	ScrubbedAt time.Time `json:"scrubbed_at"`
+	// The number of the files verified, a sample of the model files if the
+	// scrub is sampled.
+	CheckedFiles int `json:"checked_files"`
+	// The files mismatching the checksum file or missing.
+	CorruptedFiles []string `json:"corrupted_files,omitempty"`
+}
+
+// Adapter is a model (e.g. LoRA) pulled beside the base model of the
+// composite mount, into the adapters/$name subdir of the model dir.
+type Adapter struct {
+	// The name of the subdir, derived from the reference if empty.
+	Name      string `json:"name,omitempty"`
+	Reference string `json:"reference"`
+	// The model type, the type of the base model by default.
+	Type string `json:"type,omitempty"`
+}
+
+// Target is a target path published by NodePublishVolume, a volume may be
+// published to the target paths of multiple pods on the node.
+type Target struct {
+	Path         string `json:"path"`
+	PodNamespace string `json:"pod_namespace,omitempty"`
+	PodName      string `json:"pod_name,omitempty"`
+	PodUID       string `json:"pod_uid,omitempty"`
+	// The target path is mounted read-only.
+	ReadOnly bool `json:"read_only,omitempty"`
+}
+
+// AddTarget adds the target to the status, the target of the same path is
+// replaced.
+func (status *Status) AddTarget(target Target) {
+	status.RemoveTarget(target.Path)
+	status.Targets = append(status.Targets, target)
+}
+
+// RemoveTarget removes the target of the path from the status.
+func (status *Status) RemoveTarget(path string) {
+	targets := []Target{}
+	for _, target := range status.Targets {
+		if target.Path != path {
+			targets = append(targets, target)
+		}
+	}
+	status.Targets = targets
 }
 
 func NewStatusManager() (*StatusManager, error) {
 	return &StatusManager{
+		cache:       newStatusCache(),
 		HookManager: NewHookManager(),
 	}, nil
 }
 
+// Close stops watching the status files, the StatusManager must not be used
+// after it's closed.
+func (sm *StatusManager) Close() error {
+	if sm.cache != nil {
+		return sm.cache.close()
+	}
+	return nil
+}
+
 func (sm *StatusManager) set(statusPath string, status Status) (*Status, error) {
 	volumeStatusDir := filepath.Dir(statusPath)
 	if err := os.MkdirAll(volumeStatusDir, 0755); err != nil {
@@ -108,9 +302,24 @@ func (sm *StatusManager) get(statusPath string) (*Status, error) {
 }
 
 func (sm *StatusManager) getWithLock(statusPath string) (*Status, error) {
+	statusPath = filepath.Clean(statusPath)
+
+	if sm.cache != nil {
+		if status, ok := sm.cache.get(statusPath); ok {
+			return status, nil
+		}
+	}
+
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
 
+	var version uint64
+	var info os.FileInfo
+	if sm.cache != nil {
+		version = sm.cache.prepare(statusPath)
+		info, _ = os.Stat(statusPath)
+	}
+
 	status, err := sm.get(statusPath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -119,6 +328,10 @@ func (sm *StatusManager) getWithLock(statusPath string) (*Status, error) {
 		return nil, errors.Wrapf(err, "get status: %s", statusPath)
 	}
 
+	if info != nil {
+		sm.cache.set(statusPath, *status, info, version)
+	}
+
 	return status, nil
 }
 
@@ -126,6 +339,8 @@ func (sm *StatusManager) Set(statusPath string, newStatus Status) (*Status, erro
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
 
+	sm.Invalidate(statusPath)
+
 	status, err := sm.set(statusPath, newStatus)
 	if err != nil {
 		return nil, errors.Wrapf(err, "create new status: %s", statusPath)
@@ -133,6 +348,15 @@ func (sm *StatusManager) Set(statusPath string, newStatus Status) (*Status, erro
 	return status, nil
 }
 
+// Invalidate drops the cached status of the path, or of all the status files
+// under the path if it's a directory, it should be called after removing the
+// status files outside of the StatusManager.
+func (sm *StatusManager) Invalidate(path string) {
+	if sm.cache != nil {
+		sm.cache.invalidate(path)
+	}
+}
+
 func (sm *StatusManager) Get(statusPath string) (*Status, error) {
 	status, err := sm.getWithLock(statusPath)
 	if err != nil {
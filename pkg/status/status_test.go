@@ -26,6 +26,7 @@ func TestMain(m *testing.M) {
 func TestNewStatusManager(t *testing.T) {
 	sm, err := NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 	require.NotNil(t, sm)
 	require.NotNil(t, sm.HookManager)
 }
@@ -36,6 +37,7 @@ func TestStatusManager_SetAndGet(t *testing.T) {
 
 	sm, err := NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 
 	s := Status{
 		VolumeName: "pvc-vol-1",
@@ -57,6 +59,7 @@ func TestStatusManager_SetAndGet(t *testing.T) {
 func TestStatusManager_GetNotExists(t *testing.T) {
 	sm, err := NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 
 	_, err = sm.Get("/non/existent/path/status.json")
 	require.Error(t, err)
@@ -70,6 +73,7 @@ func TestStatusManager_GetEmptyFile(t *testing.T) {
 
 	sm, err := NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 
 	_, err = sm.Get(statusPath)
 	require.Error(t, err)
@@ -82,6 +86,7 @@ func TestStatusManager_GetInvalidJSON(t *testing.T) {
 
 	sm, err := NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 
 	_, err = sm.Get(statusPath)
 	require.Error(t, err)
@@ -93,6 +98,7 @@ func TestStatusManager_OverwriteStatus(t *testing.T) {
 
 	sm, err := NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 
 	_, err = sm.Set(statusPath, Status{State: StatePullRunning})
 	require.NoError(t, err)
@@ -111,6 +117,7 @@ func TestStatusManager_GetWithHookProgress(t *testing.T) {
 
 	sm, err := NewStatusManager()
 	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
 
 	_, err = sm.Set(statusPath, Status{State: StatePullRunning, VolumeName: "vol"})
 	require.NoError(t, err)
@@ -125,6 +132,89 @@ func TestStatusManager_GetWithHookProgress(t *testing.T) {
 	require.Equal(t, 2, got.Progress.Total)
 }
 
+func TestStatusManager_CacheInvalidatedByExternalWrite(t *testing.T) {
+	tmpDir := t.TempDir()
+	statusPath := filepath.Join(tmpDir, "status.json")
+
+	sm, err := NewStatusManager()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
+	require.NotNil(t, sm.cache)
+
+	_, err = sm.Set(statusPath, Status{State: StatePullRunning})
+	require.NoError(t, err)
+
+	got, err := sm.Get(statusPath)
+	require.NoError(t, err)
+	require.Equal(t, StatePullRunning, got.State)
+	_, cached := sm.cache.get(statusPath)
+	require.True(t, cached)
+
+	// Modify the status file outside of the status manager.
+	require.NoError(t, os.WriteFile(statusPath, []byte(`{"state":"MOUNTED"}`), 0644))
+
+	require.Eventually(t, func() bool {
+		got, err := sm.Get(statusPath)
+		return err == nil && got.State == StateMounted
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestStatusManager_Invalidate(t *testing.T) {
+	tmpDir := t.TempDir()
+	volumeDir := filepath.Join(tmpDir, "volume")
+	statusPath := filepath.Join(volumeDir, "status.json")
+
+	sm, err := NewStatusManager()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
+
+	_, err = sm.Set(statusPath, Status{State: StatePullSucceeded})
+	require.NoError(t, err)
+	_, err = sm.Get(statusPath)
+	require.NoError(t, err)
+
+	require.NoError(t, os.RemoveAll(volumeDir))
+	sm.Invalidate(volumeDir)
+
+	_, err = sm.Get(statusPath)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestStatusManager_CacheDroppedByVolumeRemoval(t *testing.T) {
+	volumesDir := t.TempDir()
+	statusPath := filepath.Join(volumesDir, "pvc-1", "status.json")
+
+	sm, err := NewStatusManager()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sm.Close() })
+
+	_, err = sm.Set(statusPath, Status{State: StatePullSucceeded})
+	require.NoError(t, err)
+	_, err = sm.Get(statusPath)
+	require.NoError(t, err)
+	// One watch on the volumes dir instead of one per volume dir.
+	require.True(t, sm.cache.watched[volumesDir])
+	require.NotContains(t, sm.cache.watched, filepath.Dir(statusPath))
+
+	// Remove the volume dir outside of the status manager.
+	require.NoError(t, os.RemoveAll(filepath.Dir(statusPath)))
+	require.Eventually(t, func() bool {
+		sm.cache.mutex.RLock()
+		defer sm.cache.mutex.RUnlock()
+		_, ok := sm.cache.items[statusPath]
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = sm.Get(statusPath)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestStatusManager_Close(t *testing.T) {
+	sm, err := NewStatusManager()
+	require.NoError(t, err)
+	require.NoError(t, sm.Close())
+}
+
 // ─── Progress ─────────────────────────────────────────────────────────────────
 
 func TestProgress_String(t *testing.T) {
@@ -268,3 +358,107 @@ func TestHook_GetTotal_FromManifestLayers(t *testing.T) {
 	// total comes from manifest.Layers
 	require.Equal(t, 2, p.Total)
 }
+
+func TestHook_GetPulledSize(t *testing.T) {
+	h := NewHook(context.Background())
+	manifest := ocispec.Manifest{}
+
+	ok := ocispec.Descriptor{Digest: "sha256:ok", Size: 100}
+	failed := ocispec.Descriptor{Digest: "sha256:failed", Size: 200}
+	running := ocispec.Descriptor{Digest: "sha256:running", Size: 400}
+	h.BeforePullLayer(ok, manifest)
+	h.BeforePullLayer(failed, manifest)
+	h.BeforePullLayer(running, manifest)
+	h.AfterPullLayer(ok, nil)
+	h.AfterPullLayer(failed, os.ErrInvalid)
+
+	require.Equal(t, int64(100), h.GetPulledSize())
+}
+
+func TestHook_MarkPulled(t *testing.T) {
+	h := NewHook(context.Background())
+	h.SetTotal(2)
+
+	h.MarkPulled(ocispec.Descriptor{Digest: "sha256:resumed", Size: 100})
+
+	require.Equal(t, int64(100), h.GetPulledSize())
+	progress := h.GetProgress()
+	require.Equal(t, 2, progress.Total)
+	require.Len(t, progress.Items, 1)
+	require.NotNil(t, progress.Items[0].FinishedAt)
+}
+
+func TestHook_DownloadedBytes(t *testing.T) {
+	h := NewHook(context.Background())
+	manifest := ocispec.Manifest{
+		Layers: []ocispec.Descriptor{
+			{Digest: "sha256:l1", Size: 100},
+			{Digest: "sha256:l2", Size: 300},
+		},
+	}
+	desc := ocispec.Descriptor{Digest: "sha256:l1", Size: 100}
+	h.BeforePullLayer(desc, manifest)
+
+	// The bytes written to the progress writer are counted, up to the size.
+	_, err := h.ProgressWriter(desc.Digest).Write(make([]byte, 40))
+	require.NoError(t, err)
+	p := h.GetProgress()
+	require.Equal(t, int64(40), p.Items[0].DownloadedBytes)
+	require.Equal(t, int64(40), p.DownloadedBytes)
+	require.Equal(t, int64(400), p.TotalBytes)
+	require.Equal(t, 10.0, p.Percentage())
+
+	h.AddDownloadedBytes(desc.Digest, 200)
+	require.Equal(t, int64(100), h.GetProgress().DownloadedBytes)
+
+	// The retried layer starts from the resumed offset.
+	h.SetDownloadedBytes(desc.Digest, 20)
+	require.Equal(t, int64(20), h.GetProgress().DownloadedBytes)
+
+	h.AfterPullLayer(desc, nil)
+	h.MarkPulled(ocispec.Descriptor{Digest: "sha256:l2", Size: 300})
+	p = h.GetProgress()
+	require.Equal(t, int64(400), p.DownloadedBytes)
+	require.Equal(t, 100.0, p.Percentage())
+
+	// The total size set externally takes precedence, e.g. for the filters.
+	h.SetTotalSize(800)
+	require.Equal(t, int64(800), h.GetProgress().TotalBytes)
+}
+
+func TestHook_Throughput(t *testing.T) {
+	h := NewHook(context.Background())
+	desc := ocispec.Descriptor{Digest: "sha256:l1", Size: 1000}
+	h.BeforePullLayer(desc, ocispec.Manifest{Layers: []ocispec.Descriptor{desc}})
+
+	// Not enough samples in the window yet.
+	h.AddDownloadedBytes(desc.Digest, 100)
+	p := h.GetProgress()
+	require.Zero(t, p.Throughput)
+	require.Nil(t, p.ETA)
+
+	now := time.Now()
+	h.samples = []throughputSample{
+		{at: now.Add(-20 * time.Second), bytes: 0},
+		{at: now.Add(-10 * time.Second), bytes: 0},
+		{at: now.Add(-5 * time.Second), bytes: 50},
+	}
+	h.transferred = 100
+	require.Equal(t, int64(10), h.getThroughput(now))
+
+	// The rest of 900 bytes at 10 bytes per second.
+	p = h.GetProgress()
+	require.InDelta(t, 10, p.Throughput, 1)
+	require.InDelta(t, 90, p.RemainingSeconds, 10)
+	require.NotNil(t, p.ETA)
+
+	// The throughput drops as the transfer stalls.
+	require.Equal(t, int64(3), h.getThroughput(now.Add(10*time.Second)))
+
+	// The reset of the downloaded bytes by the retry isn't transferred.
+	h.SetDownloadedBytes(desc.Digest, 0)
+	require.Equal(t, int64(100), h.transferred)
+	h.AfterPullLayer(desc, nil)
+	require.Equal(t, int64(1100), h.transferred)
+	require.Nil(t, h.GetProgress().ETA)
+}
@@ -2,8 +2,11 @@ package utils
 
 import (
 	"context"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -67,3 +70,215 @@ func IsInSameDevice(path1, path2 string) (bool, error) {
 	}
 	return stat1.Dev == stat2.Dev, nil
 }
+
+// resolvePath returns the absolute path with symlinks resolved, the
+// non-existent trailing elements of the path are kept as is.
+func resolvePath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "get absolute path: %s", path)
+	}
+
+	existing := path
+	missing := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(resolved, missing), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", errors.Wrapf(err, "eval symlinks: %s", existing)
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return path, nil
+		}
+		missing = filepath.Join(filepath.Base(existing), missing)
+		existing = parent
+	}
+}
+
+// IsPathUnder checks if the path is located under the base directory
+// (excluding the base directory itself) after resolving symlinks.
+func IsPathUnder(baseDir, path string) (bool, error) {
+	resolvedBaseDir, err := resolvePath(baseDir)
+	if err != nil {
+		return false, errors.Wrapf(err, "resolve base dir: %s", baseDir)
+	}
+	resolvedPath, err := resolvePath(path)
+	if err != nil {
+		return false, errors.Wrapf(err, "resolve path: %s", path)
+	}
+
+	rel, err := filepath.Rel(resolvedBaseDir, resolvedPath)
+	if err != nil {
+		return false, nil
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// LockDir takes an exclusive lock on the directory by flock a lock file under
+// it, the lock is held until the returned file is closed or the process exits.
+func LockDir(dir string) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "create dir: %s", dir)
+	}
+
+	lockPath := filepath.Join(dir, ".lock")
+	file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open lock file: %s", lockPath)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errors.Errorf("dir %s is locked by another process", dir)
+		}
+		return nil, errors.Wrapf(err, "lock file: %s", lockPath)
+	}
+
+	return file, nil
+}
+
+// LinkDir re-creates the directory tree of src under dst, the regular files
+// are hardlinked and the symlinks are copied.
+func LinkDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return errors.Wrapf(err, "get relative path: %s", path)
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir():
+			if err := os.MkdirAll(target, info.Mode().Perm()); err != nil {
+				return errors.Wrapf(err, "create dir: %s", target)
+			}
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return errors.Wrapf(err, "read link: %s", path)
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return errors.Wrapf(err, "create symlink: %s", target)
+			}
+		case info.Mode().IsRegular():
+			if err := os.Link(path, target); err != nil {
+				return errors.Wrapf(err, "link %s to %s", path, target)
+			}
+		}
+
+		return nil
+	})
+}
+
+// CopyDir copies the directory tree of src to dst, the special files (e.g.
+// unix sockets) are skipped.
+func CopyDir(src, dst string) error {
+	return NewDirCopier().Copy(src, dst)
+}
+
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// DirCopier copies the directory trees preserving the hardlinks, a file
+// hardlinked in the source trees is copied once, then hardlinked to the
+// copied file, including across the trees copied by the same copier.
+type DirCopier struct {
+	links map[fileID]string
+}
+
+func NewDirCopier() *DirCopier {
+	return &DirCopier{
+		links: map[fileID]string{},
+	}
+}
+
+// Copy copies the directory tree of src to dst, the special files (e.g.
+// unix sockets) are skipped.
+func (c *DirCopier) Copy(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := entry.Info()
+		if err != nil {
+			return errors.Wrapf(err, "stat: %s", path)
+		}
+
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			if err := os.MkdirAll(target, mode.Perm()); err != nil {
+				return errors.Wrapf(err, "create dir: %s", target)
+			}
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return errors.Wrapf(err, "read link: %s", path)
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return errors.Wrapf(err, "create link: %s", target)
+			}
+		case mode.IsRegular():
+			stat, ok := info.Sys().(*syscall.Stat_t)
+			if !ok || stat.Nlink <= 1 {
+				return copyFile(path, target, mode.Perm())
+			}
+			id := fileID{dev: uint64(stat.Dev), ino: stat.Ino}
+			if copied, ok := c.links[id]; ok {
+				if err := os.Link(copied, target); err != nil {
+					return errors.Wrapf(err, "link %s to %s", copied, target)
+				}
+				return nil
+			}
+			if err := copyFile(path, target, mode.Perm()); err != nil {
+				return err
+			}
+			c.links[id] = target
+		}
+
+		return nil
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return errors.Wrapf(err, "open file: %s", src)
+	}
+	defer func() { _ = srcFile.Close() }()
+
+	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return errors.Wrapf(err, "create file: %s", dst)
+	}
+	defer func() { _ = dstFile.Close() }()
+
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		return errors.Wrapf(err, "copy file: %s", src)
+	}
+
+	if err := dstFile.Sync(); err != nil {
+		return errors.Wrapf(err, "sync file: %s", dst)
+	}
+
+	return nil
+}
@@ -113,3 +113,98 @@ func TestIsInSameDevice_NonExistent(t *testing.T) {
 	_, err := IsInSameDevice("/non/existent/path1", "/non/existent/path2")
 	require.Error(t, err)
 }
+
+func TestIsPathUnder(t *testing.T) {
+	baseDir := t.TempDir()
+	outsideDir := t.TempDir()
+	podsDir := filepath.Join(baseDir, "pods")
+	require.NoError(t, os.MkdirAll(filepath.Join(podsDir, "uid-1"), 0755))
+	require.NoError(t, os.Symlink(outsideDir, filepath.Join(podsDir, "uid-1", "escape")))
+
+	for _, tc := range []struct {
+		path  string
+		under bool
+	}{
+		{filepath.Join(podsDir, "uid-1", "volumes", "mount"), true},
+		{filepath.Join(podsDir, "uid-1"), true},
+		{podsDir, false},
+		{filepath.Join(podsDir, "..", "plugins"), false},
+		{filepath.Join(podsDir, "uid-1", "..", "..", "..", "etc"), false},
+		{filepath.Join(podsDir, "uid-1", "escape", "mount"), false},
+		{outsideDir, false},
+		{"/etc", false},
+	} {
+		under, err := IsPathUnder(podsDir, tc.path)
+		require.NoError(t, err)
+		require.Equal(t, tc.under, under, tc.path)
+	}
+}
+
+func TestLockDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "root")
+
+	lock, err := LockDir(dir)
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(dir, ".lock"))
+
+	_, err = LockDir(dir)
+	require.ErrorContains(t, err, "locked by another process")
+
+	require.NoError(t, lock.Close())
+	lock, err = LockDir(dir)
+	require.NoError(t, err)
+	require.NoError(t, lock.Close())
+}
+
+func TestLinkDir(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	dst := filepath.Join(t.TempDir(), "dst")
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "sub", "a.bin"), []byte("foo"), 0644))
+	require.NoError(t, os.Symlink("sub/a.bin", filepath.Join(src, "link")))
+
+	require.NoError(t, LinkDir(src, dst))
+
+	srcInfo, err := os.Stat(filepath.Join(src, "sub", "a.bin"))
+	require.NoError(t, err)
+	dstInfo, err := os.Stat(filepath.Join(dst, "sub", "a.bin"))
+	require.NoError(t, err)
+	require.True(t, os.SameFile(srcInfo, dstInfo))
+
+	link, err := os.Readlink(filepath.Join(dst, "link"))
+	require.NoError(t, err)
+	require.Equal(t, "sub/a.bin", link)
+
+	// The source dir must exist.
+	require.Error(t, LinkDir(filepath.Join(t.TempDir(), "missing"), dst))
+}
+
+func TestDirCopier(t *testing.T) {
+	root := t.TempDir()
+	blobs := filepath.Join(root, "blobs")
+	model := filepath.Join(root, "model")
+	require.NoError(t, os.MkdirAll(blobs, 0755))
+	require.NoError(t, os.MkdirAll(model, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(blobs, "a"), []byte("foo"), 0644))
+	require.NoError(t, os.Link(filepath.Join(blobs, "a"), filepath.Join(model, "a.bin")))
+	require.NoError(t, os.WriteFile(filepath.Join(model, "b.bin"), []byte("bar"), 0644))
+
+	dst := t.TempDir()
+	copier := NewDirCopier()
+	require.NoError(t, copier.Copy(blobs, filepath.Join(dst, "blobs")))
+	require.NoError(t, copier.Copy(model, filepath.Join(dst, "model")))
+
+	// The hardlinks across the copied trees are preserved.
+	blobInfo, err := os.Stat(filepath.Join(dst, "blobs", "a"))
+	require.NoError(t, err)
+	fileInfo, err := os.Stat(filepath.Join(dst, "model", "a.bin"))
+	require.NoError(t, err)
+	require.True(t, os.SameFile(blobInfo, fileInfo))
+	srcInfo, err := os.Stat(filepath.Join(blobs, "a"))
+	require.NoError(t, err)
+	require.False(t, os.SameFile(srcInfo, blobInfo))
+
+	data, err := os.ReadFile(filepath.Join(dst, "model", "b.bin"))
+	require.NoError(t, err)
+	require.Equal(t, "bar", string(data))
+}